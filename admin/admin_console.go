@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -36,6 +37,7 @@ type Server struct {
 	streamsTemplate           *template.Template
 	configTemplate            *template.Template
 	clusterTemplate           *template.Template
+	explainTemplate           *template.Template
 	dbStats                   *databaseStats
 	lastDbStatsRequestTime    time.Time
 	topicsData                []topicData
@@ -75,6 +77,10 @@ func NewServer(cfg *conf.Config, levelMgrClient levels.Client, streamManager ope
 	if err != nil {
 		return nil, err
 	}
+	explainTemplate, err := template.New("explain").Funcs(funcMap).Parse(explainTemplate)
+	if err != nil {
+		return nil, err
+	}
 	return &Server{
 		cfg:              cfg,
 		levelMgrClient:   levelMgrClient,
@@ -86,6 +92,7 @@ func NewServer(cfg *conf.Config, levelMgrClient levels.Client, streamManager ope
 		streamsTemplate:  streamsTemplate,
 		configTemplate:   configTemplate,
 		clusterTemplate:  clusterTemplate,
+		explainTemplate:  explainTemplate,
 		startTime:        arista.NanoTime(),
 	}, nil
 }
@@ -107,6 +114,7 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/streams", s.ServeStreams)
 	mux.HandleFunc("/config", s.ServeConfig)
 	mux.HandleFunc("/cluster", s.ServeCluster)
+	mux.HandleFunc("/explain", s.ServeExplain)
 
 	listenAddress := s.cfg.AdminConsoleAddresses[s.cfg.NodeID]
 	s.listener, err = common.Listen("tcp", listenAddress)
@@ -298,6 +306,13 @@ type streamData struct {
 	OutPartitions int
 	OutMapping    string
 	ChildStreams  string
+	RowsIn        uint64
+	RowsOut       uint64
+	StateReads    uint64
+	StateWrites   uint64
+	ProcTime      string
+	WaterMarkLag  string
+	Backlog       string
 }
 
 func (s *Server) getStreamsData() []streamData {
@@ -338,6 +353,9 @@ func (s *Server) getStreamsData() []streamData {
 			OutMapping:    stream.OutSchema.MappingID,
 			ChildStreams:  childStr,
 		}
+		if stats, ok := s.streamManager.GetStreamStats(sd.Name); ok {
+			setStreamStatsData(&sd, stats)
+		}
 		streamsData = append(streamsData, sd)
 	}
 	// sort by stream name
@@ -349,6 +367,33 @@ func (s *Server) getStreamsData() []streamData {
 	return s.streamsData
 }
 
+// setStreamStatsData sets the rows in of the first operator, the rows out of the last operator, and the totals of the
+// other metrics over all the operators of the stream
+func setStreamStatsData(sd *streamData, stats *opers.StreamStats) {
+	if len(stats.Operators) == 0 {
+		return
+	}
+	sd.RowsIn = stats.Operators[0].RowsIn
+	sd.RowsOut = stats.Operators[len(stats.Operators)-1].RowsOut
+	var procTime time.Duration
+	waterMarkLag := time.Duration(-1)
+	for _, op := range stats.Operators {
+		sd.StateReads += op.StateReads
+		sd.StateWrites += op.StateWrites
+		procTime += op.ProcessingTime
+		if op.WaterMarkLag > waterMarkLag {
+			waterMarkLag = op.WaterMarkLag
+		}
+	}
+	sd.ProcTime = procTime.String()
+	if waterMarkLag != -1 {
+		sd.WaterMarkLag = waterMarkLag.String()
+	}
+	if stats.Backlog != -1 {
+		sd.Backlog = strconv.FormatInt(stats.Backlog, 10)
+	}
+}
+
 func (s *Server) ServeStreams(response http.ResponseWriter, _ *http.Request) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -368,6 +413,79 @@ func (s *Server) ServeStreams(response http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+type explainData struct {
+	StreamName string
+	Operators  []explainOperatorData
+}
+
+type explainOperatorData struct {
+	ID              int
+	ParentID        string
+	Operator        string
+	Definition      string
+	InSchema        string
+	OutSchema       string
+	MappingID       string
+	Partitions      int
+	Processors      int
+	Repartition     bool
+	SlabIDs         string
+	Retention       string
+	UpstreamStreams string
+	ChildStreams    string
+}
+
+func (s *Server) ServeExplain(response http.ResponseWriter, request *http.Request) {
+	streamName := request.URL.Query().Get("stream")
+	plan, ok := s.streamManager.ExplainStream(streamName)
+	if !ok {
+		http.Error(response, fmt.Sprintf("unknown stream '%s'", streamName), http.StatusNotFound)
+		return
+	}
+	data := explainData{StreamName: streamName}
+	for _, op := range plan {
+		opData := explainOperatorData{
+			ID:              op.ID,
+			Operator:        op.Operator,
+			Definition:      op.Definition,
+			OutSchema:       op.OutSchema.EventSchema.String(),
+			MappingID:       op.OutSchema.MappingID,
+			Partitions:      op.Partitions,
+			Processors:      len(op.OutSchema.ProcessorIDs),
+			Repartition:     op.Repartition,
+			UpstreamStreams: strings.Join(op.UpstreamStreams, ", "),
+			ChildStreams:    strings.Join(op.ChildStreams, ", "),
+		}
+		if op.ParentID != -1 {
+			opData.ParentID = strconv.Itoa(op.ParentID)
+		}
+		if op.InSchema != nil {
+			opData.InSchema = op.InSchema.EventSchema.String()
+		}
+		var slabIDs []string
+		for _, slabID := range op.SlabIDs {
+			slabIDs = append(slabIDs, strconv.Itoa(slabID))
+		}
+		opData.SlabIDs = strings.Join(slabIDs, ", ")
+		if op.Retention != 0 {
+			opData.Retention = op.Retention.String()
+		}
+		data.Operators = append(data.Operators, opData)
+	}
+	html := strings.Builder{}
+	err := s.explainTemplate.Execute(&html, data)
+	if err != nil {
+		s.handleError(err, response)
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/html")
+	_, err = response.Write([]byte(html.String()))
+	if err != nil {
+		log.Errorf("failed to write admin response: %v", err)
+	}
+}
+
 func (s *Server) ServeConfig(response http.ResponseWriter, _ *http.Request) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
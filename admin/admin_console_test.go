@@ -228,10 +228,17 @@ func TestStreams(t *testing.T) {
 	<th>Out Partitions</th>
 	<th>Out Mapping</th>
 	<th>Child Streams</th>
+	<th>Rows In</th>
+	<th>Rows Out</th>
+	<th>State Reads</th>
+	<th>State Writes</th>
+	<th>Processing Time</th>
+	<th>Watermark Lag</th>
+	<th>Backlog</th>
 </tr>
 
 <tr>
-	<td>child_stream_a</td>
+	<td><a href="explain?stream=child_stream_a">child_stream_a</a></td>
 	<td>stream_abc -&gt; (to stream)</td>
 	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
 	<td>23</td>
@@ -240,10 +247,17 @@ func TestStreams(t *testing.T) {
 	<td>23</td>
 	<td>mapping1</td>
 	<td></td>
+	<td>0</td>
+	<td>0</td>
+	<td>0</td>
+	<td>0</td>
+	<td></td>
+	<td></td>
+	<td></td>
 </tr>
 
 <tr>
-	<td>child_stream_b</td>
+	<td><a href="explain?stream=child_stream_b">child_stream_b</a></td>
 	<td>stream_abc -&gt; (to stream)</td>
 	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
 	<td>23</td>
@@ -252,10 +266,17 @@ func TestStreams(t *testing.T) {
 	<td>23</td>
 	<td>mapping1</td>
 	<td></td>
+	<td>0</td>
+	<td>0</td>
+	<td>0</td>
+	<td>0</td>
+	<td></td>
+	<td></td>
+	<td></td>
 </tr>
 
 <tr>
-	<td>stream_abc</td>
+	<td><a href="explain?stream=stream_abc">stream_abc</a></td>
 	<td>(bridge from topicA partitions=10)-&gt;(to stream)</td>
 	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
 	<td>23</td>
@@ -264,10 +285,17 @@ func TestStreams(t *testing.T) {
 	<td>23</td>
 	<td>mapping1</td>
 	<td>child_stream_a, child_stream_ab</td>
+	<td>100</td>
+	<td>100</td>
+	<td>0</td>
+	<td>110</td>
+	<td>5ms</td>
+	<td>1.5s</td>
+	<td>25</td>
 </tr>
 
 <tr>
-	<td>stream_xyz</td>
+	<td><a href="explain?stream=stream_xyz">stream_xyz</a></td>
 	<td>(bridge from topicA partitions=10)-&gt;(partition by key partitions=20)-&gt;(to stream)</td>
 	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
 	<td>23</td>
@@ -276,6 +304,13 @@ func TestStreams(t *testing.T) {
 	<td>20</td>
 	<td>mapping2</td>
 	<td></td>
+	<td>0</td>
+	<td>0</td>
+	<td>0</td>
+	<td>0</td>
+	<td></td>
+	<td></td>
+	<td></td>
 </tr>
 
 </table>
@@ -385,6 +420,113 @@ func TestStreams(t *testing.T) {
 	testAdminConsole(t, "streams", levels.Stats{}, nil, streamInfos, nil, nil, expected)
 }
 
+func TestExplain(t *testing.T) {
+
+	expected :=
+		`<html>
+<head>
+<link href='https://fonts.googleapis.com/css?family=Roboto:400' rel='stylesheet' type='text/css'>
+<style>body {font-family: 'Roboto', sans-serif;}</style>
+<title>Tektite Explain</title>
+</head>
+<body>
+<h1>Explain stream_abc</h1>
+<table border="1" width="100%">
+<tr>
+	<th>ID</th>
+	<th>Parent ID</th>
+	<th>Operator</th>
+	<th width="15%">Definition</th>
+	<th width="15%">In Schema</th>
+	<th width="15%">Out Schema</th>
+	<th>Mapping</th>
+	<th>Partitions</th>
+	<th>Processors</th>
+	<th>Repartition</th>
+	<th>Slab IDs</th>
+	<th>Retention</th>
+	<th>Upstream Streams</th>
+	<th>Child Streams</th>
+</tr>
+
+<tr>
+	<td>0</td>
+	<td></td>
+	<td>bridge from</td>
+	<td>(bridge from topicA partitions=10)</td>
+	<td></td>
+	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
+	<td>mapping1</td>
+	<td>10</td>
+	<td>0</td>
+	<td>false</td>
+	<td>1000, 1001</td>
+	<td></td>
+	<td></td>
+	<td></td>
+</tr>
+
+<tr>
+	<td>1</td>
+	<td>0</td>
+	<td>partition</td>
+	<td>(partition by key partitions=20)</td>
+	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
+	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
+	<td>mapping2</td>
+	<td>20</td>
+	<td>0</td>
+	<td>true</td>
+	<td></td>
+	<td></td>
+	<td></td>
+	<td></td>
+</tr>
+
+<tr>
+	<td>2</td>
+	<td>1</td>
+	<td>store stream</td>
+	<td>(store stream retention=1h)</td>
+	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
+	<td>offset: int, event_time: timestamp, key: bytes, hdrs: bytes, val: bytes</td>
+	<td>mapping2</td>
+	<td>20</td>
+	<td>0</td>
+	<td>false</td>
+	<td>1002</td>
+	<td>1h0m0s</td>
+	<td></td>
+	<td>child_stream_a, child_stream_b</td>
+</tr>
+
+</table>
+</body>
+</html>
+`
+	testAdminConsole(t, "explain?stream=stream_abc", levels.Stats{}, nil, nil, nil, nil, expected)
+}
+
+func TestExplainUnknownStream(t *testing.T) {
+	address, err := common.AddressWithPort("localhost")
+	require.NoError(t, err)
+	cfg := &conf.Config{}
+	cfg.ApplyDefaults()
+	cfg.AdminConsoleEnabled = true
+	cfg.AdminConsoleAddresses = []string{address}
+	webui, err := NewServer(cfg, &testLevelMgrClient{}, &testStreamManager{}, &testProcessorManager{})
+	require.NoError(t, err)
+	err = webui.Start()
+	require.NoError(t, err)
+	defer func() {
+		err := webui.Stop()
+		require.NoError(t, err)
+	}()
+	resp, err := createClient(t).Get(fmt.Sprintf("http://%s/explain?stream=unknown", address))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
 func TestConfig(t *testing.T) {
 
 	expected :=
@@ -753,6 +895,10 @@ func (t *testLevelMgrClient) LoadLastFlushedVersion() (int64, error) {
 	return 0, nil
 }
 
+func (t *testLevelMgrClient) GetOldestRetainedVersion() (int64, error) {
+	return 0, nil
+}
+
 func (t *testLevelMgrClient) GetStats() (levels.Stats, error) {
 	return t.stats, nil
 }
@@ -810,10 +956,57 @@ func (t *testStreamManager) UndeployStream(parser.DeleteStreamDesc, int64) error
 	panic("not implemented")
 }
 
+func (t *testStreamManager) AlterStream(parser.CreateStreamDesc, []int, []int, string, int64) error {
+	panic("not implemented")
+}
+
 func (t *testStreamManager) GetStream(string) *opers.StreamInfo {
 	panic("not implemented")
 }
 
+func (t *testStreamManager) ExplainStream(streamName string) ([]*opers.OperatorPlan, bool) {
+	if streamName != "stream_abc" {
+		return nil, false
+	}
+	schema1 := &opers.OperatorSchema{
+		EventSchema:     opers.KafkaSchema,
+		PartitionScheme: opers.PartitionScheme{Partitions: 10, MappingID: "mapping1"},
+	}
+	schema2 := &opers.OperatorSchema{
+		EventSchema:     opers.KafkaSchema,
+		PartitionScheme: opers.PartitionScheme{Partitions: 20, MappingID: "mapping2"},
+	}
+	return []*opers.OperatorPlan{
+		{ID: 0, ParentID: -1, Operator: "bridge from", Definition: "(bridge from topicA partitions=10)",
+			OutSchema: schema1, Partitions: 10, SlabIDs: []int{1000, 1001}},
+		{ID: 1, ParentID: 0, Operator: "partition", Definition: "(partition by key partitions=20)",
+			InSchema: schema1, OutSchema: schema2, Partitions: 20, Repartition: true},
+		{ID: 2, ParentID: 1, Operator: "store stream", Definition: "(store stream retention=1h)",
+			InSchema: schema2, OutSchema: schema2, Partitions: 20, SlabIDs: []int{1002}, Retention: time.Hour,
+			ChildStreams: []string{"child_stream_a", "child_stream_b"}},
+	}, true
+}
+
+func (t *testStreamManager) GetStreamStats(streamName string) (*opers.StreamStats, bool) {
+	if streamName != "stream_abc" {
+		return nil, false
+	}
+	return &opers.StreamStats{
+		StreamName: streamName,
+		Backlog:    25,
+		Operators: []*opers.OperatorStats{
+			{ID: 0, Operator: "bridge from", RowsIn: 100, RowsOut: 100, Batches: 10, StateWrites: 10,
+				ProcessingTime: 2 * time.Millisecond, WaterMarkLag: 1500 * time.Millisecond},
+			{ID: 1, Operator: "store stream", RowsIn: 100, RowsOut: 100, Batches: 10, StateWrites: 100,
+				ProcessingTime: 3 * time.Millisecond, WaterMarkLag: -1},
+		},
+	}, true
+}
+
+func (t *testStreamManager) GetAllStreamStats() []*opers.StreamStats {
+	panic("not implemented")
+}
+
 func (t *testStreamManager) GetAllStreams() []*opers.StreamInfo {
 	return t.allStreams
 }
@@ -886,6 +1079,26 @@ func (t *testStreamManager) Dump() {
 	panic("not implemented")
 }
 
+func (t *testStreamManager) SavepointStream(parser.SavepointDesc, int) error {
+	panic("not implemented")
+}
+
+func (t *testStreamManager) LoadSavepoint(parser.CreateStreamDesc, []int, []int) (*opers.LoadedStream, error) {
+	panic("not implemented")
+}
+
+func (t *testStreamManager) DeployLoadedStream(*opers.LoadedStream, string, int64) error {
+	panic("not implemented")
+}
+
+func (t *testStreamManager) DiscardLoadedStream(*opers.LoadedStream) {
+	panic("not implemented")
+}
+
+func (t *testStreamManager) PinSchemaVersions(*parser.CreateStreamDesc, string) (string, error) {
+	panic("not implemented")
+}
+
 type testProcessorManager struct {
 	groupStates map[int]clustmgr.GroupState
 }
@@ -128,10 +128,17 @@ var streamsTemplate = `<html>
 	<th>Out Partitions</th>
 	<th>Out Mapping</th>
 	<th>Child Streams</th>
+	<th>Rows In</th>
+	<th>Rows Out</th>
+	<th>State Reads</th>
+	<th>State Writes</th>
+	<th>Processing Time</th>
+	<th>Watermark Lag</th>
+	<th>Backlog</th>
 </tr>
 {{range .}}
 <tr>
-	<td>{{.Name}}</td>
+	<td><a href="explain?stream={{.Name}}">{{.Name}}</a></td>
 	<td>{{.StreamDef}}</td>
 	<td>{{.InSchema}}</td>
 	<td>{{.InPartitions}}</td>
@@ -140,6 +147,61 @@ var streamsTemplate = `<html>
 	<td>{{.OutPartitions}}</td>
 	<td>{{.OutMapping}}</td>
 	<td>{{.ChildStreams}}</td>
+	<td>{{.RowsIn}}</td>
+	<td>{{.RowsOut}}</td>
+	<td>{{.StateReads}}</td>
+	<td>{{.StateWrites}}</td>
+	<td>{{.ProcTime}}</td>
+	<td>{{.WaterMarkLag}}</td>
+	<td>{{.Backlog}}</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`
+
+var explainTemplate = `<html>
+<head>
+<link href='https://fonts.googleapis.com/css?family=Roboto:400' rel='stylesheet' type='text/css'>
+<style>body {font-family: 'Roboto', sans-serif;}</style>
+<title>Tektite Explain</title>
+</head>
+<body>
+<h1>Explain {{.StreamName}}</h1>
+<table border="1" width="100%">
+<tr>
+	<th>ID</th>
+	<th>Parent ID</th>
+	<th>Operator</th>
+	<th width="15%">Definition</th>
+	<th width="15%">In Schema</th>
+	<th width="15%">Out Schema</th>
+	<th>Mapping</th>
+	<th>Partitions</th>
+	<th>Processors</th>
+	<th>Repartition</th>
+	<th>Slab IDs</th>
+	<th>Retention</th>
+	<th>Upstream Streams</th>
+	<th>Child Streams</th>
+</tr>
+{{range .Operators}}
+<tr>
+	<td>{{.ID}}</td>
+	<td>{{.ParentID}}</td>
+	<td>{{.Operator}}</td>
+	<td>{{.Definition}}</td>
+	<td>{{.InSchema}}</td>
+	<td>{{.OutSchema}}</td>
+	<td>{{.MappingID}}</td>
+	<td>{{.Partitions}}</td>
+	<td>{{.Processors}}</td>
+	<td>{{.Repartition}}</td>
+	<td>{{.SlabIDs}}</td>
+	<td>{{.Retention}}</td>
+	<td>{{.UpstreamStreams}}</td>
+	<td>{{.ChildStreams}}</td>
 </tr>
 {{end}}
 </table>
@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/control"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/objstore/metering"
+	"github.com/spirit-labs/tektite/replication"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// adminServer serves the admin api of the agent - metrics in the Prometheus text format, reports, and operations on
+// the tables in the object store
+type adminServer struct {
+	lock       sync.Mutex
+	address    string
+	meter      *metering.Meter
+	controller *control.Controller
+	replicator *replication.Replicator
+	httpServer *http.Server
+	listener   net.Listener
+	closeWg    sync.WaitGroup
+}
+
+func newAdminServer(address string, meter *metering.Meter, controller *control.Controller,
+	replicator *replication.Replicator) *adminServer {
+	return &adminServer{
+		address:    address,
+		meter:      meter,
+		controller: controller,
+		replicator: replicator,
+	}
+}
+
+func (a *adminServer) start() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", a.handleMetrics)
+	mux.HandleFunc("GET /objstore/report", a.handleObjStoreReport)
+	mux.HandleFunc("POST /tables/gc", a.handleTableGC)
+	mux.HandleFunc("GET /tables/fsck", a.handleTableFsck)
+	mux.HandleFunc("GET /replication/status", a.handleReplicationStatus)
+	mux.HandleFunc("POST /backups", a.handleCreateBackup)
+	mux.HandleFunc("GET /backups", a.handleListBackups)
+	mux.HandleFunc("DELETE /backups/{id}", a.handleDeleteBackup)
+	mux.HandleFunc("POST /backups/{id}/restore-topic", a.handleRestoreTopic)
+	a.httpServer = &http.Server{
+		Handler: mux,
+	}
+	var err error
+	a.listener, err = common.Listen("tcp", a.address)
+	if err != nil {
+		return err
+	}
+	a.closeWg = sync.WaitGroup{}
+	a.closeWg.Add(1)
+	httpServer, listener := a.httpServer, a.listener
+	common.Go(func() {
+		defer a.closeWg.Done()
+		err := httpServer.Serve(listener)
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("failed to start the admin server: %v", err)
+		}
+	})
+	return nil
+}
+
+func (a *adminServer) stop() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.httpServer == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	a.closeWg.Wait()
+	a.httpServer = nil
+	return nil
+}
+
+func (a *adminServer) listenAddress() string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.listener == nil {
+		return ""
+	}
+	return a.listener.Addr().String()
+}
+
+func (a *adminServer) handleMetrics(response http.ResponseWriter, _ *http.Request) {
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := a.meter.WriteMetrics(response); err != nil {
+		log.Errorf("failed to write metrics response: %v", err)
+	}
+}
+
+func (a *adminServer) handleObjStoreReport(response http.ResponseWriter, _ *http.Request) {
+	response.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(response).Encode(a.meter.Report()); err != nil {
+		log.Errorf("failed to write object store report response: %v", err)
+	}
+}
+
+// handleTableGC runs the table garbage collector, deleting orphaned tables. It must be called on the agent which is
+// the cluster leader.
+func (a *adminServer) handleTableGC(response http.ResponseWriter, _ *http.Request) {
+	a.runTableGC(response, false)
+}
+
+// handleTableFsck runs the table garbage collector as a dry run, reporting orphaned tables and dangling references
+// without deleting anything. It must be called on the agent which is the cluster leader.
+func (a *adminServer) handleTableFsck(response http.ResponseWriter, _ *http.Request) {
+	a.runTableGC(response, true)
+}
+
+func (a *adminServer) runTableGC(response http.ResponseWriter, dryRun bool) {
+	report, err := a.controller.RunTableGC(dryRun)
+	if err != nil {
+		writeAdminError(response, err)
+		return
+	}
+	writeAdminJSON(response, report)
+}
+
+// handleReplicationStatus returns the status of replication. Replication is only active on the agent which is the
+// cluster leader.
+func (a *adminServer) handleReplicationStatus(response http.ResponseWriter, _ *http.Request) {
+	if a.replicator == nil {
+		http.Error(response, "replication is not enabled", http.StatusNotFound)
+		return
+	}
+	response.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(response).Encode(a.replicator.Status()); err != nil {
+		log.Errorf("failed to write replication status response: %v", err)
+	}
+}
+
+// handleCreateBackup creates a backup of the cluster. It must be called on the agent which is the cluster leader.
+func (a *adminServer) handleCreateBackup(response http.ResponseWriter, _ *http.Request) {
+	info, err := a.controller.CreateBackup()
+	if err != nil {
+		writeAdminError(response, err)
+		return
+	}
+	writeAdminJSON(response, info)
+}
+
+// handleListBackups lists the backups of the cluster. It must be called on the agent which is the cluster leader.
+func (a *adminServer) handleListBackups(response http.ResponseWriter, _ *http.Request) {
+	infos, err := a.controller.ListBackups()
+	if err != nil {
+		writeAdminError(response, err)
+		return
+	}
+	writeAdminJSON(response, infos)
+}
+
+// handleDeleteBackup deletes a backup. It must be called on the agent which is the cluster leader.
+func (a *adminServer) handleDeleteBackup(response http.ResponseWriter, request *http.Request) {
+	if err := a.controller.DeleteBackup(request.PathValue("id")); err != nil {
+		writeAdminError(response, err)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// handleRestoreTopic restores the topic named by the "topic" query parameter from a backup, as a new topic named by
+// the "as" query parameter. It must be called on the agent which is the cluster leader.
+func (a *adminServer) handleRestoreTopic(response http.ResponseWriter, request *http.Request) {
+	topicName := request.URL.Query().Get("topic")
+	restoredName := request.URL.Query().Get("as")
+	if topicName == "" || restoredName == "" {
+		http.Error(response, "the topic and as query parameters must be specified", http.StatusBadRequest)
+		return
+	}
+	info, err := a.controller.RestoreTopic(request.PathValue("id"), topicName, restoredName)
+	if err != nil {
+		writeAdminError(response, err)
+		return
+	}
+	writeAdminJSON(response, info)
+}
+
+func writeAdminError(response http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if common.IsUnavailableError(err) {
+		status = http.StatusServiceUnavailable
+	} else if errors.Is(err, control.ErrBackupNotFound) || common.IsTektiteErrorWithCode(err, common.TopicDoesNotExist) {
+		status = http.StatusNotFound
+	} else if common.IsTektiteErrorWithCode(err, common.TopicAlreadyExists) {
+		status = http.StatusConflict
+	}
+	http.Error(response, err.Error(), status)
+}
+
+func writeAdminJSON(response http.ResponseWriter, v any) {
+	response.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(response).Encode(v); err != nil {
+		log.Errorf("failed to write admin response: %v", err)
+	}
+}
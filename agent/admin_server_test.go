@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spirit-labs/tektite/apiclient"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/control"
+	"github.com/spirit-labs/tektite/kafkaprotocol"
+	"github.com/spirit-labs/tektite/objstore/metering"
+	"github.com/spirit-labs/tektite/sst"
+	"github.com/spirit-labs/tektite/testutils"
+	"github.com/spirit-labs/tektite/topicmeta"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminServerObjStoreReport(t *testing.T) {
+	topicName := "test-topic-1"
+	topicInfos := []topicmeta.TopicInfo{
+		{
+			Name:                topicName,
+			PartitionCount:      10,
+			MaxMessageSizeBytes: math.MaxInt,
+		},
+	}
+	cfg := NewConf()
+	var err error
+	cfg.AdminListenAddress, err = common.AddressWithPort("localhost")
+	require.NoError(t, err)
+	agent, _, tearDown := setupAgent(t, topicInfos, cfg)
+	defer tearDown(t)
+
+	req := kafkaprotocol.ProduceRequest{
+		Acks:      -1,
+		TimeoutMs: 1234,
+		TopicData: []kafkaprotocol.ProduceRequestTopicProduceData{
+			{
+				Name: common.StrPtr(topicName),
+				PartitionData: []kafkaprotocol.ProduceRequestPartitionProduceData{
+					{
+						Index:   3,
+						Records: testutils.CreateKafkaRecordBatchWithIncrementingKVs(0, 10),
+					},
+				},
+			},
+		},
+	}
+	cl, err := apiclient.NewKafkaApiClient()
+	require.NoError(t, err)
+	conn, err := cl.NewConnection(agent.Conf().KafkaListenerConfig.Address)
+	require.NoError(t, err)
+	defer func() {
+		err := conn.Close()
+		require.NoError(t, err)
+	}()
+	var resp kafkaprotocol.ProduceResponse
+	_, err = conn.SendRequest(&req, kafkaprotocol.APIKeyProduce, 3, &resp)
+	require.NoError(t, err)
+	require.Equal(t, int16(kafkaprotocol.ErrorCodeNone), resp.Responses[0].PartitionResponses[0].ErrorCode)
+
+	// The produced batch is pushed to the object store in a table
+	dataBucket := agent.Conf().PusherConf.DataBucketName
+	pusherEntry, ok := findReportEntry(agent.ObjStoreReport(), metering.SubsystemPusher, dataBucket)
+	require.True(t, ok)
+	require.Greater(t, pusherEntry.PutRequests, int64(0))
+	require.Greater(t, pusherEntry.BytesWritten, int64(0))
+	require.Greater(t, pusherEntry.EstimatedCost, float64(0))
+
+	body := httpGet(t, fmt.Sprintf("http://%s/objstore/report", agent.AdminListenAddress()))
+	var report metering.Report
+	require.NoError(t, json.Unmarshal(body, &report))
+	pusherEntry2, ok := findReportEntry(report, metering.SubsystemPusher, dataBucket)
+	require.True(t, ok)
+	require.GreaterOrEqual(t, pusherEntry2.PutRequests, pusherEntry.PutRequests)
+	require.Greater(t, report.TotalEstimatedCost, float64(0))
+
+	body = httpGet(t, fmt.Sprintf("http://%s/metrics", agent.AdminListenAddress()))
+	require.True(t, strings.Contains(string(body),
+		fmt.Sprintf(`tektite_objstore_requests_total{subsystem="pusher",bucket="%s",type="put"}`, dataBucket)))
+}
+
+func TestAdminServerTableGC(t *testing.T) {
+	cfg := NewConf()
+	var err error
+	cfg.AdminListenAddress, err = common.AddressWithPort("localhost")
+	require.NoError(t, err)
+	gracePeriod := 100 * time.Millisecond
+	cfg.ControllerConf.TableGCInterval = 0
+	cfg.ControllerConf.TableGCGracePeriod = gracePeriod
+	agent, objStore, tearDown := setupAgentWithoutTopics(t, cfg)
+	defer tearDown(t)
+
+	dataBucket := agent.Conf().ControllerConf.SSTableBucketName
+	orphanID := sst.CreateSSTableId()
+	err = objStore.Put(context.Background(), dataBucket, orphanID, []byte("foo"))
+	require.NoError(t, err)
+	time.Sleep(gracePeriod)
+
+	// fsck reports the orphan without deleting it
+	var report control.TableGCReport
+	testutils.WaitUntil(t, func() (bool, error) {
+		// wait for the agent to become leader
+		resp, err := http.Get(fmt.Sprintf("http://%s/tables/fsck", agent.AdminListenAddress()))
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			require.NoError(t, resp.Body.Close())
+		}()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			return false, nil
+		}
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return true, json.NewDecoder(resp.Body).Decode(&report)
+	})
+	require.True(t, report.DryRun)
+	require.Equal(t, []string{orphanID}, report.OrphanedTables)
+	require.Equal(t, 0, len(report.DanglingReferences))
+	v, err := objStore.Get(context.Background(), dataBucket, orphanID)
+	require.NoError(t, err)
+	require.NotNil(t, v)
+
+	resp, err := http.Post(fmt.Sprintf("http://%s/tables/gc", agent.AdminListenAddress()), "", nil)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	report = control.TableGCReport{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	require.False(t, report.DryRun)
+	require.Equal(t, []string{orphanID}, report.OrphanedTables)
+	v, err = objStore.Get(context.Background(), dataBucket, orphanID)
+	require.NoError(t, err)
+	require.Nil(t, v)
+}
+
+func TestAdminServerNotStarted(t *testing.T) {
+	agent, _, tearDown := setupAgent(t, nil, NewConf())
+	defer tearDown(t)
+	require.Equal(t, "", agent.AdminListenAddress())
+}
+
+func findReportEntry(report metering.Report, subsystem string, bucket string) (metering.ReportEntry, bool) {
+	for _, entry := range report.Entries {
+		if entry.Subsystem == subsystem && entry.Bucket == bucket {
+			return entry, true
+		}
+	}
+	return metering.ReportEntry{}, false
+}
+
+func httpGet(t *testing.T, url string) []byte {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return body
+}
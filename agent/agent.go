@@ -12,8 +12,12 @@ import (
 	"github.com/spirit-labs/tektite/kafkaserver2"
 	"github.com/spirit-labs/tektite/lsm"
 	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/objstore/encryption"
+	"github.com/spirit-labs/tektite/objstore/metering"
 	"github.com/spirit-labs/tektite/parthash"
 	"github.com/spirit-labs/tektite/pusher"
+	"github.com/spirit-labs/tektite/replication"
+	"github.com/spirit-labs/tektite/schemaserver"
 	"github.com/spirit-labs/tektite/sst"
 	"github.com/spirit-labs/tektite/topicmeta"
 	"github.com/spirit-labs/tektite/transport"
@@ -48,6 +52,12 @@ type Agent struct {
 	clusterMembershipFactory ClusterMembershipFactory
 	tableGetter              sst.TableGetter
 	authCaches               *auth.UserAuthCaches
+	schemaServer             *schemaserver.Server
+	objStore                 objstore.Client
+	objStoreMeter            *metering.Meter
+	objStoreEncryptor        *encryption.Encryptor
+	adminServer              *adminServer
+	replicator               *replication.Replicator
 }
 
 func NewAgent(cfg Conf, objStore objstore.Client) (*Agent, error) {
@@ -59,7 +69,7 @@ func NewAgent(cfg Conf, objStore objstore.Client) (*Agent, error) {
 	if err != nil {
 		return nil, err
 	}
-	clusterMembershipFactory := func(data []byte, listener MembershipListener) ClusterMembership {
+	clusterMembershipFactory := func(data []byte, objStore objstore.Client, listener MembershipListener) ClusterMembership {
 		return cluster.NewMembership(cfg.ClusterMembershipConfig, data, objStore, listener)
 	}
 	return NewAgentWithFactories(cfg, objStore, socketClient.CreateConnection, transportServer,
@@ -68,7 +78,7 @@ func NewAgent(cfg Conf, objStore objstore.Client) (*Agent, error) {
 
 const partitionHashCacheMaxSize = 100000
 
-type ClusterMembershipFactory func(data []byte, listener MembershipListener) ClusterMembership
+type ClusterMembershipFactory func(data []byte, objStore objstore.Client, listener MembershipListener) ClusterMembership
 
 type MembershipListener func(thisMemberID int32, state cluster.MembershipState) error
 
@@ -82,12 +92,23 @@ func NewAgentWithFactories(cfg Conf, objStore objstore.Client, connectionFactory
 	if !common.Is64BitArch() {
 		return nil, errors.New("agent can only run on a 64-bit CPU architecture")
 	}
+	meter := metering.NewMeter(cfg.ObjStoreMeteringConf)
 	agent := &Agent{
 		cfg:              cfg,
 		partitionLeaders: map[string]map[int]map[int]int32{},
+		objStore:         objStore,
+		objStoreMeter:    meter,
+	}
+	if cfg.ObjStoreEncryptionConf.Enabled() {
+		encryptor, err := encryption.NewEncryptor(cfg.ObjStoreEncryptionConf)
+		if err != nil {
+			return nil, err
+		}
+		agent.objStoreEncryptor = encryptor
 	}
 	agent.connCaches = transport.NewConnCaches(cfg.MaxConnectionsPerAddress, connectionFactory)
-	agent.controller = control.NewController(cfg.ControllerConf, objStore, agent.connCaches, connectionFactory, transportServer)
+	agent.controller = control.NewController(cfg.ControllerConf, agent.objStoreClient(metering.SubsystemController),
+		agent.connCaches, connectionFactory, transportServer)
 	agent.authCaches = auth.NewUserAuthCaches(cfg.UserAuthCacheTimeout, func() (auth.ControlClient, error) {
 		return agent.controller.Client()
 	})
@@ -103,7 +124,8 @@ func NewAgentWithFactories(cfg Conf, objStore objstore.Client, connectionFactory
 		return nil, err
 	}
 	agent.partitionHashes = partitionHashes
-	fetchCache, err := fetchcache.NewCache(objStore, agent.connCaches, transportServer, cfg.FetchCacheConf)
+	fetchCache, err := fetchcache.NewCache(agent.objStoreClient(metering.SubsystemFetchCache), agent.connCaches,
+		transportServer, cfg.FetchCacheConf)
 	if err != nil {
 		return nil, err
 	}
@@ -111,6 +133,21 @@ func NewAgentWithFactories(cfg Conf, objStore objstore.Client, connectionFactory
 	getter := &fetchCacheGetter{fetchCache: fetchCache}
 	agent.tableGetter = getter.get
 	agent.controller.SetTableGetter(getter.get)
+	if cfg.ReplicationConf.Enabled() {
+		replicaClient := meter.Client(metering.SubsystemReplication, cfg.ReplicationConf.ReplicaObjStore)
+		if agent.objStoreEncryptor != nil {
+			replicaClient = agent.objStoreEncryptor.Client(replicaClient)
+		}
+		agent.replicator = replication.NewReplicator(cfg.ReplicationConf,
+			agent.objStoreClient(metering.SubsystemReplication), replicaClient, cfg.ControllerConf.SSTableBucketName,
+			cfg.ControllerConf.ControllerMetaDataBucketName, cfg.ControllerConf.ControllerMetaDataKey)
+	}
+	agent.controller.SetTableRegisteredListener(func(tableIDs []sst.SSTableID) {
+		getter.warm(tableIDs)
+		if agent.replicator != nil {
+			agent.replicator.TablesRegistered(tableIDs)
+		}
+	})
 	clientFactory := func() (pusher.ControlClient, error) {
 		// Note, we do not use a controller client cache here - the table pusher uses its own connection to avoid
 		// a deadlock where an operation like delete topic uses a connection then the controller calls table pusher
@@ -118,15 +155,16 @@ func NewAgentWithFactories(cfg Conf, objStore objstore.Client, connectionFactory
 		// is in use.
 		return agent.controller.Client()
 	}
-	tablePusher, err := pusher.NewTablePusher(cfg.PusherConf, agent.topicMetaCache, objStore, clientFactory, getter.get, partitionHashes, agent)
+	tablePusher, err := pusher.NewTablePusher(cfg.PusherConf, agent.topicMetaCache,
+		agent.objStoreClient(metering.SubsystemPusher), clientFactory, getter.get, partitionHashes, agent)
 	if err != nil {
 		return nil, err
 	}
 	agent.tablePusher = tablePusher
 	transportServer.RegisterHandler(transport.HandlerIDTablePusherDirectWrite, tablePusher.HandleDirectWriteRequest)
 	transportServer.RegisterHandler(transport.HandlerIDTablePusherDirectProduce, tablePusher.HandleDirectProduceRequest)
-	bf, err := fetcher.NewBatchFetcher(objStore, agent.topicMetaCache, partitionHashes, agent.controlClientCache, getter.get,
-		cfg.FetcherConf)
+	bf, err := fetcher.NewBatchFetcher(agent.objStoreClient(metering.SubsystemFetcher), agent.topicMetaCache, partitionHashes, agent.controlClientCache, getter.get,
+		getter.getRange, cfg.FetcherConf)
 	if err != nil {
 		return nil, err
 	}
@@ -143,6 +181,9 @@ func NewAgentWithFactories(cfg Conf, objStore objstore.Client, connectionFactory
 		cfg.KafkaListenerConfig.TLSConfig, cfg.AuthType, agent.newKafkaHandler, agent.authCaches)
 	agent.manifold = &membershipChangedManifold{listeners: []MembershipListener{fetchCache.MembershipChanged,
 		agent.controller.MembershipChanged, bf.MembershipChanged, groupCoord.MembershipChanged}}
+	if agent.replicator != nil {
+		agent.manifold.listeners = append(agent.manifold.listeners, agent.replicator.MembershipChanged)
+	}
 	agent.clusterMembershipFactory = clusterMembershipFactory
 	agent.transportServer = transportServer
 	clFactory := func() (lsm.ControllerClient, error) {
@@ -152,8 +193,8 @@ func NewAgentWithFactories(cfg Conf, objStore objstore.Client, connectionFactory
 		}
 		return &compactionWorkerControllerClient{cc: cc}, nil
 	}
-	agent.compactionWorkersService = lsm.NewCompactionWorkerService(cfg.CompactionWorkersConf, objStore,
-		clFactory, getter.get, partitionHashes, true)
+	agent.compactionWorkersService = lsm.NewCompactionWorkerService(cfg.CompactionWorkersConf,
+		agent.objStoreClient(metering.SubsystemCompaction), clFactory, getter.get, partitionHashes, true)
 	scramManager, err := auth.NewScramManager(auth.ScramAuthTypeSHA512, agent.controlClientCache, getter.get,
 		cfg.AllowScramNonceAsPrefix)
 	if err != nil {
@@ -165,6 +206,14 @@ func NewAgentWithFactories(cfg Conf, objStore objstore.Client, connectionFactory
 		return nil, err
 	}
 	agent.saslAuthManager = saslAuthManager
+	if cfg.SchemaRegistryConf.Enabled {
+		schemaLog := &schemaTopicLog{agent: agent, topicName: cfg.SchemaRegistryConf.TopicName}
+		agent.schemaServer = schemaserver.NewServer(cfg.SchemaRegistryConf, schemaLog, &schemaRegistryLeadership{agent: agent})
+		transportServer.RegisterHandler(transport.HandlerIDSchemaRegistryWrite, agent.schemaServer.HandleForwardedWrite)
+	}
+	if cfg.AdminListenAddress != "" {
+		agent.adminServer = newAdminServer(cfg.AdminListenAddress, meter, agent.controller, agent.replicator)
+	}
 	return agent, nil
 }
 
@@ -174,6 +223,9 @@ func (a *Agent) Start() error {
 	if a.started {
 		return nil
 	}
+	if err := a.checkReplica(); err != nil {
+		return err
+	}
 	if err := a.controller.Start(); err != nil {
 		return err
 	}
@@ -207,10 +259,24 @@ func (a *Agent) Start() error {
 		KafkaListenerAddress: a.kafkaServer.ListenAddress(),
 		Location:             a.cfg.FetchCacheConf.AzInfo,
 	}
-	a.membership = a.clusterMembershipFactory(membershipData.Serialize(nil), a.manifold.membershipChanged)
+	a.membership = a.clusterMembershipFactory(membershipData.Serialize(nil),
+		a.objStoreClient(metering.SubsystemMembership), a.manifold.membershipChanged)
 	if err := a.membership.Start(); err != nil {
 		return err
 	}
+	if a.schemaServer != nil {
+		if err := a.schemaServer.Start(); err != nil {
+			return err
+		}
+	}
+	if a.replicator != nil {
+		a.replicator.Start()
+	}
+	if a.adminServer != nil {
+		if err := a.adminServer.start(); err != nil {
+			return err
+		}
+	}
 	a.started = true
 	return nil
 }
@@ -221,6 +287,19 @@ func (a *Agent) Stop() error {
 	if !a.started {
 		return nil
 	}
+	if a.adminServer != nil {
+		if err := a.adminServer.stop(); err != nil {
+			return err
+		}
+	}
+	if a.replicator != nil {
+		a.replicator.Stop()
+	}
+	if a.schemaServer != nil {
+		if err := a.schemaServer.Stop(); err != nil {
+			return err
+		}
+	}
 	// We must close conn caches and control caches first and incoming kafka requests might be waiting on response from outgoing rpcs
 	// these will need to return before the kafka server can close
 	a.connCaches.Close()
@@ -257,6 +336,48 @@ func (a *Agent) Stop() error {
 	return nil
 }
 
+// checkReplica promotes the replica the agent is started from if configured to. Otherwise, it checks the agent is not
+// being started from a replica, as the cluster would then conflict with the cluster replicating to it.
+func (a *Agent) checkReplica() error {
+	client := a.objStoreClient(metering.SubsystemReplication)
+	metaDataBucketName := a.cfg.ControllerConf.ControllerMetaDataBucketName
+	if a.cfg.PromoteReplica {
+		_, err := replication.Promote(client, a.cfg.ControllerConf.SSTableBucketName, metaDataBucketName,
+			a.cfg.ControllerConf.ControllerMetaDataKey)
+		return err
+	}
+	replica, err := replication.IsUnpromotedReplica(client, metaDataBucketName)
+	if err != nil {
+		return err
+	}
+	if replica {
+		return errors.Errorf("bucket %s holds a replica which has not been promoted - the replica must be promoted to start a cluster from it",
+			metaDataBucketName)
+	}
+	return nil
+}
+
+// ReplicationStatus returns the status of replication, or false if replication is not enabled.
+func (a *Agent) ReplicationStatus() (replication.Status, bool) {
+	if a.replicator == nil {
+		return replication.Status{}, false
+	}
+	return a.replicator.Status(), true
+}
+
+// RestoreBackup restores the whole cluster from a backup - see control.RestoreBackup. All agents in the cluster must be
+// stopped.
+func RestoreBackup(cfg Conf, objStore objstore.Client, backupID string) (control.BackupInfo, error) {
+	if cfg.ObjStoreEncryptionConf.Enabled() {
+		encryptor, err := encryption.NewEncryptor(cfg.ObjStoreEncryptionConf)
+		if err != nil {
+			return control.BackupInfo{}, err
+		}
+		objStore = encryptor.Client(objStore)
+	}
+	return control.RestoreBackup(objStore, cfg.ControllerConf, backupID)
+}
+
 func (a *Agent) DeliveredClusterVersion() int {
 	return int(atomic.LoadInt64(&a.manifold.deliveredClusterVersion))
 }
@@ -285,6 +406,35 @@ func (a *Agent) ClusterListenAddress() string {
 	return a.transportServer.Address()
 }
 
+// SchemaRegistry returns the schema registry hosted by the agent, or nil if it is not enabled.
+func (a *Agent) SchemaRegistry() *schemaserver.Server {
+	return a.schemaServer
+}
+
+// objStoreClient returns the object store client for a subsystem. Each subsystem gets its own client from the meter,
+// so we can see which subsystems the requests come from. Encryption is applied above the meter, so the meter sees the
+// requests that are actually made to the object store.
+func (a *Agent) objStoreClient(subsystem string) objstore.Client {
+	client := a.objStoreMeter.Client(subsystem, a.objStore)
+	if a.objStoreEncryptor != nil {
+		client = a.objStoreEncryptor.Client(client)
+	}
+	return client
+}
+
+// ObjStoreReport returns the requests made to the object store by the agent and their estimated cost
+func (a *Agent) ObjStoreReport() metering.Report {
+	return a.objStoreMeter.Report()
+}
+
+// AdminListenAddress returns the address the admin api is listening on, or an empty string if it is not started.
+func (a *Agent) AdminListenAddress() string {
+	if a.adminServer == nil {
+		return ""
+	}
+	return a.adminServer.listenAddress()
+}
+
 func (a *Agent) TableGetter() sst.TableGetter {
 	return a.tableGetter
 }
@@ -321,6 +471,18 @@ func (o *fetchCacheGetter) get(tableID sst.SSTableID) (*sst.SSTable, error) {
 	return sst.GetSSTableFromBytes(bytes)
 }
 
+func (o *fetchCacheGetter) warm(tableIDs []sst.SSTableID) {
+	keys := make([][]byte, len(tableIDs))
+	for i, tableID := range tableIDs {
+		keys[i] = tableID
+	}
+	o.fetchCache.WarmTables(keys)
+}
+
+func (o *fetchCacheGetter) getRange(tableID sst.SSTableID, offset int64, length int64) ([]byte, error) {
+	return o.fetchCache.GetTableBytesRange(tableID, offset, length)
+}
+
 type compactionWorkerControllerClient struct {
 	cc control.Client
 }
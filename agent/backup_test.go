@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spirit-labs/tektite/apiclient"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/control"
+	"github.com/spirit-labs/tektite/kafkaencoding"
+	"github.com/spirit-labs/tektite/kafkaprotocol"
+	"github.com/spirit-labs/tektite/topicmeta"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestBackupAndRestoreTopic(t *testing.T) {
+	topicName := "test-topic-1"
+	partitionID := 3
+	topicInfos := []topicmeta.TopicInfo{
+		{
+			Name:                topicName,
+			PartitionCount:      5,
+			MaxMessageSizeBytes: math.MaxInt,
+		},
+	}
+	cfg := NewConf()
+	var err error
+	cfg.AdminListenAddress, err = common.AddressWithPort("localhost")
+	require.NoError(t, err)
+	agent, _, tearDown := setupAgent(t, topicInfos, cfg)
+	defer tearDown(t)
+	adminAddress := agent.AdminListenAddress()
+	batch := produceBatch(t, topicName, partitionID, agent.Conf().KafkaListenerConfig.Address)
+
+	var info control.BackupInfo
+	sendAdminRequest(t, http.MethodPost, fmt.Sprintf("http://%s/backups", adminAddress), http.StatusOK, &info)
+	require.Greater(t, info.TableCount, 0)
+	var infos []control.BackupInfo
+	sendAdminRequest(t, http.MethodGet, fmt.Sprintf("http://%s/backups", adminAddress), http.StatusOK, &infos)
+	require.Equal(t, 1, len(infos))
+	require.Equal(t, info.ID, infos[0].ID)
+
+	// Delete the topic by mistake
+	cl, err := apiclient.NewKafkaApiClient()
+	require.NoError(t, err)
+	conn, err := cl.NewConnection(agent.Conf().KafkaListenerConfig.Address)
+	require.NoError(t, err)
+	defer func() {
+		err := conn.Close()
+		require.NoError(t, err)
+	}()
+	sendDeleteTopicsExpectErrCode(t, conn, topicName, kafkaprotocol.ErrorCodeNone)
+
+	restoreURL := fmt.Sprintf("http://%s/backups/%s/restore-topic?topic=%s&as=%s", adminAddress, info.ID, topicName,
+		topicName)
+	var restored topicmeta.TopicInfo
+	sendAdminRequest(t, http.MethodPost, restoreURL, http.StatusOK, &restored)
+	require.Equal(t, topicName, restored.Name)
+	require.Equal(t, 5, restored.PartitionCount)
+	// Can't restore over an existing topic
+	sendAdminRequest(t, http.MethodPost, restoreURL, http.StatusConflict, nil)
+
+	fetched := fetchBatch(t, topicName, partitionID, agent.Conf().KafkaListenerConfig.Address)
+	kafkaencoding.SetCrc(batch, 0)
+	kafkaencoding.SetCrc(fetched, 0)
+	require.Equal(t, batch, fetched)
+
+	sendAdminRequest(t, http.MethodDelete, fmt.Sprintf("http://%s/backups/%s", adminAddress, info.ID),
+		http.StatusNoContent, nil)
+	sendAdminRequest(t, http.MethodDelete, fmt.Sprintf("http://%s/backups/%s", adminAddress, info.ID),
+		http.StatusNotFound, nil)
+}
+
+func sendAdminRequest(t *testing.T, method string, url string, expectedStatus int, result any) {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, expectedStatus, resp.StatusCode)
+	if result != nil {
+		err = json.NewDecoder(resp.Body).Decode(result)
+		require.NoError(t, err)
+	}
+}
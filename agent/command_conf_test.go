@@ -1,6 +1,10 @@
 package agent
 
 import (
+	"fmt"
+	"github.com/spirit-labs/tektite/objstore/azure"
+	"github.com/spirit-labs/tektite/objstore/gcs"
+	"github.com/spirit-labs/tektite/objstore/minio"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -42,3 +46,57 @@ func TestInvalidConsumerGroupInitialJoinDelayInterval(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, "invalid value for consumer-group-initial-join-delay-ms must be >= 0 ms", err.Error())
 }
+
+func TestCreateObjStoreClient(t *testing.T) {
+	conf := CommandConf{ObjStoreURL: "some-url", ObjStoreUsername: "some-user", ObjStorePassword: "some-pwd"}
+
+	conf.ObjStoreType = "s3"
+	client, err := createObjStoreClient(conf)
+	require.NoError(t, err)
+	require.IsType(t, &minio.Client{}, client)
+
+	conf.ObjStoreType = "azure"
+	client, err = createObjStoreClient(conf)
+	require.NoError(t, err)
+	require.IsType(t, &azure.Client{}, client)
+
+	conf.ObjStoreType = "gcs"
+	client, err = createObjStoreClient(conf)
+	require.NoError(t, err)
+	require.IsType(t, &gcs.Client{}, client)
+
+	conf.ObjStoreType = "foo"
+	_, err = createObjStoreClient(conf)
+	require.Error(t, err)
+	require.Equal(t, "invalid obj-store-type: foo", err.Error())
+}
+
+func TestCreateObjStoreClientMissingCredentials(t *testing.T) {
+	for _, objStoreType := range []string{"s3", "azure"} {
+		conf := CommandConf{ObjStoreURL: "some-url", ObjStoreType: objStoreType, ObjStoreUsername: "some-user"}
+		_, err := createObjStoreClient(conf)
+		require.Error(t, err)
+		require.Equal(t, fmt.Sprintf("obj-store-username and obj-store-password must be specified when obj-store-type is '%s'", objStoreType), err.Error())
+	}
+	// gcs can use application default credentials
+	conf := CommandConf{ObjStoreURL: "some-url", ObjStoreType: "gcs"}
+	_, err := createObjStoreClient(conf)
+	require.NoError(t, err)
+}
+
+func TestCreateReplicaObjStoreClient(t *testing.T) {
+	conf := CommandConf{ReplicaObjStoreURL: "some-url", ReplicaObjStoreType: "s3", ReplicaObjStoreUsername: "some-user"}
+	_, err := createReplicaObjStoreClient(conf)
+	require.Error(t, err)
+	require.Equal(t, "replica-obj-store-username and replica-obj-store-password must be specified when replica-obj-store-type is 's3'", err.Error())
+
+	conf.ReplicaObjStorePassword = "some-pwd"
+	client, err := createReplicaObjStoreClient(conf)
+	require.NoError(t, err)
+	require.IsType(t, &minio.Client{}, client)
+
+	conf.ReplicaObjStoreType = "foo"
+	_, err = createReplicaObjStoreClient(conf)
+	require.Error(t, err)
+	require.Equal(t, "invalid replica-obj-store-type: foo", err.Error())
+}
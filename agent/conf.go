@@ -6,6 +6,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/spirit-labs/tektite/cluster"
+	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/conf"
 	"github.com/spirit-labs/tektite/control"
 	"github.com/spirit-labs/tektite/fetchcache"
@@ -14,16 +15,26 @@ import (
 	kafkaserver "github.com/spirit-labs/tektite/kafkaserver2"
 	log "github.com/spirit-labs/tektite/logger"
 	"github.com/spirit-labs/tektite/lsm"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/objstore/azure"
+	"github.com/spirit-labs/tektite/objstore/encryption"
+	"github.com/spirit-labs/tektite/objstore/gcs"
+	"github.com/spirit-labs/tektite/objstore/metering"
 	"github.com/spirit-labs/tektite/objstore/minio"
 	"github.com/spirit-labs/tektite/pusher"
+	"github.com/spirit-labs/tektite/replication"
+	"github.com/spirit-labs/tektite/schemareg"
+	"github.com/spirit-labs/tektite/schemaserver"
 	"net"
 	"time"
 )
 
 type CommandConf struct {
-	ObjStoreUsername                string             `help:"username for the object store" required:""`
-	ObjStorePassword                string             `help:"password for the object store" required:""`
+	ObjStoreUsername                string             `help:"username for the object store. for 'azure' this is the storage account name"`
+	ObjStorePassword                string             `help:"password for the object store. for 'azure' this is the storage account key"`
 	ObjStoreURL                     string             `help:"url of the object store" required:""`
+	ObjStoreType                    string             `help:"type of the object store. one of 's3', 'azure' or 'gcs'" default:"s3"`
+	ObjStoreCredentialsFile         string             `help:"path to a service account credentials file for 'gcs'. if not specified application default credentials are used"`
 	ClusterName                     string             `help:"name of the agent cluster" required:""`
 	Location                        string             `help:"location (e.g. availability zone) that the agent runs in" required:""`
 	KafkaListenAddress              string             `help:"address to listen on for kafka connections"`
@@ -43,9 +54,34 @@ type CommandConf struct {
 	DefaultMaxMessageSizeBytes      int           `help:"the maximum size of a message batch that can be sent to a topic - can be overridden at topic level" default:"1048576"`
 	MetadataWriteIntervalMs         int           `help:"interval between writing database metadata to permanent storage, in milliseconds" default:"100"`
 	StorageCompressionType          string        `help:"determines how data is compressed before writing to object storage. one of 'lz4', 'zstd' or 'none'" default:"lz4"`
+	StorageDataFormat               int           `help:"format of the data tables written to object storage. one of 1 or 2. format 2 tables have an index of blocks and a bloom filter, so lookups fetch less data, but can only be read by agents which support format 2" default:"1"`
 	FetchCompressionType            string        `help:"determines how data is compressed before returning a fetched batch to a consumer. one of 'gzip', 'snappy', 'lz4', 'zstd' or 'none'" default:"lz4"`
 	DataWriteIntervalMs             int           `help:"maximum interval between writing database data to permanent storage, in milliseconds" default:"200"`
 	PusherBufferMaxSizeBytes        int           `help:"maximum size of the push buffer in bytes - when it is full a data table will be written to object storage" default:"4194304"`
+	SchemaRegistryEnabled           bool          `help:"if 'true' then the agent hosts a schema registry with a Confluent compatible REST API"`
+	SchemaRegistryListenAddress     string        `help:"address to listen on for schema registry connections" default:"localhost:8081"`
+	SchemaRegistryCompatibility     string        `help:"default compatibility level for schema registry subjects. one of 'none', 'backward', 'backward_transitive', 'forward', 'forward_transitive', 'full' or 'full_transitive'" default:"backward"`
+	AdminListenAddress              string        `help:"address to listen on for the admin api, which serves metrics, the object store request report and table garbage collection. if not specified the admin api is not started"`
+	ObjStoreGetRequestPrice         float64       `help:"price per 1000 get requests to the object store, used to estimate the cost of object store requests" default:"0.0004"`
+	ObjStorePutRequestPrice         float64       `help:"price per 1000 put requests to the object store, used to estimate the cost of object store requests" default:"0.005"`
+	ObjStoreListRequestPrice        float64       `help:"price per 1000 list requests to the object store, used to estimate the cost of object store requests" default:"0.005"`
+	ObjStoreDeleteRequestPrice      float64       `help:"price per 1000 delete requests to the object store, used to estimate the cost of object store requests" default:"0"`
+	ObjStoreCoalesceGets            bool          `help:"if 'true' then concurrent gets of the same object from the object store are coalesced into a single request"`
+	FetchCacheDiskDir               string        `help:"directory of the on-disk tier of the fetch cache, ideally on a local SSD. if not specified there is no disk tier"`
+	FetchCacheDiskMaxSizeBytes      int           `help:"maximum size in bytes of the on-disk tier of the fetch cache" default:"17179869184"`
+	ObjStoreEncryptionKeyFile       string        `help:"path to a key file holding the key encryption keys used to encrypt objects written to the object store. if not specified objects are not encrypted"`
+	ObjStoreAllowUnencryptedReads   bool          `help:"if 'true' then objects in the object store which are not encrypted can still be read when encryption is enabled, e.g. objects written before it was enabled"`
+	TableGCInterval                 time.Duration `help:"interval between runs of the garbage collector which deletes orphaned tables from the object store. if zero it does not run periodically" default:"10m"`
+	TableGCGracePeriod              time.Duration `help:"minimum age of a table in the object store before the garbage collector considers it orphaned if it is not registered" default:"1h"`
+	ReplicaObjStoreURL              string        `help:"url of the object store to replicate the data of the cluster to, typically in another region. if not specified the data is not replicated"`
+	ReplicaObjStoreType             string        `help:"type of the replica object store. one of 's3', 'azure' or 'gcs'" default:"s3"`
+	ReplicaObjStoreUsername         string        `help:"username for the replica object store. for 'azure' this is the storage account name"`
+	ReplicaObjStorePassword         string        `help:"password for the replica object store. for 'azure' this is the storage account key"`
+	ReplicaObjStoreCredentialsFile  string        `help:"path to a service account credentials file for a 'gcs' replica object store. if not specified application default credentials are used"`
+	ReplicaClusterName              string        `help:"name of the cluster which will be started from the replica, which determines the buckets the data is replicated to. if not specified the name of this cluster is used"`
+	ReplicationSnapshotInterval     time.Duration `help:"interval between snapshots of the cluster metadata written to the replica. the replica is behind by at most this interval" default:"30s"`
+	PromoteReplica                  bool          `help:"if 'true' then the replica in the object store is promoted when the agent starts, so the cluster can be started from it. the cluster which replicated to it can no longer write to it"`
+	RestoreBackup                   string        `help:"id of a backup to restore the cluster from. the agent restores the backup and exits without starting. all agents in the cluster must be stopped"`
 }
 
 var authTypeMapping = map[string]kafkaserver.AuthenticationType{
@@ -129,6 +165,8 @@ func CreateConfFromCommandConf(commandConf CommandConf) (Conf, error) {
 	cfg.FetchCacheConf.DataBucketName = dataBucketName
 	cfg.FetchCacheConf.MaxSizeBytes = 1 * 1024 * 1024 * 1024 // 1GiB
 	cfg.FetchCacheConf.AzInfo = commandConf.Location
+	cfg.FetchCacheConf.DiskCacheDir = commandConf.FetchCacheDiskDir
+	cfg.FetchCacheConf.DiskCacheMaxSizeBytes = commandConf.FetchCacheDiskMaxSizeBytes
 	// configure group coordinator
 	cfg.GroupCoordinatorConf.InitialJoinDelay, err =
 		validateDurationMs("consumer-group-initial-join-delay-ms", commandConf.ConsumerGroupInitialJoinDelayMs, 0)
@@ -162,6 +200,14 @@ func CreateConfFromCommandConf(commandConf CommandConf) (Conf, error) {
 		return Conf{}, errors.Errorf("invalid compression-type: %s", commandConf.StorageCompressionType)
 	}
 	cfg.PusherConf.TableCompressionType = storageCompressionType
+	cfg.ControllerConf.TableCompressionType = storageCompressionType
+	dataFormat := common.DataFormat(commandConf.StorageDataFormat)
+	if dataFormat != common.DataFormatV1 && dataFormat != common.DataFormatV2 {
+		return Conf{}, errors.Errorf("invalid storage-data-format: %d - must be 1 or 2", commandConf.StorageDataFormat)
+	}
+	cfg.PusherConf.DataFormat = dataFormat
+	cfg.ControllerConf.DataFormat = dataFormat
+	cfg.CompactionWorkersConf.DataFormat = dataFormat
 	fetchCompressionType := compress.FromString(commandConf.FetchCompressionType)
 	if fetchCompressionType == compress.CompressionTypeUnknown {
 		return Conf{}, errors.Errorf("invalid compression-type: %s", commandConf.StorageCompressionType)
@@ -176,6 +222,35 @@ func CreateConfFromCommandConf(commandConf CommandConf) (Conf, error) {
 		return Conf{}, errors.Errorf("invalid pusher-buffer-max-size-bytes: %d", commandConf.PusherBufferMaxSizeBytes)
 	}
 	cfg.PusherConf.BufferMaxSizeBytes = commandConf.PusherBufferMaxSizeBytes
+	cfg.SchemaRegistryConf.Enabled = commandConf.SchemaRegistryEnabled
+	if cfg.SchemaRegistryConf.Enabled {
+		cfg.SchemaRegistryConf.ListenAddress = commandConf.SchemaRegistryListenAddress
+		level, ok := schemareg.ParseCompatibilityLevel(commandConf.SchemaRegistryCompatibility)
+		if !ok {
+			return Conf{}, errors.Errorf("invalid schema-registry-compatibility: %s", commandConf.SchemaRegistryCompatibility)
+		}
+		cfg.SchemaRegistryConf.DefaultCompatibility = level
+	}
+	cfg.AdminListenAddress = commandConf.AdminListenAddress
+	cfg.ObjStoreMeteringConf.Prices = metering.Prices{
+		GetRequests:    commandConf.ObjStoreGetRequestPrice,
+		PutRequests:    commandConf.ObjStorePutRequestPrice,
+		ListRequests:   commandConf.ObjStoreListRequestPrice,
+		DeleteRequests: commandConf.ObjStoreDeleteRequestPrice,
+	}
+	cfg.ObjStoreMeteringConf.CoalesceGets = commandConf.ObjStoreCoalesceGets
+	cfg.ObjStoreEncryptionConf.KeyFile = commandConf.ObjStoreEncryptionKeyFile
+	cfg.ObjStoreEncryptionConf.AllowUnencryptedReads = commandConf.ObjStoreAllowUnencryptedReads
+	cfg.ControllerConf.TableGCInterval = commandConf.TableGCInterval
+	cfg.ControllerConf.TableGCGracePeriod = commandConf.TableGCGracePeriod
+	replicaClusterName := commandConf.ReplicaClusterName
+	if replicaClusterName == "" {
+		replicaClusterName = commandConf.ClusterName
+	}
+	cfg.ReplicationConf.ReplicaDataBucketName = replicaClusterName + "-data"
+	cfg.ReplicationConf.ReplicaMetaDataBucketName = replicaClusterName + "-data"
+	cfg.ReplicationConf.SnapshotInterval = commandConf.ReplicationSnapshotInterval
+	cfg.PromoteReplica = commandConf.PromoteReplica
 	return cfg, nil
 }
 
@@ -191,19 +266,94 @@ func CreateAgentFromCommandConf(commandConf CommandConf) (*Agent, error) {
 	if err != nil {
 		return nil, err
 	}
-	minioConf := minio.Conf{
-		Endpoint: commandConf.ObjStoreURL,
-		Username: commandConf.ObjStoreUsername,
-		Password: commandConf.ObjStorePassword,
-		Secure:   false,
+	objStore, err := createObjStoreClient(commandConf)
+	if err != nil {
+		return nil, err
 	}
-	objStore := minio.NewMinioClient(minioConf)
 	if err := objStore.Start(); err != nil {
 		return nil, err
 	}
+	if commandConf.ReplicaObjStoreURL != "" {
+		replicaObjStore, err := createReplicaObjStoreClient(commandConf)
+		if err != nil {
+			return nil, err
+		}
+		if err := replicaObjStore.Start(); err != nil {
+			return nil, err
+		}
+		cfg.ReplicationConf.ReplicaObjStore = replicaObjStore
+	}
 	return NewAgent(cfg, objStore)
 }
 
+// RestoreBackupFromCommandConf restores the cluster from the backup specified in the command conf - see RestoreBackup
+func RestoreBackupFromCommandConf(commandConf CommandConf) (control.BackupInfo, error) {
+	cfg, err := CreateConfFromCommandConf(commandConf)
+	if err != nil {
+		return control.BackupInfo{}, err
+	}
+	objStore, err := createObjStoreClient(commandConf)
+	if err != nil {
+		return control.BackupInfo{}, err
+	}
+	if err := objStore.Start(); err != nil {
+		return control.BackupInfo{}, err
+	}
+	defer func() {
+		if err := objStore.Stop(); err != nil {
+			log.Warnf("failed to stop object store client: %v", err)
+		}
+	}()
+	return RestoreBackup(cfg, objStore, commandConf.RestoreBackup)
+}
+
+func createObjStoreClient(commandConf CommandConf) (objstore.Client, error) {
+	return newObjStoreClient("obj-store", commandConf.ObjStoreType, commandConf.ObjStoreURL,
+		commandConf.ObjStoreUsername, commandConf.ObjStorePassword, commandConf.ObjStoreCredentialsFile)
+}
+
+func createReplicaObjStoreClient(commandConf CommandConf) (objstore.Client, error) {
+	return newObjStoreClient("replica-obj-store", commandConf.ReplicaObjStoreType, commandConf.ReplicaObjStoreURL,
+		commandConf.ReplicaObjStoreUsername, commandConf.ReplicaObjStorePassword,
+		commandConf.ReplicaObjStoreCredentialsFile)
+}
+
+// newObjStoreClient creates an object store client. The flag prefix is the prefix of the command line flags the
+// arguments come from, used in error messages.
+func newObjStoreClient(flagPrefix string, storeType string, url string, username string, password string,
+	credentialsFile string) (objstore.Client, error) {
+	switch storeType {
+	case "s3":
+		if username == "" || password == "" {
+			return nil, errors.Errorf("%s-username and %s-password must be specified when %s-type is 's3'",
+				flagPrefix, flagPrefix, flagPrefix)
+		}
+		return minio.NewMinioClient(minio.Conf{
+			Endpoint: url,
+			Username: username,
+			Password: password,
+			Secure:   false,
+		}), nil
+	case "azure":
+		if username == "" || password == "" {
+			return nil, errors.Errorf("%s-username and %s-password must be specified when %s-type is 'azure'",
+				flagPrefix, flagPrefix, flagPrefix)
+		}
+		return azure.NewAzureClient(azure.Conf{
+			Endpoint:    url,
+			AccountName: username,
+			AccountKey:  password,
+		}), nil
+	case "gcs":
+		return gcs.NewGcsClient(gcs.Conf{
+			Endpoint:        url,
+			CredentialsFile: credentialsFile,
+		}), nil
+	default:
+		return nil, errors.Errorf("invalid %s-type: %s", flagPrefix, storeType)
+	}
+}
+
 func selectNetworkInterface() (string, error) {
 	addresses, err := net.InterfaceAddrs()
 	if err != nil {
@@ -235,6 +385,11 @@ type Conf struct {
 	FetcherConf                fetcher.Conf
 	FetchCacheConf             fetchcache.Conf
 	GroupCoordinatorConf       group.Conf
+	SchemaRegistryConf         schemaserver.Conf
+	ObjStoreMeteringConf       metering.Conf
+	ObjStoreEncryptionConf     encryption.Conf
+	ReplicationConf            replication.Conf
+	AdminListenAddress         string
 	MaxControllerClients       int
 	MaxConnectionsPerAddress   int
 	AuthType                   kafkaserver.AuthenticationType
@@ -247,6 +402,8 @@ type Conf struct {
 	EnableTopicAutoCreate      bool
 	DefaultPartitionCount      int
 	DefaultMaxMessageSizeBytes int
+	// PromoteReplica promotes the replica the agent is started from - see replication.Promote
+	PromoteReplica bool
 }
 
 func NewConf() Conf {
@@ -259,6 +416,10 @@ func NewConf() Conf {
 		FetcherConf:                fetcher.NewConf(),
 		FetchCacheConf:             fetchcache.NewConf(),
 		GroupCoordinatorConf:       group.NewConf(),
+		SchemaRegistryConf:         schemaserver.NewConf(),
+		ObjStoreMeteringConf:       metering.NewConf(),
+		ObjStoreEncryptionConf:     encryption.NewConf(),
+		ReplicationConf:            replication.NewConf(),
 		MaxControllerClients:       DefaultMaxControllerClients,
 		MaxConnectionsPerAddress:   DefaultMaxConnectionsPerAddress,
 		AuthType:                   kafkaserver.AuthenticationTypeNone,
@@ -307,6 +468,18 @@ func (c *Conf) Validate() error {
 	if err := c.GroupCoordinatorConf.Validate(); err != nil {
 		return err
 	}
+	if err := c.SchemaRegistryConf.Validate(); err != nil {
+		return err
+	}
+	if err := c.ObjStoreMeteringConf.Validate(); err != nil {
+		return err
+	}
+	if err := c.ObjStoreEncryptionConf.Validate(); err != nil {
+		return err
+	}
+	if err := c.ReplicationConf.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -2,6 +2,9 @@ package agent
 
 import (
 	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
 	"encoding/binary"
 	"fmt"
 	"github.com/spirit-labs/tektite/apiclient"
@@ -17,6 +20,8 @@ import (
 	"hash/crc32"
 	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -24,14 +29,37 @@ import (
 )
 
 func TestFetchSimpleV2(t *testing.T) {
-	testFetchSimple(t, 2)
+	testFetchSimple(t, 2, NewConf())
 }
 
 func TestFetchSimpleV3(t *testing.T) {
-	testFetchSimple(t, 3)
+	testFetchSimple(t, 3, NewConf())
 }
 
-func testFetchSimple(t *testing.T, apiVersion int16) {
+func TestFetchEncryptedAtRest(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := crand.Read(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	err = os.WriteFile(keyFile, []byte(fmt.Sprintf(`{"current_key_id": "key-1", "keys": [{"id": "key-1", "key": "%s"}]}`,
+		base64.StdEncoding.EncodeToString(key))), 0o600)
+	require.NoError(t, err)
+	cfg := NewConf()
+	cfg.ObjStoreEncryptionConf.KeyFile = keyFile
+	objStore := testFetchSimple(t, 3, cfg)
+
+	// Everything written to the object store must be encrypted
+	infos, err := objStore.ListObjectsWithPrefix(context.Background(), cfg.PusherConf.DataBucketName, "", -1)
+	require.NoError(t, err)
+	require.Greater(t, len(infos), 0)
+	for _, info := range infos {
+		value, err := objStore.Get(context.Background(), cfg.PusherConf.DataBucketName, info.Key)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(value, []byte("TEKENC")), "object %s is not encrypted", info.Key)
+	}
+}
+
+func testFetchSimple(t *testing.T, apiVersion int16, cfg Conf) *dev.InMemStore {
 	topicName := "test-topic-1"
 	partitionID := 12
 	topicInfos := []topicmeta.TopicInfo{
@@ -41,8 +69,7 @@ func testFetchSimple(t *testing.T, apiVersion int16) {
 			MaxMessageSizeBytes: math.MaxInt,
 		},
 	}
-	cfg := NewConf()
-	agent, _, tearDown := setupAgent(t, topicInfos, cfg)
+	agent, objStore, tearDown := setupAgent(t, topicInfos, cfg)
 	defer tearDown(t)
 
 	address := agent.Conf().KafkaListenerConfig.Address
@@ -105,6 +132,38 @@ func testFetchSimple(t *testing.T, apiVersion int16) {
 	kafkaencoding.SetCrc(bNoCrc2, 0)
 
 	require.Equal(t, bNoCrc1, bNoCrc2)
+	return objStore
+}
+
+func TestFetchCacheDiskTierWarmedOnRegistration(t *testing.T) {
+	topicName := "test-topic-1"
+	topicInfos := []topicmeta.TopicInfo{
+		{
+			Name:                topicName,
+			PartitionCount:      10,
+			MaxMessageSizeBytes: math.MaxInt,
+		},
+	}
+	cfg := NewConf()
+	cfg.FetchCacheConf.DiskCacheDir = t.TempDir()
+	agent, _, tearDown := setupAgent(t, topicInfos, cfg)
+	defer tearDown(t)
+
+	produceBatch(t, topicName, 3, agent.Conf().KafkaListenerConfig.Address)
+
+	// The table written for the batch is loaded into the disk tier when it is registered
+	testutils.WaitUntil(t, func() (bool, error) {
+		entries, err := os.ReadDir(cfg.FetchCacheConf.DiskCacheDir)
+		if err != nil {
+			return false, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
 }
 
 func TestFetchSingleSenderAndFetcherShortWriteTimeout(t *testing.T) {
@@ -3,6 +3,7 @@ package agent
 import (
 	"github.com/spirit-labs/tektite/cluster"
 	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/objstore"
 	"sync"
 	"time"
 )
@@ -49,7 +50,7 @@ func (i *InMemClusterMemberships) deliverUpdatesLoop() {
 	}
 }
 
-func (i *InMemClusterMemberships) NewMembership(data []byte, listener MembershipListener) ClusterMembership {
+func (i *InMemClusterMemberships) NewMembership(data []byte, _ objstore.Client, listener MembershipListener) ClusterMembership {
 	return &InMemMembership{
 		memberships: i,
 		data:        data,
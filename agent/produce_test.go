@@ -387,6 +387,10 @@ func setupAgentWithArgs(t *testing.T, cfg Conf, objStore objstore.Client, inMemM
 	cfg.PusherConf.TableCompressionType = compress.CompressionTypeLz4
 	cfg.FetcherConf.FetchCompressionType = compress.CompressionTypeLz4
 	cfg.KafkaListenerConfig.Address = kafkaAddress
+	if cfg.SchemaRegistryConf.Enabled {
+		cfg.SchemaRegistryConf.ListenAddress, err = common.AddressWithPort("localhost")
+		require.NoError(t, err)
+	}
 	transportServer, err := localTransports.NewLocalServer(uuid.New().String())
 	require.NoError(t, err)
 	agent, err := NewAgentWithFactories(cfg, objStore, localTransports.CreateConnection, transportServer, inMemMemberships.NewMembership)
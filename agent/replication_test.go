@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spirit-labs/tektite/apiclient"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/kafkaencoding"
+	"github.com/spirit-labs/tektite/kafkaprotocol"
+	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/spirit-labs/tektite/replication"
+	"github.com/spirit-labs/tektite/testutils"
+	"github.com/spirit-labs/tektite/topicmeta"
+	"github.com/spirit-labs/tektite/transport"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReplicateAndPromote(t *testing.T) {
+	topicName := "test-topic-1"
+	partitionID := 7
+	topicInfos := []topicmeta.TopicInfo{
+		{
+			Name:                topicName,
+			PartitionCount:      10,
+			MaxMessageSizeBytes: math.MaxInt,
+		},
+	}
+	replicaStore := dev.NewInMemStore(0)
+	cfg := NewConf()
+	cfg.ReplicationConf.ReplicaObjStore = replicaStore
+	cfg.ReplicationConf.SnapshotInterval = 100 * time.Millisecond
+	var err error
+	cfg.AdminListenAddress, err = common.AddressWithPort("localhost")
+	require.NoError(t, err)
+	agent, _, tearDown := setupAgent(t, topicInfos, cfg)
+	batch := produceBatch(t, topicName, partitionID, agent.Conf().KafkaListenerConfig.Address)
+	produced := time.Now()
+
+	// Wait for a snapshot which includes the batch
+	testutils.WaitUntil(t, func() (bool, error) {
+		status := getReplicationStatus(t, agent.AdminListenAddress())
+		require.True(t, status.Active)
+		return status.LastSnapshot.SnapshotTime.After(produced), nil
+	})
+	// The region is lost
+	tearDown(t)
+
+	// The standby cluster can't be started from the replica without promoting it
+	cfg = NewConf()
+	inMemMemberships := NewInMemClusterMemberships()
+	inMemMemberships.Start()
+	localTransports := transport.NewLocalTransports()
+	transportServer, err := localTransports.NewLocalServer("standby")
+	require.NoError(t, err)
+	standby, err := NewAgentWithFactories(cfg, replicaStore, localTransports.CreateConnection, transportServer,
+		inMemMemberships.NewMembership)
+	require.NoError(t, err)
+	err = standby.Start()
+	require.Error(t, err)
+
+	cfg.PromoteReplica = true
+	standby, tearDown = setupAgentWithArgs(t, cfg, replicaStore, inMemMemberships, localTransports)
+	defer tearDown(t)
+
+	fetched := fetchBatch(t, topicName, partitionID, standby.Conf().KafkaListenerConfig.Address)
+	kafkaencoding.SetCrc(batch, 0)
+	kafkaencoding.SetCrc(fetched, 0)
+	require.Equal(t, batch, fetched)
+}
+
+func TestAdminServerReplicationNotEnabled(t *testing.T) {
+	cfg := NewConf()
+	var err error
+	cfg.AdminListenAddress, err = common.AddressWithPort("localhost")
+	require.NoError(t, err)
+	agent, _, tearDown := setupAgent(t, nil, cfg)
+	defer tearDown(t)
+	resp, err := http.Get(fmt.Sprintf("http://%s/replication/status", agent.AdminListenAddress()))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func getReplicationStatus(t *testing.T, adminAddress string) replication.Status {
+	resp, err := http.Get(fmt.Sprintf("http://%s/replication/status", adminAddress))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var status replication.Status
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	require.NoError(t, err)
+	return status
+}
+
+func fetchBatch(t *testing.T, topicName string, partitionID int, address string) []byte {
+	cl, err := apiclient.NewKafkaApiClient()
+	require.NoError(t, err)
+	conn, err := cl.NewConnection(address)
+	require.NoError(t, err)
+	defer func() {
+		err := conn.Close()
+		require.NoError(t, err)
+	}()
+	fetchReq := kafkaprotocol.FetchRequest{
+		MaxBytes: math.MaxInt32,
+		Topics: []kafkaprotocol.FetchRequestFetchTopic{
+			{
+				Topic: common.StrPtr(topicName),
+				Partitions: []kafkaprotocol.FetchRequestFetchPartition{
+					{
+						Partition:         int32(partitionID),
+						PartitionMaxBytes: math.MaxInt32,
+					},
+				},
+			},
+		},
+	}
+	var fetchResp kafkaprotocol.FetchResponse
+	r, err := conn.SendRequest(&fetchReq, kafkaprotocol.APIKeyFetch, 4, &fetchResp)
+	require.NoError(t, err)
+	res, ok := r.(*kafkaprotocol.FetchResponse)
+	require.True(t, ok)
+	require.Equal(t, 1, len(res.Responses))
+	require.Equal(t, 1, len(res.Responses[0].Partitions))
+	partResp := res.Responses[0].Partitions[0]
+	require.Equal(t, kafkaprotocol.ErrorCodeNone, int(partResp.ErrorCode))
+	require.Equal(t, 10, int(partResp.HighWatermark))
+	decompressed, err := maybeDecompressBatches([][]byte{partResp.Records})
+	require.NoError(t, err)
+	return decompressed[0]
+}
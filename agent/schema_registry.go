@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"encoding/binary"
+	"github.com/spirit-labs/tektite/cluster"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/compress"
+	"github.com/spirit-labs/tektite/kafkaencoding"
+	"github.com/spirit-labs/tektite/kafkaprotocol"
+	"github.com/spirit-labs/tektite/pusher"
+	"github.com/spirit-labs/tektite/schemaserver"
+	"github.com/spirit-labs/tektite/topicmeta"
+	"github.com/spirit-labs/tektite/transport"
+	"github.com/spirit-labs/tektite/types"
+	"math"
+	"time"
+)
+
+// schemaRegistryLeaderKey is the coordinator key used to choose the agent which performs schema registry writes
+const schemaRegistryLeaderKey = "s.schema-registry"
+
+const schemaRegistryFetchMaxBytes = 10 * 1024 * 1024
+
+// schemaTopicLog holds the state of the schema registry in a compacted topic with a single partition. Records are
+// written with a direct produce to the table pusher which owns the partition, and read with the batch fetcher.
+type schemaTopicLog struct {
+	agent     *Agent
+	topicName string
+}
+
+func (l *schemaTopicLog) getTopicInfo(create bool) (topicmeta.TopicInfo, bool, error) {
+	info, exists, err := l.agent.topicMetaCache.GetTopicInfo(l.topicName)
+	if err != nil || exists || !create {
+		return info, exists, err
+	}
+	cl, err := l.agent.controlClientCache.GetClient()
+	if err != nil {
+		return topicmeta.TopicInfo{}, false, err
+	}
+	err = cl.CreateOrUpdateTopic(topicmeta.TopicInfo{
+		Name:                l.topicName,
+		PartitionCount:      1,
+		RetentionTime:       -1,
+		MaxMessageSizeBytes: l.agent.cfg.DefaultMaxMessageSizeBytes,
+		Compacted:           true,
+	}, true)
+	if err != nil && extractErrorCode(err) != common.TopicAlreadyExists {
+		return topicmeta.TopicInfo{}, false, err
+	}
+	return l.agent.topicMetaCache.GetTopicInfo(l.topicName)
+}
+
+func (l *schemaTopicLog) Append(records []schemaserver.Record) error {
+	info, exists, err := l.getTopicInfo(true)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return common.NewTektiteErrorf(common.Unavailable, "schema registry topic %s does not exist", l.topicName)
+	}
+	timestamp := types.Timestamp{Val: time.Now().UnixMilli()}
+	batch := make([]byte, 61)
+	for i, rec := range records {
+		// Headers are encoded as a zero count
+		batch, _ = kafkaencoding.AppendToBatch(batch, int64(i), rec.Key, []byte{0}, rec.Value, timestamp, timestamp,
+			math.MaxInt, true)
+	}
+	kafkaencoding.SetBatchHeader(batch, 0, int64(len(records)-1), timestamp, timestamp, len(records))
+	partHash, err := l.agent.partitionHashes.GetPartitionHash(info.ID, 0)
+	if err != nil {
+		return err
+	}
+	pusherAddress, ok := cluster.ChooseMemberAddressForHash(partHash, l.agent.controller.GetClusterState().Members)
+	if !ok {
+		return common.NewTektiteErrorf(common.Unavailable, "no members in cluster")
+	}
+	req := pusher.DirectProduceRequest{
+		TopicProduceRequests: []pusher.TopicProduceRequest{{
+			TopicID:                  info.ID,
+			PartitionProduceRequests: []pusher.PartitionProduceRequest{{PartitionID: 0, Batch: batch}},
+		}},
+	}
+	conn, err := l.agent.connCaches.GetConnection(pusherAddress)
+	if err != nil {
+		return err
+	}
+	_, err = conn.SendRPC(transport.HandlerIDTablePusherDirectProduce, req.Serialize(createRequestBuffer()))
+	return err
+}
+
+func createRequestBuffer() []byte {
+	buff := make([]byte, 0, 128)                  // Initial size guess
+	buff = binary.BigEndian.AppendUint16(buff, 1) // rpc version - currently 1
+	return buff
+}
+
+func (l *schemaTopicLog) Read(offset int64) ([]schemaserver.Record, int64, error) {
+	_, exists, err := l.getTopicInfo(false)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !exists {
+		// Nothing has been written yet
+		return nil, offset, nil
+	}
+	req := kafkaprotocol.FetchRequest{
+		ReplicaId: -1,
+		MaxBytes:  schemaRegistryFetchMaxBytes,
+		Topics: []kafkaprotocol.FetchRequestFetchTopic{{
+			Topic: &l.topicName,
+			Partitions: []kafkaprotocol.FetchRequestFetchPartition{{
+				FetchOffset:       offset,
+				PartitionMaxBytes: schemaRegistryFetchMaxBytes,
+			}},
+		}},
+	}
+	respCh := make(chan *kafkaprotocol.FetchResponse, 1)
+	err = l.agent.batchFetcher.HandleFetchRequest(nil, 4, &req, func(resp *kafkaprotocol.FetchResponse) error {
+		respCh <- resp
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	resp := <-respCh
+	partResp := resp.Responses[0].Partitions[0]
+	if partResp.ErrorCode != kafkaprotocol.ErrorCodeNone {
+		return nil, 0, common.NewTektiteErrorf(common.Unavailable, "failed to fetch from schema registry topic: error code %d",
+			partResp.ErrorCode)
+	}
+	var records []schemaserver.Record
+	nextOffset := offset
+	buff := partResp.Records
+	for len(buff) > 0 {
+		batchLen := 12 + int(kafkaencoding.BatchLength(buff))
+		batch := buff[:batchLen]
+		buff = buff[batchLen:]
+		compressionType := compress.CompressionType(kafkaencoding.CompressionType(batch))
+		if compressionType != compress.CompressionTypeNone {
+			decompressed, err := compress.Decompress(compressionType, batch[61:])
+			if err != nil {
+				return nil, 0, err
+			}
+			batch = append(common.ByteSliceCopy(batch[:61]), decompressed...)
+		}
+		for _, msg := range kafkaencoding.BatchToRawMessages(batch) {
+			if msg.Offset < offset {
+				continue
+			}
+			records = append(records, schemaserver.Record{Key: msg.Key, Value: msg.Value})
+			nextOffset = msg.Offset + 1
+		}
+	}
+	return records, nextOffset, nil
+}
+
+// schemaRegistryLeadership uses the controller to choose the agent which performs schema registry writes, in the same
+// way as a group coordinator is chosen, so a new write leader is chosen if it leaves the cluster.
+type schemaRegistryLeadership struct {
+	agent *Agent
+}
+
+func (s *schemaRegistryLeadership) IsWriteLeader() (bool, string, error) {
+	cl, err := s.agent.controlClientCache.GetClient()
+	if err != nil {
+		return false, "", err
+	}
+	_, address, _, err := cl.GetCoordinatorInfo(schemaRegistryLeaderKey)
+	if err != nil {
+		return false, "", err
+	}
+	return address == s.agent.kafkaServer.ListenAddress(), address, nil
+}
+
+func (s *schemaRegistryLeadership) ForwardWrite(leaderAddress string, request []byte) ([]byte, error) {
+	var clusterAddress string
+	for _, member := range s.agent.controller.GetClusterState().Members {
+		var data common.MembershipData
+		data.Deserialize(member.Data, 0)
+		if data.KafkaListenerAddress == leaderAddress {
+			clusterAddress = data.ClusterListenAddress
+			break
+		}
+	}
+	if clusterAddress == "" {
+		return nil, common.NewTektiteErrorf(common.Unavailable, "schema registry write leader %s is not in the cluster",
+			leaderAddress)
+	}
+	conn, err := s.agent.connCaches.GetConnection(clusterAddress)
+	if err != nil {
+		return nil, err
+	}
+	return conn.SendRPC(transport.HandlerIDSchemaRegistryWrite, request)
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/spirit-labs/tektite/schemareg"
+	"github.com/spirit-labs/tektite/testutils"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	cfg := NewConf()
+	cfg.SchemaRegistryConf.Enabled = true
+	cfg.SchemaRegistryConf.RefreshInterval = 0
+	numAgents := 3
+	agents, tearDown := setupAgents(t, cfg, numAgents, func(int) string { return "az1" })
+	defer tearDown(t)
+	for _, agent := range agents {
+		testutils.WaitUntil(t, func() (bool, error) {
+			return len(agent.controller.GetClusterState().Members) == numAgents, nil
+		})
+	}
+
+	schemas := []string{
+		`{"type":"record","name":"order","fields":[{"name":"id","type":"int"}]}`,
+		`{"type":"record","name":"order","fields":[{"name":"id","type":"int"},{"name":"qty","type":"int","default":0}]}`,
+		`{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"qty","type":"int","default":0}]}`,
+	}
+	// Register through each agent, so writes are performed by the write leader and forwarded to it
+	for i, schema := range schemas {
+		id := registerSchema(t, agents[i].SchemaRegistry().ListenAddress(), "orders-value", schema)
+		require.Equal(t, i+1, id)
+	}
+	// Every agent can read every version
+	for _, agent := range agents {
+		reg := schemareg.NewHTTPRegistry("http://" + agent.SchemaRegistry().ListenAddress())
+		for i, schema := range schemas {
+			s, err := reg.GetSchema("orders-value", i+1)
+			require.NoError(t, err)
+			require.Equal(t, &schemareg.Schema{ID: i + 1, Subject: "orders-value", Version: i + 1,
+				SchemaType: schemareg.SchemaTypeAvro, Schema: schema}, s)
+			s, err = reg.GetSchemaByID(i + 1)
+			require.NoError(t, err)
+			require.Equal(t, schema, s.Schema)
+		}
+	}
+
+	// The schemas are held in a compacted topic
+	info, exists, err := agents[0].topicMetaCache.GetTopicInfo(cfg.SchemaRegistryConf.TopicName)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.True(t, info.Compacted)
+	require.Equal(t, 1, info.PartitionCount)
+}
+
+func registerSchema(t *testing.T, address string, subject string, schema string) int {
+	buff, err := json.Marshal(map[string]string{"schema": schema})
+	require.NoError(t, err)
+	resp, err := http.Post(fmt.Sprintf("http://%s/subjects/%s/versions", address, subject),
+		"application/vnd.schemaregistry.v1+json", bytes.NewReader(buff))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var res struct {
+		ID int `json:"id"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	return res.ID
+}
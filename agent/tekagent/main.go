@@ -50,6 +50,15 @@ func run() error {
 	if err := cfg.Log.Configure(); err != nil {
 		return err
 	}
+	if cfg.Conf.RestoreBackup != "" {
+		info, err := agent.RestoreBackupFromCommandConf(cfg.Conf)
+		if err != nil {
+			return err
+		}
+		fmt.Println(fmt.Sprintf("restored cluster from backup %s taken at %s", info.ID,
+			info.CreateTime.Format(time.RFC3339)))
+		return nil
+	}
 	ag, err := agent.CreateAgentFromCommandConf(cfg.Conf)
 	if err != nil {
 		return err
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/apache/arrow/go/v11/arrow/decimal128"
 	"github.com/spirit-labs/tektite/asl/conf"
@@ -23,6 +24,7 @@ import (
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/evbatch"
 	"github.com/spirit-labs/tektite/expr"
+	"github.com/spirit-labs/tektite/opers"
 	"github.com/spirit-labs/tektite/parser"
 	"github.com/spirit-labs/tektite/protos/clustermsgs"
 	"github.com/spirit-labs/tektite/types"
@@ -447,6 +449,66 @@ func TestExecuteDirectQueryWithColHeaders(t *testing.T) {
 	require.Equal(t, expected, bodyString)
 }
 
+func TestExplain(t *testing.T) {
+	server, queryMgr, _, _ := startServer(t)
+	defer func() {
+		err := server.Stop()
+		require.NoError(t, err)
+	}()
+	client := createClient(t, true)
+	defer client.CloseIdleConnections()
+
+	uri := fmt.Sprintf("https://%s/tektite/explain?col_headers=true", server.ListenAddress())
+	resp := sendPostRequest(t, client, uri, "explain test_stream")
+	defer closeRespBody(t, resp)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	bodyBytes, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "test_stream", queryMgr.getExplainStreamName())
+
+	expected := `["id","parent_id","operator","definition","in_schema","out_schema","mapping_id","partitions","processors","repartition","slab_ids","retention","upstream_streams","child_streams"]
+["int","int","string","string","string","string","string","int","int","bool","string","string","string","string"]
+[0,null,"kafka in","(kafka in partitions = 4)",null,"f1: int","test_stream",4,2,false,"1000",null,null,null]
+[1,0,"store stream","(store stream retention = 1h)","f1: int","f1: int","test_stream",4,2,false,"1001","1h0m0s",null,"child_stream"]
+`
+	require.Equal(t, expected, string(bodyBytes))
+}
+
+func TestExplainInvalidStatement(t *testing.T) {
+	testErrorResponse(t, "/tektite/explain", "delete(test_stream)",
+		"TEK1001 - invalid statement. must be explain\n", http.StatusBadRequest, true)
+}
+
+func TestStreamStats(t *testing.T) {
+	server, queryMgr, _, _ := startServer(t)
+	defer func() {
+		err := server.Stop()
+		require.NoError(t, err)
+	}()
+	client := createClient(t, true)
+	defer client.CloseIdleConnections()
+
+	uri := fmt.Sprintf("https://%s/tektite/stats?col_headers=true", server.ListenAddress())
+	resp := sendPostRequest(t, client, uri, "show stream stats test_stream")
+	defer closeRespBody(t, resp)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	bodyBytes, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "test_stream", queryMgr.getStatsStreamName())
+
+	expected := `["stream_name","id","operator","rows_in","rows_out","batches","avg_batch_latency","processing_time","state_reads","state_writes","watermark_lag","backlog"]
+["string","int","string","int","int","int","string","string","int","int","string","int"]
+["test_stream",0,"kafka in",100,100,10,"100µs","1ms",0,0,"2s",7]
+["test_stream",1,"store stream",100,100,10,"200µs","2ms",0,100,null,null]
+`
+	require.Equal(t, expected, string(bodyBytes))
+}
+
+func TestStreamStatsInvalidStatement(t *testing.T) {
+	testErrorResponse(t, "/tektite/stats", "explain test_stream",
+		"TEK1001 - invalid statement. must be show stream stats\n", http.StatusBadRequest, true)
+}
+
 func TestExecutePreparedStatementWithArrowEncoding(t *testing.T) {
 	server, queryMgr, _, _ := startServer(t)
 	defer func() {
@@ -818,6 +880,8 @@ type testQueryManager struct {
 
 	receiverPrepareQueryDesc *parser.PrepareQueryDesc
 	directQueryTsl           string
+	explainStreamName        string
+	statsStreamName          string
 }
 
 func (t *testQueryManager) GetLastCompletedVersion() int {
@@ -850,6 +914,61 @@ func (t *testQueryManager) ExecuteQueryDirect(tsl string, _ parser.QueryDesc, ou
 	return nil
 }
 
+func (t *testQueryManager) Explain(explain parser.ExplainDesc) (*evbatch.Batch, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.explainStreamName = explain.StreamName
+	return opers.ExplainBatch(createTestExplainPlan()), nil
+}
+
+func (t *testQueryManager) StreamStats(showStats parser.ShowStreamStatsDesc) (*evbatch.Batch, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.statsStreamName = showStats.StreamName
+	return opers.StreamStatsBatch(createTestStreamStats()), nil
+}
+
+func (t *testQueryManager) getStatsStreamName() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.statsStreamName
+}
+
+func createTestStreamStats() []*opers.StreamStats {
+	return []*opers.StreamStats{
+		{
+			StreamName: "test_stream",
+			Backlog:    7,
+			Operators: []*opers.OperatorStats{
+				{ID: 0, Operator: "kafka in", RowsIn: 100, RowsOut: 100, Batches: 10, ProcessingTime: time.Millisecond,
+					WaterMarkLag: 2 * time.Second},
+				{ID: 1, Operator: "store stream", RowsIn: 100, RowsOut: 100, Batches: 10,
+					ProcessingTime: 2 * time.Millisecond, StateWrites: 100, WaterMarkLag: -1},
+			},
+		},
+	}
+}
+
+func (t *testQueryManager) getExplainStreamName() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.explainStreamName
+}
+
+func createTestExplainPlan() []*opers.OperatorPlan {
+	schema := &opers.OperatorSchema{
+		EventSchema:     evbatch.NewEventSchema([]string{"f1"}, []types.ColumnType{types.ColumnTypeInt}),
+		PartitionScheme: opers.NewPartitionScheme("test_stream", 4, true, 2),
+	}
+	return []*opers.OperatorPlan{
+		{ID: 0, ParentID: -1, Operator: "kafka in", Definition: "(kafka in partitions = 4)", OutSchema: schema,
+			Partitions: 4, SlabIDs: []int{1000}},
+		{ID: 1, ParentID: 0, Operator: "store stream", Definition: "(store stream retention = 1h)", InSchema: schema,
+			OutSchema: schema, Partitions: 4, SlabIDs: []int{1001}, Retention: time.Hour,
+			ChildStreams: []string{"child_stream"}},
+	}
+}
+
 func (t *testQueryManager) getDirectQueryTsl() string {
 	t.lock.Lock()
 	defer t.lock.Unlock()
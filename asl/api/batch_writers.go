@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"github.com/spirit-labs/tektite/asl/encoding"
 	"github.com/spirit-labs/tektite/evbatch"
 	"github.com/spirit-labs/tektite/types"
@@ -66,6 +67,9 @@ func (j *jsonLinesBatchWriter) WriteBatch(batch *evbatch.Batch, writer http.Resp
 				case types.ColumnTypeIDTimestamp:
 					// timestamps are converted to unix millis past epoch
 					val = col.(*evbatch.TimestampColumn).Get(i).Val
+				case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+					// nested values are written as JSON arrays and objects
+					val = json.RawMessage(types.AppendJSON(nil, fType, col.(*evbatch.NestedColumn).Get(i)))
 				default:
 					panic("unknown type")
 				}
@@ -109,6 +113,9 @@ func (b *ArrowBatchWriter) WriteHeaders(columnNames []string, columnTypes []type
 			dt := fType.(*types.DecimalType)
 			buff = encoding.AppendUint32ToBufferLE(buff, uint32(dt.Precision))
 			buff = encoding.AppendUint32ToBufferLE(buff, uint32(dt.Scale))
+		} else if types.IsNestedType(fType) {
+			// Nested types are written as their type string, e.g. array<int>
+			buff = encoding.AppendStringToBufferLE(buff, fType.String())
 		}
 	}
 	binary.LittleEndian.PutUint64(buff, uint64(len(buff)-8))
@@ -191,6 +198,14 @@ func DecodeArrowSchema(buff []byte) (*evbatch.EventSchema, int) {
 			fTypes[i] = types.ColumnTypeBytes
 		case types.ColumnTypeIDTimestamp:
 			fTypes[i] = types.ColumnTypeTimestamp
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			var sType string
+			sType, off = encoding.ReadStringFromBufferLE(buff, off)
+			nestedType, err := types.StringToColumnType(sType)
+			if err != nil {
+				panic(fmt.Sprintf("invalid nested type %s", sType))
+			}
+			fTypes[i] = nestedType
 		default:
 			panic("unexpected type")
 		}
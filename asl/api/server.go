@@ -147,6 +147,8 @@ func (s *HTTPAPIServer) start() error {
 	mux.HandleFunc(fmt.Sprintf("%s/query", s.apiPath), s.handleQuery)
 	mux.HandleFunc(fmt.Sprintf("%s/exec", s.apiPath), s.handleExecPreparedStatement)
 	mux.HandleFunc(fmt.Sprintf("%s/statement", s.apiPath), s.handleStatement)
+	mux.HandleFunc(fmt.Sprintf("%s/explain", s.apiPath), s.handleExplain)
+	mux.HandleFunc(fmt.Sprintf("%s/stats", s.apiPath), s.handleStreamStats)
 	mux.HandleFunc(fmt.Sprintf("%s/wasm-register", s.apiPath), s.handleWasmRegister)
 	mux.HandleFunc(fmt.Sprintf("%s/wasm-unregister", s.apiPath), s.handleWasmUnregister)
 	mux.HandleFunc(fmt.Sprintf("%s/scram-auth", s.apiPath), s.handleScramAuth)
@@ -488,6 +490,14 @@ func convertPreparedStatementArgs(args []any, argTypes []types.ColumnType) ([]an
 			default:
 				ok = false
 			}
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			// nested values are provided as JSON arrays and objects
+			buff, err := json.Marshal(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = types.ParseJSON(buff, argType)
+			ok = args[i] != nil
 		default:
 			panic("unexpected type")
 		}
@@ -542,8 +552,9 @@ func (s *HTTPAPIServer) handleStatement(writer http.ResponseWriter, request *htt
 		writeInvalidStatementError(err.Error(), writer)
 		return
 	}
-	if tsl.CreateStream == nil && tsl.DeleteStream == nil && tsl.PrepareQuery == nil && tsl.DeleteQuery == nil {
-		writeError("invalid statement. must be create stream / delete stream / prepare query / delete query", writer, common.StatementError)
+	if tsl.CreateStream == nil && tsl.DeleteStream == nil && tsl.PrepareQuery == nil && tsl.DeleteQuery == nil &&
+		tsl.Savepoint == nil {
+		writeError("invalid statement. must be create stream / delete stream / prepare query / delete query / savepoint stream", writer, common.StatementError)
 		return
 	}
 	if err := s.commandManager.ExecuteCommand(com); err != nil {
@@ -551,6 +562,72 @@ func (s *HTTPAPIServer) handleStatement(writer http.ResponseWriter, request *htt
 	}
 }
 
+func (s *HTTPAPIServer) handleExplain(writer http.ResponseWriter, request *http.Request) {
+	defer common.TektitePanicHandler()
+	u := s.checkRequest(writer, request)
+	if u == nil {
+		return
+	}
+	if !s.maybeAuthenticate(writer, request) {
+		return
+	}
+	batchWriter := getBatchWriter(writer, request)
+	includeHeader := getIncludeHeader(u)
+	com, ok := getBodyAsString(writer, request)
+	if !ok {
+		return
+	}
+	tsl, err := s.parser.ParseTSL(com)
+	if err != nil {
+		writeInvalidStatementError(err.Error(), writer)
+		return
+	}
+	if tsl.Explain == nil {
+		writeError("invalid statement. must be explain", writer, common.StatementError)
+		return
+	}
+	execQuery(writer, batchWriter, includeHeader, func(o outFunc) error {
+		batch, err := s.queryManager.Explain(*tsl.Explain)
+		if err != nil {
+			return err
+		}
+		return o(true, 1, batch)
+	})
+}
+
+func (s *HTTPAPIServer) handleStreamStats(writer http.ResponseWriter, request *http.Request) {
+	defer common.TektitePanicHandler()
+	u := s.checkRequest(writer, request)
+	if u == nil {
+		return
+	}
+	if !s.maybeAuthenticate(writer, request) {
+		return
+	}
+	batchWriter := getBatchWriter(writer, request)
+	includeHeader := getIncludeHeader(u)
+	com, ok := getBodyAsString(writer, request)
+	if !ok {
+		return
+	}
+	tsl, err := s.parser.ParseTSL(com)
+	if err != nil {
+		writeInvalidStatementError(err.Error(), writer)
+		return
+	}
+	if tsl.ShowStreamStats == nil {
+		writeError("invalid statement. must be show stream stats", writer, common.StatementError)
+		return
+	}
+	execQuery(writer, batchWriter, includeHeader, func(o outFunc) error {
+		batch, err := s.queryManager.StreamStats(*tsl.ShowStreamStats)
+		if err != nil {
+			return err
+		}
+		return o(true, 1, batch)
+	})
+}
+
 func (s *HTTPAPIServer) checkRequest(writer http.ResponseWriter, request *http.Request) *url.URL {
 	if !checkHttp2(writer, request) {
 		return nil
@@ -206,11 +206,66 @@ func (c *Cli) doExecuteStatementWithError(statement string, out chan string) (in
 		out <- fmt.Sprintf("child_streams: %s", childStreams)
 		out <- ""
 		return 0, false, nil
+	} else if tsl.Explain != nil {
+		qr, err := c.client.Explain(statement)
+		if err != nil {
+			return 0, true, err
+		}
+		writeExplainResult(qr, out)
+		return 0, false, nil
+	} else if tsl.ShowStreamStats != nil {
+		qr, err := c.client.StreamStats(statement)
+		if err != nil {
+			return 0, true, err
+		}
+		ch := make(chan client.StreamChunk, 1)
+		ch <- client.StreamChunk{Chunk: qr}
+		close(ch)
+		return c.streamToOut(out, ch, true), true, nil
 	}
 	err := c.client.ExecuteStatement(statement)
 	return -1, true, err
 }
 
+// writeExplainResult writes each operator in the result of an explain on multiple lines, with the column order as in
+// opers.ExplainSchema
+func writeExplainResult(qr client.QueryResult, out chan string) {
+	out <- ""
+	for i := 0; i < qr.RowCount(); i++ {
+		row := qr.Row(i)
+		header := fmt.Sprintf("%d: %s", row.IntVal(0), row.StringVal(2))
+		if !row.IsNull(3) {
+			header += " " + row.StringVal(3)
+		}
+		if !row.IsNull(1) {
+			header += fmt.Sprintf(" (parent: %d)", row.IntVal(1))
+		}
+		out <- header
+		if !row.IsNull(4) {
+			out <- fmt.Sprintf("    in_schema:        {%s}", row.StringVal(4))
+		}
+		out <- fmt.Sprintf("    out_schema:       {%s}", row.StringVal(5))
+		out <- fmt.Sprintf("    partitions:       %d processors: %d mapping_id: %s", row.IntVal(7), row.IntVal(8),
+			row.StringVal(6))
+		if row.BoolVal(9) {
+			out <- "    repartition:      true"
+		}
+		if !row.IsNull(10) {
+			out <- fmt.Sprintf("    slab_ids:         %s", row.StringVal(10))
+		}
+		if !row.IsNull(11) {
+			out <- fmt.Sprintf("    retention:        %s", row.StringVal(11))
+		}
+		if !row.IsNull(12) {
+			out <- fmt.Sprintf("    upstream_streams: %s", row.StringVal(12))
+		}
+		if !row.IsNull(13) {
+			out <- fmt.Sprintf("    child_streams:    %s", row.StringVal(13))
+		}
+	}
+	out <- ""
+}
+
 func (c *Cli) streamToOut(out chan string, ch chan client.StreamChunk, isQuery bool) int {
 	rowCount := 0
 	first := true
@@ -317,6 +372,9 @@ func formatLine(res client.QueryResult, rowIndex int, colWidths []int) (string,
 			case types.ColumnTypeIDTimestamp:
 				ts := row.TimestampVal(i)
 				v = convertUnixMillisToDateString(ts.Val)
+			case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+				colType := res.Meta().ColumnTypes()[i]
+				v = string(types.AppendJSON(nil, colType, row.NestedVal(i)))
 			default:
 				panic("unexpected type")
 			}
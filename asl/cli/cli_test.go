@@ -6,6 +6,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/apache/arrow/go/v11/arrow/decimal128"
 	"github.com/spirit-labs/tektite/asl/api"
@@ -16,6 +17,7 @@ import (
 	"github.com/spirit-labs/tektite/cmdmgr"
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/opers"
 	"github.com/spirit-labs/tektite/parser"
 	"github.com/spirit-labs/tektite/protos/clustermsgs"
 	"github.com/spirit-labs/tektite/types"
@@ -230,6 +232,18 @@ func testCli(t *testing.T, clientTLSConfig client.TLSConfig, serverTLSConfig con
 	require.NoError(t, err)
 	require.Equal(t, stmt, queryMgr.getDirectQueryState())
 
+	stmt = "explain test_stream"
+	out.WriteString(stmt)
+	out.WriteRune('\n')
+	err = execStatement(stmt, cli, &out)
+	require.NoError(t, err)
+
+	stmt = "show stream stats test_stream"
+	out.WriteString(stmt)
+	out.WriteRune('\n')
+	err = execStatement(stmt, cli, &out)
+	require.NoError(t, err)
+
 	stmt = "delete(test_stream)"
 	out.WriteString(stmt)
 	out.WriteRune('\n')
@@ -321,6 +335,28 @@ OK
 | 1000049              | 49.123450                  | null                       | 49123456789.9876           | foobar-49                  | null                       | 1970-01-01 00:33:20.049000 |
 +----------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------+
 50 rows returned
+explain test_stream
+
+0: bridge from (bridge from test_topic partitions = 23)
+    out_schema:       {f1: int}
+    partitions:       23 processors: 2 mapping_id: test_stream
+    slab_ids:         1000
+1: store stream (store stream) (parent: 0)
+    in_schema:        {f1: int}
+    out_schema:       {f1: int}
+    partitions:       23 processors: 2 mapping_id: test_stream
+    slab_ids:         1001
+    retention:        1h0m0s
+    child_streams:    child_stream1, child_stream2
+
+show stream stats test_stream
++-----------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------+
+| stream_name   | id            | operator      | rows_in       | rows_out      | batches       | avg_batch_l.. | processing_.. | state_reads   | state_writes  | watermark_lag | backlog       |
++-----------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------+
+| test_stream   | 0             | bridge from   | 100           | 100           | 10            | 1ms           | 10ms          | 0             | 0             | 2s            | 7             |
+| test_stream   | 1             | store stream  | 100           | 100           | 10            | 2ms           | 20ms          | 0             | 100           | null          | null          |
++-----------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------------+
+2 rows returned
 delete(test_stream)
 OK
 register_wasm("testdata/wasm/test_mod1.wasm")
@@ -382,6 +418,35 @@ func (t *testQueryManager) ExecuteQueryDirect(tsl string, _ parser.QueryDesc, ou
 	return nil
 }
 
+func (t *testQueryManager) Explain(parser.ExplainDesc) (*evbatch.Batch, error) {
+	schema := &opers.OperatorSchema{
+		EventSchema:     evbatch.NewEventSchema([]string{"f1"}, []types.ColumnType{types.ColumnTypeInt}),
+		PartitionScheme: opers.NewPartitionScheme("test_stream", 23, true, 2),
+	}
+	return opers.ExplainBatch([]*opers.OperatorPlan{
+		{ID: 0, ParentID: -1, Operator: "bridge from", Definition: "(bridge from test_topic partitions = 23)",
+			OutSchema: schema, Partitions: 23, SlabIDs: []int{1000}},
+		{ID: 1, ParentID: 0, Operator: "store stream", Definition: "(store stream)", InSchema: schema,
+			OutSchema: schema, Partitions: 23, SlabIDs: []int{1001}, Retention: time.Hour,
+			ChildStreams: []string{"child_stream1", "child_stream2"}},
+	}), nil
+}
+
+func (t *testQueryManager) StreamStats(parser.ShowStreamStatsDesc) (*evbatch.Batch, error) {
+	return opers.StreamStatsBatch([]*opers.StreamStats{
+		{
+			StreamName: "test_stream",
+			Backlog:    7,
+			Operators: []*opers.OperatorStats{
+				{ID: 0, Operator: "bridge from", RowsIn: 100, RowsOut: 100, Batches: 10,
+					ProcessingTime: 10 * time.Millisecond, WaterMarkLag: 2 * time.Second},
+				{ID: 1, Operator: "store stream", RowsIn: 100, RowsOut: 100, Batches: 10,
+					ProcessingTime: 20 * time.Millisecond, StateWrites: 100, WaterMarkLag: -1},
+			},
+		},
+	}), nil
+}
+
 func (t *testQueryManager) getDirectQueryState() string {
 	t.lock.Lock()
 	defer t.lock.Unlock()
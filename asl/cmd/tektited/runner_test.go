@@ -138,6 +138,11 @@ func createConfigWithAllFields() conf.Config {
 		KafkaNewMemberJoinTimeout:   4 * time.Second,
 		KafkaFetchCacheMaxSizeBytes: 7654321,
 
+		SchemaRegistryCacheMaxAge: conf.DefaultSchemaRegistryCacheMaxAge,
+
+		SavepointChunkMaxSizeBytes: conf.DefaultSavepointChunkMaxSizeBytes,
+		SavepointCallTimeout:       conf.DefaultSavepointCallTimeout,
+
 		CommandCompactionInterval: 3 * time.Second,
 
 		DDProfilerTypes:           "HEAP,CPU",
@@ -169,6 +174,7 @@ func createConfigWithAllFields() conf.Config {
 		PrefixRetentionRemoveCheckInterval: 17 * time.Second,
 		PrefixRetentionRefreshInterval:     13 * time.Second,
 		CompactionMaxSSTableSize:           54321,
+		HistoryRetention:                   36 * time.Hour,
 
 		TableCacheMaxSizeBytes:  12345678,
 		TableCacheSSTableMaxAge: 5 * time.Minute,
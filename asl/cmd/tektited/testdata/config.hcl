@@ -44,6 +44,7 @@ ss-table-register-retry-delay = "35s"
 ss-table-push-retry-delay = "6s"
 prefix-retention-remove-check-interval = "17s"
 compaction-max-ss-table-size = 54321
+history-retention = "36h"
 
 command-compaction-interval = "3s"
 
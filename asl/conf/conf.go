@@ -85,9 +85,15 @@ const (
 
 	DefaultWebUISampleInterval = 5 * time.Second
 
+	DefaultSchemaRegistryCacheMaxAge = 30 * time.Second
+
+	DefaultSavepointChunkMaxSizeBytes = 16 * 1024 * 1024
+	DefaultSavepointCallTimeout       = 1 * time.Minute
+
 	DevObjectStoreType      = "dev"
 	EmbeddedObjectStoreType = "embedded"
 	MinioObjectStoreType    = "minio"
+	FsObjectStoreType       = "fs"
 
 	DefaultWasmModuleInstances = 8
 
@@ -125,6 +131,9 @@ type Config struct {
 	SSTableRegisterRetryDelay          time.Duration
 	PrefixRetentionRemoveCheckInterval time.Duration
 	CompactionMaxSSTableSize           int
+	// HistoryRetention is how long old versions of keys are kept by compaction, so they can be seen by point-in-time
+	// queries. Zero means old versions are removed as soon as they have been flushed.
+	HistoryRetention time.Duration
 
 	// Table-cache config
 	TableCacheMaxSizeBytes  parseableInt
@@ -156,6 +165,7 @@ type Config struct {
 	ObjectStoreType         string
 	BucketName              string
 	DevObjectStoreAddresses []string
+	FsObjectStoreDir        string
 
 	MinioEndpoint string
 	MinioUsername string
@@ -205,6 +215,15 @@ type Config struct {
 	KafkaNewMemberJoinTimeout   time.Duration
 	KafkaFetchCacheMaxSizeBytes parseableInt
 
+	// Schema registry config
+	SchemaRegistryURL         string `name:"schema-registry-url"`
+	SchemaRegistryFile        string
+	SchemaRegistryCacheMaxAge time.Duration
+
+	// Savepoint config
+	SavepointChunkMaxSizeBytes parseableInt
+	SavepointCallTimeout       time.Duration
+
 	LifeCycleEndpointEnabled bool
 	LifeCycleAddress         string
 	StartupEndpointPath      string
@@ -502,6 +521,17 @@ func (c *Config) ApplyDefaults() {
 	if c.BucketName == "" {
 		c.BucketName = DefaultBucketName
 	}
+
+	if c.SchemaRegistryCacheMaxAge == 0 {
+		c.SchemaRegistryCacheMaxAge = DefaultSchemaRegistryCacheMaxAge
+	}
+
+	if c.SavepointChunkMaxSizeBytes == 0 {
+		c.SavepointChunkMaxSizeBytes = DefaultSavepointChunkMaxSizeBytes
+	}
+	if c.SavepointCallTimeout == 0 {
+		c.SavepointCallTimeout = DefaultSavepointCallTimeout
+	}
 }
 
 func invalidConfigurationError(errMsg string) error {
@@ -540,6 +570,9 @@ func (c *Config) Validate() error { //nolint:gocyclo
 			c.AdminConsoleSampleInterval = DefaultWebUISampleInterval
 		}
 	}
+	if c.MetricsEnabled && c.MetricsBind == "" {
+		return invalidConfigurationError("metrics-bind must be specified")
+	}
 	if c.LifeCycleEndpointEnabled {
 		if c.LifeCycleAddress == "" {
 			return invalidConfigurationError("life-cycle-address must be specified")
@@ -614,12 +647,18 @@ func (c *Config) Validate() error { //nolint:gocyclo
 	if c.SegmentCacheMaxSize < 0 {
 		return invalidConfigurationError("segment-cache-max-size must be >= 0")
 	}
+	if c.HistoryRetention < 0 {
+		return invalidConfigurationError("history-retention must be >= 0")
+	}
 	if c.DevObjectStoreAddresses == nil {
 		c.DevObjectStoreAddresses = []string{DefaultDevObjectStoreAddress}
 	}
 	if c.ObjectStoreType == "" {
 		c.ObjectStoreType = DevObjectStoreType
 	}
+	if c.ObjectStoreType == FsObjectStoreType && c.FsObjectStoreDir == "" {
+		return invalidConfigurationError("fs-object-store-dir must be specified when object-store-type is 'fs'")
+	}
 	if c.ClusterManagerLockTimeout < 1*time.Millisecond {
 		return invalidConfigurationError("cluster-manager-lock-timeout must be >= 1ms")
 	}
@@ -687,5 +726,17 @@ func (c *Config) Validate() error { //nolint:gocyclo
 	if c.AuthenticationCacheTimeout == 0 {
 		c.AuthenticationCacheTimeout = 10 * time.Second
 	}
+	if c.SchemaRegistryURL != "" && c.SchemaRegistryFile != "" {
+		return invalidConfigurationError("only one of schema-registry-url and schema-registry-file can be specified")
+	}
+	if c.SchemaRegistryCacheMaxAge < 0 {
+		return invalidConfigurationError("schema-registry-cache-max-age must be >= 0")
+	}
+	if c.SavepointChunkMaxSizeBytes < 1 {
+		return invalidConfigurationError("savepoint-chunk-max-size-bytes must be > 0")
+	}
+	if c.SavepointCallTimeout < 1*time.Millisecond {
+		return invalidConfigurationError("savepoint-call-timeout must be >= 1ms")
+	}
 	return nil
 }
@@ -167,6 +167,13 @@ const (
 	readyEndpointPath      = "/readiness"
 )
 
+func invalidMetricsBind() Config {
+	cnf := validConf()
+	cnf.MetricsEnabled = true
+	cnf.MetricsBind = ""
+	return cnf
+}
+
 func invalidLifecycleListenAddress() Config {
 	cnf := validConf()
 	cnf.LifeCycleEndpointEnabled = true
@@ -213,6 +220,19 @@ func invalidSegmentCacheMaxSize() Config {
 	return cnf
 }
 
+func invalidHistoryRetention() Config {
+	cnf := validConf()
+	cnf.HistoryRetention = -1 * time.Second
+	return cnf
+}
+
+func invalidFsObjectStoreDir() Config {
+	cnf := validConf()
+	cnf.ObjectStoreType = FsObjectStoreType
+	cnf.FsObjectStoreDir = ""
+	return cnf
+}
+
 func invalidLevelManagerFlushInterval() Config {
 	cnf := validConf()
 	cnf.LevelManagerFlushInterval = 0
@@ -332,6 +352,25 @@ func invalidTableCacheSSTableMaxAge() Config {
 	return cnf
 }
 
+func invalidSchemaRegistryBothSources() Config {
+	cnf := validConf()
+	cnf.SchemaRegistryURL = "http://localhost:8081"
+	cnf.SchemaRegistryFile = "schemas.json"
+	return cnf
+}
+
+func invalidSavepointChunkMaxSizeBytes() Config {
+	cnf := validConf()
+	cnf.SavepointChunkMaxSizeBytes = -1
+	return cnf
+}
+
+func invalidSavepointCallTimeout() Config {
+	cnf := validConf()
+	cnf.SavepointCallTimeout = -1
+	return cnf
+}
+
 func TestValidate(t *testing.T) {
 	tcs := []struct {
 		name string
@@ -424,6 +463,11 @@ func TestValidate(t *testing.T) {
 			invalidHTTPAPIServerListenAddress(),
 			"invalid configuration: http-api-addresses must be specified",
 		},
+		{
+			"No metrics-bind",
+			invalidMetricsBind(),
+			"invalid configuration: metrics-bind must be specified",
+		},
 		{
 			"No life-cycle-address",
 			invalidLifecycleListenAddress(),
@@ -498,6 +542,16 @@ func TestValidate(t *testing.T) {
 			invalidSegmentCacheMaxSize(),
 			"invalid configuration: segment-cache-max-size must be >= 0",
 		},
+		{
+			"Negative history-retention",
+			invalidHistoryRetention(),
+			"invalid configuration: history-retention must be >= 0",
+		},
+		{
+			"No fs-object-store-dir",
+			invalidFsObjectStoreDir(),
+			"invalid configuration: fs-object-store-dir must be specified when object-store-type is 'fs'",
+		},
 
 		{
 			"Zero cluster-manager-lock-timeout",
@@ -585,6 +639,21 @@ func TestValidate(t *testing.T) {
 			invalidTableCacheSSTableMaxAge(),
 			"invalid configuration: table-cache-sstable-max-age must be >= 1ms",
 		},
+		{
+			"Both schema-registry-url and schema-registry-file",
+			invalidSchemaRegistryBothSources(),
+			"invalid configuration: only one of schema-registry-url and schema-registry-file can be specified",
+		},
+		{
+			"Negative savepoint-chunk-max-size-bytes",
+			invalidSavepointChunkMaxSizeBytes(),
+			"invalid configuration: savepoint-chunk-max-size-bytes must be > 0",
+		},
+		{
+			"Negative savepoint-call-timeout",
+			invalidSavepointCallTimeout(),
+			"invalid configuration: savepoint-call-timeout must be >= 1ms",
+		},
 	}
 	for _, tc := range tcs {
 		t.Run(tc.name, func(t *testing.T) {
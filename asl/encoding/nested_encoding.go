@@ -0,0 +1,276 @@
+package encoding
+
+import (
+	"fmt"
+	"github.com/spirit-labs/tektite/types"
+	"sort"
+)
+
+// AppendNestedToBufferLE encodes a value of an array, map or struct type. Each element is preceded by a null marker,
+// arrays and maps are preceded by their length, and map entries are written in key order so that equal maps always
+// have the same encoding.
+func AppendNestedToBufferLE(buffer []byte, colType types.ColumnType, val any) []byte {
+	switch t := colType.(type) {
+	case *types.ArrayType:
+		arr := val.([]any)
+		buffer = AppendUint32ToBufferLE(buffer, uint32(len(arr)))
+		for _, elem := range arr {
+			buffer = appendElementToBufferLE(buffer, t.ElementType, elem)
+		}
+	case *types.MapType:
+		m := val.(map[string]any)
+		buffer = AppendUint32ToBufferLE(buffer, uint32(len(m)))
+		for _, k := range sortedMapKeys(m) {
+			buffer = AppendStringToBufferLE(buffer, k)
+			buffer = appendElementToBufferLE(buffer, t.ValueType, m[k])
+		}
+	case *types.StructType:
+		fields := val.([]any)
+		for i, fieldType := range t.FieldTypes {
+			buffer = appendElementToBufferLE(buffer, fieldType, fields[i])
+		}
+	default:
+		panic(fmt.Sprintf("not a nested type %s", colType.String()))
+	}
+	return buffer
+}
+
+func appendElementToBufferLE(buffer []byte, colType types.ColumnType, val any) []byte {
+	if val == nil {
+		return append(buffer, 0)
+	}
+	buffer = append(buffer, 1)
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		buffer = AppendUint64ToBufferLE(buffer, uint64(val.(int64)))
+	case types.ColumnTypeIDFloat:
+		buffer = AppendFloat64ToBufferLE(buffer, val.(float64))
+	case types.ColumnTypeIDBool:
+		buffer = AppendBoolToBuffer(buffer, val.(bool))
+	case types.ColumnTypeIDDecimal:
+		buffer = AppendDecimalToBuffer(buffer, val.(types.Decimal))
+	case types.ColumnTypeIDString:
+		buffer = AppendStringToBufferLE(buffer, val.(string))
+	case types.ColumnTypeIDBytes:
+		buffer = AppendBytesToBufferLE(buffer, val.([]byte))
+	case types.ColumnTypeIDTimestamp:
+		buffer = AppendUint64ToBufferLE(buffer, uint64(val.(types.Timestamp).Val))
+	default:
+		buffer = AppendNestedToBufferLE(buffer, colType, val)
+	}
+	return buffer
+}
+
+// ReadNestedFromBufferLE decodes a value encoded with AppendNestedToBufferLE.
+func ReadNestedFromBufferLE(buffer []byte, offset int, colType types.ColumnType) (any, int) {
+	switch t := colType.(type) {
+	case *types.ArrayType:
+		var l uint32
+		l, offset = ReadUint32FromBufferLE(buffer, offset)
+		arr := make([]any, l)
+		for i := range arr {
+			arr[i], offset = readElementFromBufferLE(buffer, offset, t.ElementType)
+		}
+		return arr, offset
+	case *types.MapType:
+		var l uint32
+		l, offset = ReadUint32FromBufferLE(buffer, offset)
+		m := make(map[string]any, l)
+		for i := 0; i < int(l); i++ {
+			var k string
+			k, offset = ReadStringFromBufferLE(buffer, offset)
+			m[k], offset = readElementFromBufferLE(buffer, offset, t.ValueType)
+		}
+		return m, offset
+	case *types.StructType:
+		fields := make([]any, len(t.FieldTypes))
+		for i, fieldType := range t.FieldTypes {
+			fields[i], offset = readElementFromBufferLE(buffer, offset, fieldType)
+		}
+		return fields, offset
+	default:
+		panic(fmt.Sprintf("not a nested type %s", colType.String()))
+	}
+}
+
+func readElementFromBufferLE(buffer []byte, offset int, colType types.ColumnType) (any, int) {
+	if buffer[offset] == 0 {
+		return nil, offset + 1
+	}
+	offset++
+	var val any
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		var u uint64
+		u, offset = ReadUint64FromBufferLE(buffer, offset)
+		val = int64(u)
+	case types.ColumnTypeIDFloat:
+		val, offset = ReadFloat64FromBufferLE(buffer, offset)
+	case types.ColumnTypeIDBool:
+		val, offset = ReadBoolFromBuffer(buffer, offset)
+	case types.ColumnTypeIDDecimal:
+		decType := colType.(*types.DecimalType)
+		var dec types.Decimal
+		dec, offset = ReadDecimalFromBuffer(buffer, offset)
+		dec.Precision = decType.Precision
+		dec.Scale = decType.Scale
+		val = dec
+	case types.ColumnTypeIDString:
+		val, offset = ReadStringFromBufferLE(buffer, offset)
+	case types.ColumnTypeIDBytes:
+		val, offset = ReadBytesFromBufferLE(buffer, offset)
+	case types.ColumnTypeIDTimestamp:
+		var u uint64
+		u, offset = ReadUint64FromBufferLE(buffer, offset)
+		val = types.NewTimestamp(int64(u))
+	default:
+		val, offset = ReadNestedFromBufferLE(buffer, offset, colType)
+	}
+	return val, offset
+}
+
+/*
+KeyEncodeNested
+Nested values are encoded so that they can be compared byte-wise. Each array element or map entry is preceded by a
+continuation byte of 1 and the sequence is terminated by a 0, so a sequence which is a prefix of another sorts first.
+Map entries are written in key order. Elements have the same null marker as key columns.
+*/
+func KeyEncodeNested(buffer []byte, colType types.ColumnType, val any) []byte {
+	switch t := colType.(type) {
+	case *types.ArrayType:
+		for _, elem := range val.([]any) {
+			buffer = append(buffer, 1)
+			buffer = keyEncodeElement(buffer, t.ElementType, elem)
+		}
+		buffer = append(buffer, 0)
+	case *types.MapType:
+		m := val.(map[string]any)
+		for _, k := range sortedMapKeys(m) {
+			buffer = append(buffer, 1)
+			buffer = KeyEncodeString(buffer, k)
+			buffer = keyEncodeElement(buffer, t.ValueType, m[k])
+		}
+		buffer = append(buffer, 0)
+	case *types.StructType:
+		fields := val.([]any)
+		for i, fieldType := range t.FieldTypes {
+			buffer = keyEncodeElement(buffer, fieldType, fields[i])
+		}
+	default:
+		panic(fmt.Sprintf("not a nested type %s", colType.String()))
+	}
+	return buffer
+}
+
+func keyEncodeElement(buffer []byte, colType types.ColumnType, val any) []byte {
+	if val == nil {
+		return append(buffer, 0)
+	}
+	buffer = append(buffer, 1)
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		buffer = KeyEncodeInt(buffer, val.(int64))
+	case types.ColumnTypeIDFloat:
+		buffer = KeyEncodeFloat(buffer, val.(float64))
+	case types.ColumnTypeIDBool:
+		buffer = AppendBoolToBuffer(buffer, val.(bool))
+	case types.ColumnTypeIDDecimal:
+		buffer = KeyEncodeDecimal(buffer, val.(types.Decimal))
+	case types.ColumnTypeIDString:
+		buffer = KeyEncodeString(buffer, val.(string))
+	case types.ColumnTypeIDBytes:
+		buffer = KeyEncodeBytes(buffer, val.([]byte))
+	case types.ColumnTypeIDTimestamp:
+		buffer = KeyEncodeTimestamp(buffer, val.(types.Timestamp))
+	default:
+		buffer = KeyEncodeNested(buffer, colType, val)
+	}
+	return buffer
+}
+
+func KeyDecodeNested(buffer []byte, offset int, colType types.ColumnType) (any, int, error) {
+	var err error
+	switch t := colType.(type) {
+	case *types.ArrayType:
+		arr := []any{}
+		for buffer[offset] == 1 {
+			var elem any
+			elem, offset, err = keyDecodeElement(buffer, offset+1, t.ElementType)
+			if err != nil {
+				return nil, 0, err
+			}
+			arr = append(arr, elem)
+		}
+		return arr, offset + 1, nil
+	case *types.MapType:
+		m := map[string]any{}
+		for buffer[offset] == 1 {
+			var k string
+			k, offset, err = KeyDecodeString(buffer, offset+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k], offset, err = keyDecodeElement(buffer, offset, t.ValueType)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset + 1, nil
+	case *types.StructType:
+		fields := make([]any, len(t.FieldTypes))
+		for i, fieldType := range t.FieldTypes {
+			fields[i], offset, err = keyDecodeElement(buffer, offset, fieldType)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return fields, offset, nil
+	default:
+		panic(fmt.Sprintf("not a nested type %s", colType.String()))
+	}
+}
+
+func keyDecodeElement(buffer []byte, offset int, colType types.ColumnType) (any, int, error) {
+	if buffer[offset] == 0 {
+		return nil, offset + 1, nil
+	}
+	offset++
+	var val any
+	var err error
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		val, offset = KeyDecodeInt(buffer, offset)
+	case types.ColumnTypeIDFloat:
+		val, offset = KeyDecodeFloat(buffer, offset)
+	case types.ColumnTypeIDBool:
+		val, offset = DecodeBool(buffer, offset)
+	case types.ColumnTypeIDDecimal:
+		decType := colType.(*types.DecimalType)
+		var dec types.Decimal
+		dec, offset = KeyDecodeDecimal(buffer, offset)
+		dec.Precision = decType.Precision
+		dec.Scale = decType.Scale
+		val = dec
+	case types.ColumnTypeIDString:
+		val, offset, err = KeyDecodeString(buffer, offset)
+	case types.ColumnTypeIDBytes:
+		val, offset, err = KeyDecodeBytes(buffer, offset)
+	case types.ColumnTypeIDTimestamp:
+		val, offset = KeyDecodeTimestamp(buffer, offset)
+	default:
+		val, offset, err = KeyDecodeNested(buffer, offset, colType)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return val, offset, nil
+}
+
+func sortedMapKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,81 @@
+package encoding
+
+import (
+	"github.com/apache/arrow/go/v11/arrow/decimal128"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func nestedTestType(t *testing.T) types.ColumnType {
+	colType, err := types.StringToColumnType(
+		"struct<id:int,tags:array<string>,attrs:map<string,float>,price:decimal(10,2),raw:bytes,ts:timestamp,ok:bool>")
+	require.NoError(t, err)
+	return colType
+}
+
+func nestedTestValue() any {
+	return []any{
+		int64(23),
+		[]any{"a", nil, "bc"},
+		map[string]any{"x": 1.5, "y": nil},
+		types.Decimal{Num: decimal128.FromI64(12345), Precision: 10, Scale: 2},
+		[]byte("raw"),
+		types.NewTimestamp(1000),
+		true,
+	}
+}
+
+func TestNestedRowEncodeDecode(t *testing.T) {
+	colType := nestedTestType(t)
+	val := nestedTestValue()
+	buff := AppendNestedToBufferLE([]byte("prefix"), colType, val)
+	res, off := ReadNestedFromBufferLE(buff, 6, colType)
+	require.Equal(t, len(buff), off)
+	require.Equal(t, val, res)
+}
+
+func TestNestedKeyEncodeDecode(t *testing.T) {
+	colType := nestedTestType(t)
+	val := nestedTestValue()
+	buff := KeyEncodeNested([]byte("prefix"), colType, val)
+	res, off, err := KeyDecodeNested(buff, 6, colType)
+	require.NoError(t, err)
+	require.Equal(t, len(buff), off)
+	require.Equal(t, val, res)
+}
+
+func TestNestedKeyEncodeEmpty(t *testing.T) {
+	colType := &types.ArrayType{ElementType: types.ColumnTypeInt}
+	buff := KeyEncodeNested(nil, colType, []any{})
+	res, off, err := KeyDecodeNested(buff, 0, colType)
+	require.NoError(t, err)
+	require.Equal(t, len(buff), off)
+	require.Equal(t, []any{}, res)
+}
+
+func TestKeyEncodeArrayOrdering(t *testing.T) {
+	colType := &types.ArrayType{ElementType: types.ColumnTypeInt}
+	vals := [][]any{
+		{},
+		{nil},
+		{int64(-1)},
+		{int64(1)},
+		{int64(1), int64(2)},
+		{int64(1), int64(3)},
+		{int64(2)},
+	}
+	for i := 0; i < len(vals)-1; i++ {
+		checkLessThan(t, KeyEncodeNested(nil, colType, vals[i]), KeyEncodeNested(nil, colType, vals[i+1]))
+	}
+}
+
+func TestNestedEncodingMapKeyOrder(t *testing.T) {
+	colType := &types.MapType{ValueType: types.ColumnTypeString}
+	m := map[string]any{"c": "3", "a": "1", "b": "2"}
+	// Encoding must be deterministic whatever the map iteration order
+	for i := 0; i < 10; i++ {
+		require.Equal(t, AppendNestedToBufferLE(nil, colType, m), AppendNestedToBufferLE(nil, colType, m))
+		require.Equal(t, KeyEncodeNested(nil, colType, m), KeyEncodeNested(nil, colType, m))
+	}
+}
@@ -37,6 +37,8 @@ func DecodeRowToSlice(buffer []byte, offset int, columnTypes []types.ColumnType)
 				var u uint64
 				u, offset = ReadUint64FromBufferLE(buffer, offset)
 				val = types.NewTimestamp(int64(u))
+			case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+				val, offset = ReadNestedFromBufferLE(buffer, offset, colType)
 			default:
 				panic(fmt.Sprintf("unexpected column type %d", colType))
 			}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spirit-labs/tektite/common"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/opers"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type StreamStatsProvider interface {
+	GetAllStreamStats() []*opers.StreamStats
+}
+
+// Server serves the runtime metrics of the streams deployed on this node in the Prometheus text format
+type Server struct {
+	address       string
+	statsProvider StreamStatsProvider
+	httpServer    *http.Server
+	listener      net.Listener
+	closeWg       sync.WaitGroup
+}
+
+func NewServer(address string, statsProvider StreamStatsProvider) *Server {
+	return &Server{
+		address:       address,
+		statsProvider: statsProvider,
+	}
+}
+
+func (s *Server) Start() error {
+	s.httpServer = &http.Server{
+		IdleTimeout: 0,
+	}
+	mux := http.NewServeMux()
+	s.httpServer.Handler = mux
+	mux.HandleFunc("/metrics", s.ServeMetrics)
+	var err error
+	s.listener, err = common.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	s.closeWg = sync.WaitGroup{}
+	s.closeWg.Add(1)
+	common.Go(func() {
+		defer s.closeWg.Done()
+		err := s.httpServer.Serve(s.listener)
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("failed to start the metrics server: %v", err)
+		}
+	})
+	return nil
+}
+
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	s.closeWg.Wait()
+	return nil
+}
+
+func (s *Server) ServeMetrics(response http.ResponseWriter, _ *http.Request) {
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := WriteMetrics(response, s.statsProvider.GetAllStreamStats()); err != nil {
+		log.Errorf("failed to write metrics response: %v", err)
+	}
+}
+
+type metricDef struct {
+	name       string
+	help       string
+	metricType string
+	value      func(op *opers.OperatorStats) (string, bool)
+}
+
+var operatorMetricDefs = []metricDef{
+	{"tektite_operator_rows_in_total", "Rows received by the operator.", "counter",
+		func(op *opers.OperatorStats) (string, bool) { return formatUint(op.RowsIn), true }},
+	{"tektite_operator_rows_out_total", "Rows sent downstream by the operator.", "counter",
+		func(op *opers.OperatorStats) (string, bool) { return formatUint(op.RowsOut), true }},
+	{"tektite_operator_batches_total", "Batches received by the operator.", "counter",
+		func(op *opers.OperatorStats) (string, bool) { return formatUint(op.Batches), true }},
+	{"tektite_operator_processing_seconds_total",
+		"Time spent processing batches in the operator, not including downstream operators.", "counter",
+		func(op *opers.OperatorStats) (string, bool) { return formatSeconds(op.ProcessingTime), true }},
+	{"tektite_operator_state_reads_total", "State reads made by the operator.", "counter",
+		func(op *opers.OperatorStats) (string, bool) { return formatUint(op.StateReads), true }},
+	{"tektite_operator_state_writes_total", "State writes made by the operator.", "counter",
+		func(op *opers.OperatorStats) (string, bool) { return formatUint(op.StateWrites), true }},
+	{"tektite_operator_watermark_lag_seconds", "How far the last watermark generated by the operator is behind the current time.", "gauge",
+		func(op *opers.OperatorStats) (string, bool) {
+			return formatSeconds(op.WaterMarkLag), op.WaterMarkLag != -1
+		}},
+}
+
+// WriteMetrics writes the stats in the Prometheus text exposition format
+func WriteMetrics(w io.Writer, allStats []*opers.StreamStats) error {
+	var sb strings.Builder
+	for _, def := range operatorMetricDefs {
+		writeMetricHeader(&sb, def.name, def.help, def.metricType)
+		for _, stats := range allStats {
+			for _, op := range stats.Operators {
+				val, ok := def.value(op)
+				if !ok {
+					continue
+				}
+				sb.WriteString(fmt.Sprintf("%s{stream=\"%s\",operator_id=\"%d\",operator=\"%s\"} %s\n", def.name,
+					escapeLabelValue(stats.StreamName), op.ID, escapeLabelValue(op.Operator), val))
+			}
+		}
+	}
+	writeMetricHeader(&sb, "tektite_operator_error_rows_total",
+		"Rows the operator failed to process, by the error policy of the stream.", "counter")
+	for _, stats := range allStats {
+		for _, op := range stats.Operators {
+			for _, policyRows := range []struct {
+				policy string
+				rows   uint64
+			}{{"fail", op.FailedRows}, {"skip", op.SkippedRows}, {"dead_letter", op.DeadLetteredRows}} {
+				if policyRows.rows == 0 {
+					continue
+				}
+				sb.WriteString(fmt.Sprintf("tektite_operator_error_rows_total{stream=\"%s\",operator_id=\"%d\",operator=\"%s\",policy=\"%s\"} %d\n",
+					escapeLabelValue(stats.StreamName), op.ID, escapeLabelValue(op.Operator), policyRows.policy,
+					policyRows.rows))
+			}
+		}
+	}
+	writeMetricHeader(&sb, "tektite_stream_backlog", "Messages, or for kafka in produced batches, not yet processed by the source of the stream.", "gauge")
+	for _, stats := range allStats {
+		if stats.Backlog == -1 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("tektite_stream_backlog{stream=\"%s\"} %d\n", escapeLabelValue(stats.StreamName),
+			stats.Backlog))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeMetricHeader(sb *strings.Builder, name string, help string, metricType string) {
+	sb.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType))
+}
+
+func formatUint(val uint64) string {
+	return strconv.FormatUint(val, 10)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
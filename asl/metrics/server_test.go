@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/spirit-labs/tektite/opers"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createTestStats() []*opers.StreamStats {
+	return []*opers.StreamStats{
+		{
+			StreamName: "stream1",
+			Backlog:    23,
+			Operators: []*opers.OperatorStats{
+				{ID: 0, Operator: "kafka in", RowsIn: 100, RowsOut: 100, Batches: 10,
+					ProcessingTime: 1500 * time.Millisecond, WaterMarkLag: 2 * time.Second, SkippedRows: 4},
+				{ID: 1, Operator: "store stream", RowsIn: 100, RowsOut: 100, Batches: 10,
+					ProcessingTime: 250 * time.Millisecond, StateReads: 3, StateWrites: 100, WaterMarkLag: -1},
+			},
+		},
+		{
+			StreamName: `stream"2`,
+			Backlog:    -1,
+			Operators: []*opers.OperatorStats{
+				{ID: 0, Operator: "continuation", WaterMarkLag: -1},
+			},
+		},
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var sb strings.Builder
+	err := WriteMetrics(&sb, createTestStats())
+	require.NoError(t, err)
+	expected := `# HELP tektite_operator_rows_in_total Rows received by the operator.
+# TYPE tektite_operator_rows_in_total counter
+tektite_operator_rows_in_total{stream="stream1",operator_id="0",operator="kafka in"} 100
+tektite_operator_rows_in_total{stream="stream1",operator_id="1",operator="store stream"} 100
+tektite_operator_rows_in_total{stream="stream\"2",operator_id="0",operator="continuation"} 0
+# HELP tektite_operator_rows_out_total Rows sent downstream by the operator.
+# TYPE tektite_operator_rows_out_total counter
+tektite_operator_rows_out_total{stream="stream1",operator_id="0",operator="kafka in"} 100
+tektite_operator_rows_out_total{stream="stream1",operator_id="1",operator="store stream"} 100
+tektite_operator_rows_out_total{stream="stream\"2",operator_id="0",operator="continuation"} 0
+# HELP tektite_operator_batches_total Batches received by the operator.
+# TYPE tektite_operator_batches_total counter
+tektite_operator_batches_total{stream="stream1",operator_id="0",operator="kafka in"} 10
+tektite_operator_batches_total{stream="stream1",operator_id="1",operator="store stream"} 10
+tektite_operator_batches_total{stream="stream\"2",operator_id="0",operator="continuation"} 0
+# HELP tektite_operator_processing_seconds_total Time spent processing batches in the operator, not including downstream operators.
+# TYPE tektite_operator_processing_seconds_total counter
+tektite_operator_processing_seconds_total{stream="stream1",operator_id="0",operator="kafka in"} 1.5
+tektite_operator_processing_seconds_total{stream="stream1",operator_id="1",operator="store stream"} 0.25
+tektite_operator_processing_seconds_total{stream="stream\"2",operator_id="0",operator="continuation"} 0
+# HELP tektite_operator_state_reads_total State reads made by the operator.
+# TYPE tektite_operator_state_reads_total counter
+tektite_operator_state_reads_total{stream="stream1",operator_id="0",operator="kafka in"} 0
+tektite_operator_state_reads_total{stream="stream1",operator_id="1",operator="store stream"} 3
+tektite_operator_state_reads_total{stream="stream\"2",operator_id="0",operator="continuation"} 0
+# HELP tektite_operator_state_writes_total State writes made by the operator.
+# TYPE tektite_operator_state_writes_total counter
+tektite_operator_state_writes_total{stream="stream1",operator_id="0",operator="kafka in"} 0
+tektite_operator_state_writes_total{stream="stream1",operator_id="1",operator="store stream"} 100
+tektite_operator_state_writes_total{stream="stream\"2",operator_id="0",operator="continuation"} 0
+# HELP tektite_operator_watermark_lag_seconds How far the last watermark generated by the operator is behind the current time.
+# TYPE tektite_operator_watermark_lag_seconds gauge
+tektite_operator_watermark_lag_seconds{stream="stream1",operator_id="0",operator="kafka in"} 2
+# HELP tektite_operator_error_rows_total Rows the operator failed to process, by the error policy of the stream.
+# TYPE tektite_operator_error_rows_total counter
+tektite_operator_error_rows_total{stream="stream1",operator_id="0",operator="kafka in",policy="skip"} 4
+# HELP tektite_stream_backlog Messages, or for kafka in produced batches, not yet processed by the source of the stream.
+# TYPE tektite_stream_backlog gauge
+tektite_stream_backlog{stream="stream1"} 23
+`
+	require.Equal(t, expected, sb.String())
+}
+
+type testStatsProvider struct {
+	stats []*opers.StreamStats
+}
+
+func (t *testStatsProvider) GetAllStreamStats() []*opers.StreamStats {
+	return t.stats
+}
+
+func TestServer(t *testing.T) {
+	address := "localhost:7991"
+	server := NewServer(address, &testStatsProvider{stats: createTestStats()})
+	err := server.Start()
+	require.NoError(t, err)
+	defer func() {
+		err := server.Stop()
+		require.NoError(t, err)
+	}()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", address))
+	require.NoError(t, err)
+	defer func() {
+		err := resp.Body.Close()
+		require.NoError(t, err)
+	}()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/plain; version=0.0.4", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var sb strings.Builder
+	err = WriteMetrics(&sb, createTestStats())
+	require.NoError(t, err)
+	require.Equal(t, sb.String(), string(body))
+}
@@ -63,6 +63,10 @@ const (
 	ClusterMessageShutdownMessage
 	ClusterMessageShutdownResponse
 	ClusterMessageRemotingTestMessage
+	ClusterMessageGetVersionForTimeMessage
+	ClusterMessageGetVersionForTimeResponse
+	ClusterMessageLevelManagerGetOldestRetainedVersionMessage
+	ClusterMessageLevelManagerGetOldestRetainedVersionResponse
 )
 
 func TypeForClusterMessage(clusterMessage ClusterMessage) ClusterMessageType {
@@ -81,6 +85,10 @@ func TypeForClusterMessage(clusterMessage ClusterMessage) ClusterMessageType {
 		return ClusterMessageVersionsMessage
 	case *clustermsgs.GetCurrentVersionMessage:
 		return ClusterMessageGetVersionMessage
+	case *clustermsgs.GetVersionForTimeMessage:
+		return ClusterMessageGetVersionForTimeMessage
+	case *clustermsgs.GetVersionForTimeResponse:
+		return ClusterMessageGetVersionForTimeResponse
 	case *clustermsgs.VersionCompleteMessage:
 		return ClusterMessageVersionCompleteMessage
 	case *clustermsgs.VersionFlushedMessage:
@@ -127,6 +135,10 @@ func TypeForClusterMessage(clusterMessage ClusterMessage) ClusterMessageType {
 		return ClusterMessageLevelManagerLoadLastFlushedVersionResponse
 	case *clustermsgs.LevelManagerStoreLastFlushedVersionMessage:
 		return ClusterMessageLevelManagerStoreLastFlushedVersionMessage
+	case *clustermsgs.LevelManagerGetOldestRetainedVersionMessage:
+		return ClusterMessageLevelManagerGetOldestRetainedVersionMessage
+	case *clustermsgs.LevelManagerGetOldestRetainedVersionResponse:
+		return ClusterMessageLevelManagerGetOldestRetainedVersionResponse
 	case *clustermsgs.LevelManagerGetStatsMessage:
 		return ClusterMessageLevelManagerGetStatsMessage
 	case *clustermsgs.LevelManagerGetStatsResponse:
@@ -232,6 +244,10 @@ func DeserializeClusterMessage(data []byte) (ClusterMessage, error) {
 		msg = &clustermsgs.VersionsMessage{}
 	case ClusterMessageGetVersionMessage:
 		msg = &clustermsgs.GetCurrentVersionMessage{}
+	case ClusterMessageGetVersionForTimeMessage:
+		msg = &clustermsgs.GetVersionForTimeMessage{}
+	case ClusterMessageGetVersionForTimeResponse:
+		msg = &clustermsgs.GetVersionForTimeResponse{}
 	case ClusterMessageVersionCompleteMessage:
 		msg = &clustermsgs.VersionCompleteMessage{}
 	case ClusterMessageVersionFlushedMessage:
@@ -278,6 +294,10 @@ func DeserializeClusterMessage(data []byte) (ClusterMessage, error) {
 		msg = &clustermsgs.LevelManagerLoadLastFlushedVersionResponse{}
 	case ClusterMessageLevelManagerStoreLastFlushedVersionMessage:
 		msg = &clustermsgs.LevelManagerStoreLastFlushedVersionMessage{}
+	case ClusterMessageLevelManagerGetOldestRetainedVersionMessage:
+		msg = &clustermsgs.LevelManagerGetOldestRetainedVersionMessage{}
+	case ClusterMessageLevelManagerGetOldestRetainedVersionResponse:
+		msg = &clustermsgs.LevelManagerGetOldestRetainedVersionResponse{}
 	case ClusterMessageLevelManagerGetStatsMessage:
 		msg = &clustermsgs.LevelManagerGetStatsMessage{}
 	case ClusterMessageLevelManagerGetStatsResponse:
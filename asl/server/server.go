@@ -6,6 +6,7 @@ import (
 	"github.com/spirit-labs/tektite/asl/api"
 	"github.com/spirit-labs/tektite/asl/conf"
 	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/asl/metrics"
 	"github.com/spirit-labs/tektite/asl/remoting"
 	"github.com/spirit-labs/tektite/auth"
 	"github.com/spirit-labs/tektite/clustmgr"
@@ -18,6 +19,7 @@ import (
 	"github.com/spirit-labs/tektite/lock"
 	"github.com/spirit-labs/tektite/objstore"
 	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/spirit-labs/tektite/objstore/fs"
 	"github.com/spirit-labs/tektite/objstore/minio"
 	"github.com/spirit-labs/tektite/opers"
 	"github.com/spirit-labs/tektite/parser"
@@ -78,6 +80,8 @@ func NewServerWithClientFactory(config conf.Config, clientFactory kafka.ClientFa
 			Password: config.MinioPassword,
 		}
 		objStoreClient = minio.NewMinioClient(minioConf)
+	case conf.FsObjectStoreType:
+		objStoreClient = fs.NewFsClient(config.FsObjectStoreDir)
 	default:
 		return nil, common.NewTektiteErrorf(common.InvalidConfiguration, "invalid object store type: %s", config.ObjectStoreType)
 	}
@@ -138,7 +142,7 @@ func NewServerWithArgs(config conf.Config, clientFactory kafka.ClientFactory, ob
 
 	theParser := parser.NewParser(&wasmFunctionChecker{moduleManager})
 
-	streamManager := opers.NewStreamManager(clientFactory, levMgrClient, exprFactory, &config, false)
+	streamManager := opers.NewStreamManager(clientFactory, objStoreClient, levMgrClient, exprFactory, &config, false)
 
 	handlerFactory := &batchHandlerFactory{
 		cfg:           &config,
@@ -156,7 +160,7 @@ func NewServerWithArgs(config conf.Config, clientFactory kafka.ClientFactory, ob
 	streamManager.SetProcessorManager(processorManager)
 
 	queryManager := query.NewManager(processorManager, processorManager, config.NodeID, streamManager,
-		streamManager.StreamMetaIteratorProvider(), processorManager, query.NewDefaultRemoting(&config), config.ClusterAddresses,
+		streamManager.StreamMetaIteratorProvider(), processorManager, vmgrClient, query.NewDefaultRemoting(&config), config.ClusterAddresses,
 		config.QueryMaxBatchRows, exprFactory, theParser)
 
 	levelManagerService := levels.NewLevelManagerService(processorManager, &config, objStoreClient, tableCache,
@@ -216,6 +220,11 @@ func NewServerWithArgs(config conf.Config, clientFactory kafka.ClientFactory, ob
 		}
 	}
 
+	var metricsServer *metrics.Server
+	if config.MetricsEnabled {
+		metricsServer = metrics.NewServer(config.MetricsBind, streamManager)
+	}
+
 	// Other wiring
 	if localLevMgrClientFactory != nil {
 		localLevMgrClientFactory.procMgr = processorManager
@@ -253,6 +262,7 @@ func NewServerWithArgs(config conf.Config, clientFactory kafka.ClientFactory, ob
 		kafkaServer,
 		compactionService,
 		adminServer,
+		metricsServer,
 	}
 
 	s := &Server{
@@ -0,0 +1,70 @@
+package cdc
+
+import (
+	"fmt"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"time"
+)
+
+// ClientFactory creates a Client which streams the changes published by a Postgres publication, using the given
+// logical replication slot
+type ClientFactory func(connection string, slotName string, publication string) (Client, error)
+
+type Client interface {
+	// NewChangeProvider creates a ChangeProvider which streams the transactions that committed after startLSN. If
+	// startLSN is zero streaming starts from the position confirmed for the slot.
+	NewChangeProvider(startLSN LSN) (ChangeProvider, error)
+}
+
+type ChangeProvider interface {
+	// GetTransaction returns the next committed transaction, or nil if none arrives within pollTimeout
+	GetTransaction(pollTimeout time.Duration) (*Transaction, error)
+	// ConfirmFlushed tells the source that all transactions which ended at or before lsn have been durably processed
+	// and will never be requested again
+	ConfirmFlushed(lsn LSN) error
+	Start() error
+	Stop() error
+}
+
+const (
+	OpInsert = "insert"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// LSN is a position in the Postgres write-ahead log
+type LSN uint64
+
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+func ParseLSN(s string) (LSN, error) {
+	var hi, lo uint32
+	if _, err := fmt.Sscanf(s, "%X/%X", &hi, &lo); err != nil {
+		return 0, errwrap.Errorf("invalid LSN '%s'", s)
+	}
+	return LSN(uint64(hi)<<32 | uint64(lo)), nil
+}
+
+type Transaction struct {
+	XID uint32
+	// EndLSN is the position just after the commit record of the transaction. Streaming from it resumes with the
+	// next transaction.
+	EndLSN     LSN
+	CommitTime time.Time
+	Changes    []Change
+}
+
+type Change struct {
+	LSN    LSN
+	Op     string
+	Schema string
+	Table  string
+	// Before holds the old values of an update or delete. It is nil when the replica identity of the table does not
+	// provide them.
+	Before map[string]any
+	// After holds the new values of an insert or update. Values are in Postgres text format, or nil for SQL NULL.
+	// Unchanged TOASTed values of an update are not sent by Postgres so are absent.
+	After map[string]any
+}
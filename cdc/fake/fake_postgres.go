@@ -0,0 +1,613 @@
+package fake
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/common"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/xdg-go/scram"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthTrust       = "trust"
+	AuthPassword    = "password"
+	AuthMD5         = "md5"
+	AuthScramSHA256 = "scram-sha-256"
+
+	protocolVersion3 = 196608
+	sslRequestCode   = 80877103
+	firstLSN         = 0x16B3748
+	textTypeOID      = 25
+)
+
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var startReplicationRegex = regexp.MustCompile(
+	`^START_REPLICATION SLOT "((?:[^"]|"")+)" LOGICAL ([0-9A-F]+)/([0-9A-F]+) \(proto_version '1', publication_names '((?:[^']|'')+)'\)$`)
+
+// Postgres is a test double of a Postgres server. It supports just enough of the protocol for a client to
+// authenticate and stream changes from a logical replication slot using the pgoutput plugin. Transactions are
+// committed directly to the fake WAL with Commit.
+type Postgres struct {
+	lock         sync.Mutex
+	listener     net.Listener
+	user         string
+	password     string
+	authMethod   string
+	nextLSN      uint64
+	nextXID      uint32
+	transactions []*transaction
+	slots        map[string]*slot
+	relationIDs  map[string]uint32
+	commitChan   chan struct{}
+	conns        map[net.Conn]struct{}
+	connsWg      sync.WaitGroup
+	// nextPID is used to identify the connection which has a slot active
+	nextPID int
+}
+
+// Change is a row change in a transaction. Values are strings in Postgres text format, or nil for NULL.
+type Change struct {
+	Op      string
+	Schema  string
+	Table   string
+	Columns []string
+	Before  []any
+	After   []any
+}
+
+type transaction struct {
+	xid        uint32
+	beginLSN   uint64
+	changeLSNs []uint64
+	endLSN     uint64
+	commitTime time.Time
+	changes    []Change
+}
+
+type slot struct {
+	confirmedFlushLSN uint64
+	activePID         int
+}
+
+func NewPostgres(user string, password string, authMethod string) (*Postgres, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	p := &Postgres{
+		listener:    listener,
+		user:        user,
+		password:    password,
+		authMethod:  authMethod,
+		nextLSN:     firstLSN,
+		nextXID:     700,
+		slots:       map[string]*slot{},
+		relationIDs: map[string]uint32{},
+		commitChan:  make(chan struct{}),
+		conns:       map[net.Conn]struct{}{},
+	}
+	p.connsWg.Add(1)
+	common.Go(p.acceptLoop)
+	return p, nil
+}
+
+// ConnectionURL returns the URL used to connect to the server, with the given user and password
+func (p *Postgres) ConnectionURL(user string, password string) string {
+	return fmt.Sprintf("postgres://%s:%s@%s/testdb?sslmode=prefer", user, password, p.listener.Addr().String())
+}
+
+func (p *Postgres) CreateSlot(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// A new slot only sees transactions which commit after it is created
+	p.slots[name] = &slot{confirmedFlushLSN: p.nextLSN}
+}
+
+// ConfirmedFlushLSN returns the last position confirmed as flushed by a client of the slot
+func (p *Postgres) ConfirmedFlushLSN(slotName string) uint64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.slots[slotName].confirmedFlushLSN
+}
+
+// SlotActive returns whether a client is currently streaming from the slot
+func (p *Postgres) SlotActive(slotName string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.slots[slotName].activePID != 0
+}
+
+// Commit appends a transaction containing the changes to the WAL and returns the LSN of its end
+func (p *Postgres) Commit(commitTime time.Time, changes ...Change) uint64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	txn := &transaction{
+		xid:        p.nextXID,
+		beginLSN:   p.nextLSN,
+		commitTime: commitTime,
+		changes:    changes,
+	}
+	p.nextXID++
+	lsn := p.nextLSN
+	for range changes {
+		lsn += 0x40
+		txn.changeLSNs = append(txn.changeLSNs, lsn)
+	}
+	txn.endLSN = lsn + 0x30
+	p.nextLSN = txn.endLSN + 0x8
+	p.transactions = append(p.transactions, txn)
+	// wake up streaming connections
+	close(p.commitChan)
+	p.commitChan = make(chan struct{})
+	return txn.endLSN
+}
+
+func (p *Postgres) Close() {
+	if err := p.listener.Close(); err != nil {
+		log.Debugf("failed to close listener %v", err)
+	}
+	p.lock.Lock()
+	for conn := range p.conns {
+		if err := conn.Close(); err != nil {
+			log.Debugf("failed to close connection %v", err)
+		}
+	}
+	p.lock.Unlock()
+	p.connsWg.Wait()
+}
+
+func (p *Postgres) acceptLoop() {
+	defer p.connsWg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			// listener closed
+			return
+		}
+		p.lock.Lock()
+		p.conns[conn] = struct{}{}
+		p.lock.Unlock()
+		p.connsWg.Add(1)
+		common.Go(func() {
+			defer p.connsWg.Done()
+			sc := &serverConn{p: p, conn: conn, reader: bufio.NewReader(conn), sentRelations: map[uint32]string{}}
+			if err := sc.handle(); err != nil {
+				log.Debugf("fake postgres connection closed: %v", err)
+			}
+			p.lock.Lock()
+			delete(p.conns, conn)
+			p.lock.Unlock()
+			if err := conn.Close(); err != nil {
+				log.Debugf("failed to close connection %v", err)
+			}
+		})
+	}
+}
+
+type serverConn struct {
+	p             *Postgres
+	conn          net.Conn
+	reader        *bufio.Reader
+	writeLock     sync.Mutex
+	sentRelations map[uint32]string
+}
+
+func (s *serverConn) handle() error {
+	params, err := s.readStartup()
+	if err != nil {
+		return err
+	}
+	if params["user"] != s.p.user {
+		return s.sendError("FATAL", "28P01", fmt.Sprintf(`password authentication failed for user "%s"`,
+			params["user"]))
+	}
+	if params["replication"] != "database" {
+		return s.sendError("FATAL", "08P01", "only logical replication connections are supported")
+	}
+	if ok, err := s.authenticate(); err != nil || !ok {
+		return err
+	}
+	if err := s.writeMessage('R', binary.BigEndian.AppendUint32(nil, 0)); err != nil {
+		return err
+	}
+	if err := s.writeMessage('S', append(appendCString(nil, "server_version"), appendCString(nil, "16.0")...)); err != nil {
+		return err
+	}
+	if err := s.writeMessage('Z', []byte{'I'}); err != nil {
+		return err
+	}
+	for {
+		msgType, body, err := s.readMessage()
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case 'Q':
+			query := strings.TrimRight(string(body), "\x00")
+			if err := s.startReplication(query); err != nil {
+				return err
+			}
+		case 'X':
+			return nil
+		default:
+			return s.sendError("FATAL", "08P01", fmt.Sprintf("unexpected message type '%c'", msgType))
+		}
+	}
+}
+
+func (s *serverConn) readStartup() (map[string]string, error) {
+	for {
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(s.reader, lenBuf[:]); err != nil {
+			return nil, err
+		}
+		body := make([]byte, binary.BigEndian.Uint32(lenBuf[:])-4)
+		if _, err := io.ReadFull(s.reader, body); err != nil {
+			return nil, err
+		}
+		code := binary.BigEndian.Uint32(body)
+		if code == sslRequestCode {
+			// SSL is not supported
+			if _, err := s.conn.Write([]byte{'N'}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if code != protocolVersion3 {
+			return nil, errwrap.Errorf("unsupported protocol version %d", code)
+		}
+		params := map[string]string{}
+		parts := strings.Split(string(body[4:]), "\x00")
+		for i := 0; i+1 < len(parts); i += 2 {
+			if parts[i] == "" {
+				break
+			}
+			params[parts[i]] = parts[i+1]
+		}
+		return params, nil
+	}
+}
+
+func (s *serverConn) authenticate() (bool, error) {
+	switch s.p.authMethod {
+	case AuthTrust:
+		return true, nil
+	case AuthPassword:
+		if err := s.writeMessage('R', binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+			return false, err
+		}
+		return s.checkPassword(s.p.password)
+	case AuthMD5:
+		salt := []byte{1, 2, 3, 4}
+		msg := binary.BigEndian.AppendUint32(nil, 5)
+		if err := s.writeMessage('R', append(msg, salt...)); err != nil {
+			return false, err
+		}
+		inner := md5.Sum([]byte(s.p.password + s.p.user))
+		outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+		return s.checkPassword("md5" + hex.EncodeToString(outer[:]))
+	case AuthScramSHA256:
+		return s.authenticateScram()
+	default:
+		return false, errwrap.Errorf("unknown auth method %s", s.p.authMethod)
+	}
+}
+
+func (s *serverConn) checkPassword(expected string) (bool, error) {
+	msgType, body, err := s.readMessage()
+	if err != nil {
+		return false, err
+	}
+	if msgType != 'p' || strings.TrimRight(string(body), "\x00") != expected {
+		return false, s.authFailed()
+	}
+	return true, nil
+}
+
+func (s *serverConn) authenticateScram() (bool, error) {
+	client, err := scram.SHA256.NewClient(s.p.user, s.p.password, "")
+	if err != nil {
+		return false, err
+	}
+	creds := client.GetStoredCredentials(scram.KeyFactors{Salt: "fake-salt", Iters: 4096})
+	server, err := scram.SHA256.NewServer(func(string) (scram.StoredCredentials, error) {
+		return creds, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	conv := server.NewConversation()
+	msg := binary.BigEndian.AppendUint32(nil, 10)
+	msg = appendCString(msg, "SCRAM-SHA-256")
+	if err := s.writeMessage('R', append(msg, 0)); err != nil {
+		return false, err
+	}
+	// SASLInitialResponse
+	msgType, body, err := s.readMessage()
+	if err != nil {
+		return false, err
+	}
+	mechEnd := strings.IndexByte(string(body), 0)
+	if msgType != 'p' || mechEnd < 0 || string(body[:mechEnd]) != "SCRAM-SHA-256" || len(body) < mechEnd+5 {
+		return false, s.authFailed()
+	}
+	serverFirst, err := conv.Step(string(body[mechEnd+5:]))
+	if err != nil {
+		return false, s.authFailed()
+	}
+	if err := s.writeMessage('R', append(binary.BigEndian.AppendUint32(nil, 11), serverFirst...)); err != nil {
+		return false, err
+	}
+	// SASLResponse
+	msgType, body, err = s.readMessage()
+	if err != nil {
+		return false, err
+	}
+	if msgType != 'p' {
+		return false, s.authFailed()
+	}
+	serverFinal, err := conv.Step(string(body))
+	if err != nil || !conv.Valid() {
+		return false, s.authFailed()
+	}
+	if err := s.writeMessage('R', append(binary.BigEndian.AppendUint32(nil, 12), serverFinal...)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *serverConn) authFailed() error {
+	return s.sendError("FATAL", "28P01", fmt.Sprintf(`password authentication failed for user "%s"`, s.p.user))
+}
+
+func (s *serverConn) startReplication(query string) error {
+	matches := startReplicationRegex.FindStringSubmatch(query)
+	if matches == nil {
+		return s.sendError("ERROR", "42601", fmt.Sprintf("syntax error in replication command: %s", query))
+	}
+	slotName := strings.ReplaceAll(matches[1], `""`, `"`)
+	hi, _ := strconv.ParseUint(matches[2], 16, 32)
+	lo, _ := strconv.ParseUint(matches[3], 16, 32)
+	startLSN := hi<<32 | lo
+
+	s.p.lock.Lock()
+	sl, ok := s.p.slots[slotName]
+	if !ok {
+		s.p.lock.Unlock()
+		return s.sendError("ERROR", "42704", fmt.Sprintf(`replication slot "%s" does not exist`, slotName))
+	}
+	if sl.activePID != 0 {
+		s.p.lock.Unlock()
+		return s.sendError("ERROR", "55006", fmt.Sprintf(`replication slot "%s" is active for PID %d`, slotName,
+			sl.activePID))
+	}
+	s.p.nextPID++
+	pid := s.p.nextPID
+	sl.activePID = pid
+	// As with Postgres, transactions before the confirmed position of the slot are never sent again
+	if sl.confirmedFlushLSN > startLSN {
+		startLSN = sl.confirmedFlushLSN
+	}
+	s.p.lock.Unlock()
+	defer func() {
+		s.p.lock.Lock()
+		sl.activePID = 0
+		s.p.lock.Unlock()
+	}()
+
+	// CopyBothResponse
+	if err := s.writeMessage('W', []byte{0, 0, 0}); err != nil {
+		return err
+	}
+	closedChan := make(chan error, 1)
+	common.Go(func() {
+		closedChan <- s.readStandbyStatus(sl)
+	})
+	for {
+		s.p.lock.Lock()
+		var txns []*transaction
+		for _, txn := range s.p.transactions {
+			if txn.endLSN > startLSN {
+				txns = append(txns, txn)
+			}
+		}
+		commitChan := s.p.commitChan
+		s.p.lock.Unlock()
+		for _, txn := range txns {
+			if err := s.sendTransaction(txn); err != nil {
+				return err
+			}
+			startLSN = txn.endLSN
+		}
+		// Ask the client where it has got to
+		keepalive := []byte{'k'}
+		keepalive = binary.BigEndian.AppendUint64(keepalive, startLSN)
+		keepalive = binary.BigEndian.AppendUint64(keepalive, uint64(time.Since(pgEpoch).Microseconds()))
+		if err := s.writeMessage('d', append(keepalive, 1)); err != nil {
+			return err
+		}
+		select {
+		case <-commitChan:
+		case err := <-closedChan:
+			return err
+		}
+	}
+}
+
+// readStandbyStatus reads client messages while streaming, until the client goes away
+func (s *serverConn) readStandbyStatus(sl *slot) error {
+	for {
+		msgType, body, err := s.readMessage()
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case 'd':
+			if len(body) >= 25 && body[0] == 'r' {
+				flushed := binary.BigEndian.Uint64(body[9:])
+				s.p.lock.Lock()
+				if flushed > sl.confirmedFlushLSN {
+					sl.confirmedFlushLSN = flushed
+				}
+				s.p.lock.Unlock()
+			}
+		case 'X', 'c':
+			return nil
+		}
+	}
+}
+
+func (s *serverConn) sendTransaction(txn *transaction) error {
+	commitTime := uint64(txn.commitTime.Sub(pgEpoch).Microseconds())
+	begin := []byte{'B'}
+	begin = binary.BigEndian.AppendUint64(begin, txn.endLSN-0x30)
+	begin = binary.BigEndian.AppendUint64(begin, commitTime)
+	begin = binary.BigEndian.AppendUint32(begin, txn.xid)
+	if err := s.sendXLogData(txn.beginLSN, begin); err != nil {
+		return err
+	}
+	for i, change := range txn.changes {
+		relID, err := s.maybeSendRelation(txn.changeLSNs[i], &change)
+		if err != nil {
+			return err
+		}
+		var msg []byte
+		switch change.Op {
+		case "insert":
+			msg = binary.BigEndian.AppendUint32([]byte{'I'}, relID)
+			msg = appendTuple(append(msg, 'N'), change.After)
+		case "update":
+			msg = binary.BigEndian.AppendUint32([]byte{'U'}, relID)
+			if change.Before != nil {
+				msg = appendTuple(append(msg, 'O'), change.Before)
+			}
+			msg = appendTuple(append(msg, 'N'), change.After)
+		case "delete":
+			msg = binary.BigEndian.AppendUint32([]byte{'D'}, relID)
+			msg = appendTuple(append(msg, 'O'), change.Before)
+		default:
+			panic(fmt.Sprintf("unknown op %s", change.Op))
+		}
+		if err := s.sendXLogData(txn.changeLSNs[i], msg); err != nil {
+			return err
+		}
+	}
+	commit := []byte{'C', 0}
+	commit = binary.BigEndian.AppendUint64(commit, txn.endLSN-0x30)
+	commit = binary.BigEndian.AppendUint64(commit, txn.endLSN)
+	commit = binary.BigEndian.AppendUint64(commit, commitTime)
+	return s.sendXLogData(txn.endLSN-0x30, commit)
+}
+
+// maybeSendRelation sends a relation message the first time a relation, or a new version of it, is used on the
+// connection, as pgoutput does
+func (s *serverConn) maybeSendRelation(lsn uint64, change *Change) (uint32, error) {
+	qualifiedName := change.Schema + "." + change.Table
+	s.p.lock.Lock()
+	relID, ok := s.p.relationIDs[qualifiedName]
+	if !ok {
+		relID = uint32(16384 + len(s.p.relationIDs))
+		s.p.relationIDs[qualifiedName] = relID
+	}
+	s.p.lock.Unlock()
+	columns := strings.Join(change.Columns, ",")
+	if s.sentRelations[relID] == columns {
+		return relID, nil
+	}
+	msg := binary.BigEndian.AppendUint32([]byte{'R'}, relID)
+	msg = appendCString(msg, change.Schema)
+	msg = appendCString(msg, change.Table)
+	msg = append(msg, 'd')
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(change.Columns)))
+	for i, col := range change.Columns {
+		// the first column is the key
+		if i == 0 {
+			msg = append(msg, 1)
+		} else {
+			msg = append(msg, 0)
+		}
+		msg = appendCString(msg, col)
+		msg = binary.BigEndian.AppendUint32(msg, textTypeOID)
+		msg = binary.BigEndian.AppendUint32(msg, 0xFFFFFFFF)
+	}
+	if err := s.sendXLogData(lsn, msg); err != nil {
+		return 0, err
+	}
+	s.sentRelations[relID] = columns
+	return relID, nil
+}
+
+func (s *serverConn) sendXLogData(lsn uint64, msg []byte) error {
+	buf := make([]byte, 0, len(msg)+25)
+	buf = append(buf, 'w')
+	buf = binary.BigEndian.AppendUint64(buf, lsn)
+	buf = binary.BigEndian.AppendUint64(buf, lsn)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(time.Since(pgEpoch).Microseconds()))
+	return s.writeMessage('d', append(buf, msg...))
+}
+
+func (s *serverConn) sendError(severity string, code string, message string) error {
+	var body []byte
+	body = appendCString(append(body, 'S'), severity)
+	body = appendCString(append(body, 'C'), code)
+	body = appendCString(append(body, 'M'), message)
+	if err := s.writeMessage('E', append(body, 0)); err != nil {
+		return err
+	}
+	if severity == "FATAL" {
+		return errwrap.New(message)
+	}
+	return s.writeMessage('Z', []byte{'I'})
+}
+
+func (s *serverConn) readMessage() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(s.reader, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(hdr[1:])-4)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], body, nil
+}
+
+func (s *serverConn) writeMessage(msgType byte, body []byte) error {
+	buf := append([]byte{msgType}, binary.BigEndian.AppendUint32(nil, uint32(len(body)+4))...)
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	_, err := s.conn.Write(append(buf, body...))
+	return err
+}
+
+func appendTuple(buf []byte, values []any) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(values)))
+	for _, v := range values {
+		if v == nil {
+			buf = append(buf, 'n')
+			continue
+		}
+		s := v.(string)
+		buf = append(buf, 't')
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+		buf = append(buf, s...)
+	}
+	return buf
+}
+
+func appendCString(buf []byte, s string) []byte {
+	buf = append(buf, s...)
+	return append(buf, 0)
+}
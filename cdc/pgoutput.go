@@ -0,0 +1,256 @@
+package cdc
+
+import (
+	"encoding/binary"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"time"
+)
+
+// pgEpoch is the epoch of Postgres timestamps, which are microseconds since 2000-01-01 UTC
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func fromPgTime(micros int64) time.Time {
+	return pgEpoch.Add(time.Duration(micros) * time.Microsecond)
+}
+
+func toPgTime(t time.Time) int64 {
+	return t.Sub(pgEpoch).Microseconds()
+}
+
+// pgoutputDecoder decodes the messages sent by the pgoutput logical decoding plugin, protocol version 1, and
+// assembles the changes into transactions
+type pgoutputDecoder struct {
+	relations map[uint32]*relation
+	txn       *Transaction
+}
+
+type relation struct {
+	schema  string
+	table   string
+	columns []string
+}
+
+func newPgoutputDecoder() *pgoutputDecoder {
+	return &pgoutputDecoder{relations: map[uint32]*relation{}}
+}
+
+// decode handles a single message, which starts at lsn in the WAL. The transaction is returned once its commit
+// message has been decoded.
+func (d *pgoutputDecoder) decode(lsn LSN, msg []byte) (*Transaction, error) {
+	if len(msg) == 0 {
+		return nil, errwrap.New("empty pgoutput message")
+	}
+	r := &msgReader{buf: msg[1:]}
+	switch msg[0] {
+	case 'B':
+		r.uint64() // final LSN of the transaction, also sent in the commit
+		commitTime := r.uint64()
+		xid := r.uint32()
+		d.txn = &Transaction{XID: xid, CommitTime: fromPgTime(int64(commitTime))}
+	case 'C':
+		r.byte()   // flags, currently unused
+		r.uint64() // LSN of the commit record
+		endLSN := r.uint64()
+		commitTime := r.uint64()
+		if r.err != nil {
+			return nil, r.err
+		}
+		txn := d.txn
+		if txn == nil {
+			return nil, errwrap.New("received commit without begin")
+		}
+		d.txn = nil
+		txn.EndLSN = LSN(endLSN)
+		txn.CommitTime = fromPgTime(int64(commitTime))
+		return txn, nil
+	case 'R':
+		relID := r.uint32()
+		rel := &relation{schema: r.cstring(), table: r.cstring()}
+		r.byte() // replica identity
+		numCols := int(r.uint16())
+		for i := 0; i < numCols && r.err == nil; i++ {
+			r.byte() // flags
+			rel.columns = append(rel.columns, r.cstring())
+			r.uint32() // type OID
+			r.uint32() // type modifier
+		}
+		if r.err == nil {
+			d.relations[relID] = rel
+		}
+	case 'I':
+		rel, err := d.getRelation(r)
+		if err != nil {
+			return nil, err
+		}
+		if kind := r.byte(); kind != 'N' && r.err == nil {
+			return nil, errwrap.Errorf("unexpected tuple type '%c' in insert", kind)
+		}
+		after := r.tuple(rel)
+		return nil, d.addChange(r, lsn, OpInsert, rel, nil, after)
+	case 'U':
+		rel, err := d.getRelation(r)
+		if err != nil {
+			return nil, err
+		}
+		var before map[string]any
+		kind := r.byte()
+		if kind == 'K' || kind == 'O' {
+			// The old key, or the whole old row if the replica identity is full
+			before = r.tuple(rel)
+			kind = r.byte()
+		}
+		if kind != 'N' && r.err == nil {
+			return nil, errwrap.Errorf("unexpected tuple type '%c' in update", kind)
+		}
+		after := r.tuple(rel)
+		return nil, d.addChange(r, lsn, OpUpdate, rel, before, after)
+	case 'D':
+		rel, err := d.getRelation(r)
+		if err != nil {
+			return nil, err
+		}
+		if kind := r.byte(); kind != 'K' && kind != 'O' && r.err == nil {
+			return nil, errwrap.Errorf("unexpected tuple type '%c' in delete", kind)
+		}
+		before := r.tuple(rel)
+		return nil, d.addChange(r, lsn, OpDelete, rel, before, nil)
+	case 'T', 'Y', 'O', 'M':
+		// truncate, type, origin and logical decoding messages are not captured
+	default:
+		return nil, errwrap.Errorf("unexpected pgoutput message type '%c'", msg[0])
+	}
+	return nil, r.err
+}
+
+func (d *pgoutputDecoder) getRelation(r *msgReader) (*relation, error) {
+	relID := r.uint32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	rel, ok := d.relations[relID]
+	if !ok {
+		return nil, errwrap.Errorf("received change for unknown relation %d", relID)
+	}
+	return rel, nil
+}
+
+func (d *pgoutputDecoder) addChange(r *msgReader, lsn LSN, op string, rel *relation, before map[string]any,
+	after map[string]any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if d.txn == nil {
+		return errwrap.Errorf("received %s outside of a transaction", op)
+	}
+	d.txn.Changes = append(d.txn.Changes, Change{
+		LSN:    lsn,
+		Op:     op,
+		Schema: rel.schema,
+		Table:  rel.table,
+		Before: before,
+		After:  after,
+	})
+	return nil
+}
+
+// msgReader reads the fields of a protocol message. Once a read fails, subsequent reads return zero values and err
+// is set.
+type msgReader struct {
+	buf []byte
+	err error
+}
+
+func (r *msgReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = errwrap.New("message is truncated")
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *msgReader) byte() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *msgReader) uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *msgReader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *msgReader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *msgReader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	for i, b := range r.buf {
+		if b == 0 {
+			s := string(r.buf[:i])
+			r.buf = r.buf[i+1:]
+			return s
+		}
+	}
+	r.err = errwrap.New("message is truncated")
+	return ""
+}
+
+// tuple reads TupleData. Values are returned as strings in Postgres text format, or nil for NULL. Unchanged TOASTed
+// values are not sent, so are omitted.
+func (r *msgReader) tuple(rel *relation) map[string]any {
+	numCols := int(r.uint16())
+	if r.err != nil {
+		return nil
+	}
+	if numCols != len(rel.columns) {
+		r.err = errwrap.Errorf("tuple for %s.%s has %d columns, expected %d", rel.schema, rel.table, numCols,
+			len(rel.columns))
+		return nil
+	}
+	values := make(map[string]any, numCols)
+	for _, col := range rel.columns {
+		switch kind := r.byte(); kind {
+		case 'n':
+			values[col] = nil
+		case 'u':
+		case 't', 'b':
+			l := int(r.uint32())
+			if v := r.next(l); v != nil {
+				values[col] = string(v)
+			}
+		default:
+			if r.err == nil {
+				r.err = errwrap.Errorf("unexpected column type '%c' in tuple", kind)
+			}
+		}
+		if r.err != nil {
+			return nil
+		}
+	}
+	return values
+}
@@ -0,0 +1,85 @@
+package cdc
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDecodeUnchangedToastAndKeyOnlyIdentity(t *testing.T) {
+	d := newPgoutputDecoder()
+	txn, err := d.decode(100, beginMsg())
+	require.NoError(t, err)
+	require.Nil(t, txn)
+	_, err = d.decode(110, relationMsg(1, "public", "docs", "id", "body"))
+	require.NoError(t, err)
+
+	// The old key only, and an unchanged TOASTed body
+	update := binary.BigEndian.AppendUint32([]byte{'U'}, 1)
+	update = append(update, 'K', 0, 2, 't', 0, 0, 0, 1, '7', 'n')
+	update = append(update, 'N', 0, 2, 't', 0, 0, 0, 1, '8', 'u')
+	_, err = d.decode(120, update)
+	require.NoError(t, err)
+
+	commit := []byte{'C', 0}
+	commit = binary.BigEndian.AppendUint64(commit, 130)
+	commit = binary.BigEndian.AppendUint64(commit, 140)
+	commit = binary.BigEndian.AppendUint64(commit, 0)
+	txn, err = d.decode(130, commit)
+	require.NoError(t, err)
+	require.NotNil(t, txn)
+	require.Equal(t, LSN(140), txn.EndLSN)
+	require.Equal(t, 1, len(txn.Changes))
+	change := txn.Changes[0]
+	require.Equal(t, LSN(120), change.LSN)
+	require.Equal(t, OpUpdate, change.Op)
+	require.Equal(t, map[string]any{"id": "7", "body": nil}, change.Before)
+	require.Equal(t, map[string]any{"id": "8"}, change.After)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	d := newPgoutputDecoder()
+	_, err := d.decode(100, beginMsg())
+	require.NoError(t, err)
+
+	_, err = d.decode(110, binary.BigEndian.AppendUint32([]byte{'I'}, 99))
+	require.Error(t, err)
+	require.Equal(t, "received change for unknown relation 99", err.Error())
+
+	_, err = d.decode(110, relationMsg(1, "public", "docs", "id")[:8])
+	require.Error(t, err)
+	require.Equal(t, "message is truncated", err.Error())
+
+	_, err = d.decode(110, []byte{'Z'})
+	require.Error(t, err)
+	require.Equal(t, "unexpected pgoutput message type 'Z'", err.Error())
+}
+
+func TestParseLSN(t *testing.T) {
+	lsn, err := ParseLSN("16/B374D848")
+	require.NoError(t, err)
+	require.Equal(t, LSN(0x16B374D848), lsn)
+	require.Equal(t, "16/B374D848", lsn.String())
+	_, err = ParseLSN("foo")
+	require.Error(t, err)
+}
+
+func beginMsg() []byte {
+	msg := binary.BigEndian.AppendUint64([]byte{'B'}, 130)
+	msg = binary.BigEndian.AppendUint64(msg, 0)
+	return binary.BigEndian.AppendUint32(msg, 700)
+}
+
+func relationMsg(relID uint32, schema string, table string, columns ...string) []byte {
+	msg := binary.BigEndian.AppendUint32([]byte{'R'}, relID)
+	msg = appendCString(msg, schema)
+	msg = appendCString(msg, table)
+	msg = append(msg, 'd')
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(columns)))
+	for _, col := range columns {
+		msg = appendCString(append(msg, 0), col)
+		msg = binary.BigEndian.AppendUint32(msg, 25)
+		msg = binary.BigEndian.AppendUint32(msg, 0xFFFFFFFF)
+	}
+	return msg
+}
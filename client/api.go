@@ -15,6 +15,10 @@ type Client interface {
 
 	StreamExecuteQuery(query string) (chan StreamChunk, error)
 
+	Explain(statement string) (QueryResult, error)
+
+	StreamStats(statement string) (QueryResult, error)
+
 	RegisterWasmModule(modulePath string) error
 
 	UnregisterWasmModule(moduleName string) error
@@ -84,6 +88,10 @@ type Column interface {
 	BytesVal(rowIndex int) []byte
 
 	TimestampVal(rowIndex int) types.Timestamp
+
+	// NestedVal returns the value of an array, map or struct column - []any for arrays and structs, and
+	// map[string]any for maps
+	NestedVal(rowIndex int) any
 }
 
 type Row interface {
@@ -102,6 +110,8 @@ type Row interface {
 	BytesVal(colIndex int) []byte
 
 	TimestampVal(colIndex int) types.Timestamp
+
+	NestedVal(colIndex int) any
 }
 
 type Meta interface {
@@ -45,6 +45,8 @@ func NewClientWithAuth(serverAddress string, tlsConfig TLSConfig, scramAuthType
 		statementURL:      fmt.Sprintf("https://%s/tektite/statement", serverAddress),
 		queryURL:          fmt.Sprintf("https://%s/tektite/query?col_headers=true", serverAddress),
 		execPSURL:         fmt.Sprintf("https://%s/tektite/exec?col_headers=true", serverAddress),
+		explainURL:        fmt.Sprintf("https://%s/tektite/explain?col_headers=true", serverAddress),
+		statsURL:          fmt.Sprintf("https://%s/tektite/stats?col_headers=true", serverAddress),
 		registerWasmURL:   fmt.Sprintf("https://%s/tektite/wasm-register", serverAddress),
 		unregisterWasmURL: fmt.Sprintf("https://%s/tektite/wasm-unregister", serverAddress),
 		putUserURL:        fmt.Sprintf("https://%s/tektite/put-user", serverAddress),
@@ -151,6 +153,8 @@ type client struct {
 	scramURL          string
 	statementURL      string
 	queryURL          string
+	explainURL        string
+	statsURL          string
 	execPSURL         string
 	registerWasmURL   string
 	unregisterWasmURL string
@@ -397,6 +401,16 @@ func (c *client) ExecuteQuery(query string) (QueryResult, error) {
 	return c.executeQuery(c.queryURL, query)
 }
 
+// Explain executes an explain statement, e.g. 'explain my_stream', returning one row for each operator
+func (c *client) Explain(statement string) (QueryResult, error) {
+	return c.executeQuery(c.explainURL, statement)
+}
+
+// StreamStats executes a 'show stream stats' statement, returning one row for each operator
+func (c *client) StreamStats(statement string) (QueryResult, error) {
+	return c.executeQuery(c.statsURL, statement)
+}
+
 func (c *client) executePreparedQuery(queryName string, args ...any) (QueryResult, error) {
 	return c.executeQuery(c.execPSURL, createExecutePSBody(queryName, args...))
 }
@@ -669,6 +683,10 @@ func (a *arrowBasedColumn) TimestampVal(rowIndex int) types.Timestamp {
 	return a.col.(*evbatch.TimestampColumn).Get(rowIndex)
 }
 
+func (a *arrowBasedColumn) NestedVal(rowIndex int) any {
+	return a.col.(*evbatch.NestedColumn).Get(rowIndex)
+}
+
 type arrowBasedRow struct {
 	rowIndex int
 	qr       *arrowBasedQueryResult
@@ -705,3 +723,7 @@ func (a *arrowBasedRow) BytesVal(colIndex int) []byte {
 func (a *arrowBasedRow) TimestampVal(colIndex int) types.Timestamp {
 	return a.qr.batch.Columns[colIndex].(*evbatch.TimestampColumn).Get(a.rowIndex)
 }
+
+func (a *arrowBasedRow) NestedVal(colIndex int) any {
+	return a.qr.batch.Columns[colIndex].(*evbatch.NestedColumn).Get(a.rowIndex)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/spirit-labs/tektite/cmdmgr"
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/opers"
 	"github.com/spirit-labs/tektite/parser"
 	"github.com/spirit-labs/tektite/protos/clustermsgs"
 	"github.com/spirit-labs/tektite/types"
@@ -68,7 +69,7 @@ func testExecuteCommand(t *testing.T, command string) {
 func TestExecuteCommandError(t *testing.T) {
 	tsl := `test_stream := (broodge from test_topic partitions = 23) -> (store stream)`
 	testExecuteCommandError(t, tsl,
-		`expected one of: 'aggregate', 'backfill', 'bridge', 'filter', 'join', 'kafka', 'partition', 'producer', 'project', 'store', 'topic', 'union' (line 1 column 17):
+		`expected one of: 'aggregate', 'backfill', 'bridge', 'cdc', 'decode', 'dedup', 'encode', 'explode', 'filter', 'http_sink', 'join', 'kafka', 'match_recognize', 'partition', 'producer', 'project', 'store', 'topic', 'topn', 'union' (line 1 column 17):
 test_stream := (broodge from test_topic partitions = 23) -> (store stream)
                 ^`)
 	testExecuteCommandError(t, "adasdasdasd", "reached end of statement")
@@ -167,6 +168,48 @@ func TestStreamExecuteQuery(t *testing.T) {
 	}
 }
 
+func TestExplain(t *testing.T) {
+	server, queryMgr, _, _, cl := setup(t)
+	defer func() {
+		cl.Close()
+		err := server.Stop()
+		require.NoError(t, err)
+	}()
+
+	res, err := cl.Explain("explain some_stream")
+	require.NoError(t, err)
+	require.Equal(t, "some_stream", queryMgr.getExplainStreamName())
+	require.Equal(t, opers.ExplainSchema.ColumnNames(), res.Meta().ColumnNames())
+	require.Equal(t, 1, res.RowCount())
+	require.Equal(t, "kafka in", res.Row(0).StringVal(2))
+	require.True(t, res.Row(0).IsNull(1))
+
+	_, err = cl.Explain("explain unknown_stream")
+	require.Error(t, err)
+	require.Equal(t, "unknown stream 'unknown_stream'", err.Error())
+}
+
+func TestStreamStats(t *testing.T) {
+	server, _, _, _, cl := setup(t)
+	defer func() {
+		cl.Close()
+		err := server.Stop()
+		require.NoError(t, err)
+	}()
+
+	res, err := cl.StreamStats("show stream stats")
+	require.NoError(t, err)
+	require.Equal(t, opers.StreamStatsSchema.ColumnNames(), res.Meta().ColumnNames())
+	require.Equal(t, 1, res.RowCount())
+	require.Equal(t, "test_stream", res.Row(0).StringVal(0))
+	require.Equal(t, int64(10), res.Row(0).IntVal(3))
+	require.True(t, res.Row(0).IsNull(11))
+
+	_, err = cl.StreamStats("show stream stats unknown_stream")
+	require.Error(t, err)
+	require.Equal(t, "unknown stream 'unknown_stream'", err.Error())
+}
+
 func TestStreamExecuteQueryError(t *testing.T) {
 	testStreamExecuteQueryError(t, "qwdqwdqwdqwd",
 		`expected '(' but found 'qwdqwdqwdqwd' (line 1 column 1):
@@ -443,6 +486,8 @@ type testQueryManager struct {
 	paramsSchema *evbatch.EventSchema
 
 	directQuerytsl string
+
+	explainStreamName string
 }
 
 func (t *testQueryManager) GetLastCompletedVersion() int {
@@ -464,6 +509,43 @@ func (t *testQueryManager) ExecuteQueryDirect(tsl string, _ parser.QueryDesc, ou
 	return nil
 }
 
+func (t *testQueryManager) Explain(explain parser.ExplainDesc) (*evbatch.Batch, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if explain.StreamName == "unknown_stream" {
+		return nil, common.NewQueryErrorf("unknown stream '%s'", explain.StreamName)
+	}
+	t.explainStreamName = explain.StreamName
+	schema := &opers.OperatorSchema{
+		EventSchema:     evbatch.NewEventSchema([]string{"f1"}, []types.ColumnType{types.ColumnTypeInt}),
+		PartitionScheme: opers.NewPartitionScheme(explain.StreamName, 4, true, 2),
+	}
+	return opers.ExplainBatch([]*opers.OperatorPlan{
+		{ID: 0, ParentID: -1, Operator: "kafka in", OutSchema: schema, Partitions: 4},
+	}), nil
+}
+
+func (t *testQueryManager) StreamStats(showStats parser.ShowStreamStatsDesc) (*evbatch.Batch, error) {
+	if showStats.StreamName == "unknown_stream" {
+		return nil, common.NewQueryErrorf("unknown stream '%s'", showStats.StreamName)
+	}
+	return opers.StreamStatsBatch([]*opers.StreamStats{
+		{
+			StreamName: "test_stream",
+			Backlog:    -1,
+			Operators: []*opers.OperatorStats{
+				{ID: 0, Operator: "kafka in", RowsIn: 10, RowsOut: 10, Batches: 1, WaterMarkLag: -1},
+			},
+		},
+	}), nil
+}
+
+func (t *testQueryManager) getExplainStreamName() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.explainStreamName
+}
+
 func (t *testQueryManager) getDirectQueryState() string {
 	t.lock.Lock()
 	defer t.lock.Unlock()
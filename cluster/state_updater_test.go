@@ -189,6 +189,13 @@ func (u *unavailableObjStoreProxy) Get(ctx context.Context, bucket string, key s
 	return u.objStore.Get(ctx, bucket, key)
 }
 
+func (u *unavailableObjStoreProxy) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	if u.unavailable.Load() {
+		return nil, common.NewTektiteErrorf(common.Unavailable, "store is unavailable")
+	}
+	return u.objStore.GetRange(ctx, bucket, key, offset, length)
+}
+
 func (u *unavailableObjStoreProxy) Put(ctx context.Context, bucket string, key string, value []byte) error {
 	if u.unavailable.Load() {
 		return common.NewTektiteErrorf(common.Unavailable, "store is unavailable")
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"github.com/alecthomas/kong"
 	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/objstore"
 	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/spirit-labs/tektite/objstore/fs"
 	"os"
 )
 
 var CLI struct {
 	ListenAddr string `help:"IP address the local store will listen on" default:"127.0.0.1:6690"`
+	Dir        string `help:"Directory to persist objects in. If not specified objects are held in memory and lost on exit"`
 }
 
 func main() {
@@ -21,7 +24,13 @@ func main() {
 func run() error {
 	defer common.TektitePanicHandler()
 	kong.Parse(&CLI)
-	localStore := dev.NewDevStore(CLI.ListenAddr)
+	var client objstore.Client
+	if CLI.Dir != "" {
+		client = fs.NewFsClient(CLI.Dir)
+	} else {
+		client = dev.NewInMemStore(0)
+	}
+	localStore := dev.NewDevStoreWithClient(CLI.ListenAddr, client)
 	if err := localStore.Start(); err != nil {
 		return err
 	}
@@ -221,13 +221,9 @@ func (m *manager) processCommands(batch *evbatch.Batch) (int64, error) {
 			extraData := batch.GetBytesColumn(3).Get(i)
 			receiverSequences, slabSequences := deserializeExtraData(extraData)
 			ast.CreateStream.TestSource = m.testSource
-			err = m.streamManager.DeployStream(*ast.CreateStream, receiverSequences, slabSequences, command, commandID)
+			err = m.deployStream(ast.CreateStream, receiverSequences, slabSequences, command, commandID)
 		} else if ast.DeleteStream != nil {
-			pi := m.streamManager.GetStream(ast.DeleteStream.StreamName)
-			err = m.streamManager.UndeployStream(*ast.DeleteStream, commandID)
-			if err == nil {
-				m.commandIDsToClear = append(m.commandIDsToClear, pi.CommandID, commandID)
-			}
+			err = m.undeployStream(ast.DeleteStream, commandID)
 		} else if ast.PrepareQuery != nil {
 			err = m.queryManager.PrepareQuery(*ast.PrepareQuery)
 		} else if ast.DeleteQuery != nil {
@@ -284,6 +280,25 @@ func (m *manager) ExecuteCommand(command string) error {
 	if err != nil {
 		return err
 	}
+	if ast.Savepoint != nil {
+		// A savepoint does not change the streams so it is not stored as a command
+		return m.savepointStream(ast.Savepoint)
+	}
+	if ast.CreateStream != nil {
+		// The command is stored with any unspecified schema versions resolved, and reparsed so that this node deploys
+		// exactly what the other nodes will
+		pinned, err := m.streamManager.PinSchemaVersions(ast.CreateStream, command)
+		if err != nil {
+			return err
+		}
+		if pinned != command {
+			command = pinned
+			ast, err = m.parser.ParseTSL(command)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	var extraData []byte
 	var receiverSequences, slabSequences []int
 	if ast.CreateStream != nil {
@@ -306,6 +321,23 @@ func (m *manager) ExecuteCommand(command string) error {
 	}
 	defer m.releaseClusterLock()
 
+	var loadedStream *opers.LoadedStream
+	if ast.CreateStream != nil && ast.CreateStream.FromSavepoint != "" {
+		// The savepoint is loaded into the slabs of the stream before the command is stored, so no node deploys the
+		// stream without its state. The savepoint is only loaded by the node that executes the command, which then
+		// deploys the same operators that the state was loaded for.
+		loadedStream, err = m.streamManager.LoadSavepoint(*ast.CreateStream, receiverSequences, slabSequences)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if loadedStream != nil {
+				// The stream was not deployed, so the state is deleted
+				m.streamManager.DiscardLoadedStream(loadedStream)
+			}
+		}()
+	}
+
 	// Load the commands to get the last command id - note that what is in store is the source of truth
 	batch, err := m.LoadCommands(m.lastProcessedCommandID + 1)
 	if err != nil {
@@ -347,14 +379,15 @@ func (m *manager) ExecuteCommand(command string) error {
 	m.lastProcessedCommandID = commandID
 
 	// Now we can process the command locally
-	if ast.CreateStream != nil {
-		err = m.streamManager.DeployStream(*ast.CreateStream, receiverSequences, slabSequences, command, commandID)
-	} else if ast.DeleteStream != nil {
-		pi := m.streamManager.GetStream(ast.DeleteStream.StreamName)
-		err = m.streamManager.UndeployStream(*ast.DeleteStream, commandID)
+	if loadedStream != nil {
+		err = m.streamManager.DeployLoadedStream(loadedStream, command, commandID)
 		if err == nil {
-			m.commandIDsToClear = append(m.commandIDsToClear, pi.CommandID, commandID)
+			loadedStream = nil
 		}
+	} else if ast.CreateStream != nil {
+		err = m.deployStream(ast.CreateStream, receiverSequences, slabSequences, command, commandID)
+	} else if ast.DeleteStream != nil {
+		err = m.undeployStream(ast.DeleteStream, commandID)
 	} else if ast.PrepareQuery != nil {
 		err = m.queryManager.PrepareQuery(*ast.PrepareQuery)
 	} else if ast.DeleteQuery != nil {
@@ -386,6 +419,33 @@ func (m *manager) ExecuteCommand(command string) error {
 	return err
 }
 
+func (m *manager) deployStream(createStream *parser.CreateStreamDesc, receiverSequences []int, slabSequences []int,
+	command string, commandID int64) error {
+	if createStream.Alter {
+		return m.streamManager.AlterStream(*createStream, receiverSequences, slabSequences, command, commandID)
+	}
+	return m.streamManager.DeployStream(*createStream, receiverSequences, slabSequences, command, commandID)
+}
+
+func (m *manager) savepointStream(savepoint *parser.SavepointDesc) error {
+	_, lastCompletedVersion, _, err := m.vmgrClient.GetVersions()
+	if err != nil {
+		return err
+	}
+	return m.streamManager.SavepointStream(*savepoint, lastCompletedVersion)
+}
+
+func (m *manager) undeployStream(deleteStream *parser.DeleteStreamDesc, commandID int64) error {
+	pi := m.streamManager.GetStream(deleteStream.StreamName)
+	if err := m.streamManager.UndeployStream(*deleteStream, commandID); err != nil {
+		return err
+	}
+	// The commands which created and altered the stream are no longer needed
+	m.commandIDsToClear = append(m.commandIDsToClear, pi.CommandID, commandID)
+	m.commandIDsToClear = append(m.commandIDsToClear, pi.ReplacedCommandIDs...)
+	return nil
+}
+
 func (m *manager) MaybeCompact() error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -479,10 +539,10 @@ func (m *manager) getSequences(seqCount int, seqName string, base int) ([]int, e
 func calcSequencesCountForStream(cp *parser.CreateStreamDesc) (receiverCount int, slabCount int) {
 	for _, desc := range cp.OperatorDescs {
 		switch desc.(type) {
-		case *parser.BridgeFromDesc:
+		case *parser.BridgeFromDesc, *parser.CdcFromDesc:
 			receiverCount++
 			slabCount++
-		case *parser.BridgeToDesc:
+		case *parser.BridgeToDesc, *parser.HttpSinkDesc:
 			receiverCount++
 			slabCount += 3
 		case *parser.KafkaInDesc:
@@ -502,6 +562,12 @@ func calcSequencesCountForStream(cp *parser.CreateStreamDesc) (receiverCount int
 			slabCount += 2
 		case *parser.StoreTableDesc:
 			slabCount++
+		case *parser.DedupDesc:
+			slabCount++
+		case *parser.TopNDesc:
+			slabCount += 2
+		case *parser.MatchRecognizeDesc:
+			slabCount++
 		case *parser.JoinDesc:
 			slabCount += 2
 			receiverCount++
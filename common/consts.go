@@ -20,5 +20,7 @@ const (
 	DeleteSlabReceiverID      = 6
 	UserCredsReceiverID       = 7
 	UserCredsDeleteReceiverID = 8
+	SavepointReceiverID       = 9
+	LoadSavepointReceiverID   = 10
 	UserReceiverIDBase        = 1000
 )
@@ -4,6 +4,9 @@ type DataFormat byte
 
 const (
 	DataFormatV1 DataFormat = 1
+	// DataFormatV2 SSTables are split into data blocks, with a prefix compressed index of the blocks and a bloom filter
+	// on key prefixes
+	DataFormatV2 DataFormat = 2
 )
 
 type MetadataFormat byte
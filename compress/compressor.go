@@ -8,6 +8,7 @@ import (
 	"github.com/pierrec/lz4/v4"
 	"github.com/pkg/errors"
 	"io"
+	"runtime"
 )
 
 type CompressionType byte
@@ -81,6 +82,8 @@ func Compress(compressionType CompressionType, buff []byte, data []byte) ([]byte
 		}
 	case CompressionTypeZstd:
 		compressed, err := zstd.Compress(nil, data)
+		// zstd passes data to C as a uintptr, which does not stop it being collected while the C call runs
+		runtime.KeepAlive(data)
 		if err != nil {
 			return nil, err
 		}
@@ -111,7 +114,10 @@ func Decompress(compressionType CompressionType, data []byte) ([]byte, error) {
 	case CompressionTypeLz4:
 		r = lz4.NewReader(bytes.NewReader(data))
 	case CompressionTypeZstd:
-		return zstd.Decompress(nil, data)
+		decompressed, err := zstd.Decompress(nil, data)
+		// see Compress
+		runtime.KeepAlive(data)
+		return decompressed, err
 	default:
 		return nil, errors.Errorf("unexpected compression type: %d", compressionType)
 	}
@@ -0,0 +1,481 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/compress"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/lsm"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/parthash"
+	"github.com/spirit-labs/tektite/queryutils"
+	"github.com/spirit-labs/tektite/sst"
+	"github.com/spirit-labs/tektite/topicmeta"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// BackupKeyPrefix is the prefix of the keys backups are stored with in the data bucket
+	BackupKeyPrefix        = "backup-"
+	backupListTimeout      = 1 * time.Minute
+	restoreTableMaxSize    = 16 * 1024 * 1024
+	restoreRegisterTimeout = 1 * time.Minute
+)
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+// BackupInfo describes a backup of the cluster
+type BackupInfo struct {
+	ID         string    `json:"id"`
+	CreateTime time.Time `json:"create_time"`
+	// TableCount is the number of tables referenced by the backup
+	TableCount int `json:"table_count"`
+}
+
+// backup is stored as JSON with the BackupKeyPrefix
+type backup struct {
+	BackupInfo
+	TableIDs []string `json:"table_ids"`
+	// MetaData is the serialized master record of the LSM
+	MetaData []byte `json:"meta_data"`
+}
+
+/*
+backupManager creates and deletes backups of the cluster. All cluster state - topic metadata, offsets, sequences, ACLs,
+user credentials and consumer group offsets - is stored in the LSM, so a backup is a copy of the LSM master record,
+which is consistent as of the time it was taken, along with the ids of the tables it references.
+
+Tables referenced by a backup are retained - they are not deleted by the LSM manager when they are compacted away, or
+by the table garbage collector. The references are counted across backups, and are loaded when the controller
+activates, before the LSM is started. When the last backup referencing a table is deleted, the table becomes an orphan
+if it is no longer registered in the LSM, and is deleted by the table garbage collector.
+*/
+type backupManager struct {
+	lock       sync.Mutex
+	objStore   objstore.Client
+	bucketName string
+	lsmHolder  *LsmHolder
+	// backups are held without their metadata
+	backups   map[string]*backup
+	tableRefs map[string]int
+}
+
+func newBackupManager(objStore objstore.Client, bucketName string, lsmHolder *LsmHolder) *backupManager {
+	return &backupManager{
+		objStore:   objStore,
+		bucketName: bucketName,
+		lsmHolder:  lsmHolder,
+		backups:    map[string]*backup{},
+		tableRefs:  map[string]int{},
+	}
+}
+
+func (b *backupManager) load() error {
+	infos, err := objstore.ListObjectsWithPrefixWithTimeout(b.objStore, b.bucketName, BackupKeyPrefix, -1,
+		backupListTimeout)
+	if err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, info := range infos {
+		bk, err := readBackup(b.objStore, b.bucketName, info.Key[len(BackupKeyPrefix):])
+		if err != nil {
+			if errors.Is(err, ErrBackupNotFound) {
+				// Deleted since listing
+				continue
+			}
+			return err
+		}
+		bk.MetaData = nil
+		b.addBackup(bk)
+	}
+	return nil
+}
+
+func (b *backupManager) isRetained(tableID sst.SSTableID) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.tableRefs[string(tableID)] > 0
+}
+
+func (b *backupManager) retainedTableIDs() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	tableIDs := make([]string, 0, len(b.tableRefs))
+	for tableID := range b.tableRefs {
+		tableIDs = append(tableIDs, tableID)
+	}
+	return tableIDs
+}
+
+func (b *backupManager) create() (BackupInfo, error) {
+	// Must be called outside the lock, as the LSM manager calls isRetained with its lock held
+	metaData, err := b.lsmHolder.GetMasterRecordBytes()
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	mr := &lsm.MasterRecord{}
+	mr.Deserialize(metaData, 0)
+	tableIDs := mr.TableIDs()
+	now := time.Now().UTC()
+	bk := &backup{
+		BackupInfo: BackupInfo{
+			ID:         fmt.Sprintf("%s-%s", now.Format("20060102T150405Z"), uuid.New().String()[:8]),
+			CreateTime: now,
+			TableCount: len(tableIDs),
+		},
+		TableIDs: make([]string, len(tableIDs)),
+		MetaData: metaData,
+	}
+	for i, tableID := range tableIDs {
+		bk.TableIDs[i] = string(tableID)
+	}
+	buff, err := json.Marshal(bk)
+	if err != nil {
+		return BackupInfo{}, errors.WithStack(err)
+	}
+	// The tables are retained before the backup is written. A table deregistered since the master record was taken
+	// is not deleted until the LSM delete delay has passed, so it is retained before it can be deleted.
+	b.lock.Lock()
+	b.addBackup(bk)
+	b.lock.Unlock()
+	ok, _, err := objstore.PutIfNotExistsWithTimeout(b.objStore, b.bucketName, BackupKeyPrefix+bk.ID, buff,
+		objStoreCallTimeout)
+	if err == nil && !ok {
+		err = errors.Errorf("backup %s already exists", bk.ID)
+	}
+	if err != nil {
+		b.lock.Lock()
+		b.removeBackup(bk.ID)
+		b.lock.Unlock()
+		return BackupInfo{}, err
+	}
+	bk.MetaData = nil
+	log.Infof("created backup %s referencing %d tables", bk.ID, bk.TableCount)
+	return bk.BackupInfo, nil
+}
+
+func (b *backupManager) list() []BackupInfo {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	infos := make([]BackupInfo, 0, len(b.backups))
+	for _, bk := range b.backups {
+		infos = append(infos, bk.BackupInfo)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].CreateTime.Equal(infos[j].CreateTime) {
+			return infos[i].ID < infos[j].ID
+		}
+		return infos[i].CreateTime.Before(infos[j].CreateTime)
+	})
+	return infos
+}
+
+func (b *backupManager) get(backupID string) (*backup, error) {
+	b.lock.Lock()
+	_, ok := b.backups[backupID]
+	b.lock.Unlock()
+	if !ok {
+		return nil, errors.Wrap(ErrBackupNotFound, backupID)
+	}
+	return readBackup(b.objStore, b.bucketName, backupID)
+}
+
+func (b *backupManager) delete(backupID string) error {
+	b.lock.Lock()
+	_, ok := b.backups[backupID]
+	b.lock.Unlock()
+	if !ok {
+		return errors.Wrap(ErrBackupNotFound, backupID)
+	}
+	// The backup is deleted before its tables are released, so they're never deleted while it still exists
+	if err := objstore.DeleteWithTimeout(b.objStore, b.bucketName, BackupKeyPrefix+backupID,
+		objStoreCallTimeout); err != nil {
+		return err
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.removeBackup(backupID)
+	log.Infof("deleted backup %s", backupID)
+	return nil
+}
+
+func (b *backupManager) addBackup(bk *backup) {
+	b.backups[bk.ID] = bk
+	for _, tableID := range bk.TableIDs {
+		b.tableRefs[tableID]++
+	}
+}
+
+func (b *backupManager) removeBackup(backupID string) {
+	bk, ok := b.backups[backupID]
+	if !ok {
+		return
+	}
+	delete(b.backups, backupID)
+	for _, tableID := range bk.TableIDs {
+		refs := b.tableRefs[tableID] - 1
+		if refs <= 0 {
+			delete(b.tableRefs, tableID)
+		} else {
+			b.tableRefs[tableID] = refs
+		}
+	}
+}
+
+func readBackup(objStore objstore.Client, bucketName string, backupID string) (*backup, error) {
+	buff, err := objstore.GetWithTimeout(objStore, bucketName, BackupKeyPrefix+backupID, objStoreCallTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if buff == nil {
+		return nil, errors.Wrap(ErrBackupNotFound, backupID)
+	}
+	bk := &backup{}
+	if err := json.Unmarshal(buff, bk); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return bk, nil
+}
+
+/*
+RestoreBackup restores the whole cluster as of a backup, replacing the current state. The cluster must be stopped, as
+the controller metadata is overwritten. All topics, offsets, ACLs, user credentials and consumer group offsets are
+restored to their state when the backup was taken - anything committed after that is lost. Tables which are no longer
+referenced once the cluster is restarted are deleted by the table garbage collector. Other backups are kept.
+*/
+func RestoreBackup(objStore objstore.Client, cfg Conf, backupID string) (BackupInfo, error) {
+	bk, err := readBackup(objStore, cfg.SSTableBucketName, backupID)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	infos, err := objstore.ListObjectsWithPrefixWithTimeout(objStore, cfg.SSTableBucketName, sst.SSTableIDPrefix, -1,
+		backupListTimeout)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	existing := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		existing[info.Key] = struct{}{}
+	}
+	var missing []string
+	for _, tableID := range bk.TableIDs {
+		if _, ok := existing[tableID]; !ok {
+			missing = append(missing, tableID)
+		}
+	}
+	if len(missing) > 0 {
+		return BackupInfo{}, errors.Errorf("cannot restore backup %s - %d tables referenced by it are missing from bucket %s: %v",
+			backupID, len(missing), cfg.SSTableBucketName, missing)
+	}
+	if err := objstore.PutWithTimeout(objStore, cfg.ControllerMetaDataBucketName, cfg.ControllerMetaDataKey,
+		bk.MetaData, objStoreCallTimeout); err != nil {
+		return BackupInfo{}, err
+	}
+	log.Infof("restored cluster from backup %s taken at %s", backupID, bk.CreateTime.Format(time.RFC3339))
+	return bk.BackupInfo, nil
+}
+
+/*
+restoreTopic restores a topic, as of a backup, to a new topic with the name restoredName while the cluster is running,
+e.g. to recover a topic which was deleted by mistake. The data of the topic is read from the backup and rewritten with
+the id of the new topic into new tables, which are registered in the LSM atomically with the topic metadata. Consumer
+group offsets are not restored. The registration batch returned must be registered in the LSM with
+topicmeta.Manager.AddRestoredTopic.
+*/
+func restoreTopic(bk *backup, topicName string, restoredName string, objStore objstore.Client, bucketName string,
+	format common.DataFormat, compressionType compress.CompressionType,
+	topicMetaManager *topicmeta.Manager) (topicmeta.TopicInfo, lsm.RegistrationBatch, error) {
+	var regBatch lsm.RegistrationBatch
+	backupLsm := lsm.NewManager(objStore, func() {}, false, false, lsm.NewConf())
+	if err := backupLsm.Start(bk.MetaData); err != nil {
+		return topicmeta.TopicInfo{}, regBatch, err
+	}
+	defer func() {
+		if err := backupLsm.Stop(); err != nil {
+			log.Warnf("failed to stop backup lsm: %v", err)
+		}
+	}()
+	tg := &objStoreTableGetter{objStore: objStore, bucketName: bucketName}
+	infos, err := topicmeta.LoadTopicInfos(backupLsm, tg.GetSSTable)
+	if err != nil {
+		return topicmeta.TopicInfo{}, regBatch, err
+	}
+	var info topicmeta.TopicInfo
+	found := false
+	for _, ti := range infos {
+		if ti.Name == topicName {
+			info = ti
+			found = true
+			break
+		}
+	}
+	if !found {
+		return topicmeta.TopicInfo{}, regBatch, common.NewTektiteErrorf(common.TopicDoesNotExist,
+			"topic: %s does not exist in backup %s", topicName, bk.ID)
+	}
+	if _, _, exists, err := topicMetaManager.GetTopicInfo(restoredName); err != nil {
+		return topicmeta.TopicInfo{}, regBatch, err
+	} else if exists {
+		return topicmeta.TopicInfo{}, regBatch, common.NewTektiteErrorf(common.TopicAlreadyExists,
+			"topic: %s already exists", restoredName)
+	}
+	restoredID, err := topicMetaManager.ReserveTopicID()
+	if err != nil {
+		return topicmeta.TopicInfo{}, regBatch, err
+	}
+	oldID := info.ID
+	info.ID = restoredID
+	info.Name = restoredName
+	builder := &restoreTableBuilder{
+		objStore:        objStore,
+		bucketName:      bucketName,
+		format:          format,
+		compressionType: compressionType,
+	}
+	for partitionID := 0; partitionID < info.PartitionCount; partitionID++ {
+		if err := builder.copyPartition(backupLsm, tg.GetSSTable, oldID, restoredID, partitionID); err != nil {
+			return topicmeta.TopicInfo{}, regBatch, err
+		}
+		if err := builder.flush(); err != nil {
+			return topicmeta.TopicInfo{}, regBatch, err
+		}
+	}
+	if err := builder.addKV(topicMetaManager.TopicKV(info)); err != nil {
+		return topicmeta.TopicInfo{}, regBatch, err
+	}
+	if err := builder.flush(); err != nil {
+		return topicmeta.TopicInfo{}, regBatch, err
+	}
+	regBatch.Registrations = builder.registrations
+	return info, regBatch, nil
+}
+
+// restoreTableBuilder builds tables from KVs and pushes them to the object store. It is flushed after each partition,
+// so a table only holds the data of one partition, and a partition's KVs are added in key order, so the newest table
+// with the partition prefix in L0 holds its highest offset.
+type restoreTableBuilder struct {
+	objStore        objstore.Client
+	bucketName      string
+	format          common.DataFormat
+	compressionType compress.CompressionType
+	kvs             []common.KV
+	size            int
+	registrations   []lsm.RegistrationEntry
+}
+
+func (r *restoreTableBuilder) copyPartition(querier queryutils.Querier, tableGetter sst.TableGetter, oldID int,
+	newID int, partitionID int) error {
+	oldPartHash, err := parthash.CreatePartitionHash(oldID, partitionID)
+	if err != nil {
+		return err
+	}
+	newPartHash, err := parthash.CreatePartitionHash(newID, partitionID)
+	if err != nil {
+		return err
+	}
+	iter, err := queryutils.CreateIteratorForKeyRange(oldPartHash, common.IncBigEndianBytes(oldPartHash), querier,
+		tableGetter)
+	if err != nil {
+		return err
+	}
+	if iter == nil {
+		return nil
+	}
+	defer iter.Close()
+	for {
+		ok, kv, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if len(kv.Value) == 0 || !bytes.Equal(oldPartHash, kv.Key[:len(oldPartHash)]) {
+			// Deleted
+			continue
+		}
+		key := make([]byte, 0, len(kv.Key))
+		key = append(key, newPartHash...)
+		key = append(key, kv.Key[len(oldPartHash):]...)
+		value := kv.Value
+		if meta := common.ReadValueMetadata(value); len(meta) == 2 && meta[0] == int64(oldID) {
+			value = common.RemoveValueMetadata(value)
+			value = common.AppendValueMetadata(common.ByteSliceCopy(value), int64(newID), meta[1])
+		}
+		if err := r.addKV(common.KV{Key: key, Value: value}); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *restoreTableBuilder) addKV(kv common.KV) error {
+	r.kvs = append(r.kvs, kv)
+	r.size += len(kv.Key) + len(kv.Value)
+	if r.size >= restoreTableMaxSize {
+		return r.flush()
+	}
+	return nil
+}
+
+func (r *restoreTableBuilder) flush() error {
+	if len(r.kvs) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(r.kvs, func(a, b common.KV) int {
+		return bytes.Compare(a.Key, b.Key)
+	})
+	table, smallestKey, largestKey, minVersion, maxVersion, err := sst.BuildSSTable(r.format,
+		int(1.1*float64(r.size)), len(r.kvs), common.NewKvSliceIterator(r.kvs))
+	if err != nil {
+		return err
+	}
+	buff, err := table.ToStorageBytes(r.compressionType)
+	if err != nil {
+		return err
+	}
+	tableID := sst.CreateSSTableId()
+	if err := objstore.PutWithTimeout(r.objStore, r.bucketName, tableID, buff, objStoreCallTimeout); err != nil {
+		return err
+	}
+	r.registrations = append(r.registrations, lsm.RegistrationEntry{
+		Level:            0,
+		TableID:          []byte(tableID),
+		MinVersion:       minVersion,
+		MaxVersion:       maxVersion,
+		KeyStart:         smallestKey,
+		KeyEnd:           largestKey,
+		DeleteRatio:      table.DeleteRatio(),
+		AddedTime:        uint64(time.Now().UnixMilli()),
+		NumEntries:       uint64(table.NumEntries()),
+		TableSize:        uint64(table.SizeBytes()),
+		NumPrefixDeletes: uint32(table.NumPrefixDeletes()),
+	})
+	r.kvs = nil
+	r.size = 0
+	return nil
+}
+
+type objStoreTableGetter struct {
+	objStore   objstore.Client
+	bucketName string
+}
+
+func (o *objStoreTableGetter) GetSSTable(tableID sst.SSTableID) (*sst.SSTable, error) {
+	buff, err := objstore.GetWithTimeout(o.objStore, o.bucketName, string(tableID), objStoreCallTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if buff == nil {
+		return nil, errors.Errorf("table %s not found", string(tableID))
+	}
+	return sst.GetSSTableFromBytes(buff)
+}
@@ -0,0 +1,279 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/lsm"
+	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/spirit-labs/tektite/parthash"
+	"github.com/spirit-labs/tektite/queryutils"
+	"github.com/spirit-labs/tektite/sst"
+	"github.com/spirit-labs/tektite/topicmeta"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCreateListDeleteBackups(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	controllers, _, tearDown := setupControllersWithObjectStore(t, 2, objStore)
+	defer tearDown(t)
+	updateMembership(t, 1, 1, controllers, 0, 1)
+	controller := controllers[0]
+
+	backups, err := controller.ListBackups()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(backups))
+
+	tableID1 := sst.CreateSSTableId()
+	registerTable(t, controller, tableID1)
+	info1, err := controller.CreateBackup()
+	require.NoError(t, err)
+	require.Equal(t, 1, info1.TableCount)
+	tableID2 := sst.CreateSSTableId()
+	registerTable(t, controller, tableID2)
+	info2, err := controller.CreateBackup()
+	require.NoError(t, err)
+	require.Equal(t, 2, info2.TableCount)
+	require.NotEqual(t, info1.ID, info2.ID)
+
+	backups, err = controller.ListBackups()
+	require.NoError(t, err)
+	require.Equal(t, []BackupInfo{info1, info2}, trimMonotonic(backups))
+	requireObjectsExist(t, objStore, controller.cfg.SSTableBucketName, BackupKeyPrefix+info1.ID,
+		BackupKeyPrefix+info2.ID)
+	require.True(t, controller.backups.isRetained([]byte(tableID1)))
+	require.True(t, controller.backups.isRetained([]byte(tableID2)))
+
+	// Only the leader has backups
+	_, err = controllers[1].ListBackups()
+	require.Error(t, err)
+	require.True(t, common.IsTektiteErrorWithCode(err, common.Unavailable))
+
+	// Backups are loaded when a new leader activates
+	updateMembership(t, 2, 2, controllers, 1, 0)
+	backups, err = controllers[1].ListBackups()
+	require.NoError(t, err)
+	require.Equal(t, []BackupInfo{info1, info2}, trimMonotonic(backups))
+	controller = controllers[1]
+
+	err = controller.DeleteBackup(info1.ID)
+	require.NoError(t, err)
+	backups, err = controller.ListBackups()
+	require.NoError(t, err)
+	require.Equal(t, []BackupInfo{info2}, trimMonotonic(backups))
+	v, err := objStore.Get(context.Background(), controller.cfg.SSTableBucketName, BackupKeyPrefix+info1.ID)
+	require.NoError(t, err)
+	require.Nil(t, v)
+	// Still referenced by the other backup
+	require.True(t, controller.backups.isRetained([]byte(tableID1)))
+
+	err = controller.DeleteBackup(info1.ID)
+	require.ErrorIs(t, err, ErrBackupNotFound)
+	err = controller.DeleteBackup(info2.ID)
+	require.NoError(t, err)
+	require.False(t, controller.backups.isRetained([]byte(tableID1)))
+	require.False(t, controller.backups.isRetained([]byte(tableID2)))
+}
+
+func TestBackupRetainsTablesFromTableGC(t *testing.T) {
+	gracePeriod := 100 * time.Millisecond
+	objStore := dev.NewInMemStore(0)
+	controllers, _, tearDown := setupControllersWithObjectStoreAndConfigSetter(t, 1, objStore, func(conf *Conf) {
+		conf.TableGCInterval = 0
+		conf.TableGCGracePeriod = gracePeriod
+	})
+	defer tearDown(t)
+	updateMembership(t, 1, 1, controllers, 0)
+	controller := controllers[0]
+	bucketName := controller.cfg.SSTableBucketName
+
+	tableID := sst.CreateSSTableId()
+	putObject(t, objStore, bucketName, tableID)
+	registerTable(t, controller, tableID)
+	info, err := controller.CreateBackup()
+	require.NoError(t, err)
+
+	// Deregister the table so it's no longer referenced by the LSM
+	cl, err := controller.Client()
+	require.NoError(t, err)
+	defer func() {
+		err := cl.Close()
+		require.NoError(t, err)
+	}()
+	batch := createBatch(0, []byte(tableID), []byte("key000001"), []byte("key000010"))
+	err = cl.ApplyLsmChanges(lsm.RegistrationBatch{DeRegistrations: batch.Registrations})
+	require.NoError(t, err)
+
+	time.Sleep(gracePeriod)
+	report, err := controller.RunTableGC(false)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(report.OrphanedTables))
+	requireObjectsExist(t, objStore, bucketName, tableID)
+
+	// Once the backup is deleted the table is an orphan
+	err = controller.DeleteBackup(info.ID)
+	require.NoError(t, err)
+	report, err = controller.RunTableGC(false)
+	require.NoError(t, err)
+	require.Equal(t, []string{tableID}, report.OrphanedTables)
+}
+
+func TestRestoreTopic(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	controllers, _, tearDown := setupControllersWithObjectStore(t, 1, objStore)
+	defer tearDown(t)
+	updateMembership(t, 1, 1, controllers, 0)
+	controller := controllers[0]
+	bucketName := controller.cfg.SSTableBucketName
+
+	// The topic only exists in the backup - as if it was deleted after the backup was taken
+	info := topicmeta.TopicInfo{
+		ID:             topicmeta.TopicIDSequenceBase + 100,
+		Name:           "topic1",
+		PartitionCount: 3,
+		RetentionTime:  time.Hour,
+	}
+	numBatches := 10
+	kvs := []common.KV{controller.topicMetaManager.TopicKV(info)}
+	for partitionID := 0; partitionID < info.PartitionCount; partitionID++ {
+		kvs = append(kvs, createTopicDataKVs(t, info.ID, partitionID, numBatches)...)
+	}
+	pushAndRegisterTable(t, kvs, controller)
+	backup, err := controller.CreateBackup()
+	require.NoError(t, err)
+
+	restored, err := controller.RestoreTopic(backup.ID, "topic1", "topic1-restored")
+	require.NoError(t, err)
+	require.Equal(t, "topic1-restored", restored.Name)
+	require.NotEqual(t, info.ID, restored.ID)
+	require.Equal(t, info.PartitionCount, restored.PartitionCount)
+	require.Equal(t, info.RetentionTime, restored.RetentionTime)
+
+	got, _, exists, err := controller.topicMetaManager.GetTopicInfo("topic1-restored")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, restored, got)
+
+	tg := &objStoreTableGetter{objStore: objStore, bucketName: bucketName}
+	for partitionID := 0; partitionID < info.PartitionCount; partitionID++ {
+		expected := createTopicDataKVs(t, restored.ID, partitionID, numBatches)
+		partHash, err := parthash.CreatePartitionHash(restored.ID, partitionID)
+		require.NoError(t, err)
+		iter, err := queryutils.CreateIteratorForKeyRange(partHash, common.IncBigEndianBytes(partHash),
+			controller.lsmHolder, tg.GetSSTable)
+		require.NoError(t, err)
+		var actual []common.KV
+		for {
+			ok, kv, err := iter.Next()
+			require.NoError(t, err)
+			if !ok {
+				break
+			}
+			actual = append(actual, kv)
+		}
+		iter.Close()
+		require.Equal(t, expected, actual)
+		offset, err := controller.offsetsCache.LoadHighestOffsetForPartition(restored.ID, partitionID)
+		require.NoError(t, err)
+		require.Equal(t, int64(numBatches-1), offset)
+	}
+
+	_, err = controller.RestoreTopic(backup.ID, "topic1", "topic1-restored")
+	require.Error(t, err)
+	require.True(t, common.IsTektiteErrorWithCode(err, common.TopicAlreadyExists))
+	_, err = controller.RestoreTopic(backup.ID, "unknown", "unknown-restored")
+	require.Error(t, err)
+	require.True(t, common.IsTektiteErrorWithCode(err, common.TopicDoesNotExist))
+	_, err = controller.RestoreTopic("unknown", "topic1", "topic1-restored-2")
+	require.ErrorIs(t, err, ErrBackupNotFound)
+}
+
+func TestRestoreBackup(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	controllers, _, tearDown := setupControllersWithObjectStore(t, 1, objStore)
+	updateMembership(t, 1, 1, controllers, 0)
+	controller := controllers[0]
+	cfg := controller.cfg
+
+	tableID1 := sst.CreateSSTableId()
+	putObject(t, objStore, cfg.SSTableBucketName, tableID1)
+	registerTable(t, controller, tableID1)
+	info, err := controller.CreateBackup()
+	require.NoError(t, err)
+	tableID2 := sst.CreateSSTableId()
+	putObject(t, objStore, cfg.SSTableBucketName, tableID2)
+	registerTable(t, controller, tableID2)
+	tearDown(t)
+
+	restored, err := RestoreBackup(objStore, cfg, info.ID)
+	require.NoError(t, err)
+	require.Equal(t, info, trimMonotonic([]BackupInfo{restored})[0])
+
+	controllers, _, tearDown = setupControllersWithObjectStore(t, 1, objStore)
+	defer tearDown(t)
+	updateMembership(t, 1, 1, controllers, 0)
+	registered, _, err := controllers[0].lsmHolder.GetTableIDs()
+	require.NoError(t, err)
+	require.Equal(t, []sst.SSTableID{[]byte(tableID1)}, registered)
+	// The backup still exists
+	backups, err := controllers[0].ListBackups()
+	require.NoError(t, err)
+	require.Equal(t, []BackupInfo{info}, trimMonotonic(backups))
+
+	_, err = RestoreBackup(objStore, cfg, "unknown")
+	require.ErrorIs(t, err, ErrBackupNotFound)
+	// Can't restore if tables are missing
+	err = objStore.Delete(context.Background(), cfg.SSTableBucketName, tableID1)
+	require.NoError(t, err)
+	_, err = RestoreBackup(objStore, cfg, info.ID)
+	require.Error(t, err)
+}
+
+func pushAndRegisterTable(t *testing.T, kvs []common.KV, controller *Controller) {
+	builder := &restoreTableBuilder{
+		objStore:   controller.objStoreClient,
+		bucketName: controller.cfg.SSTableBucketName,
+		format:     controller.cfg.DataFormat,
+	}
+	for _, kv := range kvs {
+		err := builder.addKV(kv)
+		require.NoError(t, err)
+	}
+	err := builder.flush()
+	require.NoError(t, err)
+	ch := make(chan error, 1)
+	err = controller.lsmHolder.ApplyLsmChanges(lsm.RegistrationBatch{Registrations: builder.registrations},
+		func(err error) error {
+			ch <- err
+			return nil
+		})
+	require.NoError(t, err)
+	err = <-ch
+	require.NoError(t, err)
+}
+
+func createTopicDataKVs(t *testing.T, topicID int, partitionID int, numBatches int) []common.KV {
+	partHash, err := parthash.CreatePartitionHash(topicID, partitionID)
+	require.NoError(t, err)
+	var kvs []common.KV
+	for i := 0; i < numBatches; i++ {
+		key := append(common.ByteSliceCopy(partHash), common.EntryTypeTopicData)
+		key = encoding.KeyEncodeInt(key, int64(i))
+		key = encoding.EncodeVersion(key, uint64(i))
+		value := []byte(fmt.Sprintf("batch-%d-%d", partitionID, i))
+		value = common.AppendValueMetadata(value, int64(topicID), int64(partitionID))
+		kvs = append(kvs, common.KV{Key: key, Value: value})
+	}
+	return kvs
+}
+
+// trimMonotonic removes the monotonic clock reading from the create times, which is lost when a backup is read
+func trimMonotonic(infos []BackupInfo) []BackupInfo {
+	for i := range infos {
+		infos[i].CreateTime = infos[i].CreateTime.Round(0)
+	}
+	return infos
+}
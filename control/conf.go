@@ -1,7 +1,9 @@
 package control
 
 import (
+	"github.com/pkg/errors"
 	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/compress"
 	"github.com/spirit-labs/tektite/lsm"
 	"time"
 )
@@ -16,6 +18,9 @@ type Conf struct {
 	SequencesBlockSize           int
 	AzInfo                       string
 	LsmStateWriteInterval        time.Duration
+	TableGCInterval              time.Duration
+	TableGCGracePeriod           time.Duration
+	TableCompressionType         compress.CompressionType
 }
 
 func NewConf() Conf {
@@ -28,6 +33,8 @@ func NewConf() Conf {
 		LsmConf:                      lsm.NewConf(),
 		SequencesBlockSize:           100,
 		LsmStateWriteInterval:        10 * time.Millisecond,
+		TableGCInterval:              10 * time.Minute,
+		TableGCGracePeriod:           1 * time.Hour,
 	}
 }
 
@@ -35,5 +42,11 @@ func (c *Conf) Validate() error {
 	if err := c.LsmConf.Validate(); err != nil {
 		return err
 	}
+	if c.TableGCInterval < 0 {
+		return errors.Errorf("invalid table gc interval %s - must be >= 0", c.TableGCInterval)
+	}
+	if c.TableGCGracePeriod <= 0 {
+		return errors.Errorf("invalid table gc grace period %s - must be > 0", c.TableGCGracePeriod)
+	}
 	return nil
 }
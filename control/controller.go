@@ -37,6 +37,8 @@ type Controller struct {
 	connFactory                transport.ConnectionFactory
 	transportServer            transport.Server
 	lsmHolder                  *LsmHolder
+	tableGC                    *tableGC
+	backups                    *backupManager
 	offsetsCache               *offsets.Cache
 	topicMetaManager           *topicmeta.Manager
 	currentMembership          cluster.MembershipState
@@ -45,6 +47,7 @@ type Controller struct {
 	groupCoordinatorController *CoordinatorController
 	aclManager                 *AclManager
 	tableGetter                sst.TableGetter
+	tableRegisteredListener    func(tableIDs []sst.SSTableID)
 	sequences                  *Sequences
 	memberID                   int32
 	activateClusterVersion     int64
@@ -122,6 +125,24 @@ func (c *Controller) SetTableGetter(getter sst.TableGetter) {
 	c.tableGetter = getter
 }
 
+// SetTableRegisteredListener sets a function that is called with the ids of tables after they have been registered
+// in the LSM
+func (c *Controller) SetTableRegisteredListener(listener func(tableIDs []sst.SSTableID)) {
+	c.tableRegisteredListener = listener
+}
+
+func (c *Controller) tablesRegistered(regBatch *lsm.RegistrationBatch) {
+	if c.tableRegisteredListener == nil || len(regBatch.Registrations) == 0 {
+		return
+	}
+	tableIDs := make([]sst.SSTableID, len(regBatch.Registrations))
+	for i, reg := range regBatch.Registrations {
+		// copy, as the id can refer to the request buffer
+		tableIDs[i] = common.ByteSliceCopy(reg.TableID)
+	}
+	c.tableRegisteredListener(tableIDs)
+}
+
 func (c *Controller) GetActivateClusterVersion() int {
 	return int(atomic.LoadInt64(&c.activateClusterVersion))
 }
@@ -138,8 +159,108 @@ func (c *Controller) LsmManager() *lsm.Manager {
 	return c.lsmHolder.lsmManager
 }
 
+// RunTableGC runs the table garbage collector now, deleting orphaned tables from the object store unless dryRun is
+// true. It can only be run on the leader.
+func (c *Controller) RunTableGC(dryRun bool) (TableGCReport, error) {
+	c.lock.RLock()
+	gc := c.tableGC
+	c.lock.RUnlock()
+	if gc == nil {
+		return TableGCReport{}, common.NewTektiteErrorf(common.Unavailable, "controller is not leader")
+	}
+	return gc.run(dryRun)
+}
+
+// CreateBackup creates a backup of the cluster, which retains the tables it references until it is deleted. It can
+// only be run on the leader.
+func (c *Controller) CreateBackup() (BackupInfo, error) {
+	backups, err := c.getBackupManager()
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	return backups.create()
+}
+
+// ListBackups lists the backups of the cluster in the order they were created. It can only be run on the leader.
+func (c *Controller) ListBackups() ([]BackupInfo, error) {
+	backups, err := c.getBackupManager()
+	if err != nil {
+		return nil, err
+	}
+	return backups.list(), nil
+}
+
+// DeleteBackup deletes a backup, releasing the tables it references. It can only be run on the leader.
+func (c *Controller) DeleteBackup(backupID string) error {
+	backups, err := c.getBackupManager()
+	if err != nil {
+		return err
+	}
+	return backups.delete(backupID)
+}
+
+// RestoreTopic restores a topic as of a backup to a new topic with the name restoredName, which must not exist. It can
+// only be run on the leader.
+func (c *Controller) RestoreTopic(backupID string, topicName string, restoredName string) (topicmeta.TopicInfo, error) {
+	// The lock must not be held while the tables are registered, as registration can wait for compaction to free
+	// space in L0
+	c.lock.RLock()
+	backups, topicMetaManager, lsmHolder := c.backups, c.topicMetaManager, c.lsmHolder
+	c.lock.RUnlock()
+	if backups == nil {
+		return topicmeta.TopicInfo{}, common.NewTektiteErrorf(common.Unavailable, "controller is not leader")
+	}
+	bk, err := backups.get(backupID)
+	if err != nil {
+		return topicmeta.TopicInfo{}, err
+	}
+	info, regBatch, err := restoreTopic(bk, topicName, restoredName, c.objStoreClient, c.cfg.SSTableBucketName,
+		c.cfg.DataFormat, c.cfg.TableCompressionType, topicMetaManager)
+	if err != nil {
+		return topicmeta.TopicInfo{}, err
+	}
+	err = topicMetaManager.AddRestoredTopic(info, func() error {
+		ch := make(chan error, 1)
+		if err := lsmHolder.ApplyLsmChanges(regBatch, func(err error) error {
+			ch <- err
+			return nil
+		}); err != nil {
+			return err
+		}
+		select {
+		case err := <-ch:
+			if err != nil {
+				return err
+			}
+		case <-time.After(restoreRegisterTimeout):
+			return errors.Errorf("timed out waiting for tables of restored topic %s to be registered", restoredName)
+		}
+		c.tablesRegistered(&regBatch)
+		return nil
+	})
+	if err != nil {
+		return topicmeta.TopicInfo{}, err
+	}
+	log.Infof("restored topic %s from backup %s as topic %s with id %d", topicName, backupID, restoredName, info.ID)
+	return info, nil
+}
+
+func (c *Controller) getBackupManager() (*backupManager, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.backups == nil {
+		return nil, common.NewTektiteErrorf(common.Unavailable, "controller is not leader")
+	}
+	return c.backups, nil
+}
+
 func (c *Controller) stop() error {
 	log.Debugf("controller %d is stopping", c.memberID)
+	if c.tableGC != nil {
+		c.tableGC.stop()
+		c.tableGC = nil
+	}
+	c.backups = nil
 	if c.lsmHolder != nil {
 		if err := c.lsmHolder.Stop(); err != nil {
 			return err
@@ -191,10 +312,17 @@ func (c *Controller) MembershipChanged(thisMemberID int32, newState cluster.Memb
 			log.Infof("%p controller %d activating as leader, newState %v", c, thisMemberID, newState)
 			lsmHolder := NewLsmHolder(c.cfg.ControllerMetaDataBucketName, c.cfg.ControllerMetaDataKey, c.objStoreClient,
 				c.cfg.LsmStateWriteInterval, c.cfg.LsmConf)
+			// Tables referenced by backups must be retained from the start
+			backups := newBackupManager(c.objStoreClient, c.cfg.SSTableBucketName, lsmHolder)
+			if err := backups.load(); err != nil {
+				return err
+			}
+			lsmHolder.SetTableRetainedFunc(backups.isRetained)
 			if err := lsmHolder.Start(); err != nil {
 				return err
 			}
 			c.lsmHolder = lsmHolder
+			c.backups = backups
 			topicMetaManager, err := topicmeta.NewManager(lsmHolder, c.objStoreClient, c.cfg.SSTableBucketName, c.cfg.DataFormat,
 				c.connCaches, c.sendDirectWrite)
 			if err != nil {
@@ -223,6 +351,9 @@ func (c *Controller) MembershipChanged(thisMemberID int32, newState cluster.Memb
 				return err
 			}
 			c.aclManager = aclManager
+			c.tableGC = newTableGC(c.objStoreClient, c.cfg.SSTableBucketName, lsmHolder, backups.retainedTableIDs,
+				c.cfg.TableGCInterval, c.cfg.TableGCGracePeriod)
+			c.tableGC.start()
 		}
 	} else {
 		// This controller is not leader
@@ -329,6 +460,7 @@ func (c *Controller) handleRegisterL0Table(_ *transport.ConnectionContext, reque
 		if err != nil {
 			return responseWriter(nil, err)
 		}
+		c.tablesRegistered(&regBatch)
 		// Send back zero byte to represent nil OK response
 		responseBuff = append(responseBuff, 0)
 		return responseWriter(responseBuff, nil)
@@ -373,6 +505,7 @@ func (c *Controller) handleApplyChanges(_ *transport.ConnectionContext, request
 		if err != nil {
 			return responseWriter(nil, err)
 		}
+		c.tablesRegistered(&req.RegBatch)
 		// Send back zero byte to represent nil OK response
 		responseBuff = append(responseBuff, 0)
 		return responseWriter(responseBuff, nil)
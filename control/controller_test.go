@@ -134,6 +134,14 @@ func TestControllerApplyChanges(t *testing.T) {
 	controllers, tearDown := setupControllers(t, 1)
 	defer tearDown(t)
 
+	var registeredLock sync.Mutex
+	var registered []sst.SSTableID
+	controllers[0].SetTableRegisteredListener(func(tableIDs []sst.SSTableID) {
+		registeredLock.Lock()
+		defer registeredLock.Unlock()
+		registered = append(registered, tableIDs...)
+	})
+
 	updateMembership(t, 1, 1, controllers, 0)
 
 	cl, err := controllers[0].Client()
@@ -151,6 +159,10 @@ func TestControllerApplyChanges(t *testing.T) {
 	err = cl.ApplyLsmChanges(batch)
 	require.NoError(t, err)
 
+	registeredLock.Lock()
+	require.Equal(t, []sst.SSTableID{tableID}, registered)
+	registeredLock.Unlock()
+
 	res, err := cl.QueryTablesInRange(keyStart, keyEnd)
 	require.NoError(t, err)
 
@@ -32,6 +32,7 @@ type LsmHolder struct {
 	stateWriteTimer        *time.Timer
 	stateWriteInterval     time.Duration
 	metaDataEtag           string
+	tableRetained          func(tableID sst.SSTableID) bool
 }
 
 type queuedRegistration struct {
@@ -61,6 +62,9 @@ func (s *LsmHolder) Start() error {
 		return err
 	}
 	lsmManager := lsm.NewManager(s.objStore, s.maybeRetryApplies, true, false, s.lsmOpts)
+	if s.tableRetained != nil {
+		lsmManager.SetTableRetainedFunc(s.tableRetained)
+	}
 	if err := lsmManager.Start(metaData); err != nil {
 		return err
 	}
@@ -71,6 +75,14 @@ func (s *LsmHolder) Start() error {
 	return nil
 }
 
+// SetTableRetainedFunc sets a function which determines whether tables which are no longer registered are retained
+// in the object store - see lsm.Manager.SetTableRetainedFunc. It must be called before Start.
+func (s *LsmHolder) SetTableRetainedFunc(tableRetained func(tableID sst.SSTableID) bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.tableRetained = tableRetained
+}
+
 const objectStoreCallTimeout = 5 * time.Second
 
 func (s *LsmHolder) loadMetadata() ([]byte, string, error) {
@@ -153,6 +165,37 @@ func (s *LsmHolder) GetTablesForHighestKeyWithPrefix(prefix []byte) ([]sst.SSTab
 	return s.lsmManager.GetTablesForHighestKeyWithPrefix(prefix)
 }
 
+// GetTableIDs returns the ids of the tables registered in the LSM, and the ids of tables which exist but are not
+// registered - those waiting to be deleted, and those in L0 registrations which are queued waiting for free space
+func (s *LsmHolder) GetTableIDs() ([]sst.SSTableID, []sst.SSTableID, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if err := s.checkStarted(); err != nil {
+		return nil, nil, err
+	}
+	registered, unregistered, err := s.lsmManager.GetTableIDs()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, queuedReg := range s.queuedRegistrations {
+		for _, reg := range queuedReg.regBatch.Registrations {
+			unregistered = append(unregistered, reg.TableID)
+		}
+	}
+	return registered, unregistered, nil
+}
+
+// GetMasterRecordBytes returns the serialized master record of the LSM. Note that it can contain registrations which
+// have not been written to object storage yet.
+func (s *LsmHolder) GetMasterRecordBytes() ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if err := s.checkStarted(); err != nil {
+		return nil, err
+	}
+	return s.lsmManager.GetMasterRecordBytes(), nil
+}
+
 // ApplyLsmChanges - apply some changes to the LSM structure. Note that this method completes asynchronously as
 // L0 registrations will be queued if there is not enough free space
 func (s *LsmHolder) ApplyLsmChanges(regBatch lsm.RegistrationBatch, completionFunc func(error) error) error {
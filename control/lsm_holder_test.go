@@ -207,6 +207,38 @@ func TestApplyChangesL0Full(t *testing.T) {
 	}
 }
 
+func TestGetTableIDs(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	opts := lsm.Conf{
+		L0MaxTablesBeforeBlocking: 2,
+	}
+	holder := NewLsmHolder(metaDataBucketName, metaDataKey, objStore, stateWriteInterval, opts)
+	err := holder.Start()
+	require.NoError(t, err)
+	defer func() {
+		err := holder.Stop()
+		require.NoError(t, err)
+	}()
+
+	keyStart := []byte("key000001")
+	keyEnd := []byte("key000010")
+	var tabIDs []sst.SSTableID
+	for i := 0; i < 3; i++ {
+		tableID := []byte(uuid.New().String())
+		tabIDs = append(tabIDs, tableID)
+		err := holder.ApplyLsmChanges(createBatch(0, tableID, keyStart, keyEnd), func(err error) error {
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	// The last registration is queued as L0 is full
+	registered, unregistered, err := holder.GetTableIDs()
+	require.NoError(t, err)
+	require.ElementsMatch(t, tabIDs[:2], registered)
+	require.Equal(t, []sst.SSTableID{tabIDs[2]}, unregistered)
+}
+
 func createBatch(level int, tableID []byte, keyStart []byte, keyEnd []byte) lsm.RegistrationBatch {
 	regEntry := lsm.RegistrationEntry{
 		Level:      level,
@@ -0,0 +1,190 @@
+package control
+
+import (
+	"github.com/pkg/errors"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/sst"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	tableGCListTimeout     = 1 * time.Minute
+	tableGCDeleteBatchSize = 1000
+)
+
+// TableGCReport is the result of a run of the table garbage collector
+type TableGCReport struct {
+	DryRun bool `json:"dry_run"`
+	// TablesScanned is the number of table objects in the data bucket
+	TablesScanned int `json:"tables_scanned"`
+	// RegisteredTables is the number of tables registered in the LSM
+	RegisteredTables int `json:"registered_tables"`
+	// OrphanedTables are table objects which are not referenced by the LSM and are older than the grace period. They
+	// are deleted unless it is a dry run.
+	OrphanedTables []string `json:"orphaned_tables"`
+	// DanglingReferences are tables registered in the LSM which do not exist in the data bucket
+	DanglingReferences []string `json:"dangling_references"`
+}
+
+/*
+tableGC deletes orphaned tables from the data bucket. A table is orphaned when it was written to the object store but
+never registered in the LSM, e.g. if an agent crashed between pushing a table and registering it, or when it was
+deregistered but the controller failed before deleting it. Such tables are never deleted by the LSM manager, which
+only deletes tables it knows about.
+
+The data bucket is listed and diffed against the tables referenced by the LSM. As a table is written before it is
+registered, tables modified less than the grace period ago are never considered orphaned - the grace period must be
+longer than any table could take to be registered after it was written. Tables retained by backups are never
+considered orphaned. Only objects with the table id prefix are considered, so other objects stored in the bucket, such
+as the controller metadata and backups, are not touched.
+
+A run can also be a dry run ("fsck") which deletes nothing, but reports orphaned tables and dangling references -
+tables registered in the LSM which are missing from the bucket.
+*/
+type tableGC struct {
+	runLock     sync.Mutex
+	lock        sync.Mutex
+	objStore    objstore.Client
+	bucketName  string
+	lsmHolder   *LsmHolder
+	retained    func() []string
+	interval    time.Duration
+	gracePeriod time.Duration
+	timer       *time.Timer
+	stopped     atomic.Bool
+}
+
+func newTableGC(objStore objstore.Client, bucketName string, lsmHolder *LsmHolder, retained func() []string,
+	interval time.Duration, gracePeriod time.Duration) *tableGC {
+	return &tableGC{
+		objStore:    objStore,
+		bucketName:  bucketName,
+		lsmHolder:   lsmHolder,
+		retained:    retained,
+		interval:    interval,
+		gracePeriod: gracePeriod,
+	}
+}
+
+func (g *tableGC) start() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.interval > 0 {
+		g.scheduleRun()
+	}
+}
+
+func (g *tableGC) stop() {
+	g.stopped.Store(true)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+}
+
+func (g *tableGC) scheduleRun() {
+	g.timer = time.AfterFunc(g.interval, func() {
+		report, err := g.run(false)
+		if err != nil {
+			log.Warnf("failed to garbage collect tables: %v", err)
+		} else if len(report.OrphanedTables) > 0 {
+			log.Infof("deleted %d orphaned tables", len(report.OrphanedTables))
+		}
+		if len(report.DanglingReferences) > 0 {
+			log.Errorf("found %d tables registered in the LSM which do not exist in the object store: %v",
+				len(report.DanglingReferences), report.DanglingReferences)
+		}
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		if !g.stopped.Load() {
+			g.scheduleRun()
+		}
+	})
+}
+
+func (g *tableGC) run(dryRun bool) (TableGCReport, error) {
+	// Only one run at a time
+	g.runLock.Lock()
+	defer g.runLock.Unlock()
+	report := TableGCReport{DryRun: dryRun}
+	if g.stopped.Load() {
+		return report, errors.New("table gc is stopped")
+	}
+	// A table is written before it is registered, and deregistered before it is deleted, so we get the registered
+	// tables before listing the bucket to find dangling references, and after listing it to find orphans. Otherwise,
+	// tables written and registered in between would be reported as dangling, or tables written before listing but
+	// registered after it as orphaned.
+	registeredBefore, _, err := g.lsmHolder.GetTableIDs()
+	if err != nil {
+		return report, err
+	}
+	infos, err := objstore.ListObjectsWithPrefixWithTimeout(g.objStore, g.bucketName, sst.SSTableIDPrefix, -1,
+		tableGCListTimeout)
+	if err != nil {
+		return report, err
+	}
+	registered, unregistered, err := g.lsmHolder.GetTableIDs()
+	if err != nil {
+		return report, err
+	}
+	report.TablesScanned = len(infos)
+	report.RegisteredTables = len(registered)
+	referenced := make(map[string]struct{}, len(registered)+len(unregistered))
+	for _, tableID := range registered {
+		referenced[string(tableID)] = struct{}{}
+	}
+	for _, tableID := range unregistered {
+		referenced[string(tableID)] = struct{}{}
+	}
+	// Tables referenced by backups are retained
+	for _, tableID := range g.retained() {
+		referenced[tableID] = struct{}{}
+	}
+	now := time.Now()
+	listed := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		listed[info.Key] = struct{}{}
+		if _, ok := referenced[info.Key]; ok {
+			continue
+		}
+		if now.Sub(info.LastModified) < g.gracePeriod {
+			continue
+		}
+		report.OrphanedTables = append(report.OrphanedTables, info.Key)
+	}
+	registeredAfter := make(map[string]struct{}, len(registered))
+	for _, tableID := range registered {
+		registeredAfter[string(tableID)] = struct{}{}
+	}
+	for _, tableID := range registeredBefore {
+		sTableID := string(tableID)
+		if _, ok := listed[sTableID]; ok {
+			continue
+		}
+		// A table deregistered since is not dangling - it could have been deleted
+		if _, ok := registeredAfter[sTableID]; ok {
+			report.DanglingReferences = append(report.DanglingReferences, sTableID)
+		}
+	}
+	sort.Strings(report.DanglingReferences)
+	if dryRun || len(report.OrphanedTables) == 0 {
+		return report, nil
+	}
+	for start := 0; start < len(report.OrphanedTables); start += tableGCDeleteBatchSize {
+		if g.stopped.Load() {
+			// We could no longer be leader so must not delete anything else
+			return report, errors.New("table gc is stopped")
+		}
+		batch := report.OrphanedTables[start:min(start+tableGCDeleteBatchSize, len(report.OrphanedTables))]
+		log.Infof("deleting orphaned tables %v", batch)
+		if err := objstore.DeleteAllWithTimeout(g.objStore, g.bucketName, batch, objstore.DefaultCallTimeout); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
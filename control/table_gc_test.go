@@ -0,0 +1,130 @@
+package control
+
+import (
+	"context"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/spirit-labs/tektite/sst"
+	"github.com/spirit-labs/tektite/testutils"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTableGC(t *testing.T) {
+	gracePeriod := 100 * time.Millisecond
+	objStore := dev.NewInMemStore(0)
+	controllers, _, tearDown := setupControllersWithObjectStoreAndConfigSetter(t, 1, objStore, func(conf *Conf) {
+		// No periodic runs
+		conf.TableGCInterval = 0
+		conf.TableGCGracePeriod = gracePeriod
+	})
+	defer tearDown(t)
+	updateMembership(t, 1, 1, controllers, 0)
+	controller := controllers[0]
+	bucketName := controller.cfg.SSTableBucketName
+
+	registeredID := sst.CreateSSTableId()
+	orphanID := sst.CreateSSTableId()
+	danglingID := sst.CreateSSTableId()
+	putObject(t, objStore, bucketName, registeredID)
+	// Objects which aren't tables must not be deleted
+	putObject(t, objStore, bucketName, "not-a-table")
+	registerTable(t, controller, registeredID)
+	// Registered but never written
+	registerTable(t, controller, danglingID)
+	// Written after registering, so it's younger than the grace period however long registering takes
+	putObject(t, objStore, bucketName, orphanID)
+
+	// Nothing is older than the grace period yet
+	report, err := controller.RunTableGC(false)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.TablesScanned)
+	require.Equal(t, 2, report.RegisteredTables)
+	require.Equal(t, 0, len(report.OrphanedTables))
+	require.Equal(t, []string{danglingID}, report.DanglingReferences)
+
+	time.Sleep(gracePeriod)
+	youngOrphanID := sst.CreateSSTableId()
+	putObject(t, objStore, bucketName, youngOrphanID)
+
+	// A dry run deletes nothing
+	report, err = controller.RunTableGC(true)
+	require.NoError(t, err)
+	require.True(t, report.DryRun)
+	require.Equal(t, 3, report.TablesScanned)
+	require.Equal(t, []string{orphanID}, report.OrphanedTables)
+	require.Equal(t, []string{danglingID}, report.DanglingReferences)
+	requireObjectsExist(t, objStore, bucketName, registeredID, orphanID, youngOrphanID, "not-a-table")
+
+	report, err = controller.RunTableGC(false)
+	require.NoError(t, err)
+	require.False(t, report.DryRun)
+	require.Equal(t, []string{orphanID}, report.OrphanedTables)
+	requireObjectsExist(t, objStore, bucketName, registeredID, youngOrphanID, "not-a-table")
+	v, err := objStore.Get(context.Background(), bucketName, orphanID)
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	// The young orphan is deleted once it's older than the grace period
+	time.Sleep(gracePeriod)
+	report, err = controller.RunTableGC(false)
+	require.NoError(t, err)
+	require.Equal(t, []string{youngOrphanID}, report.OrphanedTables)
+	requireObjectsExist(t, objStore, bucketName, registeredID, "not-a-table")
+}
+
+func TestTableGCPeriodic(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	controllers, _, tearDown := setupControllersWithObjectStoreAndConfigSetter(t, 1, objStore, func(conf *Conf) {
+		conf.TableGCInterval = 10 * time.Millisecond
+		conf.TableGCGracePeriod = 10 * time.Millisecond
+	})
+	defer tearDown(t)
+	updateMembership(t, 1, 1, controllers, 0)
+	bucketName := controllers[0].cfg.SSTableBucketName
+
+	orphanID := sst.CreateSSTableId()
+	putObject(t, objStore, bucketName, orphanID)
+	testutils.WaitUntil(t, func() (bool, error) {
+		v, err := objStore.Get(context.Background(), bucketName, orphanID)
+		return v == nil, err
+	})
+}
+
+func TestTableGCNotLeader(t *testing.T) {
+	controllers, tearDown := setupControllers(t, 2)
+	defer tearDown(t)
+	updateMembership(t, 1, 1, controllers, 0, 1)
+
+	_, err := controllers[1].RunTableGC(true)
+	require.Error(t, err)
+	require.True(t, common.IsTektiteErrorWithCode(err, common.Unavailable))
+
+	_, err = controllers[0].RunTableGC(true)
+	require.NoError(t, err)
+}
+
+func putObject(t *testing.T, objStore *dev.InMemStore, bucketName string, key string) {
+	err := objStore.Put(context.Background(), bucketName, key, []byte("foo"))
+	require.NoError(t, err)
+}
+
+func registerTable(t *testing.T, controller *Controller, tableID string) {
+	cl, err := controller.Client()
+	require.NoError(t, err)
+	defer func() {
+		err := cl.Close()
+		require.NoError(t, err)
+	}()
+	err = cl.ApplyLsmChanges(createBatch(0, []byte(tableID), []byte("key000001"), []byte("key000010")))
+	require.NoError(t, err)
+}
+
+func requireObjectsExist(t *testing.T, objStore *dev.InMemStore, bucketName string, keys ...string) {
+	for _, key := range keys {
+		v, err := objStore.Get(context.Background(), bucketName, key)
+		require.NoError(t, err)
+		require.NotNil(t, v, "object %s does not exist", key)
+	}
+}
@@ -70,6 +70,9 @@ func NewBatchFromBytes(schema *EventSchema, rowCount int, bytes [][]byte) *Batch
 		case types.ColumnTypeIDTimestamp:
 			cols[i] = NewTimestampColumnFromBytes(bytes[buffPos:buffPos+2], rowCount)
 			buffPos += 2
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			cols[i] = NewNestedColumnFromBytes(bytes[buffPos:buffPos+3], rowCount, columnType)
+			buffPos += 3
 		default:
 			panic("unexpected type")
 		}
@@ -96,6 +99,8 @@ func (b *Batch) ToBytes() [][]byte {
 			mBuffs = c.array.Data().Buffers()
 		case *TimestampColumn:
 			mBuffs = c.array.Data().Buffers()
+		case *NestedColumn:
+			mBuffs = c.array.Data().Buffers()
 		default:
 			panic("unknown type")
 		}
@@ -170,6 +175,10 @@ func (b *Batch) GetBytesColumn(colIndex int) *BytesColumn {
 	return b.Columns[colIndex].(*BytesColumn)
 }
 
+func (b *Batch) GetNestedColumn(colIndex int) *NestedColumn {
+	return b.Columns[colIndex].(*NestedColumn)
+}
+
 func (b *Batch) GetTimestampColumn(colIndex int) *TimestampColumn {
 	return b.Columns[colIndex].(*TimestampColumn)
 }
@@ -648,6 +657,86 @@ func (ic *TimestampColumn) Len() int {
 	return ic.array.Len()
 }
 
+// NewNestedColBuilder creates a builder for an array, map or struct column. Each value is held in its encoded form,
+// see encoding.AppendNestedToBufferLE.
+func NewNestedColBuilder(nestedType types.ColumnType) *NestedColBuilder {
+	allocator := memory.NewGoAllocator()
+	builder := array.NewBinaryBuilder(allocator, arrow.BinaryTypes.Binary)
+	return &NestedColBuilder{
+		nestedType: nestedType,
+		builder:    builder,
+	}
+}
+
+type NestedColBuilder struct {
+	nestedType types.ColumnType
+	builder    *array.BinaryBuilder
+	buff       []byte
+}
+
+func (ib *NestedColBuilder) AppendNull() {
+	ib.builder.AppendNull()
+}
+
+func (ib *NestedColBuilder) Append(val any) {
+	ib.buff = encoding.AppendNestedToBufferLE(ib.buff[:0], ib.nestedType, val)
+	ib.builder.Append(ib.buff)
+}
+
+func (ib *NestedColBuilder) AppendEncoded(val []byte) {
+	ib.builder.Append(val)
+}
+
+func (ib *NestedColBuilder) BuildNestedColumn() *NestedColumn {
+	return &NestedColumn{nestedType: ib.nestedType, array: ib.builder.NewBinaryArray()}
+}
+
+func (ib *NestedColBuilder) Build() Column {
+	return ib.BuildNestedColumn()
+}
+
+var _ Column = &NestedColumn{}
+
+type NestedColumn struct {
+	nestedType types.ColumnType
+	array      *array.Binary
+}
+
+func NewNestedColumnFromBytes(bytes [][]byte, length int, nestedType types.ColumnType) *NestedColumn {
+	mbs := bytesToMBuffs(bytes)
+	data := array.NewData(&arrow.BinaryType{}, length, mbs, nil, 0, 0)
+	arr := array.NewBinaryData(data)
+	ic := &NestedColumn{nestedType: nestedType, array: arr}
+	return ic
+}
+
+func (ic *NestedColumn) Retain() {
+	ic.array.Retain()
+}
+
+func (ic *NestedColumn) Release() {
+	ic.array.Release()
+}
+
+// Get decodes the value at the row - []any for an array or struct and map[string]any for a map.
+func (ic *NestedColumn) Get(row int) any {
+	val, _ := encoding.ReadNestedFromBufferLE(ic.array.Value(row), 0, ic.nestedType)
+	return val
+}
+
+// GetEncoded returns the encoded value at the row without decoding it.
+func (ic *NestedColumn) GetEncoded(row int) []byte {
+	return ic.array.Value(row)
+}
+
+func (ic *NestedColumn) IsNull(row int) bool {
+	return ic.array.IsNull(row)
+}
+
+func (ic *NestedColumn) Len() int {
+	return ic.array.Len()
+}
+
 func CreateColBuilders(columnTypes []types.ColumnType) []ColumnBuilder {
 	colBuilders := make([]ColumnBuilder, len(columnTypes))
 	for colIndex, ft := range columnTypes {
@@ -666,6 +755,8 @@ func CreateColBuilders(columnTypes []types.ColumnType) []ColumnBuilder {
 			colBuilders[colIndex] = NewBytesColBuilder()
 		case types.ColumnTypeIDTimestamp:
 			colBuilders[colIndex] = NewTimestampColBuilder()
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			colBuilders[colIndex] = NewNestedColBuilder(ft)
 		default:
 			panic(fmt.Sprintf("unknown column type %d", ft.ID()))
 		}
@@ -703,6 +794,8 @@ func CopyColumnEntryWithCol(ft types.ColumnType, col Column, colBuilder ColumnBu
 		colBuilder.(*BytesColBuilder).Append(col.(*BytesColumn).Get(rowIndex))
 	case types.ColumnTypeIDTimestamp:
 		colBuilder.(*TimestampColBuilder).Append(col.(*TimestampColumn).Get(rowIndex))
+	case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+		colBuilder.(*NestedColBuilder).AppendEncoded(col.(*NestedColumn).GetEncoded(rowIndex))
 	default:
 		panic(fmt.Sprintf("unknown column type %d", ft.ID()))
 	}
@@ -768,6 +861,11 @@ func (b *Batch) Equal(other *Batch) bool {
 				if col1.(*TimestampColumn).Get(i).Val != col2.(*TimestampColumn).Get(i).Val {
 					return false
 				}
+			case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+				// Nested values have a canonical encoding so can be compared without decoding
+				if !bytes.Equal(col1.(*NestedColumn).GetEncoded(i), col2.(*NestedColumn).GetEncoded(i)) {
+					return false
+				}
 			default:
 				panic("unexpected type")
 			}
@@ -812,6 +910,8 @@ func (b *Batch) Dump() {
 				builder.WriteString(fmt.Sprintf("%v", col.(*BytesColumn).Get(i)))
 			case types.ColumnTypeIDTimestamp:
 				builder.WriteString(fmt.Sprintf("%d", col.(*TimestampColumn).Get(i).Val))
+			case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+				builder.WriteString(fmt.Sprintf("%v", col.(*NestedColumn).Get(i)))
 			}
 			if j != len(b.Columns)-1 {
 				builder.WriteString(", ")
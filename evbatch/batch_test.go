@@ -101,3 +101,35 @@ func TestBatchBytes(t *testing.T) {
 
 	require.True(t, batch.Equal(batch2))
 }
+
+func TestNestedColumns(t *testing.T) {
+	arrType := &types.ArrayType{ElementType: types.ColumnTypeInt}
+	mapType := &types.MapType{ValueType: types.ColumnTypeString}
+	structType := &types.StructType{FieldNames: []string{"a", "b"},
+		FieldTypes: []types.ColumnType{types.ColumnTypeString, arrType}}
+	schema := NewEventSchema([]string{"f0", "f1", "f2"}, []types.ColumnType{arrType, mapType, structType})
+	builders := CreateColBuilders(schema.ColumnTypes())
+	for i := 0; i < 10; i++ {
+		builders[0].(*NestedColBuilder).Append([]any{int64(i), nil, int64(i + 1)})
+		builders[1].(*NestedColBuilder).Append(map[string]any{"k1": fmt.Sprintf("v%d", i), "k2": nil})
+		builders[2].(*NestedColBuilder).Append([]any{fmt.Sprintf("s%d", i), []any{int64(i)}})
+		for _, builder := range builders {
+			builder.AppendNull()
+		}
+	}
+	batch := NewBatchFromBuilders(schema, builders...)
+	defer batch.Release()
+	require.Equal(t, 20, batch.RowCount)
+	for i := 0; i < 10; i++ {
+		rowIndex := 2 * i
+		require.Equal(t, []any{int64(i), nil, int64(i + 1)}, batch.GetNestedColumn(0).Get(rowIndex))
+		require.Equal(t, map[string]any{"k1": fmt.Sprintf("v%d", i), "k2": nil}, batch.GetNestedColumn(1).Get(rowIndex))
+		require.Equal(t, []any{fmt.Sprintf("s%d", i), []any{int64(i)}}, batch.GetNestedColumn(2).Get(rowIndex))
+		for _, col := range batch.Columns {
+			require.True(t, col.IsNull(rowIndex+1))
+		}
+	}
+
+	batch2 := NewBatchFromBytes(schema, batch.RowCount, batch.ToBytes())
+	require.True(t, batch.Equal(batch2))
+}
@@ -38,6 +38,9 @@ func EncodeRowCols(batch *Batch, rowIndex int, rowCols []int, buffer []byte) []b
 		case types.ColumnTypeIDTimestamp:
 			val := (col.(*TimestampColumn)).Get(rowIndex)
 			buffer = encoding2.AppendUint64ToBufferLE(buffer, uint64(val.Val))
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			// The column already holds the row encoding of the value
+			buffer = append(buffer, (col.(*NestedColumn)).GetEncoded(rowIndex)...)
 		default:
 			panic(fmt.Sprintf("unexpected column type %d", ft))
 		}
@@ -85,6 +88,9 @@ func EncodeKeyCol(rowIndex int, col Column, colType types.ColumnType, buffer []b
 	case types.ColumnTypeIDTimestamp:
 		val := col.(*TimestampColumn).Get(rowIndex)
 		buffer = encoding2.KeyEncodeTimestamp(buffer, val)
+	case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+		val := col.(*NestedColumn).Get(rowIndex)
+		buffer = encoding2.KeyEncodeNested(buffer, colType, val)
 	default:
 		panic(fmt.Sprintf("unexpected column type %d", colType))
 	}
@@ -17,6 +17,8 @@ type Expression interface {
 	EvalString(rowIndex int, batch *evbatch.Batch) (string, bool, error)
 	EvalBytes(rowIndex int, batch *evbatch.Batch) ([]byte, bool, error)
 	EvalTimestamp(rowIndex int, batch *evbatch.Batch) (types.Timestamp, bool, error)
+	// EvalNested evaluates an expression with an array, map or struct result type
+	EvalNested(rowIndex int, batch *evbatch.Batch) (any, bool, error)
 	ResultType() types.ColumnType
 }
 
@@ -133,6 +135,9 @@ func createColumnExpr(desc *parser.IdentifierExprDesc, schema *evbatch.EventSche
 		}
 	}
 	if colIndex == -1 {
+		if fieldExpr, ok := createStructFieldExpr(desc.IdentifierName, schema); ok {
+			return fieldExpr, nil
+		}
 		return nil, desc.ErrorAtPosition("unknown column '%s'. (available columns: %s)", desc.IdentifierName, schema.String())
 	}
 	return NewColumnExpression(colIndex, colType), nil
@@ -308,6 +313,14 @@ func (f *ExpressionFactory) createFunctionOperator(desc *parser.FunctionExprDesc
 		return NewUint64LEFunction(args, desc)
 	case "abs":
 		return NewAbsFunction(args, desc)
+	case "element_at":
+		return NewElementAtFunction(args, desc)
+	case "size":
+		return NewSizeFunction(args, desc)
+	case "from_json":
+		return NewFromJsonFunction(args, desc)
+	case "to_json":
+		return NewToJsonFunction(args, desc)
 	default:
 		// External function
 		return NewExternalFunction(args, desc, f.ExternalInvokerFactory)
@@ -379,6 +392,14 @@ func (c *ColumnExpr) EvalTimestamp(rowIndex int, batch *evbatch.Batch) (types.Ti
 	return col.Get(rowIndex), false, nil
 }
 
+func (c *ColumnExpr) EvalNested(rowIndex int, batch *evbatch.Batch) (any, bool, error) {
+	col := batch.GetNestedColumn(c.colIndex)
+	if col.IsNull(rowIndex) {
+		return nil, true, nil
+	}
+	return col.Get(rowIndex), false, nil
+}
+
 func (c *ColumnExpr) ResultType() types.ColumnType {
 	return c.exprType
 }
@@ -489,3 +510,7 @@ func (b *baseExpr) EvalBytes(_ int, _ *evbatch.Batch) ([]byte, bool, error) {
 func (b *baseExpr) EvalTimestamp(_ int, _ *evbatch.Batch) (types.Timestamp, bool, error) {
 	panic("not supported")
 }
+
+func (b *baseExpr) EvalNested(_ int, _ *evbatch.Batch) (any, bool, error) {
+	panic("not supported")
+}
@@ -129,6 +129,17 @@ func (e *ExternalFunction) EvalTimestamp(rowIndex int, batch *evbatch.Batch) (ty
 	return r.(types.Timestamp), false, nil
 }
 
+func (e *ExternalFunction) EvalNested(rowIndex int, batch *evbatch.Batch) (any, bool, error) {
+	r, null, err := e.eval(rowIndex, batch)
+	if err != nil {
+		return nil, false, err
+	}
+	if null {
+		return nil, true, nil
+	}
+	return r, false, nil
+}
+
 func (e *ExternalFunction) ResultType() types.ColumnType {
 	return e.returnType
 }
@@ -154,6 +165,8 @@ func (e *ExternalFunction) eval(rowIndex int, batch *evbatch.Batch) (any, bool,
 			v, null, err = operand.EvalBytes(rowIndex, batch)
 		case types.ColumnTypeIDTimestamp:
 			v, null, err = operand.EvalTimestamp(rowIndex, batch)
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			v, null, err = operand.EvalNested(rowIndex, batch)
 		default:
 			panic("unexpected column type")
 		}
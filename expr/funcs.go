@@ -248,6 +248,20 @@ func (i *IfFunction) EvalTimestamp(rowIndex int, inBatch *evbatch.Batch) (types.
 	}
 }
 
+func (i *IfFunction) EvalNested(rowIndex int, inBatch *evbatch.Batch) (any, bool, error) {
+	testVal, null, err := i.testExpr.EvalBool(rowIndex, inBatch)
+	if err != nil {
+		return nil, false, err
+	}
+	if null {
+		return nil, true, nil
+	}
+	if testVal {
+		return i.trueExpr.EvalNested(rowIndex, inBatch)
+	}
+	return i.falseExpr.EvalNested(rowIndex, inBatch)
+}
+
 func (i *IfFunction) Eval() (evbatch.Column, error) {
 	panic("not supported")
 }
@@ -560,6 +574,10 @@ func NewCaseFunction(argExprs []Expression, desc *parser.FunctionExprDesc) (*Cas
 		return nil, desc.ErrorAtPosition("'case' function requires an even number of arguments - %d found", len(argExprs))
 	}
 	testExpr := argExprs[0]
+	if types.IsNestedType(testExpr.ResultType()) {
+		return nil, desc.ErrorAtPosition("'case' function test expression cannot be of type %s",
+			testExpr.ResultType().String())
+	}
 	defaultExpr := argExprs[len(argExprs)-1]
 	var caseExprs []Expression
 	var retExprs []Expression
@@ -927,6 +945,20 @@ func (c *CaseFunction) EvalTimestamp(rowIndex int, batch *evbatch.Batch) (types.
 	return retVal, null, nil
 }
 
+func (c *CaseFunction) EvalNested(rowIndex int, batch *evbatch.Batch) (any, bool, error) {
+	matchingIndex, null, err := c.getMatchingIndex(rowIndex, batch)
+	if err != nil {
+		return nil, false, err
+	}
+	if null {
+		return nil, true, nil
+	}
+	if matchingIndex == -1 {
+		return c.defaultExpr.EvalNested(rowIndex, batch)
+	}
+	return c.retExprs[matchingIndex].EvalNested(rowIndex, batch)
+}
+
 func (c *CaseFunction) ResultType() types.ColumnType {
 	return c.resultType
 }
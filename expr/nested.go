@@ -0,0 +1,332 @@
+package expr
+
+import (
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"strings"
+)
+
+// EvalValue evaluates an expression of any type, returning the value in the same representation as nested values.
+func EvalValue(expr Expression, rowIndex int, batch *evbatch.Batch) (any, bool, error) {
+	switch expr.ResultType().ID() {
+	case types.ColumnTypeIDInt:
+		return expr.EvalInt(rowIndex, batch)
+	case types.ColumnTypeIDFloat:
+		return expr.EvalFloat(rowIndex, batch)
+	case types.ColumnTypeIDBool:
+		return expr.EvalBool(rowIndex, batch)
+	case types.ColumnTypeIDDecimal:
+		return expr.EvalDecimal(rowIndex, batch)
+	case types.ColumnTypeIDString:
+		return expr.EvalString(rowIndex, batch)
+	case types.ColumnTypeIDBytes:
+		return expr.EvalBytes(rowIndex, batch)
+	case types.ColumnTypeIDTimestamp:
+		return expr.EvalTimestamp(rowIndex, batch)
+	case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+		return expr.EvalNested(rowIndex, batch)
+	default:
+		panic("unexpected column type")
+	}
+}
+
+// elementExpr is embedded by expressions which return an element of a nested value, and so can have any result type.
+type elementExpr struct {
+	resultType types.ColumnType
+	evalValue  func(rowIndex int, batch *evbatch.Batch) (any, bool, error)
+}
+
+func (e *elementExpr) EvalInt(rowIndex int, batch *evbatch.Batch) (int64, bool, error) {
+	v, null, err := e.evalValue(rowIndex, batch)
+	if err != nil || null {
+		return 0, null, err
+	}
+	return v.(int64), false, nil
+}
+
+func (e *elementExpr) EvalFloat(rowIndex int, batch *evbatch.Batch) (float64, bool, error) {
+	v, null, err := e.evalValue(rowIndex, batch)
+	if err != nil || null {
+		return 0, null, err
+	}
+	return v.(float64), false, nil
+}
+
+func (e *elementExpr) EvalBool(rowIndex int, batch *evbatch.Batch) (bool, bool, error) {
+	v, null, err := e.evalValue(rowIndex, batch)
+	if err != nil || null {
+		return false, null, err
+	}
+	return v.(bool), false, nil
+}
+
+func (e *elementExpr) EvalDecimal(rowIndex int, batch *evbatch.Batch) (types.Decimal, bool, error) {
+	v, null, err := e.evalValue(rowIndex, batch)
+	if err != nil || null {
+		return types.Decimal{}, null, err
+	}
+	return v.(types.Decimal), false, nil
+}
+
+func (e *elementExpr) EvalString(rowIndex int, batch *evbatch.Batch) (string, bool, error) {
+	v, null, err := e.evalValue(rowIndex, batch)
+	if err != nil || null {
+		return "", null, err
+	}
+	return v.(string), false, nil
+}
+
+func (e *elementExpr) EvalBytes(rowIndex int, batch *evbatch.Batch) ([]byte, bool, error) {
+	v, null, err := e.evalValue(rowIndex, batch)
+	if err != nil || null {
+		return nil, null, err
+	}
+	return v.([]byte), false, nil
+}
+
+func (e *elementExpr) EvalTimestamp(rowIndex int, batch *evbatch.Batch) (types.Timestamp, bool, error) {
+	v, null, err := e.evalValue(rowIndex, batch)
+	if err != nil || null {
+		return types.Timestamp{}, null, err
+	}
+	return v.(types.Timestamp), false, nil
+}
+
+func (e *elementExpr) EvalNested(rowIndex int, batch *evbatch.Batch) (any, bool, error) {
+	return e.evalValue(rowIndex, batch)
+}
+
+func (e *elementExpr) ResultType() types.ColumnType {
+	return e.resultType
+}
+
+// StructFieldExpr returns a field of a struct. It is created for identifiers of the form col.field1.field2 where col
+// is a column of struct type.
+type StructFieldExpr struct {
+	elementExpr
+	structExpr Expression
+	fieldIndex int
+}
+
+func createStructFieldExpr(identifier string, schema *evbatch.EventSchema) (Expression, bool) {
+	// Try the longest prefix first, as column names can themselves contain '.'
+	for pos := strings.LastIndexByte(identifier, '.'); pos > 0; pos = strings.LastIndexByte(identifier[:pos], '.') {
+		colName := identifier[:pos]
+		for i, cName := range schema.ColumnNames() {
+			if cName != colName {
+				continue
+			}
+			var expr Expression = NewColumnExpression(i, schema.ColumnTypes()[i])
+			for _, fieldName := range strings.Split(identifier[pos+1:], ".") {
+				structType, ok := expr.ResultType().(*types.StructType)
+				if !ok {
+					return nil, false
+				}
+				fieldIndex := structType.FieldIndex(fieldName)
+				if fieldIndex == -1 {
+					return nil, false
+				}
+				expr = newStructFieldExpr(expr, fieldIndex, structType.FieldTypes[fieldIndex])
+			}
+			return expr, true
+		}
+	}
+	return nil, false
+}
+
+func newStructFieldExpr(structExpr Expression, fieldIndex int, fieldType types.ColumnType) *StructFieldExpr {
+	s := &StructFieldExpr{structExpr: structExpr, fieldIndex: fieldIndex}
+	s.elementExpr = elementExpr{resultType: fieldType, evalValue: s.evalField}
+	return s
+}
+
+func (s *StructFieldExpr) evalField(rowIndex int, batch *evbatch.Batch) (any, bool, error) {
+	v, null, err := s.structExpr.EvalNested(rowIndex, batch)
+	if err != nil || null {
+		return nil, null, err
+	}
+	field := v.([]any)[s.fieldIndex]
+	return field, field == nil, nil
+}
+
+// ElementAtFunction returns the element of an array at a zero based index, or the value of a map for a key. The result
+// is null if the index is out of range or the key is not present.
+type ElementAtFunction struct {
+	elementExpr
+	collectionExpr Expression
+	keyExpr        Expression
+}
+
+func NewElementAtFunction(argExprs []Expression, desc *parser.FunctionExprDesc) (*ElementAtFunction, error) {
+	if len(argExprs) != 2 {
+		return nil, desc.ErrorAtPosition("'element_at' requires 2 arguments - %d found", len(argExprs))
+	}
+	var resultType types.ColumnType
+	switch t := argExprs[0].ResultType().(type) {
+	case *types.ArrayType:
+		if argExprs[1].ResultType() != types.ColumnTypeInt {
+			return nil, desc.ErrorAtPosition("'element_at' second argument must be of type int when first argument is an array - it is of type %s",
+				argExprs[1].ResultType().String())
+		}
+		resultType = t.ElementType
+	case *types.MapType:
+		if argExprs[1].ResultType() != types.ColumnTypeString {
+			return nil, desc.ErrorAtPosition("'element_at' second argument must be of type string when first argument is a map - it is of type %s",
+				argExprs[1].ResultType().String())
+		}
+		resultType = t.ValueType
+	default:
+		return nil, desc.ErrorAtPosition("'element_at' first argument must be an array or a map - it is of type %s",
+			argExprs[0].ResultType().String())
+	}
+	e := &ElementAtFunction{collectionExpr: argExprs[0], keyExpr: argExprs[1]}
+	e.elementExpr = elementExpr{resultType: resultType, evalValue: e.evalElement}
+	return e, nil
+}
+
+func (e *ElementAtFunction) evalElement(rowIndex int, batch *evbatch.Batch) (any, bool, error) {
+	collection, null, err := e.collectionExpr.EvalNested(rowIndex, batch)
+	if err != nil || null {
+		return nil, null, err
+	}
+	var elem any
+	switch c := collection.(type) {
+	case []any:
+		index, null, err := e.keyExpr.EvalInt(rowIndex, batch)
+		if err != nil || null {
+			return nil, null, err
+		}
+		if index < 0 || index >= int64(len(c)) {
+			return nil, true, nil
+		}
+		elem = c[index]
+	case map[string]any:
+		key, null, err := e.keyExpr.EvalString(rowIndex, batch)
+		if err != nil || null {
+			return nil, null, err
+		}
+		elem = c[key]
+	}
+	return elem, elem == nil, nil
+}
+
+type SizeFunction struct {
+	baseExpr
+	operandExpr Expression
+}
+
+func NewSizeFunction(argExprs []Expression, desc *parser.FunctionExprDesc) (*SizeFunction, error) {
+	if len(argExprs) != 1 {
+		return nil, desc.ErrorAtPosition("'size' requires 1 argument - %d found", len(argExprs))
+	}
+	operandType := argExprs[0].ResultType()
+	if operandType.ID() != types.ColumnTypeIDArray && operandType.ID() != types.ColumnTypeIDMap {
+		return nil, desc.ErrorAtPosition("'size' argument must be an array or a map - it is of type %s",
+			operandType.String())
+	}
+	return &SizeFunction{operandExpr: argExprs[0]}, nil
+}
+
+func (s *SizeFunction) EvalInt(rowIndex int, batch *evbatch.Batch) (int64, bool, error) {
+	val, null, err := s.operandExpr.EvalNested(rowIndex, batch)
+	if err != nil || null {
+		return 0, null, err
+	}
+	switch v := val.(type) {
+	case []any:
+		return int64(len(v)), false, nil
+	case map[string]any:
+		return int64(len(v)), false, nil
+	default:
+		panic("unexpected nested value")
+	}
+}
+
+func (s *SizeFunction) ResultType() types.ColumnType {
+	return types.ColumnTypeInt
+}
+
+// FromJsonFunction parses JSON into a value of the nested type given by the second argument. JSON values which do not
+// match the type become null.
+type FromJsonFunction struct {
+	baseExpr
+	jsonArg    Expression
+	isBytes    bool
+	resultType types.ColumnType
+}
+
+func NewFromJsonFunction(argExprs []Expression, desc *parser.FunctionExprDesc) (*FromJsonFunction, error) {
+	if len(argExprs) != 2 {
+		return nil, desc.ErrorAtPosition("'from_json' requires 2 arguments - %d found", len(argExprs))
+	}
+	jsonArgType := argExprs[0].ResultType()
+	if jsonArgType != types.ColumnTypeString && jsonArgType != types.ColumnTypeBytes {
+		return nil, desc.ErrorAtPosition("'from_json' first argument must be of type string or bytes - it is of type %s",
+			jsonArgType.String())
+	}
+	if _, ok := argExprs[1].(*StringConstantExpr); !ok {
+		return nil, desc.ErrorAtPosition("'from_json' second argument must be a string literal containing an array, map or struct type")
+	}
+	sType, _, _ := argExprs[1].EvalString(0, nil)
+	resultType, err := types.StringToColumnType(sType)
+	if err != nil {
+		return nil, desc.ArgExprs[1].ErrorAtPosition("%v", err)
+	}
+	if !types.IsNestedType(resultType) {
+		return nil, desc.ArgExprs[1].ErrorAtPosition("'from_json' type must be an array, map or struct type - it is %s",
+			resultType.String())
+	}
+	return &FromJsonFunction{
+		jsonArg:    argExprs[0],
+		isBytes:    jsonArgType == types.ColumnTypeBytes,
+		resultType: resultType,
+	}, nil
+}
+
+func (f *FromJsonFunction) EvalNested(rowIndex int, batch *evbatch.Batch) (any, bool, error) {
+	var val any
+	if f.isBytes {
+		b, null, err := f.jsonArg.EvalBytes(rowIndex, batch)
+		if err != nil || null {
+			return nil, null, err
+		}
+		val = types.ParseJSON(b, f.resultType)
+	} else {
+		s, null, err := f.jsonArg.EvalString(rowIndex, batch)
+		if err != nil || null {
+			return nil, null, err
+		}
+		val = types.ParseJSON(common.StringToByteSliceZeroCopy(s), f.resultType)
+	}
+	return val, val == nil, nil
+}
+
+func (f *FromJsonFunction) ResultType() types.ColumnType {
+	return f.resultType
+}
+
+type ToJsonFunction struct {
+	baseExpr
+	operandExpr Expression
+}
+
+func NewToJsonFunction(argExprs []Expression, desc *parser.FunctionExprDesc) (*ToJsonFunction, error) {
+	if len(argExprs) != 1 {
+		return nil, desc.ErrorAtPosition("'to_json' requires 1 argument - %d found", len(argExprs))
+	}
+	return &ToJsonFunction{operandExpr: argExprs[0]}, nil
+}
+
+func (t *ToJsonFunction) EvalString(rowIndex int, batch *evbatch.Batch) (string, bool, error) {
+	val, null, err := EvalValue(t.operandExpr, rowIndex, batch)
+	if err != nil || null {
+		return "", null, err
+	}
+	return string(types.AppendJSON(nil, t.operandExpr.ResultType(), val)), false, nil
+}
+
+func (t *ToJsonFunction) ResultType() types.ColumnType {
+	return types.ColumnTypeString
+}
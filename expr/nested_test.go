@@ -0,0 +1,97 @@
+package expr
+
+import (
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func createNestedTestBatch(t *testing.T) *evbatch.Batch {
+	tagsType, err := types.StringToColumnType("array<string>")
+	require.NoError(t, err)
+	attrsType, err := types.StringToColumnType("map<string,int>")
+	require.NoError(t, err)
+	addrType, err := types.StringToColumnType("struct<city:string,zip:struct<code:int>>")
+	require.NoError(t, err)
+	schema := evbatch.NewEventSchema([]string{"tags", "attrs", "addr", "json"},
+		[]types.ColumnType{tagsType, attrsType, addrType, types.ColumnTypeString})
+	colBuilders := evbatch.CreateColBuilders(schema.ColumnTypes())
+	colBuilders[0].(*evbatch.NestedColBuilder).Append([]any{"a", nil, "c"})
+	colBuilders[1].(*evbatch.NestedColBuilder).Append(map[string]any{"x": int64(1), "y": int64(2)})
+	colBuilders[2].(*evbatch.NestedColBuilder).Append([]any{"london", []any{int64(123)}})
+	colBuilders[3].(*evbatch.StringColBuilder).Append(`{"city":"paris","zip":{"code":75}}`)
+	colBuilders[0].AppendNull()
+	colBuilders[1].AppendNull()
+	colBuilders[2].AppendNull()
+	colBuilders[3].AppendNull()
+	return evbatch.NewBatchFromBuilders(schema, colBuilders...)
+}
+
+func evalNestedTestExpr(t *testing.T, exprStr string, batch *evbatch.Batch) (Expression, []any) {
+	desc, err := parser.NewParser(nil).ParseQuery("(project " + exprStr + ")")
+	require.NoError(t, err)
+	exprDesc := desc.OperatorDescs[0].(*parser.ProjectDesc).Expressions[0]
+	e, err := (&ExpressionFactory{}).CreateExpression(exprDesc, batch.Schema)
+	require.NoError(t, err)
+	var res []any
+	for i := 0; i < batch.RowCount; i++ {
+		v, null, err := EvalValue(e, i, batch)
+		require.NoError(t, err)
+		if null {
+			v = nil
+		}
+		res = append(res, v)
+	}
+	return e, res
+}
+
+func TestElementAtFunction(t *testing.T) {
+	batch := createNestedTestBatch(t)
+	e, res := evalNestedTestExpr(t, "element_at(tags, 2)", batch)
+	require.Equal(t, types.ColumnTypeString, e.ResultType())
+	require.Equal(t, []any{"c", nil}, res)
+
+	_, res = evalNestedTestExpr(t, "element_at(tags, 1)", batch)
+	require.Equal(t, []any{nil, nil}, res)
+
+	_, res = evalNestedTestExpr(t, "element_at(tags, 3)", batch)
+	require.Equal(t, []any{nil, nil}, res)
+
+	e, res = evalNestedTestExpr(t, `element_at(attrs, "y")`, batch)
+	require.Equal(t, types.ColumnTypeInt, e.ResultType())
+	require.Equal(t, []any{int64(2), nil}, res)
+
+	_, res = evalNestedTestExpr(t, `element_at(attrs, "z")`, batch)
+	require.Equal(t, []any{nil, nil}, res)
+}
+
+func TestSizeFunction(t *testing.T) {
+	batch := createNestedTestBatch(t)
+	_, res := evalNestedTestExpr(t, "size(tags)", batch)
+	require.Equal(t, []any{int64(3), nil}, res)
+	_, res = evalNestedTestExpr(t, "size(attrs)", batch)
+	require.Equal(t, []any{int64(2), nil}, res)
+}
+
+func TestStructFieldAccess(t *testing.T) {
+	batch := createNestedTestBatch(t)
+	_, res := evalNestedTestExpr(t, "addr.city", batch)
+	require.Equal(t, []any{"london", nil}, res)
+	_, res = evalNestedTestExpr(t, "addr.zip.code", batch)
+	require.Equal(t, []any{int64(123), nil}, res)
+}
+
+func TestFromJsonToJson(t *testing.T) {
+	batch := createNestedTestBatch(t)
+	e, res := evalNestedTestExpr(t, `from_json(json, "struct<city:string,zip:struct<code:int>>")`, batch)
+	require.Equal(t, "struct<city:string,zip:struct<code:int>>", e.ResultType().String())
+	require.Equal(t, []any{[]any{"paris", []any{int64(75)}}, nil}, res)
+
+	_, res = evalNestedTestExpr(t, "to_json(addr)", batch)
+	require.Equal(t, []any{`{"city":"london","zip":{"code":123}}`, nil}, res)
+
+	_, res = evalNestedTestExpr(t, "to_json(attrs)", batch)
+	require.Equal(t, []any{`{"x":1,"y":2}`, nil}, res)
+}
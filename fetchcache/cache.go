@@ -8,6 +8,7 @@ import (
 	"github.com/spirit-labs/tektite/cluster"
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/consistent"
+	log "github.com/spirit-labs/tektite/logger"
 	"github.com/spirit-labs/tektite/objstore"
 	"github.com/spirit-labs/tektite/transport"
 	"sync"
@@ -29,6 +30,16 @@ concurrent LRU cache. If not found in the cache it is looked up from the object
 As we use consistent hashing, as agents are added or removed from the cluster, most keys will still map to the same
 agents so keys won't be re-requested from the object store. If keys do migrate, then old bytes in cache will eventually
 get pushed out by the LRU mechanism.
+Ranges of tables can also be requested, so that a reader that only needs part of a table, e.g. the blocks covering a
+key range, doesn't have to fetch and cache the whole table. Each range is cached as a separate entry, on the agent
+determined by hashing the table key together with the range.
+Optionally, there is a second tier on local disk, e.g. an NVMe SSD, which is much larger than memory. Entries not found
+in memory are looked up on disk before falling back to the object store, and entries fetched from the object store are
+written to disk asynchronously. Entries on disk survive a restart of the agent. When tables are registered they are
+warmed - in each AZ, the agent that the table maps to loads it into its disk tier in the background, so that the first
+fetches of the table, and ranges of it that map to the same agent, don't have to go to the object store. Tables are only
+registered on one agent, so it forwards the warm request to one agent in each of the other AZs, which then distributes
+it across its own ring.
 */
 type Cache struct {
 	lock            sync.RWMutex
@@ -36,10 +47,13 @@ type Cache struct {
 	connCaches      *transport.ConnCaches
 	transportServer transport.Server
 	cache           *ristretto.Cache
+	diskCache       *diskCache
 	consist         *consistent.HashRing
 	members         map[int32]cluster.MembershipEntry
-	cfg             Conf
-	stats           CacheStats
+	// otherAzTargets holds, for each of the other AZs, the address of the agent that warm requests are forwarded to
+	otherAzTargets map[string]string
+	cfg            Conf
+	stats          CacheStats
 }
 
 type CacheStats struct {
@@ -47,6 +61,7 @@ type CacheStats struct {
 	Hits     int64
 	Gets     int64
 	NotFound int64
+	DiskHits int64
 }
 
 func NewCache(objStore objstore.Client, connCaches *transport.ConnCaches, transportServer transport.Server,
@@ -64,12 +79,20 @@ func NewCache(objStore objstore.Client, connCaches *transport.ConnCaches, transp
 	if err != nil {
 		return nil, err
 	}
+	var dc *diskCache
+	if cfg.DiskCacheDir != "" {
+		dc, err = newDiskCache(cfg.DiskCacheDir, int64(cfg.DiskCacheMaxSizeBytes))
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &Cache{
 		objStore:        objStore,
 		connCaches:      connCaches,
 		transportServer: transportServer,
 		members:         make(map[int32]cluster.MembershipEntry),
 		cache:           cache,
+		diskCache:       dc,
 		consist:         consistent.NewConsistentHash(cfg.VirtualFactor),
 		cfg:             cfg,
 	}, nil
@@ -81,46 +104,70 @@ type Conf struct {
 	AzInfo              string
 	VirtualFactor       int
 	ObjStoreCallTimeout time.Duration
+	// DiskCacheDir is the directory of the disk tier of the cache. If empty there is no disk tier.
+	DiskCacheDir          string
+	DiskCacheMaxSizeBytes int
 }
 
 func NewConf() Conf {
 	return Conf{
-		ObjStoreCallTimeout: DefaultObjStoreCallTimeout,
-		VirtualFactor:       DefaultVirtualFactor,
-		DataBucketName:      DefaultDataBucketName,
-		MaxSizeBytes:        DefaultMaxSizeBytes,
+		ObjStoreCallTimeout:   DefaultObjStoreCallTimeout,
+		VirtualFactor:         DefaultVirtualFactor,
+		DataBucketName:        DefaultDataBucketName,
+		MaxSizeBytes:          DefaultMaxSizeBytes,
+		DiskCacheMaxSizeBytes: DefaultDiskCacheMaxSizeBytes,
 	}
 }
 
 func (c *Conf) Validate() error {
+	if c.DiskCacheDir != "" && c.DiskCacheMaxSizeBytes <= 0 {
+		return errors.New("fetch cache diskCacheMaxSizeBytes must be > 0")
+	}
 	return nil
 }
 
 const (
-	DefaultObjStoreCallTimeout = 5 * time.Second
-	DefaultVirtualFactor       = 100
-	DefaultDataBucketName      = "tektite-data"
-	DefaultMaxSizeBytes        = 128 * 1024 * 1024
+	DefaultObjStoreCallTimeout   = 5 * time.Second
+	DefaultVirtualFactor         = 100
+	DefaultDataBucketName        = "tektite-data"
+	DefaultMaxSizeBytes          = 128 * 1024 * 1024
+	DefaultDiskCacheMaxSizeBytes = 16 * 1024 * 1024 * 1024
 )
 
 func (c *Cache) Start() {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	c.transportServer.RegisterHandler(transport.HandlerIDFetchCacheGetTableBytes, c.handleGetTableBytes)
+	c.transportServer.RegisterHandler(transport.HandlerIDFetchCacheGetTableBytesRange, c.handleGetTableBytesRange)
+	c.transportServer.RegisterHandler(transport.HandlerIDFetchCacheWarmTables, c.handleWarmTables)
+	if c.diskCache != nil {
+		c.diskCache.start()
+	}
 }
 
 func (c *Cache) Stop() {
+	if c.diskCache != nil {
+		c.diskCache.stop()
+	}
 }
 
 func (c *Cache) MembershipChanged(_ int32, membership cluster.MembershipState) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	newMembers := make(map[int32]cluster.MembershipEntry, len(membership.Members))
+	otherAzTargets := map[string]string{}
+	otherAzTargetIDs := map[string]int32{}
 	for _, member := range membership.Members {
 		data := extractMembershipData(&member)
 		if data.Location != c.cfg.AzInfo {
 			// Each AZ has it's own cache so we don't have cross AZ calls when looking up in cache
-			// Here, Az is different so we ignore
+			// Here, Az is different so we only need to remember an agent to forward warm requests to. We pick the
+			// one with the lowest member id so the choice is stable
+			id, exists := otherAzTargetIDs[data.Location]
+			if !exists || member.ID < id {
+				otherAzTargetIDs[data.Location] = member.ID
+				otherAzTargets[data.Location] = data.ClusterListenAddress
+			}
 			continue
 		}
 		newMembers[member.ID] = member
@@ -144,6 +191,7 @@ func (c *Cache) MembershipChanged(_ int32, membership cluster.MembershipState) e
 		}
 	}
 	c.members = newMembers
+	c.otherAzTargets = otherAzTargets
 	return nil
 }
 
@@ -158,24 +206,110 @@ func extractMembershipData(member *cluster.MembershipEntry) *common.MembershipDa
 }
 
 func (c *Cache) GetTableBytes(key []byte) ([]byte, error) {
+	return c.getBytes(key, transport.HandlerIDFetchCacheGetTableBytes, c.getFromCache)
+}
+
+// GetTableBytesRange gets length bytes of the table starting at offset, with the same semantics as
+// objstore.Client.GetRange
+func (c *Cache) GetTableBytesRange(key []byte, offset int64, length int64) ([]byte, error) {
+	return c.getBytes(createRangeKey(key, offset, length), transport.HandlerIDFetchCacheGetTableBytesRange,
+		c.getRangeFromCache)
+}
+
+// WarmTables asynchronously loads the tables into the disk tier of the cache on the agents that they map to, in every
+// AZ. It does nothing if there is no disk tier - agents in a cluster are expected to be configured the same.
+func (c *Cache) WarmTables(keys [][]byte) {
+	if c.diskCache == nil {
+		return
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	c.warmTablesInAz(keys)
+	for _, target := range c.otherAzTargets {
+		c.sendWarmTablesAsync(target, keys, true)
+	}
+}
+
+// warmTablesInAz warms each table on the agent in this AZ that it maps to. Must be called with the lock held.
+func (c *Cache) warmTablesInAz(keys [][]byte) {
+	remoteKeys := map[string][][]byte{}
+	for _, key := range keys {
+		target, ok := c.getTargetForKey(key)
+		if !ok {
+			return
+		}
+		if target == c.transportServer.Address() {
+			c.warmLocal(key)
+		} else {
+			remoteKeys[target] = append(remoteKeys[target], key)
+		}
+	}
+	for target, targetKeys := range remoteKeys {
+		c.sendWarmTablesAsync(target, targetKeys, false)
+	}
+}
+
+func (c *Cache) sendWarmTablesAsync(target string, keys [][]byte, distribute bool) {
+	common.Go(func() {
+		if err := c.sendWarmTables(target, keys, distribute); err != nil {
+			log.Warnf("failed to send warm tables request to %s: %v", target, err)
+		}
+	})
+}
+
+// sendWarmTables sends the keys to the target agent. If distribute is true the target warms each table on the agent in
+// its AZ that the table maps to, otherwise it warms them all locally.
+func (c *Cache) sendWarmTables(target string, keys [][]byte, distribute bool) error {
+	conn, err := c.connCaches.GetConnection(target)
+	if err != nil {
+		return err
+	}
+	req := createRequestBuffer()
+	if distribute {
+		req = append(req, 1)
+	} else {
+		req = append(req, 0)
+	}
+	req = binary.BigEndian.AppendUint32(req, uint32(len(keys)))
+	for _, key := range keys {
+		req = binary.BigEndian.AppendUint32(req, uint32(len(key)))
+		req = append(req, key...)
+	}
+	if _, err := conn.SendRPC(transport.HandlerIDFetchCacheWarmTables, req); err != nil {
+		// Always close connection on error
+		if err := conn.Close(); err != nil {
+			// Ignore
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Cache) warmLocal(key []byte) {
+	c.diskCache.warm(key, func() ([]byte, error) {
+		return c.loadTable(key)
+	})
+}
+
+func (c *Cache) getBytes(cacheKey []byte, handlerID int, localGetter func([]byte) ([]byte, error)) ([]byte, error) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
-	target, ok := c.getTargetForKey(key)
+	target, ok := c.getTargetForKey(cacheKey)
 	if !ok {
 		return nil, common.NewTektiteErrorf(common.Unavailable, "no cache members")
 	}
 	if target == c.transportServer.Address() {
 		// Target is this node - we can do a direct call
-		return c.getFromCache(key)
+		return localGetter(cacheKey)
 	}
 	conn, err := c.connCaches.GetConnection(target)
 	if err != nil {
 		return nil, err
 	}
 	req := createRequestBuffer()
-	req = binary.BigEndian.AppendUint32(req, uint32(len(key)))
-	req = append(req, key...)
-	resp, err := conn.SendRPC(transport.HandlerIDFetchCacheGetTableBytes, req)
+	req = binary.BigEndian.AppendUint32(req, uint32(len(cacheKey)))
+	req = append(req, cacheKey...)
+	resp, err := conn.SendRPC(handlerID, req)
 	if err != nil {
 		// Always close connection on error
 		if err := conn.Close(); err != nil {
@@ -201,6 +335,46 @@ func (c *Cache) getTargetForKey(key []byte) (string, bool) {
 
 func (c *Cache) handleGetTableBytes(_ *transport.ConnectionContext, request []byte, responseBuff []byte,
 	responseWriter transport.ResponseWriter) error {
+	return c.handleGet(request, responseBuff, responseWriter, c.getFromCache)
+}
+
+func (c *Cache) handleGetTableBytesRange(_ *transport.ConnectionContext, request []byte, responseBuff []byte,
+	responseWriter transport.ResponseWriter) error {
+	return c.handleGet(request, responseBuff, responseWriter, c.getRangeFromCache)
+}
+
+func (c *Cache) handleWarmTables(_ *transport.ConnectionContext, request []byte, responseBuff []byte,
+	responseWriter transport.ResponseWriter) error {
+	if err := checkRPCVersion(request); err != nil {
+		return responseWriter(nil, err)
+	}
+	if c.diskCache != nil {
+		distribute := request[2] == 1
+		numKeys := int(binary.BigEndian.Uint32(request[3:]))
+		offset := 7
+		keys := make([][]byte, numKeys)
+		for i := 0; i < numKeys; i++ {
+			lk := int(binary.BigEndian.Uint32(request[offset:]))
+			offset += 4
+			// copy as the request buffer can be reused
+			keys[i] = common.ByteSliceCopy(request[offset : offset+lk])
+			offset += lk
+		}
+		if distribute {
+			c.lock.RLock()
+			c.warmTablesInAz(keys)
+			c.lock.RUnlock()
+		} else {
+			for _, key := range keys {
+				c.warmLocal(key)
+			}
+		}
+	}
+	return responseWriter(responseBuff, nil)
+}
+
+func (c *Cache) handleGet(request []byte, responseBuff []byte, responseWriter transport.ResponseWriter,
+	localGetter func([]byte) ([]byte, error)) error {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
@@ -210,7 +384,7 @@ func (c *Cache) handleGetTableBytes(_ *transport.ConnectionContext, request []by
 	lb := binary.BigEndian.Uint32(request[2:])
 	key := request[6 : 6+lb]
 
-	bytes, err := c.getFromCache(key)
+	bytes, err := localGetter(key)
 	if err != nil {
 		return responseWriter(nil, err)
 	}
@@ -218,25 +392,79 @@ func (c *Cache) handleGetTableBytes(_ *transport.ConnectionContext, request []by
 }
 
 func (c *Cache) getFromCache(key []byte) ([]byte, error) {
+	return c.getFromCacheOrLoad(key, func() ([]byte, bool) {
+		return c.diskCache.get(key)
+	}, func() ([]byte, error) {
+		return c.loadTable(key)
+	})
+}
+
+func (c *Cache) getRangeFromCache(rangeKey []byte) ([]byte, error) {
+	key, offset, length := parseRangeKey(rangeKey)
+	return c.getFromCacheOrLoad(rangeKey, func() ([]byte, bool) {
+		if bytes, ok := c.diskCache.get(rangeKey); ok {
+			return bytes, true
+		}
+		// The whole table might be on disk, e.g. if it was warmed on this agent
+		return c.diskCache.getRange(key, offset, length)
+	}, func() ([]byte, error) {
+		return objstore.GetRangeWithTimeout(c.objStore, c.cfg.DataBucketName, string(key), offset, length,
+			c.cfg.ObjStoreCallTimeout)
+	})
+}
+
+func (c *Cache) loadTable(key []byte) ([]byte, error) {
+	return objstore.GetWithTimeout(c.objStore, c.cfg.DataBucketName, string(key), c.cfg.ObjStoreCallTimeout)
+}
+
+func (c *Cache) getFromCacheOrLoad(cacheKey []byte, diskGetter func() ([]byte, bool),
+	loader func() ([]byte, error)) ([]byte, error) {
 	atomic.AddInt64(&c.stats.Gets, 1)
-	v, ok := c.cache.Get(key)
+	v, ok := c.cache.Get(cacheKey)
 	if ok {
 		atomic.AddInt64(&c.stats.Hits, 1)
 		return v.([]byte), nil
 	}
-	bytes, err := objstore.GetWithTimeout(c.objStore, c.cfg.DataBucketName, string(key), c.cfg.ObjStoreCallTimeout)
+	if c.diskCache != nil {
+		bytes, ok := diskGetter()
+		if ok && len(bytes) > 0 {
+			atomic.AddInt64(&c.stats.DiskHits, 1)
+			c.cache.Set(cacheKey, bytes, int64(len(bytes)))
+			return bytes, nil
+		}
+	}
+	bytes, err := loader()
 	if err != nil {
 		return nil, err
 	}
 	if len(bytes) > 0 {
 		atomic.AddInt64(&c.stats.Misses, 1)
-		c.cache.Set(key, bytes, int64(len(bytes)))
+		c.cache.Set(cacheKey, bytes, int64(len(bytes)))
+		if c.diskCache != nil {
+			c.diskCache.put(cacheKey, bytes)
+		}
 	} else {
 		atomic.AddInt64(&c.stats.NotFound, 1)
 	}
 	return bytes, nil
 }
 
+// createRangeKey appends the offset and length to the table key. The result is used both as the cache key and to
+// pick the target agent, so different ranges of the same table are spread across agents.
+func createRangeKey(key []byte, offset int64, length int64) []byte {
+	rangeKey := make([]byte, 0, len(key)+16)
+	rangeKey = append(rangeKey, key...)
+	rangeKey = binary.BigEndian.AppendUint64(rangeKey, uint64(offset))
+	return binary.BigEndian.AppendUint64(rangeKey, uint64(length))
+}
+
+func parseRangeKey(rangeKey []byte) ([]byte, int64, int64) {
+	l := len(rangeKey)
+	offset := int64(binary.BigEndian.Uint64(rangeKey[l-16:]))
+	length := int64(binary.BigEndian.Uint64(rangeKey[l-8:]))
+	return rangeKey[:l-16], offset, length
+}
+
 func (c *Cache) GetStats() CacheStats {
 	c.lock.Lock()
 	defer c.lock.Unlock()
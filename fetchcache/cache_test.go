@@ -9,6 +9,7 @@ import (
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/objstore"
 	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/spirit-labs/tektite/testutils"
 	"github.com/spirit-labs/tektite/transport"
 	"github.com/stretchr/testify/require"
 	mrand "math/rand"
@@ -301,6 +302,291 @@ func TestMultipleAZs(t *testing.T) {
 	}
 }
 
+func TestCacheGetTableBytesRange(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	localTransports := transport.NewLocalTransports()
+
+	cfg := NewConf()
+	cfg.DataBucketName = "test-bucket"
+	cfg.AzInfo = "test-az"
+	cfg.MaxSizeBytes = 16 * 1024 * 1024
+
+	numNodes := 3
+	var caches []*Cache
+	var members []cluster.MembershipEntry
+	for i := 0; i < numNodes; i++ {
+		transportServer, err := localTransports.NewLocalServer(uuid.New().String())
+		require.NoError(t, err)
+		connCaches := transport.NewConnCaches(10, localTransports.CreateConnection)
+		cache, err := NewCache(objStore, connCaches, transportServer, cfg)
+		require.NoError(t, err)
+		cache.Start()
+		membershipData := common.MembershipData{
+			ClusterListenAddress: transportServer.Address(),
+			Location:             cfg.AzInfo,
+		}
+		members = append(members, cluster.MembershipEntry{
+			ID:   int32(i),
+			Data: membershipData.Serialize(nil),
+		})
+		caches = append(caches, cache)
+	}
+	defer func() {
+		for _, cache := range caches {
+			cache.Stop()
+		}
+	}()
+	for i, cache := range caches {
+		err := cache.MembershipChanged(int32(i), cluster.MembershipState{
+			LeaderVersion:  1,
+			ClusterVersion: 1,
+			Members:        members,
+		})
+		require.NoError(t, err)
+	}
+
+	kvs := setupData(t, 10, objStore, cfg.DataBucketName)
+	type byteRange struct {
+		offset int64
+		length int64
+	}
+	ranges := []byteRange{{0, 100}, {100, 100}, {950, 100}, {999, 1}, {0, 1000}}
+	numRounds := 3
+	for round := 0; round < numRounds; round++ {
+		for _, kv := range kvs {
+			for _, r := range ranges {
+				clientCache := caches[mrand.Intn(len(caches))]
+				v, err := clientCache.GetTableBytesRange(kv.Key, r.offset, r.length)
+				require.NoError(t, err)
+				require.Equal(t, objstore.SliceRange(kv.Value, r.offset, r.length), v)
+			}
+		}
+		// ristretto has async put
+		for _, cache := range caches {
+			cache.cache.Wait()
+		}
+	}
+
+	var totGets, totHits, totMisses int64
+	for _, cache := range caches {
+		stats := cache.GetStats()
+		totGets += stats.Gets
+		totHits += stats.Hits
+		totMisses += stats.Misses
+	}
+	numRanges := int64(len(kvs) * len(ranges))
+	require.Equal(t, int64(numRounds)*numRanges, totGets)
+	// Each range is only fetched from the object store once
+	require.Equal(t, numRanges, totMisses)
+	require.Equal(t, int64(numRounds-1)*numRanges, totHits)
+
+	// Ranges of tables that don't exist
+	bytes, err := caches[0].GetTableBytesRange([]byte("does-not-exist"), 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(bytes))
+}
+
+func TestCacheDiskTier(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	localTransports := transport.NewLocalTransports()
+	dir := t.TempDir()
+	caches := startDiskTierCaches(t, objStore, localTransports, []string{dir})
+	kvs := setupData(t, 10, objStore, caches[0].cfg.DataBucketName)
+	for _, kv := range kvs {
+		v, err := caches[0].GetTableBytes(kv.Key)
+		require.NoError(t, err)
+		require.Equal(t, kv.Value, v)
+	}
+	require.Equal(t, int64(len(kvs)), caches[0].GetStats().Misses)
+	// Waits for the writes to disk to complete
+	caches[0].Stop()
+
+	// Restart the cache and delete the tables from the object store, they must be served from disk
+	caches = startDiskTierCaches(t, objStore, localTransports, []string{dir})
+	for _, kv := range kvs {
+		require.NoError(t, objStore.Delete(context.Background(), caches[0].cfg.DataBucketName, string(kv.Key)))
+	}
+	for _, kv := range kvs {
+		v, err := caches[0].GetTableBytes(kv.Key)
+		require.NoError(t, err)
+		require.Equal(t, kv.Value, v)
+		// Ranges can be served from the whole table on disk
+		v, err = caches[0].GetTableBytesRange(kv.Key, 100, 50)
+		require.NoError(t, err)
+		require.Equal(t, kv.Value[100:150], v)
+	}
+	stats := caches[0].GetStats()
+	require.Equal(t, int64(2*len(kvs)), stats.DiskHits)
+	require.Equal(t, int64(0), stats.Misses)
+}
+
+func TestCacheWarmTables(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	localTransports := transport.NewLocalTransports()
+	numNodes := 3
+	var dirs []string
+	for i := 0; i < numNodes; i++ {
+		dirs = append(dirs, t.TempDir())
+	}
+	caches := startDiskTierCaches(t, objStore, localTransports, dirs)
+	kvs := setupData(t, 20, objStore, caches[0].cfg.DataBucketName)
+	var keys [][]byte
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+	caches[0].WarmTables(keys)
+	// Each table is warmed on the agent that it maps to
+	testutils.WaitUntil(t, func() (bool, error) {
+		for _, key := range keys {
+			warmed := false
+			for _, cache := range caches {
+				if cache.diskCache.contains(key) {
+					warmed = true
+				}
+			}
+			if !warmed {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	for _, kv := range kvs {
+		require.NoError(t, objStore.Delete(context.Background(), caches[0].cfg.DataBucketName, string(kv.Key)))
+	}
+	var totDiskHits, totMisses int64
+	for _, kv := range kvs {
+		v, err := caches[mrand.Intn(len(caches))].GetTableBytes(kv.Key)
+		require.NoError(t, err)
+		require.Equal(t, kv.Value, v)
+	}
+	for _, cache := range caches {
+		stats := cache.GetStats()
+		totDiskHits += stats.DiskHits
+		totMisses += stats.Misses
+	}
+	require.Equal(t, int64(len(kvs)), totDiskHits)
+	require.Equal(t, int64(0), totMisses)
+}
+
+func TestCacheWarmTablesMultipleAZs(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	localTransports := transport.NewLocalTransports()
+	numAzs := 3
+	numNodesPerAz := 3
+	var dirs, azs []string
+	for i := 0; i < numAzs; i++ {
+		for j := 0; j < numNodesPerAz; j++ {
+			dirs = append(dirs, t.TempDir())
+			azs = append(azs, fmt.Sprintf("AZ-%d", i))
+		}
+	}
+	caches := startDiskTierCachesInAzs(t, objStore, localTransports, dirs, azs)
+	kvs := setupData(t, 20, objStore, caches[0].cfg.DataBucketName)
+	var keys [][]byte
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+	// Tables are only registered on one agent, but they must be warmed in every AZ
+	caches[0].WarmTables(keys)
+	for i := 0; i < numAzs; i++ {
+		azCaches := caches[i*numNodesPerAz : (i+1)*numNodesPerAz]
+		testutils.WaitUntil(t, func() (bool, error) {
+			for _, key := range keys {
+				target, ok := azCaches[0].getTargetForKey(key)
+				require.True(t, ok)
+				for _, cache := range azCaches {
+					if cache.transportServer.Address() == target && !cache.diskCache.contains(key) {
+						return false, nil
+					}
+				}
+			}
+			return true, nil
+		})
+	}
+	for _, kv := range kvs {
+		require.NoError(t, objStore.Delete(context.Background(), caches[0].cfg.DataBucketName, string(kv.Key)))
+	}
+	// Every AZ can serve the tables from disk
+	for i := 0; i < numAzs; i++ {
+		cache := caches[i*numNodesPerAz+mrand.Intn(numNodesPerAz)]
+		for _, kv := range kvs {
+			v, err := cache.GetTableBytes(kv.Key)
+			require.NoError(t, err)
+			require.Equal(t, kv.Value, v)
+		}
+	}
+	for _, cache := range caches {
+		require.Equal(t, int64(0), cache.GetStats().Misses)
+	}
+}
+
+func TestCacheWarmTablesNoDiskTier(t *testing.T) {
+	objStore := dev.NewInMemStore(0)
+	localTransports := transport.NewLocalTransports()
+	caches := startDiskTierCaches(t, objStore, localTransports, []string{""})
+	// Must be a no-op
+	caches[0].WarmTables([][]byte{[]byte("some-key")})
+	require.Nil(t, caches[0].diskCache)
+}
+
+func TestConfValidate(t *testing.T) {
+	cfg := NewConf()
+	require.NoError(t, cfg.Validate())
+	cfg.DiskCacheDir = "some-dir"
+	require.NoError(t, cfg.Validate())
+	cfg.DiskCacheMaxSizeBytes = 0
+	require.Error(t, cfg.Validate())
+}
+
+// startDiskTierCaches starts a cache for each of the disk tier dirs - an empty dir means there's no disk tier
+func startDiskTierCaches(t *testing.T, objStore objstore.Client, localTransports *transport.LocalTransports,
+	dirs []string) []*Cache {
+	azs := make([]string, len(dirs))
+	for i := range azs {
+		azs[i] = "test-az"
+	}
+	return startDiskTierCachesInAzs(t, objStore, localTransports, dirs, azs)
+}
+
+// startDiskTierCachesInAzs is like startDiskTierCaches but the cache for dirs[i] is in AZ azs[i]
+func startDiskTierCachesInAzs(t *testing.T, objStore objstore.Client, localTransports *transport.LocalTransports,
+	dirs []string, azs []string) []*Cache {
+	var caches []*Cache
+	var members []cluster.MembershipEntry
+	for i, dir := range dirs {
+		cfg := NewConf()
+		cfg.DataBucketName = "test-bucket"
+		cfg.AzInfo = azs[i]
+		cfg.MaxSizeBytes = 16 * 1024 * 1024
+		cfg.DiskCacheDir = dir
+		transportServer, err := localTransports.NewLocalServer(uuid.New().String())
+		require.NoError(t, err)
+		connCaches := transport.NewConnCaches(10, localTransports.CreateConnection)
+		cache, err := NewCache(objStore, connCaches, transportServer, cfg)
+		require.NoError(t, err)
+		cache.Start()
+		t.Cleanup(cache.Stop)
+		membershipData := common.MembershipData{
+			ClusterListenAddress: transportServer.Address(),
+			Location:             cfg.AzInfo,
+		}
+		members = append(members, cluster.MembershipEntry{
+			ID:   int32(i),
+			Data: membershipData.Serialize(nil),
+		})
+		caches = append(caches, cache)
+	}
+	for i, cache := range caches {
+		err := cache.MembershipChanged(int32(i), cluster.MembershipState{
+			LeaderVersion:  1,
+			ClusterVersion: 1,
+			Members:        members,
+		})
+		require.NoError(t, err)
+	}
+	return caches
+}
+
 func getOtherAzGets(allAzCaches [][]*Cache, exceptIndex int) int64 {
 	var otherAZGets int64
 	for j, azCaches := range allAzCaches {
@@ -0,0 +1,415 @@
+package fetchcache
+
+import (
+	"container/list"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/common"
+	log "github.com/spirit-labs/tektite/logger"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	diskCacheMagic          uint32 = 0x54454b44 // "TEKD"
+	diskCacheFormatVersion  uint16 = 1
+	diskCacheHeaderSize            = 18
+	diskCacheChunkSize             = 64 * 1024
+	diskCacheTmpDirName            = ".tmp"
+	diskCacheWriteQueueSize        = 1000
+)
+
+var (
+	diskCacheCrcTable    = crc32.MakeTable(crc32.Castagnoli)
+	errDiskCacheCorrupt  = errors.New("disk cache entry is corrupt")
+	errDiskCacheTooLarge = errors.New("value is larger than the disk cache")
+)
+
+/*
+diskCache is an on-disk tier of the fetch cache, intended for local SSDs. Each entry is stored in its own file in the
+cache directory, named with the hex encoded key. An entry has a header holding the data length, followed by a CRC32C
+checksum for each 64KiB chunk of the data, followed by the data itself. Checksums are verified on every read, and a
+ranged read only reads, and verifies, the chunks it needs. An entry that fails verification is deleted and treated as a
+miss.
+The total size of the entries is bounded, and the least recently used entries are evicted when it is exceeded. Entries
+are kept across restarts - on startup the directory is scanned and the LRU order is rebuilt from the file modification
+times, which are updated on access.
+Writes are asynchronous and are performed by a single worker goroutine from a bounded queue. If the queue is full the
+write is dropped - it is only a cache. Files are written to a temp directory and then renamed into place so a reader
+never sees a partially written entry. We do not sync the files, a torn write after a crash is caught by the checksums.
+*/
+type diskCache struct {
+	lock         sync.Mutex
+	dir          string
+	maxSizeBytes int64
+	sizeBytes    int64
+	entries      map[string]*list.Element
+	lru          *list.List // front is most recently used
+	tasks        chan diskCacheTask
+	stopWg       sync.WaitGroup
+}
+
+type diskCacheEntry struct {
+	name string
+	size int64
+}
+
+// diskCacheTask either writes value to the cache, or if value is nil, warms the cache by loading the value with loader
+// if the key is not already cached
+type diskCacheTask struct {
+	key    []byte
+	value  []byte
+	loader func() ([]byte, error)
+}
+
+func newDiskCache(dir string, maxSizeBytes int64) (*diskCache, error) {
+	d := &diskCache{
+		dir:          dir,
+		maxSizeBytes: maxSizeBytes,
+		entries:      map[string]*list.Element{},
+		lru:          list.New(),
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	// Any temp files left behind are from writes that never completed
+	if err := os.RemoveAll(d.tmpDir()); err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	if err := os.MkdirAll(d.tmpDir(), 0o755); err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	if err := d.loadEntries(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *diskCache) loadEntries() error {
+	dirEntries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return errwrap.WithStack(err)
+	}
+	type fileInfo struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var infos []fileInfo
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+		if _, err := hex.DecodeString(name); err != nil {
+			// not one of ours
+			continue
+		}
+		fi, err := dirEntry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return errwrap.WithStack(err)
+		}
+		infos = append(infos, fileInfo{name: name, size: fi.Size(), modTime: fi.ModTime()})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].modTime.Before(infos[j].modTime)
+	})
+	for _, info := range infos {
+		d.entries[info.name] = d.lru.PushFront(&diskCacheEntry{name: info.name, size: info.size})
+		d.sizeBytes += info.size
+	}
+	// The max size may have been reduced since the entries were written
+	d.evict(0)
+	return nil
+}
+
+func (d *diskCache) start() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.tasks != nil {
+		return
+	}
+	d.tasks = make(chan diskCacheTask, diskCacheWriteQueueSize)
+	d.stopWg = sync.WaitGroup{}
+	d.stopWg.Add(1)
+	tasks := d.tasks
+	common.Go(func() {
+		defer d.stopWg.Done()
+		for task := range tasks {
+			d.runTask(task)
+		}
+	})
+}
+
+// stop waits for any queued tasks to complete
+func (d *diskCache) stop() {
+	d.lock.Lock()
+	if d.tasks == nil {
+		d.lock.Unlock()
+		return
+	}
+	close(d.tasks)
+	d.tasks = nil
+	d.lock.Unlock()
+	d.stopWg.Wait()
+}
+
+// put asynchronously writes the value to the cache
+func (d *diskCache) put(key []byte, value []byte) {
+	d.enqueue(diskCacheTask{key: key, value: value})
+}
+
+// warm asynchronously loads the value for the key with loader and writes it to the cache, unless it is already cached
+func (d *diskCache) warm(key []byte, loader func() ([]byte, error)) {
+	d.enqueue(diskCacheTask{key: key, loader: loader})
+}
+
+func (d *diskCache) enqueue(task diskCacheTask) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.tasks == nil {
+		return
+	}
+	select {
+	case d.tasks <- task:
+	default:
+		log.Debugf("fetch cache disk write queue is full - dropping write")
+	}
+}
+
+func (d *diskCache) runTask(task diskCacheTask) {
+	value := task.value
+	if value == nil {
+		if d.contains(task.key) {
+			return
+		}
+		var err error
+		value, err = task.loader()
+		if err != nil {
+			log.Warnf("failed to load value to warm fetch cache disk tier: %v", err)
+			return
+		}
+		if len(value) == 0 {
+			return
+		}
+	}
+	if err := d.write(task.key, value); err != nil && !errors.Is(err, errDiskCacheTooLarge) {
+		log.Warnf("failed to write to fetch cache disk tier: %v", err)
+	}
+}
+
+func (d *diskCache) contains(key []byte) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, ok := d.entries[hex.EncodeToString(key)]
+	return ok
+}
+
+func (d *diskCache) write(key []byte, value []byte) error {
+	numChunks := (len(value) + diskCacheChunkSize - 1) / diskCacheChunkSize
+	size := int64(diskCacheHeaderSize + 4*numChunks + len(value))
+	if size > d.maxSizeBytes {
+		return errDiskCacheTooLarge
+	}
+	header := make([]byte, 0, diskCacheHeaderSize+4*numChunks)
+	header = binary.BigEndian.AppendUint32(header, diskCacheMagic)
+	header = binary.BigEndian.AppendUint16(header, diskCacheFormatVersion)
+	header = binary.BigEndian.AppendUint32(header, diskCacheChunkSize)
+	header = binary.BigEndian.AppendUint64(header, uint64(len(value)))
+	for i := 0; i < numChunks; i++ {
+		chunk := value[i*diskCacheChunkSize : min((i+1)*diskCacheChunkSize, len(value))]
+		header = binary.BigEndian.AppendUint32(header, crc32.Checksum(chunk, diskCacheCrcTable))
+	}
+	f, err := os.CreateTemp(d.tmpDir(), "entry-")
+	if err != nil {
+		return errwrap.WithStack(err)
+	}
+	tmpPath := f.Name()
+	if _, err := f.Write(header); err != nil {
+		closeAndRemove(f)
+		return errwrap.WithStack(err)
+	}
+	if _, err := f.Write(value); err != nil {
+		closeAndRemove(f)
+		return errwrap.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		os.Remove(tmpPath)
+		return errwrap.WithStack(err)
+	}
+	name := hex.EncodeToString(key)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if elem, ok := d.entries[name]; ok {
+		d.removeElement(elem)
+	}
+	d.evict(size)
+	// We rename with the lock held so the file and the entries are always consistent
+	if err := os.Rename(tmpPath, d.path(name)); err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		os.Remove(tmpPath)
+		return errwrap.WithStack(err)
+	}
+	d.entries[name] = d.lru.PushFront(&diskCacheEntry{name: name, size: size})
+	d.sizeBytes += size
+	return nil
+}
+
+// evict removes least recently used entries until there is room for extra bytes. Must be called with the lock held.
+func (d *diskCache) evict(extra int64) {
+	for d.sizeBytes+extra > d.maxSizeBytes {
+		back := d.lru.Back()
+		if back == nil {
+			return
+		}
+		d.removeElement(back)
+	}
+}
+
+// removeElement must be called with the lock held
+func (d *diskCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*diskCacheEntry)
+	d.lru.Remove(elem)
+	delete(d.entries, entry.name)
+	d.sizeBytes -= entry.size
+	if err := os.Remove(d.path(entry.name)); err != nil && !os.IsNotExist(err) {
+		log.Warnf("failed to remove fetch cache disk entry: %v", err)
+	}
+}
+
+// get returns the whole value for the key, and false if it is not cached
+func (d *diskCache) get(key []byte) ([]byte, bool) {
+	return d.read(key, func(f *os.File, dataLength int64, checksums []byte) ([]byte, error) {
+		return readChunks(f, dataLength, checksums, 0, dataLength)
+	})
+}
+
+// getRange returns length bytes of the value for the key starting at offset, with the same semantics as
+// objstore.Client.GetRange, and false if the key is not cached
+func (d *diskCache) getRange(key []byte, offset int64, length int64) ([]byte, bool) {
+	return d.read(key, func(f *os.File, dataLength int64, checksums []byte) ([]byte, error) {
+		if offset >= dataLength {
+			return []byte{}, nil
+		}
+		if offset+length > dataLength {
+			length = dataLength - offset
+		}
+		return readChunks(f, dataLength, checksums, offset, length)
+	})
+}
+
+func (d *diskCache) read(key []byte, reader func(f *os.File, dataLength int64, checksums []byte) ([]byte, error)) ([]byte, bool) {
+	name := hex.EncodeToString(key)
+	d.lock.Lock()
+	elem, ok := d.entries[name]
+	if ok {
+		d.lru.MoveToFront(elem)
+	}
+	d.lock.Unlock()
+	if !ok {
+		return nil, false
+	}
+	path := d.path(name)
+	value, err := readEntry(path, reader)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// evicted since we looked it up
+			return nil, false
+		}
+		log.Warnf("failed to read fetch cache disk entry %s, it will be removed: %v", name, err)
+		d.lock.Lock()
+		if current, ok := d.entries[name]; ok && current == elem {
+			d.removeElement(elem)
+		}
+		d.lock.Unlock()
+		return nil, false
+	}
+	// Update the modification time so the LRU order survives a restart
+	now := time.Now()
+	//goland:noinspection GoUnhandledErrorResult
+	os.Chtimes(path, now, now)
+	return value, true
+}
+
+func readEntry(path string, reader func(f *os.File, dataLength int64, checksums []byte) ([]byte, error)) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		// not wrapped, so the caller can check for not exists
+		return nil, err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+	header := make([]byte, diskCacheHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, errDiskCacheCorrupt
+	}
+	if binary.BigEndian.Uint32(header) != diskCacheMagic ||
+		binary.BigEndian.Uint16(header[4:]) != diskCacheFormatVersion ||
+		binary.BigEndian.Uint32(header[6:]) != diskCacheChunkSize {
+		return nil, errDiskCacheCorrupt
+	}
+	dataLength := int64(binary.BigEndian.Uint64(header[10:]))
+	numChunks := (dataLength + diskCacheChunkSize - 1) / diskCacheChunkSize
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	if fi.Size() != diskCacheHeaderSize+4*numChunks+dataLength {
+		return nil, errDiskCacheCorrupt
+	}
+	checksums := make([]byte, 4*numChunks)
+	if _, err := io.ReadFull(f, checksums); err != nil {
+		return nil, errDiskCacheCorrupt
+	}
+	return reader(f, dataLength, checksums)
+}
+
+// readChunks reads the chunks of the data covering the range, verifies their checksums, and returns the range
+func readChunks(f *os.File, dataLength int64, checksums []byte, offset int64, length int64) ([]byte, error) {
+	if length == 0 {
+		return []byte{}, nil
+	}
+	firstChunk := offset / diskCacheChunkSize
+	lastChunk := (offset + length - 1) / diskCacheChunkSize
+	start := firstChunk * diskCacheChunkSize
+	end := min((lastChunk+1)*diskCacheChunkSize, dataLength)
+	buff := make([]byte, end-start)
+	dataStart := int64(diskCacheHeaderSize + len(checksums))
+	if _, err := f.ReadAt(buff, dataStart+start); err != nil {
+		return nil, errDiskCacheCorrupt
+	}
+	for chunk := firstChunk; chunk <= lastChunk; chunk++ {
+		chunkStart := chunk*diskCacheChunkSize - start
+		chunkBytes := buff[chunkStart:min(chunkStart+diskCacheChunkSize, int64(len(buff)))]
+		if crc32.Checksum(chunkBytes, diskCacheCrcTable) != binary.BigEndian.Uint32(checksums[4*chunk:]) {
+			return nil, errDiskCacheCorrupt
+		}
+	}
+	return buff[offset-start : offset-start+length], nil
+}
+
+func (d *diskCache) tmpDir() string {
+	return filepath.Join(d.dir, diskCacheTmpDirName)
+}
+
+func (d *diskCache) path(name string) string {
+	return filepath.Join(d.dir, name)
+}
+
+func closeAndRemove(f *os.File) {
+	//goland:noinspection GoUnhandledErrorResult
+	f.Close()
+	//goland:noinspection GoUnhandledErrorResult
+	os.Remove(f.Name())
+}
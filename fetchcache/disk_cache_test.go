@@ -0,0 +1,205 @@
+package fetchcache
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskCachePutGet(t *testing.T) {
+	dc := newStartedDiskCache(t, t.TempDir(), 10*1024*1024)
+	_, ok := dc.get([]byte("key1"))
+	require.False(t, ok)
+
+	// Sizes either side of chunk boundaries
+	for i, size := range []int{1, 1000, diskCacheChunkSize - 1, diskCacheChunkSize, diskCacheChunkSize + 1,
+		3*diskCacheChunkSize + 123} {
+		key := []byte{byte(i)}
+		value := randomBytes(size)
+		require.NoError(t, dc.write(key, value))
+		res, ok := dc.get(key)
+		require.True(t, ok)
+		require.Equal(t, value, res)
+	}
+}
+
+func TestDiskCacheGetRange(t *testing.T) {
+	dc := newStartedDiskCache(t, t.TempDir(), 10*1024*1024)
+	key := []byte("key1")
+	value := randomBytes(3*diskCacheChunkSize + 1000)
+	require.NoError(t, dc.write(key, value))
+	type byteRange struct {
+		offset int64
+		length int64
+	}
+	for _, r := range []byteRange{{0, 100}, {0, int64(len(value))}, {diskCacheChunkSize - 10, 20},
+		{diskCacheChunkSize, diskCacheChunkSize}, {100, 2 * diskCacheChunkSize}, {int64(len(value)) - 10, 100},
+		{int64(len(value)), 10}, {int64(len(value)) + 100, 10}, {10, 0}} {
+		res, ok := dc.getRange(key, r.offset, r.length)
+		require.True(t, ok)
+		require.Equal(t, objstore.SliceRange(value, r.offset, r.length), res)
+	}
+	_, ok := dc.getRange([]byte("key2"), 0, 100)
+	require.False(t, ok)
+}
+
+func TestDiskCacheAsyncPut(t *testing.T) {
+	dc := newStartedDiskCache(t, t.TempDir(), 10*1024*1024)
+	value := randomBytes(1000)
+	dc.put([]byte("key1"), value)
+	// stop waits for queued writes
+	dc.stop()
+	res, ok := dc.get([]byte("key1"))
+	require.True(t, ok)
+	require.Equal(t, value, res)
+	// dropped once stopped
+	dc.put([]byte("key2"), value)
+	require.False(t, dc.contains([]byte("key2")))
+}
+
+func TestDiskCacheLRUEviction(t *testing.T) {
+	valueSize := 1000
+	entrySize := diskCacheHeaderSize + 4 + valueSize
+	dc := newStartedDiskCache(t, t.TempDir(), int64(3*entrySize))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, dc.write([]byte{byte(i)}, randomBytes(valueSize)))
+	}
+	// Access 0 so 1 is the least recently used
+	_, ok := dc.get([]byte{0})
+	require.True(t, ok)
+	require.NoError(t, dc.write([]byte{3}, randomBytes(valueSize)))
+	require.True(t, dc.contains([]byte{0}))
+	require.False(t, dc.contains([]byte{1}))
+	require.True(t, dc.contains([]byte{2}))
+	require.True(t, dc.contains([]byte{3}))
+	require.Equal(t, int64(3*entrySize), dc.sizeBytes)
+	_, err := os.Stat(dc.path(hex.EncodeToString([]byte{1})))
+	require.True(t, os.IsNotExist(err))
+
+	// Too large to ever fit
+	err = dc.write([]byte{4}, randomBytes(4*valueSize))
+	require.True(t, errors.Is(err, errDiskCacheTooLarge))
+	require.Equal(t, 3, len(dc.entries))
+
+	// Overwriting an entry doesn't count its size twice
+	require.NoError(t, dc.write([]byte{3}, randomBytes(valueSize)))
+	require.Equal(t, 3, len(dc.entries))
+	require.Equal(t, int64(3*entrySize), dc.sizeBytes)
+}
+
+func TestDiskCacheCorruptEntry(t *testing.T) {
+	dc := newStartedDiskCache(t, t.TempDir(), 10*1024*1024)
+	key := []byte("key1")
+	value := randomBytes(2*diskCacheChunkSize + 100)
+	require.NoError(t, dc.write(key, value))
+	path := dc.path(hex.EncodeToString(key))
+
+	// Flip a byte in the second chunk
+	corruptFile(t, path, int64(diskCacheHeaderSize+4*3+diskCacheChunkSize+10))
+	// A range in the first chunk is still good
+	res, ok := dc.getRange(key, 0, 100)
+	require.True(t, ok)
+	require.Equal(t, value[:100], res)
+	// But a range in the second is not, and the entry is removed
+	_, ok = dc.getRange(key, diskCacheChunkSize, 100)
+	require.False(t, ok)
+	require.False(t, dc.contains(key))
+	_, err := os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+	require.Equal(t, int64(0), dc.sizeBytes)
+
+	// Truncated
+	require.NoError(t, dc.write(key, value))
+	require.NoError(t, os.Truncate(path, 1000))
+	_, ok = dc.get(key)
+	require.False(t, ok)
+	require.False(t, dc.contains(key))
+
+	// Bad header
+	require.NoError(t, dc.write(key, value))
+	corruptFile(t, path, 0)
+	_, ok = dc.get(key)
+	require.False(t, ok)
+	require.False(t, dc.contains(key))
+}
+
+func TestDiskCacheReload(t *testing.T) {
+	dir := t.TempDir()
+	valueSize := 1000
+	entrySize := diskCacheHeaderSize + 4 + valueSize
+	dc := newStartedDiskCache(t, dir, 10*1024*1024)
+	var values [][]byte
+	for i := 0; i < 4; i++ {
+		value := randomBytes(valueSize)
+		values = append(values, value)
+		require.NoError(t, dc.write([]byte{byte(i)}, value))
+		// make sure modification times differ
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Access 0 so it is the most recently used
+	_, ok := dc.get([]byte{0})
+	require.True(t, ok)
+	dc.stop()
+	// Temp files from incomplete writes are removed
+	tmpFile := filepath.Join(dc.tmpDir(), "entry-123")
+	require.NoError(t, os.WriteFile(tmpFile, []byte("foo"), 0o644))
+
+	// Reopen with room for three entries - the least recently used is evicted
+	dc = newStartedDiskCache(t, dir, int64(3*entrySize))
+	require.Equal(t, 3, len(dc.entries))
+	require.False(t, dc.contains([]byte{1}))
+	for _, i := range []int{0, 2, 3} {
+		res, ok := dc.get([]byte{byte(i)})
+		require.True(t, ok)
+		require.Equal(t, values[i], res)
+	}
+	_, err := os.Stat(tmpFile)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestDiskCacheWarm(t *testing.T) {
+	dc := newStartedDiskCache(t, t.TempDir(), 10*1024*1024)
+	value := randomBytes(1000)
+	var loads int
+	loader := func() ([]byte, error) {
+		loads++
+		return value, nil
+	}
+	dc.warm([]byte("key1"), loader)
+	dc.warm([]byte("key1"), loader)
+	// Not found in the object store
+	dc.warm([]byte("key2"), func() ([]byte, error) {
+		return nil, nil
+	})
+	dc.stop()
+	require.Equal(t, 1, loads)
+	res, ok := dc.get([]byte("key1"))
+	require.True(t, ok)
+	require.Equal(t, value, res)
+	require.False(t, dc.contains([]byte("key2")))
+}
+
+func newStartedDiskCache(t *testing.T, dir string, maxSizeBytes int64) *diskCache {
+	dc, err := newDiskCache(dir, maxSizeBytes)
+	require.NoError(t, err)
+	dc.start()
+	t.Cleanup(dc.stop)
+	return dc
+}
+
+func corruptFile(t *testing.T, path string, offset int64) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	b := make([]byte, 1)
+	_, err = f.ReadAt(b, offset)
+	require.NoError(t, err)
+	b[0] ^= 0xff
+	_, err = f.WriteAt(b, offset)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
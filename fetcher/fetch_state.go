@@ -389,12 +389,11 @@ func (p *PartitionFetchState) createIteratorForKeyRange(ids lsm.OverlappingTable
 	if len(ids) == 0 {
 		return &iteration.EmptyIterator{}, nil
 	}
-	tableGetter := p.fs.bf.getTableFromCache
 	var iters []iteration.Iterator
 	for _, nonOverLapIDs := range ids {
 		if len(nonOverLapIDs) == 1 {
 			info := nonOverLapIDs[0]
-			iter, err := sst.NewLazySSTableIterator(info.ID, tableGetter, keyStart, keyEnd)
+			iter, err := p.fs.bf.newLazySSTableIterator(info.ID, keyStart, keyEnd)
 			if err != nil {
 				return nil, err
 			}
@@ -402,7 +401,7 @@ func (p *PartitionFetchState) createIteratorForKeyRange(ids lsm.OverlappingTable
 		} else {
 			itersInChain := make([]iteration.Iterator, len(nonOverLapIDs))
 			for j, nonOverlapID := range nonOverLapIDs {
-				iter, err := sst.NewLazySSTableIterator(nonOverlapID.ID, tableGetter, keyStart, keyEnd)
+				iter, err := p.fs.bf.newLazySSTableIterator(nonOverlapID.ID, keyStart, keyEnd)
 				if err != nil {
 					return nil, err
 				}
@@ -440,7 +439,7 @@ func (p *PartitionFetchState) createIteratorFromTabIDs(tableIDs []*sst.SSTableID
 	if len(tableIDs) > 1 {
 		iters := make([]iteration.Iterator, len(tableIDs))
 		for i, tid := range tableIDs {
-			sstIter, err := sst.NewLazySSTableIterator(*tid, p.fs.bf.getTableFromCache, keyStart, keyEnd)
+			sstIter, err := p.fs.bf.newLazySSTableIterator(*tid, keyStart, keyEnd)
 			if err != nil {
 				return nil, err
 			}
@@ -449,7 +448,7 @@ func (p *PartitionFetchState) createIteratorFromTabIDs(tableIDs []*sst.SSTableID
 		iter = iteration.NewChainingIterator(iters)
 	} else {
 		var err error
-		iter, err = sst.NewLazySSTableIterator(*tableIDs[0], p.fs.bf.getTableFromCache, keyStart, keyEnd)
+		iter, err = p.fs.bf.newLazySSTableIterator(*tableIDs[0], keyStart, keyEnd)
 		if err != nil {
 			return nil, err
 		}
@@ -5,6 +5,7 @@ import (
 	"github.com/spirit-labs/tektite/cluster"
 	"github.com/spirit-labs/tektite/compress"
 	"github.com/spirit-labs/tektite/control"
+	"github.com/spirit-labs/tektite/iteration"
 	"github.com/spirit-labs/tektite/kafkaprotocol"
 	"github.com/spirit-labs/tektite/objstore"
 	"github.com/spirit-labs/tektite/parthash"
@@ -28,6 +29,9 @@ that recent data could be fetched for multiple partitions, and that data could l
 locally saves multiple possibly remote calls to other agents to retrieve the table from the fetch cache.
 If not found locally the table is requested from the fetch cache. This is a distributed cache, spread across all agents
 in the same AZ. The distributed cache will get the table from object store if it doesn't have it.
+If a range getter is provided, rather than getting whole tables the iterators get just the parts of each table that
+cover the key range being fetched - the table meta and the blocks containing the range - using ranged reads. These are
+cached locally in a LocalBlockCache, and in the fetch cache, in the same way as whole tables.
 Recent consumers - i.e. ones that don't lag very far behind the latest offset in a partition are the most common. We
 wish to avoid going to the controller to request table ids every time a recent fetch request arrives. We therefore
 cache the most recently registered SSTable ids locally in PartitionRecentTables. When a fetch request arrives it can
@@ -41,27 +45,36 @@ type BatchFetcher struct {
 	partitionHashes    *parthash.PartitionHashes
 	controlFactory     control.ClientFactory
 	tableGetter        sst.TableGetter
+	rangeGetter        sst.RangeGetter
 	controlClientCache *control.ClientCache
 	dataBucketName     string
 	localCache         *LocalSSTCache
+	localBlockCache    *LocalBlockCache
 	resetSequence      int64
 	memberID           int32
 	compressionType    compress.CompressionType
 }
 
 func NewBatchFetcher(objStore objstore.Client, topicProvider topicInfoProvider, partitionHashes *parthash.PartitionHashes,
-	controlClientCache *control.ClientCache, tableGetter sst.TableGetter, cfg Conf) (*BatchFetcher, error) {
+	controlClientCache *control.ClientCache, tableGetter sst.TableGetter, rangeGetter sst.RangeGetter,
+	cfg Conf) (*BatchFetcher, error) {
 	localCache, err := NewLocalSSTCache(cfg.LocalCacheNumEntries, cfg.LocalCacheMaxBytes)
 	if err != nil {
 		return nil, err
 	}
+	localBlockCache, err := NewLocalBlockCache(cfg.LocalCacheMaxBytes)
+	if err != nil {
+		return nil, err
+	}
 	bf := &BatchFetcher{
 		objStore:           objStore,
 		topicProvider:      topicProvider,
 		partitionHashes:    partitionHashes,
 		controlClientCache: controlClientCache,
 		tableGetter:        tableGetter,
+		rangeGetter:        rangeGetter,
 		localCache:         localCache,
+		localBlockCache:    localBlockCache,
 		dataBucketName:     cfg.DataBucketName,
 		memberID:           -1,
 		compressionType:    cfg.FetchCompressionType,
@@ -148,6 +161,31 @@ func (b *BatchFetcher) getTableFromCache(tableID sst.SSTableID) (*sst.SSTable, e
 	return table, nil
 }
 
+func (b *BatchFetcher) getTableRangeFromCache(tableID sst.SSTableID, offset int64, length int64) ([]byte, error) {
+	// First look in local cache
+	bytes, ok := b.localBlockCache.Get(tableID, offset, length)
+	if ok {
+		return bytes, nil
+	}
+	// Then in distributed cache
+	bytes, err := b.rangeGetter(tableID, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes) > 0 {
+		// Add to local cache
+		b.localBlockCache.Put(tableID, offset, length, bytes)
+	}
+	return bytes, nil
+}
+
+func (b *BatchFetcher) newLazySSTableIterator(tableID sst.SSTableID, keyStart []byte, keyEnd []byte) (iteration.Iterator, error) {
+	if b.rangeGetter != nil {
+		return sst.NewLazySSTableIteratorWithRangeGetter(tableID, b.getTableRangeFromCache, keyStart, keyEnd)
+	}
+	return sst.NewLazySSTableIterator(tableID, b.getTableFromCache, keyStart, keyEnd)
+}
+
 func (b *BatchFetcher) getClient() (control.Client, error) {
 	return b.controlClientCache.GetClient()
 }
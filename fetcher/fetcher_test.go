@@ -9,6 +9,7 @@ import (
 	"github.com/spirit-labs/tektite/asl/encoding"
 	"github.com/spirit-labs/tektite/cluster"
 	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/compress"
 	"github.com/spirit-labs/tektite/control"
 	"github.com/spirit-labs/tektite/kafkaprotocol"
 	"github.com/spirit-labs/tektite/lsm"
@@ -157,7 +158,25 @@ func TestFetcherSinglePartitionNoWaitFetchMultipleBatchesSomeAfterLastReadableAn
 func TestFetcherSingleTopicMultiplePartitionsFetchAll(t *testing.T) {
 	fetcher, topicProvider, controlClient, objStore := setupFetcher(t)
 	defer stopFetcher(t, fetcher)
+	testFetcherSingleTopicMultiplePartitionsFetchAll(t, fetcher, topicProvider, controlClient, objStore)
+}
+
+func TestFetcherRangedReadsSingleTopicMultiplePartitionsFetchAll(t *testing.T) {
+	fetcher, topicProvider, controlClient, objStore := setupFetcherWithRangeGetter(t)
+	defer stopFetcher(t, fetcher)
+	testFetcherSingleTopicMultiplePartitionsFetchAll(t, fetcher, topicProvider, controlClient, objStore)
+}
+
+func TestFetcherRangedReadsSinglePartitionNoWaitFetch(t *testing.T) {
+	fetcher, topicProvider, controlClient, objStore := setupFetcherWithRangeGetter(t)
+	defer stopFetcher(t, fetcher)
+	batches, _ := setupDataDefault(t, 0, 9999, 10000, 10, 2, topicProvider, controlClient, objStore)
+	resp := sendFetchDefault(t, 4000, 0, 0, defaultMaxBytes, defaultMaxBytes, fetcher)
+	verifyDefaultResponse(t, resp, batches[4:])
+}
 
+func testFetcherSingleTopicMultiplePartitionsFetchAll(t *testing.T, fetcher *BatchFetcher, topicProvider *testTopicProvider,
+	controlClient *testControlClient, objStore objstore.Client) {
 	batches1, _ := setupForPartition(t, defaultTopicID, defaultTopicName, 23, 1000, 9999, 9999, 10, 2, topicProvider, controlClient, objStore)
 	batches2, _ := setupForPartition(t, defaultTopicID, defaultTopicName, 24, 3000, 12999, 12999, 10, 2, topicProvider, controlClient, objStore)
 	batches3, _ := setupForPartition(t, defaultTopicID, defaultTopicName, 25, 7000, 29999, 29999, 10, 2, topicProvider, controlClient, objStore)
@@ -1278,6 +1297,14 @@ func testFetcherErrorUnknownPartition(t *testing.T, partitionID int) {
 }
 
 func setupFetcher(t *testing.T) (*BatchFetcher, *testTopicProvider, *testControlClient, objstore.Client) {
+	return setupFetcherWithOpts(t, false)
+}
+
+func setupFetcherWithRangeGetter(t *testing.T) (*BatchFetcher, *testTopicProvider, *testControlClient, objstore.Client) {
+	return setupFetcherWithOpts(t, true)
+}
+
+func setupFetcherWithOpts(t *testing.T, ranged bool) (*BatchFetcher, *testTopicProvider, *testControlClient, objstore.Client) {
 	objStore := dev.NewInMemStore(0)
 	infoProvider := &testTopicProvider{infos: map[string]topicmeta.TopicInfo{}}
 	partHashes, err := parthash.NewPartitionHashes(0)
@@ -1293,7 +1320,12 @@ func setupFetcher(t *testing.T) (*BatchFetcher, *testTopicProvider, *testControl
 	controlClientCache := control.NewClientCache(10, controlFactory)
 	cfg := NewConf()
 	cfg.DataBucketName = databucketName
-	fetcher, err := NewBatchFetcher(objStore, infoProvider, partHashes, controlClientCache, getter.getSSTable, cfg)
+	var rangeGetter sst.RangeGetter
+	if ranged {
+		rangeGetter = getter.getSSTableRange
+	}
+	fetcher, err := NewBatchFetcher(objStore, infoProvider, partHashes, controlClientCache, getter.getSSTable,
+		rangeGetter, cfg)
 	require.NoError(t, err)
 	err = fetcher.Start()
 	require.NoError(t, err)
@@ -1333,7 +1365,8 @@ func setupTable(t *testing.T, batchInfos []partitionBatchInfo, objStore objstore
 	table, _, _, _, _, err := sst.BuildSSTable(common.DataFormatV1, 0, 0, iter)
 	require.NoError(t, err)
 	tableID := sst.CreateSSTableId()
-	tableData := table.Serialize()
+	tableData, err := table.ToStorageBytes(compress.CompressionTypeNone)
+	require.NoError(t, err)
 	err = objStore.Put(context.Background(), bucketName, tableID, tableData)
 	require.NoError(t, err)
 	return []byte(tableID), batches
@@ -1714,9 +1747,11 @@ func (t *testTableGetter) getSSTable(id sst.SSTableID) (*sst.SSTable, error) {
 	if len(buff) == 0 {
 		return nil, errors.New("table not found")
 	}
-	var table sst.SSTable
-	table.Deserialize(buff, 0)
-	return &table, nil
+	return sst.GetSSTableFromBytes(buff)
+}
+
+func (t *testTableGetter) getSSTableRange(id sst.SSTableID, offset int64, length int64) ([]byte, error) {
+	return t.objStore.GetRange(context.Background(), t.bucketName, string(id), offset, length)
 }
 
 type testTopicProvider struct {
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"encoding/binary"
 	"github.com/dgraph-io/ristretto"
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/sst"
@@ -45,3 +46,48 @@ func (m *LocalSSTCache) Get(key sst.SSTableID) (*sst.SSTable, bool) {
 	}
 	return v.(*sst.SSTable), true
 }
+
+// blockSizeEstimate is roughly the size of the ranges fetched when reading part of a table
+const blockSizeEstimate = 64 * 1024
+
+/*
+LocalBlockCache is the equivalent of LocalSSTCache for when parts of SSTables are fetched with ranged reads. It caches
+the most recently retrieved ranges of tables, keyed by table id, offset and length.
+*/
+type LocalBlockCache struct {
+	cache *ristretto.Cache
+}
+
+func NewLocalBlockCache(maxSizeBytes int) (*LocalBlockCache, error) {
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: int64(10 * (maxSizeBytes / blockSizeEstimate)),
+		MaxCost:     int64(maxSizeBytes),
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &LocalBlockCache{
+		cache: cache,
+	}, nil
+}
+
+func (m *LocalBlockCache) Put(tableID sst.SSTableID, offset int64, length int64, value []byte) bool {
+	return m.cache.Set(createBlockKey(tableID, offset, length), value, int64(len(value)))
+}
+
+func (m *LocalBlockCache) Get(tableID sst.SSTableID, offset int64, length int64) ([]byte, bool) {
+	v, ok := m.cache.Get(createBlockKey(tableID, offset, length))
+	if !ok {
+		return nil, false
+	}
+	return v.([]byte), true
+}
+
+func createBlockKey(tableID sst.SSTableID, offset int64, length int64) string {
+	key := make([]byte, 0, len(tableID)+16)
+	key = append(key, tableID...)
+	key = binary.BigEndian.AppendUint64(key, uint64(offset))
+	key = binary.BigEndian.AppendUint64(key, uint64(length))
+	return common.ByteSliceToStringZeroCopy(key)
+}
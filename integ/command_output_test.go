@@ -7,12 +7,10 @@ import (
 )
 
 func TestAgentCommandMissingRequiredArgs(t *testing.T) {
-	testAgentMissingRequiredArgs(t, "", "missing flags: --obj-store-username=STRING, --obj-store-password=STRING, --obj-store-url=STRING, --cluster-name=STRING, --location=STRING")
-	testAgentMissingRequiredArgs(t, "--obj-store-username=some-user", "missing flags: --obj-store-password=STRING, --obj-store-url=STRING, --cluster-name=STRING, --location=STRING")
-	testAgentMissingRequiredArgs(t, "--obj-store-username=some-user --obj-store-password=some-pwd", "missing flags: --obj-store-url=STRING, --cluster-name=STRING, --location=STRING")
-	testAgentMissingRequiredArgs(t, "--obj-store-username=some-user --obj-store-password=some-pwd --obj-store-url=some-url", "missing flags: --cluster-name=STRING, --location=STRING")
-	testAgentMissingRequiredArgs(t, "--obj-store-username=some-user --obj-store-password=some-pwd --obj-store-url=some-url --cluster-name=some-cluster", "missing flags: --location=STRING")
-	testAgentMissingRequiredArgs(t, "--obj-store-username=some-user --obj-store-password=some-pwd --obj-store-url=some-url --location=some-az", "missing flags: --cluster-name=STRING")
+	testAgentMissingRequiredArgs(t, "", "missing flags: --obj-store-url=STRING, --cluster-name=STRING, --location=STRING")
+	testAgentMissingRequiredArgs(t, "--obj-store-url=some-url", "missing flags: --cluster-name=STRING, --location=STRING")
+	testAgentMissingRequiredArgs(t, "--obj-store-url=some-url --cluster-name=some-cluster", "missing flags: --location=STRING")
+	testAgentMissingRequiredArgs(t, "--obj-store-url=some-url --location=some-az", "missing flags: --cluster-name=STRING")
 }
 
 func testAgentMissingRequiredArgs(t *testing.T, args string, expectedMsg string) {
@@ -25,13 +23,16 @@ func testAgentMissingRequiredArgs(t *testing.T, args string, expectedMsg string)
 
 func TestAgentCommandHelp(t *testing.T) {
 	expected :=
-		`Usage: tekagent --obj-store-username=STRING --obj-store-password=STRING --obj-store-url=STRING --cluster-name=STRING --location=STRING
+		`Usage: tekagent --obj-store-url=STRING --cluster-name=STRING --location=STRING
 
 Flags:
   -h, --help                                                  Show context-sensitive help.
-      --obj-store-username=STRING                             username for the object store
-      --obj-store-password=STRING                             password for the object store
+      --obj-store-username=STRING                             username for the object store. for 'azure' this is the storage account name
+      --obj-store-password=STRING                             password for the object store. for 'azure' this is the storage account key
       --obj-store-url=STRING                                  url of the object store
+      --obj-store-type="s3"                                   type of the object store. one of 's3', 'azure' or 'gcs'
+      --obj-store-credentials-file=STRING                     path to a service account credentials file for 'gcs'. if not specified application default
+                                                              credentials are used
       --cluster-name=STRING                                   name of the agent cluster
       --location=STRING                                       location (e.g. availability zone) that the agent runs in
       --kafka-listen-address=STRING                           address to listen on for kafka connections
@@ -66,11 +67,56 @@ Flags:
       --default-max-message-size-bytes=1048576                the maximum size of a message batch that can be sent to a topic - can be overridden at topic level
       --metadata-write-interval-ms=100                        interval between writing database metadata to permanent storage, in milliseconds
       --storage-compression-type="lz4"                        determines how data is compressed before writing to object storage. one of 'lz4', 'zstd' or 'none'
+      --storage-data-format=1                                 format of the data tables written to object storage. one of 1 or 2. format 2 tables have an index
+                                                              of blocks and a bloom filter, so lookups fetch less data, but can only be read by agents which
+                                                              support format 2
       --fetch-compression-type="lz4"                          determines how data is compressed before returning a fetched batch to a consumer. one of 'gzip',
                                                               'snappy', 'lz4', 'zstd' or 'none'
       --data-write-interval-ms=200                            maximum interval between writing database data to permanent storage, in milliseconds
       --pusher-buffer-max-size-bytes=4194304                  maximum size of the push buffer in bytes - when it is full a data table will be written to object
                                                               storage
+      --schema-registry-enabled                               if 'true' then the agent hosts a schema registry with a Confluent compatible REST API
+      --schema-registry-listen-address="localhost:8081"       address to listen on for schema registry connections
+      --schema-registry-compatibility="backward"              default compatibility level for schema registry subjects. one of 'none', 'backward',
+                                                              'backward_transitive', 'forward', 'forward_transitive', 'full' or 'full_transitive'
+      --admin-listen-address=STRING                           address to listen on for the admin api, which serves metrics, the object store request report and
+                                                              table garbage collection. if not specified the admin api is not started
+      --obj-store-get-request-price=0.0004                    price per 1000 get requests to the object store, used to estimate the cost of object store
+                                                              requests
+      --obj-store-put-request-price=0.005                     price per 1000 put requests to the object store, used to estimate the cost of object store
+                                                              requests
+      --obj-store-list-request-price=0.005                    price per 1000 list requests to the object store, used to estimate the cost of object store
+                                                              requests
+      --obj-store-delete-request-price=0                      price per 1000 delete requests to the object store, used to estimate the cost of object store
+                                                              requests
+      --obj-store-coalesce-gets                               if 'true' then concurrent gets of the same object from the object store are coalesced into a
+                                                              single request
+      --fetch-cache-disk-dir=STRING                           directory of the on-disk tier of the fetch cache, ideally on a local SSD. if not specified there
+                                                              is no disk tier
+      --fetch-cache-disk-max-size-bytes=17179869184           maximum size in bytes of the on-disk tier of the fetch cache
+      --obj-store-encryption-key-file=STRING                  path to a key file holding the key encryption keys used to encrypt objects written to the object
+                                                              store. if not specified objects are not encrypted
+      --obj-store-allow-unencrypted-reads                     if 'true' then objects in the object store which are not encrypted can still be read when
+                                                              encryption is enabled, e.g. objects written before it was enabled
+      --table-gc-interval=10m                                 interval between runs of the garbage collector which deletes orphaned tables from the object
+                                                              store. if zero it does not run periodically
+      --table-gc-grace-period=1h                              minimum age of a table in the object store before the garbage collector considers it orphaned if
+                                                              it is not registered
+      --replica-obj-store-url=STRING                          url of the object store to replicate the data of the cluster to, typically in another region.
+                                                              if not specified the data is not replicated
+      --replica-obj-store-type="s3"                           type of the replica object store. one of 's3', 'azure' or 'gcs'
+      --replica-obj-store-username=STRING                     username for the replica object store. for 'azure' this is the storage account name
+      --replica-obj-store-password=STRING                     password for the replica object store. for 'azure' this is the storage account key
+      --replica-obj-store-credentials-file=STRING             path to a service account credentials file for a 'gcs' replica object store. if not specified
+                                                              application default credentials are used
+      --replica-cluster-name=STRING                           name of the cluster which will be started from the replica, which determines the buckets the data
+                                                              is replicated to. if not specified the name of this cluster is used
+      --replication-snapshot-interval=30s                     interval between snapshots of the cluster metadata written to the replica. the replica is behind
+                                                              by at most this interval
+      --promote-replica                                       if 'true' then the replica in the object store is promoted when the agent starts, so the cluster
+                                                              can be started from it. the cluster which replicated to it can no longer write to it
+      --restore-backup=STRING                                 id of a backup to restore the cluster from. the agent restores the backup and exits without
+                                                              starting. all agents in the cluster must be stopped
       --log-format="console"                                  format to write log lines in - one of: console, json
       --log-level="info"                                      lowest log level that will be emitted - one of: debug, info, warn, error`
 
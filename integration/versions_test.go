@@ -511,6 +511,10 @@ func (t *testLevelMgrClient) LoadLastFlushedVersion() (int64, error) {
 	return t.lastFlushedVersion, nil
 }
 
+func (t *testLevelMgrClient) GetOldestRetainedVersion() (int64, error) {
+	return -1, nil
+}
+
 func (t *testLevelMgrClient) RegisterSlabRetention(slabID int, retention time.Duration) error {
 	return nil
 }
@@ -423,7 +423,7 @@ func setup(t *testing.T) (opers.StreamManager, proc.Manager, *conf.Config) {
 	cfg.ApplyDefaults()
 	cfg.LogScope = t.Name()
 	cfg.ProcessorCount = 10
-	streamMgr := opers.NewStreamManager(nil, &testSlabRetentions{},
+	streamMgr := opers.NewStreamManager(nil, nil, &testSlabRetentions{},
 		&expr.ExpressionFactory{}, cfg, true)
 
 	stateMgr := &testClustStateMgr{}
@@ -280,6 +280,7 @@ type DefaultMessageProvider struct {
 }
 
 var _ MessageProvider = &DefaultMessageProvider{}
+var _ WatermarkProvider = &DefaultMessageProvider{}
 
 func (dmp *DefaultMessageProvider) GetMessage(pollTimeout time.Duration) (*Message, error) {
 	dmp.lock.Lock()
@@ -320,6 +321,20 @@ func (dmp *DefaultMessageProvider) GetMessage(pollTimeout time.Duration) (*Messa
 	}
 }
 
+// WatermarkOffsets returns the watermarks cached by the consumer, which are updated as messages are fetched
+func (dmp *DefaultMessageProvider) WatermarkOffsets(partitionID int) (int64, int64, bool) {
+	dmp.lock.Lock()
+	defer dmp.lock.Unlock()
+	if dmp.consumer == nil {
+		return 0, 0, false
+	}
+	low, high, err := dmp.consumer.GetWatermarkOffsets(dmp.topicName, int32(partitionID))
+	if err != nil || high < 0 {
+		return 0, 0, false
+	}
+	return low, high, true
+}
+
 func (dmp *DefaultMessageProvider) Stop() error {
 	dmp.lock.Lock()
 	defer dmp.lock.Unlock()
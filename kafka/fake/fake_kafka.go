@@ -245,6 +245,16 @@ func (f *MessageProvider) GetMessage(pollTimeout time.Duration) (*kafka.Message,
 	return f.subscriber.GetMessage(pollTimeout)
 }
 
+func (f *MessageProvider) WatermarkOffsets(partitionID int) (int64, int64, bool) {
+	if partitionID < 0 || partitionID >= len(f.topic.partitions) {
+		return 0, 0, false
+	}
+	partition := f.topic.partitions[partitionID]
+	partition.lock.Lock()
+	defer partition.lock.Unlock()
+	return 0, int64(len(partition.messages)), true
+}
+
 func (f *MessageProvider) Start() error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
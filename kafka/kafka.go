@@ -18,6 +18,12 @@ type MessageProvider interface {
 	Stop() error
 }
 
+// WatermarkProvider is implemented by MessageProviders which know the offsets of the first message in a partition
+// (low) and of the next message to be written to it (high)
+type WatermarkProvider interface {
+	WatermarkOffsets(partitionID int) (low int64, high int64, ok bool)
+}
+
 type MessageProducer interface {
 	SendBatch(batch *evbatch.Batch) error
 	Stop() error
@@ -28,6 +28,9 @@ type Client interface {
 
 	LoadLastFlushedVersion() (int64, error)
 
+	// GetOldestRetainedVersion returns the oldest version that point-in-time queries can be executed at
+	GetOldestRetainedVersion() (int64, error)
+
 	GetStats() (Stats, error)
 
 	RegisterSlabRetention(slabID int, retention time.Duration) error
@@ -132,6 +135,16 @@ func (c *externalClient) LoadLastFlushedVersion() (int64, error) {
 	return resp.LastFlushedVersion, nil
 }
 
+func (c *externalClient) GetOldestRetainedVersion() (int64, error) {
+	req := &clustermsgs.LevelManagerGetOldestRetainedVersionMessage{}
+	r, err := c.sendRpcWithRetryOnNoLeader(req)
+	if err != nil {
+		return 0, err
+	}
+	resp := r.(*clustermsgs.LevelManagerGetOldestRetainedVersionResponse)
+	return resp.OldestRetainedVersion, nil
+}
+
 func (c *externalClient) GetStats() (Stats, error) {
 	req := &clustermsgs.LevelManagerGetStatsMessage{}
 	r, err := c.sendRpcWithRetryOnNoLeader(req)
@@ -76,7 +76,7 @@ func (lm *LevelManager) scheduleCompaction(level int, tableSlices [][]*TableEntr
 	destLevelExists := len(segmentEntries) > 0
 	hasLocked := false
 	now := uint64(time.Now().UTC().UnixMilli())
-	lfv := lm.masterRecord.lastFlushedVersion
+	lfv := lm.getCompactableVersion(int64(now))
 
 outer:
 	for _, tables := range tableSlices {
@@ -210,7 +210,7 @@ outer:
 			preserveTombstones: preserveTombstones,
 			scheduleTime:       arista.NanoTime(),
 			serverTime:         uint64(time.Now().UTC().UnixMilli()),
-			lastFlushedVersion: lm.masterRecord.lastFlushedVersion,
+			lastFlushedVersion: lfv,
 			sourceRange:        sourceRange,
 			destRange:          destRange,
 		}
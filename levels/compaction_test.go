@@ -1904,6 +1904,87 @@ func TestJobCreatedWithLastFlushedVersion(t *testing.T) {
 	require.Equal(t, int64(100), job.lastFlushedVersion)
 }
 
+func TestJobCreatedWithHistoryRetention(t *testing.T) {
+	lm, tearDown := setupLevelManagerWithConfigSetter(t, true, func(cfg *conf.Config) {
+		cfg.L1CompactionTrigger = 1
+		cfg.CompactionJobTimeout = time.Hour
+		cfg.HistoryRetention = time.Hour
+	})
+	defer tearDown(t)
+
+	err := lm.StoreLastFlushedVersion(100, false, 0)
+	require.NoError(t, err)
+
+	sst1 := createTableEntryWithDeleteRatio("sst1", 0, 9, 0.5)
+	sst2 := createTableEntryWithDeleteRatio("sst2", 10, 19, 0.5)
+	populateLevel(t, lm, 1, sst1, sst2)
+	err = lm.MaybeScheduleCompaction()
+	require.NoError(t, err)
+
+	// Version 100 was flushed within the retention period, so no old versions can be removed yet
+	job, err := getJob(lm)
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), job.lastFlushedVersion)
+
+	now := time.Now().UnixMilli()
+	lm.lock.Lock()
+	lm.flushedVersionTimes = nil
+	lm.addFlushedVersionTime(50, now-2*time.Hour.Milliseconds())
+	lm.addFlushedVersionTime(80, now-90*time.Minute.Milliseconds())
+	lm.addFlushedVersionTime(100, now)
+	// The entry for version 50 is no longer needed
+	require.Equal(t, 2, len(lm.flushedVersionTimes))
+	require.Equal(t, int64(80), lm.getCompactableVersion(now))
+	require.Equal(t, int64(100), lm.getCompactableVersion(now+time.Hour.Milliseconds()))
+	lm.lock.Unlock()
+
+	sst3 := createTableEntryWithDeleteRatio("sst3", 20, 29, 0.5)
+	populateLevel(t, lm, 1, sst3)
+	err = lm.MaybeScheduleCompaction()
+	require.NoError(t, err)
+	job, err = getJob(lm)
+	require.NoError(t, err)
+	require.Equal(t, int64(80), job.lastFlushedVersion)
+}
+
+func TestGetOldestRetainedVersion(t *testing.T) {
+	lm, tearDown := setupLevelManagerWithConfigSetter(t, true, func(cfg *conf.Config) {
+		cfg.HistoryRetention = time.Hour
+	})
+	defer tearDown(t)
+
+	oldest, err := lm.GetOldestRetainedVersion()
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), oldest)
+
+	// Version 100 was flushed within the retention period, so no old versions can have been removed yet
+	err = lm.StoreLastFlushedVersion(100, false, 0)
+	require.NoError(t, err)
+	oldest, err = lm.GetOldestRetainedVersion()
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), oldest)
+
+	now := time.Now().UnixMilli()
+	lm.lock.Lock()
+	lm.flushedVersionTimes = nil
+	lm.addFlushedVersionTime(80, now-90*time.Minute.Milliseconds())
+	lm.addFlushedVersionTime(100, now)
+	lm.lock.Unlock()
+	oldest, err = lm.GetOldestRetainedVersion()
+	require.NoError(t, err)
+	require.Equal(t, int64(80), oldest)
+
+	// After a restart flush times are lost, and compactions before the restart may have used any version up to the
+	// last flushed version
+	lm.lock.Lock()
+	lm.flushedVersionTimes = nil
+	lm.loadedFlushedVersion = 100
+	lm.lock.Unlock()
+	oldest, err = lm.GetOldestRetainedVersion()
+	require.NoError(t, err)
+	require.Equal(t, int64(100), oldest)
+}
+
 func TestCompactionChooseOldestTablesToCompact(t *testing.T) {
 	entries := []TableEntry{
 		createTE(0, 3000, 0),
@@ -143,6 +143,23 @@ func (l *loadLastFlushedVersionHandler) HandleMessage(_ remoting.MessageHolder)
 	return &clustermsgs.LevelManagerLoadLastFlushedVersionResponse{LastFlushedVersion: lfv}, nil
 }
 
+type getOldestRetainedVersionHandler struct {
+	ms *LevelManagerService
+}
+
+func (g *getOldestRetainedVersionHandler) HandleMessage(_ remoting.MessageHolder) (remoting.ClusterMessage, error) {
+	g.ms.lock.RLock()
+	defer g.ms.lock.RUnlock()
+	if g.ms.levelManager == nil {
+		return nil, createNotLeaderError(g.ms)
+	}
+	version, err := g.ms.levelManager.GetOldestRetainedVersion()
+	if err != nil {
+		return nil, err
+	}
+	return &clustermsgs.LevelManagerGetOldestRetainedVersionResponse{OldestRetainedVersion: version}, nil
+}
+
 type storeLastFlushedVersionHandler struct {
 	ms *LevelManagerService
 }
@@ -216,6 +233,8 @@ func (l *LevelManagerService) SetClusterMessageHandlers(remotingServer remoting.
 		&storeLastFlushedVersionHandler{ms: l})
 	remotingServer.RegisterBlockingMessageHandler(remoting.ClusterMessageLevelManagerLoadLastFlushedVersionMessage,
 		&loadLastFlushedVersionHandler{ms: l})
+	remotingServer.RegisterBlockingMessageHandler(remoting.ClusterMessageLevelManagerGetOldestRetainedVersionMessage,
+		&getOldestRetainedVersionHandler{ms: l})
 	remotingServer.RegisterBlockingMessageHandler(remoting.ClusterMessageLevelManagerGetStatsMessage,
 		&getStatsHandler{ms: l})
 	remotingServer.RegisterConnectionClosedHandler(l.connectionClosed)
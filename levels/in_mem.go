@@ -14,6 +14,10 @@ func (c *InMemClient) LoadLastFlushedVersion() (int64, error) {
 	return -1, nil
 }
 
+func (c *InMemClient) GetOldestRetainedVersion() (int64, error) {
+	return -1, nil
+}
+
 func (c *InMemClient) QueryTablesInRange(keyStart []byte, keyEnd []byte) (OverlappingTables, error) {
 	return c.LevelManager.QueryTablesInRange(keyStart, keyEnd)
 }
@@ -75,6 +75,15 @@ type LevelManager struct {
 	level0Groups                   map[int][]*TableEntry
 	sstProcessorMap                map[string]int
 	readable                       atomic.Pointer[readableState]
+	flushedVersionTimes            []flushedVersionTime
+	loadedFlushedVersion           int64
+}
+
+// flushedVersionTime records when a last flushed version was stored. Compaction uses these to find the newest version
+// that was flushed before the start of the history retention period.
+type flushedVersionTime struct {
+	version int64
+	time    int64 // millis past epoch
 }
 
 type readableState struct {
@@ -210,6 +219,7 @@ func (lm *LevelManager) Start(block bool) error {
 		lm.lock.Lock()
 		defer lm.lock.Unlock()
 		lm.masterRecord = mr
+		lm.loadedFlushedVersion = mr.lastFlushedVersion
 		if lm.conf.LevelManagerFlushInterval != -1 {
 			// -1 disables periodic flushing (used in tests)
 			lm.scheduleFlushNoLock(lm.conf.LevelManagerFlushInterval, true)
@@ -1632,9 +1642,58 @@ func (lm *LevelManager) StoreLastFlushedVersion(version int64, reprocess bool, r
 	lm.masterRecord.lastFlushedVersion = version
 	lm.masterRecord.version++
 	lm.hasChanges = true
+	lm.addFlushedVersionTime(version, time.Now().UnixMilli())
 	return nil
 }
 
+func (lm *LevelManager) addFlushedVersionTime(version int64, unixMillis int64) {
+	if lm.conf.HistoryRetention == 0 {
+		return
+	}
+	lm.flushedVersionTimes = append(lm.flushedVersionTimes, flushedVersionTime{version: version, time: unixMillis})
+	cutoff := unixMillis - lm.conf.HistoryRetention.Milliseconds()
+	// We keep the newest entry at or before the cutoff, it's the one that determines the compactable version
+	i := sort.Search(len(lm.flushedVersionTimes), func(i int) bool {
+		return lm.flushedVersionTimes[i].time > cutoff
+	})
+	if i > 1 {
+		lm.flushedVersionTimes = lm.flushedVersionTimes[i-1:]
+	}
+}
+
+// getCompactableVersion returns the version that compaction can use as its last flushed version - older versions of
+// a key can only be removed if they are below it. If history retention is configured, this is the newest version that
+// was flushed before the start of the retention period, so old versions survive long enough for point-in-time
+// queries. Flush times are not persisted, so after the level manager restarts no old versions are removed until the
+// retention period has passed.
+func (lm *LevelManager) getCompactableVersion(unixMillis int64) int64 {
+	lfv := lm.masterRecord.lastFlushedVersion
+	if lm.conf.HistoryRetention == 0 {
+		return lfv
+	}
+	cutoff := unixMillis - lm.conf.HistoryRetention.Milliseconds()
+	i := sort.Search(len(lm.flushedVersionTimes), func(i int) bool {
+		return lm.flushedVersionTimes[i].time > cutoff
+	})
+	if i == 0 {
+		return -1
+	}
+	return min(lm.flushedVersionTimes[i-1].version, lfv)
+}
+
+// GetOldestRetainedVersion returns the oldest version that point-in-time queries can be executed at, as compaction may
+// have removed versions of keys that are older than the compactable version. Compactions that ran before the level
+// manager was loaded may have used any version up to the last flushed version at the time it was loaded, as flush times
+// are not persisted.
+func (lm *LevelManager) GetOldestRetainedVersion() (int64, error) {
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+	if lm.getState() != stateActive {
+		return 0, common.NewTektiteErrorf(common.Unavailable, "levelManager not active")
+	}
+	return max(lm.getCompactableVersion(time.Now().UnixMilli()), lm.loadedFlushedVersion), nil
+}
+
 func (lm *LevelManager) LoadLastFlushedVersion() (int64, error) {
 	lm.lock.Lock()
 	defer lm.lock.Unlock()
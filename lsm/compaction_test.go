@@ -813,6 +813,108 @@ func TestDeleteSSTablesAfterCompaction(t *testing.T) {
 	require.NotNil(t, v)
 }
 
+func TestRetainedSSTablesNotDeletedAfterCompaction(t *testing.T) {
+	deleteCheckPeriod := 100 * time.Millisecond
+	deleteDelay := 100 * time.Millisecond
+	lm, tearDown := setupLevelManagerWithConfigSetter(t, true, true, func(cfg *Conf) {
+		cfg.L0CompactionTrigger = 2
+		cfg.LevelMultiplier = 2
+		cfg.SSTableDeleteCheckInterval = deleteCheckPeriod
+		cfg.SSTableDeleteDelay = deleteDelay
+	})
+	defer tearDown(t)
+	lm.SetTableRetainedFunc(func(tableID sst.SSTableID) bool {
+		return string(tableID) == "sst2-3"
+	})
+
+	bucketName := lm.cfg.SSTableBucketName
+	for _, tableID := range []string{"sst1-1", "sst1-2", "sst1-3", "sst1-4", "sst1-5", "sst2-1", "sst2-2", "sst2-3",
+		"sst2-4", "sst2-5"} {
+		err := lm.objStore.Put(context.Background(), bucketName, tableID, []byte("foo"))
+		require.NoError(t, err)
+	}
+	populateLevel(t, lm, 1, createTableEntryWithDeleteRatio("sst1-1", 0, 1, 0.1),
+		createTableEntryWithDeleteRatio("sst1-2", 2, 3, 0.1),
+		createTableEntryWithDeleteRatio("sst1-3", 4, 5, 0.1),
+		createTableEntryWithDeleteRatio("sst1-4", 6, 7, 0.1),
+		createTableEntryWithDeleteRatio("sst1-5", 10, 19, 0.11))
+	populateLevel(t, lm, 2, createTableEntryWithDeleteRatio("sst2-1", 0, 4, 0.5),
+		createTableEntryWithDeleteRatio("sst2-2", 5, 9, 0.3),
+		createTableEntryWithDeleteRatio("sst2-3", 10, 14, 0.45),
+		createTableEntryWithDeleteRatio("sst2-4", 15, 20, 0.8),
+		createTableEntryWithDeleteRatio("sst2-5", 21, 24, 0.34))
+	err := lm.MaybeScheduleCompaction()
+	require.NoError(t, err)
+	job, err := getJob(lm)
+	require.NoError(t, err)
+	sendCompactionComplete(t, lm, job, []TableEntry{
+		createTableEntryWithDeleteRatio("sst2-6", 10, 15, 1.23),
+		createTableEntryWithDeleteRatio("sst2-7", 16, 20, 1.23),
+	})
+
+	// sst1-5 and sst2-4 are deleted, but sst2-3 is retained
+	testutils.WaitUntil(t, func() (bool, error) {
+		v, err := lm.objStore.Get(context.Background(), bucketName, "sst2-4")
+		return v == nil, err
+	})
+	v, err := lm.objStore.Get(context.Background(), bucketName, "sst1-5")
+	require.NoError(t, err)
+	require.Nil(t, v)
+	v, err = lm.objStore.Get(context.Background(), bucketName, "sst2-3")
+	require.NoError(t, err)
+	require.NotNil(t, v)
+	// It's no longer waiting to be deleted
+	_, toDelete, err := lm.GetTableIDs()
+	require.NoError(t, err)
+	require.Equal(t, 0, len(toDelete))
+}
+
+func TestGetTableIDs(t *testing.T) {
+	lm, tearDown := setupLevelManagerWithConfigSetter(t, true, true, func(cfg *Conf) {
+		cfg.L0CompactionTrigger = 2
+		cfg.LevelMultiplier = 2
+		// Make sure compacted tables aren't deleted during the test
+		cfg.SSTableDeleteDelay = time.Hour
+	})
+	defer tearDown(t)
+
+	sst11 := createTableEntryWithDeleteRatio("sst1-1", 0, 1, 0.1)
+	sst12 := createTableEntryWithDeleteRatio("sst1-2", 2, 3, 0.1)
+	sst13 := createTableEntryWithDeleteRatio("sst1-3", 4, 5, 0.1)
+	sst14 := createTableEntryWithDeleteRatio("sst1-4", 6, 7, 0.1)
+	sst15 := createTableEntryWithDeleteRatio("sst1-5", 10, 19, 0.11)
+	populateLevel(t, lm, 1, sst11, sst12, sst13, sst14, sst15)
+	sst21 := createTableEntryWithDeleteRatio("sst2-1", 0, 4, 0.5)
+	sst22 := createTableEntryWithDeleteRatio("sst2-2", 21, 24, 0.34)
+	populateLevel(t, lm, 2, sst21, sst22)
+
+	registered, toDelete, err := lm.GetTableIDs()
+	require.NoError(t, err)
+	requireTableIDs(t, registered, "sst1-1", "sst1-2", "sst1-3", "sst1-4", "sst1-5", "sst2-1", "sst2-2")
+	require.Equal(t, 0, len(toDelete))
+
+	// Compact sst1-5 into L2
+	err = lm.MaybeScheduleCompaction()
+	require.NoError(t, err)
+	job, err := getJob(lm)
+	require.NoError(t, err)
+	sendCompactionComplete(t, lm, job, []TableEntry{createTableEntryWithDeleteRatio("sst2-3", 10, 19, 0.1)})
+
+	registered, toDelete, err = lm.GetTableIDs()
+	require.NoError(t, err)
+	requireTableIDs(t, registered, "sst1-1", "sst1-2", "sst1-3", "sst1-4", "sst2-1", "sst2-2", "sst2-3")
+	requireTableIDs(t, toDelete, "sst1-5")
+}
+
+func requireTableIDs(t *testing.T, tableIDs []sst.SSTableID, expected ...string) {
+	t.Helper()
+	var actual []string
+	for _, tableID := range tableIDs {
+		actual = append(actual, string(tableID))
+	}
+	require.ElementsMatch(t, expected, actual)
+}
+
 func TestChooseLevelToCompact0(t *testing.T) {
 	testChooseLevelToCompact(t, 0, func(lm *Manager) {
 		addTablesToLevel(t, lm, 0, 10)
@@ -54,6 +54,7 @@ type CompactionWorkerServiceConf struct {
 	SSTablePushRetryDelay  time.Duration
 	TableCompressionType   compress.CompressionType
 	TopicCompactionMaxKeys int
+	DataFormat             common.DataFormat
 }
 
 func (c *CompactionWorkerServiceConf) Validate() error {
@@ -67,6 +68,7 @@ func NewCompactionWorkerServiceConf() CompactionWorkerServiceConf {
 		SSTablePushRetryDelay:  1 * time.Second,
 		MaxSSTableSize:         16 * 1024 * 1024,
 		TopicCompactionMaxKeys: 1000000,
+		DataFormat:             common.DataFormatV1,
 	}
 }
 
@@ -372,7 +374,7 @@ func (c *compactionWorker) processJob(job *CompactionJob) ([]RegistrationEntry,
 	}
 	// Per job cache
 	lastOffsetCacheMap := map[string]int64{}
-	infos, err := mergeSSTables(common.DataFormatV1, tablesToMerge, job.preserveTombstones,
+	infos, err := mergeSSTables(c.cws.cfg.DataFormat, tablesToMerge, job.preserveTombstones,
 		c.cws.cfg.MaxSSTableSize, job.lastFlushedVersion, job.id, retProvider, job.serverTime, c.isCompactedTopic,
 		func(topicID int64, partitionID int64, key []byte) (int64, bool, error) {
 			return c.lastOffsetForKey(topicID, partitionID, key, lastOffsetCacheMap)
@@ -29,6 +29,7 @@ type Manager struct {
 	hasChanges                bool
 	enableCompaction          bool
 	validateOnEachStateChange bool
+	tableRetained             func(tableID sst.SSTableID) bool
 }
 
 type Conf struct {
@@ -79,6 +80,14 @@ func NewManager(objStore objstore.Client, l0FreeCallback func(), enableCompactio
 	return lm
 }
 
+// SetTableRetainedFunc sets a function which is called before deleting a table which is no longer registered. If it
+// returns true the table is retained in the object store, e.g. as it is referenced by a backup.
+func (m *Manager) SetTableRetainedFunc(tableRetained func(tableID sst.SSTableID) bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.tableRetained = tableRetained
+}
+
 func (m *Manager) Start(masterRecordBytes []byte) error {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -165,6 +174,22 @@ func (m *Manager) GetTablesForHighestKeyWithPrefix(prefix []byte) ([]sst.SSTable
 	return tableIDs, nil
 }
 
+// GetTableIDs returns the ids of all tables registered in the LSM, and the ids of tables which have been deregistered
+// and are waiting to be deleted
+func (m *Manager) GetTableIDs() ([]sst.SSTableID, []sst.SSTableID, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if !m.started {
+		return nil, nil, errors.New("not started")
+	}
+	registered := m.masterRecord.TableIDs()
+	toDelete := make([]sst.SSTableID, len(m.tablesToDelete))
+	for i, entry := range m.tablesToDelete {
+		toDelete[i] = entry.tableID
+	}
+	return registered, toDelete, nil
+}
+
 func (m *Manager) ApplyChanges(regBatch RegistrationBatch, noCompaction bool) (bool, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
@@ -670,6 +695,11 @@ func (m *Manager) maybeDeleteTables() {
 		if age < m.cfg.SSTableDeleteDelay {
 			break
 		}
+		if m.tableRetained != nil && m.tableRetained(entry.tableID) {
+			log.Debugf("retained sstable %v", entry.tableID)
+			pos = i
+			continue
+		}
 		log.Debugf("deleted sstable %v", entry.tableID)
 		if err := objstore.DeleteWithTimeout(m.objStore, m.cfg.SSTableBucketName, string(entry.tableID), objstore.DefaultCallTimeout); err != nil {
 			log.Errorf("failed to delete ss-table from cloud store: %v", err)
@@ -426,6 +426,18 @@ func (mr *MasterRecord) Deserialize(buff []byte, offset int) int {
 	return mr.stats.Deserialize(buff, offset)
 }
 
+// TableIDs returns the ids of all the tables in the master record
+func (mr *MasterRecord) TableIDs() []sst.SSTableID {
+	var tableIDs []sst.SSTableID
+	for _, entry := range mr.levelEntries {
+		for _, lte := range entry.tableEntries {
+			te := lte.Get(entry)
+			tableIDs = append(tableIDs, common.ByteSliceCopy(te.SSTableID))
+		}
+	}
+	return tableIDs
+}
+
 type levelEntry struct {
 	maxVersion       uint64 // Note this is the max version ever stored in the level, not necessarily the current max version in the level
 	rangeStart       []byte
@@ -8,6 +8,10 @@ import (
 type Client interface {
 	GetObjectInfo(ctx context.Context, bucket string, key string) (ObjectInfo, bool, error)
 	Get(ctx context.Context, bucket string, key string) ([]byte, error)
+	// GetRange gets length bytes of the object starting at offset. If the range extends past the end of the object,
+	// the bytes up to the end are returned, and if offset is at or past the end an empty slice is returned. As with
+	// Get, nil is returned if the object does not exist.
+	GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error)
 	Put(ctx context.Context, bucket string, key string, value []byte) error
 	PutIfNotExists(ctx context.Context, bucket string, key string, value []byte) (bool, string, error)
 	PutIfMatchingEtag(ctx context.Context, bucket string, key string, value []byte, etag string) (bool, string, error)
@@ -40,6 +44,12 @@ func GetWithTimeout(client Client, bucket string, key string, timeout time.Durat
 	return client.Get(ctx, bucket, key)
 }
 
+func GetRangeWithTimeout(client Client, bucket string, key string, offset int64, length int64, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return client.GetRange(ctx, bucket, key, offset, length)
+}
+
 func PutWithTimeout(client Client, bucket string, key string, value []byte, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -75,3 +85,17 @@ func ListObjectsWithPrefixWithTimeout(client Client, bucket string, prefix strin
 	defer cancel()
 	return client.ListObjectsWithPrefix(ctx, bucket, prefix, maxKeys)
 }
+
+// SliceRange returns the range of value as specified for Client.GetRange. It's used by clients that don't support
+// ranged reads natively.
+func SliceRange(value []byte, offset int64, length int64) []byte {
+	size := int64(len(value))
+	if offset >= size {
+		return []byte{}
+	}
+	end := offset + length
+	if end > size {
+		end = size
+	}
+	return value[offset:end]
+}
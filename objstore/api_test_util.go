@@ -14,6 +14,7 @@ func TestApi(t *testing.T, client Client) {
 	testCases := []testCase{
 		{testName: "testPutGet", test: testPutGet},
 		{testName: "testPutOverwrite", test: testPutOverwrite},
+		{testName: "testGetRange", test: testGetRange},
 		{testName: "testDelete", test: testDelete},
 		{testName: "testDeleteAll", test: testDeleteAll},
 		{testName: "testPutIfNotExists", test: testPutIfNotExists},
@@ -97,6 +98,36 @@ func testPutGet(t *testing.T, client Client) {
 	require.Nil(t, vb)
 }
 
+func testGetRange(t *testing.T, client Client) {
+	ctx := context.Background()
+
+	vb, err := client.GetRange(ctx, DefaultBucket, "key1", 0, 10)
+	require.NoError(t, err)
+	require.Nil(t, vb)
+
+	err = client.Put(ctx, DefaultBucket, "key1", []byte("0123456789"))
+	require.NoError(t, err)
+
+	vb, err = client.GetRange(ctx, DefaultBucket, "key1", 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", string(vb))
+
+	vb, err = client.GetRange(ctx, DefaultBucket, "key1", 3, 4)
+	require.NoError(t, err)
+	require.Equal(t, "3456", string(vb))
+
+	// Range extends past the end
+	vb, err = client.GetRange(ctx, DefaultBucket, "key1", 7, 10)
+	require.NoError(t, err)
+	require.Equal(t, "789", string(vb))
+
+	// Offset past the end
+	vb, err = client.GetRange(ctx, DefaultBucket, "key1", 10, 5)
+	require.NoError(t, err)
+	require.NotNil(t, vb)
+	require.Equal(t, 0, len(vb))
+}
+
 func testPutOverwrite(t *testing.T, client Client) {
 	ctx := context.Background()
 
@@ -0,0 +1,236 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/objstore"
+	"io"
+	"math"
+	"net/http"
+)
+
+var _ objstore.Client = &Client{}
+
+// Client is an objstore.Client for Azure Blob Storage. Buckets map to containers and objects to block blobs.
+// Conditional puts use If-None-Match / If-Match with the blob ETag.
+type Client struct {
+	cfg    Conf
+	client *azblob.Client
+}
+
+type Conf struct {
+	// Endpoint is the blob service URL, e.g. https://myaccount.blob.core.windows.net/
+	Endpoint    string
+	AccountName string
+	AccountKey  string
+}
+
+func NewAzureClient(cfg Conf) *Client {
+	return &Client{
+		cfg: cfg,
+	}
+}
+
+func (a *Client) GetObjectInfo(ctx context.Context, bucket string, key string) (objstore.ObjectInfo, bool, error) {
+	props, err := a.blobClient(bucket, key).GetProperties(ctx, nil)
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			return objstore.ObjectInfo{}, false, nil
+		}
+		return objstore.ObjectInfo{}, false, maybeConvertError(err)
+	}
+	info := objstore.ObjectInfo{
+		Key:  key,
+		Etag: string(*props.ETag),
+	}
+	if props.LastModified != nil {
+		info.LastModified = *props.LastModified
+	}
+	return info, true, nil
+}
+
+func (a *Client) Get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	resp, err := a.client.DownloadStream(ctx, bucket, key, nil)
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			// does not exist
+			return nil, nil
+		}
+		return nil, maybeConvertError(err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	buff, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, maybeConvertError(err)
+	}
+	return buff, nil
+}
+
+func (a *Client) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	resp, err := a.client.DownloadStream(ctx, bucket, key, &azblob.DownloadStreamOptions{
+		Range: azblob.HTTPRange{Offset: offset, Count: length},
+	})
+	if err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			// does not exist
+			return nil, nil
+		}
+		if isStatus(err, http.StatusRequestedRangeNotSatisfiable) {
+			// offset is past the end of the object
+			return []byte{}, nil
+		}
+		return nil, maybeConvertError(err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+	buff, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, maybeConvertError(err)
+	}
+	return buff, nil
+}
+
+func (a *Client) Put(ctx context.Context, bucket string, key string, value []byte) error {
+	_, err := a.upload(ctx, bucket, key, value, nil)
+	return maybeConvertError(err)
+}
+
+func (a *Client) PutIfNotExists(ctx context.Context, bucket string, key string, value []byte) (bool, string, error) {
+	etag, err := a.upload(ctx, bucket, key, value, &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)})
+	if err != nil {
+		if isStatus(err, http.StatusConflict, http.StatusPreconditionFailed) {
+			// Blob already exists
+			return false, "", nil
+		}
+		return false, "", maybeConvertError(err)
+	}
+	return true, etag, nil
+}
+
+func (a *Client) PutIfMatchingEtag(ctx context.Context, bucket string, key string, value []byte, etag string) (bool, string, error) {
+	newEtag, err := a.upload(ctx, bucket, key, value, &blob.ModifiedAccessConditions{IfMatch: to.Ptr(azcore.ETag(etag))})
+	if err != nil {
+		if isStatus(err, http.StatusPreconditionFailed, http.StatusNotFound) {
+			// Etag does not match, or the blob does not exist
+			return false, "", nil
+		}
+		return false, "", maybeConvertError(err)
+	}
+	return true, newEtag, nil
+}
+
+func (a *Client) upload(ctx context.Context, bucket string, key string, value []byte,
+	conditions *blob.ModifiedAccessConditions) (string, error) {
+	var opts blockblob.UploadOptions
+	if conditions != nil {
+		opts.AccessConditions = &blob.AccessConditions{ModifiedAccessConditions: conditions}
+	}
+	resp, err := a.blobClient(bucket, key).Upload(ctx, streaming.NopCloser(bytes.NewReader(value)), &opts)
+	if err != nil {
+		return "", err
+	}
+	return string(*resp.ETag), nil
+}
+
+func (a *Client) Delete(ctx context.Context, bucket string, key string) error {
+	_, err := a.client.DeleteBlob(ctx, bucket, key, nil)
+	if err != nil && !isStatus(err, http.StatusNotFound) {
+		return maybeConvertError(err)
+	}
+	return nil
+}
+
+func (a *Client) DeleteAll(ctx context.Context, bucket string, keys []string) error {
+	for _, key := range keys {
+		if err := a.Delete(ctx, bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Client) ListObjectsWithPrefix(ctx context.Context, bucket string, prefix string, maxKeys int) ([]objstore.ObjectInfo, error) {
+	opts := azblob.ListBlobsFlatOptions{Prefix: &prefix}
+	if maxKeys != -1 && maxKeys < 5000 {
+		// MaxResults is the max number of blobs in each page, not the total number
+		opts.MaxResults = to.Ptr(int32(maxKeys))
+	}
+	if maxKeys == -1 {
+		maxKeys = math.MaxInt
+	}
+	var infos []objstore.ObjectInfo
+	pager := a.client.NewListBlobsFlatPager(bucket, &opts)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, maybeConvertError(err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			info := objstore.ObjectInfo{Key: *item.Name}
+			if item.Properties != nil && item.Properties.LastModified != nil {
+				info.LastModified = *item.Properties.LastModified
+			}
+			infos = append(infos, info)
+			if len(infos) == maxKeys {
+				return infos, nil
+			}
+		}
+	}
+	return infos, nil
+}
+
+func (a *Client) MakeBucket(ctx context.Context, bucket string) error {
+	_, err := a.client.CreateContainer(ctx, bucket, nil)
+	return err
+}
+
+func (a *Client) Start() error {
+	cred, err := azblob.NewSharedKeyCredential(a.cfg.AccountName, a.cfg.AccountKey)
+	if err != nil {
+		return err
+	}
+	client, err := azblob.NewClientWithSharedKeyCredential(a.cfg.Endpoint, cred, nil)
+	if err != nil {
+		return err
+	}
+	a.client = client
+	return nil
+}
+
+func (a *Client) Stop() error {
+	a.client = nil
+	return nil
+}
+
+func (a *Client) blobClient(bucket string, key string) *blockblob.Client {
+	return a.client.ServiceClient().NewContainerClient(bucket).NewBlockBlobClient(key)
+}
+
+func isStatus(err error, statusCodes ...int) bool {
+	var respErr *azcore.ResponseError
+	if !errwrap.As(err, &respErr) {
+		return false
+	}
+	for _, statusCode := range statusCodes {
+		if respErr.StatusCode == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func maybeConvertError(err error) error {
+	if err == nil {
+		return err
+	}
+	return common.NewTektiteErrorf(common.Unavailable, err.Error())
+}
@@ -0,0 +1,64 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"testing"
+)
+
+const (
+	// Well known credentials for the Azurite emulator
+	azuriteAccountName = "devstoreaccount1"
+	azuriteAccountKey  = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFr/KBHBeksoGMGw=="
+)
+
+func TestAzureObjStore(t *testing.T) {
+
+	ctx := context.Background()
+
+	azuriteContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "mcr.microsoft.com/azure-storage/azurite:3.33.0",
+			Cmd:          []string{"azurite-blob", "--blobHost", "0.0.0.0", "--skipApiVersionCheck"},
+			ExposedPorts: []string{"10000/tcp"},
+			WaitingFor:   wait.ForListeningPort("10000/tcp"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+
+	// Clean up the container
+	defer func() {
+		err := azuriteContainer.Terminate(ctx)
+		require.NoError(t, err)
+	}()
+
+	ip, err := azuriteContainer.Host(ctx)
+	require.NoError(t, err)
+
+	port, err := azuriteContainer.MappedPort(ctx, "10000")
+	require.NoError(t, err)
+
+	cfg := Conf{
+		Endpoint:    fmt.Sprintf("http://%s:%d/%s/", ip, port.Int(), azuriteAccountName),
+		AccountName: azuriteAccountName,
+		AccountKey:  azuriteAccountKey,
+	}
+
+	client := NewAzureClient(cfg)
+	err = client.Start()
+	require.NoError(t, err)
+
+	// Make the buckets needed by the tests
+	for i := 0; i < objstore.NumTestBuckets; i++ {
+		bucket := fmt.Sprintf("%s%.2d", objstore.TestBucketPrefix, i)
+		err = client.MakeBucket(ctx, bucket)
+		require.NoError(t, err)
+	}
+
+	objstore.TestApi(t, client)
+}
@@ -11,18 +11,27 @@ import (
 
 type Store struct {
 	rServer remoting.Server
-	store   *InMemStore
+	store   objstore.Client
 }
 
 func NewDevStore(listenAddress string) *Store {
+	return NewDevStoreWithClient(listenAddress, NewInMemStore(0))
+}
+
+// NewDevStoreWithClient creates a Store that serves the provided client, e.g. a file system backed client so that data
+// survives restarts.
+func NewDevStoreWithClient(listenAddress string, client objstore.Client) *Store {
 	rServer := remoting.NewServer(listenAddress, conf.TLSConfig{})
 	return &Store{
 		rServer: rServer,
-		store:   NewInMemStore(0),
+		store:   client,
 	}
 }
 
 func (d *Store) Start() error {
+	if err := d.store.Start(); err != nil {
+		return err
+	}
 	d.rServer.RegisterBlockingMessageHandler(remoting.ClusterMessageLocalObjStoreGet, &getMessageHandler{store: d.store})
 	d.rServer.RegisterBlockingMessageHandler(remoting.ClusterMessageLocalObjStorePut, &addMessageHandler{store: d.store})
 	d.rServer.RegisterBlockingMessageHandler(remoting.ClusterMessageLocalObjStoreDelete, &deleteMessageHandler{store: d.store})
@@ -32,11 +41,14 @@ func (d *Store) Start() error {
 }
 
 func (d *Store) Stop() error {
-	return d.rServer.Stop()
+	if err := d.rServer.Stop(); err != nil {
+		return err
+	}
+	return d.store.Stop()
 }
 
 type getMessageHandler struct {
-	store *InMemStore
+	store objstore.Client
 }
 
 func (g *getMessageHandler) HandleMessage(messageHolder remoting.MessageHolder) (remoting.ClusterMessage, error) {
@@ -49,7 +61,7 @@ func (g *getMessageHandler) HandleMessage(messageHolder remoting.MessageHolder)
 }
 
 type addMessageHandler struct {
-	store *InMemStore
+	store objstore.Client
 }
 
 func (a *addMessageHandler) HandleMessage(messageHolder remoting.MessageHolder) (remoting.ClusterMessage, error) {
@@ -69,7 +81,7 @@ func (a *addMessageHandler) HandleMessage(messageHolder remoting.MessageHolder)
 }
 
 type deleteMessageHandler struct {
-	store *InMemStore
+	store objstore.Client
 }
 
 func (d *deleteMessageHandler) HandleMessage(messageHolder remoting.MessageHolder) (remoting.ClusterMessage, error) {
@@ -79,7 +91,7 @@ func (d *deleteMessageHandler) HandleMessage(messageHolder remoting.MessageHolde
 }
 
 type deleteAllMessageHandler struct {
-	store *InMemStore
+	store objstore.Client
 }
 
 func (d *deleteAllMessageHandler) HandleMessage(messageHolder remoting.MessageHolder) (remoting.ClusterMessage, error) {
@@ -89,7 +101,7 @@ func (d *deleteAllMessageHandler) HandleMessage(messageHolder remoting.MessageHo
 }
 
 type listObjectsHandler struct {
-	store *InMemStore
+	store objstore.Client
 }
 
 func (d *listObjectsHandler) HandleMessage(messageHolder remoting.MessageHolder) (remoting.ClusterMessage, error) {
@@ -138,6 +150,16 @@ func (c *Client) Get(_ context.Context, bucket string, key string) ([]byte, erro
 	return vResp.Value, nil
 }
 
+// GetRange fetches the whole object and returns the requested range, as the dev store protocol has no ranged get.
+// The dev store is only used for development so the extra bytes transferred don't matter.
+func (c *Client) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	value, err := c.Get(ctx, bucket, key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return objstore.SliceRange(value, offset, length), nil
+}
+
 func (c *Client) Put(_ context.Context, bucket string, key string, value []byte) error {
 	req := &clustermsgs.LocalObjStorePutRequest{Bucket: bucket, Key: key, Value: value}
 	_, err := c.rClient.SendRPC(req, c.address)
@@ -3,6 +3,7 @@ package dev
 import (
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/objstore/fs"
 	"github.com/stretchr/testify/require"
 	"testing"
 )
@@ -31,3 +32,24 @@ func TestDevStore(t *testing.T) {
 
 	objstore.TestApi(t, devClient)
 }
+
+func TestDevStoreWithFsClient(t *testing.T) {
+
+	address, err := common.AddressWithPort("localhost")
+	require.NoError(t, err)
+
+	devStore := NewDevStoreWithClient(address, fs.NewFsClient(t.TempDir()))
+	err = devStore.Start()
+	require.NoError(t, err)
+
+	devClient := NewDevStoreClient(address)
+
+	defer func() {
+		//goland:noinspection GoUnhandledErrorResult
+		devClient.Stop()
+		err := devStore.Stop()
+		require.NoError(t, err)
+	}()
+
+	objstore.TestApi(t, devClient)
+}
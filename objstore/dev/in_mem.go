@@ -71,6 +71,14 @@ func (im *InMemStore) Get(_ context.Context, bucket string, key string) ([]byte,
 	return common.ByteSliceCopy(holder.value), nil //nolint:forcetypeassert
 }
 
+func (im *InMemStore) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	value, err := im.Get(ctx, bucket, key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return objstore.SliceRange(value, offset, length), nil
+}
+
 func (im *InMemStore) Put(_ context.Context, bucket string, key string, value []byte) error {
 	if err := im.checkUnavailable(); err != nil {
 		return err
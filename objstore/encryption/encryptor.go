@@ -0,0 +1,544 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/dgraph-io/ristretto"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/objstore"
+	"math"
+)
+
+var _ objstore.Client = (*encryptingClient)(nil)
+
+type Conf struct {
+	// KeyFile is the path of a key file for a FileKeyManager
+	KeyFile string
+	// KeyManager is used, if set, instead of a FileKeyManager, e.g. to wrap keys with a KMS
+	KeyManager KeyManager
+	// SegmentSize is the size of the segments of plaintext that are encrypted separately, so that ranges of an object
+	// can be read without reading the whole object
+	SegmentSize int
+	// AllowUnencryptedReads allows objects that are not encrypted to be read, e.g. objects written before encryption
+	// was enabled
+	AllowUnencryptedReads bool
+}
+
+func NewConf() Conf {
+	return Conf{
+		SegmentSize: DefaultSegmentSize,
+	}
+}
+
+const (
+	DefaultSegmentSize = 64 * 1024
+	maxSegmentSize     = 16 * 1024 * 1024
+	headerCacheSize    = 10000
+)
+
+func (c *Conf) Enabled() bool {
+	return c.KeyFile != "" || c.KeyManager != nil
+}
+
+func (c *Conf) Validate() error {
+	if c.SegmentSize <= 0 || c.SegmentSize > maxSegmentSize {
+		return errors.Errorf("invalid encryption segment size - must be > 0 and <= %d", maxSegmentSize)
+	}
+	return nil
+}
+
+/*
+Encryptor provides objstore.Clients which transparently encrypt objects as they are written and decrypt them as they
+are read, using envelope encryption. Each object is encrypted with AES-256-GCM under its own random data key, and the
+data key, wrapped by a key encryption key from the KeyManager, is stored in the header of the object.
+An object is encrypted in segments, each with its own authentication tag, so that a range of the object can be read
+and decrypted without reading the whole object. The nonce of a segment is derived from a random per object nonce and
+the segment index, and the segment index, and whether it is the last segment, are authenticated along with the header,
+so segments can't be reordered and objects can't be truncated without detection. The wrapped data key is not
+authenticated with the segments, so that objects can be rewrapped with a new key encryption key (see RewrapObjects)
+without re-encrypting them.
+The object layout is:
+
+	magic "TEKENC" | version (1 byte) | segment size (4 bytes) | plaintext length (8 bytes) | nonce (12 bytes) |
+	key id length (2 bytes) | key id | wrapped data key length (2 bytes) | wrapped data key | encrypted segments
+
+The headers of recently read objects are cached, with their unwrapped data keys, so that reading a range of an object
+usually takes a single request.
+Note that only data at rest in the object store is encrypted - data that agents cache locally, e.g. in the disk tier of
+the fetch cache, is not.
+*/
+type Encryptor struct {
+	cfg         Conf
+	keyManager  KeyManager
+	headerCache *ristretto.Cache
+}
+
+func NewEncryptor(cfg Conf) (*Encryptor, error) {
+	keyManager := cfg.KeyManager
+	if keyManager == nil {
+		if cfg.KeyFile == "" {
+			return nil, errors.New("encryption requires a key file or a key manager")
+		}
+		var err error
+		keyManager, err = NewFileKeyManager(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	headerCache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: 10 * headerCacheSize,
+		MaxCost:     headerCacheSize,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Encryptor{
+		cfg:         cfg,
+		keyManager:  keyManager,
+		headerCache: headerCache,
+	}, nil
+}
+
+// Client returns a client which encrypts objects written to, and decrypts objects read from, client
+func (e *Encryptor) Client(client objstore.Client) objstore.Client {
+	return &encryptingClient{encryptor: e, client: client}
+}
+
+var magic = []byte("TEKENC")
+
+const (
+	formatVersion       byte = 1
+	dataKeySize              = 32
+	nonceSize                = 12
+	tagSize                  = 16
+	fixedHeaderSize          = 6 + 1 + 4 + 8 + nonceSize
+	maxKeyIDLength           = math.MaxUint8
+	maxWrappedKeyLength      = 1024
+	// maxHeaderSize is the most we read to get the header of an object
+	maxHeaderSize = fixedHeaderSize + 2 + maxKeyIDLength + 2 + maxWrappedKeyLength
+)
+
+var errUnencrypted = errors.New("object is not encrypted")
+
+type header struct {
+	// fixed is the fixed size part of the header, which is authenticated with each segment
+	fixed           []byte
+	segmentSize     int64
+	plaintextLength int64
+	nonce           []byte
+	keyID           string
+	wrappedKey      []byte
+	size            int64
+	aead            cipher.AEAD
+}
+
+func (h *header) numSegments() int64 {
+	// there is always at least one segment, so that the header is authenticated
+	return max(1, (h.plaintextLength+h.segmentSize-1)/h.segmentSize)
+}
+
+// objectSize is the size of the encrypted object
+func (h *header) objectSize() int64 {
+	return h.size + h.plaintextLength + h.numSegments()*tagSize
+}
+
+func (h *header) segmentOffset(segment int64) int64 {
+	return h.size + segment*(h.segmentSize+tagSize)
+}
+
+func (h *header) segmentNonce(segment int64) []byte {
+	nonce := make([]byte, nonceSize)
+	copy(nonce, h.nonce)
+	binary.BigEndian.PutUint64(nonce[4:], binary.BigEndian.Uint64(nonce[4:])^uint64(segment))
+	return nonce
+}
+
+func (h *header) segmentAAD(segment int64) []byte {
+	aad := make([]byte, 0, len(h.fixed)+9)
+	aad = append(aad, h.fixed...)
+	aad = binary.BigEndian.AppendUint64(aad, uint64(segment))
+	if segment == h.numSegments()-1 {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+// serialize returns the header with the wrapped key set
+func (h *header) serialize() []byte {
+	buff := make([]byte, 0, len(h.fixed)+4+len(h.keyID)+len(h.wrappedKey))
+	buff = append(buff, h.fixed...)
+	buff = binary.BigEndian.AppendUint16(buff, uint16(len(h.keyID)))
+	buff = append(buff, h.keyID...)
+	buff = binary.BigEndian.AppendUint16(buff, uint16(len(h.wrappedKey)))
+	return append(buff, h.wrappedKey...)
+}
+
+func (e *Encryptor) encrypt(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	keyID, wrappedKey, err := e.wrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	fixed := make([]byte, 0, fixedHeaderSize)
+	fixed = append(fixed, magic...)
+	fixed = append(fixed, formatVersion)
+	fixed = binary.BigEndian.AppendUint32(fixed, uint32(e.cfg.SegmentSize))
+	fixed = binary.BigEndian.AppendUint64(fixed, uint64(len(plaintext)))
+	fixed = append(fixed, nonce...)
+	h := &header{
+		fixed:           fixed,
+		segmentSize:     int64(e.cfg.SegmentSize),
+		plaintextLength: int64(len(plaintext)),
+		nonce:           nonce,
+		keyID:           keyID,
+		wrappedKey:      wrappedKey,
+		aead:            aead,
+	}
+	buff := h.serialize()
+	h.size = int64(len(buff))
+	buff = append(make([]byte, 0, h.objectSize()), buff...)
+	for segment := int64(0); segment < h.numSegments(); segment++ {
+		start := segment * h.segmentSize
+		end := min(start+h.segmentSize, h.plaintextLength)
+		buff = aead.Seal(buff, h.segmentNonce(segment), plaintext[start:end], h.segmentAAD(segment))
+	}
+	return buff, nil
+}
+
+func (e *Encryptor) wrapKey(dataKey []byte) (string, []byte, error) {
+	keyID, wrappedKey, err := e.keyManager.WrapKey(dataKey)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(keyID) > maxKeyIDLength || len(wrappedKey) > maxWrappedKeyLength {
+		return "", nil, errors.New("key id or wrapped data key too long")
+	}
+	return keyID, wrappedKey, nil
+}
+
+// parseHeader parses the header at the start of buff
+func parseHeader(buff []byte) (*header, error) {
+	if len(buff) < len(magic) || !bytes.Equal(buff[:len(magic)], magic) {
+		return nil, errUnencrypted
+	}
+	if len(buff) < fixedHeaderSize+2 {
+		return nil, errors.New("invalid encrypted object - header truncated")
+	}
+	if buff[6] != formatVersion {
+		return nil, errors.Errorf("invalid encrypted object - unsupported version %d", buff[6])
+	}
+	h := &header{
+		fixed:           buff[:fixedHeaderSize],
+		segmentSize:     int64(binary.BigEndian.Uint32(buff[7:])),
+		plaintextLength: int64(binary.BigEndian.Uint64(buff[11:])),
+		nonce:           buff[19:fixedHeaderSize],
+	}
+	if h.segmentSize <= 0 || h.plaintextLength < 0 {
+		return nil, errors.New("invalid encrypted object - invalid header")
+	}
+	offset := fixedHeaderSize
+	lk := int(binary.BigEndian.Uint16(buff[offset:]))
+	offset += 2
+	if len(buff) < offset+lk+2 {
+		return nil, errors.New("invalid encrypted object - header truncated")
+	}
+	h.keyID = string(buff[offset : offset+lk])
+	offset += lk
+	lw := int(binary.BigEndian.Uint16(buff[offset:]))
+	offset += 2
+	if len(buff) < offset+lw {
+		return nil, errors.New("invalid encrypted object - header truncated")
+	}
+	h.wrappedKey = buff[offset : offset+lw]
+	h.size = int64(offset + lw)
+	return h, nil
+}
+
+// parseHeaderAndUnwrap parses the header at the start of buff and unwraps the data key
+func (e *Encryptor) parseHeaderAndUnwrap(buff []byte) (*header, error) {
+	h, err := parseHeader(buff)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := e.keyManager.UnwrapKey(h.keyID, h.wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	h.aead, err = newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// decryptSegments decrypts the consecutive segments, starting at firstSegment, in buff
+func decryptSegments(h *header, buff []byte, firstSegment int64) ([]byte, error) {
+	plaintext := make([]byte, 0, len(buff))
+	segment := firstSegment
+	for len(buff) > 0 {
+		segmentLength := min(int64(len(buff)), h.segmentSize+tagSize)
+		var err error
+		plaintext, err = h.aead.Open(plaintext, h.segmentNonce(segment), buff[:segmentLength], h.segmentAAD(segment))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt object")
+		}
+		buff = buff[segmentLength:]
+		segment++
+	}
+	return plaintext, nil
+}
+
+func (e *Encryptor) decrypt(buff []byte) ([]byte, error) {
+	h, err := e.parseHeaderAndUnwrap(buff)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buff)) != h.objectSize() {
+		return nil, errors.New("invalid encrypted object - unexpected length")
+	}
+	return decryptSegments(h, buff[h.size:], 0)
+}
+
+// RewrapObjects rewraps the data keys of the objects in the bucket with the prefix which were not wrapped with the
+// current key encryption key, so that the old key encryption keys can be retired. The objects themselves are not
+// re-encrypted. client must be the underlying client, not one provided by the Encryptor. An object is rewritten with a
+// conditional put, so one that is overwritten while being rewrapped is left alone - it will have been written with the
+// current key. Returns the number of objects rewrapped.
+func (e *Encryptor) RewrapObjects(ctx context.Context, client objstore.Client, bucket string, prefix string) (int, error) {
+	currentKeyID, err := e.keyManager.CurrentKeyID()
+	if err != nil {
+		return 0, err
+	}
+	infos, err := client.ListObjectsWithPrefix(ctx, bucket, prefix, -1)
+	if err != nil {
+		return 0, err
+	}
+	rewrapped := 0
+	for _, info := range infos {
+		buff, err := client.GetRange(ctx, bucket, info.Key, 0, maxHeaderSize)
+		if err != nil {
+			return rewrapped, err
+		}
+		if buff == nil {
+			// deleted since listed
+			continue
+		}
+		h, err := parseHeader(buff)
+		if err != nil {
+			if errors.Is(err, errUnencrypted) {
+				continue
+			}
+			return rewrapped, errors.Wrapf(err, "failed to read object %s", info.Key)
+		}
+		if h.keyID == currentKeyID {
+			continue
+		}
+		ok, err := e.rewrapObject(ctx, client, bucket, info.Key)
+		if err != nil {
+			return rewrapped, err
+		}
+		if ok {
+			rewrapped++
+		}
+	}
+	return rewrapped, nil
+}
+
+func (e *Encryptor) rewrapObject(ctx context.Context, client objstore.Client, bucket string, key string) (bool, error) {
+	// Not all object stores return etags when listing, so we get the etag here. We get it before the object, so if the
+	// object is overwritten in between, the conditional put fails.
+	info, exists, err := client.GetObjectInfo(ctx, bucket, key)
+	if err != nil || !exists {
+		return false, err
+	}
+	buff, err := client.Get(ctx, bucket, key)
+	if err != nil || buff == nil {
+		return false, err
+	}
+	h, err := parseHeader(buff)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read object %s", key)
+	}
+	dataKey, err := e.keyManager.UnwrapKey(h.keyID, h.wrappedKey)
+	if err != nil {
+		return false, err
+	}
+	keyID, wrappedKey, err := e.wrapKey(dataKey)
+	if err != nil {
+		return false, err
+	}
+	segments := buff[h.size:]
+	h.keyID = keyID
+	h.wrappedKey = wrappedKey
+	rewrapped := append(h.serialize(), segments...)
+	ok, _, err := client.PutIfMatchingEtag(ctx, bucket, key, rewrapped, info.Etag)
+	if err != nil {
+		return false, err
+	}
+	e.headerCache.Del(headerCacheKey(bucket, key))
+	return ok, nil
+}
+
+type encryptingClient struct {
+	encryptor *Encryptor
+	client    objstore.Client
+}
+
+func headerCacheKey(bucket string, key string) string {
+	return bucket + "/" + key
+}
+
+func (c *encryptingClient) GetObjectInfo(ctx context.Context, bucket string, key string) (objstore.ObjectInfo, bool, error) {
+	return c.client.GetObjectInfo(ctx, bucket, key)
+}
+
+func (c *encryptingClient) Get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	buff, err := c.client.Get(ctx, bucket, key)
+	if err != nil || buff == nil {
+		return buff, err
+	}
+	plaintext, err := c.encryptor.decrypt(buff)
+	if err != nil {
+		if errors.Is(err, errUnencrypted) && c.encryptor.cfg.AllowUnencryptedReads {
+			return buff, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read object %s", key)
+	}
+	return plaintext, nil
+}
+
+func (c *encryptingClient) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	cacheKey := headerCacheKey(bucket, key)
+	v, cached := c.encryptor.headerCache.Get(cacheKey)
+	for {
+		var h *header
+		if cached {
+			h = v.(*header)
+		} else {
+			buff, err := c.client.GetRange(ctx, bucket, key, 0, maxHeaderSize)
+			if err != nil || buff == nil {
+				return buff, err
+			}
+			h, err = c.encryptor.parseHeaderAndUnwrap(buff)
+			if err != nil {
+				if errors.Is(err, errUnencrypted) && c.encryptor.cfg.AllowUnencryptedReads {
+					return c.client.GetRange(ctx, bucket, key, offset, length)
+				}
+				return nil, errors.Wrapf(err, "failed to read object %s", key)
+			}
+			// Copy the header so we don't retain the rest of the buffer
+			h = copyHeader(h)
+			c.encryptor.headerCache.Set(cacheKey, h, 1)
+		}
+		res, err := c.getRange(ctx, h, bucket, key, offset, length)
+		if err != nil && cached {
+			// The object might have been overwritten since we cached the header
+			c.encryptor.headerCache.Del(cacheKey)
+			cached = false
+			continue
+		}
+		return res, err
+	}
+}
+
+func (c *encryptingClient) getRange(ctx context.Context, h *header, bucket string, key string, offset int64,
+	length int64) ([]byte, error) {
+	if offset >= h.plaintextLength || length <= 0 {
+		return []byte{}, nil
+	}
+	length = min(length, h.plaintextLength-offset)
+	firstSegment := offset / h.segmentSize
+	lastSegment := (offset + length - 1) / h.segmentSize
+	start := h.segmentOffset(firstSegment)
+	end := h.segmentOffset(lastSegment) + min(h.segmentSize, h.plaintextLength-lastSegment*h.segmentSize) + tagSize
+	buff, err := c.client.GetRange(ctx, bucket, key, start, end-start)
+	if err != nil {
+		return nil, err
+	}
+	if buff == nil {
+		return nil, nil
+	}
+	if int64(len(buff)) != end-start {
+		return nil, errors.Errorf("failed to read object %s - invalid encrypted object - unexpected length", key)
+	}
+	plaintext, err := decryptSegments(h, buff, firstSegment)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read object %s", key)
+	}
+	rangeStart := offset - firstSegment*h.segmentSize
+	return plaintext[rangeStart : rangeStart+length], nil
+}
+
+func copyHeader(h *header) *header {
+	hc := *h
+	hc.fixed = bytes.Clone(h.fixed)
+	hc.nonce = hc.fixed[19:fixedHeaderSize]
+	hc.wrappedKey = bytes.Clone(h.wrappedKey)
+	return &hc
+}
+
+func (c *encryptingClient) Put(ctx context.Context, bucket string, key string, value []byte) error {
+	buff, err := c.encryptor.encrypt(value)
+	if err != nil {
+		return err
+	}
+	c.encryptor.headerCache.Del(headerCacheKey(bucket, key))
+	return c.client.Put(ctx, bucket, key, buff)
+}
+
+func (c *encryptingClient) PutIfNotExists(ctx context.Context, bucket string, key string, value []byte) (bool, string, error) {
+	buff, err := c.encryptor.encrypt(value)
+	if err != nil {
+		return false, "", err
+	}
+	return c.client.PutIfNotExists(ctx, bucket, key, buff)
+}
+
+func (c *encryptingClient) PutIfMatchingEtag(ctx context.Context, bucket string, key string, value []byte, etag string) (bool, string, error) {
+	buff, err := c.encryptor.encrypt(value)
+	if err != nil {
+		return false, "", err
+	}
+	c.encryptor.headerCache.Del(headerCacheKey(bucket, key))
+	return c.client.PutIfMatchingEtag(ctx, bucket, key, buff, etag)
+}
+
+func (c *encryptingClient) Delete(ctx context.Context, bucket string, key string) error {
+	c.encryptor.headerCache.Del(headerCacheKey(bucket, key))
+	return c.client.Delete(ctx, bucket, key)
+}
+
+func (c *encryptingClient) DeleteAll(ctx context.Context, bucket string, keys []string) error {
+	for _, key := range keys {
+		c.encryptor.headerCache.Del(headerCacheKey(bucket, key))
+	}
+	return c.client.DeleteAll(ctx, bucket, keys)
+}
+
+func (c *encryptingClient) ListObjectsWithPrefix(ctx context.Context, bucket string, prefix string, maxKeys int) ([]objstore.ObjectInfo, error) {
+	return c.client.ListObjectsWithPrefix(ctx, bucket, prefix, maxKeys)
+}
+
+func (c *encryptingClient) Start() error {
+	return c.client.Start()
+}
+
+func (c *encryptingClient) Stop() error {
+	return c.client.Stop()
+}
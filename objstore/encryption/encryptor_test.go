@@ -0,0 +1,286 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptingClientApi(t *testing.T) {
+	encryptor := newTestEncryptor(t, NewConf())
+	objstore.TestApi(t, encryptor.Client(dev.NewInMemStore(0)))
+}
+
+func TestEncryptedAtRest(t *testing.T) {
+	encryptor := newTestEncryptor(t, NewConf())
+	store := dev.NewInMemStore(0)
+	client := encryptor.Client(store)
+	value := []byte("some secret value some secret value")
+	require.NoError(t, client.Put(context.Background(), "bucket1", "key1", value))
+	stored, err := store.Get(context.Background(), "bucket1", "key1")
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(stored, []byte("secret")))
+	res, err := client.Get(context.Background(), "bucket1", "key1")
+	require.NoError(t, err)
+	require.Equal(t, value, res)
+}
+
+func TestEncryptDecryptSizes(t *testing.T) {
+	cfg := NewConf()
+	cfg.SegmentSize = 100
+	encryptor := newTestEncryptor(t, cfg)
+	client := encryptor.Client(dev.NewInMemStore(0))
+	for _, size := range []int{0, 1, 99, 100, 101, 250, 1000} {
+		value := randomBytes(size)
+		require.NoError(t, client.Put(context.Background(), "bucket1", "key1", value))
+		res, err := client.Get(context.Background(), "bucket1", "key1")
+		require.NoError(t, err)
+		require.Equal(t, value, res)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	cfg := NewConf()
+	cfg.SegmentSize = 100
+	encryptor := newTestEncryptor(t, cfg)
+	store := dev.NewInMemStore(0)
+	client := encryptor.Client(store)
+	value := randomBytes(1050)
+	require.NoError(t, client.Put(context.Background(), "bucket1", "key1", value))
+	type byteRange struct {
+		offset int64
+		length int64
+	}
+	for _, r := range []byteRange{{0, 10}, {0, 1050}, {0, 2000}, {95, 10}, {100, 100}, {150, 500}, {1040, 10},
+		{1040, 100}, {1049, 1}, {1050, 10}, {2000, 10}, {10, 0}} {
+		res, err := client.GetRange(context.Background(), "bucket1", "key1", r.offset, r.length)
+		require.NoError(t, err)
+		require.Equal(t, objstore.SliceRange(value, r.offset, r.length), res)
+	}
+
+	// Overwritten by a client with a different encryptor, so the cached header is stale
+	encryptor2, err := NewEncryptor(encryptor.cfg)
+	require.NoError(t, err)
+	value2 := randomBytes(500)
+	require.NoError(t, encryptor2.Client(store).Put(context.Background(), "bucket1", "key1", value2))
+	res, err := client.GetRange(context.Background(), "bucket1", "key1", 450, 100)
+	require.NoError(t, err)
+	require.Equal(t, value2[450:], res)
+
+	res, err = client.GetRange(context.Background(), "bucket1", "does-not-exist", 0, 100)
+	require.NoError(t, err)
+	require.Nil(t, res)
+}
+
+func TestTamperedObjectFailsToDecrypt(t *testing.T) {
+	cfg := NewConf()
+	cfg.SegmentSize = 100
+	encryptor := newTestEncryptor(t, cfg)
+	store := dev.NewInMemStore(0)
+	client := encryptor.Client(store)
+	value := randomBytes(350)
+	require.NoError(t, client.Put(context.Background(), "bucket1", "key1", value))
+	stored, err := store.Get(context.Background(), "bucket1", "key1")
+	require.NoError(t, err)
+	h, err := parseHeader(stored)
+	require.NoError(t, err)
+
+	// Flip a byte in the second segment
+	tampered := bytes.Clone(stored)
+	tampered[h.segmentOffset(1)+10] ^= 0xff
+	require.NoError(t, store.Put(context.Background(), "bucket1", "key1", tampered))
+	_, err = client.Get(context.Background(), "bucket1", "key1")
+	require.Error(t, err)
+	_, err = client.GetRange(context.Background(), "bucket1", "key1", 150, 10)
+	require.Error(t, err)
+	// Other segments are still readable
+	res, err := client.GetRange(context.Background(), "bucket1", "key1", 10, 10)
+	require.NoError(t, err)
+	require.Equal(t, value[10:20], res)
+
+	// Swap the first two segments
+	tampered = bytes.Clone(stored)
+	copy(tampered[h.segmentOffset(0):], stored[h.segmentOffset(1):h.segmentOffset(2)])
+	copy(tampered[h.segmentOffset(1):], stored[h.segmentOffset(0):h.segmentOffset(1)])
+	require.NoError(t, store.Put(context.Background(), "bucket1", "key1", tampered))
+	_, err = client.Get(context.Background(), "bucket1", "key1")
+	require.Error(t, err)
+
+	// Truncate to whole segments and change the plaintext length in the header to match
+	tampered = bytes.Clone(stored[:h.segmentOffset(2)])
+	tampered[11+7] = 200 // low byte of plaintext length
+	tampered[11+6] = 0
+	require.NoError(t, store.Put(context.Background(), "bucket1", "key1", tampered))
+	_, err = client.Get(context.Background(), "bucket1", "key1")
+	require.Error(t, err)
+}
+
+func TestUnencryptedReads(t *testing.T) {
+	store := dev.NewInMemStore(0)
+	value := []byte("written before encryption was enabled")
+	require.NoError(t, store.Put(context.Background(), "bucket1", "key1", value))
+
+	client := newTestEncryptor(t, NewConf()).Client(store)
+	_, err := client.Get(context.Background(), "bucket1", "key1")
+	require.Error(t, err)
+	_, err = client.GetRange(context.Background(), "bucket1", "key1", 0, 5)
+	require.Error(t, err)
+
+	cfg := NewConf()
+	cfg.AllowUnencryptedReads = true
+	client = newTestEncryptor(t, cfg).Client(store)
+	res, err := client.Get(context.Background(), "bucket1", "key1")
+	require.NoError(t, err)
+	require.Equal(t, value, res)
+	res, err = client.GetRange(context.Background(), "bucket1", "key1", 8, 6)
+	require.NoError(t, err)
+	require.Equal(t, "before", string(res))
+}
+
+func TestKeyRotation(t *testing.T) {
+	keyFilePath := filepath.Join(t.TempDir(), "keys.json")
+	key1 := randomBytes(32)
+	writeKeyFile(t, keyFilePath, "key-1", map[string][]byte{"key-1": key1})
+	cfg := NewConf()
+	cfg.KeyFile = keyFilePath
+	encryptor, err := NewEncryptor(cfg)
+	require.NoError(t, err)
+	store := dev.NewInMemStore(0)
+	client := encryptor.Client(store)
+	require.NoError(t, client.Put(context.Background(), "bucket1", "obj1", []byte("value1")))
+	require.NoError(t, client.Put(context.Background(), "bucket1", "obj2", []byte("value2")))
+
+	// Add a new key and make it current
+	key2 := randomBytes(32)
+	writeKeyFile(t, keyFilePath, "key-2", map[string][]byte{"key-1": key1, "key-2": key2})
+	fkm := encryptor.keyManager.(*FileKeyManager)
+	require.NoError(t, fkm.maybeReload(true))
+	require.NoError(t, client.Put(context.Background(), "bucket1", "obj3", []byte("value3")))
+	requireKeyIDs(t, store, map[string]string{"obj1": "key-1", "obj2": "key-1", "obj3": "key-2"})
+
+	// Objects written with either key can be read
+	for i, key := range []string{"obj1", "obj2", "obj3"} {
+		res, err := client.Get(context.Background(), "bucket1", key)
+		require.NoError(t, err)
+		require.Equal(t, []byte{'v', 'a', 'l', 'u', 'e', byte('1' + i)}, res)
+	}
+
+	numRewrapped, err := encryptor.RewrapObjects(context.Background(), store, "bucket1", "")
+	require.NoError(t, err)
+	require.Equal(t, 2, numRewrapped)
+	requireKeyIDs(t, store, map[string]string{"obj1": "key-2", "obj2": "key-2", "obj3": "key-2"})
+	numRewrapped, err = encryptor.RewrapObjects(context.Background(), store, "bucket1", "")
+	require.NoError(t, err)
+	require.Equal(t, 0, numRewrapped)
+
+	// The old key can now be retired
+	writeKeyFile(t, keyFilePath, "key-2", map[string][]byte{"key-2": key2})
+	require.NoError(t, fkm.maybeReload(true))
+	for i, key := range []string{"obj1", "obj2", "obj3"} {
+		res, err := client.Get(context.Background(), "bucket1", key)
+		require.NoError(t, err)
+		require.Equal(t, []byte{'v', 'a', 'l', 'u', 'e', byte('1' + i)}, res)
+		res, err = client.GetRange(context.Background(), "bucket1", key, 1, 3)
+		require.NoError(t, err)
+		require.Equal(t, "alu", string(res))
+	}
+}
+
+func TestFileKeyManager(t *testing.T) {
+	keyFilePath := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, keyFilePath, "key-1", map[string][]byte{"key-1": randomBytes(32)})
+	fkm, err := NewFileKeyManager(keyFilePath)
+	require.NoError(t, err)
+	currentKeyID, err := fkm.CurrentKeyID()
+	require.NoError(t, err)
+	require.Equal(t, "key-1", currentKeyID)
+
+	dataKey := randomBytes(32)
+	keyID, wrapped, err := fkm.WrapKey(dataKey)
+	require.NoError(t, err)
+	require.Equal(t, "key-1", keyID)
+	unwrapped, err := fkm.UnwrapKey(keyID, wrapped)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, unwrapped)
+
+	// The key id is authenticated
+	_, err = fkm.UnwrapKey("key-2", wrapped)
+	require.Error(t, err)
+	wrapped[len(wrapped)-1] ^= 0xff
+	_, err = fkm.UnwrapKey(keyID, wrapped)
+	require.Error(t, err)
+}
+
+func TestInvalidKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewFileKeyManager(filepath.Join(dir, "does-not-exist.json"))
+	require.Error(t, err)
+
+	keyFilePath := filepath.Join(dir, "keys.json")
+	writeKeyFile(t, keyFilePath, "key-2", map[string][]byte{"key-1": randomBytes(32)})
+	_, err = NewFileKeyManager(keyFilePath)
+	require.Error(t, err)
+
+	writeKeyFile(t, keyFilePath, "key-1", map[string][]byte{"key-1": randomBytes(16)})
+	_, err = NewFileKeyManager(keyFilePath)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(keyFilePath, []byte("not json"), 0o600))
+	_, err = NewFileKeyManager(keyFilePath)
+	require.Error(t, err)
+}
+
+func TestConfValidate(t *testing.T) {
+	cfg := NewConf()
+	require.NoError(t, cfg.Validate())
+	require.False(t, cfg.Enabled())
+	cfg.KeyFile = "keys.json"
+	require.True(t, cfg.Enabled())
+	cfg.SegmentSize = 0
+	require.Error(t, cfg.Validate())
+}
+
+func requireKeyIDs(t *testing.T, store objstore.Client, expected map[string]string) {
+	for key, keyID := range expected {
+		stored, err := store.Get(context.Background(), "bucket1", key)
+		require.NoError(t, err)
+		h, err := parseHeader(stored)
+		require.NoError(t, err)
+		require.Equal(t, keyID, h.keyID)
+	}
+}
+
+func newTestEncryptor(t *testing.T, cfg Conf) *Encryptor {
+	keyFilePath := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyFile(t, keyFilePath, "key-1", map[string][]byte{"key-1": randomBytes(32)})
+	cfg.KeyFile = keyFilePath
+	encryptor, err := NewEncryptor(cfg)
+	require.NoError(t, err)
+	return encryptor
+}
+
+func writeKeyFile(t *testing.T, path string, currentKeyID string, keys map[string][]byte) {
+	kf := keyFile{CurrentKeyID: currentKeyID}
+	for id, key := range keys {
+		kf.Keys = append(kf.Keys, keyFileEntry{ID: id, Key: base64.StdEncoding.EncodeToString(key)})
+	}
+	bytes, err := json.Marshal(&kf)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bytes, 0o600))
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,193 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeyManager wraps and unwraps data keys with key encryption keys. It can be implemented by a KMS, so that the key
+// encryption keys never leave it.
+type KeyManager interface {
+	// CurrentKeyID returns the id of the key encryption key that new data keys are wrapped with
+	CurrentKeyID() (string, error)
+	// WrapKey encrypts the data key with the current key encryption key, returning the id of that key and the wrapped
+	// data key
+	WrapKey(dataKey []byte) (string, []byte, error)
+	// UnwrapKey decrypts a data key that was wrapped with the key encryption key with the id
+	UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error)
+}
+
+const keyFileReloadInterval = 10 * time.Second
+
+/*
+FileKeyManager is a KeyManager with key encryption keys read from a local JSON key file of the form:
+
+	{
+	  "current_key_id": "key-2",
+	  "keys": [
+	    {"id": "key-1", "key": "<base64 encoded 32 byte key>"},
+	    {"id": "key-2", "key": "<base64 encoded 32 byte key>"}
+	  ]
+	}
+
+Data keys are wrapped with AES-256-GCM under the current key. To rotate keys, add a new key to the file and make it
+current - keys that are no longer current must be kept in the file until no object has a data key wrapped with them
+(see Encryptor.RewrapObjects). The file is reloaded if it has changed, checked at most every 10 seconds, so keys can be
+rotated without restarting.
+*/
+type FileKeyManager struct {
+	lock         sync.Mutex
+	path         string
+	modTime      time.Time
+	lastChecked  time.Time
+	currentKeyID string
+	keys         map[string]cipher.AEAD
+}
+
+type keyFile struct {
+	CurrentKeyID string         `json:"current_key_id"`
+	Keys         []keyFileEntry `json:"keys"`
+}
+
+type keyFileEntry struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
+func NewFileKeyManager(path string) (*FileKeyManager, error) {
+	f := &FileKeyManager{path: path}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileKeyManager) load() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return errwrap.WithStack(err)
+	}
+	bytes, err := os.ReadFile(f.path)
+	if err != nil {
+		return errwrap.WithStack(err)
+	}
+	var kf keyFile
+	if err := json.Unmarshal(bytes, &kf); err != nil {
+		return errors.Wrapf(err, "invalid key file %s", f.path)
+	}
+	keys := make(map[string]cipher.AEAD, len(kf.Keys))
+	for _, entry := range kf.Keys {
+		if entry.ID == "" {
+			return errors.Errorf("invalid key file %s - key id must be specified", f.path)
+		}
+		if _, exists := keys[entry.ID]; exists {
+			return errors.Errorf("invalid key file %s - duplicate key id %s", f.path, entry.ID)
+		}
+		key, err := base64.StdEncoding.DecodeString(entry.Key)
+		if err != nil || len(key) != 32 {
+			return errors.Errorf("invalid key file %s - key %s must be 32 bytes, base64 encoded", f.path, entry.ID)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return err
+		}
+		keys[entry.ID] = aead
+	}
+	if _, ok := keys[kf.CurrentKeyID]; !ok {
+		return errors.Errorf("invalid key file %s - current key %s not found", f.path, kf.CurrentKeyID)
+	}
+	f.modTime = fi.ModTime()
+	f.lastChecked = time.Now()
+	f.currentKeyID = kf.CurrentKeyID
+	f.keys = keys
+	return nil
+}
+
+// maybeReload reloads the key file if it has changed, or unconditionally if force is true. Must be called with the
+// lock held.
+func (f *FileKeyManager) maybeReload(force bool) error {
+	if force {
+		return f.load()
+	}
+	if time.Since(f.lastChecked) < keyFileReloadInterval {
+		return nil
+	}
+	f.lastChecked = time.Now()
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return errwrap.WithStack(err)
+	}
+	if fi.ModTime().Equal(f.modTime) {
+		return nil
+	}
+	return f.load()
+}
+
+func (f *FileKeyManager) CurrentKeyID() (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.maybeReload(false); err != nil {
+		return "", err
+	}
+	return f.currentKeyID, nil
+}
+
+func (f *FileKeyManager) WrapKey(dataKey []byte) (string, []byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.maybeReload(false); err != nil {
+		return "", nil, err
+	}
+	aead := f.keys[f.currentKeyID]
+	wrapped := make([]byte, aead.NonceSize(), aead.NonceSize()+len(dataKey)+aead.Overhead())
+	if _, err := rand.Read(wrapped); err != nil {
+		return "", nil, errwrap.WithStack(err)
+	}
+	// The key id is authenticated so a wrapped key can't be passed off as being wrapped by a different key
+	wrapped = aead.Seal(wrapped, wrapped, dataKey, []byte(f.currentKeyID))
+	return f.currentKeyID, wrapped, nil
+}
+
+func (f *FileKeyManager) UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	aead, ok := f.keys[keyID]
+	if !ok {
+		// The key might have been added since we last loaded the file
+		if err := f.maybeReload(true); err != nil {
+			return nil, err
+		}
+		aead, ok = f.keys[keyID]
+		if !ok {
+			return nil, errors.Errorf("unknown key encryption key %s", keyID)
+		}
+	}
+	if len(wrappedKey) < aead.NonceSize() {
+		return nil, errors.New("invalid wrapped data key")
+	}
+	dataKey, err := aead.Open(nil, wrappedKey[:aead.NonceSize()], wrappedKey[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unwrap data key with key encryption key %s", keyID)
+	}
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	return aead, nil
+}
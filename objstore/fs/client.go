@@ -0,0 +1,410 @@
+package fs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/objstore"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tmpDirName   = ".tmp"
+	lockFileName = ".lock"
+)
+
+var _ objstore.Client = &Client{}
+
+// Client is an objstore.Client that stores objects as files on the local filesystem. Each bucket is a directory under
+// the root directory and each object is a file in the bucket directory, with the key escaped so that it is a valid
+// file name. Writes go to a temporary file which is then renamed into place, so readers never see a partially written
+// object and an object survives a crash once Put returns. Etags are the hex encoded sha256 of the object contents.
+type Client struct {
+	dir      string
+	condLock sync.Mutex
+}
+
+func NewFsClient(dir string) *Client {
+	return &Client{dir: dir}
+}
+
+func (c *Client) Start() error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return errwrap.WithStack(err)
+	}
+	// Any temp files left behind are from writes that never completed, e.g. because we crashed
+	if err := os.RemoveAll(c.tmpDir()); err != nil {
+		return errwrap.WithStack(err)
+	}
+	return errwrap.WithStack(os.MkdirAll(c.tmpDir(), 0o755))
+}
+
+func (c *Client) Stop() error {
+	return nil
+}
+
+func (c *Client) GetObjectInfo(_ context.Context, bucket string, key string) (objstore.ObjectInfo, bool, error) {
+	path, err := c.objectPath(bucket, key)
+	if err != nil {
+		return objstore.ObjectInfo{}, false, err
+	}
+	value, err := readFile(path)
+	if err != nil || value == nil {
+		return objstore.ObjectInfo{}, false, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// deleted since we read it
+			return objstore.ObjectInfo{}, false, nil
+		}
+		return objstore.ObjectInfo{}, false, errwrap.WithStack(err)
+	}
+	return objstore.ObjectInfo{
+		Key:          key,
+		LastModified: fi.ModTime().UTC(),
+		Etag:         etag(value),
+	}, true, nil
+}
+
+func (c *Client) Get(_ context.Context, bucket string, key string) ([]byte, error) {
+	path, err := c.objectPath(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	return readFile(path)
+}
+
+func (c *Client) GetRange(_ context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	path, err := c.objectPath(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errwrap.WithStack(err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	size := fi.Size()
+	if offset >= size {
+		return []byte{}, nil
+	}
+	if offset+length > size {
+		length = size - offset
+	}
+	buff := make([]byte, length)
+	if _, err := f.ReadAt(buff, offset); err != nil {
+		return nil, errwrap.WithStack(err)
+	}
+	return buff, nil
+}
+
+func (c *Client) Put(_ context.Context, bucket string, key string, value []byte) error {
+	path, err := c.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	return c.writeFile(path, value)
+}
+
+func (c *Client) PutIfNotExists(_ context.Context, bucket string, key string, value []byte) (bool, string, error) {
+	path, err := c.objectPath(bucket, key)
+	if err != nil {
+		return false, "", err
+	}
+	unlock, err := c.lockBucket(bucket)
+	if err != nil {
+		return false, "", err
+	}
+	defer unlock()
+	if _, err := os.Stat(path); err == nil {
+		return false, "", nil
+	} else if !os.IsNotExist(err) {
+		return false, "", errwrap.WithStack(err)
+	}
+	if err := c.writeFile(path, value); err != nil {
+		return false, "", err
+	}
+	return true, etag(value), nil
+}
+
+func (c *Client) PutIfMatchingEtag(_ context.Context, bucket string, key string, value []byte,
+	etagToMatch string) (bool, string, error) {
+	path, err := c.objectPath(bucket, key)
+	if err != nil {
+		return false, "", err
+	}
+	unlock, err := c.lockBucket(bucket)
+	if err != nil {
+		return false, "", err
+	}
+	defer unlock()
+	current, err := readFile(path)
+	if err != nil {
+		return false, "", err
+	}
+	if current == nil || etag(current) != etagToMatch {
+		return false, "", nil
+	}
+	if err := c.writeFile(path, value); err != nil {
+		return false, "", err
+	}
+	return true, etag(value), nil
+}
+
+func (c *Client) Delete(_ context.Context, bucket string, key string) error {
+	path, err := c.objectPath(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errwrap.WithStack(err)
+	}
+	return nil
+}
+
+func (c *Client) DeleteAll(ctx context.Context, bucket string, keys []string) error {
+	for _, key := range keys {
+		if err := c.Delete(ctx, bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) ListObjectsWithPrefix(_ context.Context, bucket string, prefix string, maxKeys int) ([]objstore.ObjectInfo, error) {
+	if err := checkBucketName(bucket); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(c.dir, bucket))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errwrap.WithStack(err)
+	}
+	if maxKeys == -1 {
+		maxKeys = math.MaxInt
+	}
+	var infos []objstore.ObjectInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || entry.IsDir() {
+			// internal file
+			continue
+		}
+		key, err := unescapeKey(name)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				// deleted since we listed the directory
+				continue
+			}
+			return nil, errwrap.WithStack(err)
+		}
+		infos = append(infos, objstore.ObjectInfo{
+			Key:          key,
+			LastModified: fi.ModTime().UTC(),
+		})
+	}
+	// escaping does not preserve ordering, so we must sort on the unescaped keys
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Key < infos[j].Key
+	})
+	if len(infos) > maxKeys {
+		infos = infos[:maxKeys]
+	}
+	return infos, nil
+}
+
+func (c *Client) tmpDir() string {
+	return filepath.Join(c.dir, tmpDirName)
+}
+
+func (c *Client) objectPath(bucket string, key string) (string, error) {
+	if err := checkBucketName(bucket); err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", common.NewTektiteErrorf(common.InvalidConfiguration, "object key must not be empty")
+	}
+	return filepath.Join(c.dir, bucket, escapeKey(key)), nil
+}
+
+// writeFile writes the value to a temp file, syncs it, then renames it to path. The rename is atomic so a concurrent
+// reader sees either the old or the new contents.
+func (c *Client) writeFile(path string, value []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errwrap.WithStack(err)
+	}
+	f, err := os.CreateTemp(c.tmpDir(), "obj-")
+	if err != nil {
+		return errwrap.WithStack(err)
+	}
+	tmpPath := f.Name()
+	if _, err := f.Write(value); err != nil {
+		closeAndRemove(f)
+		return errwrap.WithStack(err)
+	}
+	if err := f.Sync(); err != nil {
+		closeAndRemove(f)
+		return errwrap.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		os.Remove(tmpPath)
+		return errwrap.WithStack(err)
+	}
+	// file system timestamps can lag the wall clock, so set last-modified explicitly
+	now := time.Now()
+	if err := os.Chtimes(tmpPath, now, now); err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		os.Remove(tmpPath)
+		return errwrap.WithStack(err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		os.Remove(tmpPath)
+		return errwrap.WithStack(err)
+	}
+	// sync the directory so the rename itself is durable
+	return syncDir(dir)
+}
+
+// lockBucket serializes conditional puts on a bucket, both within this process and, using a lock file, with other
+// processes sharing the same directory.
+func (c *Client) lockBucket(bucket string) (func(), error) {
+	c.condLock.Lock()
+	dir := filepath.Join(c.dir, bucket)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		c.condLock.Unlock()
+		return nil, errwrap.WithStack(err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		c.condLock.Unlock()
+		return nil, errwrap.WithStack(err)
+	}
+	if err := lockFile(f); err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		f.Close()
+		c.condLock.Unlock()
+		return nil, errwrap.WithStack(err)
+	}
+	return func() {
+		//goland:noinspection GoUnhandledErrorResult
+		unlockFile(f)
+		//goland:noinspection GoUnhandledErrorResult
+		f.Close()
+		c.condLock.Unlock()
+	}, nil
+}
+
+func readFile(path string) ([]byte, error) {
+	value, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errwrap.WithStack(err)
+	}
+	if value == nil {
+		// empty object - must not be confused with one that does not exist
+		value = []byte{}
+	}
+	return value, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errwrap.WithStack(err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer d.Close()
+	return errwrap.WithStack(d.Sync())
+}
+
+func closeAndRemove(f *os.File) {
+	//goland:noinspection GoUnhandledErrorResult
+	f.Close()
+	//goland:noinspection GoUnhandledErrorResult
+	os.Remove(f.Name())
+}
+
+func etag(value []byte) string {
+	h := sha256.Sum256(value)
+	return hex.EncodeToString(h[:])
+}
+
+func checkBucketName(bucket string) error {
+	if bucket == "" || strings.ContainsAny(bucket, `/\`) || strings.HasPrefix(bucket, ".") {
+		return common.NewTektiteErrorf(common.InvalidConfiguration, "invalid bucket name '%s'", bucket)
+	}
+	return nil
+}
+
+// escapeKey converts a key into a file name. Alphanumerics, '-', '_' and '.' are kept, anything else is written as
+// '%' followed by two hex digits. A leading '.' is also escaped so that names starting with '.' are free for internal
+// files.
+func escapeKey(key string) string {
+	var sb strings.Builder
+	for i := 0; i < len(key); i++ {
+		b := key[i]
+		if isUnreserved(b) && !(i == 0 && b == '.') {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return sb.String()
+}
+
+func unescapeKey(name string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if b != '%' {
+			sb.WriteByte(b)
+			continue
+		}
+		if i+2 >= len(name) {
+			return "", errwrap.Errorf("invalid object file name '%s'", name)
+		}
+		decoded, err := hex.DecodeString(name[i+1 : i+3])
+		if err != nil {
+			return "", errwrap.Errorf("invalid object file name '%s'", name)
+		}
+		sb.WriteByte(decoded[0])
+		i += 2
+	}
+	return sb.String(), nil
+}
+
+func isUnreserved(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '-' || b == '_' || b == '.'
+}
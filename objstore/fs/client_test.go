@@ -0,0 +1,202 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFsClient(t *testing.T) {
+	client := startClient(t, t.TempDir())
+	objstore.TestApi(t, client)
+}
+
+func TestPersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	client := startClient(t, dir)
+	ctx := context.Background()
+	err := client.Put(ctx, "bucket1", "key1", []byte("val1"))
+	require.NoError(t, err)
+	err = client.Put(ctx, "bucket1", "some/nested/key2", []byte("val2"))
+	require.NoError(t, err)
+	err = client.Stop()
+	require.NoError(t, err)
+
+	client = startClient(t, dir)
+	val, err := client.Get(ctx, "bucket1", "key1")
+	require.NoError(t, err)
+	require.Equal(t, "val1", string(val))
+	val, err = client.Get(ctx, "bucket1", "some/nested/key2")
+	require.NoError(t, err)
+	require.Equal(t, "val2", string(val))
+
+	infos, err := client.ListObjectsWithPrefix(ctx, "bucket1", "", -1)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(infos))
+	require.Equal(t, "key1", infos[0].Key)
+	require.Equal(t, "some/nested/key2", infos[1].Key)
+}
+
+func TestStartRemovesTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	client := startClient(t, dir)
+	err := client.Stop()
+	require.NoError(t, err)
+	tmpFile := filepath.Join(dir, tmpDirName, "obj-12345")
+	err = os.WriteFile(tmpFile, []byte("partial"), 0o644)
+	require.NoError(t, err)
+
+	startClient(t, dir)
+	_, err = os.Stat(tmpFile)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestEtags(t *testing.T) {
+	client := startClient(t, t.TempDir())
+	ctx := context.Background()
+
+	ok, etag1, err := client.PutIfNotExists(ctx, "bucket1", "key1", []byte("val1"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	info, exists, err := client.GetObjectInfo(ctx, "bucket1", "key1")
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, etag1, info.Etag)
+	require.Equal(t, "key1", info.Key)
+
+	// wrong etag
+	ok, _, err = client.PutIfMatchingEtag(ctx, "bucket1", "key1", []byte("val2"), "not-the-etag")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, etag2, err := client.PutIfMatchingEtag(ctx, "bucket1", "key1", []byte("val2"), etag1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotEqual(t, etag1, etag2)
+
+	// old etag no longer matches
+	ok, _, err = client.PutIfMatchingEtag(ctx, "bucket1", "key1", []byte("val3"), etag1)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	val, err := client.Get(ctx, "bucket1", "key1")
+	require.NoError(t, err)
+	require.Equal(t, "val2", string(val))
+
+	// no object
+	ok, _, err = client.PutIfMatchingEtag(ctx, "bucket1", "key2", []byte("val1"), etag1)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, exists, err = client.GetObjectInfo(ctx, "bucket1", "key2")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestEmptyObject(t *testing.T) {
+	client := startClient(t, t.TempDir())
+	ctx := context.Background()
+	err := client.Put(ctx, "bucket1", "key1", []byte{})
+	require.NoError(t, err)
+	val, err := client.Get(ctx, "bucket1", "key1")
+	require.NoError(t, err)
+	require.NotNil(t, val)
+	require.Equal(t, 0, len(val))
+}
+
+func TestConcurrentConditionalPuts(t *testing.T) {
+	dir := t.TempDir()
+	// Use two clients on the same directory to simulate separate processes
+	clients := []*Client{startClient(t, dir), NewFsClient(dir)}
+	ctx := context.Background()
+
+	ok, _, err := clients[0].PutIfNotExists(ctx, "bucket1", "counter", []byte("0"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	numGoroutines := 10
+	numIncrements := 20
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines)
+	errCh := make(chan error, numGoroutines)
+	for i := 0; i < numGoroutines; i++ {
+		client := clients[i%len(clients)]
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numIncrements; j++ {
+				for {
+					info, _, err := client.GetObjectInfo(ctx, "bucket1", "counter")
+					if err != nil {
+						errCh <- err
+						return
+					}
+					val, err := client.Get(ctx, "bucket1", "counter")
+					if err != nil {
+						errCh <- err
+						return
+					}
+					var count int
+					_, err = fmt.Sscanf(string(val), "%d", &count)
+					if err != nil {
+						errCh <- err
+						return
+					}
+					if info.Etag != etag(val) {
+						// changed between calls
+						continue
+					}
+					ok, _, err := client.PutIfMatchingEtag(ctx, "bucket1", "counter", []byte(fmt.Sprintf("%d", count+1)), info.Etag)
+					if err != nil {
+						errCh <- err
+						return
+					}
+					if ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		require.NoError(t, err)
+	}
+	val, err := clients[0].Get(ctx, "bucket1", "counter")
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("%d", numGoroutines*numIncrements), string(val))
+}
+
+func TestEscapeKey(t *testing.T) {
+	keys := []string{"foo", "foo/bar", ".hidden", "a.b.c", "with space", "100%", "..", "über", "a\\b:c"}
+	for _, key := range keys {
+		escaped := escapeKey(key)
+		require.NotContains(t, escaped, "/")
+		require.NotEqual(t, '.', escaped[0])
+		unescaped, err := unescapeKey(escaped)
+		require.NoError(t, err)
+		require.Equal(t, key, unescaped)
+	}
+}
+
+func TestInvalidBucket(t *testing.T) {
+	client := startClient(t, t.TempDir())
+	for _, bucket := range []string{"", ".tmp", "foo/bar"} {
+		err := client.Put(context.Background(), bucket, "key1", []byte("val1"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid bucket name")
+	}
+}
+
+func startClient(t *testing.T, dir string) *Client {
+	client := NewFsClient(dir)
+	err := client.Start()
+	require.NoError(t, err)
+	return client
+}
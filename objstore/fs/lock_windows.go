@@ -0,0 +1,15 @@
+//go:build windows
+
+package fs
+
+import "os"
+
+// File locking is not supported on windows, so conditional puts are only serialized within a single process.
+
+func lockFile(_ *os.File) error {
+	return nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
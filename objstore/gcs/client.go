@@ -0,0 +1,244 @@
+package gcs
+
+import (
+	"cloud.google.com/go/storage"
+	"context"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/objstore"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+var _ objstore.Client = &Client{}
+
+// Client is an objstore.Client for Google Cloud Storage. The etag of an object is its generation number, and
+// conditional puts use generation preconditions, which unlike GCS etags are guaranteed to change on every write.
+type Client struct {
+	cfg    Conf
+	client *storage.Client
+}
+
+type Conf struct {
+	// Endpoint overrides the default GCS endpoint, e.g. to use an emulator. Optional.
+	Endpoint string
+	// CredentialsFile is the path to a service account key file. If not set, application default credentials are used.
+	CredentialsFile string
+	// WithoutAuthentication disables authentication, for use with an emulator.
+	WithoutAuthentication bool
+}
+
+func NewGcsClient(cfg Conf) *Client {
+	return &Client{
+		cfg: cfg,
+	}
+}
+
+func (g *Client) GetObjectInfo(ctx context.Context, bucket string, key string) (objstore.ObjectInfo, bool, error) {
+	attrs, err := g.client.Bucket(bucket).Object(key).Attrs(ctx)
+	if err != nil {
+		if errwrap.Is(err, storage.ErrObjectNotExist) {
+			return objstore.ObjectInfo{}, false, nil
+		}
+		return objstore.ObjectInfo{}, false, maybeConvertError(err)
+	}
+	return objstore.ObjectInfo{
+		Key:          key,
+		LastModified: attrs.Updated,
+		Etag:         generationToEtag(attrs.Generation),
+	}, true, nil
+}
+
+func (g *Client) Get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	reader, err := g.client.Bucket(bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		if errwrap.Is(err, storage.ErrObjectNotExist) {
+			// does not exist
+			return nil, nil
+		}
+		return nil, maybeConvertError(err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer reader.Close()
+	buff, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, maybeConvertError(err)
+	}
+	return buff, nil
+}
+
+func (g *Client) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	reader, err := g.client.Bucket(bucket).Object(key).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		if errwrap.Is(err, storage.ErrObjectNotExist) {
+			// does not exist
+			return nil, nil
+		}
+		if isStatus(err, http.StatusRequestedRangeNotSatisfiable) {
+			// offset is past the end of the object
+			return []byte{}, nil
+		}
+		return nil, maybeConvertError(err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer reader.Close()
+	buff, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, maybeConvertError(err)
+	}
+	return buff, nil
+}
+
+func (g *Client) Put(ctx context.Context, bucket string, key string, value []byte) error {
+	_, err := g.write(ctx, g.client.Bucket(bucket).Object(key), value)
+	return maybeConvertError(err)
+}
+
+func (g *Client) PutIfNotExists(ctx context.Context, bucket string, key string, value []byte) (bool, string, error) {
+	obj := g.client.Bucket(bucket).Object(key).If(storage.Conditions{DoesNotExist: true})
+	generation, err := g.write(ctx, obj, value)
+	if err != nil {
+		if isStatus(err, http.StatusPreconditionFailed) {
+			// Pre-condition failed - this means key already exists
+			return false, "", nil
+		}
+		return false, "", maybeConvertError(err)
+	}
+	return true, generationToEtag(generation), nil
+}
+
+func (g *Client) PutIfMatchingEtag(ctx context.Context, bucket string, key string, value []byte, etag string) (bool, string, error) {
+	generation, err := strconv.ParseInt(etag, 10, 64)
+	if err != nil || generation == 0 {
+		// Not an etag returned by this client so cannot match
+		return false, "", nil
+	}
+	obj := g.client.Bucket(bucket).Object(key).If(storage.Conditions{GenerationMatch: generation})
+	newGeneration, err := g.write(ctx, obj, value)
+	if err != nil {
+		if isStatus(err, http.StatusPreconditionFailed, http.StatusNotFound) {
+			// Generation does not match, or the object does not exist
+			return false, "", nil
+		}
+		return false, "", maybeConvertError(err)
+	}
+	return true, generationToEtag(newGeneration), nil
+}
+
+func (g *Client) write(ctx context.Context, obj *storage.ObjectHandle, value []byte) (int64, error) {
+	writer := obj.NewWriter(ctx)
+	// Objects are small enough to upload in a single request, which avoids the overhead of a resumable upload
+	writer.ChunkSize = 0
+	if _, err := writer.Write(value); err != nil {
+		//goland:noinspection GoUnhandledErrorResult
+		writer.Close()
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+	return writer.Attrs().Generation, nil
+}
+
+func (g *Client) Delete(ctx context.Context, bucket string, key string) error {
+	err := g.client.Bucket(bucket).Object(key).Delete(ctx)
+	if err != nil && !errwrap.Is(err, storage.ErrObjectNotExist) {
+		return maybeConvertError(err)
+	}
+	return nil
+}
+
+func (g *Client) DeleteAll(ctx context.Context, bucket string, keys []string) error {
+	for _, key := range keys {
+		if err := g.Delete(ctx, bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Client) ListObjectsWithPrefix(ctx context.Context, bucket string, prefix string, maxKeys int) ([]objstore.ObjectInfo, error) {
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name", "Updated"}); err != nil {
+		return nil, err
+	}
+	if maxKeys == -1 {
+		maxKeys = math.MaxInt
+	}
+	var infos []objstore.ObjectInfo
+	it := g.client.Bucket(bucket).Objects(ctx, query)
+	for len(infos) < maxKeys {
+		attrs, err := it.Next()
+		if errwrap.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, maybeConvertError(err)
+		}
+		infos = append(infos, objstore.ObjectInfo{
+			Key:          attrs.Name,
+			LastModified: attrs.Updated,
+		})
+	}
+	return infos, nil
+}
+
+func (g *Client) MakeBucket(ctx context.Context, projectID string, bucket string) error {
+	return g.client.Bucket(bucket).Create(ctx, projectID, nil)
+}
+
+func (g *Client) Start() error {
+	var opts []option.ClientOption
+	if g.cfg.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(g.cfg.Endpoint))
+	}
+	if g.cfg.WithoutAuthentication {
+		opts = append(opts, option.WithoutAuthentication())
+	} else if g.cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(g.cfg.CredentialsFile))
+	}
+	client, err := storage.NewClient(context.Background(), opts...)
+	if err != nil {
+		return err
+	}
+	g.client = client
+	return nil
+}
+
+func (g *Client) Stop() error {
+	if g.client == nil {
+		return nil
+	}
+	err := g.client.Close()
+	g.client = nil
+	return err
+}
+
+func generationToEtag(generation int64) string {
+	return strconv.FormatInt(generation, 10)
+}
+
+func isStatus(err error, statusCodes ...int) bool {
+	var apiErr *googleapi.Error
+	if !errwrap.As(err, &apiErr) {
+		return false
+	}
+	for _, statusCode := range statusCodes {
+		if apiErr.Code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func maybeConvertError(err error) error {
+	if err == nil {
+		return err
+	}
+	return common.NewTektiteErrorf(common.Unavailable, err.Error())
+}
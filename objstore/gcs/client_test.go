@@ -0,0 +1,66 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"testing"
+)
+
+func TestGcsObjStore(t *testing.T) {
+
+	ctx := context.Background()
+
+	gcsContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "fsouza/fake-gcs-server:1.50.2",
+			Cmd:          []string{"-scheme", "http", "-port", "4443"},
+			ExposedPorts: []string{"4443/tcp"},
+			WaitingFor:   wait.ForListeningPort("4443/tcp"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+
+	// Clean up the container
+	defer func() {
+		err := gcsContainer.Terminate(ctx)
+		require.NoError(t, err)
+	}()
+
+	ip, err := gcsContainer.Host(ctx)
+	require.NoError(t, err)
+
+	port, err := gcsContainer.MappedPort(ctx, "4443")
+	require.NoError(t, err)
+
+	cfg := Conf{
+		Endpoint:              fmt.Sprintf("http://%s:%d/storage/v1/", ip, port.Int()),
+		WithoutAuthentication: true,
+	}
+
+	client := NewGcsClient(cfg)
+	err = client.Start()
+	require.NoError(t, err)
+
+	// Make the buckets needed by the tests
+	for i := 0; i < objstore.NumTestBuckets; i++ {
+		bucket := fmt.Sprintf("%s%.2d", objstore.TestBucketPrefix, i)
+		err = client.MakeBucket(ctx, "test-project", bucket)
+		require.NoError(t, err)
+	}
+
+	objstore.TestApi(t, client)
+}
+
+func TestPutIfMatchingEtagInvalidEtag(t *testing.T) {
+	// An etag that is not a generation number can never match, so no request needs to be made
+	client := NewGcsClient(Conf{})
+	ok, etag, err := client.PutIfMatchingEtag(context.Background(), "bucket1", "key1", []byte("val1"), "not-a-generation")
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, "", etag)
+}
@@ -0,0 +1,383 @@
+package metering
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/objstore"
+	"golang.org/x/sync/singleflight"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Subsystems which make object store requests in the agent
+const (
+	SubsystemController  = "controller"
+	SubsystemMembership  = "membership"
+	SubsystemPusher      = "pusher"
+	SubsystemFetcher     = "fetcher"
+	SubsystemFetchCache  = "fetchcache"
+	SubsystemCompaction  = "compaction"
+	SubsystemReplication = "replication"
+)
+
+type Conf struct {
+	// Prices are used to estimate the cost of the requests made
+	Prices Prices
+	// CoalesceGets determines whether concurrent Gets of the same object are coalesced into a single request
+	CoalesceGets bool
+}
+
+// Prices are the prices, in whatever currency the bill is in, per 1000 requests of each type. Object stores charge
+// the same for a conditional put as for a put, and for a HEAD as for a GET.
+type Prices struct {
+	GetRequests    float64
+	PutRequests    float64
+	ListRequests   float64
+	DeleteRequests float64
+}
+
+func NewConf() Conf {
+	return Conf{
+		Prices: DefaultPrices,
+	}
+}
+
+// DefaultPrices are the prices in USD of S3 standard storage
+var DefaultPrices = Prices{
+	GetRequests:    0.0004,
+	PutRequests:    0.005,
+	ListRequests:   0.005,
+	DeleteRequests: 0,
+}
+
+func (c *Conf) Validate() error {
+	if c.Prices.GetRequests < 0 || c.Prices.PutRequests < 0 || c.Prices.ListRequests < 0 || c.Prices.DeleteRequests < 0 {
+		return errors.New("invalid object store request price - must be >= 0")
+	}
+	return nil
+}
+
+type requestType int
+
+const (
+	requestTypeGet requestType = iota
+	requestTypePut
+	requestTypeList
+	requestTypeDelete
+	numRequestTypes
+)
+
+var requestTypeNames = [numRequestTypes]string{"get", "put", "list", "delete"}
+
+func (p *Prices) price(reqType requestType) float64 {
+	switch reqType {
+	case requestTypeGet:
+		return p.GetRequests
+	case requestTypePut:
+		return p.PutRequests
+	case requestTypeList:
+		return p.ListRequests
+	case requestTypeDelete:
+		return p.DeleteRequests
+	default:
+		panic("unknown request type")
+	}
+}
+
+/*
+Meter counts the requests made to an object store, and the bytes read and written, by the subsystem that made them and
+by bucket. From the counts and the configured prices it estimates what the requests cost. Each subsystem is given its
+own Client from the Meter which records the requests against it.
+
+If Gets are coalesced, a Get of an object which is already being fetched waits for that request and shares its result
+rather than making another request. Only the request actually made is counted, against the subsystem that made it; the
+Gets that shared its result are counted separately.
+*/
+type Meter struct {
+	cfg       Conf
+	startTime time.Time
+	counters  sync.Map // counterKey -> *counters
+	getGroup  singleflight.Group
+}
+
+func NewMeter(cfg Conf) *Meter {
+	return &Meter{
+		cfg:       cfg,
+		startTime: time.Now(),
+	}
+}
+
+type counterKey struct {
+	subsystem string
+	bucket    string
+}
+
+type counters struct {
+	requests      [numRequestTypes]atomic.Int64
+	errors        [numRequestTypes]atomic.Int64
+	bytesRead     atomic.Int64
+	bytesWritten  atomic.Int64
+	coalescedGets atomic.Int64
+}
+
+func (m *Meter) getCounters(subsystem string, bucket string) *counters {
+	key := counterKey{subsystem: subsystem, bucket: bucket}
+	c, ok := m.counters.Load(key)
+	if !ok {
+		c, _ = m.counters.LoadOrStore(key, &counters{})
+	}
+	return c.(*counters)
+}
+
+// Client returns a client which makes requests using the given client and records them against the subsystem
+func (m *Meter) Client(subsystem string, client objstore.Client) objstore.Client {
+	return &meteredClient{
+		meter:     m,
+		subsystem: subsystem,
+		client:    client,
+	}
+}
+
+type ReportEntry struct {
+	Subsystem      string  `json:"subsystem"`
+	Bucket         string  `json:"bucket"`
+	GetRequests    int64   `json:"get_requests"`
+	PutRequests    int64   `json:"put_requests"`
+	ListRequests   int64   `json:"list_requests"`
+	DeleteRequests int64   `json:"delete_requests"`
+	FailedRequests int64   `json:"failed_requests"`
+	CoalescedGets  int64   `json:"coalesced_gets"`
+	BytesRead      int64   `json:"bytes_read"`
+	BytesWritten   int64   `json:"bytes_written"`
+	EstimatedCost  float64 `json:"estimated_cost"`
+}
+
+type Report struct {
+	// Since is when the meter started counting
+	Since              time.Time     `json:"since"`
+	Entries            []ReportEntry `json:"entries"`
+	TotalRequests      int64         `json:"total_requests"`
+	TotalEstimatedCost float64       `json:"total_estimated_cost"`
+}
+
+// Report returns the counts for each subsystem and bucket, ordered by subsystem then bucket
+func (m *Meter) Report() Report {
+	report := Report{Since: m.startTime}
+	m.counters.Range(func(k, v any) bool {
+		key := k.(counterKey)
+		c := v.(*counters)
+		entry := ReportEntry{
+			Subsystem:      key.subsystem,
+			Bucket:         key.bucket,
+			GetRequests:    c.requests[requestTypeGet].Load(),
+			PutRequests:    c.requests[requestTypePut].Load(),
+			ListRequests:   c.requests[requestTypeList].Load(),
+			DeleteRequests: c.requests[requestTypeDelete].Load(),
+			CoalescedGets:  c.coalescedGets.Load(),
+			BytesRead:      c.bytesRead.Load(),
+			BytesWritten:   c.bytesWritten.Load(),
+		}
+		for reqType := requestType(0); reqType < numRequestTypes; reqType++ {
+			entry.FailedRequests += c.errors[reqType].Load()
+			numRequests := c.requests[reqType].Load()
+			report.TotalRequests += numRequests
+			entry.EstimatedCost += m.cost(reqType, numRequests)
+		}
+		report.TotalEstimatedCost += entry.EstimatedCost
+		report.Entries = append(report.Entries, entry)
+		return true
+	})
+	sort.Slice(report.Entries, func(i, j int) bool {
+		e1, e2 := report.Entries[i], report.Entries[j]
+		if e1.Subsystem != e2.Subsystem {
+			return e1.Subsystem < e2.Subsystem
+		}
+		return e1.Bucket < e2.Bucket
+	})
+	return report
+}
+
+func (m *Meter) cost(reqType requestType, numRequests int64) float64 {
+	return float64(numRequests) * m.cfg.Prices.price(reqType) / 1000
+}
+
+// WriteMetrics writes the counts in the Prometheus text exposition format
+func (m *Meter) WriteMetrics(w io.Writer) error {
+	report := m.Report()
+	var sb strings.Builder
+	writeMetricHeader(&sb, "tektite_objstore_requests_total", "Requests made to the object store.", "counter")
+	for _, entry := range report.Entries {
+		for reqType, count := range []int64{entry.GetRequests, entry.PutRequests, entry.ListRequests, entry.DeleteRequests} {
+			sb.WriteString(fmt.Sprintf("tektite_objstore_requests_total{%s,type=\"%s\"} %d\n", entryLabels(entry),
+				requestTypeNames[reqType], count))
+		}
+	}
+	for _, def := range []struct {
+		name  string
+		help  string
+		value func(entry *ReportEntry) string
+	}{
+		{"tektite_objstore_failed_requests_total", "Requests made to the object store which returned an error.",
+			func(entry *ReportEntry) string { return strconv.FormatInt(entry.FailedRequests, 10) }},
+		{"tektite_objstore_coalesced_gets_total", "Gets which shared the result of a concurrent Get of the same object instead of making a request.",
+			func(entry *ReportEntry) string { return strconv.FormatInt(entry.CoalescedGets, 10) }},
+		{"tektite_objstore_read_bytes_total", "Bytes read from the object store.",
+			func(entry *ReportEntry) string { return strconv.FormatInt(entry.BytesRead, 10) }},
+		{"tektite_objstore_written_bytes_total", "Bytes written to the object store.",
+			func(entry *ReportEntry) string { return strconv.FormatInt(entry.BytesWritten, 10) }},
+		{"tektite_objstore_estimated_cost_total", "Estimated cost of the requests made to the object store.",
+			func(entry *ReportEntry) string { return strconv.FormatFloat(entry.EstimatedCost, 'f', -1, 64) }},
+	} {
+		writeMetricHeader(&sb, def.name, def.help, "counter")
+		for i := range report.Entries {
+			entry := &report.Entries[i]
+			sb.WriteString(fmt.Sprintf("%s{%s} %s\n", def.name, entryLabels(*entry), def.value(entry)))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeMetricHeader(sb *strings.Builder, name string, help string, metricType string) {
+	sb.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType))
+}
+
+func entryLabels(entry ReportEntry) string {
+	return fmt.Sprintf("subsystem=\"%s\",bucket=\"%s\"", escapeLabelValue(entry.Subsystem),
+		escapeLabelValue(entry.Bucket))
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+var _ objstore.Client = &meteredClient{}
+
+type meteredClient struct {
+	meter     *Meter
+	subsystem string
+	client    objstore.Client
+}
+
+func (m *meteredClient) record(bucket string, reqType requestType, numRequests int, bytesRead int, bytesWritten int,
+	err error) {
+	c := m.meter.getCounters(m.subsystem, bucket)
+	c.requests[reqType].Add(int64(numRequests))
+	if err != nil {
+		c.errors[reqType].Add(int64(numRequests))
+	}
+	if bytesRead > 0 {
+		c.bytesRead.Add(int64(bytesRead))
+	}
+	if bytesWritten > 0 {
+		c.bytesWritten.Add(int64(bytesWritten))
+	}
+}
+
+func (m *meteredClient) GetObjectInfo(ctx context.Context, bucket string, key string) (objstore.ObjectInfo, bool, error) {
+	info, exists, err := m.client.GetObjectInfo(ctx, bucket, key)
+	m.record(bucket, requestTypeGet, 1, 0, 0, err)
+	return info, exists, err
+}
+
+func (m *meteredClient) Get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	if !m.meter.cfg.CoalesceGets {
+		return m.get(ctx, bucket, key)
+	}
+	// The request is made on behalf of all the callers waiting for it, so it must not be cancelled if the caller
+	// that made it goes away. It keeps the deadline of that caller.
+	madeRequest := false
+	ch := m.meter.getGroup.DoChan(bucket+"/"+key, func() (any, error) {
+		madeRequest = true
+		fetchCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithDeadline(fetchCtx, deadline)
+			defer cancel()
+		}
+		return m.get(fetchCtx, bucket, key)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		value := res.Val.([]byte)
+		if !madeRequest {
+			m.meter.getCounters(m.subsystem, bucket).coalescedGets.Add(1)
+		}
+		if !res.Shared || value == nil {
+			return value, nil
+		}
+		// Each caller gets its own copy, as callers may modify the value
+		return append([]byte{}, value...), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *meteredClient) get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	value, err := m.client.Get(ctx, bucket, key)
+	m.record(bucket, requestTypeGet, 1, len(value), 0, err)
+	return value, err
+}
+
+func (m *meteredClient) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	value, err := m.client.GetRange(ctx, bucket, key, offset, length)
+	m.record(bucket, requestTypeGet, 1, len(value), 0, err)
+	return value, err
+}
+
+func (m *meteredClient) Put(ctx context.Context, bucket string, key string, value []byte) error {
+	err := m.client.Put(ctx, bucket, key, value)
+	m.record(bucket, requestTypePut, 1, 0, len(value), err)
+	return err
+}
+
+func (m *meteredClient) PutIfNotExists(ctx context.Context, bucket string, key string, value []byte) (bool, string, error) {
+	ok, etag, err := m.client.PutIfNotExists(ctx, bucket, key, value)
+	m.record(bucket, requestTypePut, 1, 0, len(value), err)
+	return ok, etag, err
+}
+
+func (m *meteredClient) PutIfMatchingEtag(ctx context.Context, bucket string, key string, value []byte, etag string) (bool, string, error) {
+	ok, newEtag, err := m.client.PutIfMatchingEtag(ctx, bucket, key, value, etag)
+	m.record(bucket, requestTypePut, 1, 0, len(value), err)
+	return ok, newEtag, err
+}
+
+func (m *meteredClient) Delete(ctx context.Context, bucket string, key string) error {
+	err := m.client.Delete(ctx, bucket, key)
+	m.record(bucket, requestTypeDelete, 1, 0, 0, err)
+	return err
+}
+
+// DeleteAll is counted as a delete request for each key. Some object stores can delete many keys in one request,
+// but they still charge per key deleted.
+func (m *meteredClient) DeleteAll(ctx context.Context, bucket string, keys []string) error {
+	err := m.client.DeleteAll(ctx, bucket, keys)
+	m.record(bucket, requestTypeDelete, len(keys), 0, 0, err)
+	return err
+}
+
+func (m *meteredClient) ListObjectsWithPrefix(ctx context.Context, bucket string, prefix string, maxKeys int) ([]objstore.ObjectInfo, error) {
+	infos, err := m.client.ListObjectsWithPrefix(ctx, bucket, prefix, maxKeys)
+	m.record(bucket, requestTypeList, 1, 0, 0, err)
+	return infos, err
+}
+
+func (m *meteredClient) Start() error {
+	return m.client.Start()
+}
+
+func (m *meteredClient) Stop() error {
+	return m.client.Stop()
+}
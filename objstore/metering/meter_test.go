@@ -0,0 +1,182 @@
+package metering
+
+import (
+	"bytes"
+	"context"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMeteredClientApi(t *testing.T) {
+	meter := NewMeter(NewConf())
+	objstore.TestApi(t, meter.Client(SubsystemPusher, dev.NewInMemStore(0)))
+}
+
+func TestMeterCountsRequests(t *testing.T) {
+	meter := NewMeter(NewConf())
+	store := dev.NewInMemStore(0)
+	pusherClient := meter.Client(SubsystemPusher, store)
+	fetcherClient := meter.Client(SubsystemFetcher, store)
+	ctx := context.Background()
+
+	require.NoError(t, pusherClient.Put(ctx, "bucket1", "key1", []byte("value1")))
+	require.NoError(t, pusherClient.Put(ctx, "bucket2", "key2", []byte("value-2")))
+	_, _, err := pusherClient.PutIfNotExists(ctx, "bucket1", "key3", []byte("v3"))
+	require.NoError(t, err)
+	value, err := fetcherClient.Get(ctx, "bucket1", "key1")
+	require.NoError(t, err)
+	require.Equal(t, "value1", string(value))
+	value, err = fetcherClient.GetRange(ctx, "bucket1", "key1", 1, 3)
+	require.NoError(t, err)
+	require.Equal(t, "alu", string(value))
+	_, _, err = fetcherClient.GetObjectInfo(ctx, "bucket1", "key1")
+	require.NoError(t, err)
+	_, err = fetcherClient.ListObjectsWithPrefix(ctx, "bucket1", "key", -1)
+	require.NoError(t, err)
+	require.NoError(t, pusherClient.DeleteAll(ctx, "bucket1", []string{"key1", "key3"}))
+
+	report := meter.Report()
+	require.Equal(t, []ReportEntry{
+		{Subsystem: SubsystemFetcher, Bucket: "bucket1", GetRequests: 3, ListRequests: 1, BytesRead: 9,
+			EstimatedCost: 3*DefaultPrices.GetRequests/1000 + DefaultPrices.ListRequests/1000},
+		{Subsystem: SubsystemPusher, Bucket: "bucket1", PutRequests: 2, DeleteRequests: 2, BytesWritten: 8,
+			EstimatedCost: 2 * DefaultPrices.PutRequests / 1000},
+		{Subsystem: SubsystemPusher, Bucket: "bucket2", PutRequests: 1, BytesWritten: 7,
+			EstimatedCost: DefaultPrices.PutRequests / 1000},
+	}, report.Entries)
+	require.Equal(t, int64(9), report.TotalRequests)
+	require.InDelta(t, 3*DefaultPrices.GetRequests/1000+DefaultPrices.ListRequests/1000+3*DefaultPrices.PutRequests/1000,
+		report.TotalEstimatedCost, 1e-12)
+}
+
+func TestMeterConfiguredPrices(t *testing.T) {
+	cfg := NewConf()
+	cfg.Prices = Prices{GetRequests: 1, PutRequests: 10, ListRequests: 100, DeleteRequests: 1000}
+	meter := NewMeter(cfg)
+	client := meter.Client(SubsystemCompaction, dev.NewInMemStore(0))
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, client.Put(ctx, "bucket1", "key1", []byte("value1")))
+		_, err := client.Get(ctx, "bucket1", "key1")
+		require.NoError(t, err)
+	}
+	_, err := client.ListObjectsWithPrefix(ctx, "bucket1", "", -1)
+	require.NoError(t, err)
+	require.NoError(t, client.Delete(ctx, "bucket1", "key1"))
+	require.InDelta(t, 1+10+0.1+1, meter.Report().TotalEstimatedCost, 1e-9)
+}
+
+func TestMeterCountsFailedRequests(t *testing.T) {
+	meter := NewMeter(NewConf())
+	store := dev.NewInMemStore(0)
+	client := meter.Client(SubsystemController, store)
+	store.SetUnavailable(true)
+	_, err := client.Get(context.Background(), "bucket1", "key1")
+	require.Error(t, err)
+	err = client.Put(context.Background(), "bucket1", "key1", []byte("value1"))
+	require.Error(t, err)
+	report := meter.Report()
+	require.Equal(t, 1, len(report.Entries))
+	require.Equal(t, int64(1), report.Entries[0].GetRequests)
+	require.Equal(t, int64(1), report.Entries[0].PutRequests)
+	require.Equal(t, int64(2), report.Entries[0].FailedRequests)
+}
+
+func TestMeterCoalescesGets(t *testing.T) {
+	cfg := NewConf()
+	cfg.CoalesceGets = true
+	meter := NewMeter(cfg)
+	// Delay so the Gets overlap
+	store := dev.NewInMemStore(100 * time.Millisecond)
+	client := meter.Client(SubsystemFetchCache, store)
+	require.NoError(t, store.Put(context.Background(), "bucket1", "key1", []byte("value1")))
+
+	numGets := 10
+	values := make([][]byte, numGets)
+	var wg sync.WaitGroup
+	wg.Add(numGets)
+	for i := 0; i < numGets; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			value, err := client.Get(context.Background(), "bucket1", "key1")
+			require.NoError(t, err)
+			values[i] = value
+		}()
+	}
+	wg.Wait()
+	for _, value := range values {
+		require.Equal(t, "value1", string(value))
+	}
+	// Callers must each get their own copy of the value
+	values[0][0] = 'x'
+	require.Equal(t, "value1", string(values[1]))
+
+	entry := meter.Report().Entries[0]
+	require.Less(t, entry.GetRequests, int64(numGets))
+	require.Equal(t, int64(numGets), entry.GetRequests+entry.CoalescedGets)
+
+	// Not found
+	value, err := client.Get(context.Background(), "bucket1", "key2")
+	require.NoError(t, err)
+	require.Nil(t, value)
+}
+
+func TestMeterCoalescedGetCancelled(t *testing.T) {
+	cfg := NewConf()
+	cfg.CoalesceGets = true
+	meter := NewMeter(cfg)
+	store := dev.NewInMemStore(200 * time.Millisecond)
+	client := meter.Client(SubsystemFetchCache, store)
+	require.NoError(t, store.Put(context.Background(), "bucket1", "key1", []byte("value1")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Get(ctx, "bucket1", "key1")
+		errCh <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// Joins the request made by the first Get
+	resCh := make(chan []byte, 1)
+	go func() {
+		value, err := client.Get(context.Background(), "bucket1", "key1")
+		require.NoError(t, err)
+		resCh <- value
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	require.Equal(t, context.Canceled, <-errCh)
+	// The cancelled caller must not cancel the request for the other caller
+	require.Equal(t, "value1", string(<-resCh))
+}
+
+func TestMeterWriteMetrics(t *testing.T) {
+	meter := NewMeter(NewConf())
+	client := meter.Client(SubsystemPusher, dev.NewInMemStore(0))
+	require.NoError(t, client.Put(context.Background(), "bucket1", "key1", []byte("value1")))
+	var buff bytes.Buffer
+	require.NoError(t, meter.WriteMetrics(&buff))
+	out := buff.String()
+	for _, line := range []string{
+		"# TYPE tektite_objstore_requests_total counter",
+		`tektite_objstore_requests_total{subsystem="pusher",bucket="bucket1",type="put"} 1`,
+		`tektite_objstore_requests_total{subsystem="pusher",bucket="bucket1",type="get"} 0`,
+		`tektite_objstore_written_bytes_total{subsystem="pusher",bucket="bucket1"} 6`,
+		`tektite_objstore_estimated_cost_total{subsystem="pusher",bucket="bucket1"} 0.000005`,
+	} {
+		require.True(t, strings.Contains(out, line+"\n"), "missing %s in\n%s", line, out)
+	}
+}
+
+func TestConfValidate(t *testing.T) {
+	cfg := NewConf()
+	require.NoError(t, cfg.Validate())
+	cfg.Prices.ListRequests = -1
+	require.Error(t, cfg.Validate())
+}
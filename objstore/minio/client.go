@@ -64,6 +64,35 @@ func (m *Client) Get(ctx context.Context, bucket string, key string) ([]byte, er
 	return buff, nil
 }
 
+func (m *Client) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+	obj, err := m.client.GetObject(ctx, bucket, key, opts)
+	if err != nil {
+		return nil, maybeConvertError(err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer obj.Close()
+	buff, err := io.ReadAll(obj)
+	if err != nil {
+		var merr minio.ErrorResponse
+		if errwrap.As(err, &merr) {
+			if merr.StatusCode == 404 {
+				// does not exist
+				return nil, nil
+			}
+			if merr.StatusCode == 416 {
+				// offset is past the end of the object
+				return []byte{}, nil
+			}
+		}
+		return nil, maybeConvertError(err)
+	}
+	return buff, nil
+}
+
 func (m *Client) Put(ctx context.Context, bucket string, key string, value []byte) error {
 	buff := bytes.NewBuffer(value)
 	_, err := m.client.PutObject(ctx, bucket, key, buff, int64(len(value)),
@@ -6,6 +6,7 @@ import (
 	"github.com/apache/arrow/go/v11/arrow/decimal128"
 	"github.com/spirit-labs/tektite/types"
 	"math"
+	"reflect"
 	"strings"
 )
 
@@ -25,6 +26,13 @@ type AggFunc interface {
 	RequiresExtraData() bool
 }
 
+// RetractableAggFunc is implemented by aggregate functions that can remove values which have previously been
+// aggregated. This is required to aggregate the changelog of a table, where updated and deleted rows are retracted.
+type RetractableAggFunc interface {
+	// Retract removes vals from the aggregate. vals is a slice of the type of the aggregated expression.
+	Retract(prevVal any, extraData []byte, vals any) (any, []byte, error)
+}
+
 var aggFuncsMap = map[string]AggFunc{
 	"sum":   saf,
 	"count": caf,
@@ -86,6 +94,46 @@ func (a AvgAggFunc) ComputeDecimal(_ any, extraData []byte, vals []types.Decimal
 	if err != nil {
 		return nil, nil, err
 	}
+	return decimalAvgResult(fRes, extra)
+}
+
+func (a AvgAggFunc) Retract(_ any, extraData []byte, vals any) (any, []byte, error) {
+	switch v := vals.(type) {
+	case []int64:
+		valsTot := int64(0)
+		for _, val := range v {
+			valsTot += val
+		}
+		return computeAvg(extraData, -float64(valsTot), -len(v))
+	case []float64:
+		valsTot := float64(0)
+		for _, val := range v {
+			valsTot += val
+		}
+		return computeAvg(extraData, -valsTot, -len(v))
+	case []types.Timestamp:
+		valsTot := int64(0)
+		for _, val := range v {
+			valsTot += val.Val
+		}
+		avg, extra, err := computeAvg(extraData, -float64(valsTot), -len(v))
+		return types.NewTimestamp(int64(avg)), extra, err
+	case []types.Decimal:
+		fTot := float64(0)
+		for _, val := range v {
+			fTot += val.ToFloat64()
+		}
+		fRes, extra, err := computeAvg(extraData, -fTot, -len(v))
+		if err != nil {
+			return nil, nil, err
+		}
+		return decimalAvgResult(fRes, extra)
+	default:
+		panic("unexpected type")
+	}
+}
+
+func decimalAvgResult(fRes float64, extra []byte) (any, []byte, error) {
 	num, err := decimal128.FromFloat64(fRes, types.DefaultDecimalPrecision, types.DefaultDecimalScale)
 	if err != nil {
 		return nil, nil, err
@@ -110,7 +158,11 @@ func computeAvg(extraData []byte, valsTot float64, valsCount int) (float64, []by
 	}
 	tot += valsTot
 	count += valsCount
-	avg := tot / float64(count)
+	var avg float64
+	if count != 0 {
+		// count can return to zero when all values have been retracted
+		avg = tot / float64(count)
+	}
 	binary.LittleEndian.PutUint64(extraData, math.Float64bits(tot))
 	binary.LittleEndian.PutUint64(extraData[8:], uint64(count))
 	return avg, extraData, nil
@@ -191,6 +243,14 @@ func (c CountAggFunc) ComputeTimestamp(p any, _ []byte, vals []types.Timestamp)
 	return prev + int64(len(vals)), nil, nil
 }
 
+func (c CountAggFunc) Retract(p any, _ []byte, vals any) (any, []byte, error) {
+	var prev int64
+	if p != nil {
+		prev = p.(int64)
+	}
+	return prev - int64(reflect.ValueOf(vals).Len()), nil, nil
+}
+
 func (c CountAggFunc) ReturnTypeForExpressionType(types.ColumnType) types.ColumnType {
 	return types.ColumnTypeInt
 }
@@ -240,6 +300,44 @@ func (c SumAggFunc) ComputeDecimal(s any, _ []byte, vals []types.Decimal) (any,
 	return sum, nil, nil
 }
 
+func (c SumAggFunc) Retract(s any, _ []byte, vals any) (any, []byte, error) {
+	switch v := vals.(type) {
+	case []int64:
+		var sum int64
+		if s != nil {
+			sum = s.(int64)
+		}
+		for _, val := range v {
+			sum -= val
+		}
+		return sum, nil, nil
+	case []float64:
+		var sum float64
+		if s != nil {
+			sum = s.(float64)
+		}
+		for _, val := range v {
+			sum -= val
+		}
+		return sum, nil, nil
+	case []types.Decimal:
+		var sum types.Decimal
+		if s != nil {
+			sum = s.(types.Decimal)
+		}
+		for _, val := range v {
+			var err error
+			sum, err = sum.Subtract(&val)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return sum, nil, nil
+	default:
+		panic("unexpected type")
+	}
+}
+
 func (c SumAggFunc) ReturnTypeForExpressionType(t types.ColumnType) types.ColumnType {
 	return t
 }
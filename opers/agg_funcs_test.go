@@ -289,3 +289,40 @@ func TestAvg(t *testing.T) {
 	require.Equal(t, expected, res)
 	require.NotNil(t, extra)
 }
+
+func TestRetract(t *testing.T) {
+	saf := &SumAggFunc{}
+	res, _, err := saf.Retract(int64(17), nil, []int64{5, -6})
+	require.NoError(t, err)
+	require.Equal(t, int64(18), res)
+
+	res, _, err = saf.Retract(float64(10.5), nil, []float64{0.25, 0.25})
+	require.NoError(t, err)
+	require.Equal(t, float64(10), res)
+
+	res, _, err = saf.Retract(createDecimal(t, "12.12"), nil, []types.Decimal{createDecimal(t, "2.02")})
+	require.NoError(t, err)
+	require.Equal(t, createDecimal(t, "10.1"), res)
+
+	caf := &CountAggFunc{}
+	res, _, err = caf.Retract(int64(10), nil, []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Equal(t, int64(7), res)
+
+	avg := &AvgAggFunc{}
+	_, extra, err := avg.ComputeInt(nil, nil, []int64{10, 20, 30, 40})
+	require.NoError(t, err)
+	res, extra, err = avg.Retract(nil, extra, []int64{10, 20})
+	require.NoError(t, err)
+	require.Equal(t, float64(35), res)
+	// Retracting all values returns to zero
+	res, _, err = avg.Retract(nil, extra, []int64{30, 40})
+	require.NoError(t, err)
+	require.Equal(t, float64(0), res)
+
+	// min and max cannot be retracted
+	_, ok := AggFunc(min).(RetractableAggFunc)
+	require.False(t, ok)
+	_, ok = AggFunc(maxAgg).(RetractableAggFunc)
+	require.False(t, ok)
+}
@@ -43,6 +43,12 @@ func NewAggregateOperator(inSchema *OperatorSchema, aggDesc *parser.AggregateDes
 		keyExprStrs = append([]string{windowStartColName, windowEndColName}, keyExprStrs...)
 	}
 
+	// When aggregating the changelog of a table, update_before and delete rows are retracted from the aggregate
+	changeTypeIndex := changeTypeColIndex(inSchema.EventSchema)
+	if changeTypeIndex != -1 && windowed {
+		return nil, statementErrorAtTokenNamef("size", aggDesc, "cannot use a windowed aggregation on the changelog of a table")
+	}
+
 	var aggFuncHolders []aggFuncHolder
 	var extraStateAggs []int
 	var keyColHolders []keyColHolder
@@ -93,6 +99,12 @@ func NewAggregateOperator(inSchema *OperatorSchema, aggDesc *parser.AggregateDes
 		if !ok {
 			return aggExprDesc.ErrorAtPosition("unknown aggregate function '%s'. must be one of 'count', 'sum', 'min' or 'avg'", aggFuncName)
 		}
+		if changeTypeIndex != -1 && index != 0 {
+			if _, ok := aggFunc.(RetractableAggFunc); !ok {
+				return aggExprDesc.ErrorAtPosition("aggregate function '%s' cannot be used on the changelog of a table as it does not support retractions. must be one of 'count', 'sum' or 'avg'",
+					aggFuncName)
+			}
+		}
 		innerExpr := fo.ArgExprs[0]
 
 		if alias != "" {
@@ -110,6 +122,9 @@ func NewAggregateOperator(inSchema *OperatorSchema, aggDesc *parser.AggregateDes
 		if err != nil {
 			return err
 		}
+		if types.IsNestedType(e.ResultType()) {
+			return innerExpr.ErrorAtPosition("cannot aggregate an expression of type %s", e.ResultType().String())
+		}
 		aggFuncHolders = append(aggFuncHolders, aggFuncHolder{
 			aggFunc:   aggFunc,
 			innerExpr: e,
@@ -233,6 +248,7 @@ func NewAggregateOperator(inSchema *OperatorSchema, aggDesc *parser.AggregateDes
 		hop:                         int(hop.Milliseconds()),
 		eventTimeColIndex:           eventTimeColIndex,
 		processingEventTimeColIndex: processingEventTimeColIndex,
+		changeTypeColIndex:          changeTypeIndex,
 		hasOffset:                   hasOffset,
 		storeResults:                storeResults,
 		includeWindowCols:           includeWindowCols,
@@ -276,6 +292,7 @@ type AggregateOperator struct {
 	lateness                    int64
 	eventTimeColIndex           int
 	processingEventTimeColIndex int
+	changeTypeColIndex          int
 	hasOffset                   bool
 	storeResults                bool
 	includeWindowCols           bool
@@ -402,9 +419,9 @@ func (a *AggregateOperator) handleStreamBatch(batch *evbatch.Batch, execCtx Stre
 		return nil, err
 	}
 
-	grouped := a.groupData(cols, batch)
+	grouped, retracted := a.groupData(cols, batch)
 
-	writtenEntries, err := a.computeAggs(grouped, execCtx)
+	writtenEntries, err := a.computeAggs(grouped, retracted, execCtx)
 	if err != nil {
 		return nil, err
 	}
@@ -619,7 +636,7 @@ func (a *AggregateOperator) createCols(batch *evbatch.Batch) ([]evbatch.Column,
 	return cols, nil
 }
 
-func (a *AggregateOperator) groupData(cols []evbatch.Column, batch *evbatch.Batch) map[string][]any {
+func (a *AggregateOperator) groupData(cols []evbatch.Column, batch *evbatch.Batch) (map[string][]any, map[string][]any) {
 	// Now for each agg func, we first group all the values by the key cols
 	var keyCache []string
 	if len(a.aggFuncHolders) > 1 {
@@ -627,16 +644,30 @@ func (a *AggregateOperator) groupData(cols []evbatch.Column, batch *evbatch.Batc
 		// each time
 		keyCache = make([]string, batch.RowCount)
 	}
+	var retract []bool
+	var retracted map[string][]any
+	if a.changeTypeColIndex != -1 {
+		// Values from update_before and delete rows are grouped separately, so they can be retracted
+		retract = make([]bool, batch.RowCount)
+		changeTypeCol := batch.GetStringColumn(a.changeTypeColIndex)
+		for row := 0; row < batch.RowCount; row++ {
+			if !changeTypeCol.IsNull(row) {
+				changeType := changeTypeCol.Get(row)
+				retract[row] = changeType == ChangeTypeUpdateBefore || changeType == ChangeTypeDelete
+			}
+		}
+		retracted = map[string][]any{}
+	}
 	grouped := map[string][]any{}
 	for i, aggHolder := range a.aggFuncHolders {
-		a.groupDataForAggFunc(cols, batch.RowCount, keyCache, i, aggHolder.colIndex, grouped,
+		a.groupDataForAggFunc(cols, batch.RowCount, keyCache, i, aggHolder.colIndex, grouped, retract, retracted,
 			aggHolder.innerExpr.ResultType().ID())
 	}
-	return grouped
+	return grouped, retracted
 }
 
 func (a *AggregateOperator) groupDataForAggFunc(cols []evbatch.Column, rc int, keyCache []string, aggIndex int, aggColIndex int,
-	grouped map[string][]any, ftID types.ColumnTypeID) {
+	grouped map[string][]any, retract []bool, retracted map[string][]any, ftID types.ColumnTypeID) {
 	for row := 0; row < rc; row++ {
 		var sKey string
 		if keyCache != nil {
@@ -654,6 +685,17 @@ func (a *AggregateOperator) groupDataForAggFunc(cols []evbatch.Column, rc int, k
 			gArr = make([]any, len(a.aggFuncHolders))
 			grouped[sKey] = gArr
 		}
+		if retract != nil && retract[row] {
+			if aggIndex == 0 {
+				// The event_time of the aggregate is the max event_time seen, so is not retracted
+				continue
+			}
+			gArr = retracted[sKey]
+			if gArr == nil {
+				gArr = make([]any, len(a.aggFuncHolders))
+				retracted[sKey] = gArr
+			}
+		}
 		col := cols[aggColIndex]
 		if col.IsNull(row) {
 			continue
@@ -758,7 +800,8 @@ func groupTimestampData(col evbatch.Column, row int, vals any) any {
 	return append(timestampVals, val)
 }
 
-func (a *AggregateOperator) computeAggs(grouped map[string][]any, execCtx StreamExecContext) ([]common.KV, error) {
+func (a *AggregateOperator) computeAggs(grouped map[string][]any, retracted map[string][]any,
+	execCtx StreamExecContext) ([]common.KV, error) {
 	var writtenEntries []common.KV
 	numAggs := len(a.aggColTypes)
 	for key, groupedArr := range grouped {
@@ -836,6 +879,12 @@ func (a *AggregateOperator) computeAggs(grouped map[string][]any, execCtx Stream
 			if err != nil {
 				return nil, err
 			}
+			if retractedArr := retracted[key]; retractedArr != nil && retractedArr[i] != nil {
+				res, extraRes, err = aggHolder.aggFunc.(RetractableAggFunc).Retract(res, extraRes, retractedArr[i])
+				if err != nil {
+					return nil, err
+				}
+			}
 			state.data[i] = res
 			if a.hasExtraStateAggs {
 				state.extraData[i] = extraRes
@@ -1124,6 +1124,41 @@ func TestAggregateWithColumnAliases(t *testing.T) {
 	testAggregate(t, inColumnNames, inColumnTypes, aggExprs, keyExprs, inData, outColumnNames, outColumnTypes, outData)
 }
 
+func TestAggregateChangelog(t *testing.T) {
+	inColumnNames := []string{"event_time", "id", "region", "balance", "change_type"}
+	inColumnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeString,
+		types.ColumnTypeInt, types.ColumnTypeString}
+	inData := [][]any{
+		{types.NewTimestamp(1000), "acc1", "r1", int64(100), "insert"},
+		{types.NewTimestamp(1001), "acc2", "r1", int64(50), "insert"},
+		{types.NewTimestamp(1002), "acc3", "r2", int64(10), "insert"},
+		{types.NewTimestamp(1000), "acc1", "r1", int64(100), "update_before"},
+		{types.NewTimestamp(1003), "acc1", "r1", int64(120), "update_after"},
+		{types.NewTimestamp(1002), "acc3", "r2", int64(10), "delete"},
+	}
+	aggExprs := []string{"sum(balance)", "count(balance)", "avg(balance)"}
+	keyExprs := []string{"region"}
+	outColumnNames := []string{"event_time", "region", "sum(balance)", "count(balance)", "avg(balance)"}
+	outColumnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeInt,
+		types.ColumnTypeInt, types.ColumnTypeFloat}
+	outData := [][]any{
+		{types.NewTimestamp(1003), "r1", int64(170), int64(2), float64(85)},
+		{types.NewTimestamp(1002), "r2", int64(0), int64(0), float64(0)},
+	}
+	stored := testAggregateWithStoredData(t, inColumnNames, inColumnTypes, aggExprs, keyExprs, inData, outColumnNames, outColumnTypes, outData, nil)
+
+	// Move acc2 from r1 to r2 - retracting a value from a group only updates the stored state
+	inData = [][]any{
+		{types.NewTimestamp(1001), "acc2", "r1", int64(50), "update_before"},
+		{types.NewTimestamp(1004), "acc2", "r2", int64(50), "update_after"},
+	}
+	outData = [][]any{
+		{types.NewTimestamp(1003), "r1", int64(120), int64(1), float64(120)},
+		{types.NewTimestamp(1004), "r2", int64(50), int64(1), float64(50)},
+	}
+	testAggregateWithStoredData(t, inColumnNames, inColumnTypes, aggExprs, keyExprs, inData, outColumnNames, outColumnTypes, outData, stored)
+}
+
 func testAggregate(t *testing.T, inColumnNames []string, inColumnTypes []types.ColumnType, aggExprs []string, keyExprs []string, inData [][]any,
 	outColumnNames []string, outColumnTypes []types.ColumnType, outData [][]any) {
 	testAggregateWithStoredData(t, inColumnNames, inColumnTypes, aggExprs, keyExprs, inData, outColumnNames, outColumnTypes, outData, nil)
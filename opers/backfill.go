@@ -436,6 +436,10 @@ func (b *BackfillOperator) loadBatchForPartition(partID int) (*evbatch.Batch, er
 				u, byteOff = encoding2.ReadUint64FromBufferLE(buff, byteOff)
 				ts := types.NewTimestamp(int64(u))
 				colBuilders[rowCol].(*evbatch.TimestampColBuilder).Append(ts)
+			case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+				start := byteOff
+				_, byteOff = encoding2.ReadNestedFromBufferLE(buff, byteOff, colType)
+				colBuilders[rowCol].(*evbatch.NestedColBuilder).AppendEncoded(buff[start:byteOff])
 			default:
 				panic("unknown type")
 			}
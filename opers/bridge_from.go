@@ -40,6 +40,8 @@ type BridgeFromOperator struct {
 	topicName            string
 	watermarkOperator    *WaterMarkOperator
 	hashCache            *partitionHashCache
+	// lastIngestedOffsets holds the offset of the last message ingested for each partition, or -1 if none
+	lastIngestedOffsets []int64
 }
 
 const (
@@ -70,6 +72,10 @@ func NewBridgeFromOperator(receiverID int, pollTimeout time.Duration, maxMessage
 	for i := 0; i < len(minOffsets); i++ {
 		minOffsets[i] = -1
 	}
+	lastIngestedOffsets := make([]int64, schema.PartitionScheme.MaxPartitionID+1)
+	for i := range lastIngestedOffsets {
+		lastIngestedOffsets[i] = -1
+	}
 	return &BridgeFromOperator{
 		id:                   uuid.New().String(),
 		schema:               schema,
@@ -87,6 +93,7 @@ func NewBridgeFromOperator(receiverID int, pollTimeout time.Duration, maxMessage
 		lastFlushedVersion:   lastFlushedVersion,
 		topicName:            topicName,
 		hashCache:            newPartitionHashCache(schema.MappingID, schema.Partitions),
+		lastIngestedOffsets:  lastIngestedOffsets,
 	}, nil
 }
 
@@ -167,6 +174,9 @@ func (bf *BridgeFromOperator) IngestMessages(processor proc.Processor, msgs []*k
 			}
 		}
 	}
+	for _, msg := range msgs {
+		atomic.StoreInt64(&bf.lastIngestedOffsets[msg.PartInfo.PartitionID], msg.PartInfo.Offset)
+	}
 	atomic.AddUint64(bf.ingestedMessageCount, uint64(len(msgs)))
 	log.Debugf("bridge from ingested %d msgs - %s ", len(msgs), bf.topicName)
 	return nil
@@ -372,10 +382,43 @@ func (bf *BridgeFromOperator) getConsumers() []*consumerHolder {
 	return consumers
 }
 
+// backlog returns the number of messages in the topic which have not been ingested yet, summed over the partitions
+// for which the message provider knows the watermarks
+func (bf *BridgeFromOperator) backlog() int64 {
+	bf.lock.Lock()
+	defer bf.lock.Unlock()
+	var backlog int64
+	for _, holder := range bf.consumers {
+		wmProvider, ok := holder.msgProvider.(kafka.WatermarkProvider)
+		if !ok || holder.consumer == nil {
+			continue
+		}
+		for i, partID := range holder.partitions {
+			low, high, ok := wmProvider.WatermarkOffsets(partID)
+			if !ok {
+				continue
+			}
+			next := atomic.LoadInt64(&bf.lastIngestedOffsets[partID]) + 1
+			if next == 0 {
+				// nothing ingested yet
+				next = low
+				if i < len(holder.startOffsets) && holder.startOffsets[i] > low {
+					next = holder.startOffsets[i]
+				}
+			}
+			if high > next {
+				backlog += high - next
+			}
+		}
+	}
+	return backlog
+}
+
 type consumerHolder struct {
 	bf           *BridgeFromOperator
 	processor    proc.Processor
 	consumer     *MessageConsumer
+	msgProvider  kafka.MessageProvider
 	partitions   []int
 	paused       bool
 	startOffsets []int64
@@ -407,6 +450,7 @@ func (c *consumerHolder) start() {
 		return
 	}
 	c.consumer = consumer
+	c.msgProvider = msgProvider
 	c.paused = false
 }
 
@@ -1,6 +1,7 @@
 package opers
 
 import (
+	"fmt"
 	"github.com/spirit-labs/tektite/asl/conf"
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/evbatch"
@@ -19,33 +20,9 @@ func NewBridgeToOperator(cfg *conf.Config, desc *parser.BridgeToDesc, storeStrea
 		return nil, err
 	}
 	schema := storeStreamOperator.OutSchema()
-	maxPartition := 0
-	for partId := range schema.PartitionProcessorMapping {
-		if partId > maxPartition {
-			maxPartition = partId
-		}
-	}
-	offsetsToCommit := make([]int64, maxPartition+1)
-	for i := 0; i < len(offsetsToCommit); i++ {
-		offsetsToCommit[i] = -1
-	}
-	var initialRetryDelay time.Duration
-	if desc.InitialRetryDelay != nil {
-		initialRetryDelay = *desc.InitialRetryDelay
-	} else {
-		initialRetryDelay = defaultInitialRetryDelay
-	}
-	if initialRetryDelay < 1*time.Millisecond {
-		return nil, statementErrorAtTokenNamef("initial_retry_delay", desc, "'initial_retry_delay' must be >= 1ms")
-	}
-	var maxRetryDelay time.Duration
-	if desc.MaxRetryDelay != nil {
-		maxRetryDelay = *desc.MaxRetryDelay
-	} else {
-		maxRetryDelay = defaultMaxRetryDelay
-	}
-	if maxRetryDelay < initialRetryDelay {
-		return nil, statementErrorAtTokenNamef("max_retry_delay", desc, "'max_retry_delay' must be >= 'initial_retry_delay'")
+	initialRetryDelay, maxRetryDelay, err := getRetryDelays(desc.InitialRetryDelay, desc.MaxRetryDelay, desc)
+	if err != nil {
+		return nil, err
 	}
 	var connectTimeout time.Duration
 	if desc.ConnectTimeout != nil {
@@ -59,13 +36,30 @@ func NewBridgeToOperator(cfg *conf.Config, desc *parser.BridgeToDesc, storeStrea
 	} else {
 		sendTimeout = defaultSendTimeout
 	}
-	producers := make([]kafka.MessageProducer, maxPartition+1)
+	producers := make([]kafka.MessageProducer, maxPartitionID(schema)+1)
 	for partId := range schema.PartitionProcessorMapping {
 		producers[partId], err = msgClient.NewMessageProducer(partId, connectTimeout, sendTimeout)
 		if err != nil {
 			return nil, err
 		}
 	}
+	bto := newBridgeToOperator(cfg, "bridge to", fmt.Sprintf("topic %s", desc.TopicName), storeStreamOperator,
+		backFillOperator, producers, initialRetryDelay, maxRetryDelay)
+	bto.msgClient = msgClient
+	return bto, nil
+}
+
+// newBridgeToOperator creates an operator which sends batches using the producers, one for each partition. Batches
+// which cannot be sent are stored, and sent later by the backfill operator, which retries with backoff. This is also
+// used by other operators which send to an external system, such as the http_sink operator.
+func newBridgeToOperator(cfg *conf.Config, operatorName string, target string, storeStreamOperator *StoreStreamOperator,
+	backFillOperator *BackfillOperator, producers []kafka.MessageProducer, initialRetryDelay time.Duration,
+	maxRetryDelay time.Duration) *BridgeToOperator {
+	schema := storeStreamOperator.OutSchema()
+	offsetsToCommit := make([]int64, maxPartitionID(schema)+1)
+	for i := 0; i < len(offsetsToCommit); i++ {
+		offsetsToCommit[i] = -1
+	}
 	maxProcessorID := 0
 	for procID := range schema.ProcessorPartitionMapping {
 		if procID > maxProcessorID {
@@ -73,21 +67,53 @@ func NewBridgeToOperator(cfg *conf.Config, desc *parser.BridgeToDesc, storeStrea
 		}
 	}
 	bto := &BridgeToOperator{
-		desc:                desc,
+		operatorName:        operatorName,
+		target:              target,
 		schema:              schema,
 		storeStreamOperator: storeStreamOperator,
 		backFillOperator:    backFillOperator,
 		offsetsToCommit:     offsetsToCommit,
 		pausedMode:          make([]bool, maxProcessorID+1),
 		producers:           producers,
-		msgClient:           msgClient,
 		initialRetryDelay:   initialRetryDelay,
 		maxRetryDelay:       maxRetryDelay,
 		lastRetryDuration:   make([]time.Duration, maxProcessorID+1),
 		cfg:                 cfg,
 	}
 	backFillOperator.AddDownStreamOperator(&backfillSink{b: bto})
-	return bto, nil
+	return bto
+}
+
+func maxPartitionID(schema *OperatorSchema) int {
+	maxPartition := 0
+	for partId := range schema.PartitionProcessorMapping {
+		if partId > maxPartition {
+			maxPartition = partId
+		}
+	}
+	return maxPartition
+}
+
+func getRetryDelays(initial *time.Duration, max *time.Duration, desc errMsgAtPositionProvider) (time.Duration, time.Duration, error) {
+	var initialRetryDelay time.Duration
+	if initial != nil {
+		initialRetryDelay = *initial
+	} else {
+		initialRetryDelay = defaultInitialRetryDelay
+	}
+	if initialRetryDelay < 1*time.Millisecond {
+		return 0, 0, statementErrorAtTokenNamef("initial_retry_delay", desc, "'initial_retry_delay' must be >= 1ms")
+	}
+	var maxRetryDelay time.Duration
+	if max != nil {
+		maxRetryDelay = *max
+	} else {
+		maxRetryDelay = defaultMaxRetryDelay
+	}
+	if maxRetryDelay < initialRetryDelay {
+		return 0, 0, statementErrorAtTokenNamef("max_retry_delay", desc, "'max_retry_delay' must be >= 'initial_retry_delay'")
+	}
+	return initialRetryDelay, maxRetryDelay, nil
 }
 
 const (
@@ -100,7 +126,8 @@ const (
 
 type BridgeToOperator struct {
 	BaseOperator
-	desc                *parser.BridgeToDesc
+	operatorName        string
+	target              string
 	schema              *OperatorSchema
 	slabID              int
 	storeStreamOperator *StoreStreamOperator
@@ -148,8 +175,8 @@ func (b *BridgeToOperator) sendBatch(batch *evbatch.Batch, execCtx StreamExecCon
 		if err := b.enterPausedMode(execCtx); err != nil {
 			return err
 		}
-		log.Warnf("%s 'bridge to' operator failed to topic %s. Will backoff and retry send after delay - error: %v",
-			b.cfg.LogScope, b.desc.TopicName, err)
+		log.Warnf("%s '%s' operator failed to send to %s. Will backoff and retry send after delay - error: %v",
+			b.cfg.LogScope, b.operatorName, b.target, err)
 		// We return an error which is caught in HandleStreamBatch to signify that the send failed
 		return sfe
 	}
@@ -162,7 +189,7 @@ func (b *BridgeToOperator) sendBatch(batch *evbatch.Batch, execCtx StreamExecCon
 func (b *BridgeToOperator) enterPausedMode(execCtx StreamExecContext) error {
 	processor := execCtx.Processor()
 	processorID := processor.ID()
-	log.Debugf("%s bridge to entering paused mode for processor %d", b.cfg.LogScope, processorID)
+	log.Debugf("%s %s entering paused mode for processor %d", b.cfg.LogScope, b.operatorName, processorID)
 	processor.CheckInProcessorLoop()
 	b.pausedMode[processor.ID()] = true
 	partIDs, ok := b.schema.ProcessorPartitionMapping[processorID]
@@ -185,7 +212,7 @@ func (b *BridgeToOperator) enterPausedMode(execCtx StreamExecContext) error {
 }
 
 func (b *BridgeToOperator) exitPausedMode(processor proc.Processor, partIDs []int) {
-	log.Debugf("%s bridge to exiting paused mode for processor %d", b.cfg.LogScope, processor.ID())
+	log.Debugf("%s %s exiting paused mode for processor %d", b.cfg.LogScope, b.operatorName, processor.ID())
 	processor.SubmitAction(func() error {
 		processor.CheckInProcessorLoop()
 		procID := processor.ID()
@@ -219,7 +246,7 @@ func (b *BridgeToOperator) getRetryDelay(processorID int) time.Duration {
 		}
 	}
 	b.lastRetryDuration[processorID] = delay
-	log.Debugf("%s bridge to retrying after delay of %d ms", b.cfg.LogScope, delay.Milliseconds())
+	log.Debugf("%s %s retrying after delay of %d ms", b.cfg.LogScope, b.operatorName, delay.Milliseconds())
 	return delay
 }
 
@@ -0,0 +1,524 @@
+package opers
+
+import (
+	"encoding/binary"
+	"github.com/google/uuid"
+	"github.com/spirit-labs/tektite/asl/arista"
+	"github.com/spirit-labs/tektite/asl/conf"
+	"github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/cdc"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/evbatch"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/proc"
+	"github.com/spirit-labs/tektite/types"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CdcFromOperator ingests the changes captured from a Postgres database using logical replication. Changes are
+// ingested a whole transaction at a time into a single partition, and the end LSN of the last transaction ingested is
+// stored in the offsets slab, so that after failure ingest resumes from the last flushed version exactly. Postgres is
+// only told that changes have been flushed once the version containing them has been flushed, so it never discards
+// changes which could be requested again.
+type CdcFromOperator struct {
+	BaseOperator
+	id                   string
+	schema               *OperatorSchema
+	lock                 sync.Mutex
+	procMgr              ProcessorManager
+	stopped              bool
+	receiverID           int
+	pollTimeout          time.Duration
+	maxMessages          int
+	client               cdc.Client
+	holder               *cdcConsumerHolder
+	consumerTimer        *common.TimerHandle
+	disableIngest        bool
+	cfg                  *conf.Config
+	ingestedMessageCount *uint64
+	offsetsSlabID        uint64
+	ingestEnabled        *atomic.Bool
+	lastFlushedVersion   *int64
+	slotName             string
+	watermarkOperator    *WaterMarkOperator
+	hashCache            *partitionHashCache
+	// lastIngestedLSN is the end LSN of the last transaction ingested, or 0 if none
+	lastIngestedLSN atomic.Uint64
+}
+
+var CdcSchema = evbatch.NewEventSchema([]string{EventTimeColName, "lsn", "op", "table_name", "before", "after"},
+	[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeString,
+		&types.MapType{ValueType: types.ColumnTypeString}, &types.MapType{ValueType: types.ColumnTypeString}})
+
+func NewCdcFromOperator(receiverID int, pollTimeout time.Duration, maxMessages int, connection string, slotName string,
+	publication string, clientFactory cdc.ClientFactory, testDisableIngest bool, procMgr ProcessorManager,
+	offsetsSlabID int, cfg *conf.Config, ingestedMessageCount *uint64, mappingID string, ingestEnabled *atomic.Bool,
+	lastFlushedVersion *int64) (*CdcFromOperator, error) {
+	schema := &OperatorSchema{
+		EventSchema:     CdcSchema,
+		PartitionScheme: NewPartitionScheme(mappingID, 1, true, cfg.ProcessorCount),
+	}
+	client, err := clientFactory(connection, slotName, publication)
+	if err != nil {
+		return nil, err
+	}
+	return &CdcFromOperator{
+		id:                   uuid.New().String(),
+		schema:               schema,
+		receiverID:           receiverID,
+		pollTimeout:          pollTimeout,
+		maxMessages:          maxMessages,
+		client:               client,
+		disableIngest:        testDisableIngest,
+		procMgr:              procMgr,
+		cfg:                  cfg,
+		ingestedMessageCount: ingestedMessageCount,
+		offsetsSlabID:        uint64(offsetsSlabID),
+		ingestEnabled:        ingestEnabled,
+		lastFlushedVersion:   lastFlushedVersion,
+		slotName:             slotName,
+		hashCache:            newPartitionHashCache(schema.MappingID, schema.Partitions),
+	}, nil
+}
+
+func (cf *CdcFromOperator) IngestTransactions(processor proc.Processor, txns []*cdc.Transaction) error {
+	colBuilders := evbatch.CreateColBuilders(cf.schema.EventSchema.ColumnTypes())
+	var maxEventTime int64
+	numChanges := 0
+	for _, txn := range txns {
+		unixMillis := txn.CommitTime.In(time.UTC).UnixMilli()
+		if unixMillis > maxEventTime {
+			maxEventTime = unixMillis
+		}
+		for _, change := range txn.Changes {
+			colBuilders[0].(*evbatch.TimestampColBuilder).Append(types.NewTimestamp(unixMillis))
+			colBuilders[1].(*evbatch.IntColBuilder).Append(int64(change.LSN))
+			colBuilders[2].(*evbatch.StringColBuilder).Append(change.Op)
+			colBuilders[3].(*evbatch.StringColBuilder).Append(change.Schema + "." + change.Table)
+			appendChangeValues(colBuilders[4].(*evbatch.NestedColBuilder), change.Before)
+			appendChangeValues(colBuilders[5].(*evbatch.NestedColBuilder), change.After)
+			numChanges++
+		}
+	}
+	endLSN := txns[len(txns)-1].EndLSN
+	if numChanges > 0 {
+		// We encode the max event time and the end LSN of the last transaction on the evBatchBytes, so they are
+		// available when the batch is received
+		evBatchBytes := make([]byte, 16)
+		binary.LittleEndian.PutUint64(evBatchBytes, uint64(maxEventTime))
+		binary.LittleEndian.PutUint64(evBatchBytes[8:], uint64(endLSN))
+		evBatch := evbatch.NewBatchFromBuilders(cf.schema.EventSchema, colBuilders...)
+		pb := proc.NewProcessBatch(-1, evBatch, cf.receiverID, 0, -1)
+		pb.EvBatchBytes = evBatchBytes
+		if err := processor.IngestBatchSync(pb); err != nil {
+			return err
+		}
+		cf.lastIngestedLSN.Store(uint64(endLSN))
+	}
+	atomic.AddUint64(cf.ingestedMessageCount, uint64(numChanges))
+	log.Debugf("cdc from ingested %d changes in %d transactions - slot %s", numChanges, len(txns), cf.slotName)
+	return nil
+}
+
+func appendChangeValues(builder *evbatch.NestedColBuilder, values map[string]any) {
+	if values == nil {
+		builder.AppendNull()
+	} else {
+		builder.Append(values)
+	}
+}
+
+func (cf *CdcFromOperator) ReceiveBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	evBatchBytes := execCtx.EventBatchBytes()
+	if cf.watermarkOperator.waterMarkType == WaterMarkTypeEventTime {
+		maxEventTime := int64(binary.LittleEndian.Uint64(evBatchBytes))
+		cf.watermarkOperator.updateMaxEventTime(int(maxEventTime), execCtx.Processor().ID())
+	}
+	cf.storeLastLSN(binary.LittleEndian.Uint64(evBatchBytes[8:]), execCtx)
+	return batch, cf.sendBatchDownStream(batch, execCtx)
+}
+
+func (cf *CdcFromOperator) ForwardingProcessorCount() int {
+	return len(cf.schema.PartitionScheme.ProcessorIDs)
+}
+
+func (cf *CdcFromOperator) ReceiveBarrier(execCtx StreamExecContext) error {
+	cf.watermarkOperator.setWatermark(execCtx)
+	return cf.BaseOperator.HandleBarrier(execCtx)
+}
+
+func (cf *CdcFromOperator) RequiresBarriersInjection() bool {
+	return true
+}
+
+func (cf *CdcFromOperator) HandleStreamBatch(*evbatch.Batch, StreamExecContext) (*evbatch.Batch, error) {
+	panic("not used")
+}
+
+func (cf *CdcFromOperator) HandleBarrier(StreamExecContext) error {
+	panic("not used")
+}
+
+func (cf *CdcFromOperator) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
+	panic("not used")
+}
+
+func (cf *CdcFromOperator) InSchema() *OperatorSchema {
+	return cf.schema
+}
+
+func (cf *CdcFromOperator) OutSchema() *OperatorSchema {
+	return cf.schema
+}
+
+func (cf *CdcFromOperator) GetWatermarkOperator() *WaterMarkOperator {
+	return cf.watermarkOperator
+}
+
+func (cf *CdcFromOperator) Setup(mgr StreamManagerCtx) error {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+	mgr.RegisterReceiver(cf.receiverID, cf)
+	if cf.disableIngest {
+		return nil
+	}
+	processors := mgr.ProcessorManager().RegisterListener(cf.id, cf.processorChange)
+	for _, p := range processors {
+		if err := cf.processorStarted(p, false); err != nil {
+			return err
+		}
+	}
+	cf.scheduleConsumerCheckTimer(true)
+	return nil
+}
+
+func (cf *CdcFromOperator) Teardown(mgr StreamManagerCtx, completeCB func(error)) {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+	cf.stopped = true
+	if cf.consumerTimer != nil {
+		cf.consumerTimer.Stop()
+	}
+	// As with bridge from, the consumer must be stopped async as stopping waits for processing to complete, which
+	// could be blocked waiting for the stream manager lock
+	go func() {
+		cf.lock.Lock()
+		if cf.holder != nil {
+			cf.holder.stop(false)
+			cf.holder = nil
+		}
+		cf.lock.Unlock()
+		completeCB(nil)
+	}()
+	mgr.ProcessorManager().UnregisterListener(cf.id)
+	mgr.UnregisterReceiver(cf.receiverID)
+}
+
+func (cf *CdcFromOperator) processorChange(processor proc.Processor, started bool, promoted bool) {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+	if cf.stopped {
+		return
+	}
+	if started {
+		if err := cf.processorStarted(processor, promoted); err != nil {
+			log.Errorf("failed to handle processorStarted %v", err)
+		}
+	} else if cf.holder != nil && cf.holder.processor.ID() == processor.ID() {
+		cf.holder.stop(false)
+		cf.holder = nil
+	}
+}
+
+func (cf *CdcFromOperator) processorStarted(processor proc.Processor, promoted bool) error {
+	if processor.ID() != cf.schema.PartitionScheme.PartitionProcessorMapping[0] {
+		// the changes are all ingested on the processor for partition zero
+		return nil
+	}
+	if cf.holder != nil {
+		panic("consumer already exists")
+	}
+	cf.holder = &cdcConsumerHolder{
+		cf:        cf,
+		processor: processor,
+	}
+	if promoted || !cf.ingestEnabled.Load() {
+		// As with bridge from, we start paused as failure processing will follow, after which ingest is started
+		log.Debugf("cdc from for slot %s processor %d started ingest is not enabled", cf.slotName, processor.ID())
+		cf.holder.paused = true
+		return nil
+	}
+	startLSN, err := cf.getStartLSN(processor, atomic.LoadInt64(cf.lastFlushedVersion))
+	if err != nil {
+		return err
+	}
+	cf.holder.startLSN = startLSN
+	cf.holder.start()
+	return nil
+}
+
+func (cf *CdcFromOperator) scheduleConsumerCheckTimer(first bool) {
+	cf.consumerTimer = common.ScheduleTimer(cf.cfg.ConsumerRetryInterval, first, cf.checkConsumer)
+}
+
+func (cf *CdcFromOperator) checkConsumer() {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+	if cf.stopped {
+		return
+	}
+	if cf.holder != nil {
+		cf.holder.checkStarted()
+	}
+	cf.scheduleConsumerCheckTimer(false)
+}
+
+func (cf *CdcFromOperator) stopIngest() {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+	if cf.holder != nil {
+		cf.holder.pause()
+	}
+}
+
+func (cf *CdcFromOperator) startIngest(version int64) error {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+	if cf.holder == nil {
+		return nil
+	}
+	// Anything ingested after the last flushed version has been rolled back, so we resume from the LSN stored at
+	// that version
+	startLSN, err := cf.getStartLSN(cf.holder.processor, version)
+	if err != nil {
+		return err
+	}
+	cf.holder.startLSN = startLSN
+	cf.lastIngestedLSN.Store(uint64(startLSN))
+	cf.holder.start()
+	return nil
+}
+
+func (cf *CdcFromOperator) getStartLSN(processor proc.Processor, version int64) (cdc.LSN, error) {
+	// Can be -1 if startup first time before any version is flushed, in which case we start from the confirmed
+	// position of the slot
+	if version == -1 {
+		return 0, nil
+	}
+	lsn, err := cf.loadLastLSN(uint64(version), processor)
+	if err != nil {
+		return 0, err
+	}
+	log.Debugf("cdc from for slot %s loaded last LSN %s at version %d", cf.slotName, lsn, version)
+	return lsn, nil
+}
+
+func (cf *CdcFromOperator) loadLastLSN(maxVersion uint64, processor proc.Processor) (cdc.LSN, error) {
+	key := encoding.EncodeEntryPrefix(cf.hashCache.getHash(0), cf.offsetsSlabID, 24)
+	v, err := processor.GetWithMaxVersion(key, maxVersion)
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, nil
+	}
+	return cdc.LSN(binary.LittleEndian.Uint64(v)), nil
+}
+
+func (cf *CdcFromOperator) storeLastLSN(lsn uint64, execCtx StreamExecContext) {
+	key := encoding.EncodeEntryPrefix(cf.hashCache.getHash(0), cf.offsetsSlabID, 32)
+	key = encoding.EncodeVersion(key, uint64(execCtx.WriteVersion()))
+	value := make([]byte, 8)
+	binary.LittleEndian.PutUint64(value, lsn)
+	execCtx.StoreEntry(common.KV{
+		Key:   key,
+		Value: value,
+	}, false)
+	if log.DebugEnabled {
+		log.Debugf("cdc from for slot %s storing LSN %s at version %d", cf.slotName, cdc.LSN(lsn),
+			execCtx.WriteVersion())
+	}
+}
+
+// flushedVersion returns the last version flushed to storage, or -1 if it is not known
+func (cf *CdcFromOperator) flushedVersion() int64 {
+	provider, ok := cf.procMgr.(interface{ GetLastFlushedVersion() int64 })
+	if !ok {
+		return -1
+	}
+	return provider.GetLastFlushedVersion()
+}
+
+type cdcConsumerHolder struct {
+	cf        *CdcFromOperator
+	processor proc.Processor
+	consumer  *cdcConsumer
+	paused    bool
+	startLSN  cdc.LSN
+}
+
+// always called with cf lock held
+func (c *cdcConsumerHolder) start() {
+	if c.consumer != nil {
+		panic("already started")
+	}
+	// If the consumer is restarted after an error we carry on from what has already been ingested
+	startLSN := c.startLSN
+	if lastIngested := cdc.LSN(c.cf.lastIngestedLSN.Load()); lastIngested > startLSN {
+		startLSN = lastIngested
+	}
+	provider, err := c.cf.client.NewChangeProvider(startLSN)
+	if err != nil {
+		log.Warnf("%s: failed to create cdc change provider %v", c.cf.cfg.LogScope, err)
+		return
+	}
+	consumer := &cdcConsumer{
+		cf:               c.cf,
+		processor:        c.processor,
+		provider:         provider,
+		confirmedVersion: -1,
+		errorHandler: func(err error) {
+			// run on separate GR to prevent deadlock
+			common.Go(func() {
+				c.cf.lock.Lock()
+				defer c.cf.lock.Unlock()
+				c.stop(true)
+			})
+		},
+	}
+	if err := consumer.start(); err != nil {
+		log.Warnf("%s: failed to start cdc consumer for slot %s %v", c.cf.cfg.LogScope, c.cf.slotName, err)
+		return
+	}
+	c.consumer = consumer
+	c.paused = false
+}
+
+// always called with cf lock held
+func (c *cdcConsumerHolder) checkStarted() {
+	if c.consumer == nil && !c.paused {
+		c.start()
+	}
+}
+
+// always called with cf lock held
+func (c *cdcConsumerHolder) stop(fromLoop bool) {
+	consumer := c.consumer
+	if consumer == nil {
+		return
+	}
+	c.consumer = nil
+	if err := consumer.stop(fromLoop); err != nil {
+		log.Debugf("failed to stop cdc consumer %v", err)
+	}
+}
+
+func (c *cdcConsumerHolder) pause() {
+	c.paused = true
+	c.stop(false)
+}
+
+// cdcConsumer polls transactions from a ChangeProvider and ingests them
+type cdcConsumer struct {
+	cf               *CdcFromOperator
+	processor        proc.Processor
+	provider         cdc.ChangeProvider
+	running          atomic.Bool
+	stopWg           sync.WaitGroup
+	errorHandler     func(error)
+	confirmedVersion int64
+}
+
+func (c *cdcConsumer) start() error {
+	if err := c.provider.Start(); err != nil {
+		return errwrap.WithStack(err)
+	}
+	c.stopWg.Add(1)
+	c.running.Store(true)
+	common.Go(c.pollLoop)
+	return nil
+}
+
+func (c *cdcConsumer) stop(fromLoop bool) error {
+	// If not called from the pollLoop then we must wait for the pollLoop to exit
+	if !fromLoop && c.running.CompareAndSwap(true, false) {
+		c.stopWg.Wait()
+	}
+	return c.provider.Stop()
+}
+
+func (c *cdcConsumer) pollLoop() {
+	defer common.TektitePanicHandler()
+	defer func() {
+		c.running.Store(false)
+		c.stopWg.Done()
+	}()
+	for c.running.Load() {
+		txns, err := c.getBatch()
+		if err != nil {
+			log.Errorf("failed to capture changes from slot %s: %v", c.cf.slotName, err)
+			c.errorHandler(err)
+			return
+		}
+		if len(txns) > 0 {
+			// This blocks until the changes were actually ingested
+			if err := c.cf.IngestTransactions(c.processor, txns); err != nil {
+				log.Errorf("failed to process batch: %v", err)
+				c.errorHandler(err)
+				return
+			}
+		}
+		if err := c.maybeConfirmFlushed(); err != nil {
+			log.Errorf("failed to confirm flushed LSN for slot %s: %v", c.cf.slotName, err)
+			c.errorHandler(err)
+			return
+		}
+	}
+}
+
+// getBatch returns whole transactions, until the number of changes reaches the max poll messages or the poll timeout
+// is reached
+func (c *cdcConsumer) getBatch() ([]*cdc.Transaction, error) {
+	start := arista.NanoTime()
+	remaining := int64(c.cf.pollTimeout)
+	var txns []*cdc.Transaction
+	numChanges := 0
+	for numChanges < c.cf.maxMessages {
+		txn, err := c.provider.GetTransaction(time.Duration(remaining))
+		if err != nil {
+			return nil, err
+		}
+		if txn == nil {
+			break
+		}
+		txns = append(txns, txn)
+		numChanges += len(txn.Changes)
+		remaining = int64(c.cf.pollTimeout) - int64(arista.NanoTime()-start)
+		if remaining <= 0 {
+			break
+		}
+	}
+	return txns, nil
+}
+
+// maybeConfirmFlushed tells Postgres the LSN stored at the last flushed version, once that version changes
+func (c *cdcConsumer) maybeConfirmFlushed() error {
+	flushedVersion := c.cf.flushedVersion()
+	if flushedVersion <= c.confirmedVersion {
+		return nil
+	}
+	lsn, err := c.cf.loadLastLSN(uint64(flushedVersion), c.processor)
+	if err != nil {
+		return err
+	}
+	if lsn != 0 {
+		if err := c.provider.ConfirmFlushed(lsn); err != nil {
+			return err
+		}
+	}
+	c.confirmedVersion = flushedVersion
+	return nil
+}
@@ -0,0 +1,143 @@
+package opers
+
+import (
+	"github.com/spirit-labs/tektite/cdc"
+	"github.com/spirit-labs/tektite/cdc/fake"
+	"github.com/spirit-labs/tektite/testutils"
+	"github.com/spirit-labs/tektite/tppm"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCdcFrom(t *testing.T) {
+	pg, err := fake.NewPostgres("repl_user", "s3cret", fake.AuthScramSHA256)
+	require.NoError(t, err)
+	defer pg.Close()
+	pg.CreateSlot("test_slot")
+
+	commitTime := time.UnixMilli(1700000000000)
+	columns := []string{"id", "name"}
+	pg.Commit(commitTime,
+		fake.Change{Op: "insert", Schema: "public", Table: "users", Columns: columns, After: []any{"1", "alice"}},
+		fake.Change{Op: "insert", Schema: "public", Table: "users", Columns: columns, After: []any{"2", "bob"}})
+	endLSN2 := pg.Commit(commitTime.Add(time.Second),
+		fake.Change{Op: "update", Schema: "public", Table: "users", Columns: columns,
+			Before: []any{"1", "alice"}, After: []any{"1", nil}},
+		fake.Change{Op: "delete", Schema: "public", Table: "users", Columns: columns, Before: []any{"2", "bob"}})
+
+	mgr, pm := createManager()
+	defer pm.Close()
+	flushedPM := &testFlushedVersionProcessorManager{TestProcessorManager: pm}
+	flushedPM.flushedVersion.Store(-1)
+	mgr.SetProcessorManager(flushedPM)
+	pm.SetBatchHandler(mgr)
+	require.NoError(t, mgr.StartIngest(-1))
+
+	tsl := `test_stream1 := (cdc from postgres connection = "` + pg.ConnectionURL("repl_user", "s3cret") +
+		`" slot = "test_slot" publication = "test_pub")`
+	deployStream(t, tsl, mgr, nil, nil, false, true)
+	info := mgr.GetStream("test_stream1")
+	require.NotNil(t, info)
+	cf := info.Operators[0].(*CdcFromOperator)
+	sinkOper := info.Operators[1].(*testSinkOper)
+	processorID := cf.OutSchema().PartitionScheme.PartitionProcessorMapping[0]
+	pm.AddActiveProcessor(processorID)
+
+	waitForRows(t, 4, sinkOper)
+	rows := cdcRows(sinkOper)
+	require.Equal(t, []any{types.NewTimestamp(commitTime.UnixMilli()), "insert", "public.users", nil,
+		map[string]any{"id": "1", "name": "alice"}}, rows[0])
+	require.Equal(t, []any{types.NewTimestamp(commitTime.UnixMilli()), "insert", "public.users", nil,
+		map[string]any{"id": "2", "name": "bob"}}, rows[1])
+	require.Equal(t, []any{types.NewTimestamp(commitTime.UnixMilli() + 1000), "update", "public.users",
+		map[string]any{"id": "1", "name": "alice"}, map[string]any{"id": "1", "name": nil}}, rows[2])
+	require.Equal(t, []any{types.NewTimestamp(commitTime.UnixMilli() + 1000), "delete", "public.users",
+		map[string]any{"id": "2", "name": "bob"}, nil}, rows[3])
+
+	// The end LSN of the last transaction is stored in the offsets slab
+	lastLSN, err := cf.loadLastLSN(math.MaxUint64, pm.GetProcessor(processorID))
+	require.NoError(t, err)
+	require.Equal(t, cdc.LSN(endLSN2), lastLSN)
+
+	// Postgres is only told about the LSN once the version it was stored in has been flushed
+	require.NotEqual(t, endLSN2, pg.ConfirmedFlushLSN("test_slot"))
+	flushedPM.flushedVersion.Store(123)
+	ok, err := testutils.WaitUntilWithError(func() (bool, error) {
+		return pg.ConfirmedFlushLSN("test_slot") == endLSN2, nil
+	}, 10*time.Second, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// After failure, ingest restarts from the LSN stored at the last flushed version
+	require.NoError(t, mgr.StopIngest())
+	pg.Commit(commitTime.Add(2*time.Second),
+		fake.Change{Op: "insert", Schema: "public", Table: "users", Columns: columns, After: []any{"3", "carol"}})
+	require.NoError(t, mgr.StartIngest(123))
+	waitForRows(t, 5, sinkOper)
+	rows = cdcRows(sinkOper)
+	require.Equal(t, map[string]any{"id": "3", "name": "carol"}, rows[4][4])
+
+	require.NoError(t, mgr.UndeployStream(createDeleteStreamDesc(t, "test_stream1"), 0))
+	ok, err = testutils.WaitUntilWithError(func() (bool, error) {
+		return !pg.SlotActive("test_slot"), nil
+	}, 10*time.Second, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestCdcFromInvalidArgs(t *testing.T) {
+	mgr, pm := createManager()
+	defer pm.Close()
+
+	err := deployStreamReturnError(t, `test_stream1 := (cdc from postgres connection = "mysql://host/db" slot = "s" publication = "p")`,
+		mgr, nil, nil, false, false)
+	require.Error(t, err)
+	require.Equal(t, `invalid 'connection' - connection URL must use the 'postgres' or 'postgresql' scheme (line 1 column 36):
+test_stream1 := (cdc from postgres connection = "mysql://host/db" slot = "s" publication = "p")
+                                   ^`, err.Error())
+
+	err = deployStreamReturnError(t, `test_stream1 := (cdc from postgres connection = "postgres://u@host/db" slot = "" publication = "p")`,
+		mgr, nil, nil, false, false)
+	require.Error(t, err)
+	require.Equal(t, `'slot' must not be empty (line 1 column 72):
+test_stream1 := (cdc from postgres connection = "postgres://u@host/db" slot = "" publication = "p")
+                                                                       ^`, err.Error())
+
+	err = deployStreamReturnError(t, `test_stream1 := (cdc from postgres connection = "postgres://u@host/db" slot = "s" publication = "p" max_poll_messages = 0)`,
+		mgr, nil, nil, false, false)
+	require.Error(t, err)
+	require.Equal(t, `invalid value for 'max_poll_messages' - must be > 0 (line 1 column 101):
+test_stream1 := (cdc from postgres connection = "postgres://u@host/db" slot = "s" publication = "p" max_poll_messages = 0)
+                                                                                                    ^`, err.Error())
+
+	err = deployStreamReturnError(t, `test_stream1 := (filter by f1 > 1) -> (cdc from postgres connection = "postgres://u@host/db" slot = "s" publication = "p")`,
+		mgr, []string{"f1"}, []types.ColumnType{types.ColumnTypeInt}, true, false)
+	require.Error(t, err)
+	require.Equal(t, `'cdc from' must be the first operator in a stream (line 1 column 40):
+test_stream1 := (filter by f1 > 1) -> (cdc from postgres connection = "postgres://u@host/db" slot = "s" publication = "p")
+                                       ^`, err.Error())
+}
+
+// cdcRows returns the rows received by the sink, without the lsn column
+func cdcRows(sinkOper *testSinkOper) [][]any {
+	var rows [][]any
+	for _, batch := range sinkOper.GetPartitionBatches()[0] {
+		for _, row := range convertBatchToAnyArray(batch) {
+			rows = append(rows, append([]any{row[0]}, row[2:]...))
+		}
+	}
+	return rows
+}
+
+type testFlushedVersionProcessorManager struct {
+	*tppm.TestProcessorManager
+	flushedVersion atomic.Int64
+}
+
+func (t *testFlushedVersionProcessorManager) GetLastFlushedVersion() int64 {
+	return t.flushedVersion.Load()
+}
@@ -0,0 +1,246 @@
+package opers
+
+import (
+	"fmt"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/schemareg"
+	"github.com/spirit-labs/tektite/types"
+	"sync"
+)
+
+// DecodeOperator decodes a bytes column containing messages in the schema registry wire format (e.g. the 'val' of
+// a Kafka message) into typed columns. The decoded column is replaced by one column for each top level field of the
+// schema the operator was deployed with.
+// Each message is decoded with the schema whose id is in its header, so messages written with other versions of the
+// schema can be decoded too. Fields are matched to columns by name, and columns with no corresponding field in the
+// message schema are null.
+type DecodeOperator struct {
+	BaseOperator
+	rowErrorPolicy
+	inSchema    *OperatorSchema
+	outSchema   *OperatorSchema
+	colIndex    int
+	colName     string
+	fields      []schemareg.Field
+	registry    schemareg.Registry
+	messageName string
+	codecs      sync.Map
+}
+
+type decodeCodec struct {
+	codec schemareg.Codec
+	// fieldIndexes maps the index of a field in the codec to the index of the field in the operator's fields, or -1
+	fieldIndexes []int
+}
+
+func NewDecodeOperator(schema *OperatorSchema, desc *parser.DecodeDesc, registry schemareg.Registry,
+	regSchema *schemareg.Schema, messageName string) (*DecodeOperator, error) {
+	colIndex := -1
+	for i, colName := range schema.EventSchema.ColumnNames() {
+		if colName == desc.ColumnName {
+			colIndex = i
+			break
+		}
+	}
+	if colIndex == -1 {
+		return nil, statementErrorAtTokenNamef(desc.ColumnName, desc, "cannot decode column '%s' - no such column",
+			desc.ColumnName)
+	}
+	if schema.EventSchema.ColumnTypes()[colIndex].ID() != types.ColumnTypeIDBytes {
+		return nil, statementErrorAtTokenNamef(desc.ColumnName, desc, "cannot decode column '%s' - it must be of type bytes",
+			desc.ColumnName)
+	}
+	codec, err := schemareg.NewCodec(regSchema, messageName)
+	if err != nil {
+		return nil, statementErrorAtTokenNamef("", desc, "invalid schema with id %d: %v", regSchema.ID, err)
+	}
+	fields := codec.Fields()
+	inNames := schema.EventSchema.ColumnNames()
+	inTypes := schema.EventSchema.ColumnTypes()
+	var outNames []string
+	var outTypes []types.ColumnType
+	outNames = append(outNames, inNames[:colIndex]...)
+	outTypes = append(outTypes, inTypes[:colIndex]...)
+	for _, f := range fields {
+		outNames = append(outNames, f.Name)
+		outTypes = append(outTypes, f.Type)
+	}
+	outNames = append(outNames, inNames[colIndex+1:]...)
+	outTypes = append(outTypes, inTypes[colIndex+1:]...)
+	names := map[string]struct{}{}
+	for _, name := range outNames {
+		if _, exists := names[name]; exists {
+			return nil, statementErrorAtTokenNamef("", desc,
+				"cannot decode column '%s' - schema field '%s' has the same name as another column", desc.ColumnName, name)
+		}
+		names[name] = struct{}{}
+	}
+	d := &DecodeOperator{
+		inSchema: schema,
+		outSchema: &OperatorSchema{
+			EventSchema:     evbatch.NewEventSchema(outNames, outTypes),
+			PartitionScheme: schema.PartitionScheme,
+		},
+		colIndex:    colIndex,
+		colName:     desc.ColumnName,
+		fields:      fields,
+		registry:    registry,
+		messageName: messageName,
+	}
+	fieldIndexes := make([]int, len(fields))
+	for i := range fieldIndexes {
+		fieldIndexes[i] = i
+	}
+	d.codecs.Store(regSchema.ID, &decodeCodec{codec: codec, fieldIndexes: fieldIndexes})
+	return d, nil
+}
+
+func (d *DecodeOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	var records deadLetterRecords
+	outBatch, err := d.processBatch(batch, &records)
+	if err != nil {
+		return nil, err
+	}
+	d.rowErrors.sendDeadLetters(&records, execCtx)
+	return outBatch, d.sendBatchDownStream(outBatch, execCtx)
+}
+
+func (d *DecodeOperator) processBatch(batch *evbatch.Batch, records *deadLetterRecords) (*evbatch.Batch, error) {
+	defer batch.Release()
+	outTypes := d.outSchema.EventSchema.ColumnTypes()
+	colBuilders := evbatch.CreateColBuilders(outTypes)
+	inTypes := d.inSchema.EventSchema.ColumnTypes()
+	numFields := len(d.fields)
+	decodedCol := batch.GetBytesColumn(d.colIndex)
+	values := make([]any, numFields)
+	for rowIndex := 0; rowIndex < batch.RowCount; rowIndex++ {
+		for i := range values {
+			values[i] = nil
+		}
+		if !decodedCol.IsNull(rowIndex) {
+			if err := d.decode(decodedCol.Get(rowIndex), values); err != nil {
+				if err := d.rowErrors.handleRowError(err, rowIndex, batch, records); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		for colIndex, colType := range inTypes {
+			if colIndex < d.colIndex {
+				evbatch.CopyColumnEntryWithCol(colType, batch.Columns[colIndex], colBuilders[colIndex], rowIndex)
+			} else if colIndex > d.colIndex {
+				evbatch.CopyColumnEntryWithCol(colType, batch.Columns[colIndex], colBuilders[colIndex-1+numFields], rowIndex)
+			}
+		}
+		for i, v := range values {
+			appendValueToBuilder(d.fields[i].Type, colBuilders[d.colIndex+i], v)
+		}
+	}
+	return evbatch.NewBatchFromBuilders(d.outSchema.EventSchema, colBuilders...), nil
+}
+
+func (d *DecodeOperator) decode(buff []byte, values []any) error {
+	schemaID, payload, err := schemareg.ReadWireHeader(buff)
+	if err != nil {
+		return d.decodeError(err)
+	}
+	codec, err := d.getCodec(schemaID)
+	if err != nil {
+		return err
+	}
+	decoded, err := codec.codec.Decode(payload)
+	if err != nil {
+		return d.decodeError(fmt.Errorf("schema id %d: %w", schemaID, err))
+	}
+	for i, v := range decoded {
+		if index := codec.fieldIndexes[i]; index != -1 {
+			values[index] = v
+		}
+	}
+	return nil
+}
+
+func (d *DecodeOperator) decodeError(err error) error {
+	return fmt.Errorf("failed to decode column '%s': %w", d.colName, err)
+}
+
+// getCodec returns the codec for a schema id, looking up the schema in the registry the first time it is seen.
+func (d *DecodeOperator) getCodec(schemaID int) (*decodeCodec, error) {
+	c, ok := d.codecs.Load(schemaID)
+	if ok {
+		return c.(*decodeCodec), nil
+	}
+	regSchema, err := d.registry.GetSchemaByID(schemaID)
+	if err != nil {
+		return nil, d.decodeError(err)
+	}
+	codec, err := schemareg.NewCodec(regSchema, d.messageName)
+	if err != nil {
+		return nil, d.decodeError(fmt.Errorf("schema id %d: %w", schemaID, err))
+	}
+	fieldIndexes := make([]int, len(codec.Fields()))
+	for i, f := range codec.Fields() {
+		fieldIndexes[i] = -1
+		for j, opField := range d.fields {
+			if f.Name == opField.Name {
+				if !types.ColumnTypesEqual(f.Type, opField.Type) {
+					return nil, d.decodeError(fmt.Errorf("field '%s' has type %s in schema id %d but %s in the stream",
+						f.Name, f.Type, schemaID, opField.Type))
+				}
+				fieldIndexes[i] = j
+				break
+			}
+		}
+	}
+	dc := &decodeCodec{codec: codec, fieldIndexes: fieldIndexes}
+	d.codecs.Store(schemaID, dc)
+	return dc, nil
+}
+
+func (d *DecodeOperator) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
+	panic("not supported in queries")
+}
+
+func (d *DecodeOperator) InSchema() *OperatorSchema {
+	return d.inSchema
+}
+
+func (d *DecodeOperator) OutSchema() *OperatorSchema {
+	return d.outSchema
+}
+
+func (d *DecodeOperator) Setup(StreamManagerCtx) error {
+	return nil
+}
+
+func (d *DecodeOperator) Teardown(_ StreamManagerCtx, completeCB func(error)) {
+	completeCB(nil)
+}
+
+func appendValueToBuilder(colType types.ColumnType, builder evbatch.ColumnBuilder, v any) {
+	if v == nil {
+		builder.AppendNull()
+		return
+	}
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		builder.(*evbatch.IntColBuilder).Append(v.(int64))
+	case types.ColumnTypeIDFloat:
+		builder.(*evbatch.FloatColBuilder).Append(v.(float64))
+	case types.ColumnTypeIDBool:
+		builder.(*evbatch.BoolColBuilder).Append(v.(bool))
+	case types.ColumnTypeIDDecimal:
+		builder.(*evbatch.DecimalColBuilder).Append(v.(types.Decimal))
+	case types.ColumnTypeIDString:
+		builder.(*evbatch.StringColBuilder).Append(v.(string))
+	case types.ColumnTypeIDBytes:
+		builder.(*evbatch.BytesColBuilder).Append(v.([]byte))
+	case types.ColumnTypeIDTimestamp:
+		builder.(*evbatch.TimestampColBuilder).Append(v.(types.Timestamp))
+	case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+		builder.(*evbatch.NestedColBuilder).Append(v)
+	default:
+		panic(fmt.Sprintf("unknown column type %d", colType.ID()))
+	}
+}
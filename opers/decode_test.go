@@ -0,0 +1,158 @@
+package opers
+
+import (
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/schemareg"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+var testRegistrySchemas = []schemareg.Schema{
+	{ID: 1, Subject: "orders-value", Version: 1, SchemaType: schemareg.SchemaTypeAvro,
+		Schema: `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"customer","type":"string"}]}`},
+	{ID: 2, Subject: "orders-value", Version: 2, SchemaType: schemareg.SchemaTypeAvro,
+		Schema: `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"customer","type":"string"},
+			{"name":"qty","type":["null","int"]}]}`},
+	{ID: 3, Subject: "orders-bad", Version: 1, SchemaType: schemareg.SchemaTypeAvro,
+		Schema: `{"type":"record","name":"order","fields":[{"name":"id","type":"string"}]}`},
+	{ID: 4, Subject: "orders-proto", Version: 1, SchemaType: schemareg.SchemaTypeProtobuf,
+		Schema: `syntax = "proto3"; message Order { int64 id = 1; string customer = 2; int64 qty = 3; }`},
+}
+
+func TestDecode(t *testing.T) {
+	registry := createTestRegistry(t)
+	do := createDecodeOperator(t, registry, `my_stream := (decode val subject="orders-value")`)
+	require.Equal(t, []string{"offset", "event_time", "key", "hdrs", "id", "customer", "qty"},
+		do.OutSchema().EventSchema.ColumnNames())
+	require.Equal(t, []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeTimestamp, types.ColumnTypeBytes,
+		types.ColumnTypeBytes, types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeInt},
+		do.OutSchema().EventSchema.ColumnTypes())
+
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), []byte("k1"), nil, encodeTestMessage(t, registry, 2, int64(1), "bob", int64(3))},
+		// Written with an older version of the schema, which doesn't have qty
+		{int64(1), types.NewTimestamp(1001), []byte("k2"), nil, encodeTestMessage(t, registry, 1, int64(2), "alice")},
+		{int64(2), types.NewTimestamp(1002), []byte("k3"), nil, nil},
+	}
+	expectedOut := [][]any{
+		{int64(0), types.NewTimestamp(1000), []byte("k1"), nil, int64(1), "bob", int64(3)},
+		{int64(1), types.NewTimestamp(1001), []byte("k2"), nil, int64(2), "alice", nil},
+		{int64(2), types.NewTimestamp(1002), []byte("k3"), nil, nil, nil, nil},
+	}
+	batch := createEventBatch(KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), dataIn)
+	out, err := do.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	require.Equal(t, expectedOut, convertBatchToAnyArray(out))
+}
+
+func TestDecodeVersion(t *testing.T) {
+	registry := createTestRegistry(t)
+	do := createDecodeOperator(t, registry, `my_stream := (decode val subject="orders-value" version=1)`)
+	require.Equal(t, []string{"offset", "event_time", "key", "hdrs", "id", "customer"},
+		do.OutSchema().EventSchema.ColumnNames())
+	// Fields that are not in the operator's schema are dropped
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), nil, nil, encodeTestMessage(t, registry, 2, int64(1), "bob", int64(3))},
+	}
+	batch := createEventBatch(KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), dataIn)
+	out, err := do.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(0), types.NewTimestamp(1000), nil, nil, int64(1), "bob"}}, convertBatchToAnyArray(out))
+}
+
+func TestDecodeProtobuf(t *testing.T) {
+	registry := createTestRegistry(t)
+	do := createDecodeOperator(t, registry, `my_stream := (decode val subject="orders-proto")`)
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), nil, nil, encodeTestMessage(t, registry, 4, int64(1), "bob", int64(3))},
+	}
+	batch := createEventBatch(KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), dataIn)
+	out, err := do.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(0), types.NewTimestamp(1000), nil, nil, int64(1), "bob", int64(3)}},
+		convertBatchToAnyArray(out))
+}
+
+func TestDecodeErrors(t *testing.T) {
+	registry := createTestRegistry(t)
+	do := createDecodeOperator(t, registry, `my_stream := (decode val subject="orders-value")`)
+	testDecodeError := func(val []byte, expectedErr string) {
+		dataIn := [][]any{{int64(0), types.NewTimestamp(1000), nil, nil, val}}
+		batch := createEventBatch(KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), dataIn)
+		_, err := do.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+		require.Error(t, err)
+		require.Equal(t, expectedErr, err.Error())
+	}
+	testDecodeError([]byte("not avro"), "failed to decode column 'val': unknown magic byte 110 in schema registry wire format")
+	testDecodeError(schemareg.AppendWireHeader(nil, 100), "failed to decode column 'val': schema with id 100 not found")
+	testDecodeError(encodeTestMessage(t, registry, 3, "x"),
+		"failed to decode column 'val': field 'id' has type string in schema id 3 but int in the stream")
+	testDecodeError(append(schemareg.AppendWireHeader(nil, 2), 0x02),
+		"failed to decode column 'val': schema id 2: failed to decode avro field 'customer': unexpected end of data")
+}
+
+func TestDecodeInvalidColumn(t *testing.T) {
+	registry := createTestRegistry(t)
+	_, err := createDecodeOperatorReturnErr(t, registry, `my_stream := (decode foo subject="orders-value")`)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "cannot decode column 'foo' - no such column"))
+
+	_, err = createDecodeOperatorReturnErr(t, registry, `my_stream := (decode offset subject="orders-value")`)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "cannot decode column 'offset' - it must be of type bytes"))
+
+	// The decoded fields must not clash with the other columns
+	_, err = createDecodeOperatorReturnErr(t, registry, `my_stream := (decode key subject="orders-value")`)
+	require.NoError(t, err)
+	inSchema := evbatch.NewEventSchema([]string{"id", "val"}, []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeBytes})
+	desc := parseDecodeDesc(t, `my_stream := (decode val subject="orders-value")`)
+	regSchema, err := registry.GetSchema("orders-value", schemareg.LatestVersion)
+	require.NoError(t, err)
+	_, err = NewDecodeOperator(&OperatorSchema{EventSchema: inSchema}, desc, registry, regSchema, "")
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(),
+		"cannot decode column 'val' - schema field 'id' has the same name as another column"))
+}
+
+func createTestRegistry(t *testing.T) schemareg.Registry {
+	registry, err := schemareg.NewMemRegistry(testRegistrySchemas)
+	require.NoError(t, err)
+	return registry
+}
+
+func encodeTestMessage(t *testing.T, registry schemareg.Registry, schemaID int, values ...any) []byte {
+	regSchema, err := registry.GetSchemaByID(schemaID)
+	require.NoError(t, err)
+	codec, err := schemareg.NewCodec(regSchema, "")
+	require.NoError(t, err)
+	payload, err := codec.Encode(values)
+	require.NoError(t, err)
+	return append(schemareg.AppendWireHeader(nil, schemaID), payload...)
+}
+
+func parseDecodeDesc(t *testing.T, tsl string) *parser.DecodeDesc {
+	ast, err := parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	return ast.CreateStream.OperatorDescs[0].(*parser.DecodeDesc)
+}
+
+func createDecodeOperator(t *testing.T, registry schemareg.Registry, tsl string) *DecodeOperator {
+	do, err := createDecodeOperatorReturnErr(t, registry, tsl)
+	require.NoError(t, err)
+	return do
+}
+
+func createDecodeOperatorReturnErr(t *testing.T, registry schemareg.Registry, tsl string) (*DecodeOperator, error) {
+	desc := parseDecodeDesc(t, tsl)
+	version := schemareg.LatestVersion
+	if desc.Version != nil {
+		version = *desc.Version
+	}
+	regSchema, err := registry.GetSchema(*desc.Subject, version)
+	require.NoError(t, err)
+	return NewDecodeOperator(&OperatorSchema{EventSchema: KafkaSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		desc, registry, regSchema, "")
+}
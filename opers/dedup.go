@@ -0,0 +1,162 @@
+package opers
+
+import (
+	"fmt"
+	encoding2 "github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/expr"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"time"
+)
+
+// DedupOperator drops rows whose key has already been seen within a configurable window of event time. The key of
+// each row that is let through is stored, along with its event_time, in a slab that has retention set, so the memory
+// of seen keys is bounded in time.
+// If emitDuplicates is true the operator does the opposite and only lets through the duplicates. This allows
+// duplicates to be routed to a side stream by creating a sibling child stream with emit_duplicates=true.
+type DedupOperator struct {
+	BaseOperator
+	schema            *OperatorSchema
+	keyExprs          []expr.Expression
+	keyTypes          []types.ColumnType
+	slabID            uint64
+	withinMillis      int64
+	eventTimeColIndex int
+	emitDuplicates    bool
+	hashCache         *partitionHashCache
+	nodeID            int
+}
+
+func NewDedupOperator(schema *OperatorSchema, desc *parser.DedupDesc, slabID int, within time.Duration,
+	emitDuplicates bool, expressionFactory *expr.ExpressionFactory, nodeID int) (*DedupOperator, error) {
+	eventTimeColIndex := -1
+	for i, colName := range schema.EventSchema.ColumnNames() {
+		if colName == EventTimeColName {
+			eventTimeColIndex = i
+			break
+		}
+	}
+	if eventTimeColIndex == -1 {
+		return nil, statementErrorAtTokenNamef("", desc, "input to 'dedup' operator must have an '%s' column", EventTimeColName)
+	}
+	var keyExprs []expr.Expression
+	var keyTypes []types.ColumnType
+	for _, keyExprDesc := range desc.KeyExprs {
+		e, err := expressionFactory.CreateExpression(keyExprDesc, schema.EventSchema)
+		if err != nil {
+			return nil, err
+		}
+		keyExprs = append(keyExprs, e)
+		keyTypes = append(keyTypes, e.ResultType())
+	}
+	return &DedupOperator{
+		schema:            schema,
+		keyExprs:          keyExprs,
+		keyTypes:          keyTypes,
+		slabID:            uint64(slabID),
+		withinMillis:      within.Milliseconds(),
+		eventTimeColIndex: eventTimeColIndex,
+		emitDuplicates:    emitDuplicates,
+		hashCache:         newPartitionHashCache(schema.MappingID, schema.Partitions),
+		nodeID:            nodeID,
+	}, nil
+}
+
+func (d *DedupOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	outBatch, err := d.processBatch(batch, execCtx)
+	if err != nil {
+		return nil, err
+	}
+	if outBatch.RowCount > 0 {
+		return outBatch, d.sendBatchDownStream(outBatch, execCtx)
+	}
+	return outBatch, nil
+}
+
+func (d *DedupOperator) processBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	defer batch.Release()
+	keyCols := make([]evbatch.Column, len(d.keyExprs))
+	for i, keyExpr := range d.keyExprs {
+		col, err := expr.EvalColumn(keyExpr, batch)
+		if err != nil {
+			return nil, err
+		}
+		keyCols[i] = col
+	}
+	eventTimeCol := batch.GetTimestampColumn(d.eventTimeColIndex)
+	partitionHash := d.hashCache.getHash(execCtx.PartitionID())
+	// Entries stored while processing this batch are not visible to execCtx.Get until the batch has been processed, so
+	// we also track the keys seen in this batch
+	seenInBatch := map[string]int64{}
+	colBuilders := evbatch.CreateColBuilders(d.schema.EventSchema.ColumnTypes())
+	for rowIndex := 0; rowIndex < batch.RowCount; rowIndex++ {
+		key := encoding2.EncodeEntryPrefix(partitionHash, d.slabID, keyInitialBufferSize)
+		for i, keyCol := range keyCols {
+			key = evbatch.EncodeKeyCol(rowIndex, keyCol, d.keyTypes[i], key)
+		}
+		eventTime := eventTimeCol.Get(rowIndex).Val
+		firstSeen, seen := seenInBatch[string(key)]
+		if !seen {
+			val, err := execCtx.Get(key)
+			if err != nil {
+				return nil, err
+			}
+			if val != nil {
+				var u uint64
+				u, _ = encoding2.ReadUint64FromBufferLE(val, 0)
+				firstSeen = int64(u)
+				seen = true
+			}
+		}
+		// The slab retention only evicts keys approximately, so we also check the window here
+		duplicate := seen && eventTime-firstSeen < d.withinMillis
+		if !duplicate {
+			seenInBatch[string(key)] = eventTime
+			d.storeKey(key, eventTime, execCtx)
+		}
+		if duplicate == d.emitDuplicates {
+			for colIndex, ft := range d.schema.EventSchema.ColumnTypes() {
+				evbatch.CopyColumnEntry(ft, colBuilders, colIndex, rowIndex, batch)
+			}
+		}
+	}
+	return evbatch.NewBatchFromBuilders(d.schema.EventSchema, colBuilders...), nil
+}
+
+func (d *DedupOperator) storeKey(key []byte, eventTime int64, execCtx StreamExecContext) {
+	if execCtx.WriteVersion() < 0 {
+		panic(fmt.Sprintf("invalid write version: %d", execCtx.WriteVersion()))
+	}
+	storeKey := encoding2.EncodeVersion(common.ByteSliceCopy(key), uint64(execCtx.WriteVersion()))
+	val := encoding2.AppendUint64ToBufferLE(make([]byte, 0, 8), uint64(eventTime))
+	if log.DebugEnabled {
+		log.Debugf("node %d dedup storing key %v with version %d", d.nodeID, storeKey, execCtx.WriteVersion())
+	}
+	execCtx.StoreEntry(common.KV{
+		Key:   storeKey,
+		Value: val,
+	}, false)
+}
+
+func (d *DedupOperator) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
+	panic("not supported in queries")
+}
+
+func (d *DedupOperator) InSchema() *OperatorSchema {
+	return d.schema
+}
+
+func (d *DedupOperator) OutSchema() *OperatorSchema {
+	return d.schema
+}
+
+func (d *DedupOperator) Setup(StreamManagerCtx) error {
+	return nil
+}
+
+func (d *DedupOperator) Teardown(_ StreamManagerCtx, completeCB func(error)) {
+	completeCB(nil)
+}
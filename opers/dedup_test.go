@@ -0,0 +1,135 @@
+package opers
+
+import (
+	encoding2 "github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/expr"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+var dedupColNames = []string{"offset", "event_time", "f1", "f2"}
+var dedupColTypes = []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeInt}
+
+func TestDedupWithinBatch(t *testing.T) {
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "a", int64(1)},
+		{int64(1), types.NewTimestamp(1001), "b", int64(2)},
+		{int64(2), types.NewTimestamp(1002), "a", int64(3)},
+		{int64(3), types.NewTimestamp(1003), "c", int64(4)},
+		{int64(4), types.NewTimestamp(1004), "b", int64(5)},
+	}
+	expectedOut := [][]any{
+		{int64(0), types.NewTimestamp(1000), "a", int64(1)},
+		{int64(1), types.NewTimestamp(1001), "b", int64(2)},
+		{int64(3), types.NewTimestamp(1003), "c", int64(4)},
+	}
+	do := createDedupOperator(t, time.Minute, false, "f1")
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	testDedup(t, do, ctx, dataIn, expectedOut)
+	require.Equal(t, 3, len(ctx.entries))
+	for _, kv := range ctx.entries {
+		slabID, _ := encoding2.ReadUint64FromBufferBE(kv.Key, 16)
+		require.Equal(t, 1001, int(slabID))
+		ver, _ := encoding2.ReadUint64FromBufferBE(kv.Key, len(kv.Key)-8)
+		require.Equal(t, 100, int(math.MaxUint64-ver))
+	}
+}
+
+func TestDedupEmitDuplicates(t *testing.T) {
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "a", int64(1)},
+		{int64(1), types.NewTimestamp(1001), "b", int64(2)},
+		{int64(2), types.NewTimestamp(1002), "a", int64(3)},
+		{int64(3), types.NewTimestamp(1003), "b", int64(4)},
+	}
+	expectedOut := [][]any{
+		{int64(2), types.NewTimestamp(1002), "a", int64(3)},
+		{int64(3), types.NewTimestamp(1003), "b", int64(4)},
+	}
+	do := createDedupOperator(t, time.Minute, true, "f1")
+	testDedup(t, do, &testExecCtx{version: 100, partitionID: 3}, dataIn, expectedOut)
+}
+
+func TestDedupMultipleKeyCols(t *testing.T) {
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "a", int64(1)},
+		{int64(1), types.NewTimestamp(1001), "a", int64(2)},
+		{int64(2), types.NewTimestamp(1002), "a", int64(1)},
+		{int64(3), types.NewTimestamp(1003), nil, int64(1)},
+		{int64(4), types.NewTimestamp(1004), nil, int64(1)},
+	}
+	expectedOut := [][]any{
+		{int64(0), types.NewTimestamp(1000), "a", int64(1)},
+		{int64(1), types.NewTimestamp(1001), "a", int64(2)},
+		{int64(3), types.NewTimestamp(1003), nil, int64(1)},
+	}
+	do := createDedupOperator(t, time.Minute, false, "f1", "f2")
+	testDedup(t, do, &testExecCtx{version: 100, partitionID: 3}, dataIn, expectedOut)
+}
+
+func TestDedupPreviouslySeen(t *testing.T) {
+	do := createDedupOperator(t, 10*time.Second, false, "f1")
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "a", int64(1)},
+		{int64(1), types.NewTimestamp(2000), "b", int64(2)},
+	}
+	testDedup(t, do, ctx, dataIn, dataIn)
+
+	// Make the stored keys visible to the next batch, we remove the version as Get doesn't look up using it
+	ctx.stored = map[string][]byte{}
+	for _, kv := range ctx.entries {
+		ctx.stored[string(kv.Key[:len(kv.Key)-8])] = kv.Value
+	}
+	ctx.entries = nil
+
+	dataIn = [][]any{
+		// within 10s of the first "a" - dropped
+		{int64(2), types.NewTimestamp(10999), "a", int64(3)},
+		// more than 10s after the first "b" - let through
+		{int64(3), types.NewTimestamp(12000), "b", int64(4)},
+		{int64(4), types.NewTimestamp(12001), "c", int64(5)},
+	}
+	expectedOut := [][]any{
+		{int64(3), types.NewTimestamp(12000), "b", int64(4)},
+		{int64(4), types.NewTimestamp(12001), "c", int64(5)},
+	}
+	testDedup(t, do, ctx, dataIn, expectedOut)
+	require.Equal(t, 2, len(ctx.entries))
+	firstSeen, _ := encoding2.ReadUint64FromBufferLE(ctx.entries[0].Value, 0)
+	require.Equal(t, 12000, int(firstSeen))
+}
+
+func TestDedupNoEventTime(t *testing.T) {
+	inSchema := evbatch.NewEventSchema([]string{"f1"}, []types.ColumnType{types.ColumnTypeString})
+	tsl, err := parser.NewParser(nil).ParseTSL("my_stream := (dedup by f1 within 1m)")
+	require.NoError(t, err)
+	desc := tsl.CreateStream.OperatorDescs[0].(*parser.DedupDesc)
+	_, err = NewDedupOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		desc, 1001, time.Minute, false, &expr.ExpressionFactory{}, -1)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "input to 'dedup' operator must have an 'event_time' column"))
+}
+
+func createDedupOperator(t *testing.T, within time.Duration, emitDuplicates bool, keyExprStrs ...string) *DedupOperator {
+	inSchema := evbatch.NewEventSchema(dedupColNames, dedupColTypes)
+	keyExprs, err := toExprs(keyExprStrs...)
+	require.NoError(t, err)
+	do, err := NewDedupOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		&parser.DedupDesc{KeyExprs: keyExprs}, 1001, within, emitDuplicates, &expr.ExpressionFactory{}, -1)
+	require.NoError(t, err)
+	return do
+}
+
+func testDedup(t *testing.T, do *DedupOperator, ctx *testExecCtx, dataIn [][]any, expectedOut [][]any) {
+	batch := createEventBatch(dedupColNames, dedupColTypes, dataIn)
+	out, err := do.HandleStreamBatch(batch, ctx)
+	require.NoError(t, err)
+	require.Equal(t, expectedOut, convertBatchToAnyArray(out))
+}
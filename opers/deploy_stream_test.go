@@ -1,6 +1,7 @@
 package opers
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/spirit-labs/tektite/asl/conf"
 	encoding2 "github.com/spirit-labs/tektite/asl/encoding"
@@ -14,6 +15,8 @@ import (
 	"github.com/spirit-labs/tektite/types"
 	"github.com/stretchr/testify/require"
 	"math"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync/atomic"
 	"testing"
@@ -405,6 +408,89 @@ func TestTableMultipleColKey(t *testing.T) {
 		expectedOut, "test_stream1", streamInfo.UserSlab.SlabID, 0, pm.GetStore())
 }
 
+func TestTableChangelogAggregate(t *testing.T) {
+	mgr, pm := createManager()
+	defer pm.Close()
+	pm.SetBatchHandler(mgr)
+
+	columnNames := []string{"event_time", "id", "region", "balance", "row_delete"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeString,
+		types.ColumnTypeInt, types.ColumnTypeBool}
+
+	pm.AddActiveProcessor(0)
+
+	deployStream(t, `test_stream1 := (store table by id changelog = true)`, mgr, columnNames, columnTypes, true, false)
+	deployStream(t, `test_stream2 := test_stream1 -> (aggregate sum(balance), count(balance) by region)`, mgr,
+		nil, nil, false, false)
+
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), "acc1", "r1", int64(100), nil},
+		{types.NewTimestamp(1001), "acc2", "r1", int64(50), nil},
+		{types.NewTimestamp(1002), "acc3", "r2", int64(10), nil},
+	}
+	injectBatch(t, "test_stream1", 0, 0, dataIn, mgr, pm)
+
+	streamInfo := mgr.GetStream("test_stream2")
+	require.NotNil(t, streamInfo)
+	expectedOut := [][]any{
+		{types.NewTimestamp(1001), "r1", int64(150), int64(2)},
+		{types.NewTimestamp(1002), "r2", int64(10), int64(1)},
+	}
+	verifyRowsInTablePartition(t, []types.ColumnType{types.ColumnTypeString}, []int{1},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt}, []int{0, 2, 3},
+		expectedOut, "test_stream1", streamInfo.UserSlab.SlabID, 0, pm.GetStore())
+
+	// Updates and deletes in the table retract the previous values from the aggregate
+	dataIn = [][]any{
+		{types.NewTimestamp(1003), "acc1", "r1", int64(120), nil},
+		{types.NewTimestamp(1004), "acc3", "r1", int64(10), nil},
+		{types.NewTimestamp(1005), "acc2", nil, nil, true},
+	}
+	injectBatch(t, "test_stream1", 0, 0, dataIn, mgr, pm)
+	expectedOut = [][]any{
+		{types.NewTimestamp(1004), "r1", int64(130), int64(2)},
+		{types.NewTimestamp(1002), "r2", int64(0), int64(0)},
+	}
+	verifyRowsInTablePartition(t, []types.ColumnType{types.ColumnTypeString}, []int{1},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt}, []int{0, 2, 3},
+		expectedOut, "test_stream1", streamInfo.UserSlab.SlabID, 0, pm.GetStore())
+}
+
+func TestTableChangelogInvalidDownstream(t *testing.T) {
+	mgr, pm := createManager()
+	defer pm.Close()
+
+	columnNames := []string{"event_time", "id", "region", "balance"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeString,
+		types.ColumnTypeInt}
+	deployStream(t, `test_stream1 := (store table by id changelog = true)`, mgr, columnNames, columnTypes, true, false)
+
+	err := deployStreamReturnError(t, `test_stream2 := test_stream1 -> (aggregate min(balance) by region)`, mgr,
+		nil, nil, false, false)
+	require.Error(t, err)
+	require.Equal(t, `aggregate function 'min' cannot be used on the changelog of a table as it does not support retractions. must be one of 'count', 'sum' or 'avg' (line 1 column 55):
+test_stream2 := test_stream1 -> (aggregate min(balance) by region)
+                                                      ^`, err.Error())
+
+	err = deployStreamReturnError(t, `test_stream2 := test_stream1 -> (aggregate sum(balance) by region size 1m hop 10s)`, mgr,
+		nil, nil, false, false)
+	require.Error(t, err)
+	require.Equal(t, `cannot use a windowed aggregation on the changelog of a table (line 1 column 67):
+test_stream2 := test_stream1 -> (aggregate sum(balance) by region size 1m hop 10s)
+                                                                  ^`, err.Error())
+
+	deployStream(t, `test_stream3 := (partition by id partitions 10 mapping "m1") -> (store table by id changelog = true)`,
+		mgr, columnNames, columnTypes, true, false)
+	deployStream(t, `test_stream4 := (partition by id partitions 10 mapping "m1") -> (store stream)`,
+		mgr, columnNames, columnTypes, true, false)
+	err = deployStreamReturnError(t, `test_stream5 := (join test_stream4 with table test_stream3 by id = id)`, mgr,
+		nil, nil, false, false)
+	require.Error(t, err)
+	require.Equal(t, `cannot join with a table that has 'changelog' enabled (line 1 column 18):
+test_stream5 := (join test_stream4 with table test_stream3 by id = id)
+                 ^`, err.Error())
+}
+
 func TestAggregate(t *testing.T) {
 	mgr, pm := createManager()
 	defer pm.Close()
@@ -459,6 +545,160 @@ func TestAggregate(t *testing.T) {
 		expectedOut, "test_stream1", streamInfo.UserSlab.SlabID, 0, pm.GetStore())
 }
 
+func TestTopN(t *testing.T) {
+	mgr, pm := createManager()
+	defer pm.Close()
+	pm.SetBatchHandler(mgr)
+
+	columnNames := []string{"event_time", "f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeInt}
+
+	pm.AddActiveProcessor(0)
+
+	tsl := `test_stream1 := (aggregate sum(f2) as total by f1 store=false) -> (topn 2 order_by total desc)`
+	deployStream(t, tsl, mgr, columnNames, columnTypes, true, true)
+
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), int64(0), int64(10), int64(1)},
+		{types.NewTimestamp(1000), int64(1), int64(11), int64(5)},
+		{types.NewTimestamp(1000), int64(2), int64(12), int64(3)},
+	}
+	injectBatch(t, "test_stream1", 0, 0, dataIn, mgr, pm)
+
+	streamInfo := mgr.GetStream("test_stream1")
+	require.NotNil(t, streamInfo)
+
+	expectedOut := [][]any{
+		{types.NewTimestamp(1000), int64(11), int64(5), int64(1)},
+		{types.NewTimestamp(1000), int64(12), int64(3), int64(2)},
+	}
+	verifyRowsInTablePartition(t, []types.ColumnType{types.ColumnTypeInt}, []int{1},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt}, []int{0, 2, 3},
+		expectedOut, "test_stream1", streamInfo.UserSlab.SlabID, 0, pm.GetStore())
+
+	dataIn = [][]any{
+		{types.NewTimestamp(1000), int64(3), int64(10), int64(6)},
+	}
+	injectBatch(t, "test_stream1", 0, 0, dataIn, mgr, pm)
+
+	// f1 = 12 has been pushed out of the top 2 so is deleted from the table
+	expectedOut = [][]any{
+		{types.NewTimestamp(1000), int64(10), int64(7), int64(1)},
+		{types.NewTimestamp(1000), int64(11), int64(5), int64(2)},
+	}
+	verifyRowsInTablePartition(t, []types.ColumnType{types.ColumnTypeInt}, []int{1},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt}, []int{0, 2, 3},
+		expectedOut, "test_stream1", streamInfo.UserSlab.SlabID, 0, pm.GetStore())
+}
+
+func TestTopNMustFollowAggregate(t *testing.T) {
+	mgr, _ := createManager()
+	tsl := `test_stream1 := (filter by f1 >= 2) -> (topn 2 order_by f1)`
+	columnNames := []string{"f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeString}
+	err := deployStreamReturnError(t, tsl, mgr, columnNames, columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `'topn' must directly follow an 'aggregate' (line 1 column 41):
+test_stream1 := (filter by f1 >= 2) -> (topn 2 order_by f1)
+                                        ^`, err.Error())
+}
+
+func TestDeployDecodeEncode(t *testing.T) {
+	mgr, _ := createManager()
+	bytes, err := json.Marshal(map[string]any{"schemas": testRegistrySchemas})
+	require.NoError(t, err)
+	registryFile := filepath.Join(t.TempDir(), "schemas.json")
+	require.NoError(t, os.WriteFile(registryFile, bytes, 0644))
+	mgr.cfg.SchemaRegistryFile = registryFile
+
+	tsl := `test_stream1 := (decode val subject="orders-value") -> (filter by qty > 1) -> (encode subject="orders-value" version=2)`
+	deployStream(t, tsl, mgr, KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), true, false)
+	streamInfo := mgr.GetStream("test_stream1")
+	require.NotNil(t, streamInfo)
+	decodeOper := streamInfo.Operators[1]
+	require.Equal(t, []string{"offset", "event_time", "key", "hdrs", "id", "customer", "qty"},
+		decodeOper.OutSchema().EventSchema.ColumnNames())
+	encodeOper := streamInfo.Operators[3]
+	require.Equal(t, KafkaSchema, encodeOper.OutSchema().EventSchema)
+
+	tsl = `test_stream2 := (decode val subject="unknown")`
+	err = deployStreamReturnError(t, tsl, mgr, KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), true, false)
+	require.Error(t, err)
+	require.Equal(t, `schema for subject 'unknown' version latest not found (line 1 column 29):
+test_stream2 := (decode val subject="unknown")
+                            ^`, err.Error())
+
+	tsl = `test_stream2 := (encode subject="orders-value" message="Order")`
+	err = deployStreamReturnError(t, tsl, mgr, KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), true, false)
+	require.Error(t, err)
+	require.Equal(t, `'message' can only be specified for protobuf schemas (line 1 column 48):
+test_stream2 := (encode subject="orders-value" message="Order")
+                                               ^`, err.Error())
+}
+
+func TestPinSchemaVersions(t *testing.T) {
+	mgr, _ := createManager()
+	bytes, err := json.Marshal(map[string]any{"schemas": testRegistrySchemas})
+	require.NoError(t, err)
+	registryFile := filepath.Join(t.TempDir(), "schemas.json")
+	require.NoError(t, os.WriteFile(registryFile, bytes, 0644))
+	mgr.cfg.SchemaRegistryFile = registryFile
+
+	tsl := `test_stream1 := (decode val subject="orders-value") -> (filter by qty > 1) -> (encode subject="orders-value" version=1) -> (encode subject="orders-value")`
+	ast, err := parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	pinned, err := mgr.PinSchemaVersions(ast.CreateStream, tsl)
+	require.NoError(t, err)
+	require.Equal(t, `test_stream1 := (decode val subject="orders-value" version=2) -> (filter by qty > 1) -> (encode subject="orders-value" version=1) -> (encode subject="orders-value" version=2)`, pinned)
+	require.Equal(t, 2, *ast.CreateStream.OperatorDescs[0].(*parser.DecodeDesc).Version)
+	require.Equal(t, 1, *ast.CreateStream.OperatorDescs[2].(*parser.EncodeDesc).Version)
+	require.Equal(t, 2, *ast.CreateStream.OperatorDescs[3].(*parser.EncodeDesc).Version)
+
+	// The schemas are resolved without locking the stream manager, so receivers are not blocked while they are fetched
+	tsl = `test_stream1 := (decode val subject="orders-value")`
+	ast, err = parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	mgr.lock.Lock()
+	pinned, err = mgr.PinSchemaVersions(ast.CreateStream, tsl)
+	mgr.lock.Unlock()
+	require.NoError(t, err)
+	require.Equal(t, `test_stream1 := (decode val subject="orders-value" version=2)`, pinned)
+
+	// Nothing to pin
+	tsl = `test_stream1 := (filter by qty > 1)`
+	ast, err = parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	pinned, err = mgr.PinSchemaVersions(ast.CreateStream, tsl)
+	require.NoError(t, err)
+	require.Equal(t, tsl, pinned)
+
+	tsl = `test_stream1 := (decode val subject="unknown")`
+	ast, err = parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	_, err = mgr.PinSchemaVersions(ast.CreateStream, tsl)
+	require.Error(t, err)
+	require.Equal(t, `schema for subject 'unknown' version latest not found (line 1 column 29):
+test_stream1 := (decode val subject="unknown")
+                            ^`, err.Error())
+}
+
+func TestDeployDecodeNoRegistry(t *testing.T) {
+	mgr, _ := createManager()
+	tsl := `test_stream1 := (decode val subject="orders-value")`
+	err := deployStreamReturnError(t, tsl, mgr, KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), true, false)
+	require.Error(t, err)
+	require.Equal(t, `cannot use 'decode' - no schema registry is configured (line 1 column 18):
+test_stream1 := (decode val subject="orders-value")
+                 ^`, err.Error())
+
+	tsl = `test_stream1 := (decode val)`
+	err = deployStreamReturnError(t, tsl, mgr, KafkaSchema.ColumnNames(), KafkaSchema.ColumnTypes(), true, false)
+	require.Error(t, err)
+	require.Equal(t, `'subject' must be specified for 'decode' (line 1 column 18):
+test_stream1 := (decode val)
+                 ^`, err.Error())
+}
+
 func TestDeployStreamAlreadyExists(t *testing.T) {
 	mgr, _ := createManager()
 	tsl := `test_stream1 :=  (filter by f1 >= 2) -> (store stream)`
@@ -481,6 +721,250 @@ delete(does_not_exist)
        ^`, err.Error())
 }
 
+func TestAlterStreamKeepsState(t *testing.T) {
+	mgr, pm := createManager()
+	defer pm.Close()
+	pm.SetBatchHandler(mgr)
+	pm.AddActiveProcessor(0)
+
+	columnNames := []string{"event_time", "f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeFloat}
+
+	tsl := `test_stream1 := (aggregate sum(f2) by f1)`
+	deployStream(t, tsl, mgr, columnNames, columnTypes, true, true)
+	streamInfo := mgr.GetStream("test_stream1")
+	slabID := streamInfo.UserSlab.SlabID
+
+	injectBatch(t, "test_stream1", 0, 0, [][]any{
+		{types.NewTimestamp(1000), int64(0), int64(10), float64(1)},
+		{types.NewTimestamp(1000), int64(1), int64(20), float64(2)},
+	}, mgr, pm)
+
+	// Add a filter before the aggregate - the aggregate is unchanged and its input schema is the same
+	tsl = `alter test_stream1 := (filter by f0 < 100) -> (aggregate sum(f2) by f1)`
+	deployStream(t, tsl, mgr, columnNames, columnTypes, true, true)
+	require.Equal(t, 1, mgr.numStreams())
+	streamInfo = mgr.GetStream("test_stream1")
+	require.True(t, streamInfo.StreamDesc.Alter)
+	require.Equal(t, 4, len(streamInfo.Operators))
+	require.Equal(t, slabID, streamInfo.UserSlab.SlabID)
+	require.Equal(t, []int64{123}, streamInfo.ReplacedCommandIDs)
+
+	injectBatch(t, "test_stream1", 0, 0, [][]any{
+		{types.NewTimestamp(1000), int64(2), int64(10), float64(3)},
+		{types.NewTimestamp(1000), int64(200), int64(20), float64(100)},
+	}, mgr, pm)
+
+	verifyRowsInTablePartition(t, []types.ColumnType{types.ColumnTypeInt}, []int{1},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeFloat}, []int{0, 2},
+		[][]any{
+			{types.NewTimestamp(1000), int64(10), float64(4)},
+			{types.NewTimestamp(1000), int64(20), float64(2)},
+		}, "test_stream1", slabID, 0, pm.GetStore())
+}
+
+func TestAlterStreamIncompatible(t *testing.T) {
+	mgr, _ := createManager()
+	columnNames := []string{"event_time", "f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeFloat}
+
+	tsl := `test_stream1 := (aggregate sum(f2) by f1)`
+	deployStream(t, tsl, mgr, columnNames, columnTypes, true, false)
+
+	tsl = `alter test_stream1 := (aggregate count(f2) by f1)`
+	err := deployStreamReturnError(t, tsl, mgr, columnNames, columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `cannot alter stream 'test_stream1' - operators which hold state or connect to other streams cannot be changed, removed or reordered (line 1 column 24):
+alter test_stream1 := (aggregate count(f2) by f1)
+                       ^`, err.Error())
+
+	tsl = `alter test_stream1 := (project f1, f2) -> (aggregate sum(f2) by f1)`
+	err = deployStreamReturnError(t, tsl, mgr, columnNames, columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `cannot alter stream 'test_stream1' - the input of this operator would change from (event_time: timestamp, f0: int, f1: int, f2: float) to (event_time: timestamp, f1: int, f2: float), but it holds state so its input cannot change (line 1 column 44):
+alter test_stream1 := (project f1, f2) -> (aggregate sum(f2) by f1)
+                                           ^`, err.Error())
+
+	tsl = `alter test_stream1 := (filter by f0 > 1)`
+	err = deployStreamReturnError(t, tsl, mgr, columnNames, columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `cannot alter stream 'test_stream1' - operators which hold state or connect to other streams cannot be changed, removed or reordered (line 1 column 7):
+alter test_stream1 := (filter by f0 > 1)
+      ^`, err.Error())
+
+	tsl = `alter test_stream2 := (filter by f0 > 1)`
+	err = deployStreamReturnError(t, tsl, mgr, columnNames, columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `unknown stream 'test_stream2' (line 1 column 7):
+alter test_stream2 := (filter by f0 > 1)
+      ^`, err.Error())
+
+	// The original stream is still deployed
+	require.Equal(t, 2, len(mgr.GetStream("test_stream1").Operators))
+}
+
+func TestAlterStreamFailsToDeploy(t *testing.T) {
+	mgr, _ := createManager()
+	columnNames := []string{"event_time", "f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeFloat}
+
+	tsl := `test_stream1 := (aggregate sum(f2) by f1 store=false)`
+	streamDesc := createTestStreamDesc(t, tsl, columnNames, columnTypes, true, false)
+	require.NoError(t, mgr.DeployStream(streamDesc, []int{6000}, []int{6000}, "", 10))
+	require.Equal(t, int64(10), mgr.lastCommandID)
+
+	// The alter is allowed, but the new aggregate fails to deploy
+	tsl = `alter test_stream1 := (aggregate sum(f2) by f1 store=false) -> (aggregate sum(f9) by f1)`
+	streamDesc = createTestStreamDesc(t, tsl, columnNames, columnTypes, true, false)
+	err := mgr.AlterStream(streamDesc, []int{6001}, []int{6001}, "", 11)
+	require.Error(t, err)
+	require.Equal(t, `unknown column 'f9'. (available columns: event_time: timestamp, f1: int, sum(f2): float) (line 1 column 79):
+alter test_stream1 := (aggregate sum(f2) by f1 store=false) -> (aggregate sum(f9) by f1)
+                                                                              ^`, err.Error())
+
+	// The original stream is still deployed with the command id it was deployed with
+	streamInfo := mgr.GetStream("test_stream1")
+	require.Equal(t, 2, len(streamInfo.Operators))
+	require.False(t, streamInfo.StreamDesc.Alter)
+	require.Equal(t, int64(10), streamInfo.CommandID)
+	require.Empty(t, streamInfo.ReplacedCommandIDs)
+	require.Equal(t, int64(10), mgr.lastCommandID)
+
+	tsl = `alter test_stream1 := (filter by f0 > 1) -> (aggregate sum(f2) by f1 store=false)`
+	streamDesc = createTestStreamDesc(t, tsl, columnNames, columnTypes, true, false)
+	require.NoError(t, mgr.AlterStream(streamDesc, []int{6002}, []int{6002}, "", 12))
+	streamInfo = mgr.GetStream("test_stream1")
+	require.Equal(t, int64(12), streamInfo.CommandID)
+	require.Equal(t, []int64{10}, streamInfo.ReplacedCommandIDs)
+	require.Equal(t, int64(12), mgr.lastCommandID)
+}
+
+func TestAlterStreamWithChildStreams(t *testing.T) {
+	mgr, _ := createManager()
+	columnNames := []string{"event_time", "f0", "f1"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeString}
+
+	deployStream(t, `test_stream1 := (filter by f0 > 1)`, mgr, columnNames, columnTypes, true, false)
+	deployStream(t, `test_stream2 := test_stream1 -> (store stream)`, mgr, nil, nil, false, false)
+
+	// Output schema is unchanged, so this is allowed
+	deployStream(t, `alter test_stream1 := (filter by f0 > 10)`, mgr, columnNames, columnTypes, true, false)
+	streamInfo := mgr.GetStream("test_stream1")
+	_, ok := streamInfo.DownstreamStreamNames["test_stream2"]
+	require.True(t, ok)
+
+	tsl := `alter test_stream1 := (project f0)`
+	err := deployStreamReturnError(t, tsl, mgr, columnNames, columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `cannot alter stream 'test_stream1' - it has child streams: [test_stream2] - so its output schema cannot change (line 1 column 7):
+alter test_stream1 := (project f0)
+      ^`, err.Error())
+}
+
+func TestExplainStream(t *testing.T) {
+	mgr, _ := createManager()
+	columnNames := []string{"event_time", "f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeFloat}
+
+	tsl := `test_stream1 := (filter by f0 > 1) -> (aggregate sum(f2) by f1 retention=1h)`
+	deployStream(t, tsl, mgr, columnNames, columnTypes, true, false)
+	deployStream(t, `child_stream := test_stream1 -> (store stream)`, mgr, nil, nil, false, false)
+
+	plan, ok := mgr.ExplainStream("test_stream1")
+	require.True(t, ok)
+	require.Equal(t, 3, len(plan))
+	require.Equal(t, "test source", plan[0].Operator)
+	require.Equal(t, -1, plan[0].ParentID)
+
+	require.Equal(t, "filter", plan[1].Operator)
+	require.Equal(t, "(filter by f0 > 1)", plan[1].Definition)
+	require.Equal(t, 0, plan[1].ParentID)
+	require.Empty(t, plan[1].SlabIDs)
+
+	agg := plan[2]
+	require.Equal(t, "aggregate", agg.Operator)
+	require.Equal(t, "(aggregate sum(f2) by f1 retention=1h)", agg.Definition)
+	require.Equal(t, 1, agg.ParentID)
+	require.Equal(t, "event_time: timestamp, f0: int, f1: int, f2: float", agg.InSchema.EventSchema.String())
+	require.Equal(t, "event_time: timestamp, f1: int, sum(f2): float", agg.OutSchema.EventSchema.String())
+	require.Equal(t, 10, agg.Partitions)
+	require.False(t, agg.Repartition)
+	require.Equal(t, []int{mgr.GetStream("test_stream1").UserSlab.SlabID}, agg.SlabIDs)
+	require.Equal(t, time.Hour, agg.Retention)
+	require.Equal(t, []string{"child_stream"}, agg.ChildStreams)
+
+	plan, ok = mgr.ExplainStream("child_stream")
+	require.True(t, ok)
+	require.Equal(t, 2, len(plan))
+	require.Equal(t, "continuation", plan[0].Operator)
+	require.Equal(t, []string{"test_stream1"}, plan[0].UpstreamStreams)
+	require.Equal(t, "store stream", plan[1].Operator)
+	require.Equal(t, 2, len(plan[1].SlabIDs))
+
+	batch := ExplainBatch(plan)
+	require.Equal(t, 2, batch.RowCount)
+	require.True(t, batch.GetIntColumn(1).IsNull(0))
+	require.Equal(t, int64(0), batch.GetIntColumn(1).Get(1))
+	require.Equal(t, "test_stream1", batch.GetStringColumn(12).Get(0))
+
+	_, ok = mgr.ExplainStream("unknown_stream")
+	require.False(t, ok)
+}
+
+func TestStreamStats(t *testing.T) {
+	mgr, pm := createManager()
+	defer pm.Close()
+	pm.SetBatchHandler(mgr)
+	pm.AddActiveProcessor(0)
+
+	tsl := `test_stream1 := (filter by f2 >= 2.1f) -> (store stream)`
+	columnNames := []string{"offset", "f1", "f2", "f3"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeFloat, types.ColumnTypeString}
+	deployStream(t, tsl, mgr, columnNames, columnTypes, true, false)
+
+	dataIn := [][]any{
+		{int64(0), int64(10), float64(1.1), "foo1"},
+		{int64(1), int64(5), float64(2.1), "foo2"},
+		{int64(2), int64(13), float64(3.1), "foo3"},
+	}
+	injectBatch(t, "test_stream1", 0, 0, dataIn, mgr, pm)
+
+	stats, ok := mgr.GetStreamStats("test_stream1")
+	require.True(t, ok)
+	require.Equal(t, "test_stream1", stats.StreamName)
+	require.Equal(t, int64(-1), stats.Backlog)
+	require.Equal(t, 3, len(stats.Operators))
+	require.Equal(t, "test source", stats.Operators[0].Operator)
+
+	// The test source passes batches straight to the filter, so rows are only counted from the filter onwards
+	filter := stats.Operators[1]
+	require.Equal(t, "filter", filter.Operator)
+	require.Equal(t, uint64(2), filter.RowsOut)
+	require.Equal(t, uint64(0), filter.StateWrites)
+	require.Equal(t, time.Duration(-1), filter.WaterMarkLag)
+
+	store := stats.Operators[2]
+	require.Equal(t, "store stream", store.Operator)
+	require.Equal(t, uint64(2), store.RowsIn)
+	require.Equal(t, uint64(1), store.Batches)
+	require.Equal(t, uint64(2), store.StateWrites)
+	require.Equal(t, uint64(0), store.StateReads)
+
+	allStats := mgr.GetAllStreamStats()
+	require.Equal(t, 1, len(allStats))
+	require.Equal(t, "test_stream1", allStats[0].StreamName)
+
+	batch := StreamStatsBatch(allStats)
+	require.Equal(t, 3, batch.RowCount)
+	require.Equal(t, "filter", batch.GetStringColumn(2).Get(1))
+	require.Equal(t, int64(2), batch.GetIntColumn(4).Get(1))
+	require.True(t, batch.GetStringColumn(10).IsNull(1))
+	require.True(t, batch.GetIntColumn(11).IsNull(0))
+
+	_, ok = mgr.GetStreamStats("unknown_stream")
+	require.False(t, ok)
+}
+
 func TestStreamStart(t *testing.T) {
 	mgr, _ := createManager()
 	tsl := `test_stream1 := (filter by f1 >= 2)`
@@ -604,7 +1088,7 @@ func createManagerWithFakeFafka(fk *fake.Kafka) (*streamManager, *tppm.TestProce
 	pm := tppm.NewTestProcessorManager()
 	cfg := &conf.Config{}
 	cfg.ApplyDefaults()
-	plm := NewStreamManager(fake.NewFakeMessageClientFactory(fk), &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false)
+	plm := NewStreamManager(fake.NewFakeMessageClientFactory(fk), nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false)
 	plm.SetProcessorManager(pm)
 	plm.Loaded()
 	return plm.(*streamManager), pm
@@ -625,6 +1109,17 @@ func getNextSeqStart(num int) int {
 func deployStreamReturnError(t *testing.T, tsl string, mgr StreamManager, injectedColumnNames []string, injectedColumnTypes []types.ColumnType,
 	testSource bool, testSink bool) error {
 	seqStart := getNextSeqStart(3)
+	streamDesc := createTestStreamDesc(t, tsl, injectedColumnNames, injectedColumnTypes, testSource, testSink)
+	receiverSeqs := []int{seqStart, seqStart + 1, seqStart + 2}
+	slabSeqs := []int{seqStart, seqStart + 1, seqStart + 2}
+	if streamDesc.Alter {
+		return mgr.AlterStream(streamDesc, receiverSeqs, slabSeqs, "", 123)
+	}
+	return mgr.DeployStream(streamDesc, receiverSeqs, slabSeqs, "", 123)
+}
+
+func createTestStreamDesc(t *testing.T, tsl string, injectedColumnNames []string, injectedColumnTypes []types.ColumnType,
+	testSource bool, testSink bool) parser.CreateStreamDesc {
 	cp, err := parser.NewParser(nil).ParseTSL(tsl)
 	require.NoError(t, err, tsl)
 	if testSink {
@@ -640,9 +1135,7 @@ func deployStreamReturnError(t *testing.T, tsl string, mgr StreamManager, inject
 		}
 		cp.CreateStream.OperatorDescs = append([]parser.Parseable{operDesc}, cp.CreateStream.OperatorDescs...)
 	}
-	receiverSeqs := []int{seqStart, seqStart + 1, seqStart + 2}
-	slabSeqs := []int{seqStart, seqStart + 1, seqStart + 2}
-	return mgr.DeployStream(*cp.CreateStream, receiverSeqs, slabSeqs, "", 123)
+	return *cp.CreateStream
 }
 
 func injectBatch(t *testing.T, streamNameIn string, partitionID int, processorID int, dataIn [][]any, mgr *streamManager,
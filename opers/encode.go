@@ -0,0 +1,191 @@
+package opers
+
+import (
+	"fmt"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/schemareg"
+	"github.com/spirit-labs/tektite/types"
+)
+
+// EncodeOperator encodes columns into a message in the schema registry wire format. Its output has the same columns as
+// a Kafka message, so it can be followed by 'kafka out' or 'bridge to'.
+// Each field of the schema is taken from the input column with the same name, fields with no such column are null.
+// Fields which are required by the schema must have a column.
+// If the input has 'key' or 'hdrs' bytes columns they are passed through, otherwise those columns are null.
+type EncodeOperator struct {
+	BaseOperator
+	inSchema          *OperatorSchema
+	outSchema         *OperatorSchema
+	codec             schemareg.Codec
+	schemaID          int
+	fieldColIndexes   []int
+	offsetColIndex    int
+	eventTimeColIndex int
+	keyColIndex       int
+	hdrsColIndex      int
+}
+
+func NewEncodeOperator(schema *OperatorSchema, desc *parser.EncodeDesc, regSchema *schemareg.Schema,
+	messageName string) (*EncodeOperator, error) {
+	codec, err := schemareg.NewCodec(regSchema, messageName)
+	if err != nil {
+		return nil, statementErrorAtTokenNamef("", desc, "invalid schema with id %d: %v", regSchema.ID, err)
+	}
+	e := &EncodeOperator{
+		inSchema:          schema,
+		codec:             codec,
+		schemaID:          regSchema.ID,
+		offsetColIndex:    -1,
+		eventTimeColIndex: -1,
+		keyColIndex:       -1,
+		hdrsColIndex:      -1,
+	}
+	colIndexes := map[string]int{}
+	inTypes := schema.EventSchema.ColumnTypes()
+	for i, colName := range schema.EventSchema.ColumnNames() {
+		colIndexes[colName] = i
+		switch colName {
+		case OffsetColName:
+			if inTypes[i].ID() == types.ColumnTypeIDInt {
+				e.offsetColIndex = i
+			}
+		case EventTimeColName:
+			e.eventTimeColIndex = i
+		case "key":
+			e.keyColIndex = i
+		case "hdrs":
+			e.hdrsColIndex = i
+		}
+	}
+	if e.eventTimeColIndex == -1 {
+		return nil, statementErrorAtTokenNamef("", desc, "input to 'encode' operator must have an '%s' column",
+			EventTimeColName)
+	}
+	for _, index := range []int{e.keyColIndex, e.hdrsColIndex} {
+		if index != -1 && inTypes[index].ID() != types.ColumnTypeIDBytes {
+			return nil, statementErrorAtTokenNamef("", desc, "column '%s' must be of type bytes",
+				schema.EventSchema.ColumnNames()[index])
+		}
+	}
+	for _, f := range codec.Fields() {
+		index, ok := colIndexes[f.Name]
+		if !ok {
+			if f.Required {
+				return nil, statementErrorAtTokenNamef("", desc,
+					"input to 'encode' operator has no column for required field '%s' of schema with id %d", f.Name,
+					regSchema.ID)
+			}
+			index = -1
+		} else if !types.ColumnTypesEqual(f.Type, inTypes[index]) {
+			return nil, statementErrorAtTokenNamef("", desc,
+				"column '%s' has type %s but schema with id %d requires type %s", f.Name, inTypes[index],
+				regSchema.ID, f.Type)
+		}
+		e.fieldColIndexes = append(e.fieldColIndexes, index)
+	}
+	outSchema := KafkaSchema
+	if e.offsetColIndex == -1 {
+		outSchema = evbatch.NewEventSchema(KafkaSchema.ColumnNames()[1:], KafkaSchema.ColumnTypes()[1:])
+	}
+	e.outSchema = &OperatorSchema{
+		EventSchema:     outSchema,
+		PartitionScheme: schema.PartitionScheme,
+	}
+	return e, nil
+}
+
+func (e *EncodeOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	outBatch, err := e.processBatch(batch)
+	if err != nil {
+		return nil, err
+	}
+	return outBatch, e.sendBatchDownStream(outBatch, execCtx)
+}
+
+func (e *EncodeOperator) processBatch(batch *evbatch.Batch) (*evbatch.Batch, error) {
+	defer batch.Release()
+	colBuilders := evbatch.CreateColBuilders(e.outSchema.EventSchema.ColumnTypes())
+	outColIndex := 0
+	copyCol := func(inColIndex int, rowIndex int, colType types.ColumnType) {
+		if inColIndex == -1 {
+			colBuilders[outColIndex].AppendNull()
+		} else {
+			evbatch.CopyColumnEntryWithCol(colType, batch.Columns[inColIndex], colBuilders[outColIndex], rowIndex)
+		}
+		outColIndex++
+	}
+	fields := e.codec.Fields()
+	values := make([]any, len(fields))
+	header := schemareg.AppendWireHeader(nil, e.schemaID)
+	for rowIndex := 0; rowIndex < batch.RowCount; rowIndex++ {
+		outColIndex = 0
+		if e.offsetColIndex != -1 {
+			copyCol(e.offsetColIndex, rowIndex, types.ColumnTypeInt)
+		}
+		copyCol(e.eventTimeColIndex, rowIndex, types.ColumnTypeTimestamp)
+		copyCol(e.keyColIndex, rowIndex, types.ColumnTypeBytes)
+		copyCol(e.hdrsColIndex, rowIndex, types.ColumnTypeBytes)
+		for i, colIndex := range e.fieldColIndexes {
+			values[i] = nil
+			if colIndex != -1 {
+				values[i] = getColumnValue(fields[i].Type, batch.Columns[colIndex], rowIndex)
+			}
+		}
+		payload, err := e.codec.Encode(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode with schema id %d: %w", e.schemaID, err)
+		}
+		val := make([]byte, 0, len(header)+len(payload))
+		val = append(val, header...)
+		val = append(val, payload...)
+		colBuilders[outColIndex].(*evbatch.BytesColBuilder).Append(val)
+	}
+	return evbatch.NewBatchFromBuilders(e.outSchema.EventSchema, colBuilders...), nil
+}
+
+func (e *EncodeOperator) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
+	panic("not supported in queries")
+}
+
+func (e *EncodeOperator) InSchema() *OperatorSchema {
+	return e.inSchema
+}
+
+func (e *EncodeOperator) OutSchema() *OperatorSchema {
+	return e.outSchema
+}
+
+func (e *EncodeOperator) Setup(StreamManagerCtx) error {
+	return nil
+}
+
+func (e *EncodeOperator) Teardown(_ StreamManagerCtx, completeCB func(error)) {
+	completeCB(nil)
+}
+
+func getColumnValue(colType types.ColumnType, col evbatch.Column, rowIndex int) any {
+	if col.IsNull(rowIndex) {
+		return nil
+	}
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		return col.(*evbatch.IntColumn).Get(rowIndex)
+	case types.ColumnTypeIDFloat:
+		return col.(*evbatch.FloatColumn).Get(rowIndex)
+	case types.ColumnTypeIDBool:
+		return col.(*evbatch.BoolColumn).Get(rowIndex)
+	case types.ColumnTypeIDDecimal:
+		return col.(*evbatch.DecimalColumn).Get(rowIndex)
+	case types.ColumnTypeIDString:
+		return col.(*evbatch.StringColumn).Get(rowIndex)
+	case types.ColumnTypeIDBytes:
+		return col.(*evbatch.BytesColumn).Get(rowIndex)
+	case types.ColumnTypeIDTimestamp:
+		return col.(*evbatch.TimestampColumn).Get(rowIndex)
+	case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+		return col.(*evbatch.NestedColumn).Get(rowIndex)
+	default:
+		panic(fmt.Sprintf("unknown column type %d", colType.ID()))
+	}
+}
@@ -0,0 +1,92 @@
+package opers
+
+import (
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/schemareg"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+var encodeColNames = []string{"offset", "event_time", "key", "customer", "id", "other"}
+var encodeColTypes = []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeTimestamp, types.ColumnTypeBytes,
+	types.ColumnTypeString, types.ColumnTypeInt, types.ColumnTypeFloat}
+
+func TestEncode(t *testing.T) {
+	registry := createTestRegistry(t)
+	eo, err := createEncodeOperator(t, registry, `my_stream := (encode subject="orders-value")`, encodeColNames, encodeColTypes)
+	require.NoError(t, err)
+	require.Equal(t, KafkaSchema, eo.OutSchema().EventSchema)
+
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), []byte("k1"), "bob", int64(1), 1.1},
+		{int64(1), types.NewTimestamp(1001), nil, "alice", nil, 2.2},
+	}
+	batch := createEventBatch(encodeColNames, encodeColTypes, dataIn)
+	out, err := eo.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	expectedOut := [][]any{
+		// qty is not in the input so is null, 'other' is not in the schema so is dropped
+		{int64(0), types.NewTimestamp(1000), []byte("k1"), nil, encodeTestMessage(t, registry, 2, int64(1), "bob", nil)},
+		{int64(1), types.NewTimestamp(1001), nil, nil, encodeTestMessage(t, registry, 2, nil, "alice", nil)},
+	}
+	require.Equal(t, expectedOut, convertBatchToAnyArray(out))
+
+	// And back again
+	do := createDecodeOperator(t, registry, `my_stream := (decode val subject="orders-value")`)
+	out, err = do.HandleStreamBatch(out, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	require.Equal(t, [][]any{
+		{int64(0), types.NewTimestamp(1000), []byte("k1"), nil, int64(1), "bob", nil},
+		// A non-nullable field with a null value is encoded as the zero value
+		{int64(1), types.NewTimestamp(1001), nil, nil, int64(0), "alice", nil},
+	}, convertBatchToAnyArray(out))
+}
+
+func TestEncodeNoOffset(t *testing.T) {
+	registry := createTestRegistry(t)
+	eo, err := createEncodeOperator(t, registry, `my_stream := (encode subject="orders-proto")`, encodeColNames[1:], encodeColTypes[1:])
+	require.NoError(t, err)
+	require.Equal(t, KafkaSchema.ColumnNames()[1:], eo.OutSchema().EventSchema.ColumnNames())
+	require.True(t, verifyKafkaSchema(eo.OutSchema().EventSchema))
+}
+
+func TestEncodeInvalidInput(t *testing.T) {
+	registry := createTestRegistry(t)
+	_, err := createEncodeOperator(t, registry, `my_stream := (encode subject="orders-value")`,
+		[]string{"id"}, []types.ColumnType{types.ColumnTypeInt})
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "input to 'encode' operator must have an 'event_time' column"))
+
+	_, err = createEncodeOperator(t, registry, `my_stream := (encode subject="orders-value")`,
+		[]string{"event_time", "id"}, []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString})
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "column 'id' has type string but schema with id 2 requires type int"))
+
+	_, err = createEncodeOperator(t, registry, `my_stream := (encode subject="orders-value")`,
+		[]string{"event_time", "key"}, []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString})
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "column 'key' must be of type bytes"))
+
+	// customer is required by the schema
+	_, err = createEncodeOperator(t, registry, `my_stream := (encode subject="orders-value")`,
+		[]string{"event_time", "id"}, []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeInt})
+	require.Error(t, err)
+	require.Equal(t, `input to 'encode' operator has no column for required field 'customer' of schema with id 2 (line 1 column 15):
+my_stream := (encode subject="orders-value")
+              ^`, err.Error())
+}
+
+func createEncodeOperator(t *testing.T, registry schemareg.Registry, tsl string, colNames []string,
+	colTypes []types.ColumnType) (*EncodeOperator, error) {
+	ast, err := parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	desc := ast.CreateStream.OperatorDescs[0].(*parser.EncodeDesc)
+	regSchema, err := registry.GetSchema(*desc.Subject, schemareg.LatestVersion)
+	require.NoError(t, err)
+	inSchema := evbatch.NewEventSchema(colNames, colTypes)
+	return NewEncodeOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		desc, regSchema, "")
+}
@@ -0,0 +1,209 @@
+package opers
+
+import (
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OperatorPlan describes a deployed operator, or an operator in a query, as returned by explain.
+type OperatorPlan struct {
+	ID int
+	// ParentID is the ID of the operator that sends batches to this one, or -1 if there isn't one in the plan
+	ParentID   int
+	Operator   string
+	Definition string
+	InSchema   *OperatorSchema
+	OutSchema  *OperatorSchema
+	// Partitions is the number of partitions the operator processes
+	Partitions int
+	// Repartition is true if the operator forwards batches to other processors
+	Repartition     bool
+	SlabIDs         []int
+	Retention       time.Duration
+	UpstreamStreams []string
+	ChildStreams    []string
+}
+
+var ExplainSchema = evbatch.NewEventSchema([]string{"id", "parent_id", "operator", "definition", "in_schema",
+	"out_schema", "mapping_id", "partitions", "processors", "repartition", "slab_ids", "retention", "upstream_streams",
+	"child_streams"},
+	[]types.ColumnType{types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeString,
+		types.ColumnTypeString, types.ColumnTypeString, types.ColumnTypeString, types.ColumnTypeInt, types.ColumnTypeInt,
+		types.ColumnTypeBool, types.ColumnTypeString, types.ColumnTypeString, types.ColumnTypeString,
+		types.ColumnTypeString})
+
+// ExplainBatch converts the plan to a batch with ExplainSchema, with one row per operator.
+func ExplainBatch(plan []*OperatorPlan) *evbatch.Batch {
+	colBuilders := evbatch.CreateColBuilders(ExplainSchema.ColumnTypes())
+	appendNullableString := func(colIndex int, s string) {
+		if s == "" {
+			colBuilders[colIndex].AppendNull()
+		} else {
+			colBuilders[colIndex].(*evbatch.StringColBuilder).Append(s)
+		}
+	}
+	for _, op := range plan {
+		colBuilders[0].(*evbatch.IntColBuilder).Append(int64(op.ID))
+		if op.ParentID == -1 {
+			colBuilders[1].AppendNull()
+		} else {
+			colBuilders[1].(*evbatch.IntColBuilder).Append(int64(op.ParentID))
+		}
+		colBuilders[2].(*evbatch.StringColBuilder).Append(op.Operator)
+		appendNullableString(3, op.Definition)
+		if op.InSchema == nil {
+			colBuilders[4].AppendNull()
+		} else {
+			colBuilders[4].(*evbatch.StringColBuilder).Append(op.InSchema.EventSchema.String())
+		}
+		colBuilders[5].(*evbatch.StringColBuilder).Append(op.OutSchema.EventSchema.String())
+		colBuilders[6].(*evbatch.StringColBuilder).Append(op.OutSchema.MappingID)
+		colBuilders[7].(*evbatch.IntColBuilder).Append(int64(op.Partitions))
+		colBuilders[8].(*evbatch.IntColBuilder).Append(int64(len(op.OutSchema.ProcessorIDs)))
+		colBuilders[9].(*evbatch.BoolColBuilder).Append(op.Repartition)
+		var slabIDs []string
+		for _, slabID := range op.SlabIDs {
+			slabIDs = append(slabIDs, strconv.Itoa(slabID))
+		}
+		appendNullableString(10, strings.Join(slabIDs, ", "))
+		if op.Retention == 0 {
+			colBuilders[11].AppendNull()
+		} else {
+			colBuilders[11].(*evbatch.StringColBuilder).Append(op.Retention.String())
+		}
+		appendNullableString(12, strings.Join(op.UpstreamStreams, ", "))
+		appendNullableString(13, strings.Join(op.ChildStreams, ", "))
+	}
+	return evbatch.NewBatchFromBuilders(ExplainSchema, colBuilders...)
+}
+
+// ExplainStream returns the plan of the deployed operators of the stream, or false if there is no such stream.
+func (sm *streamManager) ExplainStream(streamName string) ([]*OperatorPlan, bool) {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	info, ok := sm.streams[streamName]
+	if !ok {
+		return nil, false
+	}
+	retentions := map[int]time.Duration{}
+	for _, retention := range info.slabRetentions {
+		retentions[retention.slabID] = retention.Retention
+	}
+	var plan []*OperatorPlan
+	for i, oper := range info.Operators {
+		op := &OperatorPlan{
+			ID:          i,
+			ParentID:    i - 1,
+			InSchema:    oper.InSchema(),
+			OutSchema:   oper.OutSchema(),
+			Partitions:  oper.OutSchema().Partitions,
+			Repartition: isRepartitionOperator(oper),
+		}
+		if i < len(info.StreamDesc.OperatorDescs) {
+			desc := info.StreamDesc.OperatorDescs[i]
+			op.Operator = OperatorName(desc)
+			op.Definition = parser.Definition(desc)
+		} else {
+			// The test sink is added after the operators in the stream definition
+			op.Operator = "test sink"
+		}
+		if i < len(info.operatorSequences) {
+			op.SlabIDs = info.operatorSequences[i].slabIDs
+			for _, slabID := range op.SlabIDs {
+				if retention, ok := retentions[slabID]; ok && retention > op.Retention {
+					op.Retention = retention
+				}
+			}
+		}
+		for upstreamName, upstreamOper := range info.UpstreamStreamNames {
+			if upstreamOper == oper {
+				op.UpstreamStreams = append(op.UpstreamStreams, upstreamName)
+			}
+		}
+		sort.Strings(op.UpstreamStreams)
+		if i == len(info.Operators)-1 {
+			for childName := range info.DownstreamStreamNames {
+				op.ChildStreams = append(op.ChildStreams, childName)
+			}
+			sort.Strings(op.ChildStreams)
+		}
+		plan = append(plan, op)
+	}
+	return plan, true
+}
+
+func isRepartitionOperator(oper Operator) bool {
+	switch oper.(type) {
+	case *PartitionOperator, *JoinOperator, *UnionOperator:
+		return true
+	default:
+		return false
+	}
+}
+
+// OperatorName returns the name of the operator that the desc creates, as used in TSL
+func OperatorName(desc parser.Parseable) string {
+	switch desc.(type) {
+	case *parser.AggregateDesc:
+		return "aggregate"
+	case *parser.BackfillDesc:
+		return "backfill"
+	case *parser.BridgeFromDesc:
+		return "bridge from"
+	case *parser.BridgeToDesc:
+		return "bridge to"
+	case *parser.CdcFromDesc:
+		return "cdc from"
+	case *parser.ContinuationDesc:
+		return "continuation"
+	case *parser.DecodeDesc:
+		return "decode"
+	case *parser.DedupDesc:
+		return "dedup"
+	case *parser.EncodeDesc:
+		return "encode"
+	case *parser.ExplodeDesc:
+		return "explode"
+	case *parser.FilterDesc:
+		return "filter"
+	case *parser.GetDesc:
+		return "get"
+	case *parser.HttpSinkDesc:
+		return "http_sink"
+	case *parser.JoinDesc:
+		return "join"
+	case *parser.KafkaInDesc:
+		return "kafka in"
+	case *parser.KafkaOutDesc:
+		return "kafka out"
+	case *parser.MatchRecognizeDesc:
+		return "match_recognize"
+	case *parser.PartitionDesc:
+		return "partition"
+	case *parser.ProjectDesc:
+		return "project"
+	case *parser.ScanDesc:
+		return "scan"
+	case *parser.SortDesc:
+		return "sort"
+	case *parser.StoreStreamDesc:
+		return "store stream"
+	case *parser.StoreTableDesc:
+		return "store table"
+	case *parser.TopicDesc:
+		return "topic"
+	case *parser.TopNDesc:
+		return "topn"
+	case *parser.UnionDesc:
+		return "union"
+	case *TestSourceDesc:
+		return "test source"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,158 @@
+package opers
+
+import (
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"sort"
+)
+
+// ExplodeOperator outputs a row for each element of an array or map column. The exploded column is replaced by a
+// column holding the element, or by '<name>_key' and '<name>_value' columns for a map, and all other columns are
+// copied unchanged. Rows where the column is null or empty produce no output.
+type ExplodeOperator struct {
+	BaseOperator
+	inSchema    *OperatorSchema
+	outSchema   *OperatorSchema
+	colIndex    int
+	isMap       bool
+	elementType types.ColumnType
+}
+
+func NewExplodeOperator(schema *OperatorSchema, desc *parser.ExplodeDesc) (*ExplodeOperator, error) {
+	inNames := schema.EventSchema.ColumnNames()
+	inTypes := schema.EventSchema.ColumnTypes()
+	colIndex := -1
+	for i, colName := range inNames {
+		if colName == desc.ColumnName {
+			colIndex = i
+			break
+		}
+	}
+	if colIndex == -1 {
+		return nil, statementErrorAtTokenNamef(desc.ColumnName, desc, "unknown column '%s'", desc.ColumnName)
+	}
+	colType := inTypes[colIndex]
+	outName := desc.ColumnName
+	if desc.Alias != "" {
+		if isReservedIdentifierName(desc.Alias) {
+			return nil, statementErrorAtTokenNamef(desc.Alias, desc, "cannot use alias '%s', it is a reserved name",
+				desc.Alias)
+		}
+		outName = desc.Alias
+	}
+	e := &ExplodeOperator{
+		inSchema: schema,
+		colIndex: colIndex,
+	}
+	var explodedNames []string
+	var explodedTypes []types.ColumnType
+	switch t := colType.(type) {
+	case *types.ArrayType:
+		e.elementType = t.ElementType
+		explodedNames = []string{outName}
+		explodedTypes = []types.ColumnType{t.ElementType}
+	case *types.MapType:
+		e.isMap = true
+		e.elementType = t.ValueType
+		explodedNames = []string{outName + "_key", outName + "_value"}
+		explodedTypes = []types.ColumnType{types.ColumnTypeString, t.ValueType}
+	default:
+		return nil, statementErrorAtTokenNamef(desc.ColumnName, desc,
+			"cannot explode column '%s' of type %s, only array and map columns can be exploded", desc.ColumnName,
+			colType.String())
+	}
+	var outNames []string
+	var outTypes []types.ColumnType
+	outNames = append(outNames, inNames[:colIndex]...)
+	outTypes = append(outTypes, inTypes[:colIndex]...)
+	outNames = append(outNames, explodedNames...)
+	outTypes = append(outTypes, explodedTypes...)
+	outNames = append(outNames, inNames[colIndex+1:]...)
+	outTypes = append(outTypes, inTypes[colIndex+1:]...)
+	names := map[string]struct{}{}
+	for _, name := range outNames {
+		if _, exists := names[name]; exists {
+			return nil, statementErrorAtTokenNamef(desc.ColumnName, desc,
+				"cannot explode column '%s', output column '%s' already exists", desc.ColumnName, name)
+		}
+		names[name] = struct{}{}
+	}
+	outSchema := schema.Copy()
+	outSchema.EventSchema = evbatch.NewEventSchema(outNames, outTypes)
+	e.outSchema = outSchema
+	return e, nil
+}
+
+func (e *ExplodeOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	outBatch := e.processBatch(batch)
+	if outBatch.RowCount > 0 {
+		return outBatch, e.sendBatchDownStream(outBatch, execCtx)
+	}
+	return outBatch, nil
+}
+
+func (e *ExplodeOperator) processBatch(batch *evbatch.Batch) *evbatch.Batch {
+	defer batch.Release()
+	inTypes := e.inSchema.EventSchema.ColumnTypes()
+	outTypes := e.outSchema.EventSchema.ColumnTypes()
+	colBuilders := evbatch.CreateColBuilders(outTypes)
+	numExploded := len(outTypes) - len(inTypes) + 1
+	col := batch.Columns[e.colIndex].(*evbatch.NestedColumn)
+	copyOtherCols := func(rowIndex int) {
+		for inColIndex, colType := range inTypes {
+			outColIndex := inColIndex
+			if inColIndex == e.colIndex {
+				continue
+			} else if inColIndex > e.colIndex {
+				outColIndex += numExploded - 1
+			}
+			evbatch.CopyColumnEntryWithCol(colType, batch.Columns[inColIndex], colBuilders[outColIndex], rowIndex)
+		}
+	}
+	for rowIndex := 0; rowIndex < batch.RowCount; rowIndex++ {
+		if col.IsNull(rowIndex) {
+			continue
+		}
+		val := col.Get(rowIndex)
+		if e.isMap {
+			m := val.(map[string]any)
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				copyOtherCols(rowIndex)
+				colBuilders[e.colIndex].(*evbatch.StringColBuilder).Append(k)
+				appendValueToBuilder(e.elementType, colBuilders[e.colIndex+1], m[k])
+			}
+		} else {
+			for _, elem := range val.([]any) {
+				copyOtherCols(rowIndex)
+				appendValueToBuilder(e.elementType, colBuilders[e.colIndex], elem)
+			}
+		}
+	}
+	return evbatch.NewBatchFromBuilders(e.outSchema.EventSchema, colBuilders...)
+}
+
+func (e *ExplodeOperator) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
+	panic("not supported in queries")
+}
+
+func (e *ExplodeOperator) InSchema() *OperatorSchema {
+	return e.inSchema
+}
+
+func (e *ExplodeOperator) OutSchema() *OperatorSchema {
+	return e.outSchema
+}
+
+func (e *ExplodeOperator) Setup(StreamManagerCtx) error {
+	return nil
+}
+
+func (e *ExplodeOperator) Teardown(_ StreamManagerCtx, completeCB func(error)) {
+	completeCB(nil)
+}
@@ -0,0 +1,87 @@
+package opers
+
+import (
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestExplodeArray(t *testing.T) {
+	colNames := []string{"event_time", "tags", "id"}
+	colTypes := []types.ColumnType{types.ColumnTypeTimestamp,
+		&types.ArrayType{ElementType: types.ColumnTypeString}, types.ColumnTypeInt}
+	eo, err := createExplodeOperator(t, "my_stream := (explode tags as tag)", colNames, colTypes)
+	require.NoError(t, err)
+	require.Equal(t, []string{"event_time", "tag", "id"}, eo.OutSchema().EventSchema.ColumnNames())
+	require.Equal(t, []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeInt},
+		eo.OutSchema().EventSchema.ColumnTypes())
+
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), []any{"a", nil, "b"}, int64(1)},
+		{types.NewTimestamp(1001), nil, int64(2)},
+		{types.NewTimestamp(1002), []any{}, int64(3)},
+		{types.NewTimestamp(1003), []any{"c"}, int64(4)},
+	}
+	batch := createEventBatch(colNames, colTypes, dataIn)
+	out, err := eo.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	require.Equal(t, [][]any{
+		{types.NewTimestamp(1000), "a", int64(1)},
+		{types.NewTimestamp(1000), nil, int64(1)},
+		{types.NewTimestamp(1000), "b", int64(1)},
+		{types.NewTimestamp(1003), "c", int64(4)},
+	}, convertBatchToAnyArray(out))
+}
+
+func TestExplodeMap(t *testing.T) {
+	colNames := []string{"event_time", "attrs"}
+	colTypes := []types.ColumnType{types.ColumnTypeTimestamp, &types.MapType{ValueType: types.ColumnTypeInt}}
+	eo, err := createExplodeOperator(t, "my_stream := (explode attrs)", colNames, colTypes)
+	require.NoError(t, err)
+	require.Equal(t, []string{"event_time", "attrs_key", "attrs_value"}, eo.OutSchema().EventSchema.ColumnNames())
+
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), map[string]any{"y": int64(2), "x": int64(1), "z": nil}},
+	}
+	batch := createEventBatch(colNames, colTypes, dataIn)
+	out, err := eo.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	// Map entries are output in key order
+	require.Equal(t, [][]any{
+		{types.NewTimestamp(1000), "x", int64(1)},
+		{types.NewTimestamp(1000), "y", int64(2)},
+		{types.NewTimestamp(1000), "z", nil},
+	}, convertBatchToAnyArray(out))
+}
+
+func TestExplodeInvalid(t *testing.T) {
+	colNames := []string{"event_time", "tags", "tag"}
+	colTypes := []types.ColumnType{types.ColumnTypeTimestamp,
+		&types.ArrayType{ElementType: types.ColumnTypeString}, types.ColumnTypeString}
+
+	_, err := createExplodeOperator(t, "my_stream := (explode foo)", colNames, colTypes)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "unknown column 'foo'"))
+
+	_, err = createExplodeOperator(t, "my_stream := (explode tag)", colNames, colTypes)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(),
+		"cannot explode column 'tag' of type string, only array and map columns can be exploded"))
+
+	_, err = createExplodeOperator(t, "my_stream := (explode tags as tag)", colNames, colTypes)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "cannot explode column 'tags', output column 'tag' already exists"))
+}
+
+func createExplodeOperator(t *testing.T, tsl string, colNames []string,
+	colTypes []types.ColumnType) (*ExplodeOperator, error) {
+	ast, err := parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	desc := ast.CreateStream.OperatorDescs[0].(*parser.ExplodeDesc)
+	inSchema := evbatch.NewEventSchema(colNames, colTypes)
+	return NewExplodeOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		desc)
+}
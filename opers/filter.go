@@ -24,12 +24,13 @@ func NewFilterOperator(schema *OperatorSchema, exprDesc parser.ExprDesc, express
 
 type FilterOperator struct {
 	BaseOperator
+	rowErrorPolicy
 	schema *OperatorSchema
 	expr   expr.Expression
 }
 
 func (f *FilterOperator) HandleQueryBatch(batch *evbatch.Batch, execCtx QueryExecContext) (*evbatch.Batch, error) {
-	outBatch, err := f.processBatch(batch)
+	outBatch, err := f.processBatch(batch, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -37,23 +38,28 @@ func (f *FilterOperator) HandleQueryBatch(batch *evbatch.Batch, execCtx QueryExe
 }
 
 func (f *FilterOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
-	outBatch, err := f.processBatch(batch)
+	var records deadLetterRecords
+	outBatch, err := f.processBatch(batch, &records)
 	if err != nil {
 		return nil, err
 	}
+	f.rowErrors.sendDeadLetters(&records, execCtx)
 	if outBatch.RowCount > 0 {
 		return outBatch, f.sendBatchDownStream(outBatch, execCtx)
 	}
 	return outBatch, nil
 }
 
-func (f *FilterOperator) processBatch(batch *evbatch.Batch) (*evbatch.Batch, error) {
+func (f *FilterOperator) processBatch(batch *evbatch.Batch, records *deadLetterRecords) (*evbatch.Batch, error) {
 	defer batch.Release()
 	colBuilders := evbatch.CreateColBuilders(f.schema.EventSchema.ColumnTypes())
 	for rowIndex := 0; rowIndex < batch.RowCount; rowIndex++ {
 		accept, null, err := f.expr.EvalBool(rowIndex, batch)
 		if err != nil {
-			return nil, err
+			if err := f.rowErrors.handleRowError(err, rowIndex, batch, records); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		if !null && accept {
 			for colIndex, ft := range f.schema.EventSchema.ColumnTypes() {
@@ -0,0 +1,352 @@
+package opers
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/ipc"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/asl/conf"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/kafka"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHttpSinkMethod         = http.MethodPost
+	defaultHttpSinkBatchSize      = 500
+	defaultHttpSinkConcurrency    = 1
+	defaultHttpSinkRequestTimeout = 10 * time.Second
+	defaultHttpSinkRetention      = 24 * time.Hour
+	// maxHttpSinkErrorBodySize is how much of the body of an error response is included in the error
+	maxHttpSinkErrorBodySize = 4 * 1024
+
+	jsonContentType  = "application/json"
+	arrowContentType = "application/vnd.apache.arrow.stream"
+)
+
+// HttpSinkOperator sends the rows of a stream to an HTTP endpoint. Batches are always stored before they are sent, and
+// the offset of the last row sent is committed with the processor version on receipt of a barrier. If a request fails,
+// or the node fails before the version completes, the rows after the last committed offset are sent again by the
+// backfill operator, so delivery is at-least-once. Receivers can use the offset column to detect duplicates.
+type HttpSinkOperator struct {
+	*BridgeToOperator
+	producer *httpSinkProducer
+}
+
+func NewHttpSinkOperator(cfg *conf.Config, desc *parser.HttpSinkDesc, storeStreamOperator *StoreStreamOperator,
+	backFillOperator *BackfillOperator) (*HttpSinkOperator, error) {
+	u, err := url.Parse(desc.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, statementErrorAtTokenNamef("url", desc, "'url' must be an absolute http or https URL")
+	}
+	method := defaultHttpSinkMethod
+	if desc.Method != nil {
+		method = strings.ToUpper(*desc.Method)
+		if method != http.MethodPost && method != http.MethodPut && method != http.MethodPatch {
+			return nil, statementErrorAtTokenNamef("method", desc, "'method' must be one of 'POST', 'PUT' or 'PATCH'")
+		}
+	}
+	batchSize := defaultHttpSinkBatchSize
+	if desc.BatchSize != nil {
+		batchSize = *desc.BatchSize
+		if batchSize < 1 {
+			return nil, statementErrorAtTokenNamef("batch_size", desc, "'batch_size' must be > 0")
+		}
+	}
+	concurrency := defaultHttpSinkConcurrency
+	if desc.Concurrency != nil {
+		concurrency = *desc.Concurrency
+		if concurrency < 1 {
+			return nil, statementErrorAtTokenNamef("concurrency", desc, "'concurrency' must be > 0")
+		}
+	}
+	requestTimeout := defaultHttpSinkRequestTimeout
+	if desc.RequestTimeout != nil {
+		requestTimeout = *desc.RequestTimeout
+		if requestTimeout <= 0 {
+			return nil, statementErrorAtTokenNamef("request_timeout", desc, "'request_timeout' must be > 0")
+		}
+	}
+	initialRetryDelay, maxRetryDelay, err := getRetryDelays(desc.InitialRetryDelay, desc.MaxRetryDelay, desc)
+	if err != nil {
+		return nil, err
+	}
+	schema := storeStreamOperator.OutSchema()
+	producer := &httpSinkProducer{
+		client:      &http.Client{Timeout: requestTimeout},
+		url:         desc.URL,
+		method:      method,
+		headers:     desc.Headers,
+		batchSize:   batchSize,
+		concurrency: concurrency,
+	}
+	if desc.Format != nil && *desc.Format == parser.HttpSinkFormatArrow {
+		producer.contentType = arrowContentType
+		producer.encoder = newArrowEncoder(schema.EventSchema)
+	} else {
+		producer.contentType = jsonContentType
+		producer.encoder = newJSONEncoder(schema.EventSchema)
+	}
+	// The producer is shared by all partitions
+	producers := make([]kafka.MessageProducer, maxPartitionID(schema)+1)
+	for partID := range schema.PartitionProcessorMapping {
+		producers[partID] = producer
+	}
+	bto := newBridgeToOperator(cfg, "http_sink", fmt.Sprintf("url %s", desc.URL), storeStreamOperator,
+		backFillOperator, producers, initialRetryDelay, maxRetryDelay)
+	return &HttpSinkOperator{BridgeToOperator: bto, producer: producer}, nil
+}
+
+func (h *HttpSinkOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	// We store the batch first, this adds the offset if the stream does not have one
+	stored, err := h.storeStreamOperator.HandleStreamBatch(batch, execCtx)
+	if err != nil {
+		return nil, err
+	}
+	// If we're not live, or the send fails, the batch will be sent when back-filling from the last committed offset
+	_, err = h.backFillOperator.HandleStreamBatch(stored, execCtx)
+	if err != nil && err != sfe {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// httpSinkProducer sends batches to the URL, split into requests of at most batchSize rows, with up to concurrency
+// requests in flight at once. SendBatch returns an error if any request fails, in which case the whole batch will be
+// sent again.
+type httpSinkProducer struct {
+	client      *http.Client
+	url         string
+	method      string
+	headers     map[string]string
+	contentType string
+	batchSize   int
+	concurrency int
+	encoder     func(batch *evbatch.Batch, start int, end int) ([]byte, error)
+}
+
+func (h *httpSinkProducer) SendBatch(batch *evbatch.Batch) error {
+	numRequests := (batch.RowCount + h.batchSize - 1) / h.batchSize
+	errs := make([]error, numRequests)
+	sem := make(chan struct{}, h.concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < numRequests; i++ {
+		start := i * h.batchSize
+		end := start + h.batchSize
+		if end > batch.RowCount {
+			end = batch.RowCount
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = h.sendRows(batch, start, end)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *httpSinkProducer) sendRows(batch *evbatch.Batch, start int, end int) error {
+	body, err := h.encoder(batch, start, end)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(h.method, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", h.contentType)
+	for name, value := range h.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// The body must be read to the end for the connection to be reused
+		//goland:noinspection GoUnhandledErrorResult
+		io.Copy(io.Discard, resp.Body)
+		//goland:noinspection GoUnhandledErrorResult
+		resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxHttpSinkErrorBodySize))
+		if err != nil {
+			return err
+		}
+		return errors.Errorf("%s %s returned status %d: %s", h.method, h.url, resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func (h *httpSinkProducer) Start() error {
+	return nil
+}
+
+func (h *httpSinkProducer) Stop() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
+
+// newJSONEncoder returns an encoder which writes rows as a JSON array of objects, with the columns as fields
+func newJSONEncoder(schema *evbatch.EventSchema) func(batch *evbatch.Batch, start int, end int) ([]byte, error) {
+	rowType := &types.StructType{
+		FieldNames: schema.ColumnNames(),
+		FieldTypes: schema.ColumnTypes(),
+	}
+	return func(batch *evbatch.Batch, start int, end int) ([]byte, error) {
+		buff := []byte{'['}
+		fields := make([]any, len(rowType.FieldTypes))
+		for rowIndex := start; rowIndex < end; rowIndex++ {
+			if rowIndex > start {
+				buff = append(buff, ',')
+			}
+			for i, colType := range rowType.FieldTypes {
+				fields[i] = getColumnValue(colType, batch.Columns[i], rowIndex)
+			}
+			buff = types.AppendJSON(buff, rowType, fields)
+		}
+		return append(buff, ']'), nil
+	}
+}
+
+// newArrowEncoder returns an encoder which writes rows as an Arrow IPC stream containing a single record batch
+func newArrowEncoder(schema *evbatch.EventSchema) func(batch *evbatch.Batch, start int, end int) ([]byte, error) {
+	colTypes := schema.ColumnTypes()
+	fields := make([]arrow.Field, len(colTypes))
+	for i, colType := range colTypes {
+		fields[i] = arrow.Field{Name: schema.ColumnNames()[i], Type: toArrowType(colType), Nullable: true}
+	}
+	arrowSchema := arrow.NewSchema(fields, nil)
+	mem := memory.NewGoAllocator()
+	return func(batch *evbatch.Batch, start int, end int) ([]byte, error) {
+		builder := array.NewRecordBuilder(mem, arrowSchema)
+		defer builder.Release()
+		for i, colType := range colTypes {
+			fieldBuilder := builder.Field(i)
+			for rowIndex := start; rowIndex < end; rowIndex++ {
+				appendArrowValue(fieldBuilder, colType, getColumnValue(colType, batch.Columns[i], rowIndex))
+			}
+		}
+		rec := builder.NewRecord()
+		defer rec.Release()
+		var buff bytes.Buffer
+		writer := ipc.NewWriter(&buff, ipc.WithSchema(arrowSchema), ipc.WithAllocator(mem))
+		if err := writer.Write(rec); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buff.Bytes(), nil
+	}
+}
+
+func toArrowType(colType types.ColumnType) arrow.DataType {
+	switch t := colType.(type) {
+	case *types.DecimalType:
+		return &arrow.Decimal128Type{Precision: int32(t.Precision), Scale: int32(t.Scale)}
+	case *types.ArrayType:
+		return arrow.ListOf(toArrowType(t.ElementType))
+	case *types.MapType:
+		return arrow.MapOf(arrow.BinaryTypes.String, toArrowType(t.ValueType))
+	case *types.StructType:
+		fields := make([]arrow.Field, len(t.FieldNames))
+		for i, name := range t.FieldNames {
+			fields[i] = arrow.Field{Name: name, Type: toArrowType(t.FieldTypes[i]), Nullable: true}
+		}
+		return arrow.StructOf(fields...)
+	}
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		return arrow.PrimitiveTypes.Int64
+	case types.ColumnTypeIDFloat:
+		return arrow.PrimitiveTypes.Float64
+	case types.ColumnTypeIDBool:
+		return arrow.FixedWidthTypes.Boolean
+	case types.ColumnTypeIDString:
+		return arrow.BinaryTypes.String
+	case types.ColumnTypeIDBytes:
+		return arrow.BinaryTypes.Binary
+	case types.ColumnTypeIDTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_ms
+	default:
+		panic(fmt.Sprintf("unexpected type %s", colType.String()))
+	}
+}
+
+func appendArrowValue(builder array.Builder, colType types.ColumnType, val any) {
+	if val == nil {
+		builder.AppendNull()
+		return
+	}
+	switch t := colType.(type) {
+	case *types.ArrayType:
+		listBuilder := builder.(*array.ListBuilder)
+		listBuilder.Append(true)
+		for _, elem := range val.([]any) {
+			appendArrowValue(listBuilder.ValueBuilder(), t.ElementType, elem)
+		}
+		return
+	case *types.MapType:
+		mapBuilder := builder.(*array.MapBuilder)
+		mapBuilder.Append(true)
+		m := val.(map[string]any)
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			mapBuilder.KeyBuilder().(*array.StringBuilder).Append(k)
+			appendArrowValue(mapBuilder.ItemBuilder(), t.ValueType, m[k])
+		}
+		return
+	case *types.StructType:
+		structBuilder := builder.(*array.StructBuilder)
+		structBuilder.Append(true)
+		for i, fieldVal := range val.([]any) {
+			appendArrowValue(structBuilder.FieldBuilder(i), t.FieldTypes[i], fieldVal)
+		}
+		return
+	}
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		builder.(*array.Int64Builder).Append(val.(int64))
+	case types.ColumnTypeIDFloat:
+		builder.(*array.Float64Builder).Append(val.(float64))
+	case types.ColumnTypeIDBool:
+		builder.(*array.BooleanBuilder).Append(val.(bool))
+	case types.ColumnTypeIDDecimal:
+		builder.(*array.Decimal128Builder).Append(val.(types.Decimal).Num)
+	case types.ColumnTypeIDString:
+		builder.(*array.StringBuilder).Append(val.(string))
+	case types.ColumnTypeIDBytes:
+		builder.(*array.BinaryBuilder).Append(val.([]byte))
+	case types.ColumnTypeIDTimestamp:
+		builder.(*array.TimestampBuilder).Append(arrow.Timestamp(val.(types.Timestamp).Val))
+	default:
+		panic(fmt.Sprintf("unexpected type %s", colType.String()))
+	}
+}
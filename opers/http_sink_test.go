@@ -0,0 +1,262 @@
+package opers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/ipc"
+	"github.com/spirit-labs/tektite/asl/conf"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/testutils"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testHttpReceiver struct {
+	lock         sync.Mutex
+	requests     int
+	failRequests int
+	rows         []map[string]any
+	headers      []http.Header
+	methods      []string
+}
+
+func (r *testHttpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.requests++
+	if r.requests <= r.failRequests {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(body, &rows); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.rows = append(r.rows, rows...)
+	r.headers = append(r.headers, req.Header)
+	r.methods = append(r.methods, req.Method)
+}
+
+func (r *testHttpReceiver) receivedF1s() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var f1s []string
+	for _, row := range r.rows {
+		f1s = append(f1s, row["f1"].(string))
+	}
+	sort.Strings(f1s)
+	return f1s
+}
+
+func TestHttpSink(t *testing.T) {
+	receiver := &testHttpReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	tsl := `test_stream1 := (http_sink url = "` + server.URL + `/events" method = "put" headers = ("X-Api-Key" = "abc123") batch_size = 2 concurrency = 2)`
+	rowsReceived := testHttpSink(t, tsl, receiver, 5)
+
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	// 5 rows in batches of 2
+	require.Equal(t, 3, receiver.requests)
+	for i, header := range receiver.headers {
+		require.Equal(t, http.MethodPut, receiver.methods[i])
+		require.Equal(t, "abc123", header.Get("X-Api-Key"))
+		require.Equal(t, "application/json", header.Get("Content-Type"))
+	}
+	// The offset is added before the rows are sent
+	for _, row := range rowsReceived {
+		require.Contains(t, row, "offset")
+		require.Contains(t, row, "event_time")
+	}
+}
+
+func TestHttpSinkRetry(t *testing.T) {
+	receiver := &testHttpReceiver{failRequests: 3}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	tsl := `test_stream1 := (http_sink url = "` + server.URL + `" initial_retry_delay = 10ms max_retry_delay = 20ms)`
+	testHttpSink(t, tsl, receiver, 3)
+
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	require.Equal(t, 4, receiver.requests)
+}
+
+func TestHttpSinkErrorBodyTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(strings.Repeat("x", 10*maxHttpSinkErrorBodySize)))
+	}))
+	defer server.Close()
+
+	producer := &httpSinkProducer{
+		client:      server.Client(),
+		url:         server.URL,
+		method:      http.MethodPost,
+		contentType: jsonContentType,
+		batchSize:   10,
+		concurrency: 1,
+	}
+	schema := evbatch.NewEventSchema([]string{"f1"}, []types.ColumnType{types.ColumnTypeString})
+	producer.encoder = newJSONEncoder(schema)
+	batch := createEventBatch([]string{"f1"}, []types.ColumnType{types.ColumnTypeString}, [][]any{{"a"}})
+	err := producer.SendBatch(batch)
+	require.Error(t, err)
+	// Only the start of the body is included in the error
+	require.Equal(t, fmt.Sprintf("POST %s returned status 400: %s", server.URL,
+		strings.Repeat("x", maxHttpSinkErrorBodySize)), err.Error())
+}
+
+func testHttpSink(t *testing.T, tsl string, receiver *testHttpReceiver, numRows int) []map[string]any {
+	mgr, pm := createManager()
+	defer pm.Close()
+	pm.SetBatchHandler(mgr)
+	for procID := 0; procID < conf.DefaultProcessorCount; procID++ {
+		pm.AddActiveProcessor(procID)
+	}
+	columnNames := []string{"event_time", "f1"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString}
+	deployStream(t, tsl, mgr, columnNames, columnTypes, true, false)
+
+	info := mgr.GetStream("test_stream1")
+	require.NotNil(t, info)
+	processorID := info.Operators[0].OutSchema().PartitionScheme.PartitionProcessorMapping[0]
+
+	var dataIn [][]any
+	var expected []string
+	for i := 0; i < numRows; i++ {
+		f1 := string(rune('a' + i))
+		dataIn = append(dataIn, []any{types.NewTimestamp(int64(1000 + i)), f1})
+		expected = append(expected, f1)
+	}
+	injectBatch(t, "test_stream1", 0, processorID, dataIn, mgr, pm)
+
+	ok, err := testutils.WaitUntilWithError(func() (bool, error) {
+		return len(receiver.receivedF1s()) >= numRows, nil
+	}, 10*time.Second, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, expected, receiver.receivedF1s())
+
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	return receiver.rows
+}
+
+func TestHttpSinkJSONEncoder(t *testing.T) {
+	colNames := []string{"offset", "f1", "f2", "f3"}
+	colTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeTimestamp,
+		&types.ArrayType{ElementType: types.ColumnTypeInt}}
+	batch := createEventBatch(colNames, colTypes, [][]any{
+		{int64(0), "foo", types.NewTimestamp(1000), []any{int64(1), int64(2)}},
+		{int64(1), nil, types.NewTimestamp(1001), nil},
+		{int64(2), "bar", types.NewTimestamp(1002), []any{}},
+	})
+	encoder := newJSONEncoder(evbatch.NewEventSchema(colNames, colTypes))
+	body, err := encoder(batch, 1, 3)
+	require.NoError(t, err)
+	require.Equal(t, `[{"offset":1,"f1":null,"f2":1001,"f3":null},{"offset":2,"f1":"bar","f2":1002,"f3":[]}]`, string(body))
+}
+
+func TestHttpSinkArrowEncoder(t *testing.T) {
+	decType := &types.DecimalType{Precision: 10, Scale: 2}
+	colNames := []string{"offset", "f1", "f2", "f3", "f4", "f5"}
+	colTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeTimestamp, decType,
+		types.ColumnTypeBytes, &types.MapType{ValueType: types.ColumnTypeFloat}}
+	batch := createEventBatch(colNames, colTypes, [][]any{
+		{int64(0), "foo", types.NewTimestamp(1000), createDecimal(t, "12.34"), []byte("b0"),
+			map[string]any{"x": float64(1.5), "y": float64(2.5)}},
+		{int64(1), nil, types.NewTimestamp(1001), nil, nil, nil},
+	})
+	encoder := newArrowEncoder(evbatch.NewEventSchema(colNames, colTypes))
+	body, err := encoder(batch, 0, 2)
+	require.NoError(t, err)
+
+	reader, err := ipc.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	defer reader.Release()
+	require.True(t, reader.Next())
+	rec := reader.Record()
+	require.Equal(t, int64(2), rec.NumRows())
+	require.Equal(t, "offset", rec.Schema().Field(0).Name)
+	require.Equal(t, []int64{0, 1}, rec.Column(0).(*array.Int64).Int64Values())
+	f1 := rec.Column(1).(*array.String)
+	require.Equal(t, "foo", f1.Value(0))
+	require.True(t, f1.IsNull(1))
+	require.Equal(t, arrow.Timestamp(1001), rec.Column(2).(*array.Timestamp).Value(1))
+	require.Equal(t, createDecimal(t, "12.34").Num, rec.Column(3).(*array.Decimal128).Value(0))
+	require.Equal(t, []byte("b0"), rec.Column(4).(*array.Binary).Value(0))
+	m := rec.Column(5).(*array.Map)
+	require.Equal(t, 2, m.Keys().Len())
+	require.Equal(t, "x", m.Keys().(*array.String).Value(0))
+	require.Equal(t, 2.5, m.Items().(*array.Float64).Value(1))
+	require.True(t, m.IsNull(1))
+	require.False(t, reader.Next())
+}
+
+func TestHttpSinkInvalidArgs(t *testing.T) {
+	mgr, pm := createManager()
+	defer pm.Close()
+	columnNames := []string{"event_time", "f1"}
+	columnTypes := []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString}
+
+	err := deployStreamReturnError(t, `test_stream1 := (http_sink url = "ftp://foo/bar")`, mgr, columnNames, columnTypes,
+		true, false)
+	require.Error(t, err)
+	require.Equal(t, `'url' must be an absolute http or https URL (line 1 column 28):
+test_stream1 := (http_sink url = "ftp://foo/bar")
+                           ^`, err.Error())
+
+	err = deployStreamReturnError(t, `test_stream1 := (http_sink url = "http://foo/bar" method = "GET")`, mgr, columnNames,
+		columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `'method' must be one of 'POST', 'PUT' or 'PATCH' (line 1 column 51):
+test_stream1 := (http_sink url = "http://foo/bar" method = "GET")
+                                                  ^`, err.Error())
+
+	err = deployStreamReturnError(t, `test_stream1 := (http_sink url = "http://foo/bar" concurrency = 0)`, mgr, columnNames,
+		columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `'concurrency' must be > 0 (line 1 column 51):
+test_stream1 := (http_sink url = "http://foo/bar" concurrency = 0)
+                                                  ^`, err.Error())
+
+	// The parser only accepts positive durations, so the timeout is changed after parsing
+	streamDesc := createTestStreamDesc(t, `test_stream1 := (http_sink url = "http://foo/bar" request_timeout = 1s)`,
+		columnNames, columnTypes, true, false)
+	zero := time.Duration(0)
+	streamDesc.OperatorDescs[1].(*parser.HttpSinkDesc).RequestTimeout = &zero
+	err = mgr.DeployStream(streamDesc, []int{7000, 7001, 7002}, []int{7000, 7001, 7002}, "", 123)
+	require.Error(t, err)
+	require.Equal(t, `'request_timeout' must be > 0 (line 1 column 51):
+test_stream1 := (http_sink url = "http://foo/bar" request_timeout = 1s)
+                                                  ^`, err.Error())
+
+	err = deployStreamReturnError(t, `test_stream1 := (http_sink url = "http://foo/bar") -> (store stream)`, mgr, columnNames,
+		columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `'http_sink' must be the last operator in a stream (line 1 column 18):
+test_stream1 := (http_sink url = "http://foo/bar") -> (store stream)
+                 ^`, err.Error())
+}
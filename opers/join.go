@@ -86,6 +86,9 @@ func NewJoinOperator(leftTableSlabID int, rightTableSlabID int, left Operator, r
 	within time.Duration, nodeID int, receiverID int, op *parser.JoinDesc) (*JoinOperator, error) {
 
 	isStreamTableJoin := leftIsTable || rightIsTable
+	if (leftIsTable && isChangelogTable(left)) || (rightIsTable && isChangelogTable(right)) {
+		return nil, statementErrorAtTokenNamef("", op, "cannot join with a table that has 'changelog' enabled")
+	}
 
 	joinType := JoinTypeUnknown
 
@@ -174,11 +177,11 @@ func NewJoinOperator(leftTableSlabID int, rightTableSlabID int, left Operator, r
 		rightKeyCols = append(rightKeyCols, EventTimeColName)
 	}
 
-	leftTable, err := NewStoreTableOperator(left.OutSchema(), leftTableSlabID, leftKeyCols, nodeID, op)
+	leftTable, err := NewStoreTableOperator(left.OutSchema(), leftTableSlabID, leftKeyCols, false, nodeID, op)
 	if err != nil {
 		return nil, err
 	}
-	rightTable, err := NewStoreTableOperator(right.OutSchema(), rightTableSlabID, rightKeyCols, nodeID, op)
+	rightTable, err := NewStoreTableOperator(right.OutSchema(), rightTableSlabID, rightKeyCols, false, nodeID, op)
 	if err != nil {
 		return nil, err
 	}
@@ -759,6 +762,10 @@ func (r *inputOper) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecCo
 	return nil, nil
 }
 
+func (r *inputOper) getMetrics() *OperatorMetrics {
+	return r.jo.getMetrics()
+}
+
 func (r *inputOper) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
 	panic("not supported")
 }
@@ -824,6 +831,10 @@ func (b *batchReceiver) ForwardingProcessorCount() int {
 	return b.forwardingProcCount
 }
 
+func (b *batchReceiver) getMetrics() *OperatorMetrics {
+	return b.j.getMetrics()
+}
+
 func (b *batchReceiver) ReceiveBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
 	return nil, b.j.receiveBatch(batch, execCtx)
 }
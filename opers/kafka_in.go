@@ -8,6 +8,7 @@ import (
 	"github.com/spirit-labs/tektite/kafkaprotocol"
 	"github.com/spirit-labs/tektite/proc"
 	"github.com/spirit-labs/tektite/types"
+	"sync/atomic"
 	"time"
 )
 
@@ -54,6 +55,7 @@ type KafkaInOperator struct {
 	watermarkOperator        *WaterMarkOperator
 	hashCache                *partitionHashCache
 	partitionProducerMapping []map[int]map[int]int
+	inProgressBatches        atomic.Int64
 }
 
 func (k *KafkaInOperator) PartitionScheme() *PartitionScheme {
@@ -80,7 +82,16 @@ func (k *KafkaInOperator) IngestBatch(recordBatchBytes []byte, processor proc.Pr
 	evBatch := evbatch.NewBatch(RecordBatchSchema, bytesColBuilder.Build())
 	processBatch := proc.NewProcessBatch(processor.ID(), evBatch,
 		k.receiverID, partitionID, -1)
-	processor.GetReplicator().ReplicateBatch(processBatch, complFunc)
+	k.inProgressBatches.Add(1)
+	processor.GetReplicator().ReplicateBatch(processBatch, func(err error) {
+		k.inProgressBatches.Add(-1)
+		complFunc(err)
+	})
+}
+
+// backlog returns the number of produced batches which have not been processed yet
+func (k *KafkaInOperator) backlog() int64 {
+	return k.inProgressBatches.Load()
 }
 
 func (k *KafkaInOperator) maybeHandleIdempotentProducerBatch(partitionID, processorID int, bytes []byte) error {
@@ -0,0 +1,602 @@
+package opers
+
+import (
+	"fmt"
+	encoding2 "github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/expr"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"time"
+)
+
+const MatchStartColName = "match_start"
+
+// MatchRecognizeOperator detects sequences of rows, per key, that match a pattern of variables. Each variable is
+// defined by a boolean expression - a variable which is not defined matches any row. As well as the columns of the
+// current row, a definition can refer to the columns of the last row bound to a variable in the partial match, as
+// <variable>.<column>, e.g. 'ok.ip != fail.ip'. These are null if no row is bound to the variable yet. Rows are
+// considered in the order they arrive in the partition, and the rows of a match must be contiguous for the key and span
+// less than 'within'.
+//
+// The partial matches for each key are the states of an NFA, and they are kept in a slab so they survive restarts.
+// A match is emitted as soon as the pattern is satisfied, after which matching for the key resumes from the next row.
+// The match row contains the event_time of the last row, the key columns, the event_time of the first row, and, for
+// each variable, the number of rows it matched and the columns of the last row it matched.
+type MatchRecognizeOperator struct {
+	BaseOperator
+	inSchema          *OperatorSchema
+	outSchema         *OperatorSchema
+	keyExprs          []expr.Expression
+	keyTypes          []types.ColumnType
+	pattern           []parser.PatternElement
+	patternVarIndexes []int
+	vars              []string
+	varExprs          []expr.Expression
+	varExprRefs       []bool
+	hasVarRefs        bool
+	refSchema         *evbatch.EventSchema
+	captureColIndexes []int
+	captureColTypes   []types.ColumnType
+	eventTimeColIndex int
+	slabID            uint64
+	withinMillis      int64
+	hashCache         *partitionHashCache
+	nodeID            int
+}
+
+// matchRun is a partial match - the position in the pattern along with what has been captured so far
+type matchRun struct {
+	elem      int
+	count     int
+	start     int64
+	varCounts []int64
+	varRows   [][]byte
+}
+
+type matchKeyState struct {
+	key    []byte
+	runs   []*matchRun
+	stored bool
+	dirty  bool
+}
+
+func NewMatchRecognizeOperator(schema *OperatorSchema, desc *parser.MatchRecognizeDesc, slabID int, within time.Duration,
+	expressionFactory *expr.ExpressionFactory, nodeID int) (*MatchRecognizeOperator, error) {
+	eventTimeColIndex := -1
+	var captureColIndexes []int
+	var captureColNames []string
+	var captureColTypes []types.ColumnType
+	for i, colName := range schema.EventSchema.ColumnNames() {
+		switch colName {
+		case EventTimeColName:
+			eventTimeColIndex = i
+		case OffsetColName:
+		default:
+			captureColIndexes = append(captureColIndexes, i)
+			captureColNames = append(captureColNames, colName)
+			captureColTypes = append(captureColTypes, schema.EventSchema.ColumnTypes()[i])
+		}
+	}
+	if eventTimeColIndex == -1 {
+		return nil, statementErrorAtTokenNamef("", desc, "input to 'match_recognize' operator must have an '%s' column", EventTimeColName)
+	}
+	outColNames := []string{EventTimeColName}
+	outColTypes := []types.ColumnType{types.ColumnTypeTimestamp}
+	var keyExprs []expr.Expression
+	var keyTypes []types.ColumnType
+	for i, keyExprDesc := range desc.KeyExprs {
+		e, err := expressionFactory.CreateExpression(keyExprDesc, schema.EventSchema)
+		if err != nil {
+			return nil, err
+		}
+		keyExprs = append(keyExprs, e)
+		keyTypes = append(keyTypes, e.ResultType())
+		outColNames = append(outColNames, desc.KeyExprsStrings[i])
+		outColTypes = append(outColTypes, e.ResultType())
+	}
+	outColNames = append(outColNames, MatchStartColName)
+	outColTypes = append(outColTypes, types.ColumnTypeTimestamp)
+
+	// The distinct variables, in the order they first appear in the pattern
+	var vars []string
+	varIndexes := map[string]int{}
+	patternVarIndexes := make([]int, len(desc.Pattern))
+	for i, element := range desc.Pattern {
+		index, ok := varIndexes[element.Var]
+		if !ok {
+			index = len(vars)
+			varIndexes[element.Var] = index
+			vars = append(vars, element.Var)
+		}
+		patternVarIndexes[i] = index
+	}
+	// Definitions are created against the input columns followed by the columns of the row bound to each variable
+	refColNames := append([]string{}, schema.EventSchema.ColumnNames()...)
+	refColTypes := append([]types.ColumnType{}, schema.EventSchema.ColumnTypes()...)
+	varColNames := map[string]struct{}{}
+	for _, v := range vars {
+		for i, colName := range captureColNames {
+			refColName := fmt.Sprintf("%s.%s", v, colName)
+			refColNames = append(refColNames, refColName)
+			refColTypes = append(refColTypes, captureColTypes[i])
+			varColNames[refColName] = struct{}{}
+		}
+	}
+	refSchema := evbatch.NewEventSchema(refColNames, refColTypes)
+	varExprs := make([]expr.Expression, len(vars))
+	varExprRefs := make([]bool, len(vars))
+	hasVarRefs := false
+	for _, defineExprDesc := range desc.DefineExprs {
+		ok, exprDesc, alias, _ := parser.ExtractAlias(defineExprDesc)
+		if !ok || alias == "" {
+			return nil, defineExprDesc.ErrorAtPosition("define expression must be aliased with the name of a pattern variable")
+		}
+		index, ok := varIndexes[alias]
+		if !ok {
+			return nil, defineExprDesc.ErrorAtPosition("variable '%s' is not used in the pattern", alias)
+		}
+		if varExprs[index] != nil {
+			return nil, defineExprDesc.ErrorAtPosition("variable '%s' is defined more than once", alias)
+		}
+		e, err := expressionFactory.CreateExpression(exprDesc, refSchema)
+		if err != nil {
+			return nil, err
+		}
+		if e.ResultType().ID() != types.ColumnTypeIDBool {
+			return nil, exprDesc.ErrorAtPosition("definition of variable '%s' must be a boolean expression", alias)
+		}
+		varExprs[index] = e
+		varExprRefs[index] = referencesColumns(exprDesc, varColNames)
+		hasVarRefs = hasVarRefs || varExprRefs[index]
+	}
+	for _, v := range vars {
+		outColNames = append(outColNames, fmt.Sprintf("%s_count", v))
+		outColTypes = append(outColTypes, types.ColumnTypeInt)
+		for i, colName := range captureColNames {
+			outColNames = append(outColNames, fmt.Sprintf("%s_%s", v, colName))
+			outColTypes = append(outColTypes, captureColTypes[i])
+		}
+	}
+	outColSet := make(map[string]struct{}, len(outColNames))
+	for _, colName := range outColNames {
+		if _, exists := outColSet[colName]; exists {
+			return nil, statementErrorAtTokenNamef("", desc, "output of 'match_recognize' operator has duplicate column '%s'", colName)
+		}
+		outColSet[colName] = struct{}{}
+	}
+	outSchema := schema.Copy()
+	outSchema.EventSchema = evbatch.NewEventSchema(outColNames, outColTypes)
+	return &MatchRecognizeOperator{
+		inSchema:          schema,
+		outSchema:         outSchema,
+		keyExprs:          keyExprs,
+		keyTypes:          keyTypes,
+		pattern:           desc.Pattern,
+		patternVarIndexes: patternVarIndexes,
+		vars:              vars,
+		varExprs:          varExprs,
+		varExprRefs:       varExprRefs,
+		hasVarRefs:        hasVarRefs,
+		refSchema:         refSchema,
+		captureColIndexes: captureColIndexes,
+		captureColTypes:   captureColTypes,
+		eventTimeColIndex: eventTimeColIndex,
+		slabID:            uint64(slabID),
+		withinMillis:      within.Milliseconds(),
+		hashCache:         newPartitionHashCache(schema.MappingID, schema.Partitions),
+		nodeID:            nodeID,
+	}, nil
+}
+
+func (m *MatchRecognizeOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	outBatch, err := m.processBatch(batch, execCtx)
+	if err != nil {
+		return nil, err
+	}
+	if outBatch.RowCount > 0 {
+		return outBatch, m.sendBatchDownStream(outBatch, execCtx)
+	}
+	return outBatch, nil
+}
+
+func (m *MatchRecognizeOperator) processBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	defer batch.Release()
+	keyCols := make([]evbatch.Column, len(m.keyExprs))
+	for i, keyExpr := range m.keyExprs {
+		col, err := expr.EvalColumn(keyExpr, batch)
+		if err != nil {
+			return nil, err
+		}
+		keyCols[i] = col
+	}
+	varCols := make([]*evbatch.BoolColumn, len(m.varExprs))
+	for i, varExpr := range m.varExprs {
+		if varExpr == nil || m.varExprRefs[i] {
+			// Definitions that refer to bound rows are evaluated for each partial match
+			continue
+		}
+		col, err := expr.EvalColumn(varExpr, batch)
+		if err != nil {
+			return nil, err
+		}
+		varCols[i] = col.(*evbatch.BoolColumn)
+	}
+	eventTimeCol := batch.GetTimestampColumn(m.eventTimeColIndex)
+	partitionHash := m.hashCache.getHash(execCtx.PartitionID())
+	// Entries stored while processing this batch are not visible to execCtx.Get until the batch has been processed, so
+	// we keep the state of each key seen in this batch
+	keyStates := map[string]*matchKeyState{}
+	var keyOrder []string
+	colBuilders := evbatch.CreateColBuilders(m.outSchema.EventSchema.ColumnTypes())
+	for rowIndex := 0; rowIndex < batch.RowCount; rowIndex++ {
+		key := encoding2.EncodeEntryPrefix(partitionHash, m.slabID, keyInitialBufferSize)
+		for i, keyCol := range keyCols {
+			key = evbatch.EncodeKeyCol(rowIndex, keyCol, m.keyTypes[i], key)
+		}
+		state, ok := keyStates[string(key)]
+		if !ok {
+			var err error
+			state, err = m.loadState(key, execCtx)
+			if err != nil {
+				return nil, err
+			}
+			keyStates[string(key)] = state
+			keyOrder = append(keyOrder, string(key))
+		}
+		eventTime := eventTimeCol.Get(rowIndex).Val
+		row := evbatch.EncodeRowCols(batch, rowIndex, m.captureColIndexes, make([]byte, 0, rowInitialBufferSize))
+		match, err := m.processRow(state, batch, rowIndex, varCols, eventTime, row)
+		if err != nil {
+			return nil, err
+		}
+		if match != nil {
+			colBuilders[0].(*evbatch.TimestampColBuilder).Append(types.NewTimestamp(eventTime))
+			for i, keyCol := range keyCols {
+				evbatch.CopyColumnEntryWithCol(m.keyTypes[i], keyCol, colBuilders[1+i], rowIndex)
+			}
+			m.appendMatch(match, colBuilders)
+		}
+	}
+	for _, key := range keyOrder {
+		state := keyStates[key]
+		if state.dirty {
+			m.storeState(state, execCtx)
+		}
+	}
+	return evbatch.NewBatchFromBuilders(m.outSchema.EventSchema, colBuilders...), nil
+}
+
+// processRow moves each partial match for the key on by the row, and returns a completed match, if there is one
+func (m *MatchRecognizeOperator) processRow(state *matchKeyState, batch *evbatch.Batch, rowIndex int,
+	varCols []*evbatch.BoolColumn, eventTime int64, row []byte) (*matchRun, error) {
+	runs := make([]*matchRun, 0, len(state.runs)+1)
+	for _, run := range state.runs {
+		if eventTime-run.start >= m.withinMillis {
+			// Expired
+			continue
+		}
+		runs = append(runs, run)
+	}
+	// The row can also start a new match
+	runs = append(runs, nil)
+	varMatches, err := m.evalVarMatches(batch, rowIndex, varCols, runs)
+	if err != nil {
+		return nil, err
+	}
+	var next []*matchRun
+	for i, run := range runs {
+		next = m.advance(run, varMatches[i], eventTime, row, next)
+	}
+	next = dedupRuns(next, m.pattern)
+	state.dirty = state.dirty || len(next) > 0 || len(state.runs) > 0
+	// The runs are in order of start time, so if there are multiple complete matches we take the longest
+	for _, run := range next {
+		if m.isComplete(run) {
+			// Matching resumes after the last row of the match
+			state.runs = nil
+			return run, nil
+		}
+	}
+	state.runs = next
+	return nil, nil
+}
+
+// evalVarMatches returns, for each run, whether the row matches each variable. A nil run is a new match, so it has no
+// rows bound to variables.
+func (m *MatchRecognizeOperator) evalVarMatches(batch *evbatch.Batch, rowIndex int, varCols []*evbatch.BoolColumn,
+	runs []*matchRun) ([][]bool, error) {
+	rowMatches := make([]bool, len(m.vars))
+	for i, varCol := range varCols {
+		rowMatches[i] = varCol == nil || (!varCol.IsNull(rowIndex) && varCol.Get(rowIndex))
+	}
+	varMatches := make([][]bool, len(runs))
+	if !m.hasVarRefs {
+		for i := range runs {
+			varMatches[i] = rowMatches
+		}
+		return varMatches, nil
+	}
+	// The definitions that refer to bound rows are evaluated against a batch with a row for each run
+	refBatch := m.createRefBatch(batch, rowIndex, runs)
+	defer refBatch.Release()
+	refCols := make([]*evbatch.BoolColumn, len(m.varExprs))
+	for i, varExpr := range m.varExprs {
+		if !m.varExprRefs[i] {
+			continue
+		}
+		col, err := expr.EvalColumn(varExpr, refBatch)
+		if err != nil {
+			return nil, err
+		}
+		refCols[i] = col.(*evbatch.BoolColumn)
+	}
+	for runIndex := range runs {
+		matches := make([]bool, len(m.vars))
+		copy(matches, rowMatches)
+		for i, refCol := range refCols {
+			if refCol != nil {
+				matches[i] = !refCol.IsNull(runIndex) && refCol.Get(runIndex)
+			}
+		}
+		varMatches[runIndex] = matches
+	}
+	return varMatches, nil
+}
+
+// createRefBatch creates a batch with the refSchema, with a row for each run containing the columns of the row being
+// processed followed by the columns of the rows bound to each variable in the run
+func (m *MatchRecognizeOperator) createRefBatch(batch *evbatch.Batch, rowIndex int, runs []*matchRun) *evbatch.Batch {
+	colTypes := m.refSchema.ColumnTypes()
+	colBuilders := evbatch.CreateColBuilders(colTypes)
+	numInCols := len(batch.Columns)
+	for _, run := range runs {
+		for i, col := range batch.Columns {
+			evbatch.CopyColumnEntryWithCol(colTypes[i], col, colBuilders[i], rowIndex)
+		}
+		colIndex := numInCols
+		for v := range m.vars {
+			var varRow []byte
+			if run != nil {
+				varRow = run.varRows[v]
+			}
+			colIndex = m.appendVarRow(varRow, colBuilders, colIndex)
+		}
+	}
+	return evbatch.NewBatchFromBuilders(m.refSchema, colBuilders...)
+}
+
+// appendVarRow appends the captured columns of a row bound to a variable, or nulls if there is no row, starting at
+// colIndex. It returns the index of the next column.
+func (m *MatchRecognizeOperator) appendVarRow(varRow []byte, colBuilders []evbatch.ColumnBuilder, colIndex int) int {
+	numCaptureCols := len(m.captureColIndexes)
+	if varRow == nil {
+		for j := 0; j < numCaptureCols; j++ {
+			colBuilders[colIndex+j].AppendNull()
+		}
+	} else {
+		captureIndexes := make([]int, numCaptureCols)
+		for j := range captureIndexes {
+			captureIndexes[j] = colIndex + j
+		}
+		LoadColsFromValue(colBuilders, m.captureColTypes, captureIndexes, varRow)
+	}
+	return colIndex + numCaptureCols
+}
+
+// referencesColumns returns true if the expression refers to any of the columns
+func referencesColumns(desc parser.ExprDesc, colNames map[string]struct{}) bool {
+	switch d := desc.(type) {
+	case *parser.IdentifierExprDesc:
+		_, ok := colNames[d.IdentifierName]
+		return ok
+	case *parser.BinaryOperatorExprDesc:
+		return referencesColumns(d.Left, colNames) || referencesColumns(d.Right, colNames)
+	case *parser.UnaryOperatorExprDesc:
+		return referencesColumns(d.Operand, colNames)
+	case *parser.UnaryPostfixOperatorExprDesc:
+		return referencesColumns(d.Operand, colNames)
+	case *parser.FunctionExprDesc:
+		for _, argDesc := range d.ArgExprs {
+			if referencesColumns(argDesc, colNames) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// advance appends the runs that result from applying the row to the run. The run dies if the row does not extend it.
+// A nil run means a new match is started with the row.
+func (m *MatchRecognizeOperator) advance(run *matchRun, varMatches []bool, eventTime int64, row []byte,
+	next []*matchRun) []*matchRun {
+	nextElem := 0
+	if run != nil {
+		element := m.pattern[run.elem]
+		if (element.Max == -1 || run.count < element.Max) && varMatches[m.patternVarIndexes[run.elem]] {
+			next = append(next, run.extend(run.elem, run.count+1, m.patternVarIndexes[run.elem], row))
+		}
+		if run.count < element.Min {
+			return next
+		}
+		nextElem = run.elem + 1
+	}
+	// Try the following elements, skipping over any which are optional
+	for ; nextElem < len(m.pattern); nextElem++ {
+		varIndex := m.patternVarIndexes[nextElem]
+		if varMatches[varIndex] {
+			if run == nil {
+				newRun := &matchRun{
+					start:     eventTime,
+					varCounts: make([]int64, len(m.vars)),
+					varRows:   make([][]byte, len(m.vars)),
+				}
+				next = append(next, newRun.extend(nextElem, 1, varIndex, row))
+			} else {
+				next = append(next, run.extend(nextElem, 1, varIndex, row))
+			}
+		}
+		if m.pattern[nextElem].Min > 0 {
+			break
+		}
+	}
+	return next
+}
+
+func (r *matchRun) extend(elem int, count int, varIndex int, row []byte) *matchRun {
+	varCounts := make([]int64, len(r.varCounts))
+	copy(varCounts, r.varCounts)
+	varCounts[varIndex]++
+	varRows := make([][]byte, len(r.varRows))
+	copy(varRows, r.varRows)
+	varRows[varIndex] = row
+	return &matchRun{
+		elem:      elem,
+		count:     count,
+		start:     r.start,
+		varCounts: varCounts,
+		varRows:   varRows,
+	}
+}
+
+// dedupRuns removes runs which are at the same state of the NFA as an earlier run. Once the minimum of an unbounded
+// element has been reached the count no longer matters.
+func dedupRuns(runs []*matchRun, pattern []parser.PatternElement) []*matchRun {
+	type nfaState struct {
+		elem  int
+		count int
+	}
+	seen := map[nfaState]struct{}{}
+	var deduped []*matchRun
+	for _, run := range runs {
+		count := run.count
+		element := pattern[run.elem]
+		if element.Max == -1 && count > element.Min {
+			count = element.Min
+		}
+		s := nfaState{elem: run.elem, count: count}
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		deduped = append(deduped, run)
+	}
+	return deduped
+}
+
+func (m *MatchRecognizeOperator) isComplete(run *matchRun) bool {
+	if run.count < m.pattern[run.elem].Min {
+		return false
+	}
+	for _, element := range m.pattern[run.elem+1:] {
+		if element.Min > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MatchRecognizeOperator) appendMatch(match *matchRun, colBuilders []evbatch.ColumnBuilder) {
+	colIndex := 1 + len(m.keyExprs)
+	colBuilders[colIndex].(*evbatch.TimestampColBuilder).Append(types.NewTimestamp(match.start))
+	colIndex++
+	for i := range m.vars {
+		colBuilders[colIndex].(*evbatch.IntColBuilder).Append(match.varCounts[i])
+		colIndex++
+		colIndex = m.appendVarRow(match.varRows[i], colBuilders, colIndex)
+	}
+}
+
+func (m *MatchRecognizeOperator) loadState(key []byte, execCtx StreamExecContext) (*matchKeyState, error) {
+	state := &matchKeyState{key: key}
+	v, err := execCtx.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return state, nil
+	}
+	state.stored = true
+	numRuns, off := encoding2.ReadUint32FromBufferLE(v, 0)
+	state.runs = make([]*matchRun, numRuns)
+	for i := range state.runs {
+		run := &matchRun{
+			varCounts: make([]int64, len(m.vars)),
+			varRows:   make([][]byte, len(m.vars)),
+		}
+		var u32 uint32
+		var u64 uint64
+		u32, off = encoding2.ReadUint32FromBufferLE(v, off)
+		run.elem = int(u32)
+		u32, off = encoding2.ReadUint32FromBufferLE(v, off)
+		run.count = int(u32)
+		u64, off = encoding2.ReadUint64FromBufferLE(v, off)
+		run.start = int64(u64)
+		for j := range m.vars {
+			u64, off = encoding2.ReadUint64FromBufferLE(v, off)
+			run.varCounts[j] = int64(u64)
+			u32, off = encoding2.ReadUint32FromBufferLE(v, off)
+			if u32 > 0 {
+				run.varRows[j] = common.ByteSliceCopy(v[off : off+int(u32)])
+				off += int(u32)
+			}
+		}
+		state.runs[i] = run
+	}
+	return state, nil
+}
+
+func (m *MatchRecognizeOperator) storeState(state *matchKeyState, execCtx StreamExecContext) {
+	if execCtx.WriteVersion() < 0 {
+		panic(fmt.Sprintf("invalid write version: %d", execCtx.WriteVersion()))
+	}
+	var val []byte
+	if len(state.runs) > 0 {
+		val = make([]byte, 0, 64)
+		val = encoding2.AppendUint32ToBufferLE(val, uint32(len(state.runs)))
+		for _, run := range state.runs {
+			val = encoding2.AppendUint32ToBufferLE(val, uint32(run.elem))
+			val = encoding2.AppendUint32ToBufferLE(val, uint32(run.count))
+			val = encoding2.AppendUint64ToBufferLE(val, uint64(run.start))
+			for j := range m.vars {
+				val = encoding2.AppendUint64ToBufferLE(val, uint64(run.varCounts[j]))
+				val = encoding2.AppendUint32ToBufferLE(val, uint32(len(run.varRows[j])))
+				val = append(val, run.varRows[j]...)
+			}
+		}
+	} else if !state.stored {
+		// Nothing to delete
+		return
+	}
+	storeKey := encoding2.EncodeVersion(common.ByteSliceCopy(state.key), uint64(execCtx.WriteVersion()))
+	if log.DebugEnabled {
+		log.Debugf("node %d match_recognize storing %d partial matches for key %v with version %d", m.nodeID,
+			len(state.runs), storeKey, execCtx.WriteVersion())
+	}
+	execCtx.StoreEntry(common.KV{
+		Key:   storeKey,
+		Value: val,
+	}, false)
+}
+
+func (m *MatchRecognizeOperator) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
+	panic("not supported in queries")
+}
+
+func (m *MatchRecognizeOperator) InSchema() *OperatorSchema {
+	return m.inSchema
+}
+
+func (m *MatchRecognizeOperator) OutSchema() *OperatorSchema {
+	return m.outSchema
+}
+
+func (m *MatchRecognizeOperator) Setup(StreamManagerCtx) error {
+	return nil
+}
+
+func (m *MatchRecognizeOperator) Teardown(_ StreamManagerCtx, completeCB func(error)) {
+	completeCB(nil)
+}
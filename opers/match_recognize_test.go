@@ -0,0 +1,186 @@
+package opers
+
+import (
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/expr"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+var matchColNames = []string{"offset", "event_time", "user_id", "status"}
+var matchColTypes = []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeString}
+
+const loginPatternTsl = `my_stream := (match_recognize by user_id define status == "failed" as fail, status == "ok" as ok pattern="fail{3} ok" within=5m)`
+
+func TestMatchRecognize(t *testing.T) {
+	mo := createMatchRecognizeOperator(t, loginPatternTsl)
+	require.Equal(t, []string{"event_time", "user_id", "match_start", "fail_count", "fail_user_id", "fail_status",
+		"ok_count", "ok_user_id", "ok_status"}, mo.OutSchema().EventSchema.ColumnNames())
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "u1", "failed"},
+		{int64(1), types.NewTimestamp(1001), "u2", "failed"},
+		{int64(2), types.NewTimestamp(2000), "u1", "failed"},
+		{int64(3), types.NewTimestamp(3000), "u1", "failed"},
+		{int64(4), types.NewTimestamp(3001), "u2", "ok"},
+		{int64(5), types.NewTimestamp(4000), "u1", "ok"},
+	}
+	expectedOut := [][]any{
+		{types.NewTimestamp(4000), "u1", types.NewTimestamp(1000), int64(3), "u1", "failed", int64(1), "u1", "ok"},
+	}
+	testMatchRecognize(t, mo, &testExecCtx{version: 100, partitionID: 3}, dataIn, expectedOut)
+}
+
+func TestMatchRecognizeAcrossBatches(t *testing.T) {
+	mo := createMatchRecognizeOperator(t, loginPatternTsl)
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "u1", "failed"},
+		{int64(1), types.NewTimestamp(2000), "u1", "failed"},
+		// Four failures, so a match can start at either the first or the second
+		{int64(2), types.NewTimestamp(2500), "u1", "failed"},
+		{int64(3), types.NewTimestamp(3000), "u1", "failed"},
+	}
+	testMatchRecognize(t, mo, ctx, dataIn, nil)
+	require.Equal(t, 1, len(ctx.entries))
+
+	makeStoredVisible(ctx)
+	dataIn = [][]any{
+		{int64(4), types.NewTimestamp(4000), "u1", "ok"},
+		{int64(5), types.NewTimestamp(5000), "u1", "ok"},
+	}
+	expectedOut := [][]any{
+		{types.NewTimestamp(4000), "u1", types.NewTimestamp(2000), int64(3), "u1", "failed", int64(1), "u1", "ok"},
+	}
+	testMatchRecognize(t, mo, ctx, dataIn, expectedOut)
+	// There are no partial matches left, so the state is deleted
+	require.Equal(t, 1, len(ctx.entries))
+	require.Nil(t, ctx.entries[0].Value)
+}
+
+func TestMatchRecognizeWithin(t *testing.T) {
+	mo := createMatchRecognizeOperator(t, loginPatternTsl)
+	fiveMins := (5 * time.Minute).Milliseconds()
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "u1", "failed"},
+		{int64(1), types.NewTimestamp(2000), "u1", "failed"},
+		{int64(2), types.NewTimestamp(3000), "u1", "failed"},
+		{int64(3), types.NewTimestamp(1000 + fiveMins), "u1", "ok"},
+	}
+	testMatchRecognize(t, mo, &testExecCtx{version: 100, partitionID: 3}, dataIn, nil)
+}
+
+func TestMatchRecognizeContiguous(t *testing.T) {
+	mo := createMatchRecognizeOperator(t, loginPatternTsl)
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "u1", "failed"},
+		{int64(1), types.NewTimestamp(2000), "u1", "failed"},
+		{int64(2), types.NewTimestamp(2500), "u1", "logout"},
+		{int64(3), types.NewTimestamp(3000), "u1", "failed"},
+		{int64(4), types.NewTimestamp(4000), "u1", "ok"},
+	}
+	testMatchRecognize(t, mo, &testExecCtx{version: 100, partitionID: 3}, dataIn, nil)
+}
+
+func TestMatchRecognizeQuantifiers(t *testing.T) {
+	// 'any' is not defined so matches any row
+	mo := createMatchRecognizeOperator(t,
+		`my_stream := (match_recognize by user_id define status == "failed" as fail, status == "ok" as ok pattern="fail{2,} ok? any" within=5m)`)
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "u1", "failed"},
+		{int64(1), types.NewTimestamp(2000), "u1", "failed"},
+		{int64(2), types.NewTimestamp(3000), "u1", "failed"},
+		{int64(3), types.NewTimestamp(4000), "u2", "failed"},
+		{int64(4), types.NewTimestamp(5000), "u2", "failed"},
+		{int64(5), types.NewTimestamp(6000), "u2", "ok"},
+		{int64(6), types.NewTimestamp(7000), "u2", "logout"},
+	}
+	expectedOut := [][]any{
+		// A match is emitted as soon as the pattern is satisfied
+		{types.NewTimestamp(3000), "u1", types.NewTimestamp(1000), int64(2), "u1", "failed", int64(0), nil, nil,
+			int64(1), "u1", "failed"},
+		{types.NewTimestamp(6000), "u2", types.NewTimestamp(4000), int64(2), "u2", "failed", int64(0), nil, nil,
+			int64(1), "u2", "ok"},
+	}
+	testMatchRecognize(t, mo, &testExecCtx{version: 100, partitionID: 3}, dataIn, expectedOut)
+}
+
+func TestMatchRecognizeReferToBoundRows(t *testing.T) {
+	// Login failed 3 times then succeeded from a new IP
+	colNames := []string{"offset", "event_time", "user_id", "status", "ip"}
+	colTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeTimestamp, types.ColumnTypeString,
+		types.ColumnTypeString, types.ColumnTypeString}
+	mo, err := createMatchRecognizeOperatorWithSchema(t,
+		`my_stream := (match_recognize by user_id define status == "failed" as fail, status == "ok" && ip != fail.ip as ok pattern="fail{3} ok" within=5m)`,
+		colNames, colTypes)
+	require.NoError(t, err)
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "u1", "failed", "10.0.0.1"},
+		{int64(1), types.NewTimestamp(1001), "u2", "failed", "10.0.0.2"},
+		{int64(2), types.NewTimestamp(2000), "u1", "failed", "10.0.0.1"},
+		{int64(3), types.NewTimestamp(2001), "u2", "failed", "10.0.0.2"},
+		{int64(4), types.NewTimestamp(3000), "u1", "failed", "10.0.0.1"},
+		{int64(5), types.NewTimestamp(3001), "u2", "failed", "10.0.0.2"},
+		// u1 succeeds from the same IP, so there's no match
+		{int64(6), types.NewTimestamp(4000), "u1", "ok", "10.0.0.1"},
+		// u2 succeeds from a new IP
+		{int64(7), types.NewTimestamp(4001), "u2", "ok", "192.168.0.7"},
+	}
+	expectedOut := [][]any{
+		{types.NewTimestamp(4001), "u2", types.NewTimestamp(1001), int64(3), "u2", "failed", "10.0.0.2", int64(1),
+			"u2", "ok", "192.168.0.7"},
+	}
+	batch := createEventBatch(colNames, colTypes, dataIn)
+	out, err := mo.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	require.Equal(t, expectedOut, convertBatchToAnyArray(out))
+}
+
+func TestMatchRecognizeReferToUnknownColumn(t *testing.T) {
+	tsl := `my_stream := (match_recognize by user_id define status == "failed" as fail, status == fail.foo as ok pattern="fail ok" within=5m)`
+	_, err := createMatchRecognizeOperatorReturnErr(t, tsl)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "unknown column 'fail.foo'"))
+}
+
+func TestMatchRecognizeInvalidDefine(t *testing.T) {
+	tsl := `my_stream := (match_recognize by user_id define status == "failed" as foo pattern="fail ok" within=5m)`
+	_, err := createMatchRecognizeOperatorReturnErr(t, tsl)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "variable 'foo' is not used in the pattern"))
+
+	tsl = `my_stream := (match_recognize by user_id define status as fail pattern="fail ok" within=5m)`
+	_, err = createMatchRecognizeOperatorReturnErr(t, tsl)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "definition of variable 'fail' must be a boolean expression"))
+}
+
+func createMatchRecognizeOperator(t *testing.T, tsl string) *MatchRecognizeOperator {
+	mo, err := createMatchRecognizeOperatorReturnErr(t, tsl)
+	require.NoError(t, err)
+	return mo
+}
+
+func createMatchRecognizeOperatorReturnErr(t *testing.T, tsl string) (*MatchRecognizeOperator, error) {
+	return createMatchRecognizeOperatorWithSchema(t, tsl, matchColNames, matchColTypes)
+}
+
+func createMatchRecognizeOperatorWithSchema(t *testing.T, tsl string, colNames []string,
+	colTypes []types.ColumnType) (*MatchRecognizeOperator, error) {
+	ast, err := parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	desc := ast.CreateStream.OperatorDescs[0].(*parser.MatchRecognizeDesc)
+	inSchema := evbatch.NewEventSchema(colNames, colTypes)
+	return NewMatchRecognizeOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		desc, 1001, *desc.Within, &expr.ExpressionFactory{}, -1)
+}
+
+func testMatchRecognize(t *testing.T, mo *MatchRecognizeOperator, ctx *testExecCtx, dataIn [][]any, expectedOut [][]any) {
+	batch := createEventBatch(matchColNames, matchColTypes, dataIn)
+	out, err := mo.HandleStreamBatch(batch, ctx)
+	require.NoError(t, err)
+	require.Equal(t, expectedOut, convertBatchToAnyArray(out))
+}
@@ -0,0 +1,233 @@
+package opers
+
+import (
+	"github.com/spirit-labs/tektite/asl/arista"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/types"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// OperatorMetrics holds the runtime counters of an operator on this node. Time and state accesses are recorded
+// inclusive of the downstream operators called by the operator, the downstream part is also recorded so the
+// operator's own share can be calculated.
+type OperatorMetrics struct {
+	rowsIn                atomic.Uint64
+	rowsOut               atomic.Uint64
+	batches               atomic.Uint64
+	processingNanos       atomic.Uint64
+	downstreamNanos       atomic.Uint64
+	stateReads            atomic.Uint64
+	stateWrites           atomic.Uint64
+	downstreamStateReads  atomic.Uint64
+	downstreamStateWrites atomic.Uint64
+	// Rows which failed to be processed, by the error policy applied to them
+	failedRows       atomic.Uint64
+	skippedRows      atomic.Uint64
+	deadLetteredRows atomic.Uint64
+}
+
+// metricsProvider is implemented by operators, and the receivers of operators, which record metrics
+type metricsProvider interface {
+	getMetrics() *OperatorMetrics
+}
+
+// stateAccessCounter is implemented by exec contexts which count the reads and writes of state made when handling
+// a batch
+type stateAccessCounter interface {
+	stateAccessCounts() (int, int)
+}
+
+func stateAccessCounts(execCtx StreamExecContext) (uint64, uint64) {
+	counter, ok := execCtx.(stateAccessCounter)
+	if !ok {
+		return 0, 0
+	}
+	reads, writes := counter.stateAccessCounts()
+	return uint64(reads), uint64(writes)
+}
+
+// measureBatch calls handle, which passes the batch to the operator with the target metrics, and records the time
+// taken and state accessed against the target, and as downstream against the caller, if there is one. The rows are
+// counted as input to the target if countRows is true.
+func measureBatch(target *OperatorMetrics, caller *OperatorMetrics, batch *evbatch.Batch, countRows bool,
+	execCtx StreamExecContext, handle func() error) error {
+	if countRows {
+		target.batches.Add(1)
+		if batch != nil {
+			target.rowsIn.Add(uint64(batch.RowCount))
+		}
+	}
+	readsBefore, writesBefore := stateAccessCounts(execCtx)
+	start := arista.NanoTime()
+	err := handle()
+	elapsed := arista.NanoTime() - start
+	readsAfter, writesAfter := stateAccessCounts(execCtx)
+	reads := readsAfter - readsBefore
+	writes := writesAfter - writesBefore
+	target.processingNanos.Add(elapsed)
+	target.stateReads.Add(reads)
+	target.stateWrites.Add(writes)
+	if caller != nil {
+		caller.downstreamNanos.Add(elapsed)
+		caller.downstreamStateReads.Add(reads)
+		caller.downstreamStateWrites.Add(writes)
+	}
+	return err
+}
+
+func subtractCounts(total uint64, downstream uint64) uint64 {
+	// The counters are read independently so the downstream count can be momentarily ahead
+	if downstream > total {
+		return 0
+	}
+	return total - downstream
+}
+
+// OperatorStats are the metrics of a deployed operator on this node
+type OperatorStats struct {
+	ID       int
+	Operator string
+	RowsIn   uint64
+	RowsOut  uint64
+	Batches  uint64
+	// ProcessingTime is the total time spent in the operator, not including the time spent in downstream operators
+	ProcessingTime time.Duration
+	StateReads     uint64
+	StateWrites    uint64
+	// WaterMarkLag is how far the watermark is behind the current time, or -1 if the operator doesn't generate
+	// watermarks or hasn't generated one yet
+	WaterMarkLag time.Duration
+	// FailedRows, SkippedRows and DeadLetteredRows are the rows which the operator failed to process, by the error
+	// policy of the stream
+	FailedRows       uint64
+	SkippedRows      uint64
+	DeadLetteredRows uint64
+}
+
+// AvgBatchLatency returns the average time spent in the operator per batch
+func (o *OperatorStats) AvgBatchLatency() time.Duration {
+	if o.Batches == 0 {
+		return 0
+	}
+	return o.ProcessingTime / time.Duration(o.Batches)
+}
+
+// StreamStats are the metrics of a deployed stream on this node
+type StreamStats struct {
+	StreamName string
+	// Backlog is the number of messages, or for kafka in the number of produced batches, that the source of the
+	// stream has not processed yet, or -1 if the stream doesn't have a kafka in or bridge from source
+	Backlog   int64
+	Operators []*OperatorStats
+}
+
+type backlogProvider interface {
+	backlog() int64
+}
+
+func (sm *streamManager) GetStreamStats(streamName string) (*StreamStats, bool) {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	info, ok := sm.streams[streamName]
+	if !ok || info.SystemStream {
+		return nil, false
+	}
+	return createStreamStats(info), true
+}
+
+// GetAllStreamStats returns the stats of all the user streams, ordered by stream name
+func (sm *streamManager) GetAllStreamStats() []*StreamStats {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	var allStats []*StreamStats
+	for _, info := range sm.streams {
+		if info.SystemStream {
+			continue
+		}
+		allStats = append(allStats, createStreamStats(info))
+	}
+	sort.Slice(allStats, func(i, j int) bool {
+		return allStats[i].StreamName < allStats[j].StreamName
+	})
+	return allStats
+}
+
+func createStreamStats(info *StreamInfo) *StreamStats {
+	stats := &StreamStats{
+		StreamName: info.StreamDesc.StreamName,
+		Backlog:    -1,
+	}
+	for i, oper := range info.Operators {
+		operStats := &OperatorStats{
+			ID:           i,
+			WaterMarkLag: -1,
+		}
+		if i < len(info.StreamDesc.OperatorDescs) {
+			operStats.Operator = OperatorName(info.StreamDesc.OperatorDescs[i])
+		} else {
+			operStats.Operator = "test sink"
+		}
+		if provider, ok := oper.(metricsProvider); ok {
+			metrics := provider.getMetrics()
+			operStats.RowsIn = metrics.rowsIn.Load()
+			operStats.RowsOut = metrics.rowsOut.Load()
+			operStats.Batches = metrics.batches.Load()
+			operStats.ProcessingTime = time.Duration(subtractCounts(metrics.processingNanos.Load(),
+				metrics.downstreamNanos.Load()))
+			operStats.StateReads = subtractCounts(metrics.stateReads.Load(), metrics.downstreamStateReads.Load())
+			operStats.StateWrites = subtractCounts(metrics.stateWrites.Load(), metrics.downstreamStateWrites.Load())
+			operStats.FailedRows = metrics.failedRows.Load()
+			operStats.SkippedRows = metrics.skippedRows.Load()
+			operStats.DeadLetteredRows = metrics.deadLetteredRows.Load()
+		}
+		if wmProvider, ok := oper.(interface{ GetWatermarkOperator() *WaterMarkOperator }); ok {
+			if wmOper := wmProvider.GetWatermarkOperator(); wmOper != nil {
+				operStats.WaterMarkLag = wmOper.waterMarkLag()
+			}
+		}
+		if blProvider, ok := oper.(backlogProvider); ok {
+			stats.Backlog = blProvider.backlog()
+		}
+		stats.Operators = append(stats.Operators, operStats)
+	}
+	return stats
+}
+
+var StreamStatsSchema = evbatch.NewEventSchema([]string{"stream_name", "id", "operator", "rows_in", "rows_out",
+	"batches", "avg_batch_latency", "processing_time", "state_reads", "state_writes", "watermark_lag", "backlog"},
+	[]types.ColumnType{types.ColumnTypeString, types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeInt,
+		types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeString, types.ColumnTypeInt,
+		types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeInt})
+
+// StreamStatsBatch converts the stats to a batch with StreamStatsSchema, with one row per operator. The backlog is
+// only set on the first operator of each stream.
+func StreamStatsBatch(allStats []*StreamStats) *evbatch.Batch {
+	colBuilders := evbatch.CreateColBuilders(StreamStatsSchema.ColumnTypes())
+	for _, stats := range allStats {
+		for _, op := range stats.Operators {
+			colBuilders[0].(*evbatch.StringColBuilder).Append(stats.StreamName)
+			colBuilders[1].(*evbatch.IntColBuilder).Append(int64(op.ID))
+			colBuilders[2].(*evbatch.StringColBuilder).Append(op.Operator)
+			colBuilders[3].(*evbatch.IntColBuilder).Append(int64(op.RowsIn))
+			colBuilders[4].(*evbatch.IntColBuilder).Append(int64(op.RowsOut))
+			colBuilders[5].(*evbatch.IntColBuilder).Append(int64(op.Batches))
+			colBuilders[6].(*evbatch.StringColBuilder).Append(op.AvgBatchLatency().String())
+			colBuilders[7].(*evbatch.StringColBuilder).Append(op.ProcessingTime.String())
+			colBuilders[8].(*evbatch.IntColBuilder).Append(int64(op.StateReads))
+			colBuilders[9].(*evbatch.IntColBuilder).Append(int64(op.StateWrites))
+			if op.WaterMarkLag == -1 {
+				colBuilders[10].AppendNull()
+			} else {
+				colBuilders[10].(*evbatch.StringColBuilder).Append(op.WaterMarkLag.String())
+			}
+			if op.ID != 0 || stats.Backlog == -1 {
+				colBuilders[11].AppendNull()
+			} else {
+				colBuilders[11].(*evbatch.IntColBuilder).Append(stats.Backlog)
+			}
+		}
+	}
+	return evbatch.NewBatchFromBuilders(StreamStatsSchema, colBuilders...)
+}
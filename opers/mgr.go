@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"github.com/alecthomas/participle/v2/lexer"
 	"github.com/emirpasic/gods/maps/treemap"
+	"github.com/pkg/errors"
 	"github.com/spirit-labs/tektite/asl/conf"
 	"github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/cdc"
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/debug"
 	"github.com/spirit-labs/tektite/evbatch"
@@ -15,8 +17,10 @@ import (
 	"github.com/spirit-labs/tektite/kafka"
 	log "github.com/spirit-labs/tektite/logger"
 	"github.com/spirit-labs/tektite/mem"
+	"github.com/spirit-labs/tektite/objstore"
 	"github.com/spirit-labs/tektite/parser"
 	"github.com/spirit-labs/tektite/proc"
+	"github.com/spirit-labs/tektite/schemareg"
 	"github.com/spirit-labs/tektite/types"
 	"math"
 	"reflect"
@@ -40,6 +44,8 @@ type StreamManager interface {
 	DeployStream(streamDesc parser.CreateStreamDesc, receiverSequences []int, slabSequences []int, tsl string,
 		commandID int64) error
 	UndeployStream(deleteStremDesc parser.DeleteStreamDesc, commandID int64) error
+	AlterStream(streamDesc parser.CreateStreamDesc, receiverSequences []int, slabSequences []int, tsl string,
+		commandID int64) error
 	GetStream(name string) *StreamInfo
 	GetAllStreams() []*StreamInfo
 	GetKafkaEndpoint(name string) *KafkaEndpointInfo
@@ -48,6 +54,8 @@ type StreamManager interface {
 		schema *OperatorSchema, keyCols []string) error
 	SetProcessorManager(procMgr ProcessorManager)
 	GetIngestedMessageCount() int
+	GetStreamStats(streamName string) (*StreamStats, bool)
+	GetAllStreamStats() []*StreamStats
 	PrepareForShutdown()
 	StreamCount() int
 	Start() error
@@ -59,6 +67,12 @@ type StreamManager interface {
 	StreamMetaIteratorProvider() *StreamMetaIteratorProvider
 	Dump()
 	RegisterReceiverWithLock(id int, receiver Receiver)
+	ExplainStream(streamName string) ([]*OperatorPlan, bool)
+	SavepointStream(savepoint parser.SavepointDesc, version int) error
+	LoadSavepoint(streamDesc parser.CreateStreamDesc, receiverSequences []int, slabSequences []int) (*LoadedStream, error)
+	DeployLoadedStream(stream *LoadedStream, tsl string, commandID int64) error
+	DiscardLoadedStream(stream *LoadedStream)
+	PinSchemaVersions(streamDesc *parser.CreateStreamDesc, command string) (string, error)
 }
 
 type Receiver interface {
@@ -111,9 +125,10 @@ const SlabTypeUserTable = SlabType(1)
 const SlabTypeQueryableInternal = SlabType(2)
 const SlabTypeInternal = SlabType(3)
 
-func NewStreamManager(messageClientFactory kafka.ClientFactory,
+func NewStreamManager(messageClientFactory kafka.ClientFactory, objStoreClient objstore.Client,
 	slabRetentions slabRetentions, expressionFactory *expr.ExpressionFactory, cfg *conf.Config, disableIngest bool) StreamManager {
 	mgr := &streamManager{
+		objStoreClient:         objStoreClient,
 		slabRetentions:         slabRetentions,
 		expressionFactory:      expressionFactory,
 		messageClientFactory:   messageClientFactory,
@@ -125,6 +140,8 @@ func NewStreamManager(messageClientFactory kafka.ClientFactory,
 		requiredCompletions:    -1,
 		lastProcessedCommandID: -1,
 		bridgeFromOpers:        map[*BridgeFromOperator]struct{}{},
+		cdcFromOpers:           map[*CdcFromOperator]struct{}{},
+		cdcClientFactory:       cdc.NewPostgresClient,
 		partitionOperators:     map[*PartitionOperator]struct{}{},
 		lastFlushedVersion:     -1,
 		streamMemStore:         treemap.NewWithStringComparator(),
@@ -137,6 +154,17 @@ func NewStreamManager(messageClientFactory kafka.ClientFactory,
 		},
 		sm: mgr,
 	}
+	mgr.receivers[common.SavepointReceiverID] = &savepointReceiver{
+		schema: &OperatorSchema{
+			EventSchema: savepointSchema,
+		},
+		sm: mgr,
+	}
+	mgr.receivers[common.LoadSavepointReceiverID] = &loadSavepointReceiver{
+		schema: &OperatorSchema{
+			EventSchema: loadSavepointSchema,
+		},
+	}
 	mgr.calculateInjectableReceivers()
 	return mgr
 }
@@ -152,6 +180,7 @@ type streamManager struct {
 	requiredCompletions    int
 	processorManager       ProcessorManager
 	messageClientFactory   kafka.ClientFactory
+	objStoreClient         objstore.Client
 	cfg                    *conf.Config
 	disableIngest          bool
 	ingestedMessageCount   uint64
@@ -161,6 +190,8 @@ type streamManager struct {
 	loaded                 bool
 	changeListeners        []func(string, bool)
 	bridgeFromOpers        map[*BridgeFromOperator]struct{}
+	cdcFromOpers           map[*CdcFromOperator]struct{}
+	cdcClientFactory       cdc.ClientFactory
 	partitionOperators     map[*PartitionOperator]struct{}
 	ingestEnabled          atomic.Bool
 	lastFlushedVersion     int64
@@ -168,6 +199,8 @@ type streamManager struct {
 	streamMemStore         *treemap.Map
 	streamMetaIterProvider *StreamMetaIteratorProvider
 	lastCommandID          int64
+	schemaRegistryLock     sync.Mutex
+	schemaRegistry         schemareg.Registry
 }
 
 func (sm *streamManager) GetIngestedMessageCount() int {
@@ -186,8 +219,18 @@ type StreamInfo struct {
 	OutSchema             *OperatorSchema
 	SystemStream          bool
 	CommandID             int64
-	Undeploying           bool
-	StreamMeta            bool
+	// ReplacedCommandIDs are the ids of the commands which created or altered the stream before the current one
+	ReplacedCommandIDs []int64
+	Undeploying        bool
+	StreamMeta         bool
+	operatorSequences  []operatorSequences
+	slabRetentions     []slabRetention
+}
+
+// operatorSequences are the receiver and slab ids used by an operator
+type operatorSequences struct {
+	receiverIDs []int
+	slabIDs     []int
 }
 
 type KafkaEndpointInfo struct {
@@ -217,6 +260,10 @@ func validateStream(streamDesc *parser.CreateStreamDesc) error {
 			if i != 0 {
 				return statementErrorAtTokenNamef("", o, "'bridge from' must be the first operator in a stream")
 			}
+		case *parser.CdcFromDesc:
+			if i != 0 {
+				return statementErrorAtTokenNamef("", o, "'cdc from' must be the first operator in a stream")
+			}
 		case *parser.BridgeToDesc:
 			if i == 0 {
 				return statementErrorAtTokenNamef("", o, "'bridge to' cannot be the first operator in a stream")
@@ -224,6 +271,13 @@ func validateStream(streamDesc *parser.CreateStreamDesc) error {
 			if i != lastIndex {
 				return statementErrorAtTokenNamef("", o, "'bridge to' must be the last operator in a stream")
 			}
+		case *parser.HttpSinkDesc:
+			if i == 0 {
+				return statementErrorAtTokenNamef("", o, "'http_sink' cannot be the first operator in a stream")
+			}
+			if i != lastIndex {
+				return statementErrorAtTokenNamef("", o, "'http_sink' must be the last operator in a stream")
+			}
 		case *parser.ContinuationDesc:
 			if i != 0 {
 				return statementErrorAtTokenNamef("", o, "continuation (->) must be at the start of a child stream")
@@ -232,6 +286,37 @@ func validateStream(streamDesc *parser.CreateStreamDesc) error {
 			if i == 0 {
 				return statementErrorAtTokenNamef("", o, "'filter' cannot be the first operator in a stream")
 			}
+		case *parser.DecodeDesc:
+			if i == 0 {
+				return statementErrorAtTokenNamef("", o, "'decode' cannot be the first operator in a stream")
+			}
+		case *parser.DedupDesc:
+			if i == 0 {
+				return statementErrorAtTokenNamef("", o, "'dedup' cannot be the first operator in a stream")
+			}
+		case *parser.EncodeDesc:
+			if i == 0 {
+				return statementErrorAtTokenNamef("", o, "'encode' cannot be the first operator in a stream")
+			}
+		case *parser.ExplodeDesc:
+			if i == 0 {
+				return statementErrorAtTokenNamef("", o, "'explode' cannot be the first operator in a stream")
+			}
+		case *parser.MatchRecognizeDesc:
+			if i == 0 {
+				return statementErrorAtTokenNamef("", o, "'match_recognize' cannot be the first operator in a stream")
+			}
+		case *parser.TopNDesc:
+			if i == 0 {
+				return statementErrorAtTokenNamef("", o, "'topn' cannot be the first operator in a stream")
+			}
+			store := true
+			if o.Store != nil {
+				store = *o.Store
+			}
+			if store && i != lastIndex {
+				return statementErrorAtTokenNamef("", o, "'topn' with 'store' = true must be the last operator in a stream")
+			}
 		case *parser.JoinDesc:
 			if i != 0 {
 				return statementErrorAtTokenNamef("", o, "'join' must be the first operator in a stream")
@@ -325,18 +410,28 @@ func (sm *streamManager) callChangeListeners(streamName string, deployed bool) {
 
 func (sm *streamManager) DeployStream(streamDesc parser.CreateStreamDesc, receiverSequences []int,
 	slabSequences []int, tsl string, commandID int64) error {
+	streamDesc, err := sm.prepareNewStream(streamDesc)
+	if err != nil {
+		return err
+	}
+	return sm.deployStream(streamDesc, receiverSequences, slabSequences, tsl, commandID)
+}
+
+// prepareNewStream checks that a stream can be created with the definition, and returns the definition to deploy.
+func (sm *streamManager) prepareNewStream(streamDesc parser.CreateStreamDesc) (parser.CreateStreamDesc, error) {
 	if isReservedIdentifierName(streamDesc.StreamName) {
-		return statementErrorAtTokenNamef(streamDesc.StreamName, &streamDesc, "stream name '%s' is a reserved name", streamDesc.StreamName)
+		return parser.CreateStreamDesc{}, statementErrorAtTokenNamef(streamDesc.StreamName, &streamDesc,
+			"stream name '%s' is a reserved name", streamDesc.StreamName)
 	}
 	_, exists := sm.streams[streamDesc.StreamName]
 	if exists {
-		return statementErrorAtTokenNamef(streamDesc.StreamName, &streamDesc, "stream '%s' already exists", streamDesc.StreamName)
+		return parser.CreateStreamDesc{}, statementErrorAtTokenNamef(streamDesc.StreamName, &streamDesc,
+			"stream '%s' already exists", streamDesc.StreamName)
 	}
 	if err := validateStream(&streamDesc); err != nil {
-		return err
+		return parser.CreateStreamDesc{}, err
 	}
-	streamDesc = sm.maybeRewriteDesc(streamDesc)
-	return sm.deployStream(streamDesc, receiverSequences, slabSequences, tsl, commandID)
+	return sm.maybeRewriteDesc(streamDesc), nil
 }
 
 func (sm *streamManager) maybeRewriteDesc(streamDesc parser.CreateStreamDesc) parser.CreateStreamDesc {
@@ -374,16 +469,108 @@ func (sm *streamManager) deployStream(streamDesc parser.CreateStreamDesc, receiv
 	}
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
+	info, err := sm.deployStreamOperators(streamDesc, receiverSequences, slabSequences, nil, tsl, commandID)
+	if err != nil {
+		return err
+	}
+	sm.streamDeployed(info, commandID)
+	return nil
+}
+
+// deployStreamOperators creates the operators of a stream and wires them into the graph. Operators are given receiver
+// and slab ids from the provided sequences, except those with an entry in reusedSequences, which keep the ids they had
+// in a previous definition of the stream.
+func (sm *streamManager) deployStreamOperators(streamDesc parser.CreateStreamDesc, receiverSequences []int,
+	slabSequences []int, reusedSequences map[int]operatorSequences, tsl string, commandID int64) (*StreamInfo, error) {
+	info, kafkaEndpointInfo, deferredWirings, err := sm.buildStreamOperators(streamDesc, receiverSequences,
+		slabSequences, reusedSequences, tsl, commandID)
+	if err != nil {
+		return nil, err
+	}
+	if err := sm.activateStreamOperators(info, kafkaEndpointInfo, deferredWirings); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// activateStreamOperators wires the operators of a stream which has been built into the graph.
+func (sm *streamManager) activateStreamOperators(info *StreamInfo, kafkaEndpointInfo *KafkaEndpointInfo,
+	deferredWirings []func(info *StreamInfo)) error {
+	streamDesc := info.StreamDesc
+	operators := info.Operators
+	for i, oper := range operators {
+		if sm.loaded {
+			// Must call setup before adding operator to previous operator or it could handle a batch before
+			// receivers are registered and error out (e.g. in the backfill operator in bridge to)
+			if err := oper.Setup(sm); err != nil {
+				return err
+			}
+		}
+		if i != len(operators)-1 {
+			nextOper := operators[i+1]
+			oper.AddDownStreamOperator(nextOper)
+		}
+		switch op := oper.(type) {
+		case *BridgeFromOperator:
+			sm.bridgeFromOpers[op] = struct{}{}
+		case *CdcFromOperator:
+			sm.cdcFromOpers[op] = struct{}{}
+		case *PartitionOperator:
+			sm.partitionOperators[op] = struct{}{}
+		}
+	}
+	sm.streams[streamDesc.StreamName] = info
+	if kafkaEndpointInfo != nil {
+		sm.kafkaEndpoints[streamDesc.StreamName] = kafkaEndpointInfo
+	}
+	sm.invalidateCachedInfo()
+	for _, retention := range info.slabRetentions {
+		if retention.Retention != 0 {
+			if err := sm.slabRetentions.RegisterSlabRetention(retention.slabID, retention.Retention); err != nil {
+				if !sm.loaded {
+					// When reprocessing command log on restart these can fail as processor not started yet, that's
+					// ok, they should already be registered
+					log.Debugf("failed to register slab retentions (not loaded): %v", err)
+				} else {
+					log.Warnf("failed to register slab retentions: %v", err)
+				}
+			}
+		}
+	}
+	for _, deferred := range deferredWirings {
+		deferred(info)
+	}
+	return nil
+}
+
+// buildStreamOperators creates the operators of a stream without making any changes to the stream manager, so the
+// stream can be discarded if it is not deployed. It returns the wirings to other streams which must be made when the
+// stream is deployed.
+func (sm *streamManager) buildStreamOperators(streamDesc parser.CreateStreamDesc, receiverSequences []int,
+	slabSequences []int, reusedSequences map[int]operatorSequences, tsl string,
+	commandID int64) (*StreamInfo, *KafkaEndpointInfo, []func(info *StreamInfo), error) {
 	var operators []Operator
 	var prevOperator Operator
 	var kafkaEndpointInfo *KafkaEndpointInfo
 	var retentions []slabRetention
 	var deferredWirings []func(info *StreamInfo)
-	receiverSliceSeqs := &sliceSeq{seqs: receiverSequences}
-	slabSliceSeqs := &sliceSeq{seqs: slabSequences}
+	var operSequences []operatorSequences
+	nextReceiverSeqs := &sliceSeq{seqs: receiverSequences}
+	nextSlabSeqs := &sliceSeq{seqs: slabSequences}
 	extraSlabInfos := map[string]*SlabInfo{}
 	var userSlab *SlabInfo
-	for _, desc := range streamDesc.OperatorDescs {
+	deadLetter, err := sm.resolveDeadLetterTarget(&streamDesc)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for i, desc := range streamDesc.OperatorDescs {
+		receiverSliceSeqs, slabSliceSeqs := nextReceiverSeqs, nextSlabSeqs
+		reused, ok := reusedSequences[i]
+		if ok {
+			receiverSliceSeqs = &sliceSeq{seqs: reused.receiverIDs}
+			slabSliceSeqs = &sliceSeq{seqs: reused.slabIDs}
+		}
+		receiverStart, slabStart := receiverSliceSeqs.pos, slabSliceSeqs.pos
 		var oper Operator
 		var err error
 		switch op := desc.(type) {
@@ -398,19 +585,34 @@ func (sm *streamManager) deployStream(streamDesc parser.CreateStreamDesc, receiv
 			}
 		case *parser.BridgeFromDesc:
 			oper, err = sm.deployBridgeFromOperator(streamDesc.StreamName, op, receiverSliceSeqs, slabSliceSeqs, extraSlabInfos)
+		case *parser.CdcFromDesc:
+			oper, err = sm.deployCdcFromOperator(streamDesc.StreamName, op, receiverSliceSeqs, slabSliceSeqs, extraSlabInfos)
 		case *parser.BridgeToDesc:
 			oper, retentions, userSlab, err = sm.deployBridgeToOperator(streamDesc.StreamName, op, prevOperator, receiverSliceSeqs,
 				slabSliceSeqs, extraSlabInfos, retentions)
+		case *parser.HttpSinkDesc:
+			oper, retentions, userSlab, err = sm.deployHttpSinkOperator(streamDesc.StreamName, op, prevOperator, receiverSliceSeqs,
+				slabSliceSeqs, extraSlabInfos, retentions)
 		case *parser.KafkaInDesc:
 			oper, kafkaEndpointInfo, err = sm.deployKafkaInOperator(streamDesc.StreamName, op, receiverSliceSeqs,
 				slabSliceSeqs, extraSlabInfos)
 		case *parser.KafkaOutDesc:
-			oper, retentions, userSlab, err = sm.deployKafkaOutOperator(streamDesc.StreamName, op,
+			oper, kafkaEndpointInfo, retentions, userSlab, err = sm.deployKafkaOutOperator(streamDesc.StreamName, op,
 				prevOperator, kafkaEndpointInfo, slabSliceSeqs, extraSlabInfos, retentions)
-		case *parser.FilterDesc:
-			oper, err = NewFilterOperator(prevOperator.OutSchema(), op.Expr, sm.expressionFactory)
-		case *parser.ProjectDesc:
-			oper, err = NewProjectOperator(prevOperator.OutSchema(), op.Expressions, true, sm.expressionFactory)
+		case *parser.FilterDesc, *parser.DecodeDesc, *parser.EncodeDesc, *parser.ExplodeDesc, *parser.ProjectDesc:
+			oper, err = sm.newStatelessOperator(desc, prevOperator.OutSchema())
+			if err == nil {
+				setRowErrorHandler(&streamDesc, desc, oper, deadLetter)
+			}
+		case *parser.DedupDesc:
+			oper, retentions, err = sm.deployDedupOperator(streamDesc.StreamName, op, prevOperator, slabSliceSeqs,
+				extraSlabInfos, retentions)
+		case *parser.MatchRecognizeDesc:
+			oper, retentions, err = sm.deployMatchRecognizeOperator(streamDesc.StreamName, op, prevOperator,
+				slabSliceSeqs, extraSlabInfos, retentions)
+		case *parser.TopNDesc:
+			oper, userSlab, err = sm.deployTopNOperator(streamDesc.StreamName, op, prevOperator, slabSliceSeqs,
+				extraSlabInfos)
 		case *parser.PartitionDesc:
 			oper, err = sm.deployPartitionOperator(op, prevOperator, receiverSliceSeqs)
 		case *parser.AggregateDesc:
@@ -434,7 +636,7 @@ func (sm *streamManager) deployStream(streamDesc parser.CreateStreamDesc, receiv
 		case *parser.ContinuationDesc:
 			upstreamStream, ok := sm.streams[op.ParentStreamName]
 			if !ok {
-				return statementErrorAtTokenNamef(op.ParentStreamName, op, "unknown parent stream '%s'",
+				return nil, nil, nil, statementErrorAtTokenNamef(op.ParentStreamName, op, "unknown parent stream '%s'",
 					op.ParentStreamName)
 			}
 			upstreamLastOper := upstreamStream.Operators[len(upstreamStream.Operators)-1]
@@ -458,8 +660,12 @@ func (sm *streamManager) deployStream(streamDesc parser.CreateStreamDesc, receiv
 			panic("unexpected operator")
 		}
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
+		operSequences = append(operSequences, operatorSequences{
+			receiverIDs: receiverSliceSeqs.seqs[receiverStart:receiverSliceSeqs.pos],
+			slabIDs:     slabSliceSeqs.seqs[slabStart:slabSliceSeqs.pos],
+		})
 		operators = append(operators, oper)
 		if prevOperator != nil {
 			oper.SetParentOperator(prevOperator)
@@ -482,52 +688,30 @@ func (sm *streamManager) deployStream(streamDesc parser.CreateStreamDesc, receiv
 		InSchema:              operators[0].InSchema(),
 		OutSchema:             operators[len(operators)-1].OutSchema(),
 		CommandID:             commandID,
+		operatorSequences:     operSequences,
+		slabRetentions:        retentions,
 	}
-	for i, oper := range operators {
+	for _, oper := range operators {
 		oper.SetStreamInfo(info)
-		if sm.loaded {
-			// Must call setup before adding operator to previous operator or it could handle a batch before
-			// receivers are registered and error out (e.g. in the backfill operator in bridge to)
-			if err := oper.Setup(sm); err != nil {
-				return err
-			}
-		}
-		if i != len(operators)-1 {
-			nextOper := operators[i+1]
-			oper.AddDownStreamOperator(nextOper)
-		}
 	}
-	sm.streams[streamDesc.StreamName] = info
-	if kafkaEndpointInfo != nil {
-		sm.kafkaEndpoints[streamDesc.StreamName] = kafkaEndpointInfo
-	}
-	sm.invalidateCachedInfo()
-	for _, retention := range retentions {
-		if retention.Retention != 0 {
-			if err := sm.slabRetentions.RegisterSlabRetention(retention.slabID, retention.Retention); err != nil {
-				if !sm.loaded {
-					// When reprocessing command log on restart these can fail as processor not started yet, that's
-					// ok, they should already be registered
-					log.Debugf("failed to register slab retentions (not loaded): %v", err)
-				} else {
-					log.Warnf("failed to register slab retentions: %v", err)
-				}
-			}
-		}
-	}
-	for _, deferred := range deferredWirings {
-		deferred(info)
-	}
-	sm.storeStreamMeta(info)
+	return info, kafkaEndpointInfo, deferredWirings, nil
+}
+
+func (sm *streamManager) streamDeployed(info *StreamInfo, commandID int64) {
 	sm.lastCommandID = commandID
+	sm.streamChanged(info)
+}
+
+// streamChanged is called when a stream has been deployed or redeployed
+func (sm *streamManager) streamChanged(info *StreamInfo) {
+	sm.storeStreamMeta(info)
 	if sm.loaded {
 		sm.calculateInjectableReceivers()
 	}
-	sm.callChangeListeners(streamDesc.StreamName, true)
+	sm.callChangeListeners(info.StreamDesc.StreamName, true)
 	if sm.loaded {
 		sm.processorManager.AfterReceiverChange() // Must be outside of lock
 	}
-	return nil
 }
 
 func (sm *streamManager) deployBridgeFromOperator(streamName string, op *parser.BridgeFromDesc,
@@ -570,10 +754,53 @@ func (sm *streamManager) deployBridgeFromOperator(streamName string, op *parser.
 	}
 	watermarkOperator := NewWaterMarkOperator(bf.InSchema(), wmType, wmLateness, wmIdleTimeout)
 	bf.watermarkOperator = watermarkOperator
-	sm.bridgeFromOpers[bf] = struct{}{}
 	return bf, nil
 }
 
+func (sm *streamManager) deployCdcFromOperator(streamName string, op *parser.CdcFromDesc,
+	receiverSliceSeqs *sliceSeq, slabSliceSeqs *sliceSeq, extraSlabInfos map[string]*SlabInfo) (*CdcFromOperator, error) {
+	receiverID := receiverSliceSeqs.GetNextID()
+	var pollTimeout = defaultPollTimeout
+	if op.PollTimeout != nil {
+		pollTimeout = *op.PollTimeout
+	}
+	var maxPollMessages = defaultMaxMessages
+	if op.MaxPollMessages != nil {
+		maxPollMessages = *op.MaxPollMessages
+	}
+	if maxPollMessages < 1 {
+		return nil, statementErrorAtTokenNamef("max_poll_messages", op, "invalid value for 'max_poll_messages' - must be > 0")
+	}
+	if op.Slot == "" {
+		return nil, statementErrorAtTokenNamef("slot", op, "'slot' must not be empty")
+	}
+	if op.Publication == "" {
+		return nil, statementErrorAtTokenNamef("publication", op, "'publication' must not be empty")
+	}
+	offsetsSlabID := slabSliceSeqs.GetNextID()
+
+	cf, err := NewCdcFromOperator(receiverID, pollTimeout, maxPollMessages, op.Connection, op.Slot, op.Publication,
+		sm.cdcClientFactory, sm.disableIngest, sm.processorManager, offsetsSlabID, sm.cfg, &sm.ingestedMessageCount,
+		getMappingID(), &sm.ingestEnabled, &sm.lastFlushedVersion)
+	if err != nil {
+		return nil, statementErrorAtTokenNamef("connection", op, "invalid 'connection' - %v", err)
+	}
+	extraSlabInfos[fmt.Sprintf("offsets-cdc-from-%s-%d", streamName, offsetsSlabID)] =
+		&SlabInfo{
+			StreamName: streamName,
+			SlabID:     offsetsSlabID,
+			Type:       SlabTypeInternal,
+			Schema:     cf.OutSchema(),
+		}
+	wmType, wmLateness, wmIdleTimeout, err := defaultWatermarkArgs(op.WatermarkType, op.WatermarkLateness,
+		op.WatermarkIdleTimeout, op)
+	if err != nil {
+		return nil, err
+	}
+	cf.watermarkOperator = NewWaterMarkOperator(cf.InSchema(), wmType, wmLateness, wmIdleTimeout)
+	return cf, nil
+}
+
 func defaultWatermarkArgs(wmType *string, wmLateness *time.Duration, wmIdleTimeout *time.Duration, op errMsgAtPositionProvider) (string, time.Duration, time.Duration, error) {
 	wType := "event_time"
 	if wmType != nil {
@@ -602,6 +829,48 @@ func (sm *streamManager) deployBridgeToOperator(streamName string, op *parser.Br
 		return nil, nil, nil, statementErrorAtTokenNamef("", op,
 			"input to 'bridge to' operator must have column types: [key:bytes, hdrs:bytes, val:bytes]")
 	}
+	var ret time.Duration
+	if op.Retention != nil {
+		ret = *op.Retention
+	} else {
+		ret = 24 * time.Hour
+	}
+	sso, bfo, prefixRetentions, userSlabInfo, err := sm.setupStoreAndBackfillOperators(streamName, "bridge-to", ret,
+		prevOperator, receiverSliceSeqs, slabSliceSeqs, extraSlabInfos, prefixRetentions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	bt, err := NewBridgeToOperator(sm.cfg, op, sso, bfo, sm.messageClientFactory)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return bt, prefixRetentions, userSlabInfo, nil
+}
+
+func (sm *streamManager) deployHttpSinkOperator(streamName string, op *parser.HttpSinkDesc, prevOperator Operator,
+	receiverSliceSeqs *sliceSeq, slabSliceSeqs *sliceSeq, extraSlabInfos map[string]*SlabInfo,
+	prefixRetentions []slabRetention) (*HttpSinkOperator, []slabRetention, *SlabInfo, error) {
+	ret := defaultHttpSinkRetention
+	if op.Retention != nil {
+		ret = *op.Retention
+	}
+	sso, bfo, prefixRetentions, userSlabInfo, err := sm.setupStoreAndBackfillOperators(streamName, "http-sink", ret,
+		prevOperator, receiverSliceSeqs, slabSliceSeqs, extraSlabInfos, prefixRetentions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hs, err := NewHttpSinkOperator(sm.cfg, op, sso, bfo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return hs, prefixRetentions, userSlabInfo, nil
+}
+
+// setupStoreAndBackfillOperators creates the operators used by operators which send to an external system - batches
+// are stored by the store stream operator, and sent by the backfill operator from the last committed offset.
+func (sm *streamManager) setupStoreAndBackfillOperators(streamName string, slabPrefix string, retention time.Duration,
+	prevOperator Operator, receiverSliceSeqs *sliceSeq, slabSliceSeqs *sliceSeq, extraSlabInfos map[string]*SlabInfo,
+	prefixRetentions []slabRetention) (*StoreStreamOperator, *BackfillOperator, []slabRetention, *SlabInfo, error) {
 	slabID := slabSliceSeqs.GetNextID()
 
 	offsetsSlabID := -1
@@ -609,7 +878,7 @@ func (sm *streamManager) deployBridgeToOperator(streamName string, op *parser.Br
 	if !hasOffset {
 		// Need to add an offset in the store operator
 		offsetsSlabID = slabSliceSeqs.GetNextID()
-		extraSlabInfos[fmt.Sprintf("bridge-to-offsets-%s-%d", streamName, offsetsSlabID)] =
+		extraSlabInfos[fmt.Sprintf("%s-offsets-%s-%d", slabPrefix, streamName, offsetsSlabID)] =
 			&SlabInfo{
 				StreamName: streamName,
 				SlabID:     offsetsSlabID,
@@ -617,24 +886,18 @@ func (sm *streamManager) deployBridgeToOperator(streamName string, op *parser.Br
 				Schema:     prevOperator.OutSchema(),
 			}
 	}
-	var ret time.Duration
-	if op.Retention != nil {
-		ret = *op.Retention
-	} else {
-		ret = 24 * time.Hour
-	}
-	sso, prefixRetentions, userSlabInfo, err := sm.setupStoreStreamOperator(streamName, ret, prevOperator.OutSchema(),
+	sso, prefixRetentions, userSlabInfo, err := sm.setupStoreStreamOperator(streamName, retention, prevOperator.OutSchema(),
 		slabID, offsetsSlabID, prefixRetentions)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	prefixRetentions = append(prefixRetentions, slabRetention{
 		slabID:    slabID,
-		Retention: ret,
+		Retention: retention,
 	})
 	backfillReceiverID := receiverSliceSeqs.GetNextID()
 	backfillOffsetsSlabID := slabSliceSeqs.GetNextID()
-	extraSlabInfos[fmt.Sprintf("bridge-to-back-fill-offsets-%s-%d", streamName, offsetsSlabID)] =
+	extraSlabInfos[fmt.Sprintf("%s-back-fill-offsets-%s-%d", slabPrefix, streamName, offsetsSlabID)] =
 		&SlabInfo{
 			StreamName: streamName,
 			SlabID:     backfillOffsetsSlabID,
@@ -642,11 +905,7 @@ func (sm *streamManager) deployBridgeToOperator(streamName string, op *parser.Br
 			Schema:     prevOperator.OutSchema(),
 		}
 	bfo := NewBackfillOperator(sso.OutSchema(), sm.cfg, slabID, backfillOffsetsSlabID, sm.cfg.MaxBackfillBatchSize, backfillReceiverID, true)
-	bt, err := NewBridgeToOperator(sm.cfg, op, sso, bfo, sm.messageClientFactory)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-	return bt, prefixRetentions, userSlabInfo, nil
+	return sso, bfo, prefixRetentions, userSlabInfo, nil
 }
 
 // We currently use the same constant mapping id for bridge from and kafka in / topic operators and for partitions (unless
@@ -748,10 +1007,11 @@ func verifyKafkaSchema(eventSchema *evbatch.EventSchema) bool {
 
 func (sm *streamManager) deployKafkaOutOperator(streamName string, op *parser.KafkaOutDesc,
 	prevOperator Operator, kafkaEndpointInfo *KafkaEndpointInfo, slabSliceSeqs *sliceSeq,
-	extraSlabInfos map[string]*SlabInfo, prefixRetentions []slabRetention) (Operator, []slabRetention, *SlabInfo, error) {
+	extraSlabInfos map[string]*SlabInfo, prefixRetentions []slabRetention) (Operator, *KafkaEndpointInfo, []slabRetention,
+	*SlabInfo, error) {
 
 	if !verifyKafkaSchema(prevOperator.OutSchema().EventSchema) {
-		return nil, nil, nil, statementErrorAtTokenNamef("", op,
+		return nil, nil, nil, nil, statementErrorAtTokenNamef("", op,
 			"input to 'kafka out' operator must have column types: [key:bytes, hdrs:bytes, val:bytes]")
 	}
 
@@ -779,7 +1039,7 @@ func (sm *streamManager) deployKafkaOutOperator(streamName string, op *parser.Ka
 		var err error
 		offsetsSlabID, err = sm.findOffsetsSlabID(prevOperator)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 
 		if offsetsSlabID == -1 {
@@ -806,9 +1066,8 @@ func (sm *streamManager) deployKafkaOutOperator(streamName string, op *parser.Ka
 	kafkaOutOper, err := NewKafkaOutOperator(storeStreamOperator, slabID, offsetsSlabID, prevOperator.OutSchema(),
 		sm.processorManager, storeOffset)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
-	updatedExisting := false
 	if kafkaEndpointInfo != nil {
 		// We have a kafka in on the same stream - make partition mapping and number of partitions are the same
 		// otherwise location of producer side partition and consumer side partition could be different - and that's
@@ -816,19 +1075,17 @@ func (sm *streamManager) deployKafkaOutOperator(streamName string, op *parser.Ka
 		s1 := prevOperator.OutSchema()
 		s2 := kafkaEndpointInfo.InEndpoint.outSchema
 		if s1.Partitions != s2.Partitions || s1.MappingID != s2.MappingID {
-			return nil, nil, nil, statementErrorAtTokenNamef("", op, "'kafka in' and 'kafka out' have different partition schemes. is there a partition operator between them?")
+			return nil, nil, nil, nil, statementErrorAtTokenNamef("", op, "'kafka in' and 'kafka out' have different partition schemes. is there a partition operator between them?")
 		}
 		kafkaEndpointInfo.OutEndpoint = kafkaOutOper
-		updatedExisting = true
-	}
-	if !updatedExisting {
-		sm.kafkaEndpoints[streamName] = &KafkaEndpointInfo{
+	} else {
+		kafkaEndpointInfo = &KafkaEndpointInfo{
 			Name:        streamName,
 			OutEndpoint: kafkaOutOper,
 			Schema:      prevOperator.OutSchema(),
 		}
 	}
-	return kafkaOutOper, prefixRetentions, userSlabInfo, nil
+	return kafkaOutOper, kafkaEndpointInfo, prefixRetentions, userSlabInfo, nil
 }
 
 func (sm *streamManager) deployPartitionOperator(op *parser.PartitionDesc,
@@ -848,7 +1105,6 @@ func (sm *streamManager) deployPartitionOperator(op *parser.PartitionDesc,
 	if err != nil {
 		return nil, err
 	}
-	sm.partitionOperators[po] = struct{}{}
 	return po, nil
 }
 
@@ -954,18 +1210,317 @@ func (sm *streamManager) deployAggregateOperator(streamName string, op *parser.A
 	return aggOper, prefixRetentions, userSlab, nil
 }
 
+// isStatelessDesc returns true for operators which hold no state and are not connected to other streams, these can be
+// freely added, changed or removed when a stream is altered
+func isStatelessDesc(desc parser.Parseable) bool {
+	switch desc.(type) {
+	case *parser.FilterDesc, *parser.DecodeDesc, *parser.EncodeDesc, *parser.ExplodeDesc, *parser.ProjectDesc:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sm *streamManager) newStatelessOperator(desc parser.Parseable, inSchema *OperatorSchema) (Operator, error) {
+	switch op := desc.(type) {
+	case *parser.FilterDesc:
+		return NewFilterOperator(inSchema, op.Expr, sm.expressionFactory)
+	case *parser.DecodeDesc:
+		return sm.deployDecodeOperator(op, inSchema)
+	case *parser.EncodeDesc:
+		return sm.deployEncodeOperator(op, inSchema)
+	case *parser.ExplodeDesc:
+		return NewExplodeOperator(inSchema, op)
+	case *parser.ProjectDesc:
+		return NewProjectOperator(inSchema, op.Expressions, true, sm.expressionFactory)
+	default:
+		panic("not a stateless operator")
+	}
+}
+
+// resolveDeadLetterTarget returns the target that failed rows are sent to, if the stream has the dead_letter error
+// policy. The target must be a stream with 'kafka in', e.g. a topic.
+func (sm *streamManager) resolveDeadLetterTarget(streamDesc *parser.CreateStreamDesc) (*deadLetterTarget, error) {
+	onError := streamDesc.OnError
+	if onError == nil || onError.Policy != parser.ErrorPolicyDeadLetter {
+		return nil, nil
+	}
+	targetName := onError.DeadLetterTarget
+	if targetName == streamDesc.StreamName {
+		return nil, statementErrorAtTokenNamef(targetName, onError, "stream '%s' cannot be its own dead letter target",
+			targetName)
+	}
+	endpoint, ok := sm.kafkaEndpoints[targetName]
+	if !ok || endpoint.InEndpoint == nil {
+		return nil, statementErrorAtTokenNamef(targetName, onError,
+			"dead letter target '%s' must be a topic or a stream with 'kafka in'", targetName)
+	}
+	return &deadLetterTarget{
+		streamName:      targetName,
+		receiverID:      endpoint.InEndpoint.ReceiverID(),
+		partitionScheme: *endpoint.InEndpoint.PartitionScheme(),
+		mgr:             sm,
+	}, nil
+}
+
+func setRowErrorHandler(streamDesc *parser.CreateStreamDesc, desc parser.Parseable, oper Operator,
+	deadLetter *deadLetterTarget) {
+	setter, ok := oper.(rowErrorHandlerSetter)
+	if !ok {
+		return
+	}
+	setter.setRowErrorHandler(newRowErrorHandler(streamDesc.OnError, streamDesc.StreamName, OperatorName(desc),
+		oper.InSchema().EventSchema, oper.(metricsProvider).getMetrics(), deadLetter))
+}
+
+func (sm *streamManager) deployDecodeOperator(op *parser.DecodeDesc, inSchema *OperatorSchema) (Operator, error) {
+	registry, regSchema, messageName, err := sm.lookupSchema(op, "decode", op.Subject, op.Version, op.Message)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecodeOperator(inSchema, op, registry, regSchema, messageName)
+}
+
+func (sm *streamManager) deployEncodeOperator(op *parser.EncodeDesc, inSchema *OperatorSchema) (Operator, error) {
+	_, regSchema, messageName, err := sm.lookupSchema(op, "encode", op.Subject, op.Version, op.Message)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncodeOperator(inSchema, op, regSchema, messageName)
+}
+
+// PinSchemaVersions resolves the latest version of the subject of any 'decode' or 'encode' operator in the stream which
+// does not specify a version, and sets it on the operator. It returns the command with the resolved versions added, so
+// that when the command is stored every node, and every restart, deploys with the same schema, even if newer versions
+// are registered later.
+func (sm *streamManager) PinSchemaVersions(streamDesc *parser.CreateStreamDesc, command string) (string, error) {
+	// The stream manager is not locked as the schemas can be fetched from a remote registry, and no stream state is
+	// needed to resolve them
+	type insertion struct {
+		offset int
+		arg    string
+	}
+	var insertions []insertion
+	for _, desc := range streamDesc.OperatorDescs {
+		var version **int
+		var err error
+		var regSchema *schemareg.Schema
+		switch op := desc.(type) {
+		case *parser.DecodeDesc:
+			if op.Version != nil {
+				continue
+			}
+			version = &op.Version
+			_, regSchema, _, err = sm.lookupSchema(op, "decode", op.Subject, nil, op.Message)
+		case *parser.EncodeDesc:
+			if op.Version != nil {
+				continue
+			}
+			version = &op.Version
+			_, regSchema, _, err = sm.lookupSchema(op, "encode", op.Subject, nil, op.Message)
+		default:
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		offset := parser.ArgumentsEndOffset(desc)
+		if offset == -1 {
+			return "", errors.Errorf("cannot pin schema version of operator %s", OperatorName(desc))
+		}
+		v := regSchema.Version
+		*version = &v
+		insertions = append(insertions, insertion{offset: offset, arg: fmt.Sprintf(" version=%d", v)})
+	}
+	// Insert from the end so the offsets of earlier operators are still valid
+	for i := len(insertions) - 1; i >= 0; i-- {
+		ins := insertions[i]
+		command = command[:ins.offset] + ins.arg + command[ins.offset:]
+	}
+	return command, nil
+}
+
+// lookupSchema finds the schema that a 'decode' or 'encode' operator is deployed with. The latest version of the
+// subject is used if no version is specified - commands are stored with the version pinned, see PinSchemaVersions.
+func (sm *streamManager) lookupSchema(desc errMsgAtPositionProvider, operName string, subject *string, version *int,
+	message *string) (schemareg.Registry, *schemareg.Schema, string, error) {
+	if subject == nil {
+		return nil, nil, "", statementErrorAtTokenNamef("", desc, "'subject' must be specified for '%s'", operName)
+	}
+	registry, err := sm.getSchemaRegistry()
+	if err != nil {
+		return nil, nil, "", statementErrorAtTokenNamef("", desc, "failed to create schema registry: %v", err)
+	}
+	if registry == nil {
+		return nil, nil, "", statementErrorAtTokenNamef("", desc,
+			"cannot use '%s' - no schema registry is configured", operName)
+	}
+	ver := schemareg.LatestVersion
+	if version != nil {
+		ver = *version
+	}
+	regSchema, err := registry.GetSchema(*subject, ver)
+	if err != nil {
+		if common.IsUnavailableError(err) {
+			return nil, nil, "", err
+		}
+		return nil, nil, "", statementErrorAtTokenNamef("subject", desc, "%v", err)
+	}
+	messageName := ""
+	if message != nil {
+		if regSchema.SchemaType != schemareg.SchemaTypeProtobuf {
+			return nil, nil, "", statementErrorAtTokenNamef("message", desc,
+				"'message' can only be specified for protobuf schemas")
+		}
+		messageName = *message
+	}
+	return registry, regSchema, messageName, nil
+}
+
+// getSchemaRegistry returns the schema registry, creating it the first time it is used. It has its own lock as schemas
+// are looked up both with and without the stream manager locked.
+func (sm *streamManager) getSchemaRegistry() (schemareg.Registry, error) {
+	sm.schemaRegistryLock.Lock()
+	defer sm.schemaRegistryLock.Unlock()
+	if sm.schemaRegistry == nil {
+		registry, err := schemareg.NewRegistryFromConfig(sm.cfg)
+		if err != nil || registry == nil {
+			return nil, err
+		}
+		sm.schemaRegistry = registry
+	}
+	return sm.schemaRegistry, nil
+}
+
+func (sm *streamManager) deployDedupOperator(streamName string, op *parser.DedupDesc, prevOperator Operator,
+	slabSliceSeqs *sliceSeq, extraSlabInfos map[string]*SlabInfo,
+	prefixRetentions []slabRetention) (Operator, []slabRetention, error) {
+	if op.Within == nil {
+		return nil, nil, statementErrorAtTokenNamef("", op, "'within' must be specified for 'dedup'")
+	}
+	within := *op.Within
+	if within < 1*time.Millisecond {
+		return nil, nil, statementErrorAtTokenNamef("within", op, "'within' (%s) must be > 0 ms", within)
+	}
+	emitDuplicates := false
+	if op.EmitDuplicates != nil {
+		emitDuplicates = *op.EmitDuplicates
+	}
+	slabID := slabSliceSeqs.GetNextID()
+	dedupOper, err := NewDedupOperator(prevOperator.OutSchema(), op, slabID, within, emitDuplicates,
+		sm.expressionFactory, sm.cfg.NodeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	extraSlabInfos[fmt.Sprintf("dedup-%s-%d", streamName, slabID)] =
+		&SlabInfo{
+			StreamName: streamName,
+			SlabID:     slabID,
+			Type:       SlabTypeInternal,
+			Schema:     prevOperator.OutSchema(),
+		}
+	// As with joins, we retain the seen keys for 2 * within, the operator also checks the window itself as retention is
+	// only applied approximately
+	prefixRetentions = append(prefixRetentions, slabRetention{
+		slabID:    slabID,
+		Retention: 2 * within,
+	})
+	return dedupOper, prefixRetentions, nil
+}
+
+func (sm *streamManager) deployMatchRecognizeOperator(streamName string, op *parser.MatchRecognizeDesc,
+	prevOperator Operator, slabSliceSeqs *sliceSeq, extraSlabInfos map[string]*SlabInfo,
+	prefixRetentions []slabRetention) (Operator, []slabRetention, error) {
+	if op.Pattern == nil {
+		return nil, nil, statementErrorAtTokenNamef("", op, "'pattern' must be specified for 'match_recognize'")
+	}
+	if op.Within == nil {
+		return nil, nil, statementErrorAtTokenNamef("", op, "'within' must be specified for 'match_recognize'")
+	}
+	within := *op.Within
+	if within < 1*time.Millisecond {
+		return nil, nil, statementErrorAtTokenNamef("within", op, "'within' (%s) must be > 0 ms", within)
+	}
+	slabID := slabSliceSeqs.GetNextID()
+	matchOper, err := NewMatchRecognizeOperator(prevOperator.OutSchema(), op, slabID, within, sm.expressionFactory,
+		sm.cfg.NodeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	extraSlabInfos[fmt.Sprintf("match-recognize-%s-%d", streamName, slabID)] =
+		&SlabInfo{
+			StreamName: streamName,
+			SlabID:     slabID,
+			Type:       SlabTypeInternal,
+			Schema:     prevOperator.OutSchema(),
+		}
+	// Partial matches older than within are discarded by the operator, retention removes the state of keys which have
+	// not received any rows since
+	prefixRetentions = append(prefixRetentions, slabRetention{
+		slabID:    slabID,
+		Retention: 2 * within,
+	})
+	return matchOper, prefixRetentions, nil
+}
+
+func (sm *streamManager) deployTopNOperator(streamName string, op *parser.TopNDesc, prevOperator Operator,
+	slabSliceSeqs *sliceSeq, extraSlabInfos map[string]*SlabInfo) (Operator, *SlabInfo, error) {
+	aggOper, ok := prevOperator.(*AggregateOperator)
+	if !ok {
+		return nil, nil, statementErrorAtTokenNamef("", op, "'topn' must directly follow an 'aggregate'")
+	}
+	storeResults := true
+	if op.Store != nil {
+		storeResults = *op.Store
+	}
+	stateSlabID := -1
+	if !aggOper.windowed || !aggOper.includeWindowCols {
+		// Rankings over a windowed aggregate which includes the window columns are computed from the closed window so
+		// there is no state to keep
+		stateSlabID = slabSliceSeqs.GetNextID()
+		extraSlabInfos[fmt.Sprintf("topn-%s-%d", streamName, stateSlabID)] =
+			&SlabInfo{
+				StreamName: streamName,
+				SlabID:     stateSlabID,
+				Type:       SlabTypeInternal,
+				Schema:     prevOperator.OutSchema(),
+			}
+	}
+	resultsSlabID := -1
+	if storeResults {
+		resultsSlabID = slabSliceSeqs.GetNextID()
+	}
+	topNOper, err := NewTopNOperator(prevOperator.OutSchema(), op, aggOper.outKeyColIndexes, aggOper.windowed,
+		stateSlabID, resultsSlabID, storeResults, sm.expressionFactory, sm.cfg.NodeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var userSlab *SlabInfo
+	if storeResults {
+		userSlab = &SlabInfo{
+			StreamName:    streamName,
+			SlabID:        resultsSlabID,
+			Schema:        topNOper.storedSchema,
+			KeyColIndexes: topNOper.storedKeyColIndexes,
+			Type:          SlabTypeUserTable,
+		}
+	}
+	return topNOper, userSlab, nil
+}
+
 func (sm *streamManager) deployStoreTableOperator(streamName string, op *parser.StoreTableDesc,
 	prevOperator Operator, slabSliceSeqs *sliceSeq,
 	prefixRetentions []slabRetention) (Operator, []slabRetention, *SlabInfo, error) {
 	slabID := slabSliceSeqs.GetNextID()
-	to, err := NewStoreTableOperator(prevOperator.OutSchema(), slabID, op.KeyCols, sm.cfg.NodeID, op)
+	changelog := op.Changelog != nil && *op.Changelog
+	to, err := NewStoreTableOperator(prevOperator.OutSchema(), slabID, op.KeyCols, changelog, sm.cfg.NodeID, op)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	userSlab := &SlabInfo{
 		StreamName:    streamName,
 		SlabID:        slabID,
-		Schema:        to.OutSchema(),
+		Schema:        to.tableSchema,
 		KeyColIndexes: to.outKeyCols,
 		Type:          SlabTypeUserTable,
 	}
@@ -1178,7 +1733,7 @@ func (sm *streamManager) findOffsetsSlabID(oper Operator) (int, error) {
 		}
 	case *KafkaInOperator:
 		return o.offsetsSlabID, nil
-	case *BridgeFromOperator:
+	case *BridgeFromOperator, *CdcFromOperator:
 		return -1, nil
 	case *PartitionOperator:
 		return -1, nil
@@ -1244,48 +1799,13 @@ func (sm *streamManager) UndeployStream(deleteStreamDesc parser.DeleteStreamDesc
 			"cannot delete stream %s - it has child streams: %v - they must be deleted first",
 			deleteStreamDesc.StreamName, dsNames)
 	}
-	info.Undeploying = true
-
-	var tearDownChan chan error
-	if sm.loaded {
-		tearDownChan = make(chan error, 1)
-		cf := common.NewCountDownFuture(len(info.Operators), func(err error) {
-			tearDownChan <- err
-		})
-		for _, oper := range info.Operators {
-			oper.Teardown(sm, cf.CountDown)
-		}
-	}
-	if len(info.DownstreamStreamNames) > 0 {
-		var dsNames []string
-		for dsName := range info.DownstreamStreamNames {
-			dsNames = append(dsNames, dsName)
-		}
-		sort.Strings(dsNames)
+	if sourceNames := sm.deadLetterSources(deleteStreamDesc.StreamName); len(sourceNames) > 0 {
 		return statementErrorAtTokenNamef(deleteStreamDesc.StreamName, &deleteStreamDesc,
-			"cannot delete stream %s - it has child streams: %v - they must be deleted first",
-			deleteStreamDesc.StreamName, dsNames)
-	}
-	delete(sm.streams, deleteStreamDesc.StreamName)
-	for upstreamStreamName, oper := range info.UpstreamStreamNames {
-		upstream, ok := sm.streams[upstreamStreamName]
-		if !ok {
-			panic("cannot find upstream")
-		}
-		delete(upstream.DownstreamStreamNames, deleteStreamDesc.StreamName)
-		if oper != nil {
-			upstream.Operators[len(upstream.Operators)-1].RemoveDownStreamOperator(oper)
-		}
-	}
-	delete(sm.kafkaEndpoints, info.StreamDesc.StreamName)
-	kafkaInOper, ok := info.Operators[0].(*BridgeFromOperator)
-	if ok {
-		delete(sm.bridgeFromOpers, kafkaInOper)
-	}
-	partitionOper, ok := info.Operators[0].(*PartitionOperator)
-	if ok {
-		delete(sm.partitionOperators, partitionOper)
+			"cannot delete stream %s - it is the dead letter target of streams: %v - they must be deleted or altered first",
+			deleteStreamDesc.StreamName, sourceNames)
 	}
+	info.Undeploying = true
+	tearDownChan := sm.removeStream(info)
 	// Now delete the data from the store
 	if info.UserSlab != nil {
 		sm.deleteSlab(info.UserSlab)
@@ -1318,6 +1838,271 @@ func (sm *streamManager) UndeployStream(deleteStreamDesc parser.DeleteStreamDesc
 	return nil
 }
 
+// deadLetterSources returns the names of the streams which send failed rows to the stream, in order
+func (sm *streamManager) deadLetterSources(streamName string) []string {
+	var names []string
+	for name, info := range sm.streams {
+		onError := info.StreamDesc.OnError
+		if onError != nil && onError.Policy == parser.ErrorPolicyDeadLetter && onError.DeadLetterTarget == streamName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// removeStream tears down the operators of a stream and unwires it from any streams it consumes from. The slabs of
+// the stream are not deleted. It returns a channel which will receive the result of the teardown, or nil if the
+// stream manager is not yet loaded.
+func (sm *streamManager) removeStream(info *StreamInfo) chan error {
+	var tearDownChan chan error
+	if sm.loaded {
+		tearDownChan = make(chan error, 1)
+		cf := common.NewCountDownFuture(len(info.Operators), func(err error) {
+			tearDownChan <- err
+		})
+		for _, oper := range info.Operators {
+			oper.Teardown(sm, cf.CountDown)
+		}
+	}
+	streamName := info.StreamDesc.StreamName
+	delete(sm.streams, streamName)
+	for upstreamStreamName, oper := range info.UpstreamStreamNames {
+		upstream, ok := sm.streams[upstreamStreamName]
+		if !ok {
+			panic("cannot find upstream")
+		}
+		delete(upstream.DownstreamStreamNames, streamName)
+		if oper != nil {
+			upstream.Operators[len(upstream.Operators)-1].RemoveDownStreamOperator(oper)
+		}
+	}
+	delete(sm.kafkaEndpoints, streamName)
+	for _, oper := range info.Operators {
+		switch op := oper.(type) {
+		case *BridgeFromOperator:
+			delete(sm.bridgeFromOpers, op)
+		case *CdcFromOperator:
+			delete(sm.cdcFromOpers, op)
+		case *PartitionOperator:
+			delete(sm.partitionOperators, op)
+		}
+	}
+	return tearDownChan
+}
+
+/*
+AlterStream replaces the definition of a deployed stream without losing its state.
+
+Operators which hold state or connect the stream to other streams must appear in the new definition unchanged, in the
+same order, and with the same input schema. They are redeployed with the same slab and receiver ids so their data is
+kept. Stateless operators (filter, project, decode, encode and explode) can be added, changed or removed, and new
+operators which hold state can be added after the existing ones. If the stream has child streams its output schema
+must not change.
+
+The old operators are removed and the new ones deployed while holding the stream manager lock, and the command id is
+updated, so any version in progress at the time of the change cannot complete - the new definition takes effect from
+the next version.
+*/
+func (sm *streamManager) AlterStream(streamDesc parser.CreateStreamDesc, receiverSequences []int,
+	slabSequences []int, tsl string, commandID int64) error {
+	sm.shutdownLock.Lock()
+	defer sm.shutdownLock.Unlock()
+	if sm.shuttingDown {
+		return common.NewTektiteErrorf(common.ShutdownError, "cluster is shutting down")
+	}
+	sm.lock.Lock()
+	unlocked := false
+	defer func() {
+		if !unlocked {
+			sm.lock.Unlock()
+		}
+	}()
+	streamName := streamDesc.StreamName
+	info, ok := sm.streams[streamName]
+	if !ok {
+		return statementErrorAtTokenNamef(streamName, &streamDesc, "unknown stream '%s'", streamName)
+	}
+	if info.Undeploying {
+		return common.NewTektiteErrorf(common.InternalError, "stream is being undeployed")
+	}
+	if err := validateStream(&streamDesc); err != nil {
+		return err
+	}
+	if streamDesc.FromSavepoint != "" {
+		return statementErrorAtTokenNamef("savepoint", &streamDesc,
+			"a savepoint can only be loaded when a stream is created, not when it is altered")
+	}
+	streamDesc = sm.maybeRewriteDesc(streamDesc)
+	reusedSequences, err := sm.checkCanAlterStream(info, &streamDesc)
+	if err != nil {
+		return err
+	}
+	log.Debugf("%s: node: %d altering stream %s", sm.cfg.LogScope, sm.cfg.NodeID, streamName)
+
+	tearDownChan := sm.removeStream(info)
+	newInfo, err := sm.deployStreamOperators(streamDesc, receiverSequences, slabSequences, reusedSequences, tsl,
+		commandID)
+	if err != nil {
+		// Put the existing stream back - it deployed before so this will succeed. The command failed, so the stream
+		// keeps the command id of its existing definition
+		var redeployErr error
+		newInfo, redeployErr = sm.deployStreamOperators(info.StreamDesc, nil, nil, info.reusedSequences(), info.Tsl,
+			info.CommandID)
+		if redeployErr != nil {
+			return redeployErr
+		}
+		newInfo.ReplacedCommandIDs = info.ReplacedCommandIDs
+		sm.reattachChildStreams(info, newInfo)
+		sm.streamChanged(newInfo)
+	} else {
+		newInfo.ReplacedCommandIDs = append(info.ReplacedCommandIDs, info.CommandID)
+		sm.reattachChildStreams(info, newInfo)
+		sm.streamDeployed(newInfo, commandID)
+	}
+	sm.lock.Unlock()
+	unlocked = true
+	if tearDownChan != nil {
+		// Wait for teardown to complete outside lock
+		if teardownErr := <-tearDownChan; teardownErr != nil {
+			return teardownErr
+		}
+	}
+	return err
+}
+
+// checkCanAlterStream checks that a stream can be altered to the new definition without losing state, and returns the
+// receiver and slab ids to reuse, keyed by operator index in the new definition.
+func (sm *streamManager) checkCanAlterStream(info *StreamInfo,
+	streamDesc *parser.CreateStreamDesc) (map[int]operatorSequences, error) {
+	streamName := streamDesc.StreamName
+	oldDescs := info.StreamDesc.OperatorDescs
+	var oldStatefulIndexes []int
+	for i, desc := range oldDescs {
+		if !isStatelessDesc(desc) {
+			oldStatefulIndexes = append(oldStatefulIndexes, i)
+		}
+	}
+	reused := map[int]operatorSequences{}
+	// The schema output by the previous operator - nil if it won't be known until a new operator is deployed
+	var schema *OperatorSchema
+	matched := 0
+	for i, desc := range streamDesc.OperatorDescs {
+		if isStatelessDesc(desc) {
+			if schema != nil {
+				oper, err := sm.newStatelessOperator(desc, schema)
+				if err != nil {
+					return nil, err
+				}
+				schema = oper.OutSchema()
+			}
+			continue
+		}
+		if matched == len(oldStatefulIndexes) {
+			// A new operator, added after all the existing ones
+			schema = nil
+			continue
+		}
+		oldIndex := oldStatefulIndexes[matched]
+		if !parser.SameDefinition(desc, oldDescs[oldIndex]) {
+			return nil, statementErrorAtTokenNamef("", alterErrorProvider(desc, streamDesc),
+				"cannot alter stream '%s' - operators which hold state or connect to other streams cannot be changed, removed or reordered",
+				streamName)
+		}
+		oldOper := info.Operators[oldIndex]
+		if i > 0 && !operatorSchemasEqual(schema, oldOper.InSchema()) {
+			inSchema := "unknown"
+			if schema != nil {
+				inSchema = schema.EventSchema.String()
+			}
+			return nil, statementErrorAtTokenNamef("", alterErrorProvider(desc, streamDesc),
+				"cannot alter stream '%s' - the input of this operator would change from (%s) to (%s), but it holds state so its input cannot change",
+				streamName, oldOper.InSchema().EventSchema.String(), inSchema)
+		}
+		reused[i] = info.operatorSequences[oldIndex]
+		schema = oldOper.OutSchema()
+		matched++
+	}
+	if matched < len(oldStatefulIndexes) {
+		return nil, statementErrorAtTokenNamef(streamName, streamDesc,
+			"cannot alter stream '%s' - operators which hold state or connect to other streams cannot be changed, removed or reordered",
+			streamName)
+	}
+	if len(info.DownstreamStreamNames) > 0 {
+		var dsNames []string
+		for dsName := range info.DownstreamStreamNames {
+			dsNames = append(dsNames, dsName)
+		}
+		sort.Strings(dsNames)
+		if schema == nil || !operatorSchemasEqual(schema, info.OutSchema) {
+			return nil, statementErrorAtTokenNamef(streamName, streamDesc,
+				"cannot alter stream '%s' - it has child streams: %v - so its output schema cannot change", streamName,
+				dsNames)
+		}
+	}
+	return reused, nil
+}
+
+// alterErrorProvider returns the desc that an error for an operator should be reported against. Operators created by
+// rewriting the stream, e.g. from a topic, have no tokens so the error is reported against the stream.
+func alterErrorProvider(desc parser.Parseable, streamDesc *parser.CreateStreamDesc) errMsgAtPositionProvider {
+	provider, ok := desc.(errMsgAtPositionProvider)
+	if ok && parser.IsParsed(desc) {
+		return provider
+	}
+	return streamDesc
+}
+
+// reusedSequences returns the receiver and slab ids of all the operators in the stream, keyed by operator index
+func (s *StreamInfo) reusedSequences() map[int]operatorSequences {
+	reused := make(map[int]operatorSequences, len(s.operatorSequences))
+	for i, seqs := range s.operatorSequences {
+		reused[i] = seqs
+	}
+	return reused
+}
+
+// reattachChildStreams wires the child streams of a stream which has been altered to the last operator of the new
+// definition
+func (sm *streamManager) reattachChildStreams(oldInfo *StreamInfo, newInfo *StreamInfo) {
+	lastOper := newInfo.Operators[len(newInfo.Operators)-1]
+	for childName := range oldInfo.DownstreamStreamNames {
+		newInfo.DownstreamStreamNames[childName] = struct{}{}
+		child, ok := sm.streams[childName]
+		if !ok {
+			panic("cannot find child stream")
+		}
+		oper := child.UpstreamStreamNames[newInfo.StreamDesc.StreamName]
+		if oper == nil {
+			continue
+		}
+		lastOper.AddDownStreamOperator(oper)
+		if _, ok := oper.(*ContinuationOperator); ok {
+			oper.SetParentOperator(lastOper)
+		}
+	}
+}
+
+func operatorSchemasEqual(schema1 *OperatorSchema, schema2 *OperatorSchema) bool {
+	if schema1 == nil || schema2 == nil {
+		return schema1 == schema2
+	}
+	if schema1.MappingID != schema2.MappingID || schema1.Partitions != schema2.Partitions {
+		return false
+	}
+	if !reflect.DeepEqual(schema1.EventSchema.ColumnNames(), schema2.EventSchema.ColumnNames()) {
+		return false
+	}
+	types1 := schema1.EventSchema.ColumnTypes()
+	types2 := schema2.EventSchema.ColumnTypes()
+	for i, colType := range types1 {
+		if !types.ColumnTypesEqual(colType, types2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func (sm *streamManager) unregisterSlabRetention(slabID int) {
 	if err := sm.slabRetentions.UnregisterSlabRetention(slabID); err != nil {
 		log.Warnf("failed to unregister slab retention %d", slabID)
@@ -1421,7 +2206,7 @@ func (sm *streamManager) RegisterSystemSlab(slabName string, persistorReceiverID
 	slabID int, schema *OperatorSchema, keyCols []string) error {
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
-	to, err := NewStoreTableOperator(schema, slabID, keyCols, -1, nil)
+	to, err := NewStoreTableOperator(schema, slabID, keyCols, false, -1, nil)
 	if err != nil {
 		return err
 	}
@@ -1641,7 +2426,18 @@ func (sm *streamManager) HandleProcessBatch(processor proc.Processor, processBat
 		}
 		return true, ec.entries, ec.GetForwardBarriers(), nil
 	} else {
-		_, err := receiver.ReceiveBatch(processBatch.EvBatch, ec)
+		var err error
+		if provider, ok := receiver.(metricsProvider); ok {
+			// Rows are counted as input where the batch enters the stream, batches received from other processors
+			// have already been counted by the sending operator
+			err = measureBatch(provider.getMetrics(), nil, processBatch.EvBatch, receiver.RequiresBarriersInjection(),
+				ec, func() error {
+					_, err := receiver.ReceiveBatch(processBatch.EvBatch, ec)
+					return err
+				})
+		} else {
+			_, err = receiver.ReceiveBatch(processBatch.EvBatch, ec)
+		}
 		if err != nil {
 			return false, nil, nil, err
 		}
@@ -1708,7 +2504,7 @@ func (sm *streamManager) GetRequiredCompletions() int {
 func buildPartitionTree(operator Operator, tree *partitionTree) {
 	switch t := operator.(type) {
 	// These operators are either top level, or forward batches to other processors
-	case *BridgeFromOperator, *KafkaInOperator, *PartitionOperator, *JoinOperator, *UnionOperator:
+	case *BridgeFromOperator, *CdcFromOperator, *KafkaInOperator, *PartitionOperator, *JoinOperator, *UnionOperator:
 		// Insert a new node in the tree
 		subTree := &partitionTree{}
 		subTree.operator = t
@@ -1784,6 +2580,10 @@ func (sm *streamManager) StopIngest() error {
 	for fk := range sm.bridgeFromOpers {
 		fkOpers = append(fkOpers, fk)
 	}
+	var cdcOpers []*CdcFromOperator
+	for cf := range sm.cdcFromOpers {
+		cdcOpers = append(cdcOpers, cf)
+	}
 	var partOpers []*PartitionOperator
 	for po := range sm.partitionOperators {
 		partOpers = append(partOpers, po)
@@ -1795,6 +2595,9 @@ func (sm *streamManager) StopIngest() error {
 	for _, fk := range fkOpers {
 		fk.stopIngest()
 	}
+	for _, cf := range cdcOpers {
+		cf.stopIngest()
+	}
 	return nil
 }
 
@@ -1809,6 +2612,11 @@ func (sm *streamManager) StartIngest(version int) error {
 			return err
 		}
 	}
+	for cf := range sm.cdcFromOpers {
+		if err := cf.startIngest(int64(version)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1968,6 +2776,17 @@ type execContext struct {
 	partitionBuilders map[int]map[int]buildersInfo
 	numForwardBatches int
 	forwardBarriers   []forwardBarrierInfo
+	stateReads        int
+	stateWrites       int
+}
+
+// DeferCompletion lets a receiver complete the batch after it returns - see proc.ProcessBatch.DeferCompletion
+func (e *execContext) DeferCompletion() func(error) {
+	return e.processBatch.DeferCompletion()
+}
+
+func (e *execContext) stateAccessCounts() (int, int) {
+	return e.stateReads, e.stateWrites
 }
 
 func (e *execContext) CheckInProcessorLoop() {
@@ -1979,6 +2798,7 @@ func (e *execContext) BackFill() bool {
 }
 
 func (e *execContext) Get(key []byte) ([]byte, error) {
+	e.stateReads++
 	// First look in cache
 	val, exists := e.processor.WriteCache().Get(key)
 	if exists {
@@ -1995,6 +2815,7 @@ func (e *execContext) StoreEntry(kv common.KV, cache bool) {
 			tp.ValidateKeyRange(kv.Key)
 		}
 	}
+	e.stateWrites++
 	if e.entries == nil {
 		e.entries = mem.NewBatch()
 	}
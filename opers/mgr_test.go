@@ -117,7 +117,7 @@ func testBridgeFromOper(t *testing.T, msgs [][]*kafka.Message, numFailuresToCrea
 
 	fact := msgClientFact{msgs: msgs,
 		numFailuresToCreate: numFailuresToCreate}
-	mgr := NewStreamManager(fact.createTestMessageClient, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false)
+	mgr := NewStreamManager(fact.createTestMessageClient, nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false)
 	mgr.SetProcessorManager(pm)
 	mgr.Loaded()
 	pm.SetBatchHandler(mgr)
@@ -224,7 +224,7 @@ func TestAddRemoveProcessors(t *testing.T) {
 	cfg := &conf.Config{}
 	cfg.ApplyDefaults()
 	fact := msgClientFact{msgs: msgs}
-	mgr := NewStreamManager(fact.createTestMessageClient, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false)
+	mgr := NewStreamManager(fact.createTestMessageClient, nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false)
 	mgr.SetProcessorManager(pm)
 	mgr.Loaded()
 	pm.SetBatchHandler(mgr)
@@ -287,7 +287,7 @@ func TestGetInjectableReceivers(t *testing.T) {
 
 	cfg := &conf.Config{}
 	cfg.ApplyDefaults()
-	mgr := NewStreamManager(nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false).(*streamManager)
+	mgr := NewStreamManager(nil, nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false).(*streamManager)
 	mgr.SetProcessorManager(pm)
 	mgr.Loaded()
 	pm.SetBatchHandler(mgr)
@@ -404,7 +404,7 @@ func TestGetForwardingProcessorCountKafkaIn(t *testing.T) {
 
 	cfg := &conf.Config{}
 	cfg.ApplyDefaults()
-	mgr := NewStreamManager(nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, true).(*streamManager)
+	mgr := NewStreamManager(nil, nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, true).(*streamManager)
 	mgr.SetProcessorManager(pm)
 	mgr.Loaded()
 	pm.SetBatchHandler(mgr)
@@ -461,7 +461,7 @@ func TestGetForwardingProcessorCountMultiplePartitions(t *testing.T) {
 
 	cfg := &conf.Config{}
 	cfg.ApplyDefaults()
-	mgr := NewStreamManager(nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, true).(*streamManager)
+	mgr := NewStreamManager(nil, nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, true).(*streamManager)
 	mgr.SetProcessorManager(pm)
 	mgr.Loaded()
 	pm.SetBatchHandler(mgr)
@@ -529,7 +529,7 @@ func TestGetRequiredCompletions(t *testing.T) {
 
 	cfg := &conf.Config{}
 	cfg.ApplyDefaults()
-	mgr := NewStreamManager(nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false).(*streamManager)
+	mgr := NewStreamManager(nil, nil, &dummySlabRetentions{}, &expr.ExpressionFactory{}, cfg, false).(*streamManager)
 	mgr.SetProcessorManager(pm)
 	mgr.Loaded()
 	pm.SetBatchHandler(mgr)
@@ -653,7 +653,7 @@ func TestRegisterUnregisterSlabRetentions(t *testing.T) {
 
 	cfg := &conf.Config{}
 	cfg.ApplyDefaults()
-	mgr := NewStreamManager(nil, retentions, &expr.ExpressionFactory{}, cfg, false).(*streamManager)
+	mgr := NewStreamManager(nil, nil, retentions, &expr.ExpressionFactory{}, cfg, false).(*streamManager)
 	mgr.SetProcessorManager(pm)
 	mgr.Loaded()
 	pm.SetBatchHandler(mgr)
@@ -0,0 +1,203 @@
+package opers
+
+import (
+	"encoding/binary"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/expr"
+	"github.com/spirit-labs/tektite/kafkaencoding"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/proc"
+	"github.com/spirit-labs/tektite/types"
+	"math"
+	"time"
+)
+
+// rowErrorHandler applies the error policy of a stream to rows which an operator fails to process, e.g. because an
+// expression errors or a value cannot be decoded. With the fail policy the error is returned and the batch fails,
+// with skip the row is dropped, and with dead_letter the row is dropped and sent to the dead letter target.
+type rowErrorHandler struct {
+	policy       string
+	streamName   string
+	operatorName string
+	metrics      *OperatorMetrics
+	deadLetter   *deadLetterTarget
+	inSchema     *evbatch.EventSchema
+	inColExprs   []expr.Expression
+}
+
+// deadLetterTarget is the kafka in operator that failed rows are produced to. We hold the receiver and partition
+// scheme rather than the operator as these are kept if the target stream is altered.
+type deadLetterTarget struct {
+	streamName      string
+	receiverID      int
+	partitionScheme PartitionScheme
+	mgr             StreamManagerCtx
+}
+
+// rowErrorPolicy is embedded by operators which apply the error policy of the stream to rows they fail to process.
+// It has no handler when the operator is used in a query, in which case errors are always returned.
+type rowErrorPolicy struct {
+	rowErrors *rowErrorHandler
+}
+
+func (r *rowErrorPolicy) setRowErrorHandler(handler *rowErrorHandler) {
+	r.rowErrors = handler
+}
+
+type rowErrorHandlerSetter interface {
+	setRowErrorHandler(handler *rowErrorHandler)
+}
+
+func newRowErrorHandler(onError *parser.OnErrorDesc, streamName string, operatorName string, inSchema *evbatch.EventSchema,
+	metrics *OperatorMetrics, deadLetter *deadLetterTarget) *rowErrorHandler {
+	policy := parser.ErrorPolicyFail
+	if onError != nil {
+		policy = onError.Policy
+	}
+	colExprs := make([]expr.Expression, len(inSchema.ColumnTypes()))
+	for i, colType := range inSchema.ColumnTypes() {
+		colExprs[i] = expr.NewColumnExpression(i, colType)
+	}
+	return &rowErrorHandler{
+		policy:       policy,
+		streamName:   streamName,
+		operatorName: operatorName,
+		metrics:      metrics,
+		deadLetter:   deadLetter,
+		inSchema:     inSchema,
+		inColExprs:   colExprs,
+	}
+}
+
+// handleRowError is called when the row at rowIndex of the batch fails with err. It returns err if the batch should
+// fail, otherwise the row should be dropped. Rows for the dead letter target are added to records.
+func (h *rowErrorHandler) handleRowError(err error, rowIndex int, batch *evbatch.Batch, records *deadLetterRecords) error {
+	if h == nil {
+		return err
+	}
+	switch h.policy {
+	case parser.ErrorPolicySkip:
+		h.metrics.skippedRows.Add(1)
+		return nil
+	case parser.ErrorPolicyDeadLetter:
+		if encodeErr := records.append(h, err, rowIndex, batch); encodeErr != nil {
+			return encodeErr
+		}
+		h.metrics.deadLetteredRows.Add(1)
+		return nil
+	default:
+		h.metrics.failedRows.Add(1)
+		return err
+	}
+}
+
+// removeFailedRows is used by operators which process a whole batch at a time. When processing the batch fails,
+// evalRow is called for each row to find the rows which fail, and the error policy is applied to those rows. It
+// returns a batch without the failed rows, which can then be processed.
+func (h *rowErrorHandler) removeFailedRows(batch *evbatch.Batch, execCtx StreamExecContext, batchErr error,
+	evalRow func(rowIndex int) error) (*evbatch.Batch, error) {
+	if h == nil || h.policy == parser.ErrorPolicyFail {
+		if h != nil {
+			h.metrics.failedRows.Add(1)
+		}
+		return nil, batchErr
+	}
+	var records deadLetterRecords
+	colTypes := batch.Schema.ColumnTypes()
+	colBuilders := evbatch.CreateColBuilders(colTypes)
+	for rowIndex := 0; rowIndex < batch.RowCount; rowIndex++ {
+		if err := evalRow(rowIndex); err != nil {
+			if err := h.handleRowError(err, rowIndex, batch, &records); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for colIndex, colType := range colTypes {
+			evbatch.CopyColumnEntry(colType, colBuilders, colIndex, rowIndex, batch)
+		}
+	}
+	h.sendDeadLetters(&records, execCtx)
+	return evbatch.NewBatchFromBuilders(batch.Schema, colBuilders...), nil
+}
+
+// sendDeadLetters produces any rows added to records to the dead letter target, on the partition with the same
+// number, modulo the number of partitions of the target, as the partition being processed
+func (h *rowErrorHandler) sendDeadLetters(records *deadLetterRecords, execCtx StreamExecContext) {
+	if h == nil || records.numRecords == 0 {
+		return
+	}
+	target := h.deadLetter
+	recordBatch := records.build()
+	partitionID := execCtx.PartitionID() % target.partitionScheme.Partitions
+	processorID := target.partitionScheme.PartitionProcessorMapping[partitionID]
+	colBuilder := evbatch.NewBytesColBuilder()
+	colBuilder.Append(recordBatch)
+	batch := evbatch.NewBatch(RecordBatchSchema, colBuilder.Build())
+	pb := proc.NewProcessBatch(processorID, batch, target.receiverID, partitionID, -1)
+	// We replicate the batch, as when producing to the target from a kafka client
+	target.mgr.ProcessorManager().ForwardBatch(pb, true, func(err error) {
+		if err != nil {
+			log.Errorf("failed to send %d rows from stream %s to dead letter stream %s: %v", records.numRecords,
+				h.streamName, target.streamName, err)
+		}
+	})
+}
+
+// deadLetterRecords builds a kafka record batch from rows which have failed. Each record has headers for the stream,
+// operator and error, and its value is the original row, encoded as a JSON object.
+type deadLetterRecords struct {
+	batchBytes     []byte
+	numRecords     int
+	firstTimestamp types.Timestamp
+	lastTimestamp  types.Timestamp
+}
+
+func (d *deadLetterRecords) append(h *rowErrorHandler, rowErr error, rowIndex int, batch *evbatch.Batch) error {
+	rowType := &types.StructType{
+		FieldNames: h.inSchema.ColumnNames(),
+		FieldTypes: h.inSchema.ColumnTypes(),
+	}
+	fields := make([]any, len(h.inColExprs))
+	for i, colExpr := range h.inColExprs {
+		val, null, err := expr.EvalValue(colExpr, rowIndex, batch)
+		if err != nil {
+			return err
+		}
+		if !null {
+			fields[i] = val
+		}
+	}
+	value := types.AppendJSON(nil, rowType, fields)
+	hdrs := binary.AppendVarint(nil, 3)
+	hdrs = appendKafkaHeader(hdrs, "stream", h.streamName)
+	hdrs = appendKafkaHeader(hdrs, "operator", h.operatorName)
+	hdrs = appendKafkaHeader(hdrs, "error", rowErr.Error())
+	timestamp := types.NewTimestamp(time.Now().UnixMilli())
+	first := d.numRecords == 0
+	if first {
+		d.batchBytes = make([]byte, 61)
+		d.firstTimestamp = timestamp
+	}
+	d.batchBytes, _ = kafkaencoding.AppendToBatch(d.batchBytes, int64(d.numRecords), nil, hdrs, value, timestamp,
+		d.firstTimestamp, math.MaxInt, first)
+	d.lastTimestamp = timestamp
+	d.numRecords++
+	return nil
+}
+
+func (d *deadLetterRecords) build() []byte {
+	// Set producer id to -1 as the batch is not from an idempotent producer
+	minusOne := int64(-1)
+	binary.BigEndian.PutUint64(d.batchBytes[43:], uint64(minusOne))
+	kafkaencoding.SetBatchHeader(d.batchBytes, 0, int64(d.numRecords-1), d.firstTimestamp, d.lastTimestamp,
+		d.numRecords)
+	return d.batchBytes
+}
+
+func appendKafkaHeader(buff []byte, name string, value string) []byte {
+	buff = binary.AppendVarint(buff, int64(len(name)))
+	buff = append(buff, name...)
+	buff = binary.AppendVarint(buff, int64(len(value)))
+	return append(buff, value...)
+}
@@ -0,0 +1,190 @@
+package opers
+
+import (
+	"encoding/binary"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/expr"
+	"github.com/spirit-labs/tektite/kafkaencoding"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/proc"
+	"github.com/spirit-labs/tektite/tppm"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestOnErrorSkip(t *testing.T) {
+	mgr, pm := createManager()
+	defer pm.Close()
+	pm.SetBatchHandler(mgr)
+	pm.AddActiveProcessor(0)
+
+	tsl := `test_stream1 := (filter by to_int(f1) > 1) -> (project f1, to_int(f1) as f2) on_error = skip`
+	columnNames := []string{"offset", "event_time", "f1"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeTimestamp, types.ColumnTypeString}
+	deployStream(t, tsl, mgr, columnNames, columnTypes, true, true)
+
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "1"},
+		{int64(1), types.NewTimestamp(1001), "abc"},
+		{int64(2), types.NewTimestamp(1002), "3"},
+	}
+	injectBatch(t, "test_stream1", 0, 0, dataIn, mgr, pm)
+
+	expectedOut := [][]any{
+		{int64(2), types.NewTimestamp(1002), "3", int64(3)},
+	}
+	verifyReceivedData(t, "test_stream1", 0, expectedOut, mgr)
+
+	stats, ok := mgr.GetStreamStats("test_stream1")
+	require.True(t, ok)
+	filter := stats.Operators[1]
+	require.Equal(t, "filter", filter.Operator)
+	require.Equal(t, uint64(1), filter.SkippedRows)
+	require.Equal(t, uint64(0), filter.FailedRows)
+	require.Equal(t, uint64(0), filter.DeadLetteredRows)
+}
+
+func TestOnErrorFail(t *testing.T) {
+	schema := &OperatorSchema{
+		EventSchema: evbatch.NewEventSchema([]string{"f1"}, []types.ColumnType{types.ColumnTypeString}),
+	}
+	exprs, err := toExprs("to_int(f1) > 1")
+	require.NoError(t, err)
+	filter, err := NewFilterOperator(schema, exprs[0], &expr.ExpressionFactory{})
+	require.NoError(t, err)
+
+	// Without an error policy, e.g. in a query, the error is returned
+	batch := createEventBatch([]string{"f1"}, []types.ColumnType{types.ColumnTypeString}, [][]any{{"1"}, {"abc"}})
+	_, err = filter.HandleStreamBatch(batch, &testExecCtx{})
+	require.Error(t, err)
+
+	// The default policy for a stream is to fail
+	filter.setRowErrorHandler(newRowErrorHandler(nil, "test_stream", "filter", schema.EventSchema,
+		&filter.metrics, nil))
+	batch = createEventBatch([]string{"f1"}, []types.ColumnType{types.ColumnTypeString}, [][]any{{"1"}, {"abc"}})
+	_, err = filter.HandleStreamBatch(batch, &testExecCtx{})
+	require.Error(t, err)
+	require.Equal(t, "function 'to_int' - cannot convert abc to int", err.Error())
+	require.Equal(t, uint64(1), filter.metrics.failedRows.Load())
+}
+
+func TestOnErrorDeadLetter(t *testing.T) {
+	schema := &OperatorSchema{
+		EventSchema: evbatch.NewEventSchema([]string{"f1", "f2"},
+			[]types.ColumnType{types.ColumnTypeString, types.ColumnTypeInt}),
+	}
+	exprs, err := toExprs("to_int(f1)")
+	require.NoError(t, err)
+	project, err := NewProjectOperator(schema, exprs, false, &expr.ExpressionFactory{})
+	require.NoError(t, err)
+
+	forwarder := &testDeadLetterForwarder{TestProcessorManager: tppm.NewTestProcessorManager()}
+	target := &deadLetterTarget{
+		streamName:      "dead_letters",
+		receiverID:      1234,
+		partitionScheme: NewPartitionScheme("dead_letters", 4, true, 8),
+		mgr:             &testStreamManagerCtx{pm: forwarder},
+	}
+	onError := &parser.OnErrorDesc{Policy: parser.ErrorPolicyDeadLetter, DeadLetterTarget: "dead_letters"}
+	project.setRowErrorHandler(newRowErrorHandler(onError, "test_stream", "project", schema.EventSchema,
+		&project.metrics, target))
+
+	batch := createEventBatch(schema.EventSchema.ColumnNames(), schema.EventSchema.ColumnTypes(),
+		[][]any{{"1", int64(10)}, {"abc", int64(11)}, {"3", int64(12)}, {"def", nil}})
+	out, err := project.HandleStreamBatch(batch, &testExecCtx{partitionID: 6})
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(1)}, {int64(3)}}, convertBatchToAnyArray(out))
+	require.Equal(t, uint64(2), project.metrics.deadLetteredRows.Load())
+
+	forwarded := forwarder.getForwarded()
+	require.Equal(t, 1, len(forwarded))
+	pb := forwarded[0]
+	require.Equal(t, 1234, pb.ReceiverID)
+	require.Equal(t, 2, pb.PartitionID)
+	require.Equal(t, target.partitionScheme.PartitionProcessorMapping[2], pb.ProcessorID)
+	require.Equal(t, -1, pb.ForwardingProcessorID)
+
+	recordBatch := pb.EvBatch.GetBytesColumn(0).Get(0)
+	require.Equal(t, int64(-1), kafkaencoding.ProducerID(recordBatch))
+	msgs := kafkaencoding.BatchToRawMessages(recordBatch)
+	require.Equal(t, 2, len(msgs))
+	require.Equal(t, `{"f1":"abc","f2":11}`, string(msgs[0].Value))
+	require.Equal(t, `{"f1":"def","f2":null}`, string(msgs[1].Value))
+	require.Equal(t, int64(1), msgs[1].Offset)
+
+	expectedHeaders := binary.AppendVarint(nil, 3)
+	expectedHeaders = appendKafkaHeader(expectedHeaders, "stream", "test_stream")
+	expectedHeaders = appendKafkaHeader(expectedHeaders, "operator", "project")
+	expectedHeaders = appendKafkaHeader(expectedHeaders, "error", "function 'to_int' - cannot convert abc to int")
+	require.Equal(t, expectedHeaders, msgs[0].Headers)
+}
+
+func TestOnErrorDeadLetterTarget(t *testing.T) {
+	mgr, _ := createManager()
+	columnNames := []string{"offset", "event_time", "f1"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeTimestamp, types.ColumnTypeString}
+
+	err := deployStreamReturnError(t, `test_stream1 := (filter by to_int(f1) > 1) on_error = dead_letter(dead_letters)`,
+		mgr, columnNames, columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `dead letter target 'dead_letters' must be a topic or a stream with 'kafka in' (line 1 column 67):
+test_stream1 := (filter by to_int(f1) > 1) on_error = dead_letter(dead_letters)
+                                                                  ^`, err.Error())
+
+	err = deployStreamReturnError(t, `test_stream1 := (filter by to_int(f1) > 1) on_error = dead_letter(test_stream1)`,
+		mgr, columnNames, columnTypes, true, false)
+	require.Error(t, err)
+	require.Equal(t, `stream 'test_stream1' cannot be its own dead letter target (line 1 column 67):
+test_stream1 := (filter by to_int(f1) > 1) on_error = dead_letter(test_stream1)
+                                                                  ^`, err.Error())
+
+	deployStream(t, `dead_letters := (topic partitions = 4)`, mgr, nil, nil, false, false)
+	deployStream(t, `test_stream1 := (filter by to_int(f1) > 1) on_error = dead_letter(dead_letters)`,
+		mgr, columnNames, columnTypes, true, false)
+
+	err = mgr.UndeployStream(createDeleteStreamDesc(t, "dead_letters"), 0)
+	require.Error(t, err)
+	require.Equal(t, `cannot delete stream dead_letters - it is the dead letter target of streams: [test_stream1] - they must be deleted or altered first (line 1 column 8):
+delete(dead_letters)
+       ^`, err.Error())
+
+	err = mgr.UndeployStream(createDeleteStreamDesc(t, "test_stream1"), 0)
+	require.NoError(t, err)
+	err = mgr.UndeployStream(createDeleteStreamDesc(t, "dead_letters"), 0)
+	require.NoError(t, err)
+}
+
+type testDeadLetterForwarder struct {
+	*tppm.TestProcessorManager
+	lock      sync.Mutex
+	forwarded []*proc.ProcessBatch
+}
+
+func (t *testDeadLetterForwarder) ForwardBatch(batch *proc.ProcessBatch, _ bool, completionFunc func(error)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.forwarded = append(t.forwarded, batch)
+	completionFunc(nil)
+}
+
+func (t *testDeadLetterForwarder) getForwarded() []*proc.ProcessBatch {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.forwarded
+}
+
+type testStreamManagerCtx struct {
+	pm ProcessorManager
+}
+
+func (t *testStreamManagerCtx) RegisterReceiver(int, Receiver) {
+}
+
+func (t *testStreamManagerCtx) UnregisterReceiver(int) {
+}
+
+func (t *testStreamManagerCtx) ProcessorManager() ProcessorManager {
+	return t.pm
+}
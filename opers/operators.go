@@ -123,6 +123,11 @@ type BaseOperator struct {
 	downstreamOperators     []Operator
 	downstreamOperatorsLock sync.RWMutex
 	streamInfo              *StreamInfo
+	metrics                 OperatorMetrics
+}
+
+func (b *BaseOperator) getMetrics() *OperatorMetrics {
+	return &b.metrics
 }
 
 func (b *BaseOperator) AddDownStreamOperator(downstream Operator) {
@@ -185,10 +190,23 @@ func (b *BaseOperator) HandleBarrier(execCtx StreamExecContext) error {
 }
 
 func (b *BaseOperator) sendBatchDownStream(batch *evbatch.Batch, execCtx StreamExecContext) error {
+	if batch != nil {
+		b.metrics.rowsOut.Add(uint64(batch.RowCount))
+	}
 	b.downstreamOperatorsLock.RLock()
 	defer b.downstreamOperatorsLock.RUnlock()
 	for _, downstream := range b.downstreamOperators {
-		if _, err := downstream.HandleStreamBatch(batch, execCtx); err != nil {
+		provider, ok := downstream.(metricsProvider)
+		if !ok {
+			if _, err := downstream.HandleStreamBatch(batch, execCtx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := measureBatch(provider.getMetrics(), &b.metrics, batch, true, execCtx, func() error {
+			_, err := downstream.HandleStreamBatch(batch, execCtx)
+			return err
+		}); err != nil {
 			return err
 		}
 	}
@@ -256,6 +274,13 @@ func LoadColsFromKey(colBuilders []evbatch.ColumnBuilder, keyColumnTypes []types
 			var val types.Timestamp
 			val, off = encoding2.KeyDecodeTimestamp(keyBuff, off)
 			colBuilder.(*evbatch.TimestampColBuilder).Append(val)
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			var val any
+			val, off, err = encoding2.KeyDecodeNested(keyBuff, off, colType)
+			if err != nil {
+				return err
+			}
+			colBuilder.(*evbatch.NestedColBuilder).Append(val)
 		default:
 			panic("unknown type")
 		}
@@ -304,6 +329,11 @@ func LoadColsFromValue(colBuilders []evbatch.ColumnBuilder, rowColumnTypes []typ
 			u, off = encoding2.ReadUint64FromBufferLE(valueBuff, off)
 			ts := types.NewTimestamp(int64(u))
 			colBuilder.(*evbatch.TimestampColBuilder).Append(ts)
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			// The column holds the same encoding as the value, so we just need to find where it ends
+			start := off
+			_, off = encoding2.ReadNestedFromBufferLE(valueBuff, off, colType)
+			colBuilder.(*evbatch.NestedColBuilder).AppendEncoded(valueBuff[start:off])
 		default:
 			panic("unknown type")
 		}
@@ -168,6 +168,10 @@ func (p *partitionReceiver) OutSchema() *OperatorSchema {
 	return p.po.outSchema
 }
 
+func (p *partitionReceiver) getMetrics() *OperatorMetrics {
+	return p.po.getMetrics()
+}
+
 func (p *partitionReceiver) ReceiveBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
 	forwardingProcID := execCtx.ForwardingProcessorID()
 	// Screen out duplicates that could have been resent from the forward queue of the source processor, e.g. due
@@ -10,6 +10,7 @@ import (
 
 type ProjectOperator struct {
 	BaseOperator
+	rowErrorPolicy
 	inSchema    *OperatorSchema
 	outSchema   *OperatorSchema
 	expressions []expr.Expression
@@ -86,6 +87,7 @@ func NewProjectOperator(inSchema *OperatorSchema, exprDescs []parser.ExprDesc, i
 }
 
 func (f *ProjectOperator) HandleQueryBatch(batch *evbatch.Batch, execCtx QueryExecContext) (*evbatch.Batch, error) {
+	defer batch.Release()
 	outBatch, err := f.processBatch(batch)
 	if err != nil {
 		return nil, err
@@ -94,16 +96,30 @@ func (f *ProjectOperator) HandleQueryBatch(batch *evbatch.Batch, execCtx QueryEx
 }
 
 func (f *ProjectOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	defer batch.Release()
 	outBatch, err := f.processBatch(batch)
 	if err != nil {
-		return nil, err
+		// Find the rows which fail and apply the error policy to them, then project the remaining rows
+		batch, err = f.rowErrors.removeFailedRows(batch, execCtx, err, func(rowIndex int) error {
+			for _, e := range f.expressions {
+				if _, _, err := expr.EvalValue(e, rowIndex, batch); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		outBatch, err = f.processBatch(batch)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return outBatch, f.sendBatchDownStream(outBatch, execCtx)
 }
 
 func (f *ProjectOperator) processBatch(batch *evbatch.Batch) (*evbatch.Batch, error) {
-	defer batch.Release()
-
 	fTypes := f.outSchema.EventSchema.ColumnTypes()
 	cols := make([]evbatch.Column, len(fTypes))
 	for i, e := range f.expressions {
@@ -0,0 +1,497 @@
+package opers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/iteration"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/proc"
+	"github.com/spirit-labs/tektite/types"
+)
+
+const (
+	savepointManifestObjectName = "manifest.json"
+	savepointLoadBatchSize      = 1000
+)
+
+var savepointSchema = evbatch.NewEventSchema(
+	[]string{"prefix", "slab_index", "slab_id", "mapping_id", "partition_id", "version"},
+	[]types.ColumnType{types.ColumnTypeString, types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeString,
+		types.ColumnTypeInt, types.ColumnTypeInt})
+
+var loadSavepointSchema = evbatch.NewEventSchema([]string{"key", "value"},
+	[]types.ColumnType{types.ColumnTypeBytes, types.ColumnTypeBytes})
+
+// savepointManifest describes the slabs in a savepoint. It is written after the data of all the slabs, so a savepoint
+// without a manifest is incomplete.
+type savepointManifest struct {
+	StreamName string          `json:"stream_name"`
+	Version    int             `json:"version"`
+	Slabs      []savepointSlab `json:"slabs"`
+}
+
+type savepointSlab struct {
+	SlabID      int      `json:"slab_id"`
+	Partitions  int      `json:"partitions"`
+	ColumnTypes []string `json:"column_types"`
+}
+
+func newSavepointSlab(slabInfo *SlabInfo) savepointSlab {
+	colTypes := slabInfo.Schema.EventSchema.ColumnTypes()
+	colTypeNames := make([]string, len(colTypes))
+	for i, colType := range colTypes {
+		colTypeNames[i] = colType.String()
+	}
+	return savepointSlab{
+		SlabID:      slabInfo.SlabID,
+		Partitions:  slabInfo.Schema.Partitions,
+		ColumnTypes: colTypeNames,
+	}
+}
+
+func (s *savepointSlab) compatibleWith(other savepointSlab) bool {
+	if s.Partitions != other.Partitions || len(s.ColumnTypes) != len(other.ColumnTypes) {
+		return false
+	}
+	for i, colType := range s.ColumnTypes {
+		if colType != other.ColumnTypes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The data of each partition is written in one or more chunks, so the size of an object is bounded. The first byte of
+// each chunk says whether there are more chunks of the partition after it.
+const (
+	savepointLastChunk byte = iota
+	savepointMoreChunks
+)
+
+func savepointChunkObjectName(prefix string, slabIndex int, partitionID int, chunk int) string {
+	return fmt.Sprintf("%s/slab-%d/partition-%d/chunk-%d", prefix, slabIndex, partitionID, chunk)
+}
+
+// savepointSlabs returns the slabs of the stream in the order that their ids were allocated. Deploying the same stream
+// definition allocates the same slabs in the same order, and this is how the slabs in a savepoint are matched with the
+// slabs of the stream it is loaded into.
+func (s *StreamInfo) savepointSlabs() []*SlabInfo {
+	slabsByID := map[int]*SlabInfo{}
+	if s.UserSlab != nil {
+		slabsByID[s.UserSlab.SlabID] = s.UserSlab
+	}
+	for _, slabInfo := range s.ExtraSlabs {
+		slabsByID[slabInfo.SlabID] = slabInfo
+	}
+	var slabs []*SlabInfo
+	for _, seqs := range s.operatorSequences {
+		for _, slabID := range seqs.slabIDs {
+			slabInfo, ok := slabsByID[slabID]
+			if ok {
+				slabs = append(slabs, slabInfo)
+			}
+		}
+	}
+	return slabs
+}
+
+// SavepointStream writes the state of each slab that the stream owns to the object store, under the prefix of the
+// savepoint. The slabs are read as of the specified version, which must be a completed version, so the savepoint is
+// consistent across all partitions.
+func (sm *streamManager) SavepointStream(savepoint parser.SavepointDesc, version int) error {
+	sm.lock.RLock()
+	info, ok := sm.streams[savepoint.StreamName]
+	if !ok || info.SystemStream {
+		sm.lock.RUnlock()
+		return statementErrorAtTokenNamef(savepoint.StreamName, &savepoint, "unknown stream '%s'", savepoint.StreamName)
+	}
+	slabs := info.savepointSlabs()
+	// We must not hold the lock while the slabs are written as the receivers lock the stream manager too
+	sm.lock.RUnlock()
+	if len(slabs) == 0 {
+		return statementErrorAtTokenNamef(savepoint.StreamName, &savepoint, "stream '%s' does not have any state to savepoint",
+			savepoint.StreamName)
+	}
+	if version < 0 {
+		return common.NewTektiteErrorf(common.Unavailable, "cannot savepoint stream - no version has completed yet")
+	}
+	manifest := savepointManifest{
+		StreamName: savepoint.StreamName,
+		Version:    version,
+	}
+	for slabIndex, slabInfo := range slabs {
+		if err := sm.savepointSlab(savepoint.Prefix, slabIndex, slabInfo, version); err != nil {
+			return err
+		}
+		manifest.Slabs = append(manifest.Slabs, newSavepointSlab(slabInfo))
+	}
+	manifestBytes, err := json.Marshal(&manifest)
+	if err != nil {
+		return err
+	}
+	return objstore.PutWithTimeout(sm.objStoreClient, sm.cfg.BucketName,
+		fmt.Sprintf("%s/%s", savepoint.Prefix, savepointManifestObjectName), manifestBytes, sm.cfg.SavepointCallTimeout)
+}
+
+func (sm *streamManager) savepointSlab(prefix string, slabIndex int, slabInfo *SlabInfo, version int) error {
+	// We send a batch to each processor that the slab uses. The data must be read on the processor that owns the
+	// partition hashes, hence using a receiver to do this.
+	ch := make(chan error, 1)
+	fut := common.NewCountDownFuture(len(slabInfo.Schema.ProcessorPartitionMapping), func(err error) {
+		ch <- err
+	})
+	for procID, partIDs := range slabInfo.Schema.ProcessorPartitionMapping {
+		colBuilders := evbatch.CreateColBuilders(savepointSchema.ColumnTypes())
+		for _, partID := range partIDs {
+			colBuilders[0].(*evbatch.StringColBuilder).Append(prefix)
+			colBuilders[1].(*evbatch.IntColBuilder).Append(int64(slabIndex))
+			colBuilders[2].(*evbatch.IntColBuilder).Append(int64(slabInfo.SlabID))
+			colBuilders[3].(*evbatch.StringColBuilder).Append(slabInfo.Schema.MappingID)
+			colBuilders[4].(*evbatch.IntColBuilder).Append(int64(partID))
+			colBuilders[5].(*evbatch.IntColBuilder).Append(int64(version))
+		}
+		eventBatch := evbatch.NewBatchFromBuilders(savepointSchema, colBuilders...)
+		batch := proc.NewProcessBatch(procID, eventBatch, common.SavepointReceiverID, partIDs[0], -1)
+		sm.processorManager.ForwardBatch(batch, false, fut.CountDown)
+	}
+	return <-ch
+}
+
+// LoadedStream is a stream whose state has been loaded from a savepoint, but which has not been deployed yet. It is
+// deployed with the operators that the state was loaded for, or discarded, which deletes the state.
+type LoadedStream struct {
+	info              *StreamInfo
+	kafkaEndpointInfo *KafkaEndpointInfo
+	deferredWirings   []func(info *StreamInfo)
+}
+
+// LoadSavepoint builds the stream with the sequences, and loads the state in the savepoint into its slabs. It is
+// called before the stream is deployed, so the stream never processes data without its state. The keys are rewritten
+// with the slab ids and partition hashes of the new slabs. If the state cannot be loaded, any state which has been
+// loaded is deleted.
+func (sm *streamManager) LoadSavepoint(streamDesc parser.CreateStreamDesc, receiverSequences []int,
+	slabSequences []int) (*LoadedStream, error) {
+	stream, err := sm.buildLoadedStream(streamDesc, receiverSequences, slabSequences)
+	if err != nil {
+		return nil, err
+	}
+	// The stream manager must not be locked while the state is loaded, as the receivers lock it too
+	if err := sm.loadSavepoint(streamDesc, stream.info.savepointSlabs()); err != nil {
+		sm.DiscardLoadedStream(stream)
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (sm *streamManager) buildLoadedStream(streamDesc parser.CreateStreamDesc, receiverSequences []int,
+	slabSequences []int) (*LoadedStream, error) {
+	if streamDesc.Alter {
+		return nil, statementErrorAtTokenNamef("savepoint", &streamDesc,
+			"a savepoint can only be loaded when a stream is created, not when it is altered")
+	}
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	streamDesc, err := sm.prepareNewStream(streamDesc)
+	if err != nil {
+		return nil, err
+	}
+	info, kafkaEndpointInfo, deferredWirings, err := sm.buildStreamOperators(streamDesc, receiverSequences,
+		slabSequences, nil, "", -1)
+	if err != nil {
+		return nil, err
+	}
+	return &LoadedStream{
+		info:              info,
+		kafkaEndpointInfo: kafkaEndpointInfo,
+		deferredWirings:   deferredWirings,
+	}, nil
+}
+
+func (sm *streamManager) loadSavepoint(streamDesc parser.CreateStreamDesc, slabs []*SlabInfo) error {
+	prefix := streamDesc.FromSavepoint
+	manifestBytes, err := objstore.GetWithTimeout(sm.objStoreClient, sm.cfg.BucketName,
+		fmt.Sprintf("%s/%s", prefix, savepointManifestObjectName), sm.cfg.SavepointCallTimeout)
+	if err != nil {
+		return err
+	}
+	if manifestBytes == nil {
+		return statementErrorAtTokenNamef("savepoint", &streamDesc, "savepoint '%s' does not exist or is incomplete",
+			prefix)
+	}
+	var manifest savepointManifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return err
+	}
+	if len(manifest.Slabs) != len(slabs) {
+		return statementErrorAtTokenNamef("savepoint", &streamDesc,
+			"savepoint '%s' has %d slabs but stream '%s' has %d - the stream must have the same operators as stream '%s' when the savepoint was taken",
+			prefix, len(manifest.Slabs), streamDesc.StreamName, len(slabs), manifest.StreamName)
+	}
+	for i, slabInfo := range slabs {
+		if !manifest.Slabs[i].compatibleWith(newSavepointSlab(slabInfo)) {
+			return statementErrorAtTokenNamef("savepoint", &streamDesc,
+				"savepoint '%s' is not compatible with stream '%s' - slab %d has a different schema or number of partitions",
+				prefix, streamDesc.StreamName, i)
+		}
+	}
+	for slabIndex, slabInfo := range slabs {
+		if err := sm.loadSavepointSlab(prefix, slabIndex, slabInfo); err != nil {
+			return err
+		}
+	}
+	log.Infof("%s: loaded savepoint '%s' of stream '%s' at version %d into stream '%s'", sm.cfg.LogScope, prefix,
+		manifest.StreamName, manifest.Version, streamDesc.StreamName)
+	return nil
+}
+
+// DeployLoadedStream deploys a stream whose state has been loaded from a savepoint. If it cannot be deployed, the
+// loaded stream must be discarded.
+func (sm *streamManager) DeployLoadedStream(stream *LoadedStream, tsl string, commandID int64) error {
+	sm.shutdownLock.Lock()
+	defer sm.shutdownLock.Unlock()
+	if sm.shuttingDown {
+		return common.NewTektiteErrorf(common.ShutdownError, "cluster is shutting down")
+	}
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	info := stream.info
+	streamDesc := info.StreamDesc
+	if _, exists := sm.streams[streamDesc.StreamName]; exists {
+		return statementErrorAtTokenNamef(streamDesc.StreamName, &streamDesc, "stream '%s' already exists",
+			streamDesc.StreamName)
+	}
+	info.Tsl = tsl
+	info.CommandID = commandID
+	if err := sm.activateStreamOperators(info, stream.kafkaEndpointInfo, stream.deferredWirings); err != nil {
+		return err
+	}
+	sm.streamDeployed(info, commandID)
+	return nil
+}
+
+// DiscardLoadedStream deletes the state which was loaded for a stream that will not be deployed
+func (sm *streamManager) DiscardLoadedStream(stream *LoadedStream) {
+	for _, slabInfo := range stream.info.savepointSlabs() {
+		sm.deleteSlab(slabInfo)
+	}
+}
+
+func (sm *streamManager) loadSavepointSlab(prefix string, slabIndex int, slabInfo *SlabInfo) error {
+	for partID := 0; partID < slabInfo.Schema.Partitions; partID++ {
+		// Each chunk is loaded before the next one is fetched, so only one chunk of the partition is held in memory
+		for chunk, more := 0, true; more; chunk++ {
+			objectName := savepointChunkObjectName(prefix, slabIndex, partID, chunk)
+			buff, err := objstore.GetWithTimeout(sm.objStoreClient, sm.cfg.BucketName, objectName,
+				sm.cfg.SavepointCallTimeout)
+			if err != nil {
+				return err
+			}
+			if len(buff) == 0 {
+				return common.NewStatementError(fmt.Sprintf("savepoint '%s' is corrupt - object '%s' is missing",
+					prefix, objectName))
+			}
+			more = buff[0] == savepointMoreChunks
+			if err := sm.loadSavepointChunk(buff[1:], slabInfo, partID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (sm *streamManager) loadSavepointChunk(buff []byte, slabInfo *SlabInfo, partID int) error {
+	partitionHash := proc.CalcPartitionHash(slabInfo.Schema.MappingID, uint64(partID))
+	procID := slabInfo.Schema.PartitionProcessorMapping[partID]
+	keyPrefix := encoding.EncodeEntryPrefix(partitionHash, uint64(slabInfo.SlabID), 24)
+	var batches []*proc.ProcessBatch
+	var colBuilders []evbatch.ColumnBuilder
+	numRows := 0
+	for offset := 0; offset < len(buff); {
+		if colBuilders == nil {
+			colBuilders = evbatch.CreateColBuilders(loadSavepointSchema.ColumnTypes())
+		}
+		var keySuffix, value []byte
+		keySuffix, offset = encoding.ReadBytesFromBufferLE(buff, offset)
+		value, offset = encoding.ReadBytesFromBufferLE(buff, offset)
+		key := make([]byte, 0, len(keyPrefix)+len(keySuffix))
+		key = append(key, keyPrefix...)
+		key = append(key, keySuffix...)
+		colBuilders[0].(*evbatch.BytesColBuilder).Append(key)
+		colBuilders[1].(*evbatch.BytesColBuilder).Append(value)
+		numRows++
+		if numRows == savepointLoadBatchSize || offset == len(buff) {
+			eventBatch := evbatch.NewBatchFromBuilders(loadSavepointSchema, colBuilders...)
+			batches = append(batches, proc.NewProcessBatch(procID, eventBatch, common.LoadSavepointReceiverID, partID, -1))
+			colBuilders = nil
+			numRows = 0
+		}
+	}
+	if len(batches) == 0 {
+		return nil
+	}
+	ch := make(chan error, 1)
+	fut := common.NewCountDownFuture(len(batches), func(err error) {
+		ch <- err
+	})
+	for _, batch := range batches {
+		// The state is replicated, like any other data ingested into a processor
+		sm.processorManager.ForwardBatch(batch, true, fut.CountDown)
+	}
+	return <-ch
+}
+
+type savepointReceiver struct {
+	schema *OperatorSchema
+	sm     *streamManager
+}
+
+func (s *savepointReceiver) InSchema() *OperatorSchema {
+	return s.schema
+}
+
+func (s *savepointReceiver) OutSchema() *OperatorSchema {
+	panic("should not be called")
+}
+
+func (s *savepointReceiver) ForwardingProcessorCount() int {
+	panic("should not be called")
+}
+
+// ReceiveBatch creates an iterator for each partition in the batch, as of the version of the savepoint, and then
+// writes the partitions to the object store on another goroutine so that the processor is not blocked while they are
+// uploaded. The batch is completed when all the partitions have been written.
+func (s *savepointReceiver) ReceiveBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	deferrer, ok := execCtx.(interface{ DeferCompletion() func(error) })
+	if !ok {
+		return nil, errors.New("savepoint batch cannot be completed asynchronously")
+	}
+	dumps := make([]partitionDump, 0, batch.RowCount)
+	for i := 0; i < batch.RowCount; i++ {
+		prefix := batch.GetStringColumn(0).Get(i)
+		slabIndex := int(batch.GetIntColumn(1).Get(i))
+		slabID := batch.GetIntColumn(2).Get(i)
+		mappingID := batch.GetStringColumn(3).Get(i)
+		partID := int(batch.GetIntColumn(4).Get(i))
+		version := batch.GetIntColumn(5).Get(i)
+		partitionHash := proc.CalcPartitionHash(mappingID, uint64(partID))
+		keyStart := encoding.EncodeEntryPrefix(partitionHash, uint64(slabID), 24)
+		keyEnd := common.IncBigEndianBytes(keyStart)
+		// The iterator is created here, on the processor, and only sees the versions up to that of the savepoint, so
+		// writes made to the partition while it is being uploaded are not included
+		iter, err := execCtx.Processor().NewIterator(keyStart, keyEnd, uint64(version), false)
+		if err != nil {
+			for _, dump := range dumps {
+				dump.iter.Close()
+			}
+			return nil, err
+		}
+		dumps = append(dumps, partitionDump{
+			prefix:    prefix,
+			slabIndex: slabIndex,
+			partID:    partID,
+			iter:      iter,
+		})
+	}
+	completionFunc := deferrer.DeferCompletion()
+	go func() {
+		var err error
+		for _, dump := range dumps {
+			if err == nil {
+				err = s.dumpPartition(dump)
+			}
+			dump.iter.Close()
+		}
+		completionFunc(err)
+	}()
+	return nil, nil
+}
+
+type partitionDump struct {
+	prefix    string
+	slabIndex int
+	partID    int
+	iter      iteration.Iterator
+}
+
+// dumpPartition writes the entries of the partition in chunks of at most SavepointChunkMaxSizeBytes plus one entry.
+// The partition hash and slab id prefix and the version are removed from the keys as they are different when the
+// savepoint is loaded.
+func (s *savepointReceiver) dumpPartition(dump partitionDump) error {
+	maxSize := int(s.sm.cfg.SavepointChunkMaxSizeBytes)
+	chunk := 0
+	buff := []byte{savepointLastChunk}
+	for {
+		valid, curr, err := dump.iter.Next()
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return s.putChunk(dump.prefix, dump.slabIndex, dump.partID, chunk, buff)
+		}
+		if len(buff) > maxSize {
+			// There is another entry, so this is not the last chunk
+			buff[0] = savepointMoreChunks
+			if err := s.putChunk(dump.prefix, dump.slabIndex, dump.partID, chunk, buff); err != nil {
+				return err
+			}
+			chunk++
+			buff = []byte{savepointLastChunk}
+		}
+		buff = encoding.AppendBytesToBufferLE(buff, curr.Key[24:len(curr.Key)-8])
+		buff = encoding.AppendBytesToBufferLE(buff, curr.Value)
+	}
+}
+
+func (s *savepointReceiver) putChunk(prefix string, slabIndex int, partID int, chunk int, buff []byte) error {
+	return objstore.PutWithTimeout(s.sm.objStoreClient, s.sm.cfg.BucketName,
+		savepointChunkObjectName(prefix, slabIndex, partID, chunk), buff, s.sm.cfg.SavepointCallTimeout)
+}
+
+func (s *savepointReceiver) ReceiveBarrier(StreamExecContext) error {
+	return nil
+}
+
+func (s *savepointReceiver) RequiresBarriersInjection() bool {
+	return false
+}
+
+type loadSavepointReceiver struct {
+	schema *OperatorSchema
+}
+
+func (l *loadSavepointReceiver) InSchema() *OperatorSchema {
+	return l.schema
+}
+
+func (l *loadSavepointReceiver) OutSchema() *OperatorSchema {
+	panic("should not be called")
+}
+
+func (l *loadSavepointReceiver) ForwardingProcessorCount() int {
+	panic("should not be called")
+}
+
+func (l *loadSavepointReceiver) ReceiveBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	for i := 0; i < batch.RowCount; i++ {
+		key := common.ByteSliceCopy(batch.GetBytesColumn(0).Get(i))
+		execCtx.StoreEntry(common.KV{
+			Key:   encoding.EncodeVersion(key, uint64(execCtx.WriteVersion())),
+			Value: batch.GetBytesColumn(1).Get(i),
+		}, false)
+	}
+	return nil, nil
+}
+
+func (l *loadSavepointReceiver) ReceiveBarrier(StreamExecContext) error {
+	return nil
+}
+
+func (l *loadSavepointReceiver) RequiresBarriersInjection() bool {
+	return false
+}
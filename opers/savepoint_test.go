@@ -0,0 +1,255 @@
+package opers
+
+import (
+	"context"
+	"fmt"
+	"github.com/spirit-labs/tektite/asl/conf"
+	"github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/proc"
+	"github.com/spirit-labs/tektite/testutils"
+	"github.com/spirit-labs/tektite/tppm"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestSavepointAndLoad(t *testing.T) {
+	mgr, pm := setupSavepointManager()
+	defer pm.Close()
+
+	columnNames := []string{"f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeString}
+	keyColTypes := []types.ColumnType{types.ColumnTypeInt}
+	rowColTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeString}
+	deployStream(t, `test_stream1 := (store table by f1)`, mgr, columnNames, columnTypes, true, false)
+	info1 := mgr.GetStream("test_stream1")
+	require.NotNil(t, info1)
+
+	pm.SetWriteVersion(100)
+	injectBatch(t, "test_stream1", 0, 0, [][]any{
+		{int64(0), int64(10), "foo1"},
+		{int64(1), int64(5), "foo2"},
+	}, mgr, pm)
+	expectedOut := [][]any{
+		{int64(1), int64(5), "foo2"},
+		{int64(0), int64(10), "foo1"},
+	}
+	verifyRowsInTablePartition(t, keyColTypes, []int{1}, rowColTypes, []int{0, 2}, expectedOut, "test_stream1",
+		info1.UserSlab.SlabID, 0, pm.GetStore())
+
+	// Written after the version of the savepoint, so not included
+	pm.SetWriteVersion(101)
+	injectBatch(t, "test_stream1", 0, 0, [][]any{
+		{int64(2), int64(5), "foo3"},
+		{int64(3), int64(7), "foo4"},
+	}, mgr, pm)
+
+	err := mgr.SavepointStream(createSavepointDesc(t, `savepoint stream test_stream1 to "savepoints/sp1"`), 100)
+	require.NoError(t, err)
+
+	// The new stream has different slab ids and partition hashes. The state is loaded before the stream is deployed
+	streamDesc := createTestStreamDesc(t, `test_stream2 := (store table by f1) from savepoint "savepoints/sp1"`,
+		columnNames, columnTypes, true, false)
+	loadedStream, err := mgr.LoadSavepoint(streamDesc, []int{5000}, []int{5000})
+	require.NoError(t, err)
+	require.Nil(t, mgr.GetStream("test_stream2"))
+	verifyRowsInTablePartition(t, keyColTypes, []int{1}, rowColTypes, []int{0, 2}, expectedOut, "test_stream2",
+		5000, 0, pm.GetStore())
+	require.NoError(t, mgr.DeployLoadedStream(loadedStream, "", 124))
+	info2 := mgr.GetStream("test_stream2")
+	require.NotNil(t, info2)
+	require.NotEqual(t, info1.UserSlab.SlabID, info2.UserSlab.SlabID)
+	verifyRowsInTablePartition(t, keyColTypes, []int{1}, rowColTypes, []int{0, 2}, expectedOut, "test_stream2",
+		info2.UserSlab.SlabID, 0, pm.GetStore())
+}
+
+func TestSavepointErrors(t *testing.T) {
+	mgr, pm := setupSavepointManager()
+	defer pm.Close()
+
+	err := mgr.SavepointStream(createSavepointDesc(t, `savepoint stream test_stream1 to "savepoints/sp1"`), 100)
+	require.Error(t, err)
+	require.Equal(t, `unknown stream 'test_stream1' (line 1 column 18):
+savepoint stream test_stream1 to "savepoints/sp1"
+                 ^`, err.Error())
+
+	columnNames := []string{"f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeString}
+	deployStream(t, `test_stream1 := (filter by f1 > 10)`, mgr, columnNames, columnTypes, true, false)
+	err = mgr.SavepointStream(createSavepointDesc(t, `savepoint stream test_stream1 to "savepoints/sp1"`), 100)
+	require.Error(t, err)
+	require.Equal(t, `stream 'test_stream1' does not have any state to savepoint (line 1 column 18):
+savepoint stream test_stream1 to "savepoints/sp1"
+                 ^`, err.Error())
+
+	deployStream(t, `test_stream2 := (store table by f1)`, mgr, columnNames, columnTypes, true, false)
+	err = mgr.SavepointStream(createSavepointDesc(t, `savepoint stream test_stream2 to "savepoints/sp1"`), 100)
+	require.NoError(t, err)
+
+	streamDesc := createTestStreamDesc(t, `test_stream3 := (store table by f1) from savepoint "savepoints/sp2"`,
+		columnNames, columnTypes, true, false)
+	_, err = mgr.LoadSavepoint(streamDesc, []int{5000}, []int{5000})
+	require.Error(t, err)
+	require.Equal(t, `savepoint 'savepoints/sp2' does not exist or is incomplete (line 1 column 42):
+test_stream3 := (store table by f1) from savepoint "savepoints/sp2"
+                                         ^`, err.Error())
+
+	streamDesc = createTestStreamDesc(t, `test_stream4 := (store table by f0, f1) from savepoint "savepoints/sp1"`,
+		[]string{"f0", "f1"}, []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeInt}, true, false)
+	_, err = mgr.LoadSavepoint(streamDesc, []int{5001}, []int{5001})
+	require.Error(t, err)
+	require.Equal(t, `savepoint 'savepoints/sp1' is not compatible with stream 'test_stream4' - slab 0 has a different schema or number of partitions (line 1 column 46):
+test_stream4 := (store table by f0, f1) from savepoint "savepoints/sp1"
+                                             ^`, err.Error())
+
+	streamDesc = createTestStreamDesc(t, `alter test_stream2 := (store table by f1) from savepoint "savepoints/sp1"`,
+		columnNames, columnTypes, true, false)
+	_, err = mgr.LoadSavepoint(streamDesc, []int{5002}, []int{5002})
+	require.Error(t, err)
+	require.Equal(t, `a savepoint can only be loaded when a stream is created, not when it is altered (line 1 column 48):
+alter test_stream2 := (store table by f1) from savepoint "savepoints/sp1"
+                                               ^`, err.Error())
+	err = mgr.AlterStream(streamDesc, []int{5002}, []int{5002}, "", 126)
+	require.Error(t, err)
+	require.Equal(t, `a savepoint can only be loaded when a stream is created, not when it is altered (line 1 column 48):
+alter test_stream2 := (store table by f1) from savepoint "savepoints/sp1"
+                                               ^`, err.Error())
+
+	// The state is not loaded into a stream which already exists
+	streamDesc = createTestStreamDesc(t, `test_stream2 := (store table by f1) from savepoint "savepoints/sp1"`,
+		columnNames, columnTypes, true, false)
+	_, err = mgr.LoadSavepoint(streamDesc, []int{5003}, []int{5003})
+	require.Error(t, err)
+	require.Equal(t, `stream 'test_stream2' already exists (line 1 column 1):
+test_stream2 := (store table by f1) from savepoint "savepoints/sp1"
+^`, err.Error())
+}
+
+func TestSavepointChunks(t *testing.T) {
+	mgr, pm := setupSavepointManager()
+	defer pm.Close()
+	// Each chunk holds one entry
+	mgr.cfg.SavepointChunkMaxSizeBytes = 1
+
+	columnNames := []string{"f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeString}
+	keyColTypes := []types.ColumnType{types.ColumnTypeInt}
+	rowColTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeString}
+	deployStream(t, `test_stream1 := (store table by f1)`, mgr, columnNames, columnTypes, true, false)
+
+	pm.SetWriteVersion(100)
+	injectBatch(t, "test_stream1", 0, 0, [][]any{
+		{int64(0), int64(10), "foo1"},
+		{int64(1), int64(5), "foo2"},
+		{int64(2), int64(7), "foo3"},
+	}, mgr, pm)
+	expectedOut := [][]any{
+		{int64(1), int64(5), "foo2"},
+		{int64(2), int64(7), "foo3"},
+		{int64(0), int64(10), "foo1"},
+	}
+	err := mgr.SavepointStream(createSavepointDesc(t, `savepoint stream test_stream1 to "savepoints/sp1"`), 100)
+	require.NoError(t, err)
+
+	objStore := mgr.objStoreClient.(*dev.InMemStore)
+	for chunk := 0; chunk < 3; chunk++ {
+		buff, err := objStore.Get(context.Background(), mgr.cfg.BucketName,
+			savepointChunkObjectName("savepoints/sp1", 0, 0, chunk))
+		require.NoError(t, err)
+		require.NotNil(t, buff)
+		if chunk < 2 {
+			require.Equal(t, savepointMoreChunks, buff[0])
+		} else {
+			require.Equal(t, savepointLastChunk, buff[0])
+		}
+	}
+	buff, err := objStore.Get(context.Background(), mgr.cfg.BucketName,
+		savepointChunkObjectName("savepoints/sp1", 0, 0, 3))
+	require.NoError(t, err)
+	require.Nil(t, buff)
+
+	streamDesc := createTestStreamDesc(t, `test_stream2 := (store table by f1) from savepoint "savepoints/sp1"`,
+		columnNames, columnTypes, true, false)
+	_, err = mgr.LoadSavepoint(streamDesc, []int{5000}, []int{5000})
+	require.NoError(t, err)
+	verifyRowsInTablePartition(t, keyColTypes, []int{1}, rowColTypes, []int{0, 2}, expectedOut, "test_stream2",
+		5000, 0, pm.GetStore())
+
+	// A missing chunk means the savepoint is corrupt
+	objectName := savepointChunkObjectName("savepoints/sp1", 0, 0, 1)
+	require.NoError(t, objStore.Delete(context.Background(), mgr.cfg.BucketName, objectName))
+	_, err = mgr.LoadSavepoint(streamDesc, []int{5001}, []int{5001})
+	require.Error(t, err)
+	require.Equal(t, fmt.Sprintf("savepoint 'savepoints/sp1' is corrupt - object '%s' is missing", objectName),
+		err.Error())
+}
+
+func TestSavepointLoadedStreamDiscarded(t *testing.T) {
+	mgr, pm := setupSavepointManager()
+	defer pm.Close()
+
+	columnNames := []string{"f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeInt, types.ColumnTypeString}
+	keyColTypes := []types.ColumnType{types.ColumnTypeInt}
+	rowColTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeString}
+	deployStream(t, `test_stream1 := (store table by f1)`, mgr, columnNames, columnTypes, true, false)
+	pm.SetWriteVersion(100)
+	injectBatch(t, "test_stream1", 0, 0, [][]any{
+		{int64(0), int64(10), "foo1"},
+	}, mgr, pm)
+	err := mgr.SavepointStream(createSavepointDesc(t, `savepoint stream test_stream1 to "savepoints/sp1"`), 100)
+	require.NoError(t, err)
+
+	streamDesc := createTestStreamDesc(t, `test_stream2 := (store table by f1) from savepoint "savepoints/sp1"`,
+		columnNames, columnTypes, true, false)
+	loadedStream, err := mgr.LoadSavepoint(streamDesc, []int{5000}, []int{5000})
+	require.NoError(t, err)
+	verifyRowsInTablePartition(t, keyColTypes, []int{1}, rowColTypes, []int{0, 2},
+		[][]any{{int64(0), int64(10), "foo1"}}, "test_stream2", 5000, 0, pm.GetStore())
+
+	// If the stream is not deployed, the state which was loaded is deleted
+	mgr.DiscardLoadedStream(loadedStream)
+	partitionHash := proc.CalcPartitionHash(loadedStream.info.UserSlab.Schema.MappingID, 0)
+	keyStart := encoding.EncodeEntryPrefix(partitionHash, 5000, 24)
+	testutils.WaitUntil(t, func() (bool, error) {
+		iter, err := pm.GetStore().NewIterator(keyStart, common.IncBigEndianBytes(keyStart), math.MaxInt64, false)
+		if err != nil {
+			return false, err
+		}
+		defer iter.Close()
+		valid, _, err := iter.Next()
+		return !valid, err
+	})
+	require.Nil(t, mgr.GetStream("test_stream2"))
+}
+
+func setupSavepointManager() (*streamManager, *tppm.TestProcessorManager) {
+	mgr, pm := createManager()
+	mgr.objStoreClient = dev.NewInMemStore(0)
+	mgr.SetProcessorManager(&testSavepointForwarder{TestProcessorManager: pm})
+	pm.SetBatchHandler(mgr)
+	for procID := 0; procID < conf.DefaultProcessorCount; procID++ {
+		pm.AddActiveProcessor(procID)
+	}
+	return mgr, pm
+}
+
+func createSavepointDesc(t *testing.T, tsl string) parser.SavepointDesc {
+	ast, err := parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	require.NotNil(t, ast.Savepoint)
+	return *ast.Savepoint
+}
+
+// testSavepointForwarder delivers forwarded batches to the local test processors
+type testSavepointForwarder struct {
+	*tppm.TestProcessorManager
+}
+
+func (t *testSavepointForwarder) ForwardBatch(batch *proc.ProcessBatch, _ bool, completionFunc func(error)) {
+	t.GetProcessor(batch.ProcessorID).IngestBatch(batch, completionFunc)
+}
@@ -1,97 +1,179 @@
 package opers
 
 import (
+	"bytes"
 	"github.com/spirit-labs/tektite/asl/encoding"
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/evbatch"
 	"github.com/spirit-labs/tektite/proc"
+	"github.com/spirit-labs/tektite/types"
+)
+
+// ChangeTypeColName is the name of the column added to the output of a table with changelog enabled. It holds one of
+// the ChangeType* values for each row.
+const ChangeTypeColName = "change_type"
+
+// RowDeleteColName is the name of an optional bool column in the input of a table with changelog enabled. Rows with
+// the column set to true delete the row with the same key from the table.
+const RowDeleteColName = "row_delete"
+
+const (
+	ChangeTypeInsert       = "insert"
+	ChangeTypeUpdateBefore = "update_before"
+	ChangeTypeUpdateAfter  = "update_after"
+	ChangeTypeDelete       = "delete"
 )
 
 type StoreTableOperator struct {
 	BaseOperator
-	inSchema   *OperatorSchema
-	outSchema  *OperatorSchema
-	inKeyCols  []int
-	outKeyCols []int
-	rowCols    []int
-	outRowCols []int
-	keyPrecfix []byte
-	nodeID     int
-	hasKey     bool
-	slabID     uint64
-	hasOffset  bool
-	hashCache  *partitionHashCache
+	inSchema           *OperatorSchema
+	outSchema          *OperatorSchema
+	tableSchema        *OperatorSchema
+	inKeyCols          []int
+	outKeyCols         []int
+	rowCols            []int
+	outRowCols         []int
+	keyColTypes        []types.ColumnType
+	rowColTypes        []types.ColumnType
+	keyPrecfix         []byte
+	nodeID             int
+	hasKey             bool
+	slabID             uint64
+	hasOffset          bool
+	changelog          bool
+	rowDeleteColIndex  int
+	changeTypeColIndex int
+	hashCache          *partitionHashCache
 }
 
-func NewStoreTableOperator(schema *OperatorSchema, slabID int, keyCols []string, nodeID int,
+func NewStoreTableOperator(schema *OperatorSchema, slabID int, keyCols []string, changelog bool, nodeID int,
 	desc errMsgAtPositionProvider) (*StoreTableOperator, error) {
+	colMap := createInColIndexMap(schema.EventSchema)
+	rowDeleteColIndex := -1
+	if changelog {
+		if len(keyCols) == 0 {
+			return nil, statementErrorAtTokenNamef("changelog", desc, "'changelog' can only be used on a table with key columns")
+		}
+		if _, ok := colMap[ChangeTypeColName]; ok {
+			return nil, statementErrorAtTokenNamef("changelog", desc, "cannot use 'changelog' - the incoming schema already has a column named '%s'",
+				ChangeTypeColName)
+		}
+		index, ok := colMap[RowDeleteColName]
+		if ok {
+			if schema.EventSchema.ColumnTypes()[index].ID() != types.ColumnTypeIDBool {
+				return nil, statementErrorAtTokenNamef("changelog", desc, "column '%s' must be of type bool", RowDeleteColName)
+			}
+			rowDeleteColIndex = index
+		}
+	}
+	// The table holds all incoming columns apart from the offset, and the row_delete column when in changelog mode
+	var tableColNames []string
+	var tableColTypes []types.ColumnType
+	tableColIndexes := make([]int, len(schema.EventSchema.ColumnNames()))
+	for i, colName := range schema.EventSchema.ColumnNames() {
+		if colName == OffsetColName || i == rowDeleteColIndex {
+			tableColIndexes[i] = -1
+			continue
+		}
+		tableColIndexes[i] = len(tableColNames)
+		tableColNames = append(tableColNames, colName)
+		tableColTypes = append(tableColTypes, schema.EventSchema.ColumnTypes()[i])
+	}
 	var inKeyCols []int
 	var outKeyCols []int
-	var rowCols []int
-	var outRowCols []int
-	colMap := createInColIndexMap(schema.EventSchema)
-	hasOffset := HasOffsetColumn(schema.EventSchema)
+	var keyColTypes []types.ColumnType
+	keyColSet := make(map[string]struct{}, len(keyCols))
 	for _, keyCol := range keyCols {
 		index, ok := colMap[keyCol]
 		if !ok {
 			return nil, statementErrorAtTokenNamef("", desc, "cannot use key column '%s' - it is not a known column in the incoming schema",
 				keyCol)
 		}
-		inKeyCols = append(inKeyCols, index)
-		if hasOffset {
-			// no offset in output
-			outKeyCols = append(outKeyCols, index-1)
-		} else {
-			outKeyCols = append(outKeyCols, index)
+		if index == rowDeleteColIndex {
+			return nil, statementErrorAtTokenNamef("", desc, "cannot use column '%s' as a key column when 'changelog' is enabled",
+				RowDeleteColName)
 		}
-	}
-	keyColSet := make(map[string]struct{}, len(keyCols))
-	for _, keyCol := range keyCols {
+		inKeyCols = append(inKeyCols, index)
+		outKeyCols = append(outKeyCols, tableColIndexes[index])
+		keyColTypes = append(keyColTypes, schema.EventSchema.ColumnTypes()[index])
 		keyColSet[keyCol] = struct{}{}
 	}
+	var rowCols []int
+	var outRowCols []int
+	var rowColTypes []types.ColumnType
 	for i, colName := range schema.EventSchema.ColumnNames() {
 		_, ok := keyColSet[colName]
-		if !ok {
-			if colName != OffsetColName {
-				// Note, we do not store the offset column in a table
-				rowCols = append(rowCols, i)
-				if hasOffset {
-					outRowCols = append(outRowCols, i-1)
-				} else {
-					outRowCols = append(outRowCols, i)
-				}
-			}
+		// Note, we do not store the offset column in a table
+		if !ok && tableColIndexes[i] != -1 {
+			rowCols = append(rowCols, i)
+			outRowCols = append(outRowCols, tableColIndexes[i])
+			rowColTypes = append(rowColTypes, schema.EventSchema.ColumnTypes()[i])
 		}
 	}
-	var outSchema *OperatorSchema
-	if hasOffset {
-		// Remove the offset column - we do not store this in the table
-		outEvSchema := evbatch.NewEventSchema(schema.EventSchema.ColumnNames()[1:], schema.EventSchema.ColumnTypes()[1:])
-		outSchema = schema.Copy()
-		outSchema.EventSchema = outEvSchema
+	if changelog && len(rowCols) == 0 {
+		return nil, statementErrorAtTokenNamef("changelog", desc, "'changelog' requires at least one column that is not a key column")
+	}
+	var tableSchema *OperatorSchema
+	if len(tableColNames) != len(schema.EventSchema.ColumnNames()) {
+		tableSchema = schema.Copy()
+		tableSchema.EventSchema = evbatch.NewEventSchema(tableColNames, tableColTypes)
 	} else {
-		outSchema = schema
+		tableSchema = schema
+	}
+	outSchema := tableSchema
+	changeTypeColIndex := -1
+	if changelog {
+		// The changelog is the table columns followed by the change type
+		changeTypeColIndex = len(tableColNames)
+		outSchema = tableSchema.Copy()
+		outSchema.EventSchema = evbatch.NewEventSchema(append(tableColNames, ChangeTypeColName),
+			append(tableColTypes, types.ColumnTypeString))
 	}
 	return &StoreTableOperator{
-		inSchema:   schema,
-		outSchema:  outSchema,
-		inKeyCols:  inKeyCols,
-		outKeyCols: outKeyCols,
-		rowCols:    rowCols,
-		outRowCols: outRowCols,
-		nodeID:     nodeID,
-		hasKey:     len(keyCols) > 0,
-		slabID:     uint64(slabID),
-		hasOffset:  hasOffset,
-		hashCache:  newPartitionHashCache(schema.MappingID, schema.Partitions),
+		inSchema:           schema,
+		outSchema:          outSchema,
+		tableSchema:        tableSchema,
+		inKeyCols:          inKeyCols,
+		outKeyCols:         outKeyCols,
+		rowCols:            rowCols,
+		outRowCols:         outRowCols,
+		keyColTypes:        keyColTypes,
+		rowColTypes:        rowColTypes,
+		nodeID:             nodeID,
+		hasKey:             len(keyCols) > 0,
+		slabID:             uint64(slabID),
+		hasOffset:          HasOffsetColumn(schema.EventSchema),
+		changelog:          changelog,
+		rowDeleteColIndex:  rowDeleteColIndex,
+		changeTypeColIndex: changeTypeColIndex,
+		hashCache:          newPartitionHashCache(schema.MappingID, schema.Partitions),
 	}, nil
 }
 
+func isChangelogTable(oper Operator) bool {
+	to, ok := oper.(*StoreTableOperator)
+	return ok && to.changelog
+}
+
+// changeTypeColIndex returns the index of the change type column if the schema is the changelog of a table, otherwise
+// -1
+func changeTypeColIndex(schema *evbatch.EventSchema) int {
+	for i, colName := range schema.ColumnNames() {
+		if colName == ChangeTypeColName && schema.ColumnTypes()[i].ID() == types.ColumnTypeIDString {
+			return i
+		}
+	}
+	return -1
+}
+
 func (s *StoreTableOperator) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
 	panic("not supported in queries")
 }
 
 func (s *StoreTableOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	if s.changelog {
+		return s.handleChangelogBatch(batch, execCtx)
+	}
 	s.storeBatchInTable(batch, execCtx)
 	if s.hasOffset {
 		// remove offset col
@@ -123,6 +205,81 @@ func (s *StoreTableOperator) storeBatchInTable(batch *evbatch.Batch, execCtx Str
 	}
 }
 
+// handleChangelogBatch upserts or deletes each incoming row and sends the resulting changes downstream. An update is
+// sent as the previous row with change type update_before followed by the new row with change type update_after, so
+// downstream operators can retract the previous values.
+func (s *StoreTableOperator) handleChangelogBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	prefix := s.createTableKeyPrefix(s.slabID, execCtx.PartitionID(), 64)
+	var rowDeleteCol *evbatch.BoolColumn
+	if s.rowDeleteColIndex != -1 {
+		rowDeleteCol = batch.GetBoolColumn(s.rowDeleteColIndex)
+	}
+	colBuilders := evbatch.CreateColBuilders(s.outSchema.EventSchema.ColumnTypes())
+	// Rows written earlier in the batch are not visible with execCtx.Get, so we keep track of them here
+	writtenInBatch := map[string][]byte{}
+	for i := 0; i < batch.RowCount; i++ {
+		key := common.ByteSliceCopy(prefix)
+		key = evbatch.EncodeKeyCols(batch, i, s.inKeyCols, key)
+		prev, ok := writtenInBatch[string(key)]
+		if !ok {
+			var err error
+			prev, err = execCtx.Get(key)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var row []byte
+		if rowDeleteCol != nil && !rowDeleteCol.IsNull(i) && rowDeleteCol.Get(i) {
+			if len(prev) == 0 {
+				// Nothing to delete
+				continue
+			}
+			if err := s.appendChange(colBuilders, key, prev, ChangeTypeDelete); err != nil {
+				return nil, err
+			}
+		} else {
+			row = make([]byte, 0, rowInitialBufferSize)
+			row = evbatch.EncodeRowCols(batch, i, s.rowCols, row)
+			if len(prev) == 0 {
+				if err := s.appendChange(colBuilders, key, row, ChangeTypeInsert); err != nil {
+					return nil, err
+				}
+			} else {
+				if bytes.Equal(prev, row) {
+					// Row is unchanged
+					continue
+				}
+				if err := s.appendChange(colBuilders, key, prev, ChangeTypeUpdateBefore); err != nil {
+					return nil, err
+				}
+				if err := s.appendChange(colBuilders, key, row, ChangeTypeUpdateAfter); err != nil {
+					return nil, err
+				}
+			}
+		}
+		writtenInBatch[string(key)] = row
+		// A nil value is a tombstone
+		execCtx.StoreEntry(common.KV{
+			Key:   encoding.EncodeVersion(key, uint64(execCtx.WriteVersion())),
+			Value: row,
+		}, false)
+	}
+	out := evbatch.NewBatchFromBuilders(s.outSchema.EventSchema, colBuilders...)
+	if out.RowCount == 0 {
+		return out, nil
+	}
+	return out, s.sendBatchDownStream(out, execCtx)
+}
+
+func (s *StoreTableOperator) appendChange(colBuilders []evbatch.ColumnBuilder, key []byte, row []byte, changeType string) error {
+	if err := LoadColsFromKey(colBuilders, s.keyColTypes, s.outKeyCols, key); err != nil {
+		return err
+	}
+	LoadColsFromValue(colBuilders, s.rowColTypes, s.outRowCols, row)
+	colBuilders[s.changeTypeColIndex].(*evbatch.StringColBuilder).Append(changeType)
+	return nil
+}
+
 func (s *StoreTableOperator) createTableKeyPrefix(slabID uint64, partID int, cap int) []byte {
 	partitionHash := s.hashCache.getHash(partID)
 	return encoding.EncodeEntryPrefix(partitionHash, slabID, cap)
@@ -69,7 +69,7 @@ func TestOffsetRemoved(t *testing.T) {
 
 func createTableOperator(t *testing.T, keyCols []string, columnNamesIn []string, columnTypesIn []types.ColumnType) *StoreTableOperator {
 	inSchema := evbatch.NewEventSchema(columnNamesIn, columnTypesIn)
-	to, err := NewStoreTableOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}}, 1001, keyCols, -1,
+	to, err := NewStoreTableOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}}, 1001, keyCols, false, -1,
 		&parser.StoreTableDesc{})
 	require.NoError(t, err)
 	return to
@@ -135,3 +135,101 @@ func testTableOperator(t *testing.T, to *StoreTableOperator, columnNamesIn []str
 		require.Equal(t, expectedOutData, loadedOutData)
 	}
 }
+
+func TestTableOperatorChangelog(t *testing.T) {
+	fNames := []string{"offset", "event_time", "id", "balance", "row_delete"}
+	fTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeInt,
+		types.ColumnTypeBool}
+	inSchema := evbatch.NewEventSchema(fNames, fTypes)
+	to, err := NewStoreTableOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}}, 1001,
+		[]string{"id"}, true, -1, &parser.StoreTableDesc{})
+	require.NoError(t, err)
+
+	// The table does not store the offset or row_delete columns
+	require.Equal(t, evbatch.NewEventSchema([]string{"event_time", "id", "balance"},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeInt}), to.tableSchema.EventSchema)
+	require.Equal(t, evbatch.NewEventSchema([]string{"event_time", "id", "balance", "change_type"},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeInt, types.ColumnTypeString}),
+		to.OutSchema().EventSchema)
+
+	ctx := &testExecCtx{
+		version: 1234,
+		stored:  map[string][]byte{},
+	}
+	dataIn := [][]any{
+		{int64(0), types.NewTimestamp(1000), "acc1", int64(100), nil},
+		{int64(1), types.NewTimestamp(1001), "acc2", int64(200), false},
+		{int64(2), types.NewTimestamp(1002), "acc1", int64(150), nil},
+		// unchanged, so there is no change
+		{int64(3), types.NewTimestamp(1002), "acc1", int64(150), nil},
+		// deleting a row that does not exist is a no-op
+		{int64(4), types.NewTimestamp(1003), "acc3", nil, true},
+	}
+	out := handleChangelogBatch(t, to, ctx, fNames, fTypes, dataIn)
+	require.Equal(t, [][]any{
+		{types.NewTimestamp(1000), "acc1", int64(100), "insert"},
+		{types.NewTimestamp(1001), "acc2", int64(200), "insert"},
+		{types.NewTimestamp(1000), "acc1", int64(100), "update_before"},
+		{types.NewTimestamp(1002), "acc1", int64(150), "update_after"},
+	}, out)
+	require.Equal(t, 3, len(ctx.entries))
+
+	// Changes are made against the rows stored by the previous batch
+	dataIn = [][]any{
+		{int64(5), types.NewTimestamp(1004), "acc2", nil, true},
+		{int64(6), types.NewTimestamp(1005), "acc1", int64(175), nil},
+		{int64(7), types.NewTimestamp(1006), "acc2", int64(50), nil},
+	}
+	out = handleChangelogBatch(t, to, ctx, fNames, fTypes, dataIn)
+	require.Equal(t, [][]any{
+		{types.NewTimestamp(1001), "acc2", int64(200), "delete"},
+		{types.NewTimestamp(1002), "acc1", int64(150), "update_before"},
+		{types.NewTimestamp(1005), "acc1", int64(175), "update_after"},
+		{types.NewTimestamp(1006), "acc2", int64(50), "insert"},
+	}, out)
+	// The delete is stored as a tombstone
+	require.Equal(t, 3, len(ctx.entries))
+	require.Nil(t, ctx.entries[0].Value)
+}
+
+func TestTableOperatorChangelogInvalidSchema(t *testing.T) {
+	createChangelogTable := func(keyCols []string, fNames []string, fTypes []types.ColumnType) error {
+		inSchema := evbatch.NewEventSchema(fNames, fTypes)
+		_, err := NewStoreTableOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}}, 1001,
+			keyCols, true, -1, nil)
+		return err
+	}
+	err := createChangelogTable(nil, []string{"event_time", "id"}, []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString})
+	require.Error(t, err)
+	require.Equal(t, "'changelog' can only be used on a table with key columns", err.Error())
+
+	err = createChangelogTable([]string{"id"}, []string{"event_time", "id", "row_delete"},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeInt})
+	require.Error(t, err)
+	require.Equal(t, "column 'row_delete' must be of type bool", err.Error())
+
+	err = createChangelogTable([]string{"id"}, []string{"event_time", "id", "change_type"},
+		[]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeString})
+	require.Error(t, err)
+	require.Equal(t, "cannot use 'changelog' - the incoming schema already has a column named 'change_type'", err.Error())
+
+	err = createChangelogTable([]string{"id"}, []string{"id"}, []types.ColumnType{types.ColumnTypeString})
+	require.Error(t, err)
+	require.Equal(t, "'changelog' requires at least one column that is not a key column", err.Error())
+}
+
+// handleChangelogBatch sends the data to the table and returns the changelog. Entries written by the table are made
+// visible to the next batch.
+func handleChangelogBatch(t *testing.T, to *StoreTableOperator, ctx *testExecCtx, fNames []string, fTypes []types.ColumnType,
+	dataIn [][]any) [][]any {
+	ctx.entries = nil
+	batch := createEventBatch(fNames, fTypes, dataIn)
+	defer batch.Release()
+	out, err := to.HandleStreamBatch(batch, ctx)
+	require.NoError(t, err)
+	for _, kv := range ctx.entries {
+		// We remove the version as we don't look up based on that
+		ctx.stored[string(kv.Key[:len(kv.Key)-8])] = kv.Value
+	}
+	return convertBatchToAnyArray(out)
+}
@@ -0,0 +1,512 @@
+package opers
+
+import (
+	"bytes"
+	"fmt"
+	encoding2 "github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/expr"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"sort"
+	"strings"
+)
+
+const (
+	RankColName    = "rank"
+	RetractColName = "retract"
+)
+
+// TopNOperator maintains the top N rows, by the order expressions, for each group defined by the key expressions. It
+// must directly follow an aggregate, and a row is identified by the key columns of that aggregate.
+//
+// When following a non-windowed aggregate, each incoming row is an update to an aggregation. The rows of each group are
+// kept in a slab and the ranking is recomputed each time the group receives an update. Changes to the ranking are sent
+// downstream as rows with a 'rank' column and a 'retract' column - a retraction means the row no longer holds that rank.
+//
+// When following a windowed aggregate, incoming batches contain the final results of closed windows, so the ranking is
+// computed over the batch. If the aggregate includes the window columns they are part of the group and of the key of
+// the stored results, so each window has its own ranking and no state is kept. Otherwise, each closed window arrives in
+// its own batch, and the ranking of a group is replaced by its ranking in the latest window - the previous ranking is
+// kept in a slab so that it can be retracted.
+//
+// Each group must be wholly contained in one partition, so the stream should be partitioned by the group key before
+// the aggregate.
+type TopNOperator struct {
+	BaseOperator
+	inSchema            *OperatorSchema
+	outSchema           *OperatorSchema
+	storedSchema        *OperatorSchema
+	n                   int
+	keyExprs            []expr.Expression
+	keyTypes            []types.ColumnType
+	orderExprs          []expr.Expression
+	orderDesc           []bool
+	orderSchema         *evbatch.EventSchema
+	orderColIndexes     []int
+	windowColIndexes    []int
+	rowKeyColIndexes    []int
+	storedKeyColIndexes []int
+	inColIndexes        []int
+	storedRowColIndexes []int
+	rankColIndex        int
+	retractColIndex     int
+	windowed            bool
+	stateSlabID         uint64
+	resultsSlabID       uint64
+	storeResults        bool
+	hashCache           *partitionHashCache
+	nodeID              int
+}
+
+type topNEntry struct {
+	rowKey     []byte
+	row        []byte
+	orderBytes []byte
+	orderVals  []any
+}
+
+func NewTopNOperator(schema *OperatorSchema, desc *parser.TopNDesc, rowKeyColIndexes []int, windowed bool,
+	stateSlabID int, resultsSlabID int, storeResults bool, expressionFactory *expr.ExpressionFactory,
+	nodeID int) (*TopNOperator, error) {
+	inColNames := schema.EventSchema.ColumnNames()
+	inColTypes := schema.EventSchema.ColumnTypes()
+	for _, colName := range inColNames {
+		if colName == RankColName || colName == RetractColName {
+			return nil, statementErrorAtTokenNamef("", desc,
+				"input to 'topn' operator must not have a column named '%s'", colName)
+		}
+	}
+	var keyExprs []expr.Expression
+	var keyTypes []types.ColumnType
+	for _, keyExprDesc := range desc.KeyExprs {
+		e, err := expressionFactory.CreateExpression(keyExprDesc, schema.EventSchema)
+		if err != nil {
+			return nil, err
+		}
+		keyExprs = append(keyExprs, e)
+		keyTypes = append(keyTypes, e.ResultType())
+	}
+	orderExprs := make([]expr.Expression, len(desc.OrderExprs))
+	orderDesc := make([]bool, len(desc.OrderExprs))
+	orderColNames := make([]string, len(desc.OrderExprs))
+	orderColTypes := make([]types.ColumnType, len(desc.OrderExprs))
+	orderColIndexes := make([]int, len(desc.OrderExprs))
+	for i, orderExprDesc := range desc.OrderExprs {
+		orderExprDesc, ascDesc := parser.ExtractAscDesc(orderExprDesc)
+		if ascDesc == "desc" || ascDesc == "descending" {
+			orderDesc[i] = true
+		} else if ascDesc != "" && ascDesc != "asc" && ascDesc != "ascending" {
+			panic("invalid asc/desc")
+		}
+		e, err := expressionFactory.CreateExpression(orderExprDesc, schema.EventSchema)
+		if err != nil {
+			return nil, err
+		}
+		if types.IsNestedType(e.ResultType()) {
+			return nil, orderExprDesc.ErrorAtPosition("cannot order by an expression of type %s", e.ResultType().String())
+		}
+		orderExprs[i] = e
+		orderColNames[i] = fmt.Sprintf("order-%d", i)
+		orderColTypes[i] = e.ResultType()
+		orderColIndexes[i] = i
+	}
+	inColIndexes := make([]int, len(inColTypes))
+	for i := range inColTypes {
+		inColIndexes[i] = i
+	}
+	var windowColIndexes []int
+	if windowed {
+		for _, windowColName := range []string{windowStartColName, windowEndColName} {
+			for i, colName := range inColNames {
+				if colName == windowColName && inColTypes[i].ID() == types.ColumnTypeIDTimestamp {
+					windowColIndexes = append(windowColIndexes, i)
+				}
+			}
+		}
+	}
+	rowKeyColSet := make(map[int]struct{}, len(rowKeyColIndexes))
+	for _, rowKeyCol := range rowKeyColIndexes {
+		rowKeyColSet[rowKeyCol] = struct{}{}
+	}
+	// The stored table holds the incoming columns plus the rank, keyed by the window, if there is one, and the row key
+	var storedKeyColIndexes []int
+	for _, windowCol := range windowColIndexes {
+		if _, ok := rowKeyColSet[windowCol]; !ok {
+			storedKeyColIndexes = append(storedKeyColIndexes, windowCol)
+		}
+	}
+	storedKeyColIndexes = append(storedKeyColIndexes, rowKeyColIndexes...)
+	storedKeyColSet := make(map[int]struct{}, len(storedKeyColIndexes))
+	for _, storedKeyCol := range storedKeyColIndexes {
+		storedKeyColSet[storedKeyCol] = struct{}{}
+	}
+	var storedRowColIndexes []int
+	for i := range inColTypes {
+		if _, ok := storedKeyColSet[i]; !ok {
+			storedRowColIndexes = append(storedRowColIndexes, i)
+		}
+	}
+	rankColIndex := len(inColTypes)
+	storedRowColIndexes = append(storedRowColIndexes, rankColIndex)
+
+	storedColNames := append(append([]string{}, inColNames...), RankColName)
+	storedColTypes := append(append([]types.ColumnType{}, inColTypes...), types.ColumnTypeInt)
+	storedSchema := schema.Copy()
+	storedSchema.EventSchema = evbatch.NewEventSchema(storedColNames, storedColTypes)
+
+	outColNames := append(append([]string{}, storedColNames...), RetractColName)
+	outColTypes := append(append([]types.ColumnType{}, storedColTypes...), types.ColumnTypeBool)
+	outSchema := schema.Copy()
+	outSchema.EventSchema = evbatch.NewEventSchema(outColNames, outColTypes)
+
+	return &TopNOperator{
+		inSchema:            schema,
+		outSchema:           outSchema,
+		storedSchema:        storedSchema,
+		n:                   desc.N,
+		keyExprs:            keyExprs,
+		keyTypes:            keyTypes,
+		orderExprs:          orderExprs,
+		orderDesc:           orderDesc,
+		orderSchema:         evbatch.NewEventSchema(orderColNames, orderColTypes),
+		orderColIndexes:     orderColIndexes,
+		windowColIndexes:    windowColIndexes,
+		rowKeyColIndexes:    rowKeyColIndexes,
+		storedKeyColIndexes: storedKeyColIndexes,
+		inColIndexes:        inColIndexes,
+		storedRowColIndexes: storedRowColIndexes,
+		rankColIndex:        rankColIndex,
+		retractColIndex:     rankColIndex + 1,
+		windowed:            windowed,
+		stateSlabID:         uint64(stateSlabID),
+		resultsSlabID:       uint64(resultsSlabID),
+		storeResults:        storeResults,
+		hashCache:           newPartitionHashCache(schema.MappingID, schema.Partitions),
+		nodeID:              nodeID,
+	}, nil
+}
+
+func (t *TopNOperator) HandleStreamBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	outBatch, err := t.processBatch(batch, execCtx)
+	if err != nil {
+		return nil, err
+	}
+	if outBatch.RowCount == 0 {
+		return outBatch, nil
+	}
+	if t.storeResults {
+		t.storeChanges(outBatch, execCtx)
+	}
+	return outBatch, t.sendBatchDownStream(outBatch, execCtx)
+}
+
+func (t *TopNOperator) processBatch(batch *evbatch.Batch, execCtx StreamExecContext) (*evbatch.Batch, error) {
+	defer batch.Release()
+	keyCols := make([]evbatch.Column, len(t.keyExprs))
+	for i, keyExpr := range t.keyExprs {
+		col, err := expr.EvalColumn(keyExpr, batch)
+		if err != nil {
+			return nil, err
+		}
+		keyCols[i] = col
+	}
+	orderCols := make([]evbatch.Column, len(t.orderExprs))
+	for i, orderExpr := range t.orderExprs {
+		col, err := expr.EvalColumn(orderExpr, batch)
+		if err != nil {
+			return nil, err
+		}
+		orderCols[i] = col
+	}
+	orderBatch := &evbatch.Batch{
+		Schema:   t.orderSchema,
+		Columns:  orderCols,
+		RowCount: batch.RowCount,
+	}
+	// Group the incoming rows, we maintain the order in which the groups were first seen so the output is deterministic
+	var groupKeys []string
+	groups := map[string][]*topNEntry{}
+	for rowIndex := 0; rowIndex < batch.RowCount; rowIndex++ {
+		groupKey := make([]byte, 0, 32)
+		if len(t.windowColIndexes) > 0 {
+			// Rows from different windows are ranked separately
+			groupKey = evbatch.EncodeKeyCols(batch, rowIndex, t.windowColIndexes, groupKey)
+		}
+		for i, keyCol := range keyCols {
+			groupKey = evbatch.EncodeKeyCol(rowIndex, keyCol, t.keyTypes[i], groupKey)
+		}
+		entry := &topNEntry{
+			rowKey:     evbatch.EncodeKeyCols(batch, rowIndex, t.rowKeyColIndexes, make([]byte, 0, 32)),
+			row:        evbatch.EncodeRowCols(batch, rowIndex, t.inColIndexes, make([]byte, 0, rowInitialBufferSize)),
+			orderBytes: evbatch.EncodeRowCols(orderBatch, rowIndex, t.orderColIndexes, make([]byte, 0, 32)),
+		}
+		entry.orderVals, _ = encoding2.DecodeRowToSlice(entry.orderBytes, 0, t.orderSchema.ColumnTypes())
+		sKey := string(groupKey)
+		entries, ok := groups[sKey]
+		if !ok {
+			groupKeys = append(groupKeys, sKey)
+		}
+		groups[sKey] = append(entries, entry)
+	}
+	colBuilders := evbatch.CreateColBuilders(t.outSchema.EventSchema.ColumnTypes())
+	partitionHash := t.hashCache.getHash(execCtx.PartitionID())
+	for _, groupKey := range groupKeys {
+		entries := groups[groupKey]
+		if t.windowed && len(t.windowColIndexes) > 0 {
+			// The batch contains the final results for the window, so there is nothing to retract
+			t.sortEntries(entries)
+			t.appendChanges(nil, t.topN(entries), colBuilders)
+			continue
+		}
+		key := encoding2.EncodeEntryPrefix(partitionHash, t.stateSlabID, 24+len(groupKey))
+		key = append(key, groupKey...)
+		prev, err := t.loadGroup(key, execCtx)
+		if err != nil {
+			return nil, err
+		}
+		var curr []*topNEntry
+		if t.windowed {
+			// The ranking in the window replaces the ranking in the previous window, and as the results of a window
+			// are final only the ranked rows need to be kept
+			t.sortEntries(entries)
+			curr = t.topN(entries)
+		} else {
+			curr = mergeTopNEntries(prev, entries)
+			t.sortEntries(curr)
+		}
+		t.storeGroup(key, curr, execCtx)
+		t.appendChanges(t.topN(prev), t.topN(curr), colBuilders)
+	}
+	return evbatch.NewBatchFromBuilders(t.outSchema.EventSchema, colBuilders...), nil
+}
+
+// mergeTopNEntries returns the previous entries of the group with any updated rows replaced by their new values. If
+// a row is updated more than once in a batch, the last update wins.
+func mergeTopNEntries(prev []*topNEntry, updates []*topNEntry) []*topNEntry {
+	latest := make(map[string]int, len(updates))
+	for i, entry := range updates {
+		latest[string(entry.rowKey)] = i
+	}
+	merged := make([]*topNEntry, 0, len(prev)+len(updates))
+	for _, entry := range prev {
+		if _, updated := latest[string(entry.rowKey)]; !updated {
+			merged = append(merged, entry)
+		}
+	}
+	for i, entry := range updates {
+		if latest[string(entry.rowKey)] == i {
+			merged = append(merged, entry)
+		}
+	}
+	return merged
+}
+
+func (t *TopNOperator) topN(entries []*topNEntry) []*topNEntry {
+	if len(entries) > t.n {
+		return entries[:t.n]
+	}
+	return entries
+}
+
+// appendChanges compares the previous and current rankings and appends a retraction for each rank whose row has
+// changed, followed by the new row for that rank.
+func (t *TopNOperator) appendChanges(prev []*topNEntry, curr []*topNEntry, colBuilders []evbatch.ColumnBuilder) {
+	changed := func(i int) bool {
+		if i >= len(prev) || i >= len(curr) {
+			return true
+		}
+		return !bytes.Equal(prev[i].rowKey, curr[i].rowKey) || !bytes.Equal(prev[i].row, curr[i].row)
+	}
+	for i, entry := range prev {
+		if changed(i) {
+			t.appendEntry(entry, i+1, true, colBuilders)
+		}
+	}
+	for i, entry := range curr {
+		if changed(i) {
+			t.appendEntry(entry, i+1, false, colBuilders)
+		}
+	}
+}
+
+func (t *TopNOperator) appendEntry(entry *topNEntry, rank int, retract bool, colBuilders []evbatch.ColumnBuilder) {
+	LoadColsFromValue(colBuilders, t.inSchema.EventSchema.ColumnTypes(), t.inColIndexes, entry.row)
+	colBuilders[t.rankColIndex].(*evbatch.IntColBuilder).Append(int64(rank))
+	colBuilders[t.retractColIndex].(*evbatch.BoolColBuilder).Append(retract)
+}
+
+func (t *TopNOperator) sortEntries(entries []*topNEntry) {
+	// We use a stable sort so that, for equal order values, rows that were ranked first keep their rank
+	sort.SliceStable(entries, func(i, j int) bool {
+		for k, orderType := range t.orderSchema.ColumnTypes() {
+			diff := compareTopNValues(orderType, entries[i].orderVals[k], entries[j].orderVals[k])
+			if diff == 0 {
+				continue
+			}
+			if t.orderDesc[k] {
+				return diff > 0
+			}
+			return diff < 0
+		}
+		return false
+	})
+}
+
+// compareTopNValues compares two values of the same type, nulls are considered less than any other value, as with the
+// sort operator.
+func compareTopNValues(colType types.ColumnType, v1 any, v2 any) int {
+	if v1 == nil || v2 == nil {
+		if v1 == nil && v2 == nil {
+			return 0
+		}
+		if v1 == nil {
+			return -1
+		}
+		return 1
+	}
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		return compareOrdered(v1.(int64), v2.(int64))
+	case types.ColumnTypeIDFloat:
+		return compareOrdered(v1.(float64), v2.(float64))
+	case types.ColumnTypeIDBool:
+		b1, b2 := v1.(bool), v2.(bool)
+		if b1 == b2 {
+			return 0
+		}
+		if !b1 {
+			return -1
+		}
+		return 1
+	case types.ColumnTypeIDDecimal:
+		d1, d2 := v1.(types.Decimal), v2.(types.Decimal)
+		if d1.Num.Less(d2.Num) {
+			return -1
+		}
+		if d2.Num.Less(d1.Num) {
+			return 1
+		}
+		return 0
+	case types.ColumnTypeIDString:
+		return strings.Compare(v1.(string), v2.(string))
+	case types.ColumnTypeIDBytes:
+		return bytes.Compare(v1.([]byte), v2.([]byte))
+	case types.ColumnTypeIDTimestamp:
+		return compareOrdered(v1.(types.Timestamp).Val, v2.(types.Timestamp).Val)
+	default:
+		panic("unknown type")
+	}
+}
+
+func compareOrdered[T int64 | float64](v1 T, v2 T) int {
+	if v1 < v2 {
+		return -1
+	}
+	if v1 > v2 {
+		return 1
+	}
+	return 0
+}
+
+func (t *TopNOperator) loadGroup(key []byte, execCtx StreamExecContext) ([]*topNEntry, error) {
+	v, err := execCtx.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	numEntries, off := encoding2.ReadUint32FromBufferLE(v, 0)
+	entries := make([]*topNEntry, numEntries)
+	for i := range entries {
+		entry := &topNEntry{}
+		entry.rowKey, off = readTopNBytes(v, off)
+		entry.row, off = readTopNBytes(v, off)
+		entry.orderBytes, off = readTopNBytes(v, off)
+		entry.orderVals, _ = encoding2.DecodeRowToSlice(entry.orderBytes, 0, t.orderSchema.ColumnTypes())
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+func readTopNBytes(buff []byte, off int) ([]byte, int) {
+	var l uint32
+	l, off = encoding2.ReadUint32FromBufferLE(buff, off)
+	end := off + int(l)
+	return common.ByteSliceCopy(buff[off:end]), end
+}
+
+func (t *TopNOperator) storeGroup(key []byte, entries []*topNEntry, execCtx StreamExecContext) {
+	if execCtx.WriteVersion() < 0 {
+		panic(fmt.Sprintf("invalid write version: %d", execCtx.WriteVersion()))
+	}
+	val := make([]byte, 0, 64)
+	val = encoding2.AppendUint32ToBufferLE(val, uint32(len(entries)))
+	for _, entry := range entries {
+		for _, b := range [][]byte{entry.rowKey, entry.row, entry.orderBytes} {
+			val = encoding2.AppendUint32ToBufferLE(val, uint32(len(b)))
+			val = append(val, b...)
+		}
+	}
+	storeKey := encoding2.EncodeVersion(common.ByteSliceCopy(key), uint64(execCtx.WriteVersion()))
+	if log.DebugEnabled {
+		log.Debugf("node %d topn storing group key %v with version %d", t.nodeID, storeKey, execCtx.WriteVersion())
+	}
+	execCtx.StoreEntry(common.KV{
+		Key:   storeKey,
+		Value: val,
+	}, false)
+}
+
+// storeChanges applies the changes to the results table, which holds the current top N rows keyed by window and row
+// key. Retracted rows are deleted, unless the same row has been given a new rank in the batch.
+func (t *TopNOperator) storeChanges(batch *evbatch.Batch, execCtx StreamExecContext) {
+	partitionHash := t.hashCache.getHash(execCtx.PartitionID())
+	prefix := encoding2.EncodeEntryPrefix(partitionHash, t.resultsSlabID, 64)
+	retractCol := batch.GetBoolColumn(t.retractColIndex)
+	upserted := map[string]struct{}{}
+	keys := make([][]byte, batch.RowCount)
+	for i := 0; i < batch.RowCount; i++ {
+		keys[i] = evbatch.EncodeKeyCols(batch, i, t.storedKeyColIndexes, common.ByteSliceCopy(prefix))
+		if !retractCol.Get(i) {
+			upserted[string(keys[i])] = struct{}{}
+		}
+	}
+	for i := 0; i < batch.RowCount; i++ {
+		var val []byte
+		if retractCol.Get(i) {
+			if _, ok := upserted[string(keys[i])]; ok {
+				continue
+			}
+		} else {
+			val = evbatch.EncodeRowCols(batch, i, t.storedRowColIndexes, make([]byte, 0, rowInitialBufferSize))
+		}
+		execCtx.StoreEntry(common.KV{
+			Key:   encoding2.EncodeVersion(keys[i], uint64(execCtx.WriteVersion())),
+			Value: val,
+		}, false)
+	}
+}
+
+func (t *TopNOperator) HandleQueryBatch(*evbatch.Batch, QueryExecContext) (*evbatch.Batch, error) {
+	panic("not supported in queries")
+}
+
+func (t *TopNOperator) InSchema() *OperatorSchema {
+	return t.inSchema
+}
+
+func (t *TopNOperator) OutSchema() *OperatorSchema {
+	return t.outSchema
+}
+
+func (t *TopNOperator) Setup(StreamManagerCtx) error {
+	return nil
+}
+
+func (t *TopNOperator) Teardown(_ StreamManagerCtx, completeCB func(error)) {
+	completeCB(nil)
+}
@@ -0,0 +1,293 @@
+package opers
+
+import (
+	"encoding/binary"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/evbatch"
+	"github.com/spirit-labs/tektite/expr"
+	"github.com/spirit-labs/tektite/parser"
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+var topNColNames = []string{"event_time", "category", "product", "revenue"}
+var topNColTypes = []types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeString, types.ColumnTypeString, types.ColumnTypeInt}
+
+func TestTopNRankingChanges(t *testing.T) {
+	to := createTopNOperator(t, "my_stream := (topn 2 order_by revenue desc by category)", false)
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10)},
+		{types.NewTimestamp(1001), "c1", "p2", int64(20)},
+		{types.NewTimestamp(1002), "c1", "p3", int64(5)},
+		{types.NewTimestamp(1003), "c2", "p4", int64(7)},
+	}
+	expectedOut := [][]any{
+		{types.NewTimestamp(1001), "c1", "p2", int64(20), int64(1), false},
+		{types.NewTimestamp(1000), "c1", "p1", int64(10), int64(2), false},
+		{types.NewTimestamp(1003), "c2", "p4", int64(7), int64(1), false},
+	}
+	testTopN(t, to, ctx, dataIn, expectedOut)
+
+	makeStoredVisible(ctx)
+	dataIn = [][]any{
+		{types.NewTimestamp(1004), "c1", "p3", int64(30)},
+		// c2 only has one row, so this enters at rank 2
+		{types.NewTimestamp(1005), "c2", "p5", int64(1)},
+	}
+	expectedOut = [][]any{
+		{types.NewTimestamp(1001), "c1", "p2", int64(20), int64(1), true},
+		{types.NewTimestamp(1000), "c1", "p1", int64(10), int64(2), true},
+		{types.NewTimestamp(1004), "c1", "p3", int64(30), int64(1), false},
+		{types.NewTimestamp(1001), "c1", "p2", int64(20), int64(2), false},
+		{types.NewTimestamp(1005), "c2", "p5", int64(1), int64(2), false},
+	}
+	testTopN(t, to, ctx, dataIn, expectedOut)
+}
+
+func TestTopNRowFallsOutOfRanking(t *testing.T) {
+	to := createTopNOperator(t, "my_stream := (topn 1 order_by revenue desc by category)", false)
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10)},
+		{types.NewTimestamp(1001), "c1", "p2", int64(5)},
+	}
+	expectedOut := [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10), int64(1), false},
+	}
+	testTopN(t, to, ctx, dataIn, expectedOut)
+
+	// p1 drops below p2, which was not in the top 1, so p2 must have been retained in the state
+	makeStoredVisible(ctx)
+	dataIn = [][]any{
+		{types.NewTimestamp(1002), "c1", "p1", int64(1)},
+	}
+	expectedOut = [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10), int64(1), true},
+		{types.NewTimestamp(1001), "c1", "p2", int64(5), int64(1), false},
+	}
+	testTopN(t, to, ctx, dataIn, expectedOut)
+}
+
+func TestTopNNoGroupAscending(t *testing.T) {
+	to := createTopNOperator(t, "my_stream := (topn 3 order_by revenue, product)", false)
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10)},
+		{types.NewTimestamp(1001), "c2", "p2", nil},
+		{types.NewTimestamp(1002), "c1", "p3", int64(10)},
+		{types.NewTimestamp(1003), "c2", "p0", int64(10)},
+		// Later update to the same row wins
+		{types.NewTimestamp(1004), "c1", "p1", int64(2)},
+	}
+	expectedOut := [][]any{
+		{types.NewTimestamp(1001), "c2", "p2", nil, int64(1), false},
+		{types.NewTimestamp(1004), "c1", "p1", int64(2), int64(2), false},
+		{types.NewTimestamp(1003), "c2", "p0", int64(10), int64(3), false},
+	}
+	testTopN(t, to, &testExecCtx{version: 100, partitionID: 3}, dataIn, expectedOut)
+}
+
+func TestTopNWindowed(t *testing.T) {
+	to := createTopNOperator(t, "my_stream := (topn 2 order_by revenue desc by category)", true)
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10)},
+		{types.NewTimestamp(1001), "c1", "p2", int64(20)},
+		{types.NewTimestamp(1002), "c1", "p3", int64(15)},
+		{types.NewTimestamp(1003), "c2", "p4", int64(7)},
+	}
+	expectedOut := [][]any{
+		{types.NewTimestamp(1001), "c1", "p2", int64(20), int64(1), false},
+		{types.NewTimestamp(1002), "c1", "p3", int64(15), int64(2), false},
+		{types.NewTimestamp(1003), "c2", "p4", int64(7), int64(1), false},
+	}
+	testTopN(t, to, ctx, dataIn, expectedOut)
+
+	// The window columns are not included, so the ranking in the next window replaces this one
+	makeStoredVisible(ctx)
+	dataIn = [][]any{
+		{types.NewTimestamp(2000), "c1", "p1", int64(30)},
+		{types.NewTimestamp(2001), "c1", "p2", int64(20)},
+		{types.NewTimestamp(2002), "c2", "p4", int64(7)},
+	}
+	expectedOut = [][]any{
+		{types.NewTimestamp(1001), "c1", "p2", int64(20), int64(1), true},
+		{types.NewTimestamp(1002), "c1", "p3", int64(15), int64(2), true},
+		{types.NewTimestamp(2000), "c1", "p1", int64(30), int64(1), false},
+		{types.NewTimestamp(2001), "c1", "p2", int64(20), int64(2), false},
+		{types.NewTimestamp(1003), "c2", "p4", int64(7), int64(1), true},
+		{types.NewTimestamp(2002), "c2", "p4", int64(7), int64(1), false},
+	}
+	testTopN(t, to, ctx, dataIn, expectedOut)
+}
+
+func TestTopNWindowedTwoWindowsInBatch(t *testing.T) {
+	ast, err := parser.NewParser(nil).ParseTSL("my_stream := (topn 1 order_by revenue desc by category)")
+	require.NoError(t, err)
+	colNames := append([]string{"ws", "we"}, topNColNames...)
+	colTypes := append([]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeTimestamp}, topNColTypes...)
+	inSchema := evbatch.NewEventSchema(colNames, colTypes)
+	to, err := NewTopNOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		ast.CreateStream.OperatorDescs[0].(*parser.TopNDesc), []int{0, 1, 3, 4}, true, -1, -1, false,
+		&expr.ExpressionFactory{}, -1)
+	require.NoError(t, err)
+	w1s, w1e := types.NewTimestamp(0), types.NewTimestamp(1000)
+	w2s, w2e := types.NewTimestamp(1000), types.NewTimestamp(2000)
+	dataIn := [][]any{
+		{w1s, w1e, types.NewTimestamp(999), "c1", "p1", int64(10)},
+		{w1s, w1e, types.NewTimestamp(999), "c1", "p2", int64(20)},
+		{w2s, w2e, types.NewTimestamp(1999), "c1", "p1", int64(15)},
+		{w2s, w2e, types.NewTimestamp(1999), "c1", "p2", int64(5)},
+	}
+	batch := createEventBatch(colNames, colTypes, dataIn)
+	out, err := to.HandleStreamBatch(batch, &testExecCtx{version: 100, partitionID: 3})
+	require.NoError(t, err)
+	// Each window has its own ranking
+	require.Equal(t, [][]any{
+		{w1s, w1e, types.NewTimestamp(999), "c1", "p2", int64(20), int64(1), false},
+		{w2s, w2e, types.NewTimestamp(1999), "c1", "p1", int64(15), int64(1), false},
+	}, convertBatchToAnyArray(out))
+}
+
+func TestTopNStoreResults(t *testing.T) {
+	tsl, err := parser.NewParser(nil).ParseTSL("my_stream := (topn 1 order_by revenue desc by category)")
+	require.NoError(t, err)
+	inSchema := evbatch.NewEventSchema(topNColNames, topNColTypes)
+	to, err := NewTopNOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		tsl.CreateStream.OperatorDescs[0].(*parser.TopNDesc), []int{1, 2}, false, 1001, 1002, true,
+		&expr.ExpressionFactory{}, -1)
+	require.NoError(t, err)
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	dataIn := [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10)},
+		{types.NewTimestamp(1001), "c2", "p2", int64(20)},
+	}
+	testTopN(t, to, ctx, dataIn, [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10), int64(1), false},
+		{types.NewTimestamp(1001), "c2", "p2", int64(20), int64(1), false},
+	})
+	results := entriesInSlab(ctx.entries, 1002)
+	require.Equal(t, 2, len(results))
+	for _, kv := range results {
+		require.NotNil(t, kv.Value)
+	}
+
+	// Retracted rows are deleted from the table
+	batch := createEventBatch(to.outSchema.EventSchema.ColumnNames(), to.outSchema.EventSchema.ColumnTypes(), [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10), int64(1), true},
+		{types.NewTimestamp(1001), "c2", "p2", int64(20), int64(1), true},
+		{types.NewTimestamp(1001), "c2", "p2", int64(20), int64(2), false},
+	})
+	ctx.entries = nil
+	to.storeChanges(batch, ctx)
+	require.Equal(t, 2, len(ctx.entries))
+	require.Nil(t, ctx.entries[0].Value)
+	require.NotNil(t, ctx.entries[1].Value)
+}
+
+func TestTopNWindowedStoreResults(t *testing.T) {
+	ast, err := parser.NewParser(nil).ParseTSL("my_stream := (topn 1 order_by revenue desc by category)")
+	require.NoError(t, err)
+	colNames := append([]string{"ws", "we"}, topNColNames...)
+	colTypes := append([]types.ColumnType{types.ColumnTypeTimestamp, types.ColumnTypeTimestamp}, topNColTypes...)
+	inSchema := evbatch.NewEventSchema(colNames, colTypes)
+	to, err := NewTopNOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		ast.CreateStream.OperatorDescs[0].(*parser.TopNDesc), []int{0, 1, 3, 4}, true, -1, 1002, true,
+		&expr.ExpressionFactory{}, -1)
+	require.NoError(t, err)
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	w1s, w1e := types.NewTimestamp(0), types.NewTimestamp(1000)
+	w2s, w2e := types.NewTimestamp(1000), types.NewTimestamp(2000)
+	dataIn := [][]any{
+		{w1s, w1e, types.NewTimestamp(999), "c1", "p1", int64(10)},
+		{w2s, w2e, types.NewTimestamp(1999), "c1", "p1", int64(15)},
+	}
+	batch := createEventBatch(colNames, colTypes, dataIn)
+	_, err = to.HandleStreamBatch(batch, ctx)
+	require.NoError(t, err)
+	// The same row in each window is stored under its own key
+	require.Equal(t, 2, len(ctx.entries))
+	require.NotEqual(t, ctx.entries[0].Key, ctx.entries[1].Key)
+	for _, kv := range ctx.entries {
+		require.NotNil(t, kv.Value)
+	}
+}
+
+func TestTopNWindowedStoreResultsReplacesPreviousWindow(t *testing.T) {
+	tsl, err := parser.NewParser(nil).ParseTSL("my_stream := (topn 1 order_by revenue desc by category)")
+	require.NoError(t, err)
+	inSchema := evbatch.NewEventSchema(topNColNames, topNColTypes)
+	to, err := NewTopNOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		tsl.CreateStream.OperatorDescs[0].(*parser.TopNDesc), []int{1, 2}, true, 1001, 1002, true,
+		&expr.ExpressionFactory{}, -1)
+	require.NoError(t, err)
+	ctx := &testExecCtx{version: 100, partitionID: 3}
+	testTopN(t, to, ctx, [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10)},
+	}, [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10), int64(1), false},
+	})
+	makeStoredVisible(ctx)
+
+	// p1 is not in the next window, so it leaves the ranking and is deleted from the table
+	testTopN(t, to, ctx, [][]any{
+		{types.NewTimestamp(2000), "c1", "p2", int64(5)},
+	}, [][]any{
+		{types.NewTimestamp(1000), "c1", "p1", int64(10), int64(1), true},
+		{types.NewTimestamp(2000), "c1", "p2", int64(5), int64(1), false},
+	})
+	results := entriesInSlab(ctx.entries, 1002)
+	require.Equal(t, 2, len(results))
+	require.Nil(t, results[0].Value)
+	require.NotNil(t, results[1].Value)
+}
+
+func TestTopNReservedColumn(t *testing.T) {
+	tsl, err := parser.NewParser(nil).ParseTSL("my_stream := (topn 1 order_by rank)")
+	require.NoError(t, err)
+	inSchema := evbatch.NewEventSchema([]string{"rank"}, []types.ColumnType{types.ColumnTypeInt})
+	_, err = NewTopNOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		tsl.CreateStream.OperatorDescs[0].(*parser.TopNDesc), []int{0}, false, 1001, -1, false,
+		&expr.ExpressionFactory{}, -1)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "input to 'topn' operator must not have a column named 'rank'"))
+}
+
+func createTopNOperator(t *testing.T, tsl string, windowed bool) *TopNOperator {
+	ast, err := parser.NewParser(nil).ParseTSL(tsl)
+	require.NoError(t, err)
+	inSchema := evbatch.NewEventSchema(topNColNames, topNColTypes)
+	to, err := NewTopNOperator(&OperatorSchema{EventSchema: inSchema, PartitionScheme: PartitionScheme{MappingID: "mapping", Partitions: 10}},
+		ast.CreateStream.OperatorDescs[0].(*parser.TopNDesc), []int{1, 2}, windowed, 1001, -1, false,
+		&expr.ExpressionFactory{}, -1)
+	require.NoError(t, err)
+	return to
+}
+
+func entriesInSlab(entries []common.KV, slabID uint64) []common.KV {
+	var inSlab []common.KV
+	for _, kv := range entries {
+		if binary.BigEndian.Uint64(kv.Key[16:24]) == slabID {
+			inSlab = append(inSlab, kv)
+		}
+	}
+	return inSlab
+}
+
+func makeStoredVisible(ctx *testExecCtx) {
+	// Get doesn't look up using the version, so we remove it
+	ctx.stored = map[string][]byte{}
+	for _, kv := range ctx.entries {
+		ctx.stored[string(kv.Key[:len(kv.Key)-8])] = kv.Value
+	}
+	ctx.entries = nil
+}
+
+func testTopN(t *testing.T, to *TopNOperator, ctx *testExecCtx, dataIn [][]any, expectedOut [][]any) {
+	batch := createEventBatch(topNColNames, topNColTypes, dataIn)
+	out, err := to.HandleStreamBatch(batch, ctx)
+	require.NoError(t, err)
+	require.Equal(t, expectedOut, convertBatchToAnyArray(out))
+}
@@ -46,6 +46,8 @@ func convertBatchToAnyArray(batch *evbatch.Batch) [][]any {
 				row = append(row, batch.GetBytesColumn(j).Get(i))
 			case types.ColumnTypeIDTimestamp:
 				row = append(row, batch.GetTimestampColumn(j).Get(i))
+			case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+				row = append(row, batch.GetNestedColumn(j).Get(i))
 			default:
 				panic("unknown type")
 			}
@@ -80,6 +82,8 @@ func createEventBatch(columnNames []string, columnTypes []types.ColumnType, data
 				colBuilder.(*evbatch.BytesColBuilder).Append(row[j].([]byte))
 			case types.ColumnTypeIDTimestamp:
 				colBuilder.(*evbatch.TimestampColBuilder).Append(row[j].(types.Timestamp))
+			case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+				colBuilder.(*evbatch.NestedColBuilder).Append(row[j])
 			default:
 				panic("unknown type")
 			}
@@ -3,6 +3,7 @@ package opers
 import (
 	"github.com/spirit-labs/tektite/asl/arista"
 	"github.com/spirit-labs/tektite/evbatch"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,6 +23,8 @@ type WaterMarkOperator struct {
 	idleTimeoutNanos          uint64
 	testIdleProcessors        bool
 	idleProcessors            []bool
+	// processorWaterMarkLags holds the lag, in ms, of the last watermark set by each processor, or -1 if none
+	processorWaterMarkLags []int64
 }
 
 func NewWaterMarkOperator(inSchema *OperatorSchema, waterMarkTypeStr string, lateness time.Duration, idleTimeout time.Duration) *WaterMarkOperator {
@@ -45,6 +48,11 @@ func NewWaterMarkOperator(inSchema *OperatorSchema, waterMarkTypeStr string, lat
 		processorLastBatchHandled = make([]uint64, maxProcessorID+1)
 	}
 
+	processorWaterMarkLags := make([]int64, maxProcessorID+1)
+	for i := range processorWaterMarkLags {
+		processorWaterMarkLags[i] = -1
+	}
+
 	idleTimeoutNanos := uint64(idleTimeout.Nanoseconds())
 	return &WaterMarkOperator{
 		schema:                    inSchema,
@@ -53,6 +61,7 @@ func NewWaterMarkOperator(inSchema *OperatorSchema, waterMarkTypeStr string, lat
 		processorLastBatchHandled: processorLastBatchHandled,
 		latenessMillis:            int(lateness.Milliseconds()),
 		idleTimeoutNanos:          idleTimeoutNanos,
+		processorWaterMarkLags:    processorWaterMarkLags,
 	}
 }
 
@@ -116,9 +125,29 @@ func (w *WaterMarkOperator) setWatermark(execCtx StreamExecContext) {
 			waterMark = maxEventTime - w.latenessMillis
 		}
 	}
+	lag := int64(-1)
+	if waterMark > 0 {
+		lag = time.Now().UTC().UnixMilli() - int64(waterMark)
+	}
+	atomic.StoreInt64(&w.processorWaterMarkLags[execCtx.Processor().ID()], lag)
 	execCtx.SetWaterMark(waterMark)
 }
 
+// waterMarkLag returns the largest lag between the current time and the last watermark set on any processor, or -1
+// if no watermark has been set
+func (w *WaterMarkOperator) waterMarkLag() time.Duration {
+	maxLag := int64(-1)
+	for i := range w.processorWaterMarkLags {
+		if lag := atomic.LoadInt64(&w.processorWaterMarkLags[i]); lag > maxLag {
+			maxLag = lag
+		}
+	}
+	if maxLag == -1 {
+		return -1
+	}
+	return time.Duration(maxLag) * time.Millisecond
+}
+
 func (w *WaterMarkOperator) InSchema() *OperatorSchema {
 	return w.schema
 }
@@ -57,6 +57,9 @@ func TestWaterMarkOperatorEventTime(t *testing.T) {
 		maxTimes[procID] = maxTime
 	}
 
+	// No watermark has been generated yet
+	require.Equal(t, time.Duration(-1), wo.waterMarkLag())
+
 	// Now send a barrier for each processor - should pick up watermark for that processor
 
 	for _, procID := range operSchema.PartitionScheme.ProcessorIDs {
@@ -71,6 +74,9 @@ func TestWaterMarkOperatorEventTime(t *testing.T) {
 		expectedWM := int(expectedMaxTime - lag.Milliseconds())
 		require.Equal(t, expectedWM, ec.WaterMark())
 	}
+
+	// Event times are within 500ms of the base time, and the watermark is a further second behind
+	require.GreaterOrEqual(t, wo.waterMarkLag(), 500*time.Millisecond)
 }
 
 func TestWaterMarkOperatorProcessingTime(t *testing.T) {
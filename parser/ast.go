@@ -2,9 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/alecthomas/participle/v2/lexer"
 	"github.com/spirit-labs/tektite/common"
@@ -46,6 +48,99 @@ func (b *BaseDesc) Parse(context *ParseContext) error {
 	return nil
 }
 
+// SameDefinition returns true if the two descs were parsed from the same sequence of tokens, ignoring whitespace and
+// position. Descs which were not parsed, e.g. those created when rewriting a stream, are compared by value.
+func SameDefinition(desc1 Parseable, desc2 Parseable) bool {
+	tv1, ok1 := desc1.(tokenValuesProvider)
+	tv2, ok2 := desc2.(tokenValuesProvider)
+	if ok1 && ok2 {
+		tokens1 := tv1.tokenValues()
+		tokens2 := tv2.tokenValues()
+		if tokens1 != nil && tokens2 != nil {
+			return reflect.DeepEqual(tokens1, tokens2)
+		}
+	}
+	return reflect.DeepEqual(desc1, desc2)
+}
+
+// IsParsed returns true if the desc was created by parsing, so has tokens that errors can be reported against
+func IsParsed(desc Parseable) bool {
+	tv, ok := desc.(tokenValuesProvider)
+	return ok && tv.tokenValues() != nil
+}
+
+// Definition returns the text that the desc was parsed from, including the enclosing parentheses of an operator, or
+// the empty string if the desc was not parsed.
+func Definition(desc Parseable) string {
+	dp, ok := desc.(definitionProvider)
+	if !ok {
+		return ""
+	}
+	return dp.definition()
+}
+
+// ArgumentsEndOffset returns the offset in the parsed input of the closing parenthesis of an operator desc, which is
+// where a further named argument can be added to its definition, or -1 if the desc was not parsed.
+func ArgumentsEndOffset(desc Parseable) int {
+	dp, ok := desc.(definitionProvider)
+	if !ok {
+		return -1
+	}
+	return dp.argumentsEndOffset()
+}
+
+type tokenValuesProvider interface {
+	tokenValues() []string
+}
+
+type definitionProvider interface {
+	definition() string
+	argumentsEndOffset() int
+}
+
+func (b *BaseDesc) definition() string {
+	tokens := b.tokenInfo.tokens
+	if len(tokens) == 0 {
+		return ""
+	}
+	input := b.tokenInfo.input
+	start := tokens[0].Pos.Offset
+	last := tokens[len(tokens)-1]
+	end := last.Pos.Offset + len(last.Value)
+	// Operator descs are parsed after the opening parenthesis
+	lp := start
+	for lp > 0 && unicode.IsSpace(rune(input[lp-1])) {
+		lp--
+	}
+	if lp > 0 && input[lp-1] == '(' {
+		start = lp - 1
+	}
+	return input[start:end]
+}
+
+func (b *BaseDesc) argumentsEndOffset() int {
+	tokens := b.tokenInfo.tokens
+	if len(tokens) == 0 {
+		return -1
+	}
+	last := tokens[len(tokens)-1]
+	if last.Value != ")" {
+		return -1
+	}
+	return last.Pos.Offset
+}
+
+func (b *BaseDesc) tokenValues() []string {
+	if b.tokenInfo.tokens == nil {
+		return nil
+	}
+	values := make([]string, len(b.tokenInfo.tokens))
+	for i, token := range b.tokenInfo.tokens {
+		values[i] = token.Value
+	}
+	return values
+}
+
 func (b *BaseDesc) ErrorMsgAtToken(msg string, tokenVal string) string {
 	tok := &b.tokenInfo.tokens[0] // default to first token
 	if tokenVal != "" {
@@ -71,12 +166,15 @@ func NewTSLDesc() *TSLDesc {
 
 type TSLDesc struct {
 	BaseDesc
-	CreateStream *CreateStreamDesc
-	DeleteStream *DeleteStreamDesc
-	PrepareQuery *PrepareQueryDesc
-	ListStreams  *ListStreamsDesc
-	ShowStream   *ShowStreamDesc
-	DeleteQuery  *DeleteQueryDesc
+	CreateStream    *CreateStreamDesc
+	DeleteStream    *DeleteStreamDesc
+	PrepareQuery    *PrepareQueryDesc
+	ListStreams     *ListStreamsDesc
+	ShowStream      *ShowStreamDesc
+	ShowStreamStats *ShowStreamStatsDesc
+	DeleteQuery     *DeleteQueryDesc
+	Explain         *ExplainDesc
+	Savepoint       *SavepointDesc
 }
 
 func (t *TSLDesc) parse(context *ParseContext) error {
@@ -107,6 +205,15 @@ func (t *TSLDesc) parse(context *ParseContext) error {
 		}
 		t.ListStreams = listStreams
 	case "show":
+		pos := context.CursorPos()
+		if pos+1 < len(context.tokens) && context.TokenAt(pos+1).Value == "stream" {
+			showStreamStats := NewShowStreamStatsDesc()
+			if err := showStreamStats.Parse(context); err != nil {
+				return err
+			}
+			t.ShowStreamStats = showStreamStats
+			return nil
+		}
 		showStream := NewShowStreamDesc()
 		if err := showStream.Parse(context); err != nil {
 			return err
@@ -118,13 +225,40 @@ func (t *TSLDesc) parse(context *ParseContext) error {
 			return err
 		}
 		t.DeleteQuery = deleteQuery
-	default:
-		createStreamDesc := NewCreateStreamDesc()
-		if err := createStreamDesc.Parse(context); err != nil {
+	case "explain":
+		// 'explain' can also be the name of a stream, in which case it is followed by ':='
+		pos := context.CursorPos()
+		if pos+1 < len(context.tokens) && context.TokenAt(pos+1).Value == ":=" {
+			return t.parseCreateStream(context)
+		}
+		explain := NewExplainDesc()
+		if err := explain.Parse(context); err != nil {
+			return err
+		}
+		t.Explain = explain
+	case "savepoint":
+		// 'savepoint' can also be the name of a stream, in which case it is followed by ':='
+		pos := context.CursorPos()
+		if pos+1 < len(context.tokens) && context.TokenAt(pos+1).Value == ":=" {
+			return t.parseCreateStream(context)
+		}
+		savepoint := NewSavepointDesc()
+		if err := savepoint.Parse(context); err != nil {
 			return err
 		}
-		t.CreateStream = createStreamDesc
+		t.Savepoint = savepoint
+	default:
+		return t.parseCreateStream(context)
+	}
+	return nil
+}
+
+func (t *TSLDesc) parseCreateStream(context *ParseContext) error {
+	createStreamDesc := NewCreateStreamDesc()
+	if err := createStreamDesc.Parse(context); err != nil {
+		return err
 	}
+	t.CreateStream = createStreamDesc
 	return nil
 }
 
@@ -145,6 +279,15 @@ func (t *TSLDesc) clearTokenState() {
 	if t.ShowStream != nil {
 		t.ShowStream.clearTokenState()
 	}
+	if t.ShowStreamStats != nil {
+		t.ShowStreamStats.clearTokenState()
+	}
+	if t.Explain != nil {
+		t.Explain.clearTokenState()
+	}
+	if t.Savepoint != nil {
+		t.Savepoint.clearTokenState()
+	}
 }
 
 func NewCreateStreamDesc() *CreateStreamDesc {
@@ -157,6 +300,13 @@ type CreateStreamDesc struct {
 	BaseDesc
 	StreamName    string
 	OperatorDescs []Parseable
+	// Alter is true if the statement replaces the definition of an existing stream, e.g. alter my_stream := ...
+	Alter bool
+	// OnError is the error policy of the stream, e.g. my_stream := ... on_error = skip, or nil if not specified
+	OnError *OnErrorDesc
+	// FromSavepoint is the object store prefix of a savepoint that the state of the stream is loaded from, e.g.
+	// my_stream := ... from savepoint "savepoints/my_stream", or empty if not specified
+	FromSavepoint string
 	TestSource    bool
 	TestSink      bool
 }
@@ -169,6 +319,20 @@ func (cs *CreateStreamDesc) parse(context *ParseContext) error {
 	if token.Type != IdentTokenType {
 		return foundUnexpectedTokenError("identifier", token, context.input)
 	}
+	if token.Value == "alter" {
+		// 'alter' can also be the name of a stream, in which case it is followed by ':='
+		next, ok := context.PeekToken()
+		if ok && next.Value != ":=" {
+			cs.Alter = true
+			token, err = context.expectToken()
+			if err != nil {
+				return err
+			}
+			if token.Type != IdentTokenType {
+				return foundUnexpectedTokenError("identifier", token, context.input)
+			}
+		}
+	}
 	cs.StreamName = token.Value
 	if _, err := context.expectToken(":="); err != nil {
 		return err
@@ -180,6 +344,9 @@ func (cs *CreateStreamDesc) parse(context *ParseContext) error {
 		if !context.HasNext() {
 			break
 		}
+		if token, _ := context.PeekToken(); token.Value == "on_error" || token.Value == "from" {
+			return cs.parseClauses(context)
+		}
 		if _, err := context.expectToken("->"); err != nil {
 			return err
 		}
@@ -187,6 +354,39 @@ func (cs *CreateStreamDesc) parse(context *ParseContext) error {
 	return nil
 }
 
+// parseClauses parses the optional clauses which can follow the operators of a stream, in any order
+func (cs *CreateStreamDesc) parseClauses(context *ParseContext) error {
+	for context.HasNext() {
+		token, _ := context.PeekToken()
+		if token.Value == "on_error" && cs.OnError == nil {
+			onError := NewOnErrorDesc()
+			if err := onError.Parse(context); err != nil {
+				return err
+			}
+			cs.OnError = onError
+		} else if token.Value == "from" && cs.FromSavepoint == "" {
+			context.NextToken()
+			if _, err := context.expectToken("savepoint"); err != nil {
+				return err
+			}
+			token, err := context.expectToken()
+			if err != nil {
+				return err
+			}
+			if token.Type != StringLiteralTokenType {
+				return foundUnexpectedTokenError("string literal", token, context.input)
+			}
+			cs.FromSavepoint = stripQuotes(token.Value)
+			if cs.FromSavepoint == "" {
+				return errorAtPosition("savepoint prefix must not be empty", token.Pos, context.input)
+			}
+		} else {
+			return foundUnexpectedTokenError("end of statement", token, context.input)
+		}
+	}
+	return nil
+}
+
 func (cs *CreateStreamDesc) parseOperatorDesc(context *ParseContext) error {
 	token, ok := context.PeekToken()
 	if !ok {
@@ -226,6 +426,12 @@ func (cs *CreateStreamDesc) parseOperatorDesc(context *ParseContext) error {
 			panic(fmt.Sprintf("invalid bridge type %s", token.Value))
 		}
 		context.MoveCursor(-2)
+	case "cdc":
+		if _, err = context.expectToken("from"); err != nil {
+			return err
+		}
+		operatorDesc = NewCdcFromDesc()
+		context.MoveCursor(-2)
 	case "filter":
 		operatorDesc = NewFilterDesc()
 		context.MoveCursor(-1)
@@ -276,9 +482,30 @@ func (cs *CreateStreamDesc) parseOperatorDesc(context *ParseContext) error {
 	case "backfill":
 		operatorDesc = NewBackfillDesc()
 		context.MoveCursor(-1)
+	case "decode":
+		operatorDesc = NewDecodeDesc()
+		context.MoveCursor(-1)
+	case "dedup":
+		operatorDesc = NewDedupDesc()
+		context.MoveCursor(-1)
+	case "encode":
+		operatorDesc = NewEncodeDesc()
+		context.MoveCursor(-1)
+	case "explode":
+		operatorDesc = NewExplodeDesc()
+		context.MoveCursor(-1)
+	case "topn":
+		operatorDesc = NewTopNDesc()
+		context.MoveCursor(-1)
+	case "match_recognize":
+		operatorDesc = NewMatchRecognizeDesc()
+		context.MoveCursor(-1)
+	case "http_sink":
+		operatorDesc = NewHttpSinkDesc()
+		context.MoveCursor(-1)
 	default:
-		expected := expectedStr("aggregate", "backfill", "bridge", "filter", "join", "kafka", "partition",
-			"producer", "project", "store", "topic", "union")
+		expected := expectedStr("aggregate", "backfill", "bridge", "cdc", "decode", "dedup", "encode", "explode",
+			"filter", "http_sink", "join", "kafka", "match_recognize", "partition", "producer", "project", "store", "topic", "topn", "union")
 		return errorAtPosition(fmt.Sprintf("expected %s", expected), token.Pos, context.input)
 	}
 	if err := operatorDesc.Parse(context); err != nil {
@@ -296,6 +523,66 @@ func (cs *CreateStreamDesc) clearTokenState() {
 			clearable.clearTokenState()
 		}
 	}
+	if cs.OnError != nil {
+		cs.OnError.clearTokenState()
+	}
+}
+
+const (
+	ErrorPolicyFail       = "fail"
+	ErrorPolicySkip       = "skip"
+	ErrorPolicyDeadLetter = "dead_letter"
+)
+
+func NewOnErrorDesc() *OnErrorDesc {
+	super := &OnErrorDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+// OnErrorDesc describes what happens to rows which fail expression evaluation or decoding in a stream, e.g.
+// on_error = skip or on_error = dead_letter(my_topic)
+type OnErrorDesc struct {
+	BaseDesc
+	Policy string
+	// DeadLetterTarget is the name of the stream that failed rows are sent to, when Policy is ErrorPolicyDeadLetter
+	DeadLetterTarget string
+}
+
+func (o *OnErrorDesc) parse(context *ParseContext) error {
+	if _, err := context.expectToken("on_error"); err != nil {
+		return err
+	}
+	token, skippedPastEquals, ok := skipPastOptionalEquals(context)
+	if !ok {
+		return endOfInputError()
+	}
+	switch token.Value {
+	case ErrorPolicyFail, ErrorPolicySkip, ErrorPolicyDeadLetter:
+		o.Policy = token.Value
+	default:
+		expected := expectedStr(ErrorPolicyFail, ErrorPolicySkip, ErrorPolicyDeadLetter)
+		if !skippedPastEquals {
+			expected = fmt.Sprintf(`'=' or %s`, expected)
+		}
+		return foundUnexpectedTokenError(expected, token, context.input)
+	}
+	if o.Policy != ErrorPolicyDeadLetter {
+		return nil
+	}
+	if _, err := context.expectToken("("); err != nil {
+		return err
+	}
+	token, err := context.expectToken()
+	if err != nil {
+		return err
+	}
+	if token.Type != IdentTokenType {
+		return foundUnexpectedTokenError("identifier", token, context.input)
+	}
+	o.DeadLetterTarget = token.Value
+	_, err = context.expectToken(")")
+	return err
 }
 
 func NewQueryDesc() *QueryDesc {
@@ -478,7 +765,8 @@ func (p *PrepareQueryDesc) parse(context *ParseContext) error {
 		tok := context.TokenAt(i)
 		if tok.Type == IdentTokenType {
 			if tok.Value[0] == '$' {
-				parts := strings.Split(tok.Value, ":")
+				// Only split on the first ':' as struct types contain ':'
+				parts := strings.SplitN(tok.Value, ":", 2)
 				ok := false
 				if len(parts) == 2 {
 					ct, err := types.StringToColumnType(parts[1])
@@ -491,7 +779,7 @@ func (p *PrepareQueryDesc) parse(context *ParseContext) error {
 					}
 				}
 				if !ok {
-					return errorAtPosition("invalid prepared statement parameter. must be of form '$name:type' where type is one of int, float, bool, decimal(p, s), string, bytes, timestamp",
+					return errorAtPosition("invalid prepared statement parameter. must be of form '$name:type' where type is one of int, float, bool, decimal(p, s), string, bytes, timestamp, array<type>, map<string,type>, struct<name:type,...>",
 						tok.Pos, context.input)
 				}
 			}
@@ -582,6 +870,160 @@ func (p *ShowStreamDesc) clearTokenState() {
 	p.BaseDesc.clearTokenState()
 }
 
+func NewShowStreamStatsDesc() *ShowStreamStatsDesc {
+	super := &ShowStreamStatsDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+// ShowStreamStatsDesc is 'show stream stats', for all streams, or 'show stream stats my_stream'
+type ShowStreamStatsDesc struct {
+	BaseDesc
+	StreamName string
+}
+
+func (p *ShowStreamStatsDesc) parse(context *ParseContext) error {
+	if _, err := context.expectToken("show"); err != nil {
+		return err
+	}
+	if _, err := context.expectToken("stream"); err != nil {
+		return err
+	}
+	if _, err := context.expectToken("stats"); err != nil {
+		return err
+	}
+	token, ok := context.NextToken()
+	if !ok {
+		return nil
+	}
+	if token.Type != IdentTokenType {
+		return foundUnexpectedTokenError("identifier", token, context.input)
+	}
+	p.StreamName = token.Value
+	if token, ok := context.NextToken(); ok {
+		return foundUnexpectedTokenError("end of statement", token, context.input)
+	}
+	return nil
+}
+
+func (p *ShowStreamStatsDesc) clearTokenState() {
+	p.BaseDesc.clearTokenState()
+}
+
+func NewExplainDesc() *ExplainDesc {
+	super := &ExplainDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+// ExplainDesc describes an explain statement. Either StreamName is set, e.g. explain my_stream, or Query is set,
+// e.g. explain (scan all from my_table)->(filter by x > 10)
+type ExplainDesc struct {
+	BaseDesc
+	StreamName string
+	Query      *QueryDesc
+}
+
+func (e *ExplainDesc) parse(context *ParseContext) error {
+	if _, err := context.expectToken("explain"); err != nil {
+		return err
+	}
+	token, ok := context.PeekToken()
+	if !ok {
+		return endOfInputError()
+	}
+	if token.Type == IdentTokenType {
+		context.NextToken()
+		e.StreamName = token.Value
+		return e.expectEnd(context)
+	}
+	if token.Type != LParensTokenType {
+		return foundUnexpectedTokenError("identifier or left parenthesis", token, context.input)
+	}
+	// explain(my_stream) is also allowed, in the same way as show(my_stream)
+	pos := context.CursorPos()
+	if pos+2 < len(context.tokens) && context.TokenAt(pos+1).Type == IdentTokenType &&
+		context.TokenAt(pos+2).Type == RParensTokenType {
+		e.StreamName = context.TokenAt(pos + 1).Value
+		context.MoveCursor(3)
+		return e.expectEnd(context)
+	}
+	query := NewQueryDesc()
+	if err := query.Parse(context); err != nil {
+		return err
+	}
+	e.Query = query
+	return nil
+}
+
+func (e *ExplainDesc) expectEnd(context *ParseContext) error {
+	token, ok := context.NextToken()
+	if ok {
+		return foundUnexpectedTokenError("end of statement", token, context.input)
+	}
+	return nil
+}
+
+func (e *ExplainDesc) clearTokenState() {
+	e.BaseDesc.clearTokenState()
+	if e.Query != nil {
+		e.Query.clearTokenState()
+	}
+}
+
+func NewSavepointDesc() *SavepointDesc {
+	super := &SavepointDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+// SavepointDesc describes a statement which writes the state of a stream to the object store, as of a completed
+// version, e.g. savepoint stream my_stream to "savepoints/my_stream"
+type SavepointDesc struct {
+	BaseDesc
+	StreamName string
+	Prefix     string
+}
+
+func (s *SavepointDesc) parse(context *ParseContext) error {
+	if _, err := context.expectToken("savepoint"); err != nil {
+		return err
+	}
+	if _, err := context.expectToken("stream"); err != nil {
+		return err
+	}
+	token, err := context.expectToken()
+	if err != nil {
+		return err
+	}
+	if token.Type != IdentTokenType {
+		return foundUnexpectedTokenError("identifier", token, context.input)
+	}
+	s.StreamName = token.Value
+	if _, err := context.expectToken("to"); err != nil {
+		return err
+	}
+	token, err = context.expectToken()
+	if err != nil {
+		return err
+	}
+	if token.Type != StringLiteralTokenType {
+		return foundUnexpectedTokenError("string literal", token, context.input)
+	}
+	s.Prefix = stripQuotes(token.Value)
+	if s.Prefix == "" {
+		return errorAtPosition("savepoint prefix must not be empty", token.Pos, context.input)
+	}
+	if token, ok := context.NextToken(); ok {
+		return foundUnexpectedTokenError("end of statement", token, context.input)
+	}
+	return nil
+}
+
+func (s *SavepointDesc) clearTokenState() {
+	s.BaseDesc.clearTokenState()
+}
+
 func NewContinuationDesc() *ContinuationDesc {
 	super := &ContinuationDesc{}
 	super.BaseDesc.super = super
@@ -720,37 +1162,58 @@ func (b *BridgeFromDesc) parse(context *ParseContext) error {
 	return nil
 }
 
-func NewBridgeToDesc() *BridgeToDesc {
-	super := &BridgeToDesc{}
+func NewCdcFromDesc() *CdcFromDesc {
+	super := &CdcFromDesc{}
 	super.BaseDesc.super = super
 	return super
 }
 
-type BridgeToDesc struct {
+const CdcSourcePostgres = "postgres"
+
+type CdcFromDesc struct {
 	BaseDesc
-	TopicName         string
-	Retention         *time.Duration
-	InitialRetryDelay *time.Duration
-	MaxRetryDelay     *time.Duration
-	ConnectTimeout    *time.Duration
-	SendTimeout       *time.Duration
-	Props             map[string]string
+	Source               string
+	Connection           string
+	Slot                 string
+	Publication          string
+	PollTimeout          *time.Duration
+	MaxPollMessages      *int
+	WatermarkType        *string
+	WatermarkLateness    *time.Duration
+	WatermarkIdleTimeout *time.Duration
 }
 
-func (b *BridgeToDesc) parse(context *ParseContext) error {
+func (c *CdcFromDesc) parse(context *ParseContext) error {
 	context.MoveCursor(2)
 
-	// topic name is mandatory
-	topicName, err := parseTopicName(context)
+	// Postgres logical replication is the only source currently supported
+	token, err := context.expectToken(CdcSourcePostgres)
 	if err != nil {
 		return err
 	}
-	b.TopicName = topicName
+	c.Source = token.Value
+
+	// connection, slot and publication are mandatory
+	tok, err := parseNamedArg("connection", StringLiteralTokenType, "string literal", context)
+	if err != nil {
+		return err
+	}
+	c.Connection = stripQuotes(tok.Value)
+	tok, err = parseNamedArg("slot", StringLiteralTokenType, "string literal", context)
+	if err != nil {
+		return err
+	}
+	c.Slot = stripQuotes(tok.Value)
+	tok, err = parseNamedArg("publication", StringLiteralTokenType, "string literal", context)
+	if err != nil {
+		return err
+	}
+	c.Publication = stripQuotes(tok.Value)
 
 	for {
 		token, ok := context.NextToken()
 		if !ok {
-			break
+			return endOfInputError()
 		}
 		if token.Value == ")" {
 			// End of operator definition
@@ -758,29 +1221,124 @@ func (b *BridgeToDesc) parse(context *ParseContext) error {
 		}
 		// Must be optional arg
 		if token.Type != IdentTokenType {
-			return foundUnexpectedTokenError("identifier", token, context.input)
+			return foundUnexpectedTokenError("identifier or closing ')'", token, context.input)
 		}
 		switch token.Value {
-		case "props":
-			if b.Props != nil {
+		case "poll_timeout":
+			if c.PollTimeout != nil {
 				return duplicateArgumentError(token, context)
 			}
-			props, err := parseProps(context)
+			pollTimeout, err := parseDurationArg(context)
 			if err != nil {
 				return err
 			}
-			b.Props = props
-		case "retention":
-			if b.Retention != nil {
+			c.PollTimeout = &pollTimeout
+		case "max_poll_messages":
+			if c.MaxPollMessages != nil {
 				return duplicateArgumentError(token, context)
 			}
-			ret, err := parseDurationArg(context)
+			maxPollMessages, err := parseIntArg(context)
 			if err != nil {
 				return err
 			}
-			b.Retention = &ret
-		case "initial_retry_delay":
-			if b.InitialRetryDelay != nil {
+			c.MaxPollMessages = &maxPollMessages
+		case "watermark_type":
+			if c.WatermarkType != nil {
+				return duplicateArgumentError(token, context)
+			}
+			wmType, err := parseWatermarkType(context)
+			if err != nil {
+				return err
+			}
+			c.WatermarkType = &wmType
+		case "watermark_lateness":
+			if c.WatermarkLateness != nil {
+				return duplicateArgumentError(token, context)
+			}
+			wmLateness, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			c.WatermarkLateness = &wmLateness
+		case "watermark_idle_timeout":
+			if c.WatermarkIdleTimeout != nil {
+				return duplicateArgumentError(token, context)
+			}
+			wmIdleTimeout, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			c.WatermarkIdleTimeout = &wmIdleTimeout
+		default:
+			if token.Value == "connection" || token.Value == "slot" || token.Value == "publication" {
+				return duplicateArgumentError(token, context)
+			}
+			return unknownArgumentError(token, context)
+		}
+	}
+}
+
+func NewBridgeToDesc() *BridgeToDesc {
+	super := &BridgeToDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+type BridgeToDesc struct {
+	BaseDesc
+	TopicName         string
+	Retention         *time.Duration
+	InitialRetryDelay *time.Duration
+	MaxRetryDelay     *time.Duration
+	ConnectTimeout    *time.Duration
+	SendTimeout       *time.Duration
+	Props             map[string]string
+}
+
+func (b *BridgeToDesc) parse(context *ParseContext) error {
+	context.MoveCursor(2)
+
+	// topic name is mandatory
+	topicName, err := parseTopicName(context)
+	if err != nil {
+		return err
+	}
+	b.TopicName = topicName
+
+	for {
+		token, ok := context.NextToken()
+		if !ok {
+			break
+		}
+		if token.Value == ")" {
+			// End of operator definition
+			return nil
+		}
+		// Must be optional arg
+		if token.Type != IdentTokenType {
+			return foundUnexpectedTokenError("identifier", token, context.input)
+		}
+		switch token.Value {
+		case "props":
+			if b.Props != nil {
+				return duplicateArgumentError(token, context)
+			}
+			props, err := parseProps(context)
+			if err != nil {
+				return err
+			}
+			b.Props = props
+		case "retention":
+			if b.Retention != nil {
+				return duplicateArgumentError(token, context)
+			}
+			ret, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			b.Retention = &ret
+		case "initial_retry_delay":
+			if b.InitialRetryDelay != nil {
 				return duplicateArgumentError(token, context)
 			}
 			ret, err := parseDurationArg(context)
@@ -820,6 +1378,150 @@ func (b *BridgeToDesc) parse(context *ParseContext) error {
 	return nil
 }
 
+const (
+	HttpSinkFormatJSON  = "json"
+	HttpSinkFormatArrow = "arrow"
+)
+
+func NewHttpSinkDesc() *HttpSinkDesc {
+	super := &HttpSinkDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+// HttpSinkDesc describes an operator which sends the rows of a stream to an HTTP endpoint, e.g.
+// (http_sink url = "http://my_host:8080/events" method = "PUT" headers = ("Authorization" = "Bearer xyz") batch_size = 100
+// format = arrow concurrency = 4)
+type HttpSinkDesc struct {
+	BaseDesc
+	URL               string
+	Method            *string
+	Headers           map[string]string
+	BatchSize         *int
+	Format            *string
+	Concurrency       *int
+	Retention         *time.Duration
+	InitialRetryDelay *time.Duration
+	MaxRetryDelay     *time.Duration
+	RequestTimeout    *time.Duration
+}
+
+func (h *HttpSinkDesc) parse(context *ParseContext) error {
+	context.MoveCursor(1)
+
+	// url is mandatory
+	tok, err := parseNamedArg("url", StringLiteralTokenType, "string literal", context)
+	if err != nil {
+		return err
+	}
+	h.URL = stripQuotes(tok.Value)
+
+	for {
+		token, ok := context.NextToken()
+		if !ok {
+			return endOfInputError()
+		}
+		if token.Value == ")" {
+			// End of operator definition
+			return nil
+		}
+		// Must be optional arg
+		if token.Type != IdentTokenType {
+			return foundUnexpectedTokenError("identifier", token, context.input)
+		}
+		switch token.Value {
+		case "method":
+			if h.Method != nil {
+				return duplicateArgumentError(token, context)
+			}
+			tok, err := parseNamedArgValue(StringLiteralTokenType, "string literal", context)
+			if err != nil {
+				return err
+			}
+			method := stripQuotes(tok.Value)
+			h.Method = &method
+		case "headers":
+			if h.Headers != nil {
+				return duplicateArgumentError(token, context)
+			}
+			headers, err := parseProps(context)
+			if err != nil {
+				return err
+			}
+			h.Headers = headers
+		case "batch_size":
+			if h.BatchSize != nil {
+				return duplicateArgumentError(token, context)
+			}
+			batchSize, err := parseIntArg(context)
+			if err != nil {
+				return err
+			}
+			h.BatchSize = &batchSize
+		case "format":
+			if h.Format != nil {
+				return duplicateArgumentError(token, context)
+			}
+			tok, err := parseNamedArgValue(IdentTokenType, "identifier", context)
+			if err != nil {
+				return err
+			}
+			if tok.Value != HttpSinkFormatJSON && tok.Value != HttpSinkFormatArrow {
+				return foundUnexpectedTokenError(expectedStr(HttpSinkFormatJSON, HttpSinkFormatArrow), tok,
+					context.input)
+			}
+			h.Format = &tok.Value
+		case "concurrency":
+			if h.Concurrency != nil {
+				return duplicateArgumentError(token, context)
+			}
+			concurrency, err := parseIntArg(context)
+			if err != nil {
+				return err
+			}
+			h.Concurrency = &concurrency
+		case "retention":
+			if h.Retention != nil {
+				return duplicateArgumentError(token, context)
+			}
+			ret, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			h.Retention = &ret
+		case "initial_retry_delay":
+			if h.InitialRetryDelay != nil {
+				return duplicateArgumentError(token, context)
+			}
+			ret, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			h.InitialRetryDelay = &ret
+		case "max_retry_delay":
+			if h.MaxRetryDelay != nil {
+				return duplicateArgumentError(token, context)
+			}
+			ret, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			h.MaxRetryDelay = &ret
+		case "request_timeout":
+			if h.RequestTimeout != nil {
+				return duplicateArgumentError(token, context)
+			}
+			ret, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			h.RequestTimeout = &ret
+		default:
+			return unknownArgumentError(token, context)
+		}
+	}
+}
+
 func NewFilterDesc() *FilterDesc {
 	super := &FilterDesc{}
 	super.BaseDesc.super = super
@@ -894,6 +1596,9 @@ type StoreTableDesc struct {
 	BaseDesc
 	KeyCols   []string
 	Retention *time.Duration
+	// Changelog, when true, makes the table emit a changelog row for every insert, update and delete instead of
+	// forwarding the incoming rows
+	Changelog *bool
 }
 
 func (s *StoreTableDesc) parse(context *ParseContext) error {
@@ -921,25 +1626,36 @@ func (s *StoreTableDesc) parse(context *ParseContext) error {
 		return errorAtPosition(`no key columns specified`, nextToken.Pos, context.input)
 	}
 	s.KeyCols = cols
-	token, ok = context.NextToken()
-	if !ok {
-		return endOfInputError()
-	}
-	if token.Value == ")" {
-		return nil
-	}
-	if token.Value != "retention" {
-		return foundUnexpectedTokenError("retention", token, context.input)
-	}
-	retention, err := parseDurationArg(context)
-	if err != nil {
-		return err
-	}
-	s.Retention = &retention
-	if _, err := context.expectToken(")"); err != nil {
-		return err
+	for {
+		token, ok = context.NextToken()
+		if !ok {
+			return endOfInputError()
+		}
+		switch token.Value {
+		case ")":
+			return nil
+		case "retention":
+			if s.Retention != nil {
+				return duplicateArgumentError(token, context)
+			}
+			retention, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			s.Retention = &retention
+		case "changelog":
+			if s.Changelog != nil {
+				return duplicateArgumentError(token, context)
+			}
+			changelog, err := parseBool(context)
+			if err != nil {
+				return err
+			}
+			s.Changelog = &changelog
+		default:
+			return foundUnexpectedTokenError(expectedStr("retention", "changelog"), token, context.input)
+		}
 	}
-	return nil
 }
 
 func NewProjectDesc() *ProjectDesc {
@@ -1614,55 +2330,626 @@ func (b *BackfillDesc) parse(context *ParseContext) error {
 	return err
 }
 
-func NewGetDesc() *GetDesc {
-	super := &GetDesc{}
+func NewDedupDesc() *DedupDesc {
+	super := &DedupDesc{}
 	super.BaseDesc.super = super
 	return super
 }
 
-type GetDesc struct {
+type DedupDesc struct {
 	BaseDesc
-	KeyExprs  []ExprDesc
-	TableName string
+	KeyExprs        []ExprDesc
+	KeyExprsStrings []string
+	Within          *time.Duration
+	EmitDuplicates  *bool
 }
 
-func (g *GetDesc) parse(context *ParseContext) error {
+func (d *DedupDesc) parse(context *ParseContext) error {
 	context.MoveCursor(1)
-	_, keyExprs, err := parseExpressions(context)
+	if _, err := context.expectToken("by"); err != nil {
+		return err
+	}
+	keyExprStrings, keyExprs, err := parseExpressions(context)
 	if err != nil {
 		return err
 	}
-	if len(keyExprs) < 1 {
-		nextToken, ok := context.PeekToken()
+	if len(keyExprs) == 0 {
+		tok, ok := context.PeekToken()
 		if !ok {
 			return endOfInputError()
 		}
-		return errorAtPosition("there must be at least one key expression", nextToken.Pos, context.input)
-	}
-	g.KeyExprs = keyExprs
-	_, err = context.expectToken("from")
-	if err != nil {
-		return err
-	}
-	token, ok := context.NextToken()
-	if !ok {
-		return endOfInputError()
-	}
-	if token.Type != IdentTokenType {
-		return foundUnexpectedTokenError("identifier", token, context.input)
+		return emptyKeyExpressionsError(tok.Pos, context)
 	}
-	g.TableName = token.Value
-	_, err = context.expectToken(")")
-	return err
-}
-
-func (g *GetDesc) clearTokenState() {
-	g.BaseDesc.clearTokenState()
-	for _, expr := range g.KeyExprs {
-		clearable, ok := expr.(tokenClearable)
-		if ok {
-			clearable.clearTokenState()
-		}
+	d.KeyExprs = keyExprs
+	d.KeyExprsStrings = keyExprStrings
+	for {
+		token, ok := context.NextToken()
+		if !ok {
+			break
+		}
+		if token.Value == ")" {
+			// End of operator definition
+			return nil
+		}
+		// Must be optional arg
+		if token.Type != IdentTokenType {
+			return foundUnexpectedTokenError("identifier", token, context.input)
+		}
+		switch token.Value {
+		case "within":
+			if d.Within != nil {
+				return duplicateArgumentError(token, context)
+			}
+			within, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			d.Within = &within
+		case "emit_duplicates":
+			if d.EmitDuplicates != nil {
+				return duplicateArgumentError(token, context)
+			}
+			emitDuplicates, err := parseBool(context)
+			if err != nil {
+				return err
+			}
+			d.EmitDuplicates = &emitDuplicates
+		default:
+			return unknownArgumentError(token, context)
+		}
+	}
+	return nil
+}
+
+func (d *DedupDesc) clearTokenState() {
+	d.BaseDesc.clearTokenState()
+	for _, expr := range d.KeyExprs {
+		clearable, ok := expr.(tokenClearable)
+		if ok {
+			clearable.clearTokenState()
+		}
+	}
+}
+
+func NewTopNDesc() *TopNDesc {
+	super := &TopNDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+type TopNDesc struct {
+	BaseDesc
+	N               int
+	OrderExprs      []ExprDesc
+	KeyExprs        []ExprDesc
+	KeyExprsStrings []string
+	Store           *bool
+}
+
+func (t *TopNDesc) parse(context *ParseContext) error {
+	context.MoveCursor(1)
+	token, err := context.expectToken()
+	if err != nil {
+		return err
+	}
+	if token.Type != IntegerTokenType {
+		return foundUnexpectedTokenError("integer", token, context.input)
+	}
+	n, err := strconv.Atoi(token.Value)
+	if err != nil || n < 1 {
+		return errorAtPosition(fmt.Sprintf("%s is not a positive integer", token.Value), token.Pos, context.input)
+	}
+	t.N = n
+	if _, err := context.expectToken("order_by"); err != nil {
+		return err
+	}
+	_, orderExprs, err := parseExpressions(context)
+	if err != nil {
+		return err
+	}
+	if len(orderExprs) == 0 {
+		tok, ok := context.PeekToken()
+		if !ok {
+			return endOfInputError()
+		}
+		return errorAtPosition(`at least one order expression must be specified`, tok.Pos, context.input)
+	}
+	t.OrderExprs = orderExprs
+	for {
+		token, ok := context.NextToken()
+		if !ok {
+			break
+		}
+		if token.Value == ")" {
+			// End of operator definition
+			return nil
+		}
+		// Must be optional arg
+		if token.Type != IdentTokenType {
+			return foundUnexpectedTokenError("identifier", token, context.input)
+		}
+		switch token.Value {
+		case "by":
+			if t.KeyExprs != nil {
+				return duplicateArgumentError(token, context)
+			}
+			keyExprStrings, keyExprs, err := parseExpressions(context)
+			if err != nil {
+				return err
+			}
+			if len(keyExprs) == 0 {
+				tok, ok := context.PeekToken()
+				if !ok {
+					return endOfInputError()
+				}
+				return emptyKeyExpressionsError(tok.Pos, context)
+			}
+			t.KeyExprs = keyExprs
+			t.KeyExprsStrings = keyExprStrings
+		case "store":
+			if t.Store != nil {
+				return duplicateArgumentError(token, context)
+			}
+			store, err := parseBool(context)
+			if err != nil {
+				return err
+			}
+			t.Store = &store
+		default:
+			return unknownArgumentError(token, context)
+		}
+	}
+	return nil
+}
+
+func (t *TopNDesc) clearTokenState() {
+	t.BaseDesc.clearTokenState()
+	for _, expr := range t.OrderExprs {
+		clearable, ok := expr.(tokenClearable)
+		if ok {
+			clearable.clearTokenState()
+		}
+	}
+	for _, expr := range t.KeyExprs {
+		clearable, ok := expr.(tokenClearable)
+		if ok {
+			clearable.clearTokenState()
+		}
+	}
+}
+
+func NewMatchRecognizeDesc() *MatchRecognizeDesc {
+	super := &MatchRecognizeDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+type MatchRecognizeDesc struct {
+	BaseDesc
+	KeyExprs          []ExprDesc
+	KeyExprsStrings   []string
+	DefineExprs       []ExprDesc
+	DefineExprStrings []string
+	PatternString     string
+	Pattern           []PatternElement
+	Within            *time.Duration
+}
+
+// PatternElement is a pattern variable along with its quantifier. Max is -1 if the number of matches is unbounded.
+type PatternElement struct {
+	Var string
+	Min int
+	Max int
+}
+
+func (m *MatchRecognizeDesc) parse(context *ParseContext) error {
+	context.MoveCursor(1)
+	if _, err := context.expectToken("by"); err != nil {
+		return err
+	}
+	keyExprStrings, keyExprs, err := parseExpressions(context)
+	if err != nil {
+		return err
+	}
+	if len(keyExprs) == 0 {
+		tok, ok := context.PeekToken()
+		if !ok {
+			return endOfInputError()
+		}
+		return emptyKeyExpressionsError(tok.Pos, context)
+	}
+	m.KeyExprs = keyExprs
+	m.KeyExprsStrings = keyExprStrings
+	for {
+		token, ok := context.NextToken()
+		if !ok {
+			break
+		}
+		if token.Value == ")" {
+			// End of operator definition
+			return nil
+		}
+		// Must be optional arg
+		if token.Type != IdentTokenType {
+			return foundUnexpectedTokenError("identifier", token, context.input)
+		}
+		switch token.Value {
+		case "define":
+			if m.DefineExprs != nil {
+				return duplicateArgumentError(token, context)
+			}
+			defineExprStrings, defineExprs, err := parseExpressions(context)
+			if err != nil {
+				return err
+			}
+			if len(defineExprs) == 0 {
+				tok, ok := context.PeekToken()
+				if !ok {
+					return endOfInputError()
+				}
+				return emptyKeyExpressionsError(tok.Pos, context)
+			}
+			m.DefineExprs = defineExprs
+			m.DefineExprStrings = defineExprStrings
+		case "pattern":
+			if m.Pattern != nil {
+				return duplicateArgumentError(token, context)
+			}
+			tok, err := parseNamedArgValue(StringLiteralTokenType, "string literal", context)
+			if err != nil {
+				return err
+			}
+			m.PatternString = stripQuotes(tok.Value)
+			m.Pattern, err = ParsePattern(m.PatternString)
+			if err != nil {
+				return errorAtPosition(fmt.Sprintf("invalid pattern: %v", err), tok.Pos, context.input)
+			}
+		case "within":
+			if m.Within != nil {
+				return duplicateArgumentError(token, context)
+			}
+			within, err := parseDurationArg(context)
+			if err != nil {
+				return err
+			}
+			m.Within = &within
+		default:
+			return unknownArgumentError(token, context)
+		}
+	}
+	return nil
+}
+
+func (m *MatchRecognizeDesc) clearTokenState() {
+	m.BaseDesc.clearTokenState()
+	for _, expr := range m.KeyExprs {
+		clearable, ok := expr.(tokenClearable)
+		if ok {
+			clearable.clearTokenState()
+		}
+	}
+	for _, expr := range m.DefineExprs {
+		clearable, ok := expr.(tokenClearable)
+		if ok {
+			clearable.clearTokenState()
+		}
+	}
+}
+
+// ParsePattern parses a pattern such as "fail{3,} ok?" into its elements. Each element is a variable name, optionally
+// followed by a quantifier: '?', '*', '+', '{n}', '{n,}' or '{n,m}'.
+func ParsePattern(pattern string) ([]PatternElement, error) {
+	var elements []PatternElement
+	pos := 0
+	for {
+		for pos < len(pattern) && pattern[pos] == ' ' {
+			pos++
+		}
+		if pos == len(pattern) {
+			break
+		}
+		start := pos
+		for pos < len(pattern) && isPatternVarChar(pattern[pos]) {
+			pos++
+		}
+		if start == pos {
+			return nil, fmt.Errorf("expected variable name at position %d", pos)
+		}
+		element := PatternElement{Var: pattern[start:pos], Min: 1, Max: 1}
+		if pos < len(pattern) {
+			switch pattern[pos] {
+			case '?':
+				element.Min, element.Max = 0, 1
+				pos++
+			case '*':
+				element.Min, element.Max = 0, -1
+				pos++
+			case '+':
+				element.Min, element.Max = 1, -1
+				pos++
+			case '{':
+				end := strings.IndexByte(pattern[pos:], '}')
+				if end == -1 {
+					return nil, fmt.Errorf("unclosed quantifier at position %d", pos)
+				}
+				var err error
+				element.Min, element.Max, err = parseQuantifierBounds(pattern[pos+1 : pos+end])
+				if err != nil {
+					return nil, fmt.Errorf("invalid quantifier at position %d: %v", pos, err)
+				}
+				pos += end + 1
+			}
+		}
+		if pos < len(pattern) && pattern[pos] != ' ' {
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", pattern[pos], pos)
+		}
+		elements = append(elements, element)
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+	canBeEmpty := true
+	for _, element := range elements {
+		if element.Min > 0 {
+			canBeEmpty = false
+		}
+	}
+	if canBeEmpty {
+		return nil, fmt.Errorf("pattern must require at least one row")
+	}
+	return elements, nil
+}
+
+func NewDecodeDesc() *DecodeDesc {
+	super := &DecodeDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+// DecodeDesc describes a decode operator, which decodes a bytes column written in the schema registry wire format
+// into typed columns, e.g. (decode val subject="orders-value")
+type DecodeDesc struct {
+	BaseDesc
+	ColumnName string
+	Subject    *string
+	Version    *int
+	Message    *string
+}
+
+func (d *DecodeDesc) parse(context *ParseContext) error {
+	context.MoveCursor(1)
+	token, ok := context.NextToken()
+	if !ok {
+		return endOfInputError()
+	}
+	if token.Type != IdentTokenType {
+		return foundUnexpectedTokenError("column name", token, context.input)
+	}
+	d.ColumnName = token.Value
+	for {
+		token, ok := context.NextToken()
+		if !ok {
+			break
+		}
+		if token.Value == ")" {
+			// End of operator definition
+			return nil
+		}
+		// Must be optional arg
+		if token.Type != IdentTokenType {
+			return foundUnexpectedTokenError("identifier", token, context.input)
+		}
+		if err := parseSchemaArg(token, &d.Subject, &d.Version, &d.Message, context); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewEncodeDesc() *EncodeDesc {
+	super := &EncodeDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+// EncodeDesc describes an encode operator, which encodes columns into a 'val' column in the schema registry wire
+// format, ready for 'kafka out' or 'bridge to', e.g. (encode subject="orders-value")
+type EncodeDesc struct {
+	BaseDesc
+	Subject *string
+	Version *int
+	Message *string
+}
+
+func (e *EncodeDesc) parse(context *ParseContext) error {
+	context.MoveCursor(1)
+	for {
+		token, ok := context.NextToken()
+		if !ok {
+			break
+		}
+		if token.Value == ")" {
+			// End of operator definition
+			return nil
+		}
+		// Must be optional arg
+		if token.Type != IdentTokenType {
+			return foundUnexpectedTokenError("identifier", token, context.input)
+		}
+		if err := parseSchemaArg(token, &e.Subject, &e.Version, &e.Message, context); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewExplodeDesc() *ExplodeDesc {
+	super := &ExplodeDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+// ExplodeDesc describes an explode operator, which outputs a row for each element of an array or map column, e.g.
+// (explode tags as tag)
+type ExplodeDesc struct {
+	BaseDesc
+	ColumnName string
+	Alias      string
+}
+
+func (e *ExplodeDesc) parse(context *ParseContext) error {
+	context.MoveCursor(1)
+	token, err := context.expectToken()
+	if err != nil {
+		return err
+	}
+	if token.Type != IdentTokenType {
+		return foundUnexpectedTokenError("identifier", token, context.input)
+	}
+	e.ColumnName = token.Value
+	token, err = context.expectToken(")", "as")
+	if err != nil {
+		return err
+	}
+	if token.Value == ")" {
+		return nil
+	}
+	token, err = context.expectToken()
+	if err != nil {
+		return err
+	}
+	if token.Type != IdentTokenType {
+		return foundUnexpectedTokenError("identifier", token, context.input)
+	}
+	e.Alias = token.Value
+	_, err = context.expectToken(")")
+	return err
+}
+
+// parseSchemaArg parses one of the arguments which identify a schema in the schema registry
+func parseSchemaArg(token lexer.Token, subject **string, version **int, message **string, context *ParseContext) error {
+	switch token.Value {
+	case "subject":
+		if *subject != nil {
+			return duplicateArgumentError(token, context)
+		}
+		tok, err := parseNamedArgValue(StringLiteralTokenType, "string literal", context)
+		if err != nil {
+			return err
+		}
+		s := stripQuotes(tok.Value)
+		*subject = &s
+	case "version":
+		if *version != nil {
+			return duplicateArgumentError(token, context)
+		}
+		tok, err := parseNamedArgValue(IntegerTokenType, "integer", context)
+		if err != nil {
+			return err
+		}
+		v, err := strconv.Atoi(tok.Value)
+		if err != nil {
+			return err
+		}
+		*version = &v
+	case "message":
+		if *message != nil {
+			return duplicateArgumentError(token, context)
+		}
+		tok, err := parseNamedArgValue(StringLiteralTokenType, "string literal", context)
+		if err != nil {
+			return err
+		}
+		m := stripQuotes(tok.Value)
+		*message = &m
+	default:
+		return unknownArgumentError(token, context)
+	}
+	return nil
+}
+
+func isPatternVarChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func parseQuantifierBounds(s string) (int, int, error) {
+	lower, upper, hasComma := strings.Cut(s, ",")
+	min, err := strconv.Atoi(strings.TrimSpace(lower))
+	if err != nil || min < 0 {
+		return 0, 0, fmt.Errorf("'%s' is not a valid lower bound", lower)
+	}
+	if !hasComma {
+		if min == 0 {
+			return 0, 0, fmt.Errorf("exact bound must be > 0")
+		}
+		return min, min, nil
+	}
+	upper = strings.TrimSpace(upper)
+	if upper == "" {
+		return min, -1, nil
+	}
+	max, err := strconv.Atoi(upper)
+	if err != nil || max < 1 || max < min {
+		return 0, 0, fmt.Errorf("'%s' is not a valid upper bound", upper)
+	}
+	return min, max, nil
+}
+
+func NewGetDesc() *GetDesc {
+	super := &GetDesc{}
+	super.BaseDesc.super = super
+	return super
+}
+
+type GetDesc struct {
+	BaseDesc
+	KeyExprs    []ExprDesc
+	TableName   string
+	AsOfVersion *int
+	AsOfTime    *time.Time
+}
+
+func (g *GetDesc) parse(context *ParseContext) error {
+	context.MoveCursor(1)
+	_, keyExprs, err := parseExpressions(context)
+	if err != nil {
+		return err
+	}
+	if len(keyExprs) < 1 {
+		nextToken, ok := context.PeekToken()
+		if !ok {
+			return endOfInputError()
+		}
+		return errorAtPosition("there must be at least one key expression", nextToken.Pos, context.input)
+	}
+	g.KeyExprs = keyExprs
+	_, err = context.expectToken("from")
+	if err != nil {
+		return err
+	}
+	token, ok := context.NextToken()
+	if !ok {
+		return endOfInputError()
+	}
+	if token.Type != IdentTokenType {
+		return foundUnexpectedTokenError("identifier", token, context.input)
+	}
+	g.TableName = token.Value
+	g.AsOfVersion, g.AsOfTime, err = parseAsOf(context)
+	if err != nil {
+		return err
+	}
+	_, err = context.expectToken(")")
+	return err
+}
+
+func (g *GetDesc) clearTokenState() {
+	g.BaseDesc.clearTokenState()
+	for _, expr := range g.KeyExprs {
+		clearable, ok := expr.(tokenClearable)
+		if ok {
+			clearable.clearTokenState()
+		}
 	}
 }
 
@@ -1680,6 +2967,8 @@ type ScanDesc struct {
 	FromIncl     bool
 	TableName    string
 	All          bool
+	AsOfVersion  *int
+	AsOfTime     *time.Time
 }
 
 func (s *ScanDesc) parse(context *ParseContext) error {
@@ -1695,10 +2984,53 @@ func (s *ScanDesc) parse(context *ParseContext) error {
 		return foundUnexpectedTokenError("identifier", token, context.input)
 	}
 	s.TableName = token.Value
-	_, err := context.expectToken(")")
+	var err error
+	s.AsOfVersion, s.AsOfTime, err = parseAsOf(context)
+	if err != nil {
+		return err
+	}
+	_, err = context.expectToken(")")
 	return err
 }
 
+// parseAsOf parses the optional as_of argument of a get or scan, which is either a version or an RFC 3339 timestamp
+// string, e.g. as_of = 12345 or as_of = "2024-05-01T09:30:00Z"
+func parseAsOf(context *ParseContext) (*int, *time.Time, error) {
+	token, ok := context.PeekToken()
+	if !ok {
+		return nil, nil, endOfInputError()
+	}
+	if token.Value != "as_of" {
+		return nil, nil, nil
+	}
+	context.NextToken()
+	token, skippedPastEquals, ok := skipPastOptionalEquals(context)
+	if !ok {
+		return nil, nil, endOfInputError()
+	}
+	switch token.Type {
+	case IntegerTokenType:
+		version, err := strconv.Atoi(token.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &version, nil, nil
+	case StringLiteralTokenType:
+		asOfTime, err := time.Parse(time.RFC3339Nano, stripQuotes(token.Value))
+		if err != nil {
+			return nil, nil, errorAtPosition("as_of timestamp must be in RFC 3339 format, e.g. \"2024-05-01T09:30:00Z\"",
+				token.Pos, context.input)
+		}
+		return nil, &asOfTime, nil
+	default:
+		expected := "integer or string literal"
+		if !skippedPastEquals {
+			expected = fmt.Sprintf(`'=' or %s`, expected)
+		}
+		return nil, nil, foundUnexpectedTokenError(expected, token, context.input)
+	}
+}
+
 func (s *ScanDesc) parseRange(context *ParseContext) error {
 	nextToken, ok := context.PeekToken()
 	if !ok {
@@ -1998,6 +3330,14 @@ func parseDurationArg(context *ParseContext) (time.Duration, error) {
 	return dur, nil
 }
 
+func parseIntArg(context *ParseContext) (int, error) {
+	tok, err := parseNamedArgValue(IntegerTokenType, "integer", context)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(tok.Value)
+}
+
 func parseWatermarkType(context *ParseContext) (string, error) {
 	tok, err := parseNamedArgValue(IdentTokenType, "identifier", context)
 	if err != nil {
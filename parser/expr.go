@@ -764,4 +764,9 @@ var BuiltinFunctions = map[string]struct{}{
 	"uint64_le":   {},
 
 	"abs": {},
+
+	"element_at": {},
+	"size":       {},
+	"from_json":  {},
+	"to_json":    {},
 }
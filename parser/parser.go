@@ -15,11 +15,11 @@ var lex = lexer.MustSimple([]lexer.SimpleRule{
 	// Note - there is ambiguity for "==" as this appears is a valid expr, so we omit it from JoinType
 	{"JoinType", `(?:\*=|=\*)`},
 	{"UnaryPostfixOp", `(?:ascending|asc|descending|desc)`},
-	{"BinaryOp", `(?:as|==|!=|<=|>=|&&|\|\||[-+\*/%<>])`},
+	{"BinaryOp", `(?:as\b|==|!=|<=|>=|&&|\|\||[-+\*/%<>])`},
 	{"UnaryOp", `!`},
 	{"ArgAssignment", `=`},
 	{"BoolLiteral", `(?:true|false)`},
-	{"Ident", `\$?[a-zA-Z_](?:[a-zA-Z0-9_.\-]*[a-zA-Z0-9])?(\:(?:int|float|bool|decimal\(\d+,\s*\d+\)|string|bytes|timestamp))?`},
+	{"Ident", `\$?[a-zA-Z_](?:[a-zA-Z0-9_.\-]*[a-zA-Z0-9])?(\:(?:int|float|bool|decimal\(\d+,\s*\d+\)|string|bytes|timestamp|(?:array|map|struct)<[a-zA-Z0-9_<>:,()]*>))?`},
 	{"ListSeparator", `,`},
 	{"LParens", `\(`},
 	{"RParens", `\)`},
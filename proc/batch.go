@@ -3,6 +3,7 @@ package proc
 import (
 	"github.com/spirit-labs/tektite/evbatch"
 	"github.com/spirit-labs/tektite/mem"
+	"sync"
 )
 
 type BatchHandler interface {
@@ -34,6 +35,45 @@ type ProcessBatch struct {
 	BackFill              bool
 	EvBatch               *evbatch.Batch
 	EvBatchBytes          []byte
+
+	deferredCompletionFunc func(error)
+	completionDeferred     bool
+}
+
+// ProcessInjected processes a batch injected into a processor and then completes it with the result, unless the
+// receiver of the batch deferred completion, in which case it is completed when the receiver calls the deferred
+// completion function. submit runs a function on the processor loop - deferred completions are called there.
+func (pb *ProcessBatch) ProcessInjected(process func() error, submit func(func()), completionFunc func(error)) {
+	var once sync.Once
+	complete := func(err error) {
+		once.Do(func() {
+			completionFunc(err)
+		})
+	}
+	pb.deferredCompletionFunc = func(err error) {
+		submit(func() {
+			complete(err)
+		})
+	}
+	pb.completionDeferred = false
+	err := process()
+	pb.deferredCompletionFunc = nil
+	// If processing failed after completion was deferred, the batch is completed with the error now and the deferred
+	// completion is ignored
+	if err != nil || !pb.completionDeferred {
+		complete(err)
+	}
+}
+
+// DeferCompletion is called by the receiver of an injected batch which finishes handling it asynchronously, e.g.
+// after writing to the object store, so the processor is not blocked. The batch is completed when the returned
+// function is called, rather than when the receiver returns.
+func (pb *ProcessBatch) DeferCompletion() func(error) {
+	if pb.deferredCompletionFunc == nil {
+		panic("completion can only be deferred for an injected batch while it is being processed")
+	}
+	pb.completionDeferred = true
+	return pb.deferredCompletionFunc
 }
 
 func (pb *ProcessBatch) Copy() *ProcessBatch {
@@ -114,6 +114,19 @@ func (l *LevelManagerLocalClient) LoadLastFlushedVersion() (int64, error) {
 	return resp.LastFlushedVersion, nil
 }
 
+func (l *LevelManagerLocalClient) GetOldestRetainedVersion() (int64, error) {
+	if l.processorManager == nil {
+		panic("processor manager not set")
+	}
+	req := &clustermsgs.LevelManagerGetOldestRetainedVersionMessage{}
+	r, err := l.sendLevelManagerRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	resp := r.(*clustermsgs.LevelManagerGetOldestRetainedVersionResponse)
+	return resp.OldestRetainedVersion, nil
+}
+
 func (l *LevelManagerLocalClient) GetStats() (levels.Stats, error) {
 	if l.processorManager == nil {
 		panic("processor manager not set")
@@ -762,6 +762,10 @@ func (t *testVmgrClient) IsFailureComplete(int) (bool, error) {
 	panic("not implemented")
 }
 
+func (t *testVmgrClient) GetVersionForTime(int64) (int, error) {
+	panic("not implemented")
+}
+
 func (t *testVmgrClient) VersionFlushed(int, int, int) error {
 	return nil
 }
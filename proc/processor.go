@@ -302,6 +302,16 @@ func (p *processor) IngestBatch(batch *ProcessBatch, completionFunc func(error))
 	})
 }
 
+// submitFunc runs the function on the processor loop, or straight away if the processor has been stopped
+func (p *processor) submitFunc(f func()) {
+	if !p.SubmitAction(func() error {
+		f()
+		return nil
+	}) {
+		f()
+	}
+}
+
 func (p *processor) SetNotIdleNotifier(notifier func()) {
 	p.notIdleNotifier = notifier
 }
@@ -363,7 +373,9 @@ func (p *processor) doHandleBatch(batch *ProcessBatch, reprocess bool, completio
 			// Assign a version
 			batch.Version = p.currentVersion
 			// Process it now
-			completionFunc(p.processBatch(batch, reprocess))
+			batch.ProcessInjected(func() error {
+				return p.processBatch(batch, reprocess)
+			}, p.submitFunc, completionFunc)
 			return
 		}
 		if info.delaying && batch.Version > info.lastBarrierVersion {
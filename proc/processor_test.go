@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/emirpasic/gods/maps/treemap"
+	"github.com/pkg/errors"
 	"github.com/spirit-labs/tektite/asl/conf"
 	"github.com/spirit-labs/tektite/asl/encoding"
 	"github.com/spirit-labs/tektite/asl/remoting"
@@ -79,6 +80,43 @@ func TestProcessBatch(t *testing.T) {
 	require.Equal(t, []byte("val1"), v)
 }
 
+func TestProcessBatchDeferredCompletion(t *testing.T) {
+	processorID := 1234
+	batchHandler := &testBatchHandler{
+		deferCompletion: true,
+	}
+	proc := createProcessor(t, processorID, &testForwarder{}, batchHandler, &testReceiverInfoProvider{})
+	proc.SetLeader()
+	proc.CloseVersion(0, nil)
+
+	batch := NewProcessBatch(processorID, nil, 1, 1, -1)
+	ch := make(chan error, 1)
+	proc.IngestBatch(batch, func(err error) {
+		ch <- err
+	})
+	// The batch is not completed when the handler returns
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 0, len(ch))
+
+	// The handler has run, as actions are run in order
+	completionFuncCh := make(chan func(error), 1)
+	proc.SubmitAction(func() error {
+		completionFuncCh <- batchHandler.completionFunc
+		return nil
+	})
+	completionFunc := <-completionFuncCh
+	require.NotNil(t, completionFunc)
+	completionFunc(errors.New("upload failed"))
+	err := <-ch
+	require.Error(t, err)
+	require.Equal(t, "upload failed", err.Error())
+
+	// The batch is only completed once
+	completionFunc(nil)
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, 0, len(ch))
+}
+
 func TestActionsAfterStopNotRun(t *testing.T) {
 
 	batchHandler := &testBatchHandler{}
@@ -1251,6 +1289,8 @@ type testBatchHandler struct {
 	memBatch         *mem.Batch
 	forwardedBatches []*ProcessBatch
 	err              error
+	deferCompletion  bool
+	completionFunc   func(error)
 }
 
 type batchInfo struct {
@@ -1268,6 +1308,9 @@ func (t *testBatchHandler) HandleProcessBatch(processor Processor, processBatch
 		processor:    processor,
 		processBatch: processBatch,
 	})
+	if t.deferCompletion {
+		t.completionFunc = processBatch.DeferCompletion()
+	}
 	return true, t.memBatch, t.forwardedBatches, t.err
 }
 
@@ -115,6 +115,15 @@ func (c *VersionManagerClient) VersionFlushed(processorID int, version int, clus
 	return err
 }
 
+func (c *VersionManagerClient) GetVersionForTime(unixMillis int64) (int, error) {
+	r, err := c.sendMsg(&clustermsgs.GetVersionForTimeMessage{Time: unixMillis}, false)
+	if err != nil {
+		return 0, err
+	}
+	resp := r.(*clustermsgs.GetVersionForTimeResponse)
+	return int(resp.Version), nil
+}
+
 func (c *VersionManagerClient) sendMsg(msg remoting.ClusterMessage, retry bool) (remoting.ClusterMessage, error) {
 	for {
 		leader, err := c.mgr.GetLeaderNode(vmgr.VersionManagerProcessorID)
@@ -940,6 +940,91 @@ func (x *LevelManagerStoreLastFlushedVersionMessage) GetLastFlushedVersion() int
 	return 0
 }
 
+type LevelManagerGetOldestRetainedVersionMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *LevelManagerGetOldestRetainedVersionMessage) Reset() {
+	*x = LevelManagerGetOldestRetainedVersionMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_clustermsgs_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LevelManagerGetOldestRetainedVersionMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LevelManagerGetOldestRetainedVersionMessage) ProtoMessage() {}
+
+func (x *LevelManagerGetOldestRetainedVersionMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_clustermsgs_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LevelManagerGetOldestRetainedVersionMessage.ProtoReflect.Descriptor instead.
+func (*LevelManagerGetOldestRetainedVersionMessage) Descriptor() ([]byte, []int) {
+	return file_clustermsgs_proto_rawDescGZIP(), []int{15}
+}
+
+type LevelManagerGetOldestRetainedVersionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	OldestRetainedVersion int64 `protobuf:"varint,1,opt,name=oldest_retained_version,json=oldestRetainedVersion,proto3" json:"oldest_retained_version,omitempty"`
+}
+
+func (x *LevelManagerGetOldestRetainedVersionResponse) Reset() {
+	*x = LevelManagerGetOldestRetainedVersionResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_clustermsgs_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LevelManagerGetOldestRetainedVersionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LevelManagerGetOldestRetainedVersionResponse) ProtoMessage() {}
+
+func (x *LevelManagerGetOldestRetainedVersionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_clustermsgs_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LevelManagerGetOldestRetainedVersionResponse.ProtoReflect.Descriptor instead.
+func (*LevelManagerGetOldestRetainedVersionResponse) Descriptor() ([]byte, []int) {
+	return file_clustermsgs_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *LevelManagerGetOldestRetainedVersionResponse) GetOldestRetainedVersion() int64 {
+	if x != nil {
+		return x.OldestRetainedVersion
+	}
+	return 0
+}
+
 type LevelManagerGetStatsMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -949,7 +1034,7 @@ type LevelManagerGetStatsMessage struct {
 func (x *LevelManagerGetStatsMessage) Reset() {
 	*x = LevelManagerGetStatsMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[15]
+		mi := &file_clustermsgs_proto_msgTypes[17]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -962,7 +1047,7 @@ func (x *LevelManagerGetStatsMessage) String() string {
 func (*LevelManagerGetStatsMessage) ProtoMessage() {}
 
 func (x *LevelManagerGetStatsMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[15]
+	mi := &file_clustermsgs_proto_msgTypes[17]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -975,7 +1060,7 @@ func (x *LevelManagerGetStatsMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LevelManagerGetStatsMessage.ProtoReflect.Descriptor instead.
 func (*LevelManagerGetStatsMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{15}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{17}
 }
 
 type LevelManagerGetStatsResponse struct {
@@ -989,7 +1074,7 @@ type LevelManagerGetStatsResponse struct {
 func (x *LevelManagerGetStatsResponse) Reset() {
 	*x = LevelManagerGetStatsResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[16]
+		mi := &file_clustermsgs_proto_msgTypes[18]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1002,7 +1087,7 @@ func (x *LevelManagerGetStatsResponse) String() string {
 func (*LevelManagerGetStatsResponse) ProtoMessage() {}
 
 func (x *LevelManagerGetStatsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[16]
+	mi := &file_clustermsgs_proto_msgTypes[18]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1015,7 +1100,7 @@ func (x *LevelManagerGetStatsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LevelManagerGetStatsResponse.ProtoReflect.Descriptor instead.
 func (*LevelManagerGetStatsResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{16}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *LevelManagerGetStatsResponse) GetPayload() []byte {
@@ -1037,7 +1122,7 @@ type LevelManagerRegisterSlabRetentionMessage struct {
 func (x *LevelManagerRegisterSlabRetentionMessage) Reset() {
 	*x = LevelManagerRegisterSlabRetentionMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[17]
+		mi := &file_clustermsgs_proto_msgTypes[19]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1050,7 +1135,7 @@ func (x *LevelManagerRegisterSlabRetentionMessage) String() string {
 func (*LevelManagerRegisterSlabRetentionMessage) ProtoMessage() {}
 
 func (x *LevelManagerRegisterSlabRetentionMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[17]
+	mi := &file_clustermsgs_proto_msgTypes[19]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1063,7 +1148,7 @@ func (x *LevelManagerRegisterSlabRetentionMessage) ProtoReflect() protoreflect.M
 
 // Deprecated: Use LevelManagerRegisterSlabRetentionMessage.ProtoReflect.Descriptor instead.
 func (*LevelManagerRegisterSlabRetentionMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{17}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *LevelManagerRegisterSlabRetentionMessage) GetSlabId() int64 {
@@ -1091,7 +1176,7 @@ type LevelManagerUnregisterSlabRetentionMessage struct {
 func (x *LevelManagerUnregisterSlabRetentionMessage) Reset() {
 	*x = LevelManagerUnregisterSlabRetentionMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[18]
+		mi := &file_clustermsgs_proto_msgTypes[20]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1104,7 +1189,7 @@ func (x *LevelManagerUnregisterSlabRetentionMessage) String() string {
 func (*LevelManagerUnregisterSlabRetentionMessage) ProtoMessage() {}
 
 func (x *LevelManagerUnregisterSlabRetentionMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[18]
+	mi := &file_clustermsgs_proto_msgTypes[20]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1117,7 +1202,7 @@ func (x *LevelManagerUnregisterSlabRetentionMessage) ProtoReflect() protoreflect
 
 // Deprecated: Use LevelManagerUnregisterSlabRetentionMessage.ProtoReflect.Descriptor instead.
 func (*LevelManagerUnregisterSlabRetentionMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{18}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *LevelManagerUnregisterSlabRetentionMessage) GetSlabId() int64 {
@@ -1138,7 +1223,7 @@ type LevelManagerGetSlabRetentionMessage struct {
 func (x *LevelManagerGetSlabRetentionMessage) Reset() {
 	*x = LevelManagerGetSlabRetentionMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[19]
+		mi := &file_clustermsgs_proto_msgTypes[21]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1151,7 +1236,7 @@ func (x *LevelManagerGetSlabRetentionMessage) String() string {
 func (*LevelManagerGetSlabRetentionMessage) ProtoMessage() {}
 
 func (x *LevelManagerGetSlabRetentionMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[19]
+	mi := &file_clustermsgs_proto_msgTypes[21]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1164,7 +1249,7 @@ func (x *LevelManagerGetSlabRetentionMessage) ProtoReflect() protoreflect.Messag
 
 // Deprecated: Use LevelManagerGetSlabRetentionMessage.ProtoReflect.Descriptor instead.
 func (*LevelManagerGetSlabRetentionMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{19}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *LevelManagerGetSlabRetentionMessage) GetSlabId() int64 {
@@ -1185,7 +1270,7 @@ type LevelManagerGetSlabRetentionResponse struct {
 func (x *LevelManagerGetSlabRetentionResponse) Reset() {
 	*x = LevelManagerGetSlabRetentionResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[20]
+		mi := &file_clustermsgs_proto_msgTypes[22]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1198,7 +1283,7 @@ func (x *LevelManagerGetSlabRetentionResponse) String() string {
 func (*LevelManagerGetSlabRetentionResponse) ProtoMessage() {}
 
 func (x *LevelManagerGetSlabRetentionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[20]
+	mi := &file_clustermsgs_proto_msgTypes[22]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1211,7 +1296,7 @@ func (x *LevelManagerGetSlabRetentionResponse) ProtoReflect() protoreflect.Messa
 
 // Deprecated: Use LevelManagerGetSlabRetentionResponse.ProtoReflect.Descriptor instead.
 func (*LevelManagerGetSlabRetentionResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{20}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *LevelManagerGetSlabRetentionResponse) GetRetention() int64 {
@@ -1230,7 +1315,7 @@ type CompactionPollMessage struct {
 func (x *CompactionPollMessage) Reset() {
 	*x = CompactionPollMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[21]
+		mi := &file_clustermsgs_proto_msgTypes[23]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1243,7 +1328,7 @@ func (x *CompactionPollMessage) String() string {
 func (*CompactionPollMessage) ProtoMessage() {}
 
 func (x *CompactionPollMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[21]
+	mi := &file_clustermsgs_proto_msgTypes[23]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1256,7 +1341,7 @@ func (x *CompactionPollMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CompactionPollMessage.ProtoReflect.Descriptor instead.
 func (*CompactionPollMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{21}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{23}
 }
 
 type CompactionPollResponse struct {
@@ -1270,7 +1355,7 @@ type CompactionPollResponse struct {
 func (x *CompactionPollResponse) Reset() {
 	*x = CompactionPollResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[22]
+		mi := &file_clustermsgs_proto_msgTypes[24]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1283,7 +1368,7 @@ func (x *CompactionPollResponse) String() string {
 func (*CompactionPollResponse) ProtoMessage() {}
 
 func (x *CompactionPollResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[22]
+	mi := &file_clustermsgs_proto_msgTypes[24]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1296,7 +1381,7 @@ func (x *CompactionPollResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CompactionPollResponse.ProtoReflect.Descriptor instead.
 func (*CompactionPollResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{22}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *CompactionPollResponse) GetJob() []byte {
@@ -1318,7 +1403,7 @@ type LocalObjStoreGetRequest struct {
 func (x *LocalObjStoreGetRequest) Reset() {
 	*x = LocalObjStoreGetRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[23]
+		mi := &file_clustermsgs_proto_msgTypes[25]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1331,7 +1416,7 @@ func (x *LocalObjStoreGetRequest) String() string {
 func (*LocalObjStoreGetRequest) ProtoMessage() {}
 
 func (x *LocalObjStoreGetRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[23]
+	mi := &file_clustermsgs_proto_msgTypes[25]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1344,7 +1429,7 @@ func (x *LocalObjStoreGetRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStoreGetRequest.ProtoReflect.Descriptor instead.
 func (*LocalObjStoreGetRequest) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{23}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *LocalObjStoreGetRequest) GetBucket() string {
@@ -1372,7 +1457,7 @@ type LocalObjStoreGetResponse struct {
 func (x *LocalObjStoreGetResponse) Reset() {
 	*x = LocalObjStoreGetResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[24]
+		mi := &file_clustermsgs_proto_msgTypes[26]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1385,7 +1470,7 @@ func (x *LocalObjStoreGetResponse) String() string {
 func (*LocalObjStoreGetResponse) ProtoMessage() {}
 
 func (x *LocalObjStoreGetResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[24]
+	mi := &file_clustermsgs_proto_msgTypes[26]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1398,7 +1483,7 @@ func (x *LocalObjStoreGetResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStoreGetResponse.ProtoReflect.Descriptor instead.
 func (*LocalObjStoreGetResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{24}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *LocalObjStoreGetResponse) GetValue() []byte {
@@ -1422,7 +1507,7 @@ type LocalObjStorePutRequest struct {
 func (x *LocalObjStorePutRequest) Reset() {
 	*x = LocalObjStorePutRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[25]
+		mi := &file_clustermsgs_proto_msgTypes[27]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1435,7 +1520,7 @@ func (x *LocalObjStorePutRequest) String() string {
 func (*LocalObjStorePutRequest) ProtoMessage() {}
 
 func (x *LocalObjStorePutRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[25]
+	mi := &file_clustermsgs_proto_msgTypes[27]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1448,7 +1533,7 @@ func (x *LocalObjStorePutRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStorePutRequest.ProtoReflect.Descriptor instead.
 func (*LocalObjStorePutRequest) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{25}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *LocalObjStorePutRequest) GetBucket() string {
@@ -1490,7 +1575,7 @@ type LocalObjStorePutResponse struct {
 func (x *LocalObjStorePutResponse) Reset() {
 	*x = LocalObjStorePutResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[26]
+		mi := &file_clustermsgs_proto_msgTypes[28]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1503,7 +1588,7 @@ func (x *LocalObjStorePutResponse) String() string {
 func (*LocalObjStorePutResponse) ProtoMessage() {}
 
 func (x *LocalObjStorePutResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[26]
+	mi := &file_clustermsgs_proto_msgTypes[28]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1516,7 +1601,7 @@ func (x *LocalObjStorePutResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStorePutResponse.ProtoReflect.Descriptor instead.
 func (*LocalObjStorePutResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{26}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{28}
 }
 
 func (x *LocalObjStorePutResponse) GetOk() bool {
@@ -1538,7 +1623,7 @@ type LocalObjStoreDeleteRequest struct {
 func (x *LocalObjStoreDeleteRequest) Reset() {
 	*x = LocalObjStoreDeleteRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[27]
+		mi := &file_clustermsgs_proto_msgTypes[29]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1551,7 +1636,7 @@ func (x *LocalObjStoreDeleteRequest) String() string {
 func (*LocalObjStoreDeleteRequest) ProtoMessage() {}
 
 func (x *LocalObjStoreDeleteRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[27]
+	mi := &file_clustermsgs_proto_msgTypes[29]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1564,7 +1649,7 @@ func (x *LocalObjStoreDeleteRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStoreDeleteRequest.ProtoReflect.Descriptor instead.
 func (*LocalObjStoreDeleteRequest) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{27}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{29}
 }
 
 func (x *LocalObjStoreDeleteRequest) GetBucket() string {
@@ -1593,7 +1678,7 @@ type LocalObjStoreDeleteAllRequest struct {
 func (x *LocalObjStoreDeleteAllRequest) Reset() {
 	*x = LocalObjStoreDeleteAllRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[28]
+		mi := &file_clustermsgs_proto_msgTypes[30]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1606,7 +1691,7 @@ func (x *LocalObjStoreDeleteAllRequest) String() string {
 func (*LocalObjStoreDeleteAllRequest) ProtoMessage() {}
 
 func (x *LocalObjStoreDeleteAllRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[28]
+	mi := &file_clustermsgs_proto_msgTypes[30]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1619,7 +1704,7 @@ func (x *LocalObjStoreDeleteAllRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStoreDeleteAllRequest.ProtoReflect.Descriptor instead.
 func (*LocalObjStoreDeleteAllRequest) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{28}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{30}
 }
 
 func (x *LocalObjStoreDeleteAllRequest) GetBucket() string {
@@ -1649,7 +1734,7 @@ type LocalObjStoreListObjectsRequest struct {
 func (x *LocalObjStoreListObjectsRequest) Reset() {
 	*x = LocalObjStoreListObjectsRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[29]
+		mi := &file_clustermsgs_proto_msgTypes[31]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1662,7 +1747,7 @@ func (x *LocalObjStoreListObjectsRequest) String() string {
 func (*LocalObjStoreListObjectsRequest) ProtoMessage() {}
 
 func (x *LocalObjStoreListObjectsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[29]
+	mi := &file_clustermsgs_proto_msgTypes[31]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1675,7 +1760,7 @@ func (x *LocalObjStoreListObjectsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStoreListObjectsRequest.ProtoReflect.Descriptor instead.
 func (*LocalObjStoreListObjectsRequest) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{29}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *LocalObjStoreListObjectsRequest) GetBucket() string {
@@ -1710,7 +1795,7 @@ type LocalObjStoreListObjectsResponse struct {
 func (x *LocalObjStoreListObjectsResponse) Reset() {
 	*x = LocalObjStoreListObjectsResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[30]
+		mi := &file_clustermsgs_proto_msgTypes[32]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1723,7 +1808,7 @@ func (x *LocalObjStoreListObjectsResponse) String() string {
 func (*LocalObjStoreListObjectsResponse) ProtoMessage() {}
 
 func (x *LocalObjStoreListObjectsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[30]
+	mi := &file_clustermsgs_proto_msgTypes[32]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1736,7 +1821,7 @@ func (x *LocalObjStoreListObjectsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStoreListObjectsResponse.ProtoReflect.Descriptor instead.
 func (*LocalObjStoreListObjectsResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{30}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *LocalObjStoreListObjectsResponse) GetInfos() []*LocalObjStoreInfoMessage {
@@ -1758,7 +1843,7 @@ type LocalObjStoreInfoMessage struct {
 func (x *LocalObjStoreInfoMessage) Reset() {
 	*x = LocalObjStoreInfoMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[31]
+		mi := &file_clustermsgs_proto_msgTypes[33]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1771,7 +1856,7 @@ func (x *LocalObjStoreInfoMessage) String() string {
 func (*LocalObjStoreInfoMessage) ProtoMessage() {}
 
 func (x *LocalObjStoreInfoMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[31]
+	mi := &file_clustermsgs_proto_msgTypes[33]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1784,7 +1869,7 @@ func (x *LocalObjStoreInfoMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocalObjStoreInfoMessage.ProtoReflect.Descriptor instead.
 func (*LocalObjStoreInfoMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{31}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *LocalObjStoreInfoMessage) GetKey() string {
@@ -1819,7 +1904,7 @@ type QueryMessage struct {
 func (x *QueryMessage) Reset() {
 	*x = QueryMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[32]
+		mi := &file_clustermsgs_proto_msgTypes[34]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1832,7 +1917,7 @@ func (x *QueryMessage) String() string {
 func (*QueryMessage) ProtoMessage() {}
 
 func (x *QueryMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[32]
+	mi := &file_clustermsgs_proto_msgTypes[34]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1845,7 +1930,7 @@ func (x *QueryMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use QueryMessage.ProtoReflect.Descriptor instead.
 func (*QueryMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{32}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *QueryMessage) GetExecId() []byte {
@@ -1917,7 +2002,7 @@ type QueryResponse struct {
 func (x *QueryResponse) Reset() {
 	*x = QueryResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[33]
+		mi := &file_clustermsgs_proto_msgTypes[35]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1930,7 +2015,7 @@ func (x *QueryResponse) String() string {
 func (*QueryResponse) ProtoMessage() {}
 
 func (x *QueryResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[33]
+	mi := &file_clustermsgs_proto_msgTypes[35]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1943,7 +2028,7 @@ func (x *QueryResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use QueryResponse.ProtoReflect.Descriptor instead.
 func (*QueryResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{33}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *QueryResponse) GetExecId() []byte {
@@ -1972,15 +2057,16 @@ type VersionsMessage struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	CurrentVersion   int64 `protobuf:"varint,1,opt,name=current_version,json=currentVersion,proto3" json:"current_version,omitempty"`
-	CompletedVersion int64 `protobuf:"varint,2,opt,name=completed_version,json=completedVersion,proto3" json:"completed_version,omitempty"`
-	FlushedVersion   int64 `protobuf:"varint,3,opt,name=flushed_version,json=flushedVersion,proto3" json:"flushed_version,omitempty"`
+	CurrentVersion        int64 `protobuf:"varint,1,opt,name=current_version,json=currentVersion,proto3" json:"current_version,omitempty"`
+	CompletedVersion      int64 `protobuf:"varint,2,opt,name=completed_version,json=completedVersion,proto3" json:"completed_version,omitempty"`
+	FlushedVersion        int64 `protobuf:"varint,3,opt,name=flushed_version,json=flushedVersion,proto3" json:"flushed_version,omitempty"`
+	OldestRetainedVersion int64 `protobuf:"varint,4,opt,name=oldest_retained_version,json=oldestRetainedVersion,proto3" json:"oldest_retained_version,omitempty"`
 }
 
 func (x *VersionsMessage) Reset() {
 	*x = VersionsMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[34]
+		mi := &file_clustermsgs_proto_msgTypes[36]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1993,7 +2079,7 @@ func (x *VersionsMessage) String() string {
 func (*VersionsMessage) ProtoMessage() {}
 
 func (x *VersionsMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[34]
+	mi := &file_clustermsgs_proto_msgTypes[36]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2006,7 +2092,7 @@ func (x *VersionsMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VersionsMessage.ProtoReflect.Descriptor instead.
 func (*VersionsMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{34}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *VersionsMessage) GetCurrentVersion() int64 {
@@ -2030,6 +2116,13 @@ func (x *VersionsMessage) GetFlushedVersion() int64 {
 	return 0
 }
 
+func (x *VersionsMessage) GetOldestRetainedVersion() int64 {
+	if x != nil {
+		return x.OldestRetainedVersion
+	}
+	return 0
+}
+
 type GetCurrentVersionMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -2039,7 +2132,7 @@ type GetCurrentVersionMessage struct {
 func (x *GetCurrentVersionMessage) Reset() {
 	*x = GetCurrentVersionMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[35]
+		mi := &file_clustermsgs_proto_msgTypes[37]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2052,7 +2145,7 @@ func (x *GetCurrentVersionMessage) String() string {
 func (*GetCurrentVersionMessage) ProtoMessage() {}
 
 func (x *GetCurrentVersionMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[35]
+	mi := &file_clustermsgs_proto_msgTypes[37]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2065,7 +2158,101 @@ func (x *GetCurrentVersionMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetCurrentVersionMessage.ProtoReflect.Descriptor instead.
 func (*GetCurrentVersionMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{35}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{37}
+}
+
+type GetVersionForTimeMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Time int64 `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (x *GetVersionForTimeMessage) Reset() {
+	*x = GetVersionForTimeMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_clustermsgs_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetVersionForTimeMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVersionForTimeMessage) ProtoMessage() {}
+
+func (x *GetVersionForTimeMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_clustermsgs_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVersionForTimeMessage.ProtoReflect.Descriptor instead.
+func (*GetVersionForTimeMessage) Descriptor() ([]byte, []int) {
+	return file_clustermsgs_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *GetVersionForTimeMessage) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+type GetVersionForTimeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version int64 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+}
+
+func (x *GetVersionForTimeResponse) Reset() {
+	*x = GetVersionForTimeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_clustermsgs_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetVersionForTimeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVersionForTimeResponse) ProtoMessage() {}
+
+func (x *GetVersionForTimeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_clustermsgs_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVersionForTimeResponse.ProtoReflect.Descriptor instead.
+func (*GetVersionForTimeResponse) Descriptor() ([]byte, []int) {
+	return file_clustermsgs_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *GetVersionForTimeResponse) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
 }
 
 type VersionCompleteMessage struct {
@@ -2082,7 +2269,7 @@ type VersionCompleteMessage struct {
 func (x *VersionCompleteMessage) Reset() {
 	*x = VersionCompleteMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[36]
+		mi := &file_clustermsgs_proto_msgTypes[40]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2095,7 +2282,7 @@ func (x *VersionCompleteMessage) String() string {
 func (*VersionCompleteMessage) ProtoMessage() {}
 
 func (x *VersionCompleteMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[36]
+	mi := &file_clustermsgs_proto_msgTypes[40]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2108,7 +2295,7 @@ func (x *VersionCompleteMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VersionCompleteMessage.ProtoReflect.Descriptor instead.
 func (*VersionCompleteMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{36}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{40}
 }
 
 func (x *VersionCompleteMessage) GetVersion() uint64 {
@@ -2151,7 +2338,7 @@ type FailureDetectedMessage struct {
 func (x *FailureDetectedMessage) Reset() {
 	*x = FailureDetectedMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[37]
+		mi := &file_clustermsgs_proto_msgTypes[41]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2164,7 +2351,7 @@ func (x *FailureDetectedMessage) String() string {
 func (*FailureDetectedMessage) ProtoMessage() {}
 
 func (x *FailureDetectedMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[37]
+	mi := &file_clustermsgs_proto_msgTypes[41]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2177,7 +2364,7 @@ func (x *FailureDetectedMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FailureDetectedMessage.ProtoReflect.Descriptor instead.
 func (*FailureDetectedMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{37}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{41}
 }
 
 func (x *FailureDetectedMessage) GetProcessorCount() uint64 {
@@ -2205,7 +2392,7 @@ type GetLastFailureFlushedVersionMessage struct {
 func (x *GetLastFailureFlushedVersionMessage) Reset() {
 	*x = GetLastFailureFlushedVersionMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[38]
+		mi := &file_clustermsgs_proto_msgTypes[42]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2218,7 +2405,7 @@ func (x *GetLastFailureFlushedVersionMessage) String() string {
 func (*GetLastFailureFlushedVersionMessage) ProtoMessage() {}
 
 func (x *GetLastFailureFlushedVersionMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[38]
+	mi := &file_clustermsgs_proto_msgTypes[42]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2231,7 +2418,7 @@ func (x *GetLastFailureFlushedVersionMessage) ProtoReflect() protoreflect.Messag
 
 // Deprecated: Use GetLastFailureFlushedVersionMessage.ProtoReflect.Descriptor instead.
 func (*GetLastFailureFlushedVersionMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{38}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{42}
 }
 
 func (x *GetLastFailureFlushedVersionMessage) GetClusterVersion() uint64 {
@@ -2252,7 +2439,7 @@ type GetLastFailureFlushedVersionResponse struct {
 func (x *GetLastFailureFlushedVersionResponse) Reset() {
 	*x = GetLastFailureFlushedVersionResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[39]
+		mi := &file_clustermsgs_proto_msgTypes[43]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2265,7 +2452,7 @@ func (x *GetLastFailureFlushedVersionResponse) String() string {
 func (*GetLastFailureFlushedVersionResponse) ProtoMessage() {}
 
 func (x *GetLastFailureFlushedVersionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[39]
+	mi := &file_clustermsgs_proto_msgTypes[43]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2278,7 +2465,7 @@ func (x *GetLastFailureFlushedVersionResponse) ProtoReflect() protoreflect.Messa
 
 // Deprecated: Use GetLastFailureFlushedVersionResponse.ProtoReflect.Descriptor instead.
 func (*GetLastFailureFlushedVersionResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{39}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{43}
 }
 
 func (x *GetLastFailureFlushedVersionResponse) GetFlushedVersion() int64 {
@@ -2300,7 +2487,7 @@ type FailureCompleteMessage struct {
 func (x *FailureCompleteMessage) Reset() {
 	*x = FailureCompleteMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[40]
+		mi := &file_clustermsgs_proto_msgTypes[44]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2313,7 +2500,7 @@ func (x *FailureCompleteMessage) String() string {
 func (*FailureCompleteMessage) ProtoMessage() {}
 
 func (x *FailureCompleteMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[40]
+	mi := &file_clustermsgs_proto_msgTypes[44]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2326,7 +2513,7 @@ func (x *FailureCompleteMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FailureCompleteMessage.ProtoReflect.Descriptor instead.
 func (*FailureCompleteMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{40}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{44}
 }
 
 func (x *FailureCompleteMessage) GetProcessorCount() uint64 {
@@ -2354,7 +2541,7 @@ type IsFailureCompleteMessage struct {
 func (x *IsFailureCompleteMessage) Reset() {
 	*x = IsFailureCompleteMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[41]
+		mi := &file_clustermsgs_proto_msgTypes[45]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2367,7 +2554,7 @@ func (x *IsFailureCompleteMessage) String() string {
 func (*IsFailureCompleteMessage) ProtoMessage() {}
 
 func (x *IsFailureCompleteMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[41]
+	mi := &file_clustermsgs_proto_msgTypes[45]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2380,7 +2567,7 @@ func (x *IsFailureCompleteMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IsFailureCompleteMessage.ProtoReflect.Descriptor instead.
 func (*IsFailureCompleteMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{41}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{45}
 }
 
 func (x *IsFailureCompleteMessage) GetClusterVersion() uint64 {
@@ -2401,7 +2588,7 @@ type IsFailureCompleteResponse struct {
 func (x *IsFailureCompleteResponse) Reset() {
 	*x = IsFailureCompleteResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[42]
+		mi := &file_clustermsgs_proto_msgTypes[46]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2414,7 +2601,7 @@ func (x *IsFailureCompleteResponse) String() string {
 func (*IsFailureCompleteResponse) ProtoMessage() {}
 
 func (x *IsFailureCompleteResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[42]
+	mi := &file_clustermsgs_proto_msgTypes[46]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2427,7 +2614,7 @@ func (x *IsFailureCompleteResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use IsFailureCompleteResponse.ProtoReflect.Descriptor instead.
 func (*IsFailureCompleteResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{42}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{46}
 }
 
 func (x *IsFailureCompleteResponse) GetComplete() bool {
@@ -2450,7 +2637,7 @@ type VersionFlushedMessage struct {
 func (x *VersionFlushedMessage) Reset() {
 	*x = VersionFlushedMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[43]
+		mi := &file_clustermsgs_proto_msgTypes[47]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2463,7 +2650,7 @@ func (x *VersionFlushedMessage) String() string {
 func (*VersionFlushedMessage) ProtoMessage() {}
 
 func (x *VersionFlushedMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[43]
+	mi := &file_clustermsgs_proto_msgTypes[47]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2476,7 +2663,7 @@ func (x *VersionFlushedMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use VersionFlushedMessage.ProtoReflect.Descriptor instead.
 func (*VersionFlushedMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{43}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{47}
 }
 
 func (x *VersionFlushedMessage) GetProcessorId() uint32 {
@@ -2509,7 +2696,7 @@ type CommandAvailableMessage struct {
 func (x *CommandAvailableMessage) Reset() {
 	*x = CommandAvailableMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[44]
+		mi := &file_clustermsgs_proto_msgTypes[48]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2522,7 +2709,7 @@ func (x *CommandAvailableMessage) String() string {
 func (*CommandAvailableMessage) ProtoMessage() {}
 
 func (x *CommandAvailableMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[44]
+	mi := &file_clustermsgs_proto_msgTypes[48]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2535,7 +2722,7 @@ func (x *CommandAvailableMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CommandAvailableMessage.ProtoReflect.Descriptor instead.
 func (*CommandAvailableMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{44}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{48}
 }
 
 type ShutdownMessage struct {
@@ -2549,7 +2736,7 @@ type ShutdownMessage struct {
 func (x *ShutdownMessage) Reset() {
 	*x = ShutdownMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[45]
+		mi := &file_clustermsgs_proto_msgTypes[49]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2562,7 +2749,7 @@ func (x *ShutdownMessage) String() string {
 func (*ShutdownMessage) ProtoMessage() {}
 
 func (x *ShutdownMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[45]
+	mi := &file_clustermsgs_proto_msgTypes[49]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2575,7 +2762,7 @@ func (x *ShutdownMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShutdownMessage.ProtoReflect.Descriptor instead.
 func (*ShutdownMessage) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{45}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{49}
 }
 
 func (x *ShutdownMessage) GetPhase() uint32 {
@@ -2596,7 +2783,7 @@ type ShutdownResponse struct {
 func (x *ShutdownResponse) Reset() {
 	*x = ShutdownResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_clustermsgs_proto_msgTypes[46]
+		mi := &file_clustermsgs_proto_msgTypes[50]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -2609,7 +2796,7 @@ func (x *ShutdownResponse) String() string {
 func (*ShutdownResponse) ProtoMessage() {}
 
 func (x *ShutdownResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_clustermsgs_proto_msgTypes[46]
+	mi := &file_clustermsgs_proto_msgTypes[50]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2622,7 +2809,7 @@ func (x *ShutdownResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShutdownResponse.ProtoReflect.Descriptor instead.
 func (*ShutdownResponse) Descriptor() ([]byte, []int) {
-	return file_clustermsgs_proto_rawDescGZIP(), []int{46}
+	return file_clustermsgs_proto_rawDescGZIP(), []int{50}
 }
 
 func (x *ShutdownResponse) GetFlushed() bool {
@@ -2762,171 +2949,191 @@ var file_clustermsgs_proto_rawDesc = []byte{
 	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x30, 0x0a, 0x14,
 	0x6c, 0x61, 0x73, 0x74, 0x5f, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x5f, 0x76, 0x65, 0x72,
 	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x6c, 0x61, 0x73, 0x74,
-	0x46, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x1d,
-	0x0a, 0x1b, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x47, 0x65,
-	0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x38, 0x0a,
-	0x1c, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x47, 0x65, 0x74,
-	0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a,
-	0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07,
-	0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x61, 0x0a, 0x28, 0x4c, 0x65, 0x76, 0x65, 0x6c,
-	0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x52, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x53,
-	0x6c, 0x61, 0x62, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x6c, 0x61, 0x62, 0x5f, 0x69, 0x64, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x6c, 0x61, 0x62, 0x49, 0x64, 0x12, 0x1c, 0x0a, 0x09,
-	0x72, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x09, 0x72, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x45, 0x0a, 0x2a, 0x4c, 0x65,
-	0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x55, 0x6e, 0x72, 0x65, 0x67, 0x69,
-	0x73, 0x74, 0x65, 0x72, 0x53, 0x6c, 0x61, 0x62, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f,
-	0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x6c, 0x61, 0x62,
-	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x6c, 0x61, 0x62, 0x49,
-	0x64, 0x22, 0x3e, 0x0a, 0x23, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65,
-	0x72, 0x47, 0x65, 0x74, 0x53, 0x6c, 0x61, 0x62, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f,
-	0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x6c, 0x61, 0x62,
-	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x6c, 0x61, 0x62, 0x49,
-	0x64, 0x22, 0x44, 0x0a, 0x24, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65,
-	0x72, 0x47, 0x65, 0x74, 0x53, 0x6c, 0x61, 0x62, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f,
-	0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x74,
-	0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x72, 0x65,
-	0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x17, 0x0a, 0x15, 0x43, 0x6f, 0x6d, 0x70, 0x61,
-	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x50, 0x6f, 0x6c, 0x6c, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
-	0x22, 0x2a, 0x0a, 0x16, 0x43, 0x6f, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x50, 0x6f,
-	0x6c, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6a, 0x6f,
-	0x62, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x6a, 0x6f, 0x62, 0x22, 0x43, 0x0a, 0x17,
-	0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x47, 0x65, 0x74,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65,
-	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12,
-	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
-	0x79, 0x22, 0x30, 0x0a, 0x18, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f,
-	0x72, 0x65, 0x47, 0x65, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a,
-	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x22, 0x7d, 0x0a, 0x17, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53,
-	0x74, 0x6f, 0x72, 0x65, 0x50, 0x75, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16,
+	0x46, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x2d,
+	0x0a, 0x2b, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x47, 0x65,
+	0x74, 0x4f, 0x6c, 0x64, 0x65, 0x73, 0x74, 0x52, 0x65, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x66, 0x0a,
+	0x2c, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x47, 0x65, 0x74,
+	0x4f, 0x6c, 0x64, 0x65, 0x73, 0x74, 0x52, 0x65, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x56, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x36, 0x0a,
+	0x17, 0x6f, 0x6c, 0x64, 0x65, 0x73, 0x74, 0x5f, 0x72, 0x65, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64,
+	0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x15,
+	0x6f, 0x6c, 0x64, 0x65, 0x73, 0x74, 0x52, 0x65, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x56, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x1d, 0x0a, 0x1b, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61,
+	0x6e, 0x61, 0x67, 0x65, 0x72, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x22, 0x38, 0x0a, 0x1c, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e,
+	0x61, 0x67, 0x65, 0x72, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x61,
+	0x0a, 0x28, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x52, 0x65,
+	0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x53, 0x6c, 0x61, 0x62, 0x52, 0x65, 0x74, 0x65, 0x6e, 0x74,
+	0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x73, 0x6c,
+	0x61, 0x62, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x73, 0x6c, 0x61,
+	0x62, 0x49, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x72, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f,
+	0x6e, 0x22, 0x45, 0x0a, 0x2a, 0x4c, 0x65, 0x76, 0x65, 0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65,
+	0x72, 0x55, 0x6e, 0x72, 0x65, 0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x53, 0x6c, 0x61, 0x62, 0x52,
+	0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x17, 0x0a, 0x07, 0x73, 0x6c, 0x61, 0x62, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x06, 0x73, 0x6c, 0x61, 0x62, 0x49, 0x64, 0x22, 0x3e, 0x0a, 0x23, 0x4c, 0x65, 0x76, 0x65,
+	0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x47, 0x65, 0x74, 0x53, 0x6c, 0x61, 0x62, 0x52,
+	0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x17, 0x0a, 0x07, 0x73, 0x6c, 0x61, 0x62, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x06, 0x73, 0x6c, 0x61, 0x62, 0x49, 0x64, 0x22, 0x44, 0x0a, 0x24, 0x4c, 0x65, 0x76, 0x65,
+	0x6c, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x47, 0x65, 0x74, 0x53, 0x6c, 0x61, 0x62, 0x52,
+	0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x09, 0x72, 0x65, 0x74, 0x65, 0x6e, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x17,
+	0x0a, 0x15, 0x43, 0x6f, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x50, 0x6f, 0x6c, 0x6c,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x2a, 0x0a, 0x16, 0x43, 0x6f, 0x6d, 0x70, 0x61,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x50, 0x6f, 0x6c, 0x6c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x10, 0x0a, 0x03, 0x6a, 0x6f, 0x62, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03,
+	0x6a, 0x6f, 0x62, 0x22, 0x43, 0x0a, 0x17, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53,
+	0x74, 0x6f, 0x72, 0x65, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16,
 	0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
 	0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
-	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x22,
-	0x0a, 0x0d, 0x69, 0x66, 0x5f, 0x6e, 0x6f, 0x74, 0x5f, 0x65, 0x78, 0x69, 0x73, 0x74, 0x73, 0x18,
-	0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x69, 0x66, 0x4e, 0x6f, 0x74, 0x45, 0x78, 0x69, 0x73,
-	0x74, 0x73, 0x22, 0x2a, 0x0a, 0x18, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74,
-	0x6f, 0x72, 0x65, 0x50, 0x75, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e,
-	0x0a, 0x02, 0x6f, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x02, 0x6f, 0x6b, 0x22, 0x46,
-	0x0a, 0x1a, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44,
-	0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06,
-	0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x75,
-	0x63, 0x6b, 0x65, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0x4b, 0x0a, 0x1d, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f,
-	0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x41, 0x6c, 0x6c,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65,
-	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12,
-	0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6b,
-	0x65, 0x79, 0x73, 0x22, 0x6c, 0x0a, 0x1f, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53,
-	0x74, 0x6f, 0x72, 0x65, 0x4c, 0x69, 0x73, 0x74, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x16,
-	0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
-	0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x19, 0x0a, 0x08, 0x6d, 0x61, 0x78, 0x5f, 0x6b, 0x65,
-	0x79, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x6d, 0x61, 0x78, 0x4b, 0x65, 0x79,
-	0x73, 0x22, 0x53, 0x0a, 0x20, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f,
-	0x72, 0x65, 0x4c, 0x69, 0x73, 0x74, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2f, 0x0a, 0x05, 0x69, 0x6e, 0x66, 0x6f, 0x73, 0x18, 0x01,
-	0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53,
-	0x74, 0x6f, 0x72, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52,
-	0x05, 0x69, 0x6e, 0x66, 0x6f, 0x73, 0x22, 0x51, 0x0a, 0x18, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f,
-	0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x4d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x03, 0x6b, 0x65, 0x79, 0x12, 0x23, 0x0a, 0x0d, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x6d, 0x6f, 0x64,
-	0x69, 0x66, 0x69, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x6c, 0x61, 0x73,
-	0x74, 0x4d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x22, 0x85, 0x02, 0x0a, 0x0c, 0x51, 0x75,
-	0x65, 0x72, 0x79, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x78,
-	0x65, 0x63, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x65, 0x78, 0x65,
-	0x63, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x71, 0x75, 0x65, 0x72, 0x79, 0x5f, 0x6e, 0x61, 0x6d,
-	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x71, 0x75, 0x65, 0x72, 0x79, 0x4e, 0x61,
-	0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x73, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x03, 0x74, 0x73, 0x6c, 0x12, 0x27, 0x0a, 0x0f, 0x68, 0x69, 0x67, 0x68, 0x65, 0x73, 0x74, 0x5f,
-	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x68,
-	0x69, 0x67, 0x68, 0x65, 0x73, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a,
-	0x0f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-	0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x56,
-	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x72, 0x67, 0x73, 0x18, 0x06,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x61, 0x72, 0x67, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x61,
-	0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a,
-	0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x65,
-	0x6e, 0x64, 0x65, 0x72, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x08, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x0d, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73,
-	0x73, 0x22, 0x52, 0x0a, 0x0d, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x78, 0x65, 0x63, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x0c, 0x52, 0x06, 0x65, 0x78, 0x65, 0x63, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x76,
-	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75,
-	0x65, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x61, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
-	0x04, 0x6c, 0x61, 0x73, 0x74, 0x22, 0x90, 0x01, 0x0a, 0x0f, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
-	0x6e, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x75, 0x72,
-	0x72, 0x65, 0x6e, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x03, 0x52, 0x0e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69,
-	0x6f, 0x6e, 0x12, 0x2b, 0x0a, 0x11, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x5f,
-	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x63,
-	0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
-	0x27, 0x0a, 0x0f, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69,
-	0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65,
-	0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x1a, 0x0a, 0x18, 0x47, 0x65, 0x74, 0x43,
-	0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x22, 0x98, 0x01, 0x0a, 0x16, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-	0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
-	0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x31, 0x0a, 0x14, 0x72, 0x65, 0x71,
-	0x75, 0x69, 0x72, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e,
-	0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x13, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65,
-	0x64, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x1d, 0x0a, 0x0a,
-	0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
-	0x52, 0x09, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x64,
-	0x6f, 0x6f, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x64, 0x6f, 0x6f, 0x6d, 0x22,
-	0x6a, 0x0a, 0x16, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x44, 0x65, 0x74, 0x65, 0x63, 0x74,
-	0x65, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x70, 0x72, 0x6f,
-	0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x04, 0x52, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x43, 0x6f, 0x75,
-	0x6e, 0x74, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x76, 0x65,
-	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75,
-	0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x4e, 0x0a, 0x23, 0x47,
-	0x65, 0x74, 0x4c, 0x61, 0x73, 0x74, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x46, 0x6c, 0x75,
-	0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x76, 0x65,
-	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75,
-	0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x4f, 0x0a, 0x24, 0x47,
-	0x65, 0x74, 0x4c, 0x61, 0x73, 0x74, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x46, 0x6c, 0x75,
-	0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x5f, 0x76,
-	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x66, 0x6c,
-	0x75, 0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x6a, 0x0a, 0x16,
-	0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x4d,
-	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73,
-	0x73, 0x6f, 0x72, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52,
-	0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12,
-	0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69,
-	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65,
-	0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x43, 0x0a, 0x18, 0x49, 0x73, 0x46, 0x61,
-	0x69, 0x6c, 0x75, 0x72, 0x65, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f,
-	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x63,
-	0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x37, 0x0a,
-	0x19, 0x49, 0x73, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65,
-	0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f,
-	0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x63, 0x6f,
-	0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x22, 0x7d, 0x0a, 0x15, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
-	0x6e, 0x46, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
-	0x21, 0x0a, 0x0c, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x5f, 0x69, 0x64, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72,
-	0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x04, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f,
-	0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x56, 0x65,
-	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x19, 0x0a, 0x17, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
-	0x41, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
-	0x22, 0x27, 0x0a, 0x0f, 0x53, 0x68, 0x75, 0x74, 0x64, 0x6f, 0x77, 0x6e, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x68, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0d, 0x52, 0x05, 0x70, 0x68, 0x61, 0x73, 0x65, 0x22, 0x2c, 0x0a, 0x10, 0x53, 0x68, 0x75,
-	0x74, 0x64, 0x6f, 0x77, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a,
-	0x07, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
-	0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x42, 0x0e, 0x5a, 0x0c, 0x63, 0x6c, 0x75, 0x73, 0x74,
-	0x65, 0x72, 0x6d, 0x73, 0x67, 0x73, 0x2f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0x30, 0x0a, 0x18, 0x4c, 0x6f, 0x63, 0x61,
+	0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x47, 0x65, 0x74, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x7d, 0x0a, 0x17, 0x4c, 0x6f,
+	0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x75, 0x74, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x10, 0x0a,
+	0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x22, 0x0a, 0x0d, 0x69, 0x66, 0x5f, 0x6e, 0x6f, 0x74, 0x5f,
+	0x65, 0x78, 0x69, 0x73, 0x74, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x69, 0x66,
+	0x4e, 0x6f, 0x74, 0x45, 0x78, 0x69, 0x73, 0x74, 0x73, 0x22, 0x2a, 0x0a, 0x18, 0x4c, 0x6f, 0x63,
+	0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x50, 0x75, 0x74, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x6f, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x02, 0x6f, 0x6b, 0x22, 0x46, 0x0a, 0x1a, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62,
+	0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0x4b, 0x0a,
+	0x1d, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x41, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16,
+	0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x6b, 0x65, 0x79, 0x73, 0x22, 0x6c, 0x0a, 0x1f, 0x4c, 0x6f,
+	0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x4c, 0x69, 0x73, 0x74, 0x4f,
+	0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a,
+	0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62,
+	0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x19, 0x0a,
+	0x08, 0x6d, 0x61, 0x78, 0x5f, 0x6b, 0x65, 0x79, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x07, 0x6d, 0x61, 0x78, 0x4b, 0x65, 0x79, 0x73, 0x22, 0x53, 0x0a, 0x20, 0x4c, 0x6f, 0x63, 0x61,
+	0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x4c, 0x69, 0x73, 0x74, 0x4f, 0x62, 0x6a,
+	0x65, 0x63, 0x74, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2f, 0x0a, 0x05,
+	0x69, 0x6e, 0x66, 0x6f, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x4c, 0x6f,
+	0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x52, 0x05, 0x69, 0x6e, 0x66, 0x6f, 0x73, 0x22, 0x51, 0x0a,
+	0x18, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x4f, 0x62, 0x6a, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x49, 0x6e,
+	0x66, 0x6f, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x23, 0x0a, 0x0d, 0x6c,
+	0x61, 0x73, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x4d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64,
+	0x22, 0x85, 0x02, 0x0a, 0x0c, 0x51, 0x75, 0x65, 0x72, 0x79, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x78, 0x65, 0x63, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x06, 0x65, 0x78, 0x65, 0x63, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x71, 0x75,
+	0x65, 0x72, 0x79, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x71, 0x75, 0x65, 0x72, 0x79, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x73, 0x6c,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x73, 0x6c, 0x12, 0x27, 0x0a, 0x0f, 0x68,
+	0x69, 0x67, 0x68, 0x65, 0x73, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x68, 0x69, 0x67, 0x68, 0x65, 0x73, 0x74, 0x56, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x63,
+	0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a,
+	0x04, 0x61, 0x72, 0x67, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x61, 0x72, 0x67,
+	0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x5f, 0x61, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x73, 0x65, 0x6e, 0x64, 0x65,
+	0x72, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x52, 0x0a, 0x0d, 0x51, 0x75, 0x65, 0x72,
+	0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x65, 0x78, 0x65,
+	0x63, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x65, 0x78, 0x65, 0x63,
+	0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x61, 0x73, 0x74,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x6c, 0x61, 0x73, 0x74, 0x22, 0xc8, 0x01, 0x0a,
+	0x0f, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x27, 0x0a, 0x0f, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x74, 0x5f, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x63, 0x75, 0x72, 0x72, 0x65,
+	0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2b, 0x0a, 0x11, 0x63, 0x6f, 0x6d,
+	0x70, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65,
+	0x64, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0e, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x36, 0x0a, 0x17, 0x6f, 0x6c, 0x64, 0x65, 0x73, 0x74, 0x5f, 0x72, 0x65, 0x74, 0x61, 0x69, 0x6e,
+	0x65, 0x64, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x15, 0x6f, 0x6c, 0x64, 0x65, 0x73, 0x74, 0x52, 0x65, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64,
+	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x1a, 0x0a, 0x18, 0x47, 0x65, 0x74, 0x43, 0x75,
+	0x72, 0x72, 0x65, 0x6e, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x22, 0x2e, 0x0a, 0x18, 0x47, 0x65, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x46, 0x6f, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x22, 0x35, 0x0a, 0x19, 0x47, 0x65, 0x74, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x46, 0x6f, 0x72, 0x54, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x98, 0x01, 0x0a, 0x16, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x31, 0x0a, 0x14, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x64, 0x5f, 0x63, 0x6f, 0x6d, 0x70,
+	0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x13, 0x72,
+	0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x64, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x69, 0x64,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x49,
+	0x64, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x6f, 0x6f, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x04, 0x64, 0x6f, 0x6f, 0x6d, 0x22, 0x6a, 0x0a, 0x16, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65,
+	0x44, 0x65, 0x74, 0x65, 0x63, 0x74, 0x65, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x27, 0x0a, 0x0f, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x5f, 0x63, 0x6f, 0x75,
+	0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73,
+	0x73, 0x6f, 0x72, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73,
+	0x74, 0x65, 0x72, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x22, 0x4e, 0x0a, 0x23, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x73, 0x74, 0x46, 0x61, 0x69, 0x6c,
+	0x75, 0x72, 0x65, 0x46, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73,
+	0x74, 0x65, 0x72, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x22, 0x4f, 0x0a, 0x24, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x73, 0x74, 0x46, 0x61, 0x69, 0x6c,
+	0x75, 0x72, 0x65, 0x46, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x66, 0x6c, 0x75,
+	0x73, 0x68, 0x65, 0x64, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x0e, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x22, 0x6a, 0x0a, 0x16, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x43, 0x6f, 0x6d,
+	0x70, 0x6c, 0x65, 0x74, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f,
+	0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x6f, 0x72,
+	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72,
+	0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e,
+	0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x43,
+	0x0a, 0x18, 0x49, 0x73, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x43, 0x6f, 0x6d, 0x70, 0x6c,
+	0x65, 0x74, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c,
+	0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x22, 0x37, 0x0a, 0x19, 0x49, 0x73, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65,
+	0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x08, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x22, 0x7d, 0x0a, 0x15,
+	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x46, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
+	0x6f, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x70, 0x72, 0x6f,
+	0x63, 0x65, 0x73, 0x73, 0x6f, 0x72, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x63, 0x6c, 0x75,
+	0x73, 0x74, 0x65, 0x72, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x19, 0x0a, 0x17, 0x43,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x41, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x27, 0x0a, 0x0f, 0x53, 0x68, 0x75, 0x74, 0x64, 0x6f,
+	0x77, 0x6e, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x68, 0x61,
+	0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x70, 0x68, 0x61, 0x73, 0x65, 0x22,
+	0x2c, 0x0a, 0x10, 0x53, 0x68, 0x75, 0x74, 0x64, 0x6f, 0x77, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x66, 0x6c, 0x75, 0x73, 0x68, 0x65, 0x64, 0x42, 0x0e, 0x5a,
+	0x0c, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x6d, 0x73, 0x67, 0x73, 0x2f, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -2941,58 +3148,62 @@ func file_clustermsgs_proto_rawDescGZIP() []byte {
 	return file_clustermsgs_proto_rawDescData
 }
 
-var file_clustermsgs_proto_msgTypes = make([]protoimpl.MessageInfo, 47)
+var file_clustermsgs_proto_msgTypes = make([]protoimpl.MessageInfo, 51)
 var file_clustermsgs_proto_goTypes = []interface{}{
-	(*ForwardBatchMessage)(nil),                         // 0: ForwardBatchMessage
-	(*ReplicateMessage)(nil),                            // 1: ReplicateMessage
-	(*LastCommittedRequest)(nil),                        // 2: LastCommittedRequest
-	(*LastCommittedResponse)(nil),                       // 3: LastCommittedResponse
-	(*SetLastCommittedMessage)(nil),                     // 4: SetLastCommittedMessage
-	(*FlushMessage)(nil),                                // 5: FlushMessage
-	(*LevelManagerGetTableIDsForRangeMessage)(nil),      // 6: LevelManagerGetTableIDsForRangeMessage
-	(*LevelManagerGetTableIDsForRangeResponse)(nil),     // 7: LevelManagerGetTableIDsForRangeResponse
-	(*LevelManagerRawResponse)(nil),                     // 8: LevelManagerRawResponse
-	(*LevelManagerApplyChangesRequest)(nil),             // 9: LevelManagerApplyChangesRequest
-	(*LevelManagerRegisterDeadVersionRangeRequest)(nil), // 10: LevelManagerRegisterDeadVersionRangeRequest
-	(*LevelManagerL0AddRequest)(nil),                    // 11: LevelManagerL0AddRequest
-	(*LevelManagerLoadLastFlushedVersionMessage)(nil),   // 12: LevelManagerLoadLastFlushedVersionMessage
-	(*LevelManagerLoadLastFlushedVersionResponse)(nil),  // 13: LevelManagerLoadLastFlushedVersionResponse
-	(*LevelManagerStoreLastFlushedVersionMessage)(nil),  // 14: LevelManagerStoreLastFlushedVersionMessage
-	(*LevelManagerGetStatsMessage)(nil),                 // 15: LevelManagerGetStatsMessage
-	(*LevelManagerGetStatsResponse)(nil),                // 16: LevelManagerGetStatsResponse
-	(*LevelManagerRegisterSlabRetentionMessage)(nil),    // 17: LevelManagerRegisterSlabRetentionMessage
-	(*LevelManagerUnregisterSlabRetentionMessage)(nil),  // 18: LevelManagerUnregisterSlabRetentionMessage
-	(*LevelManagerGetSlabRetentionMessage)(nil),         // 19: LevelManagerGetSlabRetentionMessage
-	(*LevelManagerGetSlabRetentionResponse)(nil),        // 20: LevelManagerGetSlabRetentionResponse
-	(*CompactionPollMessage)(nil),                       // 21: CompactionPollMessage
-	(*CompactionPollResponse)(nil),                      // 22: CompactionPollResponse
-	(*LocalObjStoreGetRequest)(nil),                     // 23: LocalObjStoreGetRequest
-	(*LocalObjStoreGetResponse)(nil),                    // 24: LocalObjStoreGetResponse
-	(*LocalObjStorePutRequest)(nil),                     // 25: LocalObjStorePutRequest
-	(*LocalObjStorePutResponse)(nil),                    // 26: LocalObjStorePutResponse
-	(*LocalObjStoreDeleteRequest)(nil),                  // 27: LocalObjStoreDeleteRequest
-	(*LocalObjStoreDeleteAllRequest)(nil),               // 28: LocalObjStoreDeleteAllRequest
-	(*LocalObjStoreListObjectsRequest)(nil),             // 29: LocalObjStoreListObjectsRequest
-	(*LocalObjStoreListObjectsResponse)(nil),            // 30: LocalObjStoreListObjectsResponse
-	(*LocalObjStoreInfoMessage)(nil),                    // 31: LocalObjStoreInfoMessage
-	(*QueryMessage)(nil),                                // 32: QueryMessage
-	(*QueryResponse)(nil),                               // 33: QueryResponse
-	(*VersionsMessage)(nil),                             // 34: VersionsMessage
-	(*GetCurrentVersionMessage)(nil),                    // 35: GetCurrentVersionMessage
-	(*VersionCompleteMessage)(nil),                      // 36: VersionCompleteMessage
-	(*FailureDetectedMessage)(nil),                      // 37: FailureDetectedMessage
-	(*GetLastFailureFlushedVersionMessage)(nil),         // 38: GetLastFailureFlushedVersionMessage
-	(*GetLastFailureFlushedVersionResponse)(nil),        // 39: GetLastFailureFlushedVersionResponse
-	(*FailureCompleteMessage)(nil),                      // 40: FailureCompleteMessage
-	(*IsFailureCompleteMessage)(nil),                    // 41: IsFailureCompleteMessage
-	(*IsFailureCompleteResponse)(nil),                   // 42: IsFailureCompleteResponse
-	(*VersionFlushedMessage)(nil),                       // 43: VersionFlushedMessage
-	(*CommandAvailableMessage)(nil),                     // 44: CommandAvailableMessage
-	(*ShutdownMessage)(nil),                             // 45: ShutdownMessage
-	(*ShutdownResponse)(nil),                            // 46: ShutdownResponse
+	(*ForwardBatchMessage)(nil),                          // 0: ForwardBatchMessage
+	(*ReplicateMessage)(nil),                             // 1: ReplicateMessage
+	(*LastCommittedRequest)(nil),                         // 2: LastCommittedRequest
+	(*LastCommittedResponse)(nil),                        // 3: LastCommittedResponse
+	(*SetLastCommittedMessage)(nil),                      // 4: SetLastCommittedMessage
+	(*FlushMessage)(nil),                                 // 5: FlushMessage
+	(*LevelManagerGetTableIDsForRangeMessage)(nil),       // 6: LevelManagerGetTableIDsForRangeMessage
+	(*LevelManagerGetTableIDsForRangeResponse)(nil),      // 7: LevelManagerGetTableIDsForRangeResponse
+	(*LevelManagerRawResponse)(nil),                      // 8: LevelManagerRawResponse
+	(*LevelManagerApplyChangesRequest)(nil),              // 9: LevelManagerApplyChangesRequest
+	(*LevelManagerRegisterDeadVersionRangeRequest)(nil),  // 10: LevelManagerRegisterDeadVersionRangeRequest
+	(*LevelManagerL0AddRequest)(nil),                     // 11: LevelManagerL0AddRequest
+	(*LevelManagerLoadLastFlushedVersionMessage)(nil),    // 12: LevelManagerLoadLastFlushedVersionMessage
+	(*LevelManagerLoadLastFlushedVersionResponse)(nil),   // 13: LevelManagerLoadLastFlushedVersionResponse
+	(*LevelManagerStoreLastFlushedVersionMessage)(nil),   // 14: LevelManagerStoreLastFlushedVersionMessage
+	(*LevelManagerGetOldestRetainedVersionMessage)(nil),  // 15: LevelManagerGetOldestRetainedVersionMessage
+	(*LevelManagerGetOldestRetainedVersionResponse)(nil), // 16: LevelManagerGetOldestRetainedVersionResponse
+	(*LevelManagerGetStatsMessage)(nil),                  // 17: LevelManagerGetStatsMessage
+	(*LevelManagerGetStatsResponse)(nil),                 // 18: LevelManagerGetStatsResponse
+	(*LevelManagerRegisterSlabRetentionMessage)(nil),     // 19: LevelManagerRegisterSlabRetentionMessage
+	(*LevelManagerUnregisterSlabRetentionMessage)(nil),   // 20: LevelManagerUnregisterSlabRetentionMessage
+	(*LevelManagerGetSlabRetentionMessage)(nil),          // 21: LevelManagerGetSlabRetentionMessage
+	(*LevelManagerGetSlabRetentionResponse)(nil),         // 22: LevelManagerGetSlabRetentionResponse
+	(*CompactionPollMessage)(nil),                        // 23: CompactionPollMessage
+	(*CompactionPollResponse)(nil),                       // 24: CompactionPollResponse
+	(*LocalObjStoreGetRequest)(nil),                      // 25: LocalObjStoreGetRequest
+	(*LocalObjStoreGetResponse)(nil),                     // 26: LocalObjStoreGetResponse
+	(*LocalObjStorePutRequest)(nil),                      // 27: LocalObjStorePutRequest
+	(*LocalObjStorePutResponse)(nil),                     // 28: LocalObjStorePutResponse
+	(*LocalObjStoreDeleteRequest)(nil),                   // 29: LocalObjStoreDeleteRequest
+	(*LocalObjStoreDeleteAllRequest)(nil),                // 30: LocalObjStoreDeleteAllRequest
+	(*LocalObjStoreListObjectsRequest)(nil),              // 31: LocalObjStoreListObjectsRequest
+	(*LocalObjStoreListObjectsResponse)(nil),             // 32: LocalObjStoreListObjectsResponse
+	(*LocalObjStoreInfoMessage)(nil),                     // 33: LocalObjStoreInfoMessage
+	(*QueryMessage)(nil),                                 // 34: QueryMessage
+	(*QueryResponse)(nil),                                // 35: QueryResponse
+	(*VersionsMessage)(nil),                              // 36: VersionsMessage
+	(*GetCurrentVersionMessage)(nil),                     // 37: GetCurrentVersionMessage
+	(*GetVersionForTimeMessage)(nil),                     // 38: GetVersionForTimeMessage
+	(*GetVersionForTimeResponse)(nil),                    // 39: GetVersionForTimeResponse
+	(*VersionCompleteMessage)(nil),                       // 40: VersionCompleteMessage
+	(*FailureDetectedMessage)(nil),                       // 41: FailureDetectedMessage
+	(*GetLastFailureFlushedVersionMessage)(nil),          // 42: GetLastFailureFlushedVersionMessage
+	(*GetLastFailureFlushedVersionResponse)(nil),         // 43: GetLastFailureFlushedVersionResponse
+	(*FailureCompleteMessage)(nil),                       // 44: FailureCompleteMessage
+	(*IsFailureCompleteMessage)(nil),                     // 45: IsFailureCompleteMessage
+	(*IsFailureCompleteResponse)(nil),                    // 46: IsFailureCompleteResponse
+	(*VersionFlushedMessage)(nil),                        // 47: VersionFlushedMessage
+	(*CommandAvailableMessage)(nil),                      // 48: CommandAvailableMessage
+	(*ShutdownMessage)(nil),                              // 49: ShutdownMessage
+	(*ShutdownResponse)(nil),                             // 50: ShutdownResponse
 }
 var file_clustermsgs_proto_depIdxs = []int32{
-	31, // 0: LocalObjStoreListObjectsResponse.infos:type_name -> LocalObjStoreInfoMessage
+	33, // 0: LocalObjStoreListObjectsResponse.infos:type_name -> LocalObjStoreInfoMessage
 	1,  // [1:1] is the sub-list for method output_type
 	1,  // [1:1] is the sub-list for method input_type
 	1,  // [1:1] is the sub-list for extension type_name
@@ -3187,7 +3398,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LevelManagerGetStatsMessage); i {
+			switch v := v.(*LevelManagerGetOldestRetainedVersionMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3199,7 +3410,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LevelManagerGetStatsResponse); i {
+			switch v := v.(*LevelManagerGetOldestRetainedVersionResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3211,7 +3422,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LevelManagerRegisterSlabRetentionMessage); i {
+			switch v := v.(*LevelManagerGetStatsMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3223,7 +3434,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LevelManagerUnregisterSlabRetentionMessage); i {
+			switch v := v.(*LevelManagerGetStatsResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3235,7 +3446,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LevelManagerGetSlabRetentionMessage); i {
+			switch v := v.(*LevelManagerRegisterSlabRetentionMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3247,7 +3458,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LevelManagerGetSlabRetentionResponse); i {
+			switch v := v.(*LevelManagerUnregisterSlabRetentionMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3259,7 +3470,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CompactionPollMessage); i {
+			switch v := v.(*LevelManagerGetSlabRetentionMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3271,7 +3482,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CompactionPollResponse); i {
+			switch v := v.(*LevelManagerGetSlabRetentionResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3283,7 +3494,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStoreGetRequest); i {
+			switch v := v.(*CompactionPollMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3295,7 +3506,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStoreGetResponse); i {
+			switch v := v.(*CompactionPollResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3307,7 +3518,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStorePutRequest); i {
+			switch v := v.(*LocalObjStoreGetRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3319,7 +3530,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStorePutResponse); i {
+			switch v := v.(*LocalObjStoreGetResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3331,7 +3542,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStoreDeleteRequest); i {
+			switch v := v.(*LocalObjStorePutRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3343,7 +3554,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStoreDeleteAllRequest); i {
+			switch v := v.(*LocalObjStorePutResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3355,7 +3566,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStoreListObjectsRequest); i {
+			switch v := v.(*LocalObjStoreDeleteRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3367,7 +3578,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStoreListObjectsResponse); i {
+			switch v := v.(*LocalObjStoreDeleteAllRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3379,7 +3590,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocalObjStoreInfoMessage); i {
+			switch v := v.(*LocalObjStoreListObjectsRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3391,7 +3602,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*QueryMessage); i {
+			switch v := v.(*LocalObjStoreListObjectsResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3403,7 +3614,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*QueryResponse); i {
+			switch v := v.(*LocalObjStoreInfoMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3415,7 +3626,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*VersionsMessage); i {
+			switch v := v.(*QueryMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3427,7 +3638,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetCurrentVersionMessage); i {
+			switch v := v.(*QueryResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3439,7 +3650,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*VersionCompleteMessage); i {
+			switch v := v.(*VersionsMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3451,7 +3662,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FailureDetectedMessage); i {
+			switch v := v.(*GetCurrentVersionMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3463,7 +3674,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetLastFailureFlushedVersionMessage); i {
+			switch v := v.(*GetVersionForTimeMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3475,7 +3686,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*GetLastFailureFlushedVersionResponse); i {
+			switch v := v.(*GetVersionForTimeResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3487,7 +3698,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FailureCompleteMessage); i {
+			switch v := v.(*VersionCompleteMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3499,7 +3710,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[41].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*IsFailureCompleteMessage); i {
+			switch v := v.(*FailureDetectedMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3511,7 +3722,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[42].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*IsFailureCompleteResponse); i {
+			switch v := v.(*GetLastFailureFlushedVersionMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3523,7 +3734,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[43].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*VersionFlushedMessage); i {
+			switch v := v.(*GetLastFailureFlushedVersionResponse); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3535,7 +3746,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[44].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CommandAvailableMessage); i {
+			switch v := v.(*FailureCompleteMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3547,7 +3758,7 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[45].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ShutdownMessage); i {
+			switch v := v.(*IsFailureCompleteMessage); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -3559,6 +3770,54 @@ func file_clustermsgs_proto_init() {
 			}
 		}
 		file_clustermsgs_proto_msgTypes[46].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IsFailureCompleteResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_clustermsgs_proto_msgTypes[47].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*VersionFlushedMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_clustermsgs_proto_msgTypes[48].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommandAvailableMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_clustermsgs_proto_msgTypes[49].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShutdownMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_clustermsgs_proto_msgTypes[50].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ShutdownResponse); i {
 			case 0:
 				return &v.state
@@ -3577,7 +3836,7 @@ func file_clustermsgs_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_clustermsgs_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   47,
+			NumMessages:   51,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
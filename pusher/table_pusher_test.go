@@ -2129,6 +2129,10 @@ func (f *failingObjectStoreClient) Get(_ context.Context, _ string, _ string) ([
 	panic("should not be called")
 }
 
+func (f *failingObjectStoreClient) GetRange(_ context.Context, _ string, _ string, _ int64, _ int64) ([]byte, error) {
+	panic("should not be called")
+}
+
 func (f *failingObjectStoreClient) Put(_ context.Context, _ string, _ string, _ []byte) error {
 	return errors.New("some random error")
 }
@@ -2183,6 +2187,13 @@ func (u *unavailableObjStoreClient) Get(ctx context.Context, bucket string, key
 	return u.cl.Get(ctx, bucket, key)
 }
 
+func (u *unavailableObjStoreClient) GetRange(ctx context.Context, bucket string, key string, offset int64, length int64) ([]byte, error) {
+	if !u.available.Load() {
+		return nil, common.NewTektiteErrorf(common.Unavailable, "object store is unavailable")
+	}
+	return u.cl.GetRange(ctx, bucket, key, offset, length)
+}
+
 func (u *unavailableObjStoreClient) Put(ctx context.Context, bucket string, key string, value []byte) error {
 	if !u.available.Load() {
 		return common.NewTektiteErrorf(common.Unavailable, "object store is unavailable")
@@ -220,6 +220,17 @@ func (g *GetOperator) createKey(exprs []expr.Expression, args *evbatch.Batch) ([
 			}
 			buff = append(buff, 1)
 			buff = encoding2.KeyEncodeTimestamp(buff, val)
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			val, null, err := e.EvalNested(0, args)
+			if err != nil {
+				return nil, err
+			}
+			if null {
+				buff = append(buff, 0)
+				continue
+			}
+			buff = append(buff, 1)
+			buff = encoding2.KeyEncodeNested(buff, e.ResultType(), val)
 		default:
 			panic("unknown type")
 		}
@@ -40,6 +40,8 @@ type Manager interface {
 	Start() error
 	Stop() error
 	DeleteQuery(deleteQuery parser.DeleteQueryDesc) error
+	Explain(explain parser.ExplainDesc) (*evbatch.Batch, error)
+	StreamStats(showStats parser.ShowStreamStatsDesc) (*evbatch.Batch, error)
 }
 
 type iteratorProvider interface {
@@ -59,11 +61,13 @@ type manager struct {
 	streamInfoProvider         StreamInfoProvider
 	streamMetaIteratorProvider iteratorProvider
 	procProvider               processorProvider
+	versionTimeProvider        versionTimeProvider
 	expressionFactory          *expr.ExpressionFactory
 	parser                     *parser.Parser
 	maxBatchRows               int
 	lastCompletedVersion       int64
 	lastFlushedVersion         int64
+	oldestRetainedVersion      int64
 	nodeID                     int
 }
 
@@ -74,6 +78,15 @@ type QInfo struct {
 	ParamSchema        *evbatch.EventSchema
 	RemoteResultSchema *evbatch.EventSchema
 	FullKeyLookup      bool
+	AsOf               *AsOf
+}
+
+// AsOf is the point-in-time that a query is executed at, as specified by the as_of argument of a get or scan. Only
+// one of Version and Time is set.
+type AsOf struct {
+	Version *int
+	Time    *time.Time
+	desc    errMsgAtPositionProvider
 }
 
 func createEmptyBatch(schema *evbatch.EventSchema) *evbatch.Batch {
@@ -89,6 +102,15 @@ type clusterVersionProvider interface {
 
 type StreamInfoProvider interface {
 	GetStream(streamName string) *opers.StreamInfo
+	ExplainStream(streamName string) ([]*opers.OperatorPlan, bool)
+	GetStreamStats(streamName string) (*opers.StreamStats, bool)
+	GetAllStreamStats() []*opers.StreamStats
+}
+
+type versionTimeProvider interface {
+	// GetVersionForTime returns the last version that completed at or before the specified time, in millis past epoch,
+	// or -1 if there is no such version in the retained history
+	GetVersionForTime(unixMillis int64) (int, error)
 }
 
 type queryRemoting interface {
@@ -99,8 +121,8 @@ type queryRemoting interface {
 
 func NewManager(partitionMapper proc.PartitionMapper, clustVersionProvider clusterVersionProvider, nodeID int,
 	streamInfoProvider StreamInfoProvider, streamMetaIterProvider iteratorProvider, procProvider processorProvider,
-	remoting queryRemoting, remotingListenAddresses []string, maxBatchRows int, expressionFactory *expr.ExpressionFactory,
-	parser *parser.Parser) Manager {
+	versionTimeProvider versionTimeProvider, remoting queryRemoting, remotingListenAddresses []string, maxBatchRows int,
+	expressionFactory *expr.ExpressionFactory, parser *parser.Parser) Manager {
 	return &manager{
 		preparedQueries:            map[string]*QInfo{},
 		partitionMapper:            partitionMapper,
@@ -108,11 +130,13 @@ func NewManager(partitionMapper proc.PartitionMapper, clustVersionProvider clust
 		streamInfoProvider:         streamInfoProvider,
 		streamMetaIteratorProvider: streamMetaIterProvider,
 		procProvider:               procProvider,
+		versionTimeProvider:        versionTimeProvider,
 		remoting:                   remoting,
 		remotingListenAddresses:    remotingListenAddresses,
 		remotingAddress:            remotingListenAddresses[nodeID],
 		maxBatchRows:               maxBatchRows,
 		lastCompletedVersion:       -1,
+		oldestRetainedVersion:      -1,
 		nodeID:                     nodeID,
 		expressionFactory:          expressionFactory,
 		parser:                     parser,
@@ -154,6 +178,10 @@ func (m *manager) SetLastFlushedVersion(version int64) {
 	atomic.StoreInt64(&m.lastFlushedVersion, version)
 }
 
+func (m *manager) SetOldestRetainedVersion(version int64) {
+	atomic.StoreInt64(&m.oldestRetainedVersion, version)
+}
+
 func (m *manager) GetLastCompletedVersion() int {
 	return int(atomic.LoadInt64(&m.lastCompletedVersion))
 }
@@ -195,6 +223,7 @@ func (v *versionBroadcastHandler) HandleMessage(messageHolder remoting.MessageHo
 	msg := messageHolder.Message.(*clustermsgs.VersionsMessage)
 	v.m.SetLastCompletedVersion(msg.CompletedVersion)
 	v.m.SetLastFlushedVersion(msg.FlushedVersion)
+	v.m.SetOldestRetainedVersion(msg.OldestRetainedVersion)
 	return nil, nil
 }
 
@@ -239,6 +268,7 @@ func (m *manager) createQueryInfo(opDescs []parser.Parseable, params []parser.Pr
 	var prevOperator opers.Operator
 	var streamInfo *opers.StreamInfo
 	var isFullKeyLookup bool
+	var asOf *AsOf
 	hasSort := false
 	var paramSchema *evbatch.EventSchema
 	lp := len(params)
@@ -273,6 +303,7 @@ loop:
 			}
 			oper = NewGetOperator(false, colExprs, nil, true, false, streamInfo.UserSlab.SlabID,
 				streamInfo.UserSlab.KeyColIndexes, streamInfo.UserSlab.Schema, m.nodeID, iterProvider)
+			asOf = newAsOf(desc.AsOfVersion, desc.AsOfTime, desc)
 		case *parser.ScanDesc:
 			streamInfo = m.streamInfoProvider.GetStream(desc.TableName)
 			isFullKeyLookup = false
@@ -309,6 +340,7 @@ loop:
 			oper = NewGetOperator(true, rangeStartExprs, rangeEndExprs, desc.FromIncl,
 				desc.ToIncl, streamInfo.UserSlab.SlabID,
 				streamInfo.UserSlab.KeyColIndexes, streamInfo.UserSlab.Schema, m.nodeID, iterProvider)
+			asOf = newAsOf(desc.AsOfVersion, desc.AsOfTime, desc)
 		case *parser.FilterDesc:
 			oper, err = opers.NewFilterOperator(prevOperator.OutSchema(), desc.Expr, m.expressionFactory)
 		case *parser.ProjectDesc:
@@ -376,9 +408,53 @@ loop:
 		RemoteResultSchema: remoteOperators[len(remoteOperators)-2].OutSchema().EventSchema,
 		FullKeyLookup:      isFullKeyLookup,
 		ParamSchema:        paramSchema,
+		AsOf:               asOf,
 	}, nil
 }
 
+func newAsOf(version *int, asOfTime *time.Time, desc errMsgAtPositionProvider) *AsOf {
+	if version == nil && asOfTime == nil {
+		return nil
+	}
+	return &AsOf{Version: version, Time: asOfTime, desc: desc}
+}
+
+// resolveHighestVersion returns the version a query is executed at. This is the last completed version unless the
+// query is a point-in-time query, in which case it's the version specified by as_of, or the last version that
+// completed at or before the as_of time. Versions older than the oldest retained version, which is broadcast by the
+// version manager from the level manager, may already have been compacted so they cannot be queried.
+func (m *manager) resolveHighestVersion(info *QInfo, lastCompletedVersion int64) (int64, error) {
+	asOf := info.AsOf
+	if asOf == nil {
+		return lastCompletedVersion, nil
+	}
+	if asOf.Version != nil {
+		version := int64(*asOf.Version)
+		if version > lastCompletedVersion {
+			return 0, queryErrorAtTokenf("as_of", asOf.desc,
+				"version %d has not completed yet - the last completed version is %d", version, lastCompletedVersion)
+		}
+		oldestVersion := atomic.LoadInt64(&m.oldestRetainedVersion)
+		if version < oldestVersion {
+			return 0, queryErrorAtTokenf("as_of", asOf.desc,
+				"version %d is earlier than the retained history - the oldest version available is %d", version,
+				oldestVersion)
+		}
+		return version, nil
+	}
+	version, err := m.versionTimeProvider.GetVersionForTime(asOf.Time.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	if version == -1 || int64(version) < atomic.LoadInt64(&m.oldestRetainedVersion) {
+		return 0, queryErrorAtTokenf("as_of", asOf.desc,
+			"no version is available as of %s - it is earlier than the retained history",
+			asOf.Time.UTC().Format(time.RFC3339Nano))
+	}
+	// The version manager can be ahead of the last completed version broadcast to this node
+	return min(int64(version), lastCompletedVersion), nil
+}
+
 func (m *manager) createAndValidateLookupParamExprs(schema *evbatch.EventSchema, exprDescs []parser.ExprDesc,
 	slabInfo *opers.SlabInfo) ([]expr.Expression, error) {
 	var colExprs []expr.Expression
@@ -490,6 +566,77 @@ func (m *manager) ExecuteQueryDirect(tsl string, query parser.QueryDesc,
 	return err
 }
 
+// Explain returns a batch describing the operators of a deployed stream, or of the operators that would be created to
+// execute a query, with one row per operator in opers.ExplainSchema.
+func (m *manager) Explain(explain parser.ExplainDesc) (*evbatch.Batch, error) {
+	if explain.Query == nil {
+		plan, ok := m.streamInfoProvider.ExplainStream(explain.StreamName)
+		if !ok {
+			return nil, queryErrorAtTokenf(explain.StreamName, &explain, "unknown stream '%s'", explain.StreamName)
+		}
+		return opers.ExplainBatch(plan), nil
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	opDescs := explain.Query.OperatorDescs
+	info, err := m.createQueryInfo(opDescs, nil)
+	if err != nil {
+		return nil, err
+	}
+	partitions := 1
+	if !info.FullKeyLookup {
+		partitions = info.SlabInfo.Schema.Partitions
+	}
+	var plan []*opers.OperatorPlan
+	addOperator := func(operator string, definition string, inSchema *opers.OperatorSchema,
+		outSchema *opers.OperatorSchema, partitions int) *opers.OperatorPlan {
+		op := &opers.OperatorPlan{
+			ID:         len(plan),
+			ParentID:   len(plan) - 1,
+			Operator:   operator,
+			Definition: definition,
+			InSchema:   inSchema,
+			OutSchema:  outSchema,
+			Partitions: partitions,
+		}
+		plan = append(plan, op)
+		return op
+	}
+	// The last remote operator is the networkResultsOperator which sends results back to this node
+	remoteOperators := info.RemoteOperators[:len(info.RemoteOperators)-1]
+	for i, oper := range remoteOperators {
+		op := addOperator(opers.OperatorName(opDescs[i]), parser.Definition(opDescs[i]), oper.InSchema(),
+			oper.OutSchema(), partitions)
+		if i == 0 {
+			op.SlabIDs = []int{info.SlabInfo.SlabID}
+			op.UpstreamStreams = []string{info.SlabInfo.StreamName}
+		}
+	}
+	// Results from all the partitions are gathered on the node executing the query
+	gatherSchema := &opers.OperatorSchema{EventSchema: info.RemoteResultSchema}
+	gather := addOperator("gather", "", gatherSchema, gatherSchema, 1)
+	gather.Repartition = true
+	for i, oper := range info.LocalOperators {
+		desc := opDescs[len(remoteOperators)+i]
+		addOperator(opers.OperatorName(desc), parser.Definition(desc), gatherSchema,
+			&opers.OperatorSchema{EventSchema: oper.OutSchema().EventSchema}, 1)
+	}
+	return opers.ExplainBatch(plan), nil
+}
+
+// StreamStats returns a batch with the runtime metrics, on this node, of the operators of one stream, or of all
+// streams if no stream name is given, with one row per operator in opers.StreamStatsSchema.
+func (m *manager) StreamStats(showStats parser.ShowStreamStatsDesc) (*evbatch.Batch, error) {
+	if showStats.StreamName == "" {
+		return opers.StreamStatsBatch(m.streamInfoProvider.GetAllStreamStats()), nil
+	}
+	stats, ok := m.streamInfoProvider.GetStreamStats(showStats.StreamName)
+	if !ok {
+		return nil, queryErrorAtTokenf(showStats.StreamName, &showStats, "unknown stream '%s'", showStats.StreamName)
+	}
+	return opers.StreamStatsBatch([]*opers.StreamStats{stats}), nil
+}
+
 func (m *manager) ExecutePreparedQuery(queryName string, args []any,
 	outputFunc func(last bool, numLastBatches int, batch *evbatch.Batch) error) (int, error) {
 	highestVersion := atomic.LoadInt64(&m.lastCompletedVersion)
@@ -518,6 +665,10 @@ func (m *manager) executeQuery(info *QInfo, queryName string, tsl string, args [
 		}
 		return 0, nil
 	}
+	highestVersion, err := m.resolveHighestVersion(info, highestVersion)
+	if err != nil {
+		return 0, err
+	}
 
 	execID, _ := uuid.New().MarshalBinary()
 	sExecID := common.ByteSliceToStringZeroCopy(execID)
@@ -550,6 +701,8 @@ func (m *manager) executeQuery(info *QInfo, queryName string, tsl string, args [
 				builders[i].(*evbatch.BytesColBuilder).Append(arg.([]byte))
 			case types.ColumnTypeIDTimestamp:
 				builders[i].(*evbatch.TimestampColBuilder).Append(arg.(types.Timestamp))
+			case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+				builders[i].(*evbatch.NestedColBuilder).Append(arg)
 			default:
 				panic("unexpected col type")
 			}
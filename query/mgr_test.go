@@ -111,6 +111,50 @@ prepare test_query1 := (get $p1:timestamp,$p2:string,$p3:float from test_slab1)
 	}
 }
 
+func TestExplainQuery(t *testing.T) {
+	keyCols := []int{0}
+	columnNames := []string{"f0", "f1", "f2"}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeString, types.ColumnTypeFloat}
+	schema := evbatch.NewEventSchema(columnNames, columnTypes)
+	slInfoProvider, slabID := createStreamInfoProvider("test_slab1", defaultSlabID, schema, defaultNumPartitions, keyCols)
+	ctx := setupQueryManagers(1, defaultNumPartitions, defaultMaxBatchRows, slInfoProvider)
+	defer ctx.tearDown(t)
+	mgr := ctx.qms[0].qm
+
+	ast, err := parser.NewParser(nil).ParseTSL(`explain (scan all from test_slab1) -> (filter by f0 > 10) -> (sort by f1)`)
+	require.NoError(t, err)
+	batch, err := mgr.Explain(*ast.Explain)
+	require.NoError(t, err)
+	require.Equal(t, 4, batch.RowCount)
+	expectedOperators := []string{"scan", "filter", "gather", "sort"}
+	expectedPartitions := []int64{defaultNumPartitions, defaultNumPartitions, 1, 1}
+	for i := 0; i < batch.RowCount; i++ {
+		require.Equal(t, int64(i), batch.GetIntColumn(0).Get(i))
+		require.Equal(t, expectedOperators[i], batch.GetStringColumn(2).Get(i))
+		require.Equal(t, expectedPartitions[i], batch.GetIntColumn(7).Get(i))
+		require.Equal(t, i == 2, batch.GetBoolColumn(9).Get(i))
+	}
+	require.True(t, batch.GetIntColumn(1).IsNull(0))
+	require.Equal(t, "(sort by f1)", batch.GetStringColumn(3).Get(3))
+	require.Equal(t, fmt.Sprintf("%d", slabID), batch.GetStringColumn(10).Get(0))
+	require.Equal(t, "test_slab1", batch.GetStringColumn(12).Get(0))
+
+	// A full key lookup only goes to one partition
+	ast, err = parser.NewParser(nil).ParseTSL(`explain (get 10 from test_slab1)`)
+	require.NoError(t, err)
+	batch, err = mgr.Explain(*ast.Explain)
+	require.NoError(t, err)
+	require.Equal(t, 2, batch.RowCount)
+	require.Equal(t, "get", batch.GetStringColumn(2).Get(0))
+	require.Equal(t, int64(1), batch.GetIntColumn(7).Get(0))
+
+	ast, err = parser.NewParser(nil).ParseTSL(`explain unknown_stream`)
+	require.NoError(t, err)
+	_, err = mgr.Explain(*ast.Explain)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "unknown stream 'unknown_stream'"))
+}
+
 func TestQMGetAll(t *testing.T) {
 	data := [][]any{
 		{int64(0), "x0", false, "val0"},
@@ -703,6 +747,113 @@ func TestQueryVersionsSnapshotIsolation(t *testing.T) {
 	executeQueryFromMgr(t, "test_query1", schema, keyCols, expectedKeyVals, argVals, data2, 1, mgr)
 }
 
+func TestQueryAsOf(t *testing.T) {
+	data := [][]any{
+		{int64(0), "foo0"},
+		{int64(1), "foo1"},
+		{int64(2), "foo2"},
+	}
+	data2 := [][]any{
+		{int64(0), "boo0"},
+		{int64(1), "boo1"},
+		{int64(2), "boo2"},
+	}
+	keyCols := []int{0}
+	columnTypes := []types.ColumnType{types.ColumnTypeInt, types.ColumnTypeString}
+	schema := evbatch.NewEventSchema([]string{"f0", "f1"}, columnTypes)
+	slInfoProvider, slabID := createStreamInfoProvider("test_slab1", defaultSlabID, schema, defaultNumPartitions, keyCols)
+	ctx := setupQueryManagers(defaultNumManagers, defaultNumPartitions, defaultMaxBatchRows, slInfoProvider)
+	defer ctx.tearDown(t)
+	writeDataToSlabWithVersion(t, "_default_", slabID, schema, keyCols, defaultNumPartitions, data, ctx.st, 10)
+	writeDataToSlabWithVersion(t, "_default_", slabID, schema, keyCols, defaultNumPartitions, data2, ctx.st, 13)
+	for _, mgrPair := range ctx.qms {
+		mgrPair.qm.SetLastCompletedVersion(13)
+		// Broadcast by the version manager - versions before 7 may have been compacted
+		mgrPair.qm.(*manager).SetOldestRetainedVersion(7)
+	}
+	t1 := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	ctx.vtp.addVersionTime(5, t1.Add(-time.Hour).UnixMilli())
+	ctx.vtp.addVersionTime(10, t1.UnixMilli())
+	ctx.vtp.addVersionTime(13, t1.Add(time.Minute).UnixMilli())
+	mgr := ctx.qms[0].qm
+
+	rows, err := executeDirectQuery(`(get 2 from test_slab1)`, schema, mgr)
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(2), "boo2"}}, rows)
+
+	rows, err = executeDirectQuery(`(get 2 from test_slab1 as_of = 12)`, schema, mgr)
+	require.NoError(t, err)
+	require.Equal(t, [][]any{{int64(2), "foo2"}}, rows)
+
+	rows, err = executeDirectQuery(`(scan all from test_slab1 as_of = "2024-05-01T09:30:30Z")`, schema, mgr)
+	require.NoError(t, err)
+	sortDataByKeyCols(rows, keyCols, []types.ColumnType{types.ColumnTypeInt})
+	require.Equal(t, data, rows)
+
+	rows, err = executeDirectQuery(`(scan 1 to end from test_slab1 as_of = "2024-05-01T09:31:00Z")`, schema, mgr)
+	require.NoError(t, err)
+	sortDataByKeyCols(rows, keyCols, []types.ColumnType{types.ColumnTypeInt})
+	require.Equal(t, data2[1:], rows)
+
+	_, err = executeDirectQuery(`(get 2 from test_slab1 as_of = 14)`, schema, mgr)
+	require.Error(t, err)
+	require.Equal(t, `version 14 has not completed yet - the last completed version is 13 (line 1 column 24):
+(get 2 from test_slab1 as_of = 14)
+                       ^`, err.Error())
+
+	_, err = executeDirectQuery(`(scan all from test_slab1 as_of = "2024-05-01T09:29:00+01:00")`, schema, mgr)
+	require.Error(t, err)
+	require.Equal(t, `no version is available as of 2024-05-01T08:29:00Z - it is earlier than the retained history (line 1 column 27):
+(scan all from test_slab1 as_of = "2024-05-01T09:29:00+01:00")
+                          ^`, err.Error())
+
+	// Version 5 completed at this time, but it may have been compacted
+	_, err = executeDirectQuery(`(scan all from test_slab1 as_of = "2024-05-01T08:45:00Z")`, schema, mgr)
+	require.Error(t, err)
+	require.Equal(t, `no version is available as of 2024-05-01T08:45:00Z - it is earlier than the retained history (line 1 column 27):
+(scan all from test_slab1 as_of = "2024-05-01T08:45:00Z")
+                          ^`, err.Error())
+
+	rows, err = executeDirectQuery(`(get 2 from test_slab1 as_of = 7)`, schema, mgr)
+	require.NoError(t, err)
+	require.Empty(t, rows)
+	_, err = executeDirectQuery(`(get 2 from test_slab1 as_of = 6)`, schema, mgr)
+	require.Error(t, err)
+	require.Equal(t, `version 6 is earlier than the retained history - the oldest version available is 7 (line 1 column 24):
+(get 2 from test_slab1 as_of = 6)
+                       ^`, err.Error())
+}
+
+func executeDirectQuery(tsl string, schema *evbatch.EventSchema, mgr Manager) ([][]any, error) {
+	queryDesc, err := parser.NewParser(nil).ParseQuery(tsl)
+	if err != nil {
+		return nil, err
+	}
+	var totRows [][]any
+	var lock sync.Mutex
+	var done sync.WaitGroup
+	done.Add(1)
+	var lastBatchCount int
+	err = mgr.ExecuteQueryDirect(tsl, *queryDesc, func(last bool, numLastBatches int, batch *evbatch.Batch) error {
+		rows := convertBatchToAnyArray(batch, schema)
+		lock.Lock()
+		defer lock.Unlock()
+		totRows = append(totRows, rows...)
+		if last {
+			lastBatchCount++
+			if lastBatchCount == numLastBatches {
+				done.Done()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	done.Wait()
+	return totRows, nil
+}
+
 func TestQueryFailsRemotingError(t *testing.T) {
 	ctx := setupForQueryFailureTests(t)
 	defer ctx.tearDown(t)
@@ -1055,6 +1206,30 @@ type mgrCtx struct {
 	qms  []*mgrPair
 	tnpp *tppm.TestNodePartitionProvider
 	st   tppm.Store
+	vtp  *testVersionTimeProvider
+}
+
+type testVersionTimeProvider struct {
+	lock         sync.Mutex
+	versionTimes [][2]int64
+}
+
+func (t *testVersionTimeProvider) addVersionTime(version int, unixMillis int64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.versionTimes = append(t.versionTimes, [2]int64{int64(version), unixMillis})
+}
+
+func (t *testVersionTimeProvider) GetVersionForTime(unixMillis int64) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	version := -1
+	for _, vt := range t.versionTimes {
+		if vt[1] <= unixMillis {
+			version = int(vt[0])
+		}
+	}
+	return version, nil
 }
 
 type mgrPair struct {
@@ -1113,6 +1288,7 @@ func setupQueryManagersWithClusterVersionProvider(numMgrs int, numPartitions int
 	for procID := range mapping {
 		procMgr.AddActiveProcessor(procID)
 	}
+	vtp := &testVersionTimeProvider{}
 	pairs := make([]*mgrPair, numMgrs)
 	addresses := make([]string, numMgrs)
 	for i := range addresses {
@@ -1122,7 +1298,7 @@ func setupQueryManagersWithClusterVersionProvider(numMgrs int, numPartitions int
 	for i := range pairs {
 		tm := newTestRemoting()
 		tm.start()
-		mgr := NewManager(npp, clustVersionProvider, i, slInfoProvider, nil, procMgr, tm, addresses, maxBatchRows,
+		mgr := NewManager(npp, clustVersionProvider, i, slInfoProvider, nil, procMgr, vtp, tm, addresses, maxBatchRows,
 			&expr.ExpressionFactory{}, p)
 		pair := &mgrPair{
 			qm: mgr,
@@ -1143,6 +1319,7 @@ func setupQueryManagersWithClusterVersionProvider(numMgrs int, numPartitions int
 		qms:  pairs,
 		st:   procMgr.GetStore(),
 		tnpp: npp,
+		vtp:  vtp,
 	}
 }
 
@@ -1507,3 +1684,15 @@ type testStreamInfoProvider struct {
 func (t *testStreamInfoProvider) GetStream(streamName string) *opers.StreamInfo {
 	return t.streams[streamName]
 }
+
+func (t *testStreamInfoProvider) ExplainStream(string) ([]*opers.OperatorPlan, bool) {
+	return nil, false
+}
+
+func (t *testStreamInfoProvider) GetStreamStats(string) (*opers.StreamStats, bool) {
+	return nil, false
+}
+
+func (t *testStreamInfoProvider) GetAllStreamStats() []*opers.StreamStats {
+	return nil
+}
@@ -820,6 +820,10 @@ func (t *testVmgrClient) IsFailureComplete(int) (bool, error) {
 	return true, nil
 }
 
+func (t *testVmgrClient) GetVersionForTime(int64) (int, error) {
+	return -1, nil
+}
+
 func (t *testVmgrClient) VersionFlushed(int, int, int) error {
 	return nil
 }
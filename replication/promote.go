@@ -0,0 +1,105 @@
+package replication
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/lsm"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/sst"
+	"time"
+)
+
+/*
+Promote prepares a replica so a cluster can be started from it, e.g. after the region of the replicated cluster has
+been lost. The client must be for the replica object store, and the bucket names and metadata key those the cluster
+started from the replica will use.
+
+A marker object is written to the replica which stops the replicator writing to it again, so if the replicated cluster
+comes back it cannot overwrite the data of the promoted cluster. Before that, the replica is checked to be complete -
+all tables referenced by the replicated master record must exist. Promoting is idempotent, so a cluster can be
+restarted with promotion still configured.
+
+The information of the snapshot the replica was promoted from is returned - anything committed to the replicated
+cluster after the snapshot time is lost.
+*/
+func Promote(client objstore.Client, dataBucketName string, metaDataBucketName string,
+	metaDataKey string) (SnapshotInfo, error) {
+	var info SnapshotInfo
+	infoBytes, err := objstore.GetWithTimeout(client, metaDataBucketName, SnapshotInfoKey, objStoreCallTimeout)
+	if err != nil {
+		return info, err
+	}
+	metaData, err := objstore.GetWithTimeout(client, metaDataBucketName, metaDataKey, objStoreCallTimeout)
+	if err != nil {
+		return info, err
+	}
+	if infoBytes == nil || metaData == nil {
+		return info, errors.Errorf("cannot promote replica - no snapshot has been replicated to bucket %s",
+			metaDataBucketName)
+	}
+	if err := json.Unmarshal(infoBytes, &info); err != nil {
+		return info, errors.WithStack(err)
+	}
+	mr := &lsm.MasterRecord{}
+	mr.Deserialize(metaData, 0)
+	if err := checkTablesExist(client, dataBucketName, mr.TableIDs()); err != nil {
+		return info, err
+	}
+	promotedBytes, err := json.Marshal(&promotedMarker{PromotedTime: time.Now()})
+	if err != nil {
+		return info, errors.WithStack(err)
+	}
+	ok, _, err := objstore.PutIfNotExistsWithTimeout(client, metaDataBucketName, PromotedKey, promotedBytes,
+		objStoreCallTimeout)
+	if err != nil {
+		return info, err
+	}
+	if ok {
+		log.Infof("promoted replica in bucket %s from snapshot at %s with %d tables", dataBucketName,
+			info.SnapshotTime.Format(time.RFC3339), info.TableCount)
+	}
+	return info, nil
+}
+
+type promotedMarker struct {
+	PromotedTime time.Time `json:"promoted_time"`
+}
+
+func checkTablesExist(client objstore.Client, dataBucketName string, tableIDs []sst.SSTableID) error {
+	infos, err := objstore.ListObjectsWithPrefixWithTimeout(client, dataBucketName, sst.SSTableIDPrefix, -1,
+		listTimeout)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		existing[info.Key] = struct{}{}
+	}
+	var missing []string
+	for _, tableID := range tableIDs {
+		if _, ok := existing[string(tableID)]; !ok {
+			missing = append(missing, string(tableID))
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("cannot promote replica - %d tables referenced by the replicated metadata are missing from bucket %s: %v",
+			len(missing), dataBucketName, missing)
+	}
+	return nil
+}
+
+// IsUnpromotedReplica returns true if the metadata bucket holds a replica which has not been promoted. A cluster must
+// not be started from such a replica, as the replicator would still be writing to it.
+func IsUnpromotedReplica(client objstore.Client, metaDataBucketName string) (bool, error) {
+	_, replica, err := objstore.GetObjectInfoWithTimeout(client, metaDataBucketName, SnapshotInfoKey,
+		objStoreCallTimeout)
+	if err != nil || !replica {
+		return false, err
+	}
+	_, promoted, err := objstore.GetObjectInfoWithTimeout(client, metaDataBucketName, PromotedKey, objStoreCallTimeout)
+	if err != nil {
+		return false, err
+	}
+	return !promoted, nil
+}
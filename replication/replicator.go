@@ -0,0 +1,452 @@
+package replication
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/cluster"
+	"github.com/spirit-labs/tektite/common"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/lsm"
+	"github.com/spirit-labs/tektite/objstore"
+	"github.com/spirit-labs/tektite/sst"
+	"sync"
+	"time"
+)
+
+const (
+	// SnapshotInfoKey is the key of the object in the replica metadata bucket describing the last snapshot written
+	SnapshotInfoKey = "replication-snapshot"
+	// PromotedKey is the key of the object written to the replica metadata bucket when the replica is promoted
+	PromotedKey = "replication-promoted"
+
+	copyQueueSize       = 10000
+	listTimeout         = 1 * time.Minute
+	deleteBatchSize     = 1000
+	objStoreCallTimeout = 30 * time.Second
+)
+
+var (
+	errFenced        = errors.New("replica has been written to by another cluster - replication is stopped")
+	errTableNotFound = errors.New("table not found")
+	errNotActive     = errors.New("replicator is not active")
+)
+
+type Conf struct {
+	// ReplicaObjStore is the object store that the data is replicated to. If nil, replication is disabled.
+	ReplicaObjStore           objstore.Client
+	ReplicaDataBucketName     string
+	ReplicaMetaDataBucketName string
+	// SnapshotInterval is the interval between writing snapshots of the cluster metadata to the replica. The replica
+	// can be behind by at most this interval plus the time to write a snapshot.
+	SnapshotInterval time.Duration
+}
+
+func NewConf() Conf {
+	return Conf{
+		ReplicaDataBucketName:     "tektite-data",
+		ReplicaMetaDataBucketName: "controller-meta-data",
+		SnapshotInterval:          30 * time.Second,
+	}
+}
+
+func (c *Conf) Enabled() bool {
+	return c.ReplicaObjStore != nil
+}
+
+func (c *Conf) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.ReplicaDataBucketName == "" || c.ReplicaMetaDataBucketName == "" {
+		return errors.New("invalid replication configuration - replica bucket names must be specified")
+	}
+	if c.SnapshotInterval <= 0 {
+		return errors.Errorf("invalid replication snapshot interval %s - must be > 0", c.SnapshotInterval)
+	}
+	return nil
+}
+
+// SnapshotInfo describes a snapshot of the cluster metadata written to the replica
+type SnapshotInfo struct {
+	// SnapshotTime is when the metadata was read from the source - the replica contains everything committed before
+	// this time
+	SnapshotTime time.Time `json:"snapshot_time"`
+	TableCount   int       `json:"table_count"`
+}
+
+type Status struct {
+	Active           bool         `json:"active"`
+	Fenced           bool         `json:"fenced"`
+	LastSnapshot     SnapshotInfo `json:"last_snapshot"`
+	ReplicatedTables int          `json:"replicated_tables"`
+	LastError        string       `json:"last_error,omitempty"`
+}
+
+/*
+Replicator replicates the data of an agent cluster to a second object store, typically in another region, so a
+standby cluster can be started from it if the region is lost.
+
+Everything the cluster needs is in object storage: the tables and the LSM master record which references them - topic
+metadata, offsets, ACLs and so on are all stored in tables. Tables are immutable, so are copied as soon as the
+controller has registered them. Periodically, the master record is read and written to the replica as a snapshot,
+after first copying any tables it references which have not been copied yet. The replica is therefore always
+consistent - its master record only references tables which exist in it - and is behind by at most the snapshot
+interval. Tables which are no longer referenced, e.g. as they have been compacted, are deleted from the replica once
+they have not been referenced for a snapshot interval.
+
+The replicator only runs on the agent which is the cluster leader, as that is where the controller is active. Cluster
+membership is not replicated - the standby cluster has its own.
+
+The replica must only be written to by the replicator until it is promoted (see Promote). Once promoted, or if
+anything else writes the master record in the replica, the replicator stops.
+*/
+type Replicator struct {
+	lock                 sync.Mutex
+	snapshotLock         sync.Mutex
+	cfg                  Conf
+	source               objstore.Client
+	target               objstore.Client
+	dataBucketName       string
+	metaDataBucketName   string
+	metaDataKey          string
+	copyTasks            chan string
+	copyWg               sync.WaitGroup
+	active               bool
+	initialised          bool
+	fenced               bool
+	timer                *time.Timer
+	replicated           map[string]time.Time
+	targetEtag           string
+	prevSnapshotReadTime time.Time
+	lastSnapshot         SnapshotInfo
+	lastErr              error
+}
+
+// NewReplicator creates a Replicator which replicates from the data bucket and metadata key in source to target, which
+// must be a client for the object store in the configuration.
+func NewReplicator(cfg Conf, source objstore.Client, target objstore.Client, dataBucketName string,
+	metaDataBucketName string, metaDataKey string) *Replicator {
+	return &Replicator{
+		cfg:                cfg,
+		source:             source,
+		target:             target,
+		dataBucketName:     dataBucketName,
+		metaDataBucketName: metaDataBucketName,
+		metaDataKey:        metaDataKey,
+	}
+}
+
+func (r *Replicator) Start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.copyTasks != nil {
+		return
+	}
+	r.copyTasks = make(chan string, copyQueueSize)
+	r.copyWg = sync.WaitGroup{}
+	r.copyWg.Add(1)
+	copyTasks := r.copyTasks
+	common.Go(func() {
+		defer r.copyWg.Done()
+		for tableID := range copyTasks {
+			if err := r.maybeCopyTable(tableID); err != nil && !errors.Is(err, errNotActive) {
+				// It will be copied by the next snapshot
+				log.Warnf("failed to replicate table %s: %v", tableID, err)
+			}
+		}
+	})
+}
+
+func (r *Replicator) Stop() {
+	r.lock.Lock()
+	if r.copyTasks == nil {
+		r.lock.Unlock()
+		return
+	}
+	r.deactivate()
+	close(r.copyTasks)
+	r.copyTasks = nil
+	r.lock.Unlock()
+	r.copyWg.Wait()
+	// Wait for any snapshot in progress
+	r.snapshotLock.Lock()
+	r.snapshotLock.Unlock() //nolint:staticcheck
+}
+
+// MembershipChanged activates the replicator if this agent is the cluster leader, and deactivates it if not
+func (r *Replicator) MembershipChanged(thisMemberID int32, membership cluster.MembershipState) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	leader := len(membership.Members) > 0 && membership.Members[0].ID == thisMemberID
+	if leader && !r.active && r.copyTasks != nil {
+		log.Infof("replicator activating, replicating to %s", r.cfg.ReplicaDataBucketName)
+		r.active = true
+		r.scheduleSnapshot()
+	} else if !leader && r.active {
+		r.deactivate()
+	}
+	return nil
+}
+
+func (r *Replicator) deactivate() {
+	r.active = false
+	r.initialised = false
+	r.replicated = nil
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// TablesRegistered asynchronously copies the tables to the replica
+func (r *Replicator) TablesRegistered(tableIDs []sst.SSTableID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.active || r.fenced {
+		return
+	}
+	for _, tableID := range tableIDs {
+		select {
+		case r.copyTasks <- string(tableID):
+		default:
+			log.Debugf("replication copy queue is full - table %s will be copied by the next snapshot", tableID)
+		}
+	}
+}
+
+func (r *Replicator) Status() Status {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	status := Status{
+		Active:           r.active,
+		Fenced:           r.fenced,
+		LastSnapshot:     r.lastSnapshot,
+		ReplicatedTables: len(r.replicated),
+	}
+	if r.lastErr != nil {
+		status.LastError = r.lastErr.Error()
+	}
+	return status
+}
+
+func (r *Replicator) scheduleSnapshot() {
+	r.timer = time.AfterFunc(r.cfg.SnapshotInterval, func() {
+		err := r.snapshot()
+		if err != nil {
+			log.Warnf("failed to write replication snapshot: %v", err)
+		}
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.lastErr = err
+		if r.active && !r.fenced {
+			r.scheduleSnapshot()
+		}
+	})
+}
+
+// snapshot copies any tables referenced by the master record which have not been copied yet, then writes the master
+// record to the replica
+func (r *Replicator) snapshot() error {
+	r.snapshotLock.Lock()
+	defer r.snapshotLock.Unlock()
+	r.lock.Lock()
+	active, initialised := r.active, r.initialised
+	r.lock.Unlock()
+	if !active {
+		return nil
+	}
+	if !initialised {
+		if err := r.init(); err != nil {
+			return err
+		}
+	}
+	readTime := time.Now()
+	metaData, err := objstore.GetWithTimeout(r.source, r.metaDataBucketName, r.metaDataKey, objStoreCallTimeout)
+	if err != nil {
+		return err
+	}
+	if metaData == nil {
+		// Nothing registered yet
+		return nil
+	}
+	mr := &lsm.MasterRecord{}
+	mr.Deserialize(metaData, 0)
+	tableIDs := mr.TableIDs()
+	referenced := make(map[string]struct{}, len(tableIDs))
+	for _, tableID := range tableIDs {
+		sTableID := string(tableID)
+		referenced[sTableID] = struct{}{}
+		if err := r.maybeCopyTable(sTableID); err != nil {
+			if errors.Is(err, errNotActive) {
+				return nil
+			}
+			if errors.Is(err, errTableNotFound) {
+				// It must have been compacted since we read the master record
+				return errors.Errorf("table %s referenced by the master record no longer exists - will retry", sTableID)
+			}
+			return err
+		}
+	}
+	if err := r.writeMetaData(metaData); err != nil {
+		if errors.Is(err, errNotActive) {
+			return nil
+		}
+		return err
+	}
+	info := SnapshotInfo{SnapshotTime: readTime, TableCount: len(tableIDs)}
+	infoBytes, err := json.Marshal(&info)
+	if err != nil {
+		return err
+	}
+	if err := objstore.PutWithTimeout(r.target, r.cfg.ReplicaMetaDataBucketName, SnapshotInfoKey, infoBytes,
+		objStoreCallTimeout); err != nil {
+		return err
+	}
+	// Tables copied before the previous snapshot which are not referenced by this one are no longer needed. Tables
+	// copied more recently may not have been in the master record when it was read.
+	var toDelete []string
+	r.lock.Lock()
+	r.lastSnapshot = info
+	for tableID, copiedTime := range r.replicated {
+		if _, ok := referenced[tableID]; !ok && copiedTime.Before(r.prevSnapshotReadTime) {
+			toDelete = append(toDelete, tableID)
+			delete(r.replicated, tableID)
+		}
+	}
+	r.prevSnapshotReadTime = readTime
+	r.lock.Unlock()
+	for start := 0; start < len(toDelete); start += deleteBatchSize {
+		batch := toDelete[start:min(start+deleteBatchSize, len(toDelete))]
+		if err := objstore.DeleteAllWithTimeout(r.target, r.cfg.ReplicaDataBucketName, batch,
+			objStoreCallTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// init loads the state of the replica, so tables which have already been replicated are not copied again
+func (r *Replicator) init() error {
+	if err := r.checkNotPromoted(); err != nil {
+		return err
+	}
+	initTime := time.Now()
+	infos, err := objstore.ListObjectsWithPrefixWithTimeout(r.target, r.cfg.ReplicaDataBucketName, sst.SSTableIDPrefix,
+		-1, listTimeout)
+	if err != nil {
+		return err
+	}
+	replicated := make(map[string]time.Time, len(infos))
+	for _, info := range infos {
+		replicated[info.Key] = info.LastModified
+	}
+	metaDataInfo, exists, err := objstore.GetObjectInfoWithTimeout(r.target, r.cfg.ReplicaMetaDataBucketName,
+		r.metaDataKey, objStoreCallTimeout)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.active {
+		return nil
+	}
+	// Tables copied since we listed will be added by maybeCopyTable
+	for tableID, copiedTime := range r.replicated {
+		replicated[tableID] = copiedTime
+	}
+	r.replicated = replicated
+	if exists {
+		r.targetEtag = metaDataInfo.Etag
+	} else {
+		r.targetEtag = ""
+	}
+	r.prevSnapshotReadTime = initTime
+	r.initialised = true
+	return nil
+}
+
+func (r *Replicator) maybeCopyTable(tableID string) error {
+	r.lock.Lock()
+	if !r.active || !r.initialised {
+		r.lock.Unlock()
+		return errNotActive
+	}
+	_, replicated := r.replicated[tableID]
+	r.lock.Unlock()
+	if replicated {
+		return nil
+	}
+	table, err := objstore.GetWithTimeout(r.source, r.dataBucketName, tableID, objStoreCallTimeout)
+	if err != nil {
+		return err
+	}
+	if table == nil {
+		return errTableNotFound
+	}
+	if err := objstore.PutWithTimeout(r.target, r.cfg.ReplicaDataBucketName, tableID, table,
+		objStoreCallTimeout); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.replicated != nil {
+		r.replicated[tableID] = time.Now()
+	}
+	return nil
+}
+
+// writeMetaData writes the master record to the replica. The write is conditional on the master record not having
+// been written by anything else since we last wrote it, e.g. a cluster started from the replica
+func (r *Replicator) writeMetaData(metaData []byte) error {
+	if err := r.checkNotPromoted(); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	active, etag := r.active && r.initialised, r.targetEtag
+	r.lock.Unlock()
+	if !active {
+		return errNotActive
+	}
+	var ok bool
+	var newEtag string
+	var err error
+	if etag == "" {
+		ok, newEtag, err = objstore.PutIfNotExistsWithTimeout(r.target, r.cfg.ReplicaMetaDataBucketName, r.metaDataKey,
+			metaData, objStoreCallTimeout)
+	} else {
+		ok, newEtag, err = objstore.PutIfMatchingEtagWithTimeout(r.target, r.cfg.ReplicaMetaDataBucketName,
+			r.metaDataKey, metaData, etag, objStoreCallTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return r.fence()
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.targetEtag = newEtag
+	return nil
+}
+
+func (r *Replicator) checkNotPromoted() error {
+	_, promoted, err := objstore.GetObjectInfoWithTimeout(r.target, r.cfg.ReplicaMetaDataBucketName, PromotedKey,
+		objStoreCallTimeout)
+	if err != nil {
+		return err
+	}
+	if promoted {
+		return r.fence()
+	}
+	return nil
+}
+
+func (r *Replicator) fence() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.fenced {
+		log.Errorf("replica in %s has been promoted or written to by another cluster - replication is stopped",
+			r.cfg.ReplicaMetaDataBucketName)
+	}
+	r.fenced = true
+	return errFenced
+}
@@ -0,0 +1,402 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spirit-labs/tektite/cluster"
+	"github.com/spirit-labs/tektite/lsm"
+	"github.com/spirit-labs/tektite/objstore/dev"
+	"github.com/spirit-labs/tektite/sst"
+	"github.com/spirit-labs/tektite/testutils"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const (
+	dataBucketName     = "source-data"
+	metaDataBucketName = "source-meta-data"
+	metaDataKey        = "meta-data"
+
+	replicaDataBucketName     = "replica-data"
+	replicaMetaDataBucketName = "replica-meta-data"
+)
+
+func TestSnapshot(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+
+	tableIDs := putTables(t, source, 3)
+	putMasterRecord(t, source, tableIDs...)
+	start := time.Now()
+	err := replicator.snapshot()
+	require.NoError(t, err)
+
+	requireReplicated(t, source, target, tableIDs...)
+	requireMetaDataReplicated(t, source, target)
+	info := getSnapshotInfo(t, target)
+	require.Equal(t, 3, info.TableCount)
+	require.True(t, !info.SnapshotTime.Before(start))
+	status := replicator.Status()
+	require.True(t, status.Active)
+	require.False(t, status.Fenced)
+	require.Equal(t, 3, status.ReplicatedTables)
+	require.Equal(t, info.SnapshotTime.UnixMilli(), status.LastSnapshot.SnapshotTime.UnixMilli())
+
+	// Tables not in the master record are not copied by the snapshot
+	unregistered := putTables(t, source, 1)
+	err = replicator.snapshot()
+	require.NoError(t, err)
+	requireNotExists(t, target, replicaDataBucketName, string(unregistered[0]))
+}
+
+func TestSnapshotNoMetaData(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+	putTables(t, source, 1)
+	err := replicator.snapshot()
+	require.NoError(t, err)
+	requireNotExists(t, target, replicaMetaDataBucketName, metaDataKey, SnapshotInfoKey)
+}
+
+func TestTablesRegistered(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+	// Tables are only copied once the replicator has initialised
+	err := replicator.snapshot()
+	require.NoError(t, err)
+
+	tableIDs := putTables(t, source, 3)
+	replicator.TablesRegistered(tableIDs)
+	testutils.WaitUntil(t, func() (bool, error) {
+		return replicator.Status().ReplicatedTables == 3, nil
+	})
+	requireReplicated(t, source, target, tableIDs...)
+	// The master record isn't written until the next snapshot
+	requireNotExists(t, target, replicaMetaDataBucketName, metaDataKey)
+}
+
+func TestSnapshotDeletesUnreferencedTables(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+
+	tableIDs := putTables(t, source, 3)
+	putMasterRecord(t, source, tableIDs...)
+	err := replicator.snapshot()
+	require.NoError(t, err)
+	requireReplicated(t, source, target, tableIDs...)
+
+	// Compact the first two tables into a new one
+	compacted := putTables(t, source, 1)
+	putMasterRecord(t, source, tableIDs[2], compacted[0])
+
+	// The tables are not deleted until they were copied before the previous snapshot, as they could have been in
+	// the master record read by it
+	err = replicator.snapshot()
+	require.NoError(t, err)
+	requireReplicated(t, source, target, tableIDs[2], compacted[0])
+	requireMetaDataReplicated(t, source, target)
+
+	err = replicator.snapshot()
+	require.NoError(t, err)
+	requireReplicated(t, source, target, tableIDs[2], compacted[0])
+	requireNotExists(t, target, replicaDataBucketName, string(tableIDs[0]), string(tableIDs[1]))
+	require.Equal(t, 2, replicator.Status().ReplicatedTables)
+}
+
+func TestSnapshotMissingTable(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+
+	tableIDs := putTables(t, source, 1)
+	missingID := sst.CreateSSTableId()
+	putMasterRecord(t, source, tableIDs[0], []byte(missingID))
+	err := replicator.snapshot()
+	require.Error(t, err)
+	// The master record must not be written as the replica would be inconsistent
+	requireNotExists(t, target, replicaMetaDataBucketName, metaDataKey)
+
+	putMasterRecord(t, source, tableIDs...)
+	err = replicator.snapshot()
+	require.NoError(t, err)
+	requireMetaDataReplicated(t, source, target)
+}
+
+func TestResumeReplication(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	tableIDs := putTables(t, source, 2)
+	putMasterRecord(t, source, tableIDs...)
+	err := replicator.snapshot()
+	require.NoError(t, err)
+	replicator.Stop()
+
+	// A new replicator, e.g. on a new leader, loads the state of the replica
+	replicator2 := newTestReplicator(source, target)
+	replicator2.Start()
+	defer replicator2.Stop()
+	activate(t, replicator2)
+	// Delete from the source so we know they aren't copied again
+	err = source.DeleteAll(context.Background(), dataBucketName, []string{string(tableIDs[0]), string(tableIDs[1])})
+	require.NoError(t, err)
+	moreTableIDs := putTables(t, source, 1)
+	allTableIDs := append(tableIDs, moreTableIDs...)
+	putMasterRecord(t, source, allTableIDs...)
+	err = replicator2.snapshot()
+	require.NoError(t, err)
+	require.Equal(t, 3, replicator2.Status().ReplicatedTables)
+	requireMetaDataReplicated(t, source, target)
+}
+
+func TestFencedByPromotion(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+	tableIDs := putTables(t, source, 1)
+	putMasterRecord(t, source, tableIDs...)
+	err := replicator.snapshot()
+	require.NoError(t, err)
+
+	_, err = Promote(target, replicaDataBucketName, replicaMetaDataBucketName, metaDataKey)
+	require.NoError(t, err)
+
+	putMasterRecord(t, source, append(tableIDs, putTables(t, source, 1)...)...)
+	err = replicator.snapshot()
+	require.Error(t, err)
+	require.True(t, replicator.Status().Fenced)
+	requireReplicaMetaDataTables(t, target, tableIDs...)
+}
+
+func TestFencedByOtherWriter(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+	tableIDs := putTables(t, source, 1)
+	putMasterRecord(t, source, tableIDs...)
+	err := replicator.snapshot()
+	require.NoError(t, err)
+
+	// Something else writes the master record in the replica
+	otherTableIDs := putTables(t, target, 1)
+	putMasterRecordInBucket(t, target, replicaMetaDataBucketName, otherTableIDs...)
+
+	err = replicator.snapshot()
+	require.Error(t, err)
+	require.True(t, replicator.Status().Fenced)
+	requireReplicaMetaDataTables(t, target, otherTableIDs...)
+}
+
+func TestNotLeader(t *testing.T) {
+	source := dev.NewInMemStore(0)
+	target := dev.NewInMemStore(0)
+	replicator := newTestReplicator(source, target)
+	replicator.Start()
+	defer replicator.Stop()
+	err := replicator.MembershipChanged(1, cluster.MembershipState{
+		Members: []cluster.MembershipEntry{{ID: 0}, {ID: 1}},
+	})
+	require.NoError(t, err)
+	require.False(t, replicator.Status().Active)
+
+	tableIDs := putTables(t, source, 1)
+	putMasterRecord(t, source, tableIDs...)
+	replicator.TablesRegistered(tableIDs)
+	err = replicator.snapshot()
+	require.NoError(t, err)
+	requireNotExists(t, target, replicaDataBucketName, string(tableIDs[0]))
+	requireNotExists(t, target, replicaMetaDataBucketName, metaDataKey)
+
+	// Becomes leader
+	activate(t, replicator)
+	require.True(t, replicator.Status().Active)
+	err = replicator.snapshot()
+	require.NoError(t, err)
+	requireReplicated(t, source, target, tableIDs...)
+
+	// Stops being leader
+	err = replicator.MembershipChanged(1, cluster.MembershipState{
+		Members: []cluster.MembershipEntry{{ID: 2}, {ID: 1}},
+	})
+	require.NoError(t, err)
+	require.False(t, replicator.Status().Active)
+}
+
+func TestPeriodicSnapshot(t *testing.T) {
+	source := dev.NewInMemStore(0)
+	target := dev.NewInMemStore(0)
+	cfg := testConf(target)
+	cfg.SnapshotInterval = 10 * time.Millisecond
+	replicator := NewReplicator(cfg, source, target, dataBucketName, metaDataBucketName, metaDataKey)
+	replicator.Start()
+	defer replicator.Stop()
+	activate(t, replicator)
+
+	tableIDs := putTables(t, source, 2)
+	putMasterRecord(t, source, tableIDs...)
+	testutils.WaitUntil(t, func() (bool, error) {
+		v, err := target.Get(context.Background(), replicaMetaDataBucketName, metaDataKey)
+		return v != nil, err
+	})
+	requireReplicated(t, source, target, tableIDs...)
+}
+
+func TestPromote(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+
+	// Nothing to promote
+	_, err := Promote(target, replicaDataBucketName, replicaMetaDataBucketName, metaDataKey)
+	require.Error(t, err)
+
+	tableIDs := putTables(t, source, 2)
+	putMasterRecord(t, source, tableIDs...)
+	err = replicator.snapshot()
+	require.NoError(t, err)
+
+	info, err := Promote(target, replicaDataBucketName, replicaMetaDataBucketName, metaDataKey)
+	require.NoError(t, err)
+	require.Equal(t, 2, info.TableCount)
+	require.Equal(t, getSnapshotInfo(t, target), info)
+	v, err := target.Get(context.Background(), replicaMetaDataBucketName, PromotedKey)
+	require.NoError(t, err)
+	require.NotNil(t, v)
+
+	// Promoting again is ok
+	info2, err := Promote(target, replicaDataBucketName, replicaMetaDataBucketName, metaDataKey)
+	require.NoError(t, err)
+	require.Equal(t, info, info2)
+}
+
+func TestPromoteMissingTables(t *testing.T) {
+	source, target, replicator := setupReplicator(t)
+	defer replicator.Stop()
+	tableIDs := putTables(t, source, 2)
+	putMasterRecord(t, source, tableIDs...)
+	err := replicator.snapshot()
+	require.NoError(t, err)
+
+	err = target.Delete(context.Background(), replicaDataBucketName, string(tableIDs[1]))
+	require.NoError(t, err)
+	_, err = Promote(target, replicaDataBucketName, replicaMetaDataBucketName, metaDataKey)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), string(tableIDs[1]))
+	requireNotExists(t, target, replicaMetaDataBucketName, PromotedKey)
+}
+
+func setupReplicator(t *testing.T) (*dev.InMemStore, *dev.InMemStore, *Replicator) {
+	source := dev.NewInMemStore(0)
+	target := dev.NewInMemStore(0)
+	replicator := newTestReplicator(source, target)
+	replicator.Start()
+	activate(t, replicator)
+	return source, target, replicator
+}
+
+func newTestReplicator(source *dev.InMemStore, target *dev.InMemStore) *Replicator {
+	return NewReplicator(testConf(target), source, target, dataBucketName, metaDataBucketName, metaDataKey)
+}
+
+func testConf(target *dev.InMemStore) Conf {
+	cfg := NewConf()
+	cfg.ReplicaObjStore = target
+	cfg.ReplicaDataBucketName = replicaDataBucketName
+	cfg.ReplicaMetaDataBucketName = replicaMetaDataBucketName
+	// Snapshots are run explicitly by the tests
+	cfg.SnapshotInterval = time.Hour
+	return cfg
+}
+
+func activate(t *testing.T, replicator *Replicator) {
+	err := replicator.MembershipChanged(1, cluster.MembershipState{
+		Members: []cluster.MembershipEntry{{ID: 1}},
+	})
+	require.NoError(t, err)
+}
+
+func putTables(t *testing.T, objStore *dev.InMemStore, numTables int) []sst.SSTableID {
+	var tableIDs []sst.SSTableID
+	for i := 0; i < numTables; i++ {
+		tableID := sst.CreateSSTableId()
+		err := objStore.Put(context.Background(), dataBucketName, tableID, []byte(fmt.Sprintf("table-%d", i)))
+		require.NoError(t, err)
+		tableIDs = append(tableIDs, []byte(tableID))
+	}
+	return tableIDs
+}
+
+func putMasterRecord(t *testing.T, objStore *dev.InMemStore, tableIDs ...sst.SSTableID) {
+	putMasterRecordInBucket(t, objStore, metaDataBucketName, tableIDs...)
+}
+
+func putMasterRecordInBucket(t *testing.T, objStore *dev.InMemStore, bucketName string, tableIDs ...sst.SSTableID) {
+	manager := lsm.NewManager(objStore, func() {}, false, false, lsm.NewConf())
+	err := manager.Start(nil)
+	require.NoError(t, err)
+	defer func() {
+		err := manager.Stop()
+		require.NoError(t, err)
+	}()
+	for i, tableID := range tableIDs {
+		_, err := manager.ApplyChanges(lsm.RegistrationBatch{
+			Registrations: []lsm.RegistrationEntry{{
+				Level:      0,
+				TableID:    tableID,
+				KeyStart:   []byte(fmt.Sprintf("key%06d", i*10)),
+				KeyEnd:     []byte(fmt.Sprintf("key%06d", i*10+9)),
+				MinVersion: uint64(i),
+				MaxVersion: uint64(i),
+				AddedTime:  uint64(time.Now().UnixMilli()),
+			}},
+		}, true)
+		require.NoError(t, err)
+	}
+	err = objStore.Put(context.Background(), bucketName, metaDataKey, manager.GetMasterRecordBytes())
+	require.NoError(t, err)
+}
+
+func requireReplicated(t *testing.T, source *dev.InMemStore, target *dev.InMemStore, tableIDs ...sst.SSTableID) {
+	t.Helper()
+	for _, tableID := range tableIDs {
+		expected, err := source.Get(context.Background(), dataBucketName, string(tableID))
+		require.NoError(t, err)
+		actual, err := target.Get(context.Background(), replicaDataBucketName, string(tableID))
+		require.NoError(t, err)
+		require.NotNil(t, actual, "table %s not replicated", string(tableID))
+		require.Equal(t, expected, actual)
+	}
+}
+
+func requireMetaDataReplicated(t *testing.T, source *dev.InMemStore, target *dev.InMemStore) {
+	t.Helper()
+	expected, err := source.Get(context.Background(), metaDataBucketName, metaDataKey)
+	require.NoError(t, err)
+	actual, err := target.Get(context.Background(), replicaMetaDataBucketName, metaDataKey)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func requireReplicaMetaDataTables(t *testing.T, target *dev.InMemStore, tableIDs ...sst.SSTableID) {
+	t.Helper()
+	metaData, err := target.Get(context.Background(), replicaMetaDataBucketName, metaDataKey)
+	require.NoError(t, err)
+	mr := &lsm.MasterRecord{}
+	mr.Deserialize(metaData, 0)
+	require.Equal(t, tableIDs, mr.TableIDs())
+}
+
+func requireNotExists(t *testing.T, objStore *dev.InMemStore, bucketName string, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		v, err := objStore.Get(context.Background(), bucketName, key)
+		require.NoError(t, err)
+		require.Nil(t, v, "object %s exists", key)
+	}
+}
+
+func getSnapshotInfo(t *testing.T, target *dev.InMemStore) SnapshotInfo {
+	infoBytes, err := target.Get(context.Background(), replicaMetaDataBucketName, SnapshotInfoKey)
+	require.NoError(t, err)
+	require.NotNil(t, infoBytes)
+	var info SnapshotInfo
+	err = json.Unmarshal(infoBytes, &info)
+	require.NoError(t, err)
+	return info
+}
@@ -0,0 +1,554 @@
+package schemareg
+
+import (
+	"encoding/json"
+	"github.com/apache/arrow/go/v11/arrow/decimal128"
+	"github.com/hamba/avro/v2"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/types"
+	"io"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// parseAvroSchema parses the schema with its own cache, so that named types from different schemas, or different
+// versions of the same schema, don't clash.
+func parseAvroSchema(schema string) (avro.Schema, error) {
+	s, err := avro.ParseWithCache(schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid avro schema")
+	}
+	return s, nil
+}
+
+func newAvroCodec(schema string) (*avroCodec, error) {
+	s, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := s.(*avro.RecordSchema)
+	if !ok {
+		return nil, errors.New("avro schema must be a record")
+	}
+	fields := make([]Field, len(root.Fields()))
+	for i, f := range root.Fields() {
+		fields[i] = Field{Name: f.Name(), Type: avroColumnType(f.Type()), Required: !f.HasDefault() && !avroNullable(f.Type())}
+	}
+	return &avroCodec{root: root, fields: fields}, nil
+}
+
+type avroCodec struct {
+	root   *avro.RecordSchema
+	fields []Field
+}
+
+func (a *avroCodec) Fields() []Field {
+	return a.fields
+}
+
+func (a *avroCodec) Decode(payload []byte) ([]any, error) {
+	values := make([]any, len(a.fields))
+	reader := avro.NewReader(nil, 0).Reset(payload)
+	for i, f := range a.root.Fields() {
+		v := reader.ReadNext(f.Type())
+		if err := reader.Error; err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errShortBuffer
+			}
+			return nil, errors.Wrapf(err, "failed to decode avro field '%s'", f.Name())
+		}
+		var err error
+		values[i], err = avroToColumnValue(f.Type(), a.fields[i].Type, fromAvroGeneric(f.Type(), v))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (a *avroCodec) Encode(values []any) ([]byte, error) {
+	writer := avro.NewWriter(nil, 64)
+	for i, f := range a.root.Fields() {
+		v, err := columnValueToGeneric(a.fields[i].Type, values[i])
+		if err != nil {
+			return nil, err
+		}
+		v, err = toAvroGeneric(f.Type(), v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode avro field '%s'", f.Name())
+		}
+		writer.WriteVal(f.Type(), v)
+		if writer.Error != nil {
+			return nil, errors.Wrapf(writer.Error, "failed to encode avro field '%s'", f.Name())
+		}
+	}
+	return writer.Buffer(), nil
+}
+
+var errShortBuffer = errors.New("unexpected end of data")
+
+func avroColumnType(s avro.Schema) types.ColumnType {
+	s = derefAvroSchema(s)
+	switch s.Type() {
+	case avro.Boolean:
+		return types.ColumnTypeBool
+	case avro.Int:
+		return types.ColumnTypeInt
+	case avro.Long:
+		if isAvroTimestamp(avroLogicalType(s)) {
+			return types.ColumnTypeTimestamp
+		}
+		return types.ColumnTypeInt
+	case avro.Float, avro.Double:
+		return types.ColumnTypeFloat
+	case avro.Bytes, avro.Fixed:
+		if dec := avroDecimal(s); dec != nil && dec.Precision() > 0 && dec.Precision() <= 38 {
+			return &types.DecimalType{Precision: dec.Precision(), Scale: dec.Scale()}
+		}
+		return types.ColumnTypeBytes
+	case avro.Union:
+		// A union of null and one other type is just a nullable column
+		if nonNull := nonNullBranch(s); nonNull != nil {
+			return avroColumnType(nonNull)
+		}
+		return types.ColumnTypeString
+	default:
+		return types.ColumnTypeString
+	}
+}
+
+func avroNullable(s avro.Schema) bool {
+	switch s.Type() {
+	case avro.Null:
+		return true
+	case avro.Union:
+		for _, branch := range s.(*avro.UnionSchema).Types() {
+			if branch.Type() == avro.Null {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func nonNullBranch(s avro.Schema) avro.Schema {
+	union, ok := s.(*avro.UnionSchema)
+	if !ok || !union.Nullable() {
+		return nil
+	}
+	_, typ := union.Indices()
+	return union.Types()[typ]
+}
+
+func derefAvroSchema(s avro.Schema) avro.Schema {
+	if ref, ok := s.(*avro.RefSchema); ok {
+		return ref.Schema()
+	}
+	return s
+}
+
+func avroLogicalType(s avro.Schema) avro.LogicalType {
+	if lts, ok := s.(avro.LogicalTypeSchema); ok && lts.Logical() != nil {
+		return lts.Logical().Type()
+	}
+	return ""
+}
+
+func avroDecimal(s avro.Schema) *avro.DecimalLogicalSchema {
+	if lts, ok := s.(avro.LogicalTypeSchema); ok {
+		dec, _ := lts.Logical().(*avro.DecimalLogicalSchema)
+		return dec
+	}
+	return nil
+}
+
+func isAvroTimestamp(logical avro.LogicalType) bool {
+	switch logical {
+	case avro.TimestampMillis, avro.TimestampMicros, avro.LocalTimestampMillis, avro.LocalTimestampMicros:
+		return true
+	}
+	return false
+}
+
+// avroUnionBranchName returns the name the avro library uses for a union branch in its generic representation.
+func avroUnionBranchName(s avro.Schema) string {
+	s = derefAvroSchema(s)
+	if named, ok := s.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	if logical := avroLogicalType(s); logical != "" {
+		return string(s.Type()) + "." + string(logical)
+	}
+	return string(s.Type())
+}
+
+// fromAvroGeneric converts a value decoded by the avro library to the values we use - unions are resolved to the value
+// of their branch, numbers are widened to int64 and float64, fixed values become []byte, decimals become
+// types.Decimal, and time logical types go back to the number they are encoded as.
+func fromAvroGeneric(s avro.Schema, v any) any {
+	if v == nil {
+		return nil
+	}
+	s = derefAvroSchema(s)
+	switch val := v.(type) {
+	case map[string]any:
+		switch s.Type() {
+		case avro.Union:
+			for name, branchVal := range val {
+				branch, _ := s.(*avro.UnionSchema).Types().Get(name)
+				return fromAvroGeneric(branch, branchVal)
+			}
+			return nil
+		case avro.Record:
+			for _, f := range s.(*avro.RecordSchema).Fields() {
+				val[f.Name()] = fromAvroGeneric(f.Type(), val[f.Name()])
+			}
+		case avro.Map:
+			for k, e := range val {
+				val[k] = fromAvroGeneric(s.(*avro.MapSchema).Values(), e)
+			}
+		}
+		return val
+	case []any:
+		for i, e := range val {
+			val[i] = fromAvroGeneric(s.(*avro.ArraySchema).Items(), e)
+		}
+		return val
+	case int:
+		return int64(val)
+	case float32:
+		return float64(val)
+	case *big.Rat:
+		dec := avroDecimal(s)
+		unscaled := new(big.Int).Mul(val.Num(), avroDecimalScale(dec.Scale()))
+		unscaled.Quo(unscaled, val.Denom())
+		return types.Decimal{Num: decimal128.FromBigInt(unscaled), Precision: dec.Precision(), Scale: dec.Scale()}
+	case time.Time:
+		switch avroLogicalType(s) {
+		case avro.Date:
+			return val.Unix() / int64(24*time.Hour/time.Second)
+		case avro.TimestampMicros:
+			return val.UnixMicro()
+		default:
+			return val.UnixMilli()
+		}
+	case time.Duration:
+		if avroLogicalType(s) == avro.TimeMicros {
+			return val.Microseconds()
+		}
+		return val.Milliseconds()
+	default:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Array {
+			// fixed
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return b
+		}
+		return v
+	}
+}
+
+func avroDecimalScale(scale int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+}
+
+func avroToColumnValue(s avro.Schema, colType types.ColumnType, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if nonNull := nonNullBranch(s); nonNull != nil {
+		s = nonNull
+	}
+	switch colType.ID() {
+	case types.ColumnTypeIDTimestamp:
+		ts := v.(int64)
+		switch avroLogicalType(s) {
+		case avro.TimestampMicros, avro.LocalTimestampMicros:
+			ts /= 1000
+		}
+		return types.NewTimestamp(ts), nil
+	case types.ColumnTypeIDString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return toJSONString(toJSONCompatible(v))
+	default:
+		return v, nil
+	}
+}
+
+// toJSONCompatible converts decoded nested values to values that are rendered sensibly as JSON.
+func toJSONCompatible(v any) any {
+	switch val := v.(type) {
+	case []byte:
+		return string(val)
+	case types.Decimal:
+		return json.Number(val.String())
+	case map[string]any:
+		for k, e := range val {
+			val[k] = toJSONCompatible(e)
+		}
+		return val
+	case []any:
+		for i, e := range val {
+			val[i] = toJSONCompatible(e)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// columnValueToGeneric converts a column value to the generic form accepted by the encoders.
+func columnValueToGeneric(colType types.ColumnType, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if colType.ID() == types.ColumnTypeIDString {
+		s := v.(string)
+		// String columns are either strings, or nested values as JSON, we only know which when encoding
+		return jsonOrString(s), nil
+	}
+	return v, nil
+}
+
+// jsonOrString is used for string columns - these may hold either plain strings or nested values as JSON.
+type jsonOrString string
+
+func (j jsonOrString) nested() (any, error) {
+	return fromJSONString(string(j))
+}
+
+// toAvroGeneric converts a generic value to the Go types the avro library encodes for the schema. Unions are given in
+// the library's map form, keyed by the name of the branch, so the branch is chosen here rather than by the library.
+func toAvroGeneric(s avro.Schema, v any) (any, error) {
+	s = derefAvroSchema(s)
+	if str, ok := v.(jsonOrString); ok {
+		switch s.Type() {
+		case avro.String, avro.Enum, avro.Bytes, avro.Fixed:
+			v = string(str)
+		case avro.Union:
+			// Resolved against the branches below
+		default:
+			nested, err := str.nested()
+			if err != nil {
+				return nil, err
+			}
+			v = nested
+		}
+	}
+	if v == nil {
+		return avroZero(s)
+	}
+	switch s.Type() {
+	case avro.Null:
+		return nil, errors.Errorf("cannot encode %v as null", v)
+	case avro.Boolean:
+		return toBool(v)
+	case avro.Int:
+		i, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		return int32(i), nil
+	case avro.Long:
+		i, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		switch avroLogicalType(s) {
+		case avro.TimestampMicros, avro.LocalTimestampMicros:
+			if _, ok := v.(types.Timestamp); ok {
+				i *= 1000
+			}
+		case avro.TimeMicros:
+			// The library encodes int64 as a time.Duration for this logical type
+			return time.Duration(i) * time.Microsecond, nil
+		}
+		return i, nil
+	case avro.Float:
+		f, err := toFloat64(v)
+		if err != nil {
+			return nil, err
+		}
+		return float32(f), nil
+	case avro.Double:
+		return toFloat64(v)
+	case avro.String:
+		return toString(v)
+	case avro.Bytes, avro.Fixed:
+		if dec := avroDecimal(s); dec != nil {
+			d, err := toDecimal(v, dec.Precision(), dec.Scale())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetFrac(d.Num.BigInt(), avroDecimalScale(dec.Scale())), nil
+		}
+		b, err := toBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		if fixed, ok := s.(*avro.FixedSchema); ok {
+			if len(b) != fixed.Size() {
+				return nil, errors.Errorf("value for fixed type '%s' must be %d bytes", fixed.FullName(), fixed.Size())
+			}
+			return avroFixedValue(fixed, b), nil
+		}
+		return b, nil
+	case avro.Enum:
+		str, err := toString(v)
+		if err != nil {
+			return nil, err
+		}
+		enum := s.(*avro.EnumSchema)
+		for _, sym := range enum.Symbols() {
+			if sym == str {
+				return str, nil
+			}
+		}
+		return nil, errors.Errorf("'%s' is not a symbol of enum '%s'", str, enum.FullName())
+	case avro.Union:
+		for _, branch := range s.(*avro.UnionSchema).Types() {
+			if branch.Type() == avro.Null {
+				continue
+			}
+			// Use the first branch that the value can be converted to
+			converted, err := toAvroGeneric(branch, v)
+			if err == nil {
+				return map[string]any{avroUnionBranchName(branch): converted}, nil
+			}
+		}
+		return nil, errors.Errorf("value %v does not match any type in union", v)
+	case avro.Record:
+		record, ok := v.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("cannot convert %v (%T) to a record", v, v)
+		}
+		converted := make(map[string]any, len(record))
+		for _, f := range s.(*avro.RecordSchema).Fields() {
+			fv, err := toAvroGeneric(f.Type(), record[f.Name()])
+			if err != nil {
+				return nil, errors.Wrapf(err, "field '%s'", f.Name())
+			}
+			converted[f.Name()] = fv
+		}
+		return converted, nil
+	case avro.Array:
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, errors.Errorf("cannot convert %v (%T) to an array", v, v)
+		}
+		converted := make([]any, len(arr))
+		for i, item := range arr {
+			var err error
+			if converted[i], err = toAvroGeneric(s.(*avro.ArraySchema).Items(), item); err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
+	case avro.Map:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("cannot convert %v (%T) to a map", v, v)
+		}
+		converted := make(map[string]any, len(m))
+		for k, item := range m {
+			var err error
+			if converted[k], err = toAvroGeneric(s.(*avro.MapSchema).Values(), item); err != nil {
+				return nil, err
+			}
+		}
+		return converted, nil
+	default:
+		return nil, errors.Errorf("unexpected avro type %s", s.Type())
+	}
+}
+
+// avroZero returns the value a missing value is encoded as - null where the type allows it, otherwise the zero value
+// of the type.
+func avroZero(s avro.Schema) (any, error) {
+	s = derefAvroSchema(s)
+	switch s.Type() {
+	case avro.Null:
+		return nil, nil
+	case avro.Boolean:
+		return false, nil
+	case avro.Int:
+		return int32(0), nil
+	case avro.Long:
+		if avroLogicalType(s) == avro.TimeMicros {
+			return time.Duration(0), nil
+		}
+		return int64(0), nil
+	case avro.Float:
+		return float32(0), nil
+	case avro.Double:
+		return float64(0), nil
+	case avro.String:
+		return "", nil
+	case avro.Bytes, avro.Fixed:
+		if avroDecimal(s) != nil {
+			return new(big.Rat), nil
+		}
+		if fixed, ok := s.(*avro.FixedSchema); ok {
+			return avroFixedValue(fixed, make([]byte, fixed.Size())), nil
+		}
+		return []byte{}, nil
+	case avro.Enum:
+		return s.(*avro.EnumSchema).Symbols()[0], nil
+	case avro.Array:
+		return []any{}, nil
+	case avro.Map:
+		return map[string]any{}, nil
+	case avro.Union:
+		branches := s.(*avro.UnionSchema).Types()
+		for _, branch := range branches {
+			if branch.Type() == avro.Null {
+				return map[string]any{}, nil
+			}
+		}
+		zero, err := avroZero(branches[0])
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{avroUnionBranchName(branches[0]): zero}, nil
+	case avro.Record:
+		fields := s.(*avro.RecordSchema).Fields()
+		record := make(map[string]any, len(fields))
+		for _, f := range fields {
+			zero, err := avroZero(f.Type())
+			if err != nil {
+				return nil, err
+			}
+			record[f.Name()] = zero
+		}
+		return record, nil
+	default:
+		return nil, errors.Errorf("unexpected avro type %s", s.Type())
+	}
+}
+
+// avroFixedValue returns the bytes as the array type the avro library encodes for a fixed type.
+func avroFixedValue(fixed *avro.FixedSchema, b []byte) any {
+	arr := reflect.New(reflect.ArrayOf(fixed.Size(), reflect.TypeOf(byte(0)))).Elem()
+	reflect.Copy(arr, reflect.ValueOf(b))
+	return arr.Interface()
+}
+
+func toDecimal(v any, precision int, scale int) (types.Decimal, error) {
+	switch val := v.(type) {
+	case types.Decimal:
+		return val.ConvertPrecisionAndScale(precision, scale), nil
+	case json.Number:
+		return types.NewDecimalFromString(val.String(), precision, scale)
+	case string:
+		return types.NewDecimalFromString(val, precision, scale)
+	case int64:
+		return types.NewDecimalFromInt64(val, precision, scale), nil
+	case float64:
+		return types.NewDecimalFromFloat64(val, precision, scale)
+	default:
+		return types.Decimal{}, errors.Errorf("cannot convert %v (%T) to a decimal", v, v)
+	}
+}
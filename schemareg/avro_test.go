@@ -0,0 +1,112 @@
+package schemareg
+
+import (
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const testAvroSchema = `{
+  "type": "record",
+  "name": "order",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": "long"},
+    {"name": "customer", "type": "string"},
+    {"name": "qty", "type": "int"},
+    {"name": "price", "type": "double"},
+    {"name": "express", "type": "boolean"},
+    {"name": "placed_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "shipped_at", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}]},
+    {"name": "total", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+    {"name": "status", "type": {"type": "enum", "name": "status", "symbols": ["NEW", "SHIPPED"]}},
+    {"name": "note", "type": ["null", "string"], "default": null},
+    {"name": "address", "type": {"type": "record", "name": "address", "fields": [
+      {"name": "city", "type": "string"}, {"name": "zip", "type": ["null", "int"]}]}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "attrs", "type": {"type": "map", "values": "long"}},
+    {"name": "billing", "type": ["null", "com.example.address"]},
+    {"name": "checksum", "type": {"type": "fixed", "name": "md5", "size": 4}}
+  ]
+}`
+
+func TestAvroFields(t *testing.T) {
+	codec, err := newAvroCodec(testAvroSchema)
+	require.NoError(t, err)
+	require.Equal(t, []Field{
+		{Name: "id", Type: types.ColumnTypeInt, Required: true},
+		{Name: "customer", Type: types.ColumnTypeString, Required: true},
+		{Name: "qty", Type: types.ColumnTypeInt, Required: true},
+		{Name: "price", Type: types.ColumnTypeFloat, Required: true},
+		{Name: "express", Type: types.ColumnTypeBool, Required: true},
+		{Name: "placed_at", Type: types.ColumnTypeTimestamp, Required: true},
+		{Name: "shipped_at", Type: types.ColumnTypeTimestamp},
+		{Name: "total", Type: &types.DecimalType{Precision: 10, Scale: 2}, Required: true},
+		{Name: "status", Type: types.ColumnTypeString, Required: true},
+		{Name: "note", Type: types.ColumnTypeString},
+		{Name: "address", Type: types.ColumnTypeString, Required: true},
+		{Name: "tags", Type: types.ColumnTypeString, Required: true},
+		{Name: "attrs", Type: types.ColumnTypeString, Required: true},
+		{Name: "billing", Type: types.ColumnTypeString},
+		{Name: "checksum", Type: types.ColumnTypeBytes, Required: true},
+	}, codec.Fields())
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	codec, err := newAvroCodec(testAvroSchema)
+	require.NoError(t, err)
+	total, err := types.NewDecimalFromString("-1234.56", 10, 2)
+	require.NoError(t, err)
+	values := []any{
+		int64(23), "bob", int64(3), 12.5, true, types.NewTimestamp(1700000000123), types.NewTimestamp(1700000001456),
+		total, "SHIPPED", nil, `{"city":"london","zip":null}`, `["a","b"]`, `{"x":1}`,
+		`{"city":"paris","zip":75001}`, []byte{1, 2, 3, 4},
+	}
+	encoded, err := codec.Encode(values)
+	require.NoError(t, err)
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, values, decoded)
+}
+
+func TestAvroDecodeKnownBytes(t *testing.T) {
+	codec, err := newAvroCodec(`{"type":"record","name":"r","fields":[{"name":"a","type":"long"},{"name":"b","type":"string"},
+		{"name":"c","type":["null","int"]},{"name":"d","type":{"type":"array","items":"int"}}]}`)
+	require.NoError(t, err)
+	// a=1, b="foo", c=null, d=[3,4] written as a block with a negative count and byte size
+	decoded, err := codec.Decode([]byte{0x02, 0x06, 'f', 'o', 'o', 0x00, 0x03, 0x04, 0x06, 0x08, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(1), "foo", nil, "[3,4]"}, decoded)
+}
+
+func TestAvroEncodeMissingValues(t *testing.T) {
+	codec, err := newAvroCodec(testAvroSchema)
+	require.NoError(t, err)
+	values := make([]any, len(codec.Fields()))
+	encoded, err := codec.Encode(values)
+	require.NoError(t, err)
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	// Nullable fields are null, everything else the zero value of its type
+	total := types.NewDecimalFromInt64(0, 10, 2)
+	require.Equal(t, []any{
+		int64(0), "", int64(0), float64(0), false, types.NewTimestamp(0), nil, total, "NEW", nil,
+		`{"city":"","zip":null}`, "[]", "{}", nil, []byte{0, 0, 0, 0},
+	}, decoded)
+}
+
+func TestAvroInvalid(t *testing.T) {
+	_, err := newAvroCodec(`"string"`)
+	require.Error(t, err)
+	require.Equal(t, "avro schema must be a record", err.Error())
+
+	_, err = newAvroCodec(`{"type":"record","name":"r","fields":[{"name":"a","type":"foo"}]}`)
+	require.Error(t, err)
+	require.Equal(t, "invalid avro schema: avro: unknown type: foo", err.Error())
+
+	codec, err := newAvroCodec(`{"type":"record","name":"r","fields":[{"name":"a","type":"string"}]}`)
+	require.NoError(t, err)
+	_, err = codec.Decode([]byte{0x06, 'f'})
+	require.Error(t, err)
+	require.Equal(t, "failed to decode avro field 'a': unexpected end of data", err.Error())
+}
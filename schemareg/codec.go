@@ -0,0 +1,128 @@
+package schemareg
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/types"
+)
+
+// Field is a top level field of a schema, and the type of the column it maps to. Fields with nested structure
+// (records, arrays, maps and the like) map to string columns holding the value as JSON, which can then be accessed
+// with the json_* functions.
+type Field struct {
+	Name string
+	Type types.ColumnType
+	// Required is true if the field is not nullable and has no default, so a message is not valid without a value for it
+	Required bool
+}
+
+// Codec converts between the payload of a message (without the wire format header) and column values.
+type Codec interface {
+	// Fields returns the top level fields of the schema.
+	Fields() []Field
+	// Decode returns the values of the fields, in the same order as Fields.
+	Decode(payload []byte) ([]any, error)
+	// Encode serializes values given in the same order as Fields. A nil value is encoded as null, or the zero value if
+	// the field is not nullable.
+	Encode(values []any) ([]byte, error)
+}
+
+// NewCodec creates a codec for the schema. For Protobuf schemas, messageName selects the message type used for
+// encoding and for the fields - if empty, the first message in the schema is used.
+func NewCodec(schema *Schema, messageName string) (Codec, error) {
+	switch schema.SchemaType {
+	case SchemaTypeAvro, "":
+		return newAvroCodec(schema.Schema)
+	case SchemaTypeProtobuf:
+		return newProtobufCodec(schema.Schema, messageName)
+	case SchemaTypeJSON:
+		return newJSONSchemaCodec(schema.Schema)
+	default:
+		return nil, errors.Errorf("unsupported schema type '%s'", schema.SchemaType)
+	}
+}
+
+func toJSONString(v any) (string, error) {
+	buff := &bytes.Buffer{}
+	enc := json.NewEncoder(buff)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	// Encode appends a newline
+	return string(bytes.TrimSuffix(buff.Bytes(), []byte{'\n'})), nil
+}
+
+func fromJSONString(s string) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON value")
+	}
+	return v, nil
+}
+
+// The following convert generic values, as found in JSON, to the Go type required by the serializer.
+
+func toInt64(v any) (int64, error) {
+	switch val := v.(type) {
+	case int64:
+		return val, nil
+	case int:
+		return int64(val), nil
+	case float64:
+		return int64(val), nil
+	case json.Number:
+		return val.Int64()
+	case types.Timestamp:
+		return val.Val, nil
+	default:
+		return 0, errors.Errorf("cannot convert %v (%T) to an integer", v, v)
+	}
+}
+
+func toFloat64(v any) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case int64:
+		return float64(val), nil
+	case int:
+		return float64(val), nil
+	case json.Number:
+		return val.Float64()
+	case types.Decimal:
+		return val.ToFloat64(), nil
+	default:
+		return 0, errors.Errorf("cannot convert %v (%T) to a float", v, v)
+	}
+}
+
+func toBool(v any) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.Errorf("cannot convert %v (%T) to a bool", v, v)
+	}
+	return b, nil
+}
+
+func toString(v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.Errorf("cannot convert %v (%T) to a string", v, v)
+	}
+	return s, nil
+}
+
+func toBytes(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case []byte:
+		return val, nil
+	case string:
+		return []byte(val), nil
+	default:
+		return nil, errors.Errorf("cannot convert %v (%T) to bytes", v, v)
+	}
+}
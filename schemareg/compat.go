@@ -0,0 +1,278 @@
+package schemareg
+
+import (
+	"encoding/json"
+	"github.com/hamba/avro/v2"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/types"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+)
+
+// CompatibilityLevel determines which schemas can be registered as a new version of a subject. The values are those
+// used by the Confluent schema registry.
+type CompatibilityLevel string
+
+const (
+	CompatibilityNone               CompatibilityLevel = "NONE"
+	CompatibilityBackward           CompatibilityLevel = "BACKWARD"
+	CompatibilityBackwardTransitive CompatibilityLevel = "BACKWARD_TRANSITIVE"
+	CompatibilityForward            CompatibilityLevel = "FORWARD"
+	CompatibilityForwardTransitive  CompatibilityLevel = "FORWARD_TRANSITIVE"
+	CompatibilityFull               CompatibilityLevel = "FULL"
+	CompatibilityFullTransitive     CompatibilityLevel = "FULL_TRANSITIVE"
+)
+
+func ParseCompatibilityLevel(s string) (CompatibilityLevel, bool) {
+	level := CompatibilityLevel(strings.ToUpper(s))
+	switch level {
+	case CompatibilityNone, CompatibilityBackward, CompatibilityBackwardTransitive, CompatibilityForward,
+		CompatibilityForwardTransitive, CompatibilityFull, CompatibilityFullTransitive:
+		return level, true
+	default:
+		return "", false
+	}
+}
+
+// ValidateSchema checks that a schema can be parsed.
+func ValidateSchema(schemaType SchemaType, schema string) error {
+	schemaType, err := normaliseSchemaType(schemaType)
+	if err != nil {
+		return err
+	}
+	switch schemaType {
+	case SchemaTypeAvro:
+		_, err = parseAvroSchema(schema)
+	case SchemaTypeProtobuf:
+		_, err = compileProtoSchema(schema)
+	case SchemaTypeJSON:
+		_, err = parseJSONSchemaObject(schema)
+	}
+	return err
+}
+
+// CheckCompatibility checks that data written with newSchema and data written with the previous versions of a subject
+// can be read as required by the compatibility level. previous must be ordered by version, oldest first. An error
+// describing the first incompatibility found is returned.
+func CheckCompatibility(level CompatibilityLevel, newSchema *Schema, previous []*Schema) error {
+	if level == CompatibilityNone || len(previous) == 0 {
+		return nil
+	}
+	toCheck := previous
+	switch level {
+	case CompatibilityBackward, CompatibilityForward, CompatibilityFull:
+		toCheck = previous[len(previous)-1:]
+	}
+	for i := len(toCheck) - 1; i >= 0; i-- {
+		prev := toCheck[i]
+		if normalisedType(prev.SchemaType) != normalisedType(newSchema.SchemaType) {
+			return errors.Errorf("schema type %s is different to the type %s of version %d", normalisedType(newSchema.SchemaType),
+				normalisedType(prev.SchemaType), prev.Version)
+		}
+		switch level {
+		case CompatibilityBackward, CompatibilityBackwardTransitive:
+			if err := canRead(newSchema, prev); err != nil {
+				return errors.Wrapf(err, "cannot read data written with version %d", prev.Version)
+			}
+		case CompatibilityForward, CompatibilityForwardTransitive:
+			if err := canRead(prev, newSchema); err != nil {
+				return errors.Wrapf(err, "version %d cannot read data written with the new schema", prev.Version)
+			}
+		default:
+			if err := canRead(newSchema, prev); err != nil {
+				return errors.Wrapf(err, "cannot read data written with version %d", prev.Version)
+			}
+			if err := canRead(prev, newSchema); err != nil {
+				return errors.Wrapf(err, "version %d cannot read data written with the new schema", prev.Version)
+			}
+		}
+	}
+	return nil
+}
+
+func normalisedType(schemaType SchemaType) SchemaType {
+	if schemaType == "" {
+		return SchemaTypeAvro
+	}
+	return schemaType
+}
+
+// canRead checks whether data written with the writer schema can be read with the reader schema.
+func canRead(reader *Schema, writer *Schema) error {
+	switch normalisedType(reader.SchemaType) {
+	case SchemaTypeAvro:
+		r, err := parseAvroSchema(reader.Schema)
+		if err != nil {
+			return err
+		}
+		w, err := parseAvroSchema(writer.Schema)
+		if err != nil {
+			return err
+		}
+		return avro.NewSchemaCompatibility().Compatible(r, w)
+	case SchemaTypeProtobuf:
+		r, err := compileProtoSchema(reader.Schema)
+		if err != nil {
+			return err
+		}
+		w, err := compileProtoSchema(writer.Schema)
+		if err != nil {
+			return err
+		}
+		return protoCanRead(r.Messages(), w.Messages())
+	case SchemaTypeJSON:
+		r, err := parseJSONSchemaObject(reader.Schema)
+		if err != nil {
+			return err
+		}
+		w, err := parseJSONSchemaObject(writer.Schema)
+		if err != nil {
+			return err
+		}
+		return jsonSchemaCanRead(r, w)
+	default:
+		return errors.Errorf("unsupported schema type '%s'", reader.SchemaType)
+	}
+}
+
+// protoCanRead checks that every message in the writer is still in the reader, and that fields with the same number
+// have wire compatible types. Fields that have been added or removed are compatible as all fields are optional on the
+// wire.
+func protoCanRead(reader protoreflect.MessageDescriptors, writer protoreflect.MessageDescriptors) error {
+	for i := 0; i < writer.Len(); i++ {
+		wm := writer.Get(i)
+		rm := reader.ByName(wm.Name())
+		if rm == nil {
+			return errors.Errorf("message '%s' has been removed", wm.FullName())
+		}
+		for j := 0; j < wm.Fields().Len(); j++ {
+			wf := wm.Fields().Get(j)
+			rf := rm.Fields().ByNumber(wf.Number())
+			if rf == nil {
+				continue
+			}
+			rRepeated := rf.Cardinality() == protoreflect.Repeated
+			wRepeated := wf.Cardinality() == protoreflect.Repeated
+			if rRepeated != wRepeated || rf.IsMap() != wf.IsMap() {
+				return errors.Errorf("field %d of message '%s' has changed between repeated and singular", wf.Number(),
+					wm.FullName())
+			}
+			if !protoKindsCompatible(rf, wf) {
+				return errors.Errorf("field %d of message '%s' has changed type from %s to %s", wf.Number(),
+					wm.FullName(), protoKindName(wf), protoKindName(rf))
+			}
+		}
+		if err := protoCanRead(rm.Messages(), wm.Messages()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func protoKindsCompatible(reader protoreflect.FieldDescriptor, writer protoreflect.FieldDescriptor) bool {
+	rk, wk := reader.Kind(), writer.Kind()
+	if rk == wk {
+		switch rk {
+		case protoreflect.MessageKind, protoreflect.GroupKind:
+			return reader.Message().Name() == writer.Message().Name()
+		case protoreflect.EnumKind:
+			return reader.Enum().Name() == writer.Enum().Name()
+		}
+		return true
+	}
+	group := func(k protoreflect.Kind) int {
+		switch k {
+		case protoreflect.Int32Kind, protoreflect.Uint32Kind, protoreflect.Int64Kind, protoreflect.Uint64Kind,
+			protoreflect.BoolKind, protoreflect.EnumKind:
+			return 1
+		case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+			return 2
+		case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
+			return 3
+		case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+			return 4
+		case protoreflect.StringKind, protoreflect.BytesKind:
+			return 5
+		default:
+			return 0
+		}
+	}
+	return group(rk) != 0 && group(rk) == group(wk)
+}
+
+func protoKindName(fd protoreflect.FieldDescriptor) string {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return string(fd.Message().FullName())
+	case protoreflect.EnumKind:
+		return string(fd.Enum().FullName())
+	default:
+		return fd.Kind().String()
+	}
+}
+
+type jsonSchemaObject struct {
+	properties map[string]*jsonSchemaProperty
+	required   map[string]struct{}
+	// closed is true if additionalProperties is false
+	closed bool
+}
+
+func parseJSONSchemaObject(schema string) (*jsonSchemaObject, error) {
+	var root struct {
+		Type                 any                            `json:"type"`
+		Properties           map[string]*jsonSchemaProperty `json:"properties"`
+		Required             []string                       `json:"required"`
+		AdditionalProperties any                            `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON schema")
+	}
+	if t, _ := root.Type.(string); t != "object" || root.Properties == nil {
+		return nil, errors.New("JSON schema must be an object with properties")
+	}
+	obj := &jsonSchemaObject{properties: root.Properties, required: map[string]struct{}{}}
+	for _, name := range root.Required {
+		obj.required[name] = struct{}{}
+	}
+	if b, ok := root.AdditionalProperties.(bool); ok && !b {
+		obj.closed = true
+	}
+	return obj, nil
+}
+
+func jsonSchemaCanRead(reader *jsonSchemaObject, writer *jsonSchemaObject) error {
+	for name := range reader.required {
+		if _, ok := writer.required[name]; !ok {
+			return errors.Errorf("property '%s' is required by the reader but not the writer", name)
+		}
+	}
+	for name, rp := range reader.properties {
+		wp, ok := writer.properties[name]
+		if !ok {
+			continue
+		}
+		rt, rNested := jsonSchemaColumnType(rp)
+		wt, wNested := jsonSchemaColumnType(wp)
+		if rNested || wNested {
+			if rNested != wNested {
+				return errors.Errorf("property '%s' has changed type", name)
+			}
+			continue
+		}
+		if rt.ID() != wt.ID() {
+			// A number can hold any integer
+			if !(rt.ID() == types.ColumnTypeIDFloat && wt.ID() == types.ColumnTypeIDInt) {
+				return errors.Errorf("property '%s' has changed type from %s to %s", name, wt, rt)
+			}
+		}
+	}
+	if reader.closed {
+		for name := range writer.properties {
+			if _, ok := reader.properties[name]; !ok {
+				return errors.Errorf("property '%s' is not allowed by the reader", name)
+			}
+		}
+	}
+	return nil
+}
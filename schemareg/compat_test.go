@@ -0,0 +1,121 @@
+package schemareg
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func avroVersion(version int, schema string) *Schema {
+	return &Schema{Version: version, Schema: schema}
+}
+
+const (
+	avroV1           = `{"type":"record","name":"order","fields":[{"name":"id","type":"int"}]}`
+	avroAddedDefault = `{"type":"record","name":"order","fields":[{"name":"id","type":"int"},{"name":"note","type":"string","default":""}]}`
+	avroAddedNoDef   = `{"type":"record","name":"order","fields":[{"name":"id","type":"int"},{"name":"note","type":"string"}]}`
+	avroPromoted     = `{"type":"record","name":"order","fields":[{"name":"id","type":"long"}]}`
+	avroRemovedID    = `{"type":"record","name":"order","fields":[]}`
+)
+
+func TestParseCompatibilityLevel(t *testing.T) {
+	level, ok := ParseCompatibilityLevel("backward_transitive")
+	require.True(t, ok)
+	require.Equal(t, CompatibilityBackwardTransitive, level)
+	level, ok = ParseCompatibilityLevel("FULL")
+	require.True(t, ok)
+	require.Equal(t, CompatibilityFull, level)
+	_, ok = ParseCompatibilityLevel("sideways")
+	require.False(t, ok)
+}
+
+func TestValidateSchema(t *testing.T) {
+	require.NoError(t, ValidateSchema(SchemaTypeAvro, avroV1))
+	require.NoError(t, ValidateSchema(SchemaTypeProtobuf, testProtoSchema))
+	require.NoError(t, ValidateSchema(SchemaTypeJSON, testJSONSchema))
+	require.Error(t, ValidateSchema(SchemaTypeAvro, `{"type":"record"`))
+	require.Error(t, ValidateSchema(SchemaTypeProtobuf, `syntax = "proto3";`))
+	require.Error(t, ValidateSchema("XML", "<a/>"))
+}
+
+func TestAvroCompatibility(t *testing.T) {
+	prev := []*Schema{avroVersion(1, avroV1)}
+
+	// A field added with a default can be read from old data, and old readers ignore it
+	require.NoError(t, CheckCompatibility(CompatibilityFull, avroVersion(2, avroAddedDefault), prev))
+	// Without a default, old data cannot be read
+	err := CheckCompatibility(CompatibilityBackward, avroVersion(2, avroAddedNoDef), prev)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot read data written with version 1")
+	require.NoError(t, CheckCompatibility(CompatibilityForward, avroVersion(2, avroAddedNoDef), prev))
+	// int can be promoted to long, but not back again
+	require.NoError(t, CheckCompatibility(CompatibilityBackward, avroVersion(2, avroPromoted), prev))
+	err = CheckCompatibility(CompatibilityForward, avroVersion(2, avroPromoted), prev)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "version 1 cannot read data written with the new schema")
+	// Removing a field without a default breaks old readers
+	require.NoError(t, CheckCompatibility(CompatibilityBackward, avroVersion(2, avroRemovedID), prev))
+	require.Error(t, CheckCompatibility(CompatibilityForward, avroVersion(2, avroRemovedID), prev))
+	// Anything goes with NONE
+	require.NoError(t, CheckCompatibility(CompatibilityNone, avroVersion(2, avroAddedNoDef), prev))
+}
+
+func TestTransitiveCompatibility(t *testing.T) {
+	prev := []*Schema{avroVersion(1, avroV1), avroVersion(2, avroRemovedID)}
+	// The new schema can read version 2, but not version 1 as the id field has no default
+	newSchema := avroVersion(3, `{"type":"record","name":"order","fields":[{"name":"id","type":"string","default":""}]}`)
+	require.NoError(t, CheckCompatibility(CompatibilityBackward, newSchema, prev))
+	err := CheckCompatibility(CompatibilityBackwardTransitive, newSchema, prev)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot read data written with version 1")
+}
+
+func TestCompatibilitySchemaTypeChange(t *testing.T) {
+	err := CheckCompatibility(CompatibilityBackward, &Schema{SchemaType: SchemaTypeJSON, Schema: testJSONSchema},
+		[]*Schema{avroVersion(1, avroV1)})
+	require.Error(t, err)
+	require.Equal(t, "schema type JSON is different to the type AVRO of version 1", err.Error())
+}
+
+func TestProtobufCompatibility(t *testing.T) {
+	v1 := &Schema{Version: 1, SchemaType: SchemaTypeProtobuf, Schema: `syntax = "proto3";
+message Order {
+  int32 id = 1;
+  string customer = 2;
+}`}
+	added := &Schema{SchemaType: SchemaTypeProtobuf, Schema: `syntax = "proto3";
+message Order {
+  int64 id = 1;
+  string customer = 2;
+  repeated string tags = 3;
+}`}
+	require.NoError(t, CheckCompatibility(CompatibilityFull, added, []*Schema{v1}))
+	changedKind := &Schema{SchemaType: SchemaTypeProtobuf, Schema: `syntax = "proto3";
+message Order {
+  int32 id = 1;
+  double customer = 2;
+}`}
+	require.Error(t, CheckCompatibility(CompatibilityBackward, changedKind, []*Schema{v1}))
+	madeRepeated := &Schema{SchemaType: SchemaTypeProtobuf, Schema: `syntax = "proto3";
+message Order {
+  repeated int32 id = 1;
+  string customer = 2;
+}`}
+	require.Error(t, CheckCompatibility(CompatibilityBackward, madeRepeated, []*Schema{v1}))
+}
+
+func TestJSONSchemaCompatibility(t *testing.T) {
+	v1 := &Schema{Version: 1, SchemaType: SchemaTypeJSON,
+		Schema: `{"type":"object","properties":{"id":{"type":"integer"}},"required":["id"]}`}
+	addedOptional := &Schema{SchemaType: SchemaTypeJSON,
+		Schema: `{"type":"object","properties":{"id":{"type":"integer"},"note":{"type":"string"}},"required":["id"]}`}
+	require.NoError(t, CheckCompatibility(CompatibilityBackward, addedOptional, []*Schema{v1}))
+	addedRequired := &Schema{SchemaType: SchemaTypeJSON,
+		Schema: `{"type":"object","properties":{"id":{"type":"integer"},"note":{"type":"string"}},"required":["id","note"]}`}
+	require.Error(t, CheckCompatibility(CompatibilityBackward, addedRequired, []*Schema{v1}))
+	changedType := &Schema{SchemaType: SchemaTypeJSON,
+		Schema: `{"type":"object","properties":{"id":{"type":"string"}},"required":["id"]}`}
+	require.Error(t, CheckCompatibility(CompatibilityBackward, changedType, []*Schema{v1}))
+	widened := &Schema{SchemaType: SchemaTypeJSON,
+		Schema: `{"type":"object","properties":{"id":{"type":"number"}},"required":["id"]}`}
+	require.NoError(t, CheckCompatibility(CompatibilityBackward, widened, []*Schema{v1}))
+}
@@ -0,0 +1,91 @@
+package schemareg
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+)
+
+// LoadFileRegistry loads a registry from a JSON file. This allows schemas to be resolved without a running schema
+// registry, e.g. for offline use or testing. The file has the form:
+//
+//	{"schemas": [{"id": 1, "subject": "orders-value", "version": 1, "schemaType": "AVRO", "schema": "..."}]}
+func LoadFileRegistry(path string) (*MemRegistry, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Schemas []Schema `json:"schemas"`
+	}
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return nil, errors.Wrapf(err, "invalid schema registry file %s", path)
+	}
+	return NewMemRegistry(file.Schemas)
+}
+
+// MemRegistry is an immutable in-memory registry.
+type MemRegistry struct {
+	byID      map[int]*Schema
+	bySubject map[string]map[int]*Schema
+	latest    map[string]*Schema
+}
+
+func NewMemRegistry(schemas []Schema) (*MemRegistry, error) {
+	m := &MemRegistry{
+		byID:      map[int]*Schema{},
+		bySubject: map[string]map[int]*Schema{},
+		latest:    map[string]*Schema{},
+	}
+	for i := range schemas {
+		schema := schemas[i]
+		var err error
+		schema.SchemaType, err = normaliseSchemaType(schema.SchemaType)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := m.byID[schema.ID]; exists {
+			return nil, errors.Errorf("duplicate schema id %d", schema.ID)
+		}
+		m.byID[schema.ID] = &schema
+		if schema.Subject == "" {
+			continue
+		}
+		versions, ok := m.bySubject[schema.Subject]
+		if !ok {
+			versions = map[int]*Schema{}
+			m.bySubject[schema.Subject] = versions
+		}
+		if _, exists := versions[schema.Version]; exists {
+			return nil, errors.Errorf("duplicate version %d for subject '%s'", schema.Version, schema.Subject)
+		}
+		versions[schema.Version] = &schema
+		if latest, ok := m.latest[schema.Subject]; !ok || schema.Version > latest.Version {
+			m.latest[schema.Subject] = &schema
+		}
+	}
+	return m, nil
+}
+
+func (m *MemRegistry) GetSchemaByID(id int) (*Schema, error) {
+	schema, ok := m.byID[id]
+	if !ok {
+		return nil, schemaNotFoundError("schema with id %d not found", id)
+	}
+	return schema, nil
+}
+
+func (m *MemRegistry) GetSchema(subject string, version int) (*Schema, error) {
+	if version == LatestVersion {
+		schema, ok := m.latest[subject]
+		if !ok {
+			return nil, schemaNotFoundError("schema for subject '%s' version latest not found", subject)
+		}
+		return schema, nil
+	}
+	schema, ok := m.bySubject[subject][version]
+	if !ok {
+		return nil, schemaNotFoundError("schema for subject '%s' version %d not found", subject, version)
+	}
+	return schema, nil
+}
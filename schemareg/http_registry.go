@@ -0,0 +1,100 @@
+package schemareg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/common"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const httpRegistryTimeout = 10 * time.Second
+
+// HTTPRegistry is a client for the REST API of a Confluent compatible schema registry. Basic auth credentials can be
+// provided as user info in the URL.
+type HTTPRegistry struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPRegistry(baseURL string) *HTTPRegistry {
+	return &HTTPRegistry{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: httpRegistryTimeout},
+	}
+}
+
+func (h *HTTPRegistry) GetSchemaByID(id int) (*Schema, error) {
+	schema := &Schema{}
+	found, err := h.get(fmt.Sprintf("/schemas/ids/%d", id), schema)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, schemaNotFoundError("schema with id %d not found", id)
+	}
+	// The response for a lookup by id does not include the id
+	schema.ID = id
+	schema.SchemaType, err = normaliseSchemaType(schema.SchemaType)
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func (h *HTTPRegistry) GetSchema(subject string, version int) (*Schema, error) {
+	sVersion := "latest"
+	if version != LatestVersion {
+		sVersion = fmt.Sprintf("%d", version)
+	}
+	schema := &Schema{}
+	found, err := h.get(fmt.Sprintf("/subjects/%s/versions/%s", url.PathEscape(subject), sVersion), schema)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, schemaNotFoundError("schema for subject '%s' version %s not found", subject, sVersion)
+	}
+	schema.SchemaType, err = normaliseSchemaType(schema.SchemaType)
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func (h *HTTPRegistry) get(path string, result any) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, h.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, common.NewTektiteErrorf(common.Unavailable, "failed to contact schema registry: %v", err)
+	}
+	defer func() {
+		//goland:noinspection GoUnhandledErrorResult
+		resp.Body.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, common.NewTektiteErrorf(common.Unavailable, "failed to read schema registry response: %v", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return false, common.NewTektiteErrorf(common.Unavailable, "schema registry returned status %d: %s",
+			resp.StatusCode, string(body))
+	case resp.StatusCode != http.StatusOK:
+		return false, errors.Errorf("schema registry returned status %d: %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return false, errors.Wrap(err, "invalid schema registry response")
+	}
+	return true, nil
+}
@@ -0,0 +1,221 @@
+package schemareg
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/types"
+	"time"
+)
+
+type jsonSchemaProperty struct {
+	Type   any    `json:"type"`
+	Format string `json:"format"`
+}
+
+func newJSONSchemaCodec(schema string) (*jsonSchemaCodec, error) {
+	var root struct {
+		Type       any             `json:"type"`
+		Properties json.RawMessage `json:"properties"`
+		Required   []string        `json:"required"`
+	}
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON schema")
+	}
+	if t, _ := root.Type.(string); t != "object" || root.Properties == nil {
+		return nil, errors.New("JSON schema must be an object with properties")
+	}
+	names, rawProps, err := orderedProperties(root.Properties)
+	if err != nil {
+		return nil, err
+	}
+	codec := &jsonSchemaCodec{required: map[string]struct{}{}}
+	for _, name := range root.Required {
+		codec.required[name] = struct{}{}
+	}
+	for i, name := range names {
+		var prop jsonSchemaProperty
+		if err := json.Unmarshal(rawProps[i], &prop); err != nil {
+			return nil, errors.Wrapf(err, "invalid JSON schema for property '%s'", name)
+		}
+		colType, nested := jsonSchemaColumnType(&prop)
+		_, required := codec.required[name]
+		codec.fields = append(codec.fields, Field{Name: name, Type: colType, Required: required})
+		codec.nested = append(codec.nested, nested)
+	}
+	return codec, nil
+}
+
+type jsonSchemaCodec struct {
+	fields []Field
+	// nested is true for fields held as JSON in string columns
+	nested   []bool
+	required map[string]struct{}
+}
+
+// orderedProperties returns the properties of a JSON schema in the order they are declared, so the order of the
+// fields is deterministic.
+func orderedProperties(raw json.RawMessage) ([]string, []json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, errors.New("JSON schema properties must be an object")
+	}
+	var names []string
+	var props []json.RawMessage
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid JSON schema")
+		}
+		var prop json.RawMessage
+		if err := dec.Decode(&prop); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid JSON schema")
+		}
+		names = append(names, tok.(string))
+		props = append(props, prop)
+	}
+	return names, props, nil
+}
+
+// jsonSchemaColumnType returns the column type for a property, and whether the column holds the value as JSON.
+func jsonSchemaColumnType(prop *jsonSchemaProperty) (types.ColumnType, bool) {
+	var propType string
+	switch t := prop.Type.(type) {
+	case string:
+		propType = t
+	case []any:
+		// e.g. ["string", "null"] - anything other than a single type and null is kept as JSON
+		for _, e := range t {
+			if s, _ := e.(string); s != "null" {
+				if propType != "" {
+					return types.ColumnTypeString, true
+				}
+				propType = s
+			}
+		}
+	}
+	switch propType {
+	case "integer":
+		return types.ColumnTypeInt, false
+	case "number":
+		return types.ColumnTypeFloat, false
+	case "boolean":
+		return types.ColumnTypeBool, false
+	case "string":
+		if prop.Format == "date-time" {
+			return types.ColumnTypeTimestamp, false
+		}
+		return types.ColumnTypeString, false
+	default:
+		return types.ColumnTypeString, true
+	}
+}
+
+func (j *jsonSchemaCodec) Fields() []Field {
+	return j.fields
+}
+
+func (j *jsonSchemaCodec) Decode(payload []byte) ([]any, error) {
+	v, err := fromJSONString(string(payload))
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("JSON message is not an object")
+	}
+	for name := range j.required {
+		if _, ok := obj[name]; !ok {
+			return nil, errors.Errorf("JSON message is missing required property '%s'", name)
+		}
+	}
+	values := make([]any, len(j.fields))
+	for i, f := range j.fields {
+		fv, ok := obj[f.Name]
+		if !ok || fv == nil {
+			continue
+		}
+		values[i], err = jsonToColumnValue(f.Type, fv)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for property '%s'", f.Name)
+		}
+	}
+	return values, nil
+}
+
+func jsonToColumnValue(colType types.ColumnType, v any) (any, error) {
+	switch colType.ID() {
+	case types.ColumnTypeIDInt:
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, errors.Errorf("expected an integer but found %v", v)
+		}
+		return n.Int64()
+	case types.ColumnTypeIDFloat:
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, errors.Errorf("expected a number but found %v", v)
+		}
+		return n.Float64()
+	case types.ColumnTypeIDBool:
+		return toBool(v)
+	case types.ColumnTypeIDTimestamp:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.Errorf("expected a date-time but found %v", v)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewTimestamp(t.UnixMilli()), nil
+	default:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return toJSONString(v)
+	}
+}
+
+func (j *jsonSchemaCodec) Encode(values []any) ([]byte, error) {
+	buff := &bytes.Buffer{}
+	buff.WriteByte('{')
+	first := true
+	for i, f := range j.fields {
+		v := values[i]
+		if v == nil {
+			if _, required := j.required[f.Name]; required {
+				return nil, errors.Errorf("value for required property '%s' is null", f.Name)
+			}
+			continue
+		}
+		switch val := v.(type) {
+		case types.Timestamp:
+			v = time.UnixMilli(val.Val).UTC().Format(time.RFC3339Nano)
+		case string:
+			if j.nested[i] {
+				if _, err := fromJSONString(val); err != nil {
+					return nil, errors.Wrapf(err, "invalid value for property '%s'", f.Name)
+				}
+				v = json.RawMessage(val)
+			}
+		}
+		if !first {
+			buff.WriteByte(',')
+		}
+		first = false
+		name, err := toJSONString(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		buff.WriteString(name)
+		buff.WriteByte(':')
+		sv, err := toJSONString(v)
+		if err != nil {
+			return nil, err
+		}
+		buff.WriteString(sv)
+	}
+	buff.WriteByte('}')
+	return buff.Bytes(), nil
+}
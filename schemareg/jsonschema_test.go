@@ -0,0 +1,75 @@
+package schemareg
+
+import (
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const testJSONSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "id": {"type": "integer"},
+    "customer": {"type": "string"},
+    "price": {"type": "number"},
+    "express": {"type": ["boolean", "null"]},
+    "placed_at": {"type": "string", "format": "date-time"},
+    "address": {"type": "object", "properties": {"city": {"type": "string"}}},
+    "tags": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["id"]
+}`
+
+func TestJSONSchemaFields(t *testing.T) {
+	codec, err := newJSONSchemaCodec(testJSONSchema)
+	require.NoError(t, err)
+	require.Equal(t, []Field{
+		{Name: "id", Type: types.ColumnTypeInt, Required: true},
+		{Name: "customer", Type: types.ColumnTypeString},
+		{Name: "price", Type: types.ColumnTypeFloat},
+		{Name: "express", Type: types.ColumnTypeBool},
+		{Name: "placed_at", Type: types.ColumnTypeTimestamp},
+		{Name: "address", Type: types.ColumnTypeString},
+		{Name: "tags", Type: types.ColumnTypeString},
+	}, codec.Fields())
+}
+
+func TestJSONSchemaDecode(t *testing.T) {
+	codec, err := newJSONSchemaCodec(testJSONSchema)
+	require.NoError(t, err)
+	decoded, err := codec.Decode([]byte(`{"id":23,"customer":"bob","price":12.5,"placed_at":"2023-11-14T22:13:20.123Z",
+		"address":{"city":"london"},"tags":["a"],"unknown":1}`))
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(23), "bob", 12.5, nil, types.NewTimestamp(1700000000123), `{"city":"london"}`, `["a"]`},
+		decoded)
+}
+
+func TestJSONSchemaDecodeInvalid(t *testing.T) {
+	codec, err := newJSONSchemaCodec(testJSONSchema)
+	require.NoError(t, err)
+	_, err = codec.Decode([]byte(`{"customer":"bob"}`))
+	require.Error(t, err)
+	require.Equal(t, "JSON message is missing required property 'id'", err.Error())
+
+	_, err = codec.Decode([]byte(`{"id":"23"}`))
+	require.Error(t, err)
+	require.Equal(t, "invalid value for property 'id': expected an integer but found 23", err.Error())
+}
+
+func TestJSONSchemaEncode(t *testing.T) {
+	codec, err := newJSONSchemaCodec(testJSONSchema)
+	require.NoError(t, err)
+	values := []any{int64(23), `{"not":"nested"}`, 12.5, nil, types.NewTimestamp(1700000000123), `{"city":"london"}`, `["a"]`}
+	encoded, err := codec.Encode(values)
+	require.NoError(t, err)
+	require.Equal(t, `{"id":23,"customer":"{\"not\":\"nested\"}","price":12.5,"placed_at":"2023-11-14T22:13:20.123Z","address":{"city":"london"},"tags":["a"]}`,
+		string(encoded))
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, values, decoded)
+
+	_, err = codec.Encode(make([]any, len(values)))
+	require.Error(t, err)
+	require.Equal(t, "value for required property 'id' is null", err.Error())
+}
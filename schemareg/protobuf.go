@@ -0,0 +1,428 @@
+package schemareg
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/protoutil"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/types"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"strconv"
+	"strings"
+
+	// Linked so that schemas can import the well known types
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const protoTimestampName = "google.protobuf.Timestamp"
+
+// protoSchemaFileName is the name the schema is compiled as - it appears in errors
+const protoSchemaFileName = "schema.proto"
+
+// compileProtoSchema compiles the text of a .proto file, as stored in the schema registry, into a file descriptor. The
+// schema can import the well known types, but not other files. The descriptor is rebuilt against the global registry,
+// so the well known types it refers to are the ones linked into the binary.
+func compileProtoSchema(schema string) (protoreflect.FileDescriptor, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(map[string]string{protoSchemaFileName: schema}),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), protoSchemaFileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid protobuf schema")
+	}
+	file, err := protodesc.NewFile(protoutil.ProtoFromFileDescriptor(files[0]), protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid protobuf schema")
+	}
+	if file.Messages().Len() == 0 {
+		return nil, errors.New("protobuf schema does not define any messages")
+	}
+	return file, nil
+}
+
+func newProtobufCodec(schema string, messageName string) (*protobufCodec, error) {
+	file, err := compileProtoSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	var md protoreflect.MessageDescriptor
+	if messageName == "" {
+		md = file.Messages().Get(0)
+	} else {
+		fullName := messageName
+		if file.Package() != "" && !strings.HasPrefix(messageName, string(file.Package())+".") {
+			fullName = string(file.Package()) + "." + messageName
+		}
+		md = findProtoMessage(file.Messages(), protoreflect.FullName(fullName))
+		if md == nil {
+			return nil, errors.Errorf("protobuf schema does not contain message '%s'", messageName)
+		}
+	}
+	fields := make([]Field, md.Fields().Len())
+	for i := range fields {
+		fd := md.Fields().Get(i)
+		fields[i] = Field{Name: string(fd.Name()), Type: protoColumnType(fd), Required: fd.Cardinality() == protoreflect.Required}
+	}
+	return &protobufCodec{
+		message: md,
+		indexes: protoMessageIndexes(md),
+		fields:  fields,
+	}, nil
+}
+
+type protobufCodec struct {
+	message protoreflect.MessageDescriptor
+	indexes []int
+	fields  []Field
+}
+
+func findProtoMessage(messages protoreflect.MessageDescriptors, name protoreflect.FullName) protoreflect.MessageDescriptor {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.FullName() == name {
+			return md
+		}
+		if nested := findProtoMessage(md.Messages(), name); nested != nil {
+			return nested
+		}
+	}
+	return nil
+}
+
+// protoMessageIndexes returns the path of indexes of the message within the file, as used in the wire format.
+func protoMessageIndexes(md protoreflect.MessageDescriptor) []int {
+	var indexes []int
+	var d protoreflect.Descriptor = md
+	for {
+		indexes = append([]int{d.Index()}, indexes...)
+		parent, ok := d.Parent().(protoreflect.MessageDescriptor)
+		if !ok {
+			return indexes
+		}
+		d = parent
+	}
+}
+
+func (p *protobufCodec) Fields() []Field {
+	return p.fields
+}
+
+func (p *protobufCodec) Decode(payload []byte) ([]any, error) {
+	indexes, payload, err := readMessageIndexes(payload)
+	if err != nil {
+		return nil, err
+	}
+	if !equalIndexes(indexes, p.indexes) {
+		return nil, errors.Errorf("message is not of type '%s'", p.message.FullName())
+	}
+	msg := dynamicpb.NewMessage(p.message)
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return nil, errors.Wrap(err, "failed to decode protobuf message")
+	}
+	values := make([]any, len(p.fields))
+	fds := p.message.Fields()
+	for i := range values {
+		fd := fds.Get(i)
+		if fd.HasPresence() && !msg.Has(fd) {
+			continue
+		}
+		v := msg.Get(fd)
+		switch {
+		case fd.IsList() || fd.IsMap() || (fd.Kind() == protoreflect.MessageKind && fd.Message().FullName() != protoTimestampName):
+			values[i], err = toJSONString(protoToGeneric(fd, v))
+			if err != nil {
+				return nil, err
+			}
+		default:
+			values[i] = protoScalarToGeneric(fd, v)
+			if ts, ok := values[i].(int64); ok && p.fields[i].Type.ID() == types.ColumnTypeIDTimestamp {
+				values[i] = types.NewTimestamp(ts)
+			}
+		}
+	}
+	return values, nil
+}
+
+func equalIndexes(i1 []int, i2 []int) bool {
+	if len(i1) != len(i2) {
+		return false
+	}
+	for i := range i1 {
+		if i1[i] != i2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *protobufCodec) Encode(values []any) ([]byte, error) {
+	msg := dynamicpb.NewMessage(p.message)
+	fds := p.message.Fields()
+	for i, v := range values {
+		v, err := columnValueToGeneric(p.fields[i].Type, v)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		fd := fds.Get(i)
+		if err := setProtoField(msg, fd, v); err != nil {
+			return nil, errors.Wrapf(err, "failed to encode protobuf field '%s'", fd.Name())
+		}
+	}
+	buff := appendMessageIndexes(nil, p.indexes)
+	return proto.MarshalOptions{}.MarshalAppend(buff, msg)
+}
+
+func protoColumnType(fd protoreflect.FieldDescriptor) types.ColumnType {
+	if fd.IsList() || fd.IsMap() {
+		return types.ColumnTypeString
+	}
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return types.ColumnTypeBool
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Int64Kind,
+		protoreflect.Sint64Kind, protoreflect.Sfixed64Kind, protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return types.ColumnTypeInt
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return types.ColumnTypeFloat
+	case protoreflect.BytesKind:
+		return types.ColumnTypeBytes
+	case protoreflect.MessageKind:
+		if fd.Message().FullName() == protoTimestampName {
+			return types.ColumnTypeTimestamp
+		}
+		return types.ColumnTypeString
+	default:
+		// string and enum
+		return types.ColumnTypeString
+	}
+}
+
+func protoToGeneric(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		arr := make([]any, list.Len())
+		for i := range arr {
+			arr[i] = protoSingularToGeneric(fd, list.Get(i))
+		}
+		return arr
+	case fd.IsMap():
+		m := map[string]any{}
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			m[k.String()] = protoSingularToGeneric(fd.MapValue(), mv)
+			return true
+		})
+		return m
+	default:
+		return protoSingularToGeneric(fd, v)
+	}
+}
+
+func protoSingularToGeneric(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	if fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind {
+		val := protoScalarToGeneric(fd, v)
+		if b, ok := val.([]byte); ok {
+			return string(b)
+		}
+		return val
+	}
+	msg := v.Message()
+	if fd.Message().FullName() == protoTimestampName {
+		return protoTimestampToMillis(msg)
+	}
+	m := map[string]any{}
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		m[string(fd.Name())] = protoToGeneric(fd, v)
+		return true
+	})
+	return m
+}
+
+func protoScalarToGeneric(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool()
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind, protoreflect.Int64Kind,
+		protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return v.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return int64(v.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		if b := v.Bytes(); b != nil {
+			return b
+		}
+		return []byte{}
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.MessageKind:
+		// Only timestamps are treated as scalars
+		return protoTimestampToMillis(v.Message())
+	default:
+		return nil
+	}
+}
+
+func protoTimestampToMillis(msg protoreflect.Message) int64 {
+	fds := msg.Descriptor().Fields()
+	seconds := msg.Get(fds.ByName("seconds")).Int()
+	nanos := msg.Get(fds.ByName("nanos")).Int()
+	return seconds*1000 + nanos/1000000
+}
+
+func setProtoField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, v any) error {
+	if s, ok := v.(jsonOrString); ok {
+		if fd.IsList() || fd.IsMap() || fd.Kind() == protoreflect.MessageKind {
+			nested, err := s.nested()
+			if err != nil {
+				return err
+			}
+			v = nested
+		} else {
+			v = string(s)
+		}
+	}
+	switch {
+	case fd.IsList():
+		arr, ok := v.([]any)
+		if !ok {
+			return errors.Errorf("cannot convert %v (%T) to a list", v, v)
+		}
+		list := msg.Mutable(fd).List()
+		for _, item := range arr {
+			pv, err := genericToProto(fd, item, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(pv)
+		}
+	case fd.IsMap():
+		m, ok := v.(map[string]any)
+		if !ok {
+			return errors.Errorf("cannot convert %v (%T) to a map", v, v)
+		}
+		pm := msg.Mutable(fd).Map()
+		for k, item := range m {
+			pk, err := genericToProto(fd.MapKey(), k, nil)
+			if err != nil {
+				return err
+			}
+			pv, err := genericToProto(fd.MapValue(), item, pm.NewValue)
+			if err != nil {
+				return err
+			}
+			pm.Set(pk.MapKey(), pv)
+		}
+	default:
+		pv, err := genericToProto(fd, v, func() protoreflect.Value { return msg.NewField(fd) })
+		if err != nil {
+			return err
+		}
+		msg.Set(fd, pv)
+	}
+	return nil
+}
+
+func genericToProto(fd protoreflect.FieldDescriptor, v any, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, err := toBool(v)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := protoIntKey(v)
+		return protoreflect.ValueOfInt32(int32(i)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := protoIntKey(v)
+		return protoreflect.ValueOfInt64(i), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		i, err := protoIntKey(v)
+		return protoreflect.ValueOfUint32(uint32(i)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		i, err := protoIntKey(v)
+		return protoreflect.ValueOfUint64(uint64(i)), err
+	case protoreflect.FloatKind:
+		f, err := toFloat64(v)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := toFloat64(v)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.StringKind:
+		s, err := toString(v)
+		return protoreflect.ValueOfString(s), err
+	case protoreflect.BytesKind:
+		b, err := toBytes(v)
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.EnumKind:
+		if s, ok := v.(string); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(s))
+			if ev == nil {
+				return protoreflect.Value{}, errors.Errorf("'%s' is not a value of enum '%s'", s, fd.Enum().FullName())
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		i, err := toInt64(v)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), err
+	case protoreflect.MessageKind:
+		pv := newMessage()
+		msg := pv.Message()
+		if fd.Message().FullName() == protoTimestampName {
+			millis, err := toInt64(v)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			fds := msg.Descriptor().Fields()
+			msg.Set(fds.ByName("seconds"), protoreflect.ValueOfInt64(millis/1000))
+			msg.Set(fds.ByName("nanos"), protoreflect.ValueOfInt32(int32(millis%1000)*1000000))
+			return pv, nil
+		}
+		m, ok := v.(map[string]any)
+		if !ok {
+			return protoreflect.Value{}, errors.Errorf("cannot convert %v (%T) to a message", v, v)
+		}
+		for name, fv := range m {
+			nfd := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+			if nfd == nil {
+				return protoreflect.Value{}, errors.Errorf("message '%s' has no field '%s'", fd.Message().FullName(), name)
+			}
+			if fv == nil {
+				continue
+			}
+			if err := setProtoField(msg, nfd, fv); err != nil {
+				return protoreflect.Value{}, err
+			}
+		}
+		return pv, nil
+	default:
+		return protoreflect.Value{}, errors.Errorf("unsupported protobuf field kind %s", fd.Kind())
+	}
+}
+
+// protoIntKey converts to an integer, also accepting strings as map keys are always strings in JSON.
+func protoIntKey(v any) (int64, error) {
+	if s, ok := v.(string); ok {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	if n, ok := v.(json.Number); ok {
+		return n.Int64()
+	}
+	return toInt64(v)
+}
@@ -0,0 +1,141 @@
+package schemareg
+
+import (
+	"github.com/spirit-labs/tektite/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const testProtoSchema = `
+syntax = "proto3";
+package com.example;
+
+import "google/protobuf/timestamp.proto";
+
+option java_package = "com.example.proto";
+
+/* An order */
+message Order {
+  int64 id = 1;
+  string customer = 2; // trailing comment
+  uint32 qty = 3;
+  double price = 4;
+  bool express = 5;
+  google.protobuf.Timestamp placed_at = 6;
+  Status status = 7;
+  Address address = 8;
+  repeated string tags = 9;
+  map<string, int64> attrs = 10;
+  bytes checksum = 11 [deprecated = true];
+  oneof payment {
+    string card = 12;
+    string voucher = 13;
+  }
+  reserved 20, 21;
+
+  enum Status {
+    NEW = 0;
+    SHIPPED = 1;
+  }
+  message Address {
+    string city = 1;
+    int32 zip = 2;
+  }
+}
+
+message Refund {
+  int64 order_id = 1;
+}
+
+service Orders {
+  rpc Get (Order) returns (Order) {}
+}
+`
+
+func TestProtobufFields(t *testing.T) {
+	codec, err := newProtobufCodec(testProtoSchema, "")
+	require.NoError(t, err)
+	require.Equal(t, []Field{
+		{Name: "id", Type: types.ColumnTypeInt},
+		{Name: "customer", Type: types.ColumnTypeString},
+		{Name: "qty", Type: types.ColumnTypeInt},
+		{Name: "price", Type: types.ColumnTypeFloat},
+		{Name: "express", Type: types.ColumnTypeBool},
+		{Name: "placed_at", Type: types.ColumnTypeTimestamp},
+		{Name: "status", Type: types.ColumnTypeString},
+		{Name: "address", Type: types.ColumnTypeString},
+		{Name: "tags", Type: types.ColumnTypeString},
+		{Name: "attrs", Type: types.ColumnTypeString},
+		{Name: "checksum", Type: types.ColumnTypeBytes},
+		{Name: "card", Type: types.ColumnTypeString},
+		{Name: "voucher", Type: types.ColumnTypeString},
+	}, codec.Fields())
+	require.Equal(t, []int{0}, codec.indexes)
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	codec, err := newProtobufCodec(testProtoSchema, "")
+	require.NoError(t, err)
+	values := []any{
+		int64(23), "bob", int64(3), 12.5, true, types.NewTimestamp(1700000000123), "SHIPPED",
+		`{"city":"london","zip":12}`, `["a","b"]`, `{"x":1}`, []byte{1, 2, 3}, nil, "v123",
+	}
+	encoded, err := codec.Encode(values)
+	require.NoError(t, err)
+	// Message indexes of [0] are encoded as a single zero byte
+	require.Equal(t, byte(0), encoded[0])
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, values, decoded)
+}
+
+func TestProtobufDecodeDefaults(t *testing.T) {
+	codec, err := newProtobufCodec(testProtoSchema, "")
+	require.NoError(t, err)
+	// Only id=150 is set
+	decoded, err := codec.Decode([]byte{0x00, 0x08, 0x96, 0x01})
+	require.NoError(t, err)
+	// Scalars without presence take their default value, messages and oneof fields are null
+	require.Equal(t, []any{
+		int64(150), "", int64(0), float64(0), false, nil, "NEW", nil, "[]", "{}", []byte{}, nil, nil,
+	}, decoded)
+}
+
+func TestProtobufMessageName(t *testing.T) {
+	codec, err := newProtobufCodec(testProtoSchema, "Refund")
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, codec.indexes)
+	encoded, err := codec.Encode([]any{int64(10)})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x02, 0x02, 0x08, 0x0a}, encoded)
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(10)}, decoded)
+
+	codec, err = newProtobufCodec(testProtoSchema, "com.example.Order.Address")
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1}, codec.indexes)
+
+	// The message indexes must match the message type of the codec
+	_, err = codec.Decode(encoded)
+	require.Error(t, err)
+	require.Equal(t, "message is not of type 'com.example.Order.Address'", err.Error())
+
+	_, err = newProtobufCodec(testProtoSchema, "Foo")
+	require.Error(t, err)
+	require.Equal(t, "protobuf schema does not contain message 'Foo'", err.Error())
+}
+
+func TestProtobufInvalidSchema(t *testing.T) {
+	_, err := newProtobufCodec(`syntax = "proto3"; message Foo { Bar bar = 1; }`, "")
+	require.Error(t, err)
+
+	_, err = newProtobufCodec(`syntax = "proto3"; message Foo { int64 id 1; }`, "")
+	require.Error(t, err)
+	require.Equal(t, "invalid protobuf schema: schema.proto:1:43: syntax error: unexpected int literal, expecting '='",
+		err.Error())
+
+	_, err = newProtobufCodec(`syntax = "proto3";`, "")
+	require.Error(t, err)
+	require.Equal(t, "protobuf schema does not define any messages", err.Error())
+}
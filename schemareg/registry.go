@@ -0,0 +1,158 @@
+package schemareg
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/asl/conf"
+	"github.com/spirit-labs/tektite/common"
+	"sync"
+	"time"
+)
+
+// SchemaType is the format of a schema. The values are those used by the Confluent schema registry.
+type SchemaType string
+
+const (
+	SchemaTypeAvro     SchemaType = "AVRO"
+	SchemaTypeProtobuf SchemaType = "PROTOBUF"
+	SchemaTypeJSON     SchemaType = "JSON"
+)
+
+// LatestVersion can be passed to Registry.GetSchema to get the most recently registered version of a subject.
+const LatestVersion = -1
+
+type Schema struct {
+	ID         int        `json:"id"`
+	Subject    string     `json:"subject,omitempty"`
+	Version    int        `json:"version,omitempty"`
+	SchemaType SchemaType `json:"schemaType,omitempty"`
+	Schema     string     `json:"schema"`
+}
+
+// Registry is a source of schemas, looked up either by the globally unique schema id which is embedded in messages
+// using the Confluent wire format, or by subject and version.
+type Registry interface {
+	GetSchemaByID(id int) (*Schema, error)
+	GetSchema(subject string, version int) (*Schema, error)
+}
+
+// NewRegistryFromConfig creates the registry described by the config, or returns nil if none is configured.
+func NewRegistryFromConfig(cfg *conf.Config) (Registry, error) {
+	var reg Registry
+	if cfg.SchemaRegistryURL != "" {
+		reg = NewHTTPRegistry(cfg.SchemaRegistryURL)
+	} else if cfg.SchemaRegistryFile != "" {
+		fileReg, err := LoadFileRegistry(cfg.SchemaRegistryFile)
+		if err != nil {
+			return nil, err
+		}
+		// No point caching a registry which is already in memory
+		return fileReg, nil
+	} else {
+		return nil, nil
+	}
+	return NewCachingRegistry(reg, cfg.SchemaRegistryCacheMaxAge), nil
+}
+
+func schemaNotFoundError(msg string, args ...any) error {
+	return common.NewTektiteErrorf(common.StatementError, msg, args...)
+}
+
+func normaliseSchemaType(schemaType SchemaType) (SchemaType, error) {
+	switch schemaType {
+	case "":
+		// The Confluent registry omits the type for Avro schemas
+		return SchemaTypeAvro, nil
+	case SchemaTypeAvro, SchemaTypeProtobuf, SchemaTypeJSON:
+		return schemaType, nil
+	default:
+		return "", errors.Errorf("unsupported schema type '%s'", schemaType)
+	}
+}
+
+// NewCachingRegistry wraps a registry so that lookups are only made once. Schemas are immutable once registered, so
+// lookups by id or explicit version are cached forever, whereas the latest version of a subject is cached for maxAge.
+func NewCachingRegistry(registry Registry, maxAge time.Duration) *CachingRegistry {
+	return &CachingRegistry{
+		registry: registry,
+		maxAge:   maxAge,
+		byID:     map[int]*Schema{},
+		byVer:    map[subjectVersion]*Schema{},
+		latest:   map[string]cachedLatest{},
+	}
+}
+
+type CachingRegistry struct {
+	lock     sync.RWMutex
+	registry Registry
+	maxAge   time.Duration
+	byID     map[int]*Schema
+	byVer    map[subjectVersion]*Schema
+	latest   map[string]cachedLatest
+}
+
+type subjectVersion struct {
+	subject string
+	version int
+}
+
+type cachedLatest struct {
+	schema    *Schema
+	fetchTime time.Time
+}
+
+func (c *CachingRegistry) GetSchemaByID(id int) (*Schema, error) {
+	c.lock.RLock()
+	schema, ok := c.byID[id]
+	c.lock.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	schema, err := c.registry.GetSchemaByID(id)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.byID[id] = schema
+	return schema, nil
+}
+
+func (c *CachingRegistry) GetSchema(subject string, version int) (*Schema, error) {
+	if version == LatestVersion {
+		return c.getLatest(subject)
+	}
+	key := subjectVersion{subject: subject, version: version}
+	c.lock.RLock()
+	schema, ok := c.byVer[key]
+	c.lock.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	schema, err := c.registry.GetSchema(subject, version)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.byVer[key] = schema
+	c.byID[schema.ID] = schema
+	return schema, nil
+}
+
+func (c *CachingRegistry) getLatest(subject string) (*Schema, error) {
+	c.lock.RLock()
+	cached, ok := c.latest[subject]
+	c.lock.RUnlock()
+	if ok && time.Since(cached.fetchTime) < c.maxAge {
+		return cached.schema, nil
+	}
+	schema, err := c.registry.GetSchema(subject, LatestVersion)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.latest[subject] = cachedLatest{schema: schema, fetchTime: time.Now()}
+	c.byID[schema.ID] = schema
+	return schema, nil
+}
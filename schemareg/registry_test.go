@@ -0,0 +1,171 @@
+package schemareg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testSchemas = []Schema{
+	{ID: 1, Subject: "orders-value", Version: 1, Schema: `{"type":"record","name":"order","fields":[{"name":"id","type":"long"}]}`},
+	{ID: 7, Subject: "orders-value", Version: 2, SchemaType: SchemaTypeAvro, Schema: `{"type":"record","name":"order","fields":[{"name":"id","type":"long"},{"name":"qty","type":"int"}]}`},
+	{ID: 3, Subject: "users-value", Version: 1, SchemaType: SchemaTypeJSON, Schema: `{"type":"object","properties":{"name":{"type":"string"}}}`},
+}
+
+func TestMemRegistry(t *testing.T) {
+	reg, err := NewMemRegistry(testSchemas)
+	require.NoError(t, err)
+	testRegistryLookups(t, reg)
+}
+
+func TestMemRegistryDuplicateID(t *testing.T) {
+	_, err := NewMemRegistry([]Schema{{ID: 1, Schema: "{}"}, {ID: 1, Schema: "{}"}})
+	require.Error(t, err)
+	require.Equal(t, "duplicate schema id 1", err.Error())
+}
+
+func TestFileRegistry(t *testing.T) {
+	bytes, err := json.Marshal(map[string]any{"schemas": testSchemas})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	require.NoError(t, os.WriteFile(path, bytes, 0644))
+	reg, err := LoadFileRegistry(path)
+	require.NoError(t, err)
+	testRegistryLookups(t, reg)
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	server := startTestRegistryServer(t, nil)
+	testRegistryLookups(t, NewHTTPRegistry(server.URL))
+}
+
+func TestHTTPRegistryUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	_, err := NewHTTPRegistry(server.URL).GetSchemaByID(1)
+	require.Error(t, err)
+	require.True(t, common.IsUnavailableError(err))
+}
+
+func TestCachingRegistry(t *testing.T) {
+	var requests int64
+	server := startTestRegistryServer(t, &requests)
+	reg := NewCachingRegistry(NewHTTPRegistry(server.URL), 100*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		_, err := reg.GetSchemaByID(1)
+		require.NoError(t, err)
+		_, err = reg.GetSchema("orders-value", 1)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, int(atomic.LoadInt64(&requests)))
+
+	// Looking up latest also caches the schema by id
+	schema, err := reg.GetSchema("orders-value", LatestVersion)
+	require.NoError(t, err)
+	require.Equal(t, 7, schema.ID)
+	_, err = reg.GetSchemaByID(7)
+	require.NoError(t, err)
+	_, err = reg.GetSchema("orders-value", LatestVersion)
+	require.NoError(t, err)
+	require.Equal(t, 3, int(atomic.LoadInt64(&requests)))
+
+	// Latest is refetched once it has expired
+	time.Sleep(150 * time.Millisecond)
+	_, err = reg.GetSchema("orders-value", LatestVersion)
+	require.NoError(t, err)
+	require.Equal(t, 4, int(atomic.LoadInt64(&requests)))
+}
+
+func testRegistryLookups(t *testing.T, reg Registry) {
+	schema, err := reg.GetSchemaByID(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, schema.ID)
+	// Avro is the default
+	require.Equal(t, SchemaTypeAvro, schema.SchemaType)
+	require.Equal(t, testSchemas[0].Schema, schema.Schema)
+
+	schema, err = reg.GetSchemaByID(3)
+	require.NoError(t, err)
+	require.Equal(t, SchemaTypeJSON, schema.SchemaType)
+
+	schema, err = reg.GetSchema("orders-value", 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, schema.ID)
+
+	schema, err = reg.GetSchema("orders-value", LatestVersion)
+	require.NoError(t, err)
+	require.Equal(t, 7, schema.ID)
+	require.Equal(t, 2, schema.Version)
+	require.Equal(t, "orders-value", schema.Subject)
+
+	_, err = reg.GetSchemaByID(100)
+	require.Error(t, err)
+	require.Equal(t, "schema with id 100 not found", err.Error())
+
+	_, err = reg.GetSchema("orders-value", 3)
+	require.Error(t, err)
+	require.Equal(t, "schema for subject 'orders-value' version 3 not found", err.Error())
+
+	_, err = reg.GetSchema("unknown", LatestVersion)
+	require.Error(t, err)
+	require.Equal(t, "schema for subject 'unknown' version latest not found", err.Error())
+}
+
+// startTestRegistryServer starts a server implementing the lookup endpoints of the Confluent schema registry API.
+func startTestRegistryServer(t *testing.T, requests *int64) *httptest.Server {
+	reg, err := NewMemRegistry(testSchemas)
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	writeSchema := func(w http.ResponseWriter, schema *Schema, err error) {
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+			return
+		}
+		resp := *schema
+		if resp.SchemaType == SchemaTypeAvro {
+			resp.SchemaType = ""
+		}
+		bytes, err := json.Marshal(&resp)
+		require.NoError(t, err)
+		_, _ = w.Write(bytes)
+	}
+	mux.HandleFunc("/schemas/ids/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			atomic.AddInt64(requests, 1)
+		}
+		var id int
+		_, _ = fmt.Sscanf(r.PathValue("id"), "%d", &id)
+		schema, err := reg.GetSchemaByID(id)
+		if schema != nil {
+			// The real registry doesn't return the id, subject or version
+			schema = &Schema{SchemaType: schema.SchemaType, Schema: schema.Schema}
+		}
+		writeSchema(w, schema, err)
+	})
+	mux.HandleFunc("/subjects/{subject}/versions/{version}", func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			atomic.AddInt64(requests, 1)
+		}
+		version := LatestVersion
+		if v := r.PathValue("version"); v != "latest" {
+			_, _ = fmt.Sscanf(v, "%d", &version)
+		}
+		schema, err := reg.GetSchema(r.PathValue("subject"), version)
+		writeSchema(w, schema, err)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
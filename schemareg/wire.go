@@ -0,0 +1,62 @@
+package schemareg
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+)
+
+// The Confluent wire format prefixes the serialized message with a zero magic byte and the 4 byte big-endian schema id.
+const (
+	magicByte        = 0
+	wireHeaderLength = 5
+)
+
+// ReadWireHeader returns the schema id of a message in the Confluent wire format, and the remaining payload.
+func ReadWireHeader(buff []byte) (int, []byte, error) {
+	if len(buff) < wireHeaderLength {
+		return 0, nil, errors.Errorf("message too short for schema registry wire format: %d bytes", len(buff))
+	}
+	if buff[0] != magicByte {
+		return 0, nil, errors.Errorf("unknown magic byte %d in schema registry wire format", buff[0])
+	}
+	return int(binary.BigEndian.Uint32(buff[1:])), buff[wireHeaderLength:], nil
+}
+
+func AppendWireHeader(buff []byte, schemaID int) []byte {
+	buff = append(buff, magicByte)
+	return binary.BigEndian.AppendUint32(buff, uint32(schemaID))
+}
+
+// readMessageIndexes reads the path to the message type in the .proto file that Protobuf payloads are prefixed with.
+// The path is a count followed by the indexes, all as zig-zag varints, with a count of zero as shorthand for [0].
+func readMessageIndexes(buff []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(buff)
+	if n <= 0 || count < 0 {
+		return nil, nil, errors.New("invalid protobuf message indexes")
+	}
+	buff = buff[n:]
+	if count == 0 {
+		return []int{0}, buff, nil
+	}
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, n := binary.Varint(buff)
+		if n <= 0 || index < 0 {
+			return nil, nil, errors.New("invalid protobuf message indexes")
+		}
+		indexes[i] = int(index)
+		buff = buff[n:]
+	}
+	return indexes, buff, nil
+}
+
+func appendMessageIndexes(buff []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(buff, 0)
+	}
+	buff = binary.AppendVarint(buff, int64(len(indexes)))
+	for _, index := range indexes {
+		buff = binary.AppendVarint(buff, int64(index))
+	}
+	return buff
+}
@@ -0,0 +1,38 @@
+package schemareg
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWireHeader(t *testing.T) {
+	buff := AppendWireHeader(nil, 257)
+	require.Equal(t, []byte{0, 0, 0, 1, 1}, buff)
+	buff = append(buff, 'x', 'y')
+	schemaID, payload, err := ReadWireHeader(buff)
+	require.NoError(t, err)
+	require.Equal(t, 257, schemaID)
+	require.Equal(t, []byte("xy"), payload)
+}
+
+func TestWireHeaderInvalid(t *testing.T) {
+	_, _, err := ReadWireHeader([]byte{0, 0, 1})
+	require.Error(t, err)
+	require.Equal(t, "message too short for schema registry wire format: 3 bytes", err.Error())
+
+	_, _, err = ReadWireHeader([]byte{1, 0, 0, 0, 1})
+	require.Error(t, err)
+	require.Equal(t, "unknown magic byte 1 in schema registry wire format", err.Error())
+}
+
+func TestMessageIndexes(t *testing.T) {
+	for _, indexes := range [][]int{{0}, {1}, {0, 2}, {3, 0, 1}} {
+		buff := appendMessageIndexes(nil, indexes)
+		buff = append(buff, 'x')
+		read, rem, err := readMessageIndexes(buff)
+		require.NoError(t, err)
+		require.Equal(t, indexes, read)
+		require.Equal(t, []byte("x"), rem)
+	}
+	require.Equal(t, []byte{0}, appendMessageIndexes(nil, []int{0}))
+}
@@ -0,0 +1,56 @@
+package schemaserver
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/schemareg"
+	"time"
+)
+
+type Conf struct {
+	// Enabled determines whether the agent hosts the schema registry
+	Enabled bool
+	// ListenAddress is the address the REST API listens on
+	ListenAddress string
+	// TopicName is the name of the compacted internal topic that holds the schemas
+	TopicName string
+	// DefaultCompatibility is the compatibility level for subjects which don't have one set, unless a global level has
+	// been set using the API
+	DefaultCompatibility schemareg.CompatibilityLevel
+	// RefreshInterval is the maximum age of the schemas held in memory by the agent before they are refreshed from the
+	// topic when serving a read
+	RefreshInterval time.Duration
+}
+
+func NewConf() Conf {
+	return Conf{
+		ListenAddress:        DefaultListenAddress,
+		TopicName:            DefaultTopicName,
+		DefaultCompatibility: schemareg.CompatibilityBackward,
+		RefreshInterval:      DefaultRefreshInterval,
+	}
+}
+
+const (
+	DefaultListenAddress   = "localhost:8081"
+	DefaultTopicName       = "__schemas"
+	DefaultRefreshInterval = 1 * time.Second
+)
+
+func (c *Conf) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ListenAddress == "" {
+		return errors.New("schema registry listen address must be specified")
+	}
+	if c.TopicName == "" {
+		return errors.New("schema registry topic name must be specified")
+	}
+	if _, ok := schemareg.ParseCompatibilityLevel(string(c.DefaultCompatibility)); !ok {
+		return errors.Errorf("invalid schema registry default compatibility: %s", c.DefaultCompatibility)
+	}
+	if c.RefreshInterval < 0 {
+		return errors.New("schema registry refresh interval must be >= 0")
+	}
+	return nil
+}
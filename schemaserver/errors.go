@@ -0,0 +1,41 @@
+package schemaserver
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// registryError is an error returned by the REST API, with the error code used by the Confluent schema registry.
+type registryError struct {
+	httpStatus int
+	errorCode  int
+	msg        string
+}
+
+func (e *registryError) Error() string {
+	return e.msg
+}
+
+func subjectNotFoundError(subject string) error {
+	return &registryError{httpStatus: http.StatusNotFound, errorCode: 40401,
+		msg: fmt.Sprintf("Subject '%s' not found.", subject)}
+}
+
+func versionNotFoundError(subject string, version int) error {
+	return &registryError{httpStatus: http.StatusNotFound, errorCode: 40402,
+		msg: fmt.Sprintf("Version %d not found for subject '%s'.", version, subject)}
+}
+
+func schemaNotFoundError() error {
+	return &registryError{httpStatus: http.StatusNotFound, errorCode: 40403, msg: "Schema not found"}
+}
+
+func invalidSchemaError(err error) error {
+	return &registryError{httpStatus: http.StatusUnprocessableEntity, errorCode: 42201,
+		msg: fmt.Sprintf("Invalid schema: %v", err)}
+}
+
+func storeError(err error) error {
+	return &registryError{httpStatus: http.StatusInternalServerError, errorCode: 50001,
+		msg: fmt.Sprintf("Error in the backend data store: %v", err)}
+}
@@ -0,0 +1,877 @@
+package schemaserver
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/spirit-labs/tektite/common"
+	log "github.com/spirit-labs/tektite/logger"
+	"github.com/spirit-labs/tektite/schemareg"
+	"github.com/spirit-labs/tektite/transport"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+Server is a schema registry hosted by the agent, which implements the parts of the REST API of the Confluent schema
+registry used by serializers and common tooling - subjects, versions, schemas by id, compatibility checks and config.
+The state of the registry lives in a compacted internal topic. Every agent holds the state in memory and serves reads
+from it, refreshing it from the topic when it is older than the refresh interval, or when a lookup misses.
+Writes need to see the latest state and are serialized, so they are all performed by a single agent - the write
+leader. The write leader is chosen by the controller in the same way as the coordinator for a consumer group, so if
+the write leader leaves the cluster a new one is chosen. An agent which is not the write leader forwards writes to it.
+*/
+type Server struct {
+	lock        sync.Mutex
+	writeLock   sync.Mutex
+	cfg         Conf
+	log         Log
+	leadership  Leadership
+	state       *registryState
+	nextOffset  int64
+	lastRefresh time.Time
+	httpServer  *http.Server
+	listener    net.Listener
+	closeWg     sync.WaitGroup
+}
+
+// Log is the log of records which holds the state of the registry.
+type Log interface {
+	// Append durably appends records to the log.
+	Append(records []Record) error
+	// Read returns records from the log starting at offset, and the offset to read from next. It returns no records
+	// when there are no more to read.
+	Read(offset int64) ([]Record, int64, error)
+}
+
+// Leadership determines whether this agent is the write leader, and forwards writes to the write leader if not. The
+// write leader is identified by its Kafka listen address.
+type Leadership interface {
+	IsWriteLeader() (bool, string, error)
+	ForwardWrite(leaderAddress string, request []byte) ([]byte, error)
+}
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+func NewServer(cfg Conf, log Log, leadership Leadership) *Server {
+	return &Server{
+		cfg:        cfg,
+		log:        log,
+		leadership: leadership,
+		state:      newRegistryState(),
+	}
+}
+
+func (s *Server) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleRoot)
+	mux.HandleFunc("GET /subjects", s.handleGetSubjects)
+	mux.HandleFunc("POST /subjects/{subject}", s.handleLookupSchema)
+	mux.HandleFunc("DELETE /subjects/{subject}", s.handleDeleteSubject)
+	mux.HandleFunc("GET /subjects/{subject}/versions", s.handleGetVersions)
+	mux.HandleFunc("POST /subjects/{subject}/versions", s.handleRegister)
+	mux.HandleFunc("GET /subjects/{subject}/versions/{version}", s.handleGetVersion)
+	mux.HandleFunc("GET /subjects/{subject}/versions/{version}/schema", s.handleGetVersionSchema)
+	mux.HandleFunc("DELETE /subjects/{subject}/versions/{version}", s.handleDeleteVersion)
+	mux.HandleFunc("GET /schemas/ids/{id}", s.handleGetSchemaByID)
+	mux.HandleFunc("GET /schemas/ids/{id}/versions", s.handleGetSchemaVersions)
+	mux.HandleFunc("GET /schemas/types", s.handleGetSchemaTypes)
+	mux.HandleFunc("POST /compatibility/subjects/{subject}/versions", s.handleCheckCompatibility)
+	mux.HandleFunc("POST /compatibility/subjects/{subject}/versions/{version}", s.handleCheckCompatibility)
+	mux.HandleFunc("GET /config", s.handleGetConfig)
+	mux.HandleFunc("PUT /config", s.handlePutConfig)
+	mux.HandleFunc("GET /config/{subject}", s.handleGetConfig)
+	mux.HandleFunc("PUT /config/{subject}", s.handlePutConfig)
+	mux.HandleFunc("DELETE /config/{subject}", s.handleDeleteConfig)
+	s.httpServer = &http.Server{
+		Handler: mux,
+	}
+	var err error
+	s.listener, err = common.Listen("tcp", s.cfg.ListenAddress)
+	if err != nil {
+		return err
+	}
+	s.closeWg = sync.WaitGroup{}
+	s.closeWg.Add(1)
+	httpServer, listener := s.httpServer, s.listener
+	common.Go(func() {
+		defer s.closeWg.Done()
+		err := httpServer.Serve(listener)
+		if !errwrap.Is(err, http.ErrServerClosed) {
+			log.Errorf("failed to start the schema registry server: %v", err)
+		}
+	})
+	return nil
+}
+
+func (s *Server) Stop() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.httpServer == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	s.closeWg.Wait()
+	s.httpServer = nil
+	return nil
+}
+
+// ListenAddress returns the address the server is listening on, which will differ from the configured address if that
+// uses an ephemeral port.
+func (s *Server) ListenAddress() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// refresh reads any records added to the log since it was last read, unless it was read less than the refresh interval
+// ago and force is false.
+func (s *Server) refresh(force bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !force && time.Since(s.lastRefresh) < s.cfg.RefreshInterval {
+		return nil
+	}
+	for {
+		records, nextOffset, err := s.log.Read(s.nextOffset)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			break
+		}
+		for _, rec := range records {
+			if err := s.state.apply(rec); err != nil {
+				return err
+			}
+		}
+		s.nextOffset = nextOffset
+	}
+	s.lastRefresh = time.Now()
+	return nil
+}
+
+// readState calls f with the current state. If f returns a not found error the state is refreshed and f is called
+// again, as the missing item may have been written very recently.
+func (s *Server) readState(f func(state *registryState) error) error {
+	if err := s.refresh(false); err != nil {
+		return storeError(err)
+	}
+	err := s.withState(f)
+	var regErr *registryError
+	if errwrap.As(err, &regErr) && regErr.httpStatus == http.StatusNotFound {
+		if err := s.refresh(true); err != nil {
+			return storeError(err)
+		}
+		return s.withState(f)
+	}
+	return err
+}
+
+func (s *Server) withState(f func(state *registryState) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return f(s.state)
+}
+
+func (s *Server) handleRoot(writer http.ResponseWriter, _ *http.Request) {
+	writeResponse(writer, struct{}{})
+}
+
+func (s *Server) handleGetSubjects(writer http.ResponseWriter, request *http.Request) {
+	includeDeleted := boolParam(request, "deleted")
+	subjects := []string{}
+	err := s.readState(func(state *registryState) error {
+		if includeDeleted {
+			for subject := range state.subjects {
+				subjects = append(subjects, subject)
+			}
+			sort.Strings(subjects)
+		} else {
+			subjects = append(subjects, state.liveSubjects()...)
+		}
+		return nil
+	})
+	s.respond(writer, subjects, err)
+}
+
+func (s *Server) handleGetVersions(writer http.ResponseWriter, request *http.Request) {
+	subject := request.PathValue("subject")
+	includeDeleted := boolParam(request, "deleted")
+	var versions []int
+	err := s.readState(func(state *registryState) error {
+		svs := state.subjects[subject]
+		if !includeDeleted {
+			svs = state.liveVersions(subject)
+		}
+		if len(svs) == 0 {
+			return subjectNotFoundError(subject)
+		}
+		for _, sv := range svs {
+			versions = append(versions, sv.Version)
+		}
+		return nil
+	})
+	s.respond(writer, versions, err)
+}
+
+func (s *Server) handleGetVersion(writer http.ResponseWriter, request *http.Request) {
+	sv, err := s.getVersion(request)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeResponse(writer, toSchema(sv))
+}
+
+func (s *Server) handleGetVersionSchema(writer http.ResponseWriter, request *http.Request) {
+	sv, err := s.getVersion(request)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	if _, err := writer.Write([]byte(sv.Schema)); err != nil {
+		log.Warnf("failed to write schema registry response: %v", err)
+	}
+}
+
+func (s *Server) getVersion(request *http.Request) (*schemaVersion, error) {
+	subject := request.PathValue("subject")
+	version, err := parseVersion(request.PathValue("version"))
+	if err != nil {
+		return nil, err
+	}
+	includeDeleted := boolParam(request, "deleted")
+	var sv *schemaVersion
+	err = s.readState(func(state *registryState) error {
+		var err error
+		sv, err = state.getVersion(subject, version, includeDeleted)
+		return err
+	})
+	return sv, err
+}
+
+func (s *Server) handleGetSchemaByID(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.Atoi(request.PathValue("id"))
+	if err != nil {
+		writeError(writer, schemaNotFoundError())
+		return
+	}
+	var schema schemareg.Schema
+	err = s.readState(func(state *registryState) error {
+		sv, ok := state.ids[id]
+		if !ok {
+			return schemaNotFoundError()
+		}
+		schema = schemareg.Schema{Schema: sv.Schema, SchemaType: responseSchemaType(sv.SchemaType)}
+		return nil
+	})
+	s.respond(writer, &schema, err)
+}
+
+type subjectVersion struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+func (s *Server) handleGetSchemaVersions(writer http.ResponseWriter, request *http.Request) {
+	id, err := strconv.Atoi(request.PathValue("id"))
+	if err != nil {
+		writeError(writer, schemaNotFoundError())
+		return
+	}
+	var versions []subjectVersion
+	err = s.readState(func(state *registryState) error {
+		if _, ok := state.ids[id]; !ok {
+			return schemaNotFoundError()
+		}
+		for _, subject := range state.liveSubjects() {
+			for _, sv := range state.liveVersions(subject) {
+				if sv.ID == id {
+					versions = append(versions, subjectVersion{Subject: sv.Subject, Version: sv.Version})
+				}
+			}
+		}
+		return nil
+	})
+	s.respond(writer, versions, err)
+}
+
+func (s *Server) handleGetSchemaTypes(writer http.ResponseWriter, _ *http.Request) {
+	writeResponse(writer, []schemareg.SchemaType{schemareg.SchemaTypeAvro, schemareg.SchemaTypeJSON,
+		schemareg.SchemaTypeProtobuf})
+}
+
+type schemaRequest struct {
+	Schema     string               `json:"schema"`
+	SchemaType schemareg.SchemaType `json:"schemaType"`
+}
+
+func (s *Server) handleLookupSchema(writer http.ResponseWriter, request *http.Request) {
+	subject := request.PathValue("subject")
+	req, err := readSchemaRequest(request)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	var schema *schemareg.Schema
+	err = s.readState(func(state *registryState) error {
+		versions := state.liveVersions(subject)
+		if len(versions) == 0 {
+			return subjectNotFoundError(subject)
+		}
+		fingerprint := schemaFingerprint(req.SchemaType, req.Schema)
+		for _, sv := range versions {
+			if schemaFingerprint(sv.SchemaType, sv.Schema) == fingerprint {
+				schema = toSchema(sv)
+				return nil
+			}
+		}
+		return schemaNotFoundError()
+	})
+	s.respond(writer, schema, err)
+}
+
+func (s *Server) handleRegister(writer http.ResponseWriter, request *http.Request) {
+	req, err := readSchemaRequest(request)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	res, err := s.write(&writeOp{
+		Op:         opRegister,
+		Subject:    request.PathValue("subject"),
+		Schema:     req.Schema,
+		SchemaType: req.SchemaType,
+	})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeResponse(writer, struct {
+		ID int `json:"id"`
+	}{ID: res.ID})
+}
+
+func (s *Server) handleDeleteSubject(writer http.ResponseWriter, request *http.Request) {
+	res, err := s.write(&writeOp{
+		Op:        opDeleteSubject,
+		Subject:   request.PathValue("subject"),
+		Permanent: boolParam(request, "permanent"),
+	})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeResponse(writer, res.Versions)
+}
+
+func (s *Server) handleDeleteVersion(writer http.ResponseWriter, request *http.Request) {
+	version, err := parseVersion(request.PathValue("version"))
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	res, err := s.write(&writeOp{
+		Op:        opDeleteVersion,
+		Subject:   request.PathValue("subject"),
+		Version:   version,
+		Permanent: boolParam(request, "permanent"),
+	})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeResponse(writer, res.Versions[0])
+}
+
+func (s *Server) handleCheckCompatibility(writer http.ResponseWriter, request *http.Request) {
+	subject := request.PathValue("subject")
+	version := 0
+	if versionStr := request.PathValue("version"); versionStr != "" {
+		var err error
+		version, err = parseVersion(versionStr)
+		if err != nil {
+			writeError(writer, err)
+			return
+		}
+	}
+	req, err := readSchemaRequest(request)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	if err := schemareg.ValidateSchema(req.SchemaType, req.Schema); err != nil {
+		writeError(writer, invalidSchemaError(err))
+		return
+	}
+	var compatErr error
+	err = s.readState(func(state *registryState) error {
+		var previous []*schemareg.Schema
+		level := state.compatibility(subject, s.cfg.DefaultCompatibility)
+		if version != 0 {
+			sv, err := state.getVersion(subject, version, false)
+			if err != nil {
+				return err
+			}
+			previous = []*schemareg.Schema{toSchema(sv)}
+			// Only check against the requested version
+			switch level {
+			case schemareg.CompatibilityBackwardTransitive:
+				level = schemareg.CompatibilityBackward
+			case schemareg.CompatibilityForwardTransitive:
+				level = schemareg.CompatibilityForward
+			case schemareg.CompatibilityFullTransitive:
+				level = schemareg.CompatibilityFull
+			}
+		} else {
+			for _, sv := range state.liveVersions(subject) {
+				previous = append(previous, toSchema(sv))
+			}
+		}
+		compatErr = schemareg.CheckCompatibility(level, &schemareg.Schema{Schema: req.Schema, SchemaType: req.SchemaType},
+			previous)
+		return nil
+	})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	resp := struct {
+		IsCompatible bool     `json:"is_compatible"`
+		Messages     []string `json:"messages,omitempty"`
+	}{IsCompatible: compatErr == nil}
+	if compatErr != nil && boolParam(request, "verbose") {
+		resp.Messages = []string{compatErr.Error()}
+	}
+	writeResponse(writer, &resp)
+}
+
+type configResponse struct {
+	CompatibilityLevel schemareg.CompatibilityLevel `json:"compatibilityLevel"`
+}
+
+func (s *Server) handleGetConfig(writer http.ResponseWriter, request *http.Request) {
+	subject := request.PathValue("subject")
+	var level schemareg.CompatibilityLevel
+	err := s.readState(func(state *registryState) error {
+		if subject != "" && !boolParam(request, "defaultToGlobal") {
+			var ok bool
+			level, ok = state.configs[subject]
+			if !ok {
+				return &registryError{httpStatus: http.StatusNotFound, errorCode: 40408,
+					msg: fmt.Sprintf("Subject '%s' does not have subject-level compatibility configured", subject)}
+			}
+			return nil
+		}
+		level = state.compatibility(subject, s.cfg.DefaultCompatibility)
+		return nil
+	})
+	s.respond(writer, &configResponse{CompatibilityLevel: level}, err)
+}
+
+func (s *Server) handlePutConfig(writer http.ResponseWriter, request *http.Request) {
+	var req struct {
+		Compatibility string `json:"compatibility"`
+	}
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		writeError(writer, &registryError{httpStatus: http.StatusBadRequest, errorCode: 400,
+			msg: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	level, ok := schemareg.ParseCompatibilityLevel(req.Compatibility)
+	if !ok {
+		writeError(writer, &registryError{httpStatus: http.StatusUnprocessableEntity, errorCode: 42203,
+			msg: fmt.Sprintf("Invalid compatibility level. Valid values are none, backward, forward, full, "+
+				"backward_transitive, forward_transitive, and full_transitive: %s", req.Compatibility)})
+		return
+	}
+	_, err := s.write(&writeOp{Op: opSetConfig, Subject: request.PathValue("subject"), Level: level})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeResponse(writer, struct {
+		Compatibility schemareg.CompatibilityLevel `json:"compatibility"`
+	}{Compatibility: level})
+}
+
+func (s *Server) handleDeleteConfig(writer http.ResponseWriter, request *http.Request) {
+	res, err := s.write(&writeOp{Op: opDeleteConfig, Subject: request.PathValue("subject")})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeResponse(writer, &configResponse{CompatibilityLevel: res.Level})
+}
+
+const (
+	opRegister      = "register"
+	opDeleteSubject = "delete-subject"
+	opDeleteVersion = "delete-version"
+	opSetConfig     = "set-config"
+	opDeleteConfig  = "delete-config"
+)
+
+// writeOp is a write to the registry. It is serialized as JSON when it is forwarded to the write leader.
+type writeOp struct {
+	Op         string                       `json:"op"`
+	Subject    string                       `json:"subject,omitempty"`
+	Version    int                          `json:"version,omitempty"`
+	Schema     string                       `json:"schema,omitempty"`
+	SchemaType schemareg.SchemaType         `json:"schemaType,omitempty"`
+	Permanent  bool                         `json:"permanent,omitempty"`
+	Level      schemareg.CompatibilityLevel `json:"level,omitempty"`
+}
+
+type writeResult struct {
+	ID         int                          `json:"id,omitempty"`
+	Versions   []int                        `json:"versions,omitempty"`
+	Level      schemareg.CompatibilityLevel `json:"level,omitempty"`
+	HTTPStatus int                          `json:"http_status,omitempty"`
+	ErrorCode  int                          `json:"error_code,omitempty"`
+	Message    string                       `json:"message,omitempty"`
+}
+
+// write performs the write if this agent is the write leader, otherwise it forwards it to the write leader.
+func (s *Server) write(op *writeOp) (*writeResult, error) {
+	isLeader, leaderAddress, err := s.leadership.IsWriteLeader()
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if isLeader {
+		return s.executeWrite(op)
+	}
+	buff, err := json.Marshal(op)
+	if err != nil {
+		return nil, err
+	}
+	request := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(buff)), 1) // rpc version - currently 1
+	request = append(request, buff...)
+	respBuff, err := s.leadership.ForwardWrite(leaderAddress, request)
+	if err != nil {
+		return nil, &registryError{httpStatus: http.StatusInternalServerError, errorCode: 50003,
+			msg: fmt.Sprintf("Error while forwarding the request to the leader: %v", err)}
+	}
+	var res writeResult
+	if err := json.Unmarshal(respBuff, &res); err != nil {
+		return nil, err
+	}
+	if res.ErrorCode != 0 {
+		return nil, &registryError{httpStatus: res.HTTPStatus, errorCode: res.ErrorCode, msg: res.Message}
+	}
+	return &res, nil
+}
+
+// HandleForwardedWrite handles a write forwarded from another agent.
+func (s *Server) HandleForwardedWrite(_ *transport.ConnectionContext, request []byte, responseBuff []byte,
+	responseWriter transport.ResponseWriter) error {
+	if rpcVersion := binary.BigEndian.Uint16(request); rpcVersion != 1 {
+		return responseWriter(nil, errors.New("invalid rpc version"))
+	}
+	var op writeOp
+	if err := json.Unmarshal(request[2:], &op); err != nil {
+		return responseWriter(nil, err)
+	}
+	isLeader, _, err := s.leadership.IsWriteLeader()
+	if err != nil {
+		return responseWriter(nil, err)
+	}
+	if !isLeader {
+		// Leadership has moved since the write was forwarded - the caller can retry
+		return responseWriter(nil, common.NewTektiteErrorf(common.Unavailable, "agent is not the schema registry write leader"))
+	}
+	res, err := s.executeWrite(&op)
+	if err != nil {
+		var regErr *registryError
+		if !errwrap.As(err, &regErr) {
+			return responseWriter(nil, err)
+		}
+		res = &writeResult{HTTPStatus: regErr.httpStatus, ErrorCode: regErr.errorCode, Message: regErr.msg}
+	}
+	buff, err := json.Marshal(res)
+	if err != nil {
+		return responseWriter(nil, err)
+	}
+	return responseWriter(append(responseBuff, buff...), nil)
+}
+
+func (s *Server) executeWrite(op *writeOp) (*writeResult, error) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	// We must see all previous writes, which may have been made by a previous write leader
+	if err := s.refresh(true); err != nil {
+		return nil, storeError(err)
+	}
+	var records []Record
+	var res *writeResult
+	err := s.withState(func(state *registryState) error {
+		var err error
+		switch op.Op {
+		case opRegister:
+			records, res, err = s.register(state, op)
+		case opDeleteSubject:
+			records, res, err = deleteSubject(state, op)
+		case opDeleteVersion:
+			records, res, err = deleteVersion(state, op)
+		case opSetConfig:
+			var rec Record
+			rec, err = configRecord(op.Subject, op.Level)
+			records, res = []Record{rec}, &writeResult{Level: op.Level}
+		case opDeleteConfig:
+			records, res, err = deleteConfig(state, op)
+		default:
+			err = errors.Errorf("unknown schema registry operation '%s'", op.Op)
+		}
+		return err
+	})
+	if err != nil || len(records) == 0 {
+		return res, err
+	}
+	if err := s.log.Append(records); err != nil {
+		return nil, storeError(err)
+	}
+	// Apply the records now, so the write is visible to reads on this agent straight away. They will be applied again
+	// when the log is next read, which makes no difference.
+	err = s.withState(func(state *registryState) error {
+		for _, rec := range records {
+			if err := state.apply(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (s *Server) register(state *registryState, op *writeOp) ([]Record, *writeResult, error) {
+	if err := schemareg.ValidateSchema(op.SchemaType, op.Schema); err != nil {
+		return nil, nil, invalidSchemaError(err)
+	}
+	fingerprint := schemaFingerprint(op.SchemaType, op.Schema)
+	live := state.liveVersions(op.Subject)
+	var previous []*schemareg.Schema
+	for _, sv := range live {
+		if schemaFingerprint(sv.SchemaType, sv.Schema) == fingerprint {
+			// Already registered
+			return nil, &writeResult{ID: sv.ID}, nil
+		}
+		previous = append(previous, toSchema(sv))
+	}
+	level := state.compatibility(op.Subject, s.cfg.DefaultCompatibility)
+	if err := schemareg.CheckCompatibility(level, &schemareg.Schema{Schema: op.Schema, SchemaType: op.SchemaType},
+		previous); err != nil {
+		return nil, nil, &registryError{httpStatus: http.StatusConflict, errorCode: 409,
+			msg: fmt.Sprintf("Schema being registered is incompatible with an earlier schema for subject '%s': %v",
+				op.Subject, err)}
+	}
+	id, ok := state.fingerprints[fingerprint]
+	if !ok {
+		id = state.maxID + 1
+	}
+	version := 1
+	if versions := state.subjects[op.Subject]; len(versions) > 0 {
+		version = versions[len(versions)-1].Version + 1
+	}
+	schemaType := op.SchemaType
+	if schemaType == schemareg.SchemaTypeAvro {
+		schemaType = ""
+	}
+	rec, err := schemaRecord(&schemaVersion{
+		Subject:    op.Subject,
+		Version:    version,
+		ID:         id,
+		SchemaType: schemaType,
+		Schema:     op.Schema,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return []Record{rec}, &writeResult{ID: id}, nil
+}
+
+func deleteSubject(state *registryState, op *writeOp) ([]Record, *writeResult, error) {
+	versions := state.subjects[op.Subject]
+	live := state.liveVersions(op.Subject)
+	var toDelete []*schemaVersion
+	if op.Permanent {
+		if len(versions) == 0 {
+			return nil, nil, subjectNotFoundError(op.Subject)
+		}
+		if len(live) > 0 {
+			return nil, nil, &registryError{httpStatus: http.StatusNotFound, errorCode: 40405,
+				msg: fmt.Sprintf("Subject '%s' was not deleted first before being permanently deleted", op.Subject)}
+		}
+		toDelete = versions
+	} else {
+		if len(live) == 0 {
+			return nil, nil, subjectNotFoundError(op.Subject)
+		}
+		toDelete = live
+	}
+	res := &writeResult{}
+	var records []Record
+	for _, sv := range toDelete {
+		rec, err := deletedRecord(sv, op.Permanent)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, rec)
+		res.Versions = append(res.Versions, sv.Version)
+	}
+	if _, ok := state.configs[op.Subject]; ok && op.Permanent {
+		rec, err := configRecord(op.Subject, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, res, nil
+}
+
+func deleteVersion(state *registryState, op *writeOp) ([]Record, *writeResult, error) {
+	sv, err := state.getVersion(op.Subject, op.Version, op.Permanent)
+	if err != nil {
+		return nil, nil, err
+	}
+	if op.Permanent && !sv.Deleted {
+		return nil, nil, &registryError{httpStatus: http.StatusNotFound, errorCode: 40407,
+			msg: fmt.Sprintf("Subject '%s' Version %d was not deleted first before being permanently deleted",
+				op.Subject, sv.Version)}
+	}
+	rec, err := deletedRecord(sv, op.Permanent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []Record{rec}, &writeResult{Versions: []int{sv.Version}}, nil
+}
+
+func deletedRecord(sv *schemaVersion, permanent bool) (Record, error) {
+	deleted := *sv
+	deleted.Deleted = true
+	deleted.Permanent = permanent
+	return schemaRecord(&deleted)
+}
+
+func deleteConfig(state *registryState, op *writeOp) ([]Record, *writeResult, error) {
+	level, ok := state.configs[op.Subject]
+	if !ok {
+		if op.Subject == "" {
+			// Deleting the global config when it isn't set leaves the default
+			return nil, &writeResult{Level: state.compatibility("", "")}, nil
+		}
+		return nil, nil, subjectNotFoundError(op.Subject)
+	}
+	rec, err := configRecord(op.Subject, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return []Record{rec}, &writeResult{Level: level}, nil
+}
+
+func readSchemaRequest(request *http.Request) (*schemaRequest, error) {
+	var req schemaRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		return nil, &registryError{httpStatus: http.StatusBadRequest, errorCode: 400,
+			msg: fmt.Sprintf("invalid request: %v", err)}
+	}
+	if req.SchemaType == "" {
+		req.SchemaType = schemareg.SchemaTypeAvro
+	}
+	return &req, nil
+}
+
+func toSchema(sv *schemaVersion) *schemareg.Schema {
+	return &schemareg.Schema{
+		ID:         sv.ID,
+		Subject:    sv.Subject,
+		Version:    sv.Version,
+		SchemaType: responseSchemaType(sv.SchemaType),
+		Schema:     sv.Schema,
+	}
+}
+
+// responseSchemaType returns the schema type as returned by the API, which omits the type for Avro.
+func responseSchemaType(schemaType schemareg.SchemaType) schemareg.SchemaType {
+	if schemaType == schemareg.SchemaTypeAvro {
+		return ""
+	}
+	return schemaType
+}
+
+func parseVersion(s string) (int, error) {
+	if s == "latest" {
+		return schemareg.LatestVersion, nil
+	}
+	version, err := strconv.Atoi(s)
+	if err != nil || version == 0 || version < schemareg.LatestVersion || version > math.MaxInt32 {
+		return 0, &registryError{httpStatus: http.StatusUnprocessableEntity, errorCode: 42202,
+			msg: fmt.Sprintf("The specified version '%s' is not a valid version id. Allowed values are between "+
+				"[1, 2^31-1] and the string \"latest\"", s)}
+	}
+	return version, nil
+}
+
+func boolParam(request *http.Request, name string) bool {
+	b, _ := strconv.ParseBool(request.URL.Query().Get(name))
+	return b
+}
+
+func (s *Server) respond(writer http.ResponseWriter, resp any, err error) {
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeResponse(writer, resp)
+}
+
+func writeResponse(writer http.ResponseWriter, resp any) {
+	buff, err := json.Marshal(resp)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	if _, err := writer.Write(buff); err != nil {
+		log.Warnf("failed to write schema registry response: %v", err)
+	}
+}
+
+func writeError(writer http.ResponseWriter, err error) {
+	var regErr *registryError
+	if !errwrap.As(err, &regErr) {
+		log.Errorf("schema registry request failed: %v", err)
+		regErr = &registryError{httpStatus: http.StatusInternalServerError, errorCode: 50001, msg: err.Error()}
+	}
+	buff, err := json.Marshal(struct {
+		ErrorCode int    `json:"error_code"`
+		Message   string `json:"message"`
+	}{ErrorCode: regErr.errorCode, Message: regErr.msg})
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.WriteHeader(regErr.httpStatus)
+	if _, err := writer.Write(buff); err != nil {
+		log.Warnf("failed to write schema registry response: %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package schemaserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/spirit-labs/tektite/schemareg"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+const (
+	schemaV1 = `{"type":"record","name":"order","fields":[{"name":"id","type":"int"}]}`
+	schemaV2 = `{"type":"record","name":"order","fields":[{"name":"id","type":"int"},{"name":"note","type":"string","default":""}]}`
+	// schemaV3 cannot read data written with schemaV1 or schemaV2 as note has no default
+	schemaV3 = `{"type":"record","name":"order","fields":[{"name":"id","type":"int"},{"name":"qty","type":"int"}]}`
+)
+
+type memLog struct {
+	lock    sync.Mutex
+	records []Record
+}
+
+func (m *memLog) Append(records []Record) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.records = append(m.records, records...)
+	return nil
+}
+
+func (m *memLog) Read(offset int64) ([]Record, int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if offset >= int64(len(m.records)) {
+		return nil, offset, nil
+	}
+	return append([]Record{}, m.records[offset:]...), int64(len(m.records)), nil
+}
+
+// testLeadership makes the first server the write leader, and forwards writes straight to it.
+type testLeadership struct {
+	isLeader bool
+	leader   *Server
+}
+
+func (t *testLeadership) IsWriteLeader() (bool, string, error) {
+	return t.isLeader, "leader", nil
+}
+
+func (t *testLeadership) ForwardWrite(_ string, request []byte) ([]byte, error) {
+	var resp []byte
+	var respErr error
+	err := t.leader.HandleForwardedWrite(nil, request, nil, func(buff []byte, err error) error {
+		resp, respErr = buff, err
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, respErr
+}
+
+func setupServers(t *testing.T) (*Server, *Server, *memLog) {
+	log := &memLog{}
+	cfg := NewConf()
+	cfg.Enabled = true
+	cfg.ListenAddress = "localhost:0"
+	leader := NewServer(cfg, log, &testLeadership{isLeader: true})
+	follower := NewServer(cfg, log, &testLeadership{leader: leader})
+	for _, server := range []*Server{leader, follower} {
+		require.NoError(t, server.Start())
+		s := server
+		t.Cleanup(func() {
+			require.NoError(t, s.Stop())
+		})
+	}
+	return leader, follower, log
+}
+
+func sendRequest(t *testing.T, server *Server, method string, path string, body any, result any) int {
+	var reqBody io.Reader
+	if body != nil {
+		buff, err := json.Marshal(body)
+		require.NoError(t, err)
+		reqBody = bytes.NewReader(buff)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s%s", server.ListenAddress(), path), reqBody)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	require.Equal(t, contentType, resp.Header.Get("Content-Type"))
+	if result != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(result))
+	}
+	return resp.StatusCode
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func register(t *testing.T, server *Server, subject string, schema string) int {
+	var resp struct {
+		ID int `json:"id"`
+	}
+	status := sendRequest(t, server, http.MethodPost, "/subjects/"+subject+"/versions",
+		map[string]string{"schema": schema}, &resp)
+	require.Equal(t, http.StatusOK, status)
+	return resp.ID
+}
+
+func TestRegisterAndGet(t *testing.T) {
+	for _, forward := range []bool{false, true} {
+		t.Run(fmt.Sprintf("forward=%t", forward), func(t *testing.T) {
+			leader, follower, _ := setupServers(t)
+			writer := leader
+			if forward {
+				writer = follower
+			}
+			id1 := register(t, writer, "orders-value", schemaV1)
+			id2 := register(t, writer, "orders-value", schemaV2)
+			require.Equal(t, 1, id1)
+			require.Equal(t, 2, id2)
+			// Registering the same schema again returns the existing id
+			require.Equal(t, id2, register(t, writer, "orders-value", schemaV2))
+			// And the same schema under another subject gets the same id
+			require.Equal(t, id1, register(t, writer, "other-value", schemaV1))
+
+			for _, server := range []*Server{leader, follower} {
+				reg := schemareg.NewHTTPRegistry("http://" + server.ListenAddress())
+				schema, err := reg.GetSchema("orders-value", schemareg.LatestVersion)
+				require.NoError(t, err)
+				require.Equal(t, &schemareg.Schema{ID: id2, Subject: "orders-value", Version: 2,
+					SchemaType: schemareg.SchemaTypeAvro, Schema: schemaV2}, schema)
+				schema, err = reg.GetSchemaByID(id1)
+				require.NoError(t, err)
+				require.Equal(t, schemaV1, schema.Schema)
+
+				var subjects []string
+				require.Equal(t, http.StatusOK, sendRequest(t, server, http.MethodGet, "/subjects", nil, &subjects))
+				require.Equal(t, []string{"orders-value", "other-value"}, subjects)
+				var versions []int
+				require.Equal(t, http.StatusOK, sendRequest(t, server, http.MethodGet, "/subjects/orders-value/versions",
+					nil, &versions))
+				require.Equal(t, []int{1, 2}, versions)
+			}
+		})
+	}
+}
+
+func TestRegisterIncompatible(t *testing.T) {
+	_, follower, _ := setupServers(t)
+	register(t, follower, "orders-value", schemaV1)
+	register(t, follower, "orders-value", schemaV2)
+	var errResp errorResponse
+	status := sendRequest(t, follower, http.MethodPost, "/subjects/orders-value/versions",
+		map[string]string{"schema": schemaV3}, &errResp)
+	require.Equal(t, http.StatusConflict, status)
+	require.Equal(t, 409, errResp.ErrorCode)
+
+	var compat struct {
+		IsCompatible bool `json:"is_compatible"`
+	}
+	require.Equal(t, http.StatusOK, sendRequest(t, follower, http.MethodPost,
+		"/compatibility/subjects/orders-value/versions/latest", map[string]string{"schema": schemaV3}, &compat))
+	require.False(t, compat.IsCompatible)
+
+	// Relax compatibility for the subject and try again
+	require.Equal(t, http.StatusOK, sendRequest(t, follower, http.MethodPut, "/config/orders-value",
+		map[string]string{"compatibility": "none"}, nil))
+	var config configResponse
+	require.Equal(t, http.StatusOK, sendRequest(t, follower, http.MethodGet, "/config/orders-value", nil, &config))
+	require.Equal(t, schemareg.CompatibilityNone, config.CompatibilityLevel)
+	require.Equal(t, http.StatusOK, sendRequest(t, follower, http.MethodGet, "/config", nil, &config))
+	require.Equal(t, schemareg.CompatibilityBackward, config.CompatibilityLevel)
+	require.Equal(t, 3, register(t, follower, "orders-value", schemaV3))
+}
+
+func TestRegisterInvalidSchema(t *testing.T) {
+	leader, _, _ := setupServers(t)
+	var errResp errorResponse
+	status := sendRequest(t, leader, http.MethodPost, "/subjects/orders-value/versions",
+		map[string]string{"schema": `{"type":"record"`}, &errResp)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Equal(t, 42201, errResp.ErrorCode)
+}
+
+func TestLookupSchema(t *testing.T) {
+	leader, _, _ := setupServers(t)
+	register(t, leader, "orders-value", schemaV1)
+	var schema schemareg.Schema
+	require.Equal(t, http.StatusOK, sendRequest(t, leader, http.MethodPost, "/subjects/orders-value",
+		map[string]string{"schema": schemaV1}, &schema))
+	require.Equal(t, schemareg.Schema{ID: 1, Subject: "orders-value", Version: 1, Schema: schemaV1}, schema)
+	var errResp errorResponse
+	require.Equal(t, http.StatusNotFound, sendRequest(t, leader, http.MethodPost, "/subjects/orders-value",
+		map[string]string{"schema": schemaV2}, &errResp))
+	require.Equal(t, 40403, errResp.ErrorCode)
+	require.Equal(t, http.StatusNotFound, sendRequest(t, leader, http.MethodPost, "/subjects/unknown",
+		map[string]string{"schema": schemaV1}, &errResp))
+	require.Equal(t, 40401, errResp.ErrorCode)
+}
+
+func TestDeleteSubject(t *testing.T) {
+	leader, follower, _ := setupServers(t)
+	register(t, follower, "orders-value", schemaV1)
+	register(t, follower, "orders-value", schemaV2)
+
+	// Permanent delete must follow a soft delete
+	var errResp errorResponse
+	require.Equal(t, http.StatusNotFound, sendRequest(t, follower, http.MethodDelete,
+		"/subjects/orders-value?permanent=true", nil, &errResp))
+	require.Equal(t, 40405, errResp.ErrorCode)
+
+	var deleted []int
+	require.Equal(t, http.StatusOK, sendRequest(t, follower, http.MethodDelete, "/subjects/orders-value", nil, &deleted))
+	require.Equal(t, []int{1, 2}, deleted)
+	require.Equal(t, http.StatusNotFound, sendRequest(t, leader, http.MethodGet, "/subjects/orders-value/versions",
+		nil, &errResp))
+	require.Equal(t, 40401, errResp.ErrorCode)
+	// Soft deleted schemas can still be looked up by id
+	reg := schemareg.NewHTTPRegistry("http://" + leader.ListenAddress())
+	_, err := reg.GetSchemaByID(1)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, sendRequest(t, follower, http.MethodDelete, "/subjects/orders-value?permanent=true",
+		nil, &deleted))
+	require.Equal(t, []int{1, 2}, deleted)
+	_, err = reg.GetSchemaByID(1)
+	require.Error(t, err)
+
+	// Ids are not reused after a permanent delete
+	require.Equal(t, 3, register(t, follower, "orders-value", schemaV3))
+}
+
+func TestDeleteVersion(t *testing.T) {
+	leader, _, _ := setupServers(t)
+	register(t, leader, "orders-value", schemaV1)
+	register(t, leader, "orders-value", schemaV2)
+	var deleted int
+	require.Equal(t, http.StatusOK, sendRequest(t, leader, http.MethodDelete, "/subjects/orders-value/versions/latest",
+		nil, &deleted))
+	require.Equal(t, 2, deleted)
+	var versions []int
+	require.Equal(t, http.StatusOK, sendRequest(t, leader, http.MethodGet, "/subjects/orders-value/versions", nil,
+		&versions))
+	require.Equal(t, []int{1}, versions)
+	require.Equal(t, http.StatusOK, sendRequest(t, leader, http.MethodGet, "/subjects/orders-value/versions?deleted=true",
+		nil, &versions))
+	require.Equal(t, []int{1, 2}, versions)
+	// Versions keep increasing after a delete
+	require.Equal(t, 2, register(t, leader, "orders-value", schemaV2))
+	var schema schemareg.Schema
+	require.Equal(t, http.StatusOK, sendRequest(t, leader, http.MethodGet, "/subjects/orders-value/versions/latest",
+		nil, &schema))
+	require.Equal(t, 3, schema.Version)
+
+	var errResp errorResponse
+	require.Equal(t, http.StatusUnprocessableEntity, sendRequest(t, leader, http.MethodGet,
+		"/subjects/orders-value/versions/foo", nil, &errResp))
+	require.Equal(t, 42202, errResp.ErrorCode)
+	require.Equal(t, http.StatusNotFound, sendRequest(t, leader, http.MethodGet,
+		"/subjects/orders-value/versions/7", nil, &errResp))
+	require.Equal(t, 40402, errResp.ErrorCode)
+}
+
+func TestStateRecoveredFromLog(t *testing.T) {
+	leader, _, log := setupServers(t)
+	register(t, leader, "orders-value", schemaV1)
+	register(t, leader, "orders-value", schemaV2)
+	require.Equal(t, http.StatusOK, sendRequest(t, leader, http.MethodPut, "/config",
+		map[string]string{"compatibility": "FULL"}, nil))
+
+	cfg := NewConf()
+	cfg.Enabled = true
+	cfg.ListenAddress = "localhost:0"
+	server := NewServer(cfg, log, &testLeadership{isLeader: true})
+	require.NoError(t, server.Start())
+	defer func() {
+		require.NoError(t, server.Stop())
+	}()
+	var schema schemareg.Schema
+	require.Equal(t, http.StatusOK, sendRequest(t, server, http.MethodGet, "/subjects/orders-value/versions/2", nil,
+		&schema))
+	require.Equal(t, schemaV2, schema.Schema)
+	var config configResponse
+	require.Equal(t, http.StatusOK, sendRequest(t, server, http.MethodGet, "/config", nil, &config))
+	require.Equal(t, schemareg.CompatibilityFull, config.CompatibilityLevel)
+	// The next id follows on from those in the log
+	require.Equal(t, 3, register(t, server, "other-value", schemaV3))
+}
@@ -0,0 +1,234 @@
+package schemaserver
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/schemareg"
+	"sort"
+)
+
+// Record is a record in the schemas topic. The key identifies what the record describes - a version of a subject or
+// the config of a subject - and the latest record for a key holds its current state, which is why the topic can be
+// compacted.
+type Record struct {
+	Key   []byte
+	Value []byte
+}
+
+const (
+	keyTypeSchema = "SCHEMA"
+	keyTypeConfig = "CONFIG"
+)
+
+type recordKey struct {
+	KeyType string `json:"keytype"`
+	Subject string `json:"subject,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+// schemaVersion is the value of a SCHEMA record.
+type schemaVersion struct {
+	Subject    string               `json:"subject"`
+	Version    int                  `json:"version"`
+	ID         int                  `json:"id"`
+	SchemaType schemareg.SchemaType `json:"schemaType,omitempty"`
+	Schema     string               `json:"schema"`
+	// Deleted is true if the version has been soft deleted. It is no longer returned when listing versions but can
+	// still be looked up by id.
+	Deleted bool `json:"deleted,omitempty"`
+	// Permanent is true if the version has been permanently deleted. The record is kept so ids are never reused.
+	Permanent bool `json:"permanent,omitempty"`
+}
+
+// configValue is the value of a CONFIG record. An empty level means the config has been deleted.
+type configValue struct {
+	CompatibilityLevel schemareg.CompatibilityLevel `json:"compatibilityLevel,omitempty"`
+}
+
+func schemaRecord(sv *schemaVersion) (Record, error) {
+	key, err := json.Marshal(&recordKey{KeyType: keyTypeSchema, Subject: sv.Subject, Version: sv.Version})
+	if err != nil {
+		return Record{}, err
+	}
+	value, err := json.Marshal(sv)
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Key: key, Value: value}, nil
+}
+
+func configRecord(subject string, level schemareg.CompatibilityLevel) (Record, error) {
+	key, err := json.Marshal(&recordKey{KeyType: keyTypeConfig, Subject: subject})
+	if err != nil {
+		return Record{}, err
+	}
+	value, err := json.Marshal(&configValue{CompatibilityLevel: level})
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Key: key, Value: value}, nil
+}
+
+// registryState is the state of the registry, built by applying the records in the schemas topic in order.
+type registryState struct {
+	// subjects maps subject to its versions ordered by version, including soft deleted versions
+	subjects map[string][]*schemaVersion
+	ids      map[int]*schemaVersion
+	// fingerprints maps the type and text of a schema to its id, so the same schema always gets the same id
+	fingerprints map[string]int
+	configs      map[string]schemareg.CompatibilityLevel
+	maxID        int
+}
+
+func newRegistryState() *registryState {
+	return &registryState{
+		subjects:     map[string][]*schemaVersion{},
+		ids:          map[int]*schemaVersion{},
+		fingerprints: map[string]int{},
+		configs:      map[string]schemareg.CompatibilityLevel{},
+	}
+}
+
+func schemaFingerprint(schemaType schemareg.SchemaType, schema string) string {
+	if schemaType == "" {
+		schemaType = schemareg.SchemaTypeAvro
+	}
+	return string(schemaType) + "\x00" + schema
+}
+
+func (r *registryState) apply(rec Record) error {
+	var key recordKey
+	if err := json.Unmarshal(rec.Key, &key); err != nil {
+		return errors.Wrap(err, "invalid schema registry record key")
+	}
+	switch key.KeyType {
+	case keyTypeSchema:
+		var sv schemaVersion
+		if err := json.Unmarshal(rec.Value, &sv); err != nil {
+			return errors.Wrap(err, "invalid schema registry record")
+		}
+		r.applySchema(&sv)
+	case keyTypeConfig:
+		var cv configValue
+		if err := json.Unmarshal(rec.Value, &cv); err != nil {
+			return errors.Wrap(err, "invalid schema registry record")
+		}
+		if cv.CompatibilityLevel == "" {
+			delete(r.configs, key.Subject)
+		} else {
+			r.configs[key.Subject] = cv.CompatibilityLevel
+		}
+	default:
+		// Ignore records we don't understand, they may have been written by a later version
+	}
+	return nil
+}
+
+func (r *registryState) applySchema(sv *schemaVersion) {
+	if sv.ID > r.maxID {
+		r.maxID = sv.ID
+	}
+	versions := r.subjects[sv.Subject]
+	pos := sort.Search(len(versions), func(i int) bool {
+		return versions[i].Version >= sv.Version
+	})
+	exists := pos < len(versions) && versions[pos].Version == sv.Version
+	if sv.Permanent {
+		if exists {
+			versions = append(versions[:pos], versions[pos+1:]...)
+			if len(versions) == 0 {
+				delete(r.subjects, sv.Subject)
+			} else {
+				r.subjects[sv.Subject] = versions
+			}
+		}
+		r.removeID(sv)
+		return
+	}
+	if exists {
+		versions[pos] = sv
+	} else {
+		versions = append(versions, nil)
+		copy(versions[pos+1:], versions[pos:])
+		versions[pos] = sv
+		r.subjects[sv.Subject] = versions
+	}
+	if _, ok := r.ids[sv.ID]; !ok {
+		r.ids[sv.ID] = sv
+		r.fingerprints[schemaFingerprint(sv.SchemaType, sv.Schema)] = sv.ID
+	} else if r.ids[sv.ID].Subject == sv.Subject && r.ids[sv.ID].Version == sv.Version {
+		// Keep the latest state of the version
+		r.ids[sv.ID] = sv
+	}
+}
+
+// removeID removes the id of a permanently deleted version, unless another version still uses it.
+func (r *registryState) removeID(deleted *schemaVersion) {
+	existing, ok := r.ids[deleted.ID]
+	if !ok || existing.Subject != deleted.Subject || existing.Version != deleted.Version {
+		return
+	}
+	delete(r.ids, deleted.ID)
+	for _, versions := range r.subjects {
+		for _, sv := range versions {
+			if sv.ID == deleted.ID {
+				r.ids[sv.ID] = sv
+				return
+			}
+		}
+	}
+	delete(r.fingerprints, schemaFingerprint(deleted.SchemaType, deleted.Schema))
+}
+
+// liveVersions returns the versions of a subject which have not been deleted, ordered by version.
+func (r *registryState) liveVersions(subject string) []*schemaVersion {
+	var live []*schemaVersion
+	for _, sv := range r.subjects[subject] {
+		if !sv.Deleted {
+			live = append(live, sv)
+		}
+	}
+	return live
+}
+
+func (r *registryState) liveSubjects() []string {
+	var subjects []string
+	for subject := range r.subjects {
+		if len(r.liveVersions(subject)) > 0 {
+			subjects = append(subjects, subject)
+		}
+	}
+	sort.Strings(subjects)
+	return subjects
+}
+
+// getVersion returns a version of a subject. version can be schemareg.LatestVersion. Soft deleted versions are only
+// returned if includeDeleted is true.
+func (r *registryState) getVersion(subject string, version int, includeDeleted bool) (*schemaVersion, error) {
+	versions := r.subjects[subject]
+	if !includeDeleted {
+		versions = r.liveVersions(subject)
+	}
+	if len(versions) == 0 {
+		return nil, subjectNotFoundError(subject)
+	}
+	if version == schemareg.LatestVersion {
+		return versions[len(versions)-1], nil
+	}
+	for _, sv := range versions {
+		if sv.Version == version {
+			return sv, nil
+		}
+	}
+	return nil, versionNotFoundError(subject, version)
+}
+
+func (r *registryState) compatibility(subject string, defaultLevel schemareg.CompatibilityLevel) schemareg.CompatibilityLevel {
+	if level, ok := r.configs[subject]; ok {
+		return level
+	}
+	if level, ok := r.configs[""]; ok {
+		return level
+	}
+	return defaultLevel
+}
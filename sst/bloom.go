@@ -0,0 +1,139 @@
+package sst
+
+import (
+	"bytes"
+	"github.com/cespare/xxhash/v2"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/spirit-labs/tektite/common"
+)
+
+const (
+	// bloomKeyPrefixLength is the length of the key prefixes added to the bloom filter of a DataFormatV2 table. Keys
+	// start with a 16 byte hash, e.g. the partition hash for topic data, so the filter tells us whether a table might
+	// contain any data for a partition or for the entity the hash identifies.
+	bloomKeyPrefixLength = 16
+	bloomBitsPerKey      = 10
+	// bloomNumHashes is optimal for bloomBitsPerKey (10 * ln 2), giving a false positive rate of about 1%
+	bloomNumHashes = 7
+	// bloomFilterCacheSize is the maximum number of tables whose bloom filters are held in bloomFilters
+	bloomFilterCacheSize = 16 * 1024
+)
+
+// bloomFilters caches the bloom filters of DataFormatV2 tables by table id. Once a table has been loaded, a
+// LazySSTableIterator over a key range that the filter shows is not in the table doesn't need to fetch the table
+// again. Tables are immutable so cached filters are never invalidated.
+var bloomFilters = newBloomFilterCache(bloomFilterCacheSize)
+
+type bloomFilterCache struct {
+	cache *lru.Cache
+}
+
+func newBloomFilterCache(size int) *bloomFilterCache {
+	cache, err := lru.New(size)
+	if err != nil {
+		panic(err)
+	}
+	return &bloomFilterCache{cache: cache}
+}
+
+func (b *bloomFilterCache) put(tableID SSTableID, table *SSTable) {
+	filter := table.bloomFilter()
+	if filter == nil {
+		return
+	}
+	// copy, as the filter refers to the table bytes, which we don't want to retain
+	b.cache.Add(string(tableID), common.ByteSliceCopy(filter))
+}
+
+// mayContainRange returns false if the table has a cached bloom filter which shows it has no keys in the range
+func (b *bloomFilterCache) mayContainRange(tableID SSTableID, keyStart []byte, keyEnd []byte) bool {
+	filter, ok := b.cache.Get(common.ByteSliceToStringZeroCopy(tableID))
+	if !ok {
+		return true
+	}
+	return filterMayContainRange(filter.([]byte), keyStart, keyEnd)
+}
+
+// filterMayContainRange returns false if the filter shows there are no keys in the range. It only does so if all keys
+// in the range share the same key prefix.
+func filterMayContainRange(filter []byte, keyStart []byte, keyEnd []byte) bool {
+	if filter == nil {
+		return true
+	}
+	prefix, ok := rangePrefix(keyStart, keyEnd)
+	if !ok {
+		return true
+	}
+	return bloomMayContain(filter, hashKeyPrefix(prefix))
+}
+
+// appendBloomFilter appends a bloom filter containing the given hashes. The first byte is the number of hash functions
+// and the rest is the bit array. The hash functions are derived from a single 64 bit hash using double hashing.
+func appendBloomFilter(buff []byte, hashes []uint64) []byte {
+	numBits := len(hashes) * bloomBitsPerKey
+	if numBits < 64 {
+		numBits = 64
+	}
+	numBytes := (numBits + 7) / 8
+	numBits = numBytes * 8
+	buff = append(buff, bloomNumHashes)
+	start := len(buff)
+	buff = append(buff, make([]byte, numBytes)...)
+	bits := buff[start:]
+	for _, h := range hashes {
+		h1, h2 := uint32(h), uint32(h>>32)
+		for i := uint32(0); i < bloomNumHashes; i++ {
+			bit := (h1 + i*h2) % uint32(numBits)
+			bits[bit/8] |= 1 << (bit % 8)
+		}
+	}
+	return buff
+}
+
+func bloomMayContain(filter []byte, h uint64) bool {
+	if len(filter) < 2 {
+		return true
+	}
+	numHashes := uint32(filter[0])
+	bits := filter[1:]
+	numBits := uint32(len(bits) * 8)
+	h1, h2 := uint32(h), uint32(h>>32)
+	for i := uint32(0); i < numHashes; i++ {
+		bit := (h1 + i*h2) % numBits
+		if bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hashKeyPrefix(key []byte) uint64 {
+	return xxhash.Sum64(keyPrefix(key))
+}
+
+func keyPrefix(key []byte) []byte {
+	if len(key) < bloomKeyPrefixLength {
+		return key
+	}
+	return key[:bloomKeyPrefixLength]
+}
+
+// rangePrefix returns the key prefix shared by all keys in the range keyStart (inclusive) to keyEnd (exclusive), if
+// there is one
+func rangePrefix(keyStart []byte, keyEnd []byte) ([]byte, bool) {
+	if len(keyStart) < bloomKeyPrefixLength || keyEnd == nil {
+		return nil, false
+	}
+	prefix := keyStart[:bloomKeyPrefixLength]
+	// The smallest key greater than all keys with the prefix is the prefix incremented by one
+	upper := make([]byte, bloomKeyPrefixLength)
+	copy(upper, prefix)
+	for i := len(upper) - 1; i >= 0; i-- {
+		upper[i]++
+		if upper[i] != 0 {
+			return prefix, bytes.Compare(keyEnd, upper) <= 0
+		}
+	}
+	// Prefix is all 0xff so every key >= keyStart has it
+	return prefix, true
+}
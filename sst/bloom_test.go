@@ -0,0 +1,79 @@
+package sst
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBloomFilterNoFalseNegatives(t *testing.T) {
+	var hashes []uint64
+	for i := 0; i < 10000; i++ {
+		hashes = append(hashes, hashKeyPrefix(bloomTestPrefix(i)))
+	}
+	filter := appendBloomFilter(nil, hashes)
+	for i := 0; i < 10000; i++ {
+		require.True(t, bloomMayContain(filter, hashKeyPrefix(bloomTestPrefix(i))))
+	}
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	numKeys := 10000
+	var hashes []uint64
+	for i := 0; i < numKeys; i++ {
+		hashes = append(hashes, hashKeyPrefix(bloomTestPrefix(i)))
+	}
+	filter := appendBloomFilter(nil, hashes)
+	falsePositives := 0
+	for i := numKeys; i < 2*numKeys; i++ {
+		if bloomMayContain(filter, hashKeyPrefix(bloomTestPrefix(i))) {
+			falsePositives++
+		}
+	}
+	// Expected rate is about 1%
+	require.Less(t, float64(falsePositives)/float64(numKeys), 0.03)
+}
+
+func TestBloomFilterEmpty(t *testing.T) {
+	filter := appendBloomFilter(nil, nil)
+	require.False(t, bloomMayContain(filter, hashKeyPrefix(bloomTestPrefix(0))))
+	// Missing filter must not exclude anything
+	require.True(t, bloomMayContain(nil, hashKeyPrefix(bloomTestPrefix(0))))
+}
+
+func TestRangePrefix(t *testing.T) {
+	prefix := bloomTestPrefix(23)
+	key := func(p []byte, suffix string) []byte {
+		return append(append([]byte{}, p...), suffix...)
+	}
+	upper := bloomTestPrefix(24)
+
+	p, ok := rangePrefix(key(prefix, "a"), key(prefix, "b"))
+	require.True(t, ok)
+	require.Equal(t, prefix, p)
+
+	_, ok = rangePrefix(key(prefix, "a"), upper)
+	require.True(t, ok)
+
+	_, ok = rangePrefix(key(prefix, "a"), key(upper, "a"))
+	require.False(t, ok)
+
+	_, ok = rangePrefix(key(prefix, "a"), nil)
+	require.False(t, ok)
+
+	_, ok = rangePrefix([]byte("short"), []byte("shorter"))
+	require.False(t, ok)
+
+	maxPrefix := make([]byte, bloomKeyPrefixLength)
+	for i := range maxPrefix {
+		maxPrefix[i] = 0xff
+	}
+	_, ok = rangePrefix(key(maxPrefix, "a"), key(maxPrefix, "z"))
+	require.True(t, ok)
+}
+
+func bloomTestPrefix(i int) []byte {
+	prefix := make([]byte, bloomKeyPrefixLength)
+	binary.BigEndian.PutUint64(prefix[8:], uint64(i))
+	return prefix
+}
@@ -0,0 +1,136 @@
+package sst
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/spirit-labs/tektite/asl/encoding"
+	"sort"
+)
+
+// indexRestartInterval is the number of entries between restart points in an index block
+const indexRestartInterval = 16
+
+// appendIndexBlock appends the index block of a DataFormatV2 table. It has an entry for each data block, mapping the
+// first key in the block to the offset of the block in the table. Keys are prefix compressed - an entry only stores
+// the part of its key that differs from the key of the previous entry - apart from at restart points, every
+// indexRestartInterval entries, where the whole key is stored so the restart points can be binary searched.
+//
+//	Entry
+//	╭────────────┬──────────────┬─────────────┬───────────╮
+//	│sharedLength│unsharedLength│unshared key │blockOffset│
+//	├────────────┼──────────────┼─────────────┼───────────┤
+//	│uvarint     │uvarint       │unsharedLen  │uvarint    │
+//	╰────────────┴──────────────┴─────────────┴───────────╯
+//
+// The entries are followed by the offset of each restart point relative to the start of the block, and then the
+// number of restart points, all as 4 byte little endian.
+func appendIndexBlock(buff []byte, firstKeys [][]byte, blockOffsets []int) []byte {
+	start := len(buff)
+	var restarts []uint32
+	var prevKey []byte
+	for i, key := range firstKeys {
+		shared := 0
+		if i%indexRestartInterval == 0 {
+			restarts = append(restarts, uint32(len(buff)-start))
+		} else {
+			for shared < len(prevKey) && shared < len(key) && prevKey[shared] == key[shared] {
+				shared++
+			}
+		}
+		buff = binary.AppendUvarint(buff, uint64(shared))
+		buff = binary.AppendUvarint(buff, uint64(len(key)-shared))
+		buff = append(buff, key[shared:]...)
+		buff = binary.AppendUvarint(buff, uint64(blockOffsets[i]))
+		prevKey = key
+	}
+	for _, restart := range restarts {
+		buff = encoding.AppendUint32ToBufferLE(buff, restart)
+	}
+	return encoding.AppendUint32ToBufferLE(buff, uint32(len(restarts)))
+}
+
+type indexBlock struct {
+	entries     []byte
+	restarts    []byte
+	numRestarts int
+}
+
+func newIndexBlock(buff []byte) indexBlock {
+	numRestarts, _ := encoding.ReadUint32FromBufferLE(buff, len(buff)-4)
+	restartsStart := len(buff) - 4 - 4*int(numRestarts)
+	return indexBlock{
+		entries:     buff[:restartsStart],
+		restarts:    buff[restartsStart : len(buff)-4],
+		numRestarts: int(numRestarts),
+	}
+}
+
+// find returns the number and offset of the data block that a key would be in, i.e. the last block with first key
+// <= key, or the first block if there isn't one. It also returns the offset of the next block, or -1 if it's the last
+// block. If there are no blocks, the block number is -1.
+func (b *indexBlock) find(key []byte) (int, int, int) {
+	if b.numRestarts == 0 {
+		return -1, 0, -1
+	}
+	restart := sort.Search(b.numRestarts, func(i int) bool {
+		return bytes.Compare(b.restartKey(i), key) > 0
+	}) - 1
+	if restart < 0 {
+		restart = 0
+	}
+	pos := b.restartOffset(restart)
+	blockNum := restart*indexRestartInterval - 1
+	blockOffset := 0
+	var entryKey []byte
+	for pos < len(b.entries) {
+		var offset int
+		entryKey, offset, pos = b.readEntry(pos, entryKey)
+		if blockNum >= restart*indexRestartInterval && bytes.Compare(entryKey, key) > 0 {
+			return blockNum, blockOffset, offset
+		}
+		blockNum++
+		blockOffset = offset
+	}
+	return blockNum, blockOffset, -1
+}
+
+// blockOffsets returns the offsets of all the data blocks
+func (b *indexBlock) blockOffsets() []int {
+	offsets := make([]int, 0, b.numRestarts*indexRestartInterval)
+	var key []byte
+	pos := 0
+	for pos < len(b.entries) {
+		var offset int
+		key, offset, pos = b.readEntry(pos, key)
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+func (b *indexBlock) restartOffset(i int) int {
+	offset, _ := encoding.ReadUint32FromBufferLE(b.restarts, 4*i)
+	return int(offset)
+}
+
+func (b *indexBlock) restartKey(i int) []byte {
+	pos := b.restartOffset(i)
+	// shared length is always zero at a restart point
+	_, n := binary.Uvarint(b.entries[pos:])
+	pos += n
+	unshared, n := binary.Uvarint(b.entries[pos:])
+	pos += n
+	return b.entries[pos : pos+int(unshared)]
+}
+
+// readEntry reads the entry at pos, building the key from the previous key. The previous key buffer is reused.
+func (b *indexBlock) readEntry(pos int, prevKey []byte) ([]byte, int, int) {
+	shared, n := binary.Uvarint(b.entries[pos:])
+	pos += n
+	unshared, n := binary.Uvarint(b.entries[pos:])
+	pos += n
+	key := append(prevKey[:shared], b.entries[pos:pos+int(unshared)]...)
+	pos += int(unshared)
+	offset, n := binary.Uvarint(b.entries[pos:])
+	pos += n
+	return key, int(offset), pos
+}
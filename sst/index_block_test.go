@@ -0,0 +1,75 @@
+package sst
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestIndexBlockFind(t *testing.T) {
+	for _, numBlocks := range []int{1, 2, 15, 16, 17, 100, 1000} {
+		t.Run(fmt.Sprintf("blocks-%d", numBlocks), func(t *testing.T) {
+			firstKeys, offsets := indexBlockTestData(numBlocks)
+			buff := appendIndexBlock(nil, firstKeys, offsets)
+			index := newIndexBlock(buff)
+			require.Equal(t, offsets, index.blockOffsets())
+
+			for _, key := range indexBlockTestLookups(firstKeys) {
+				expectedBlock := sort.Search(len(firstKeys), func(i int) bool {
+					return bytes.Compare(firstKeys[i], key) > 0
+				}) - 1
+				if expectedBlock < 0 {
+					expectedBlock = 0
+				}
+				expectedNext := -1
+				if expectedBlock < numBlocks-1 {
+					expectedNext = offsets[expectedBlock+1]
+				}
+				blockNum, blockOffset, nextOffset := index.find(key)
+				require.Equal(t, expectedBlock, blockNum)
+				require.Equal(t, offsets[expectedBlock], blockOffset)
+				require.Equal(t, expectedNext, nextOffset)
+			}
+		})
+	}
+}
+
+func TestIndexBlockEmpty(t *testing.T) {
+	index := newIndexBlock(appendIndexBlock(nil, nil, nil))
+	blockNum, _, _ := index.find([]byte("foo"))
+	require.Equal(t, -1, blockNum)
+	require.Empty(t, index.blockOffsets())
+}
+
+func indexBlockTestData(numBlocks int) ([][]byte, []int) {
+	keySet := map[string]struct{}{}
+	for len(keySet) < numBlocks {
+		// Common prefix so that prefix compression is exercised
+		keySet[fmt.Sprintf("key-%06d-%d", rand.Intn(1000000), rand.Intn(1000))] = struct{}{}
+	}
+	var keys []string
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	firstKeys := make([][]byte, numBlocks)
+	offsets := make([]int, numBlocks)
+	offset := 0
+	for i, key := range keys {
+		firstKeys[i] = []byte(key)
+		offsets[i] = offset
+		offset += 1 + rand.Intn(100000)
+	}
+	return firstKeys, offsets
+}
+
+func indexBlockTestLookups(firstKeys [][]byte) [][]byte {
+	lookups := [][]byte{[]byte("a"), []byte("key-"), []byte("z")}
+	for _, key := range firstKeys {
+		lookups = append(lookups, key, append(append([]byte{}, key...), 0), key[:len(key)-1])
+	}
+	return lookups
+}
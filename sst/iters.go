@@ -2,6 +2,7 @@ package sst
 
 import (
 	"bytes"
+	"github.com/pkg/errors"
 	"github.com/spirit-labs/tektite/asl/encoding"
 	"github.com/spirit-labs/tektite/common"
 	"github.com/spirit-labs/tektite/iteration"
@@ -9,7 +10,17 @@ import (
 )
 
 func (s *SSTable) NewIterator(keyStart []byte, keyEnd []byte) (iteration.Iterator, error) {
+	if s.partial && !s.rangeLoaded(keyStart, keyEnd) {
+		return nil, errors.Errorf("key range %v to %v is outside the loaded range of partial sstable", keyStart, keyEnd)
+	}
+	if !s.mayContainRange(keyStart, keyEnd) {
+		return &SSTableIterator{ss: s, nextOffset: -1}, nil
+	}
 	offset := s.findOffset(keyStart)
+	if s.partial && offset >= s.dataOffset+len(s.data) {
+		// The loaded data covers the whole key range, so the next key is beyond it
+		offset = -1
+	}
 	si := &SSTableIterator{
 		ss:         s,
 		nextOffset: offset,
@@ -33,9 +44,12 @@ func (si *SSTableIterator) Next() (bool, common.KV, error) {
 		return false, common.KV{}, nil
 	}
 	indexOffset := int(si.ss.indexOffset)
+	// data may hold only part of the table, starting at dataOffset
+	data := si.ss.data
+	pos := si.nextOffset - si.ss.dataOffset
 	var kl, vl uint32
-	kl, si.nextOffset = encoding.ReadUint32FromBufferLE(si.ss.data, si.nextOffset)
-	k := si.ss.data[si.nextOffset : si.nextOffset+int(kl)]
+	kl, pos = encoding.ReadUint32FromBufferLE(data, pos)
+	k := data[pos : pos+int(kl)]
 	if si.keyEnd != nil && bytes.Compare(k, si.keyEnd) >= 0 {
 		// End of range
 		si.nextOffset = -1
@@ -44,16 +58,17 @@ func (si *SSTableIterator) Next() (bool, common.KV, error) {
 		return false, common.KV{}, nil
 	} else {
 		si.currkV.Key = k
-		si.nextOffset += int(kl)
-		vl, si.nextOffset = encoding.ReadUint32FromBufferLE(si.ss.data, si.nextOffset)
+		pos += int(kl)
+		vl, pos = encoding.ReadUint32FromBufferLE(data, pos)
 		if vl == 0 {
 			si.currkV.Value = nil
 		} else {
-			si.currkV.Value = si.ss.data[si.nextOffset : si.nextOffset+int(vl)]
+			si.currkV.Value = data[pos : pos+int(vl)]
 		}
-		si.nextOffset += int(vl)
-		if si.nextOffset >= indexOffset { // Start of index data marks end of entries data
-			// Reached end of SSTable
+		pos += int(vl)
+		si.nextOffset = pos + si.ss.dataOffset
+		if si.nextOffset >= indexOffset || pos >= len(data) { // Start of index data marks end of entries data
+			// Reached end of SSTable, or of the part of it that is loaded
 			si.nextOffset = -1
 		}
 		si.valid = true
@@ -71,11 +86,12 @@ func (si *SSTableIterator) Close() {
 type TableGetter func(tableID SSTableID) (*SSTable, error)
 
 type LazySSTableIterator struct {
-	tableID  SSTableID
-	getter   TableGetter
-	keyStart []byte
-	keyEnd   []byte
-	iter     iteration.Iterator
+	tableID     SSTableID
+	getter      TableGetter
+	rangeGetter RangeGetter
+	keyStart    []byte
+	keyEnd      []byte
+	iter        iteration.Iterator
 }
 
 func NewLazySSTableIterator(tableID SSTableID, tableGetter TableGetter, keyStart []byte,
@@ -89,6 +105,19 @@ func NewLazySSTableIterator(tableID SSTableID, tableGetter TableGetter, keyStart
 	return it, nil
 }
 
+// NewLazySSTableIteratorWithRangeGetter creates a LazySSTableIterator which, when first used, loads only the parts of
+// the table needed for the key range, using GetSSTableForKeyRange
+func NewLazySSTableIteratorWithRangeGetter(tableID SSTableID, rangeGetter RangeGetter, keyStart []byte,
+	keyEnd []byte) (iteration.Iterator, error) {
+	it := &LazySSTableIterator{
+		tableID:     tableID,
+		rangeGetter: rangeGetter,
+		keyStart:    keyStart,
+		keyEnd:      keyEnd,
+	}
+	return it, nil
+}
+
 func (l *LazySSTableIterator) Next() (bool, common.KV, error) {
 	iter, err := l.getIter()
 	if err != nil {
@@ -133,10 +162,22 @@ func dumpSST(id SSTableID, sst *SSTable) {
 
 func (l *LazySSTableIterator) getIter() (iteration.Iterator, error) {
 	if l.iter == nil {
-		ssTable, err := l.getter(l.tableID)
+		if !bloomFilters.mayContainRange(l.tableID, l.keyStart, l.keyEnd) {
+			// We've loaded the table before and it has nothing in range, so there's no need to fetch it
+			l.iter = iteration.EmptyIterator{}
+			return l.iter, nil
+		}
+		var ssTable *SSTable
+		var err error
+		if l.rangeGetter != nil {
+			ssTable, err = GetSSTableForKeyRange(l.tableID, l.rangeGetter, l.keyStart, l.keyEnd)
+		} else {
+			ssTable, err = l.getter(l.tableID)
+		}
 		if err != nil {
 			return nil, err
 		}
+		bloomFilters.put(l.tableID, ssTable)
 		iter, err := ssTable.NewIterator(l.keyStart, l.keyEnd)
 		if err != nil {
 			return nil, err
@@ -145,3 +186,13 @@ func (l *LazySSTableIterator) getIter() (iteration.Iterator, error) {
 	}
 	return l.iter, nil
 }
+
+func (s *SSTable) rangeLoaded(keyStart []byte, keyEnd []byte) bool {
+	if s.loadedKeyStart != nil && (keyStart == nil || bytes.Compare(keyStart, s.loadedKeyStart) < 0) {
+		return false
+	}
+	if s.loadedKeyEnd != nil && (keyEnd == nil || bytes.Compare(keyEnd, s.loadedKeyEnd) > 0) {
+		return false
+	}
+	return true
+}
@@ -26,6 +26,9 @@ type SSTable struct {
 	numPrefixDeletes uint32
 	indexOffset      uint32
 	creationTime     uint64
+	// bloomOffset and bloomLength locate the bloom filter of a DataFormatV2 table
+	bloomOffset uint32
+	bloomLength uint32
 
 	//  data
 	//  Initial 5 bytes contain format and metadataOffset
@@ -47,7 +50,20 @@ type SSTable struct {
 	//  ├───────────────────────────────────────────┼──────────┤ ... repeat Key and offset pairs
 	//  │maxKeyLength bytes                         │ 4 bytes  │
 	//  ╰───────────────────────────────────────────┴──────────╯
+	//  For DataFormatV2 the key-value pairs are split into data blocks of roughly storageBlockSize, on entry
+	//  boundaries, and the index instead has an entry per data block - see appendIndexBlock. The index is followed by
+	//  a bloom filter on the key prefixes of all the keys in the table - see appendBloomFilter. When the table is
+	//  stored each data block is compressed independently, so a reader only needs to fetch the blocks it needs.
 	data []byte
+	// index holds the index and metadata, i.e. the bytes of the table from indexOffset onwards
+	index []byte
+	// partial is true if the table was loaded with GetSSTableForKeyRange, in which case data only holds the entries
+	// for the requested key range, and dataOffset is the offset in the table of the first byte of data
+	partial    bool
+	dataOffset int
+	// loadedKeyStart and loadedKeyEnd are the key range that a partial table was loaded for
+	loadedKeyStart []byte
+	loadedKeyEnd   []byte
 }
 
 // metadata contains
@@ -56,15 +72,26 @@ type SSTable struct {
 // ├────────────┼───────────┼───────────┼──────────────────┼────────────┼─────────────┤
 // │4 bytes     │ 4 bytes   │ 4 bytes   │ 4 bytes          │ 4 bytes    │ 8 bytes     │
 // ╰────────────┴───────────┴───────────┴──────────────────┴────────────┴─────────────╯
+// followed, for DataFormatV2, by
+// ╭────────────┬────────────╮
+// │bloomOffset │ bloomLength│
+// ├────────────┼────────────┤
+// │4 bytes     │ 4 bytes    │
+// ╰────────────┴────────────╯
 // - size mentioned are max sizes. variable integer encoding can produce different size results
-const maxMetadataSize = 28
+const maxMetadataSize = 36
 
+// TableDataFormatVersion is the original storage format, where the whole table is compressed as a single unit
 const TableDataFormatVersion = 1
 
 func BuildSSTable(format common.DataFormat, buffSizeEstimate int, entriesEstimate int,
 	iter iteration.Iterator) (ssTable *SSTable, smallestKey []byte, largestKey []byte, minVersion uint64,
 	maxVersion uint64, err error) {
 
+	if format != common.DataFormatV1 && format != common.DataFormatV2 {
+		return nil, nil, nil, 0, 0, errwrap.Errorf("unsupported data format %d", format)
+	}
+
 	type indexEntry struct {
 		key    []byte
 		offset uint32
@@ -84,6 +111,11 @@ func BuildSSTable(format common.DataFormat, buffSizeEstimate int, entriesEstimat
 	numPrefixDeletes := 0
 	first := true
 	var prevKey []byte
+	// For DataFormatV2
+	var blockFirstKeys [][]byte
+	var blockOffsets []int
+	var prefixHashes []uint64
+	var prevPrefix []byte
 	for {
 		v, kv, err := iter.Next()
 		if err != nil {
@@ -106,12 +138,26 @@ func BuildSSTable(format common.DataFormat, buffSizeEstimate int, entriesEstimat
 		if lk > maxKeyLength {
 			maxKeyLength = lk
 		}
+		if format == common.DataFormatV2 {
+			if len(blockOffsets) == 0 || len(buff)-blockOffsets[len(blockOffsets)-1] >= storageBlockSize {
+				// start a new data block
+				blockFirstKeys = append(blockFirstKeys, kv.Key)
+				blockOffsets = append(blockOffsets, len(buff))
+			}
+			// keys are in order so keys with the same prefix are adjacent
+			prefix := keyPrefix(kv.Key)
+			if !bytes.Equal(prefix, prevPrefix) {
+				prefixHashes = append(prefixHashes, hashKeyPrefix(kv.Key))
+				prevPrefix = prefix
+			}
+		} else {
+			indexEntries = append(indexEntries, indexEntry{
+				key:    kv.Key,
+				offset: offset,
+			})
+		}
 		buff = appendBytesWithLengthPrefix(buff, kv.Key)
 		buff = appendBytesWithLengthPrefix(buff, kv.Value)
-		indexEntries = append(indexEntries, indexEntry{
-			key:    kv.Key,
-			offset: offset,
-		})
 		numEntries++
 		version := math.MaxUint64 - binary.BigEndian.Uint64(kv.Key[len(kv.Key)-8:]) // last 8 bytes is version
 		if len(kv.Value) == 0 {
@@ -134,6 +180,13 @@ func BuildSSTable(format common.DataFormat, buffSizeEstimate int, entriesEstimat
 
 	indexOffset := len(buff)
 
+	var bloomOffset, bloomLength int
+	if format == common.DataFormatV2 {
+		buff = appendIndexBlock(buff, blockFirstKeys, blockOffsets)
+		bloomOffset = len(buff)
+		buff = appendBloomFilter(buff, prefixHashes)
+		bloomLength = len(buff) - bloomOffset
+	}
 	for _, entry := range indexEntries {
 		buff = append(buff, entry.key...)
 		paddingBytes := maxKeyLength - len(entry.key)
@@ -167,6 +220,8 @@ func BuildSSTable(format common.DataFormat, buffSizeEstimate int, entriesEstimat
 		numPrefixDeletes: uint32(numPrefixDeletes),
 		indexOffset:      uint32(indexOffset),
 		creationTime:     uint64(time.Now().UTC().UnixMilli()),
+		bloomOffset:      uint32(bloomOffset),
+		bloomLength:      uint32(bloomLength),
 	}
 
 	buff = binary.AppendUvarint(buff, uint64(maxKeyLength))
@@ -175,13 +230,21 @@ func BuildSSTable(format common.DataFormat, buffSizeEstimate int, entriesEstimat
 	buff = binary.AppendUvarint(buff, uint64(numPrefixDeletes))
 	buff = binary.AppendUvarint(buff, uint64(indexOffset))
 	buff = binary.AppendUvarint(buff, selfTable.creationTime)
+	if format == common.DataFormatV2 {
+		buff = binary.AppendUvarint(buff, uint64(bloomOffset))
+		buff = binary.AppendUvarint(buff, uint64(bloomLength))
+	}
 
 	selfTable.data = buff
+	selfTable.index = buff[indexOffset:]
 
 	return selfTable, smallestKey, largestKey, minVersion, maxVersion, nil
 }
 
 func (s *SSTable) Serialize() []byte {
+	if s.partial {
+		panic("cannot serialize a partial sstable")
+	}
 	return s.data
 }
 
@@ -190,8 +253,13 @@ func (s *SSTable) Deserialize(buff []byte, offset int) int {
 	offset++
 	var metadataOffset uint32
 	metadataOffset, _ = encoding.ReadUint32FromBufferLE(buff, offset)
-	offset = int(metadataOffset)
+	offset = s.deserializeMetadata(buff, int(metadataOffset))
+	s.data = buff
+	s.index = buff[s.indexOffset:]
+	return offset
+}
 
+func (s *SSTable) deserializeMetadata(buff []byte, offset int) int {
 	var n int
 	var value uint64
 	value, n = binary.Uvarint(buff[offset:])
@@ -212,13 +280,21 @@ func (s *SSTable) Deserialize(buff []byte, offset int) int {
 	value, n = binary.Uvarint(buff[offset:])
 	offset += n
 	s.creationTime = value
-
-	s.data = buff
-
+	if s.format == common.DataFormatV2 {
+		value, n = binary.Uvarint(buff[offset:])
+		offset += n
+		s.bloomOffset = uint32(value)
+		value, n = binary.Uvarint(buff[offset:])
+		offset += n
+		s.bloomLength = uint32(value)
+	}
 	return offset
 }
 
 func (s *SSTable) SizeBytes() int {
+	if s.partial {
+		return len(s.data) + len(s.index)
+	}
 	return len(s.data)
 }
 
@@ -249,10 +325,15 @@ func appendBytesWithLengthPrefix(buff []byte, bytes []byte) []byte {
 }
 
 func (s *SSTable) findOffset(key []byte) int {
+	if s.format == common.DataFormatV2 {
+		return s.findOffsetV2(key)
+	}
 	indexRecordLen := int(s.maxKeyLength) + 4
 	numEntries := int(s.numEntries)
-	indexOffset := int(s.indexOffset)
 	maxKeyLength := int(s.maxKeyLength)
+	if numEntries == 0 {
+		return -1
+	}
 
 	// We do a binary search in the index
 	low := 0
@@ -260,8 +341,8 @@ func (s *SSTable) findOffset(key []byte) int {
 	high := outerHighBound
 	for low < high {
 		middle := low + (high-low)/2
-		recordStart := middle*indexRecordLen + indexOffset
-		midKey := s.data[recordStart : recordStart+maxKeyLength]
+		recordStart := middle * indexRecordLen
+		midKey := s.index[recordStart : recordStart+maxKeyLength]
 		if bytes.Compare(midKey, key) < 0 {
 			low = middle + 1
 		} else {
@@ -269,47 +350,94 @@ func (s *SSTable) findOffset(key []byte) int {
 		}
 	}
 	if high == outerHighBound {
-		recordStart := high*indexRecordLen + indexOffset
-		highKey := s.data[recordStart : recordStart+maxKeyLength]
+		recordStart := high * indexRecordLen
+		highKey := s.index[recordStart : recordStart+maxKeyLength]
 		if bytes.Compare(highKey, key) < 0 {
 			// Didn't find key
 			return -1
 		}
 	}
-	recordStart := high*indexRecordLen + indexOffset
+	recordStart := high * indexRecordLen
 	valueStart := recordStart + maxKeyLength
-	off, _ := encoding.ReadUint32FromBufferLE(s.data, valueStart)
+	off, _ := encoding.ReadUint32FromBufferLE(s.index, valueStart)
 	return int(off)
 }
 
+// findOffsetV2 finds the data block that the key would be in, then scans the block for the first key >= key
+func (s *SSTable) findOffsetV2(key []byte) int {
+	index := s.indexBlock()
+	blockNum, blockOffset, nextBlockOffset := index.find(key)
+	if blockNum == -1 {
+		return -1
+	}
+	if s.partial && (blockOffset < s.dataOffset || blockOffset >= s.dataOffset+len(s.data)) {
+		// block is not loaded
+		return blockOffset
+	}
+	blockEnd := nextBlockOffset
+	if blockEnd == -1 {
+		blockEnd = int(s.indexOffset)
+	}
+	offset := blockOffset
+	for offset < blockEnd {
+		pos := offset - s.dataOffset
+		var kl, vl uint32
+		kl, pos = encoding.ReadUint32FromBufferLE(s.data, pos)
+		if bytes.Compare(s.data[pos:pos+int(kl)], key) >= 0 {
+			return offset
+		}
+		pos += int(kl)
+		vl, pos = encoding.ReadUint32FromBufferLE(s.data, pos)
+		offset = pos + int(vl) + s.dataOffset
+	}
+	// All keys in the block are < key, so it's the first key of the next block
+	return nextBlockOffset
+}
+
+func (s *SSTable) indexBlock() indexBlock {
+	return newIndexBlock(s.index[:s.bloomOffset-s.indexOffset])
+}
+
+// mayContainRange returns false if the bloom filter shows the table has no keys in the range. It only does so if all
+// keys in the range share the same key prefix.
+func (s *SSTable) mayContainRange(keyStart []byte, keyEnd []byte) bool {
+	return filterMayContainRange(s.bloomFilter(), keyStart, keyEnd)
+}
+
+// bloomFilter returns the bloom filter of a DataFormatV2 table, or nil for other formats
+func (s *SSTable) bloomFilter() []byte {
+	if s.format != common.DataFormatV2 {
+		return nil
+	}
+	bloomStart := s.bloomOffset - s.indexOffset
+	return s.index[bloomStart : bloomStart+s.bloomLength]
+}
+
 func (s *SSTable) ToStorageBytes(compressionType compress.CompressionType) ([]byte, error) {
-	tableData := s.Serialize()
 	// First two bytes is format version
-	buff := make([]byte, 0, len(tableData)/10) // guess on initial capacity
-	buff = binary.BigEndian.AppendUint16(buff, uint16(TableDataFormatVersion))
+	buff := make([]byte, 0, len(s.data)/10) // guess on initial capacity
+	buff = binary.BigEndian.AppendUint16(buff, uint16(TableDataFormatVersionRanged))
 	// Next byte is compression type
 	buff = append(buff, byte(compressionType))
-	if compressionType != compress.CompressionTypeNone {
-		// Note, we compress the entire table using our own configured compression - any compressed produced records
-		// will already have been decompressed by this point.
-		var err error
-		buff, err = compress.Compress(compressionType, buff, tableData)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		buff = append(buff, tableData...)
-	}
-	return buff, nil
+	// Note, we compress using our own configured compression - any compressed produced records will already have been
+	// decompressed by this point.
+	return s.appendRangedStorage(buff, compressionType)
 }
 
+// SSTableIDPrefix is the prefix of the ids, and so the object store keys, of all tables
+const SSTableIDPrefix = "sst-"
+
 func CreateSSTableId() string {
-	return fmt.Sprintf("sst-%s", uuid.New().String())
+	return fmt.Sprintf("%s%s", SSTableIDPrefix, uuid.New().String())
 }
 
 func GetSSTableFromBytes(bytes []byte) (*SSTable, error) {
 	version := binary.BigEndian.Uint16(bytes)
-	if version != TableDataFormatVersion {
+	switch version {
+	case TableDataFormatVersion:
+	case TableDataFormatVersionRanged:
+		return getSSTableFromRangedStorage(bytes)
+	default:
 		return nil, errors.Errorf("invalid table format version: %d", version)
 	}
 	compressionType := compress.CompressionType(bytes[2])
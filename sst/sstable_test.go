@@ -12,6 +12,14 @@ import (
 )
 
 func TestBuildTable(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testBuildTable(t, format)
+		})
+	}
+}
+
+func testBuildTable(t *testing.T, format common.DataFormat) {
 	commonPrefix := []byte("keyprefix/")
 	numEntries := 1000
 	iter := prepareInput(commonPrefix, []byte("valueprefix/"), numEntries)
@@ -22,9 +30,9 @@ func TestBuildTable(t *testing.T) {
 		iter.AddKV([]byte(key), nil)
 	}
 	now := uint64(time.Now().UTC().UnixMilli())
-	sstable, smallestKey, largestKey, _, _, err := BuildSSTable(common.DataFormatV1, 0, 0, iter)
+	sstable, smallestKey, largestKey, _, _, err := BuildSSTable(format, 0, 0, iter)
 	require.NoError(t, err)
-	require.Equal(t, common.DataFormatV1, sstable.format)
+	require.Equal(t, format, sstable.format)
 	require.Equal(t, numEntries+numDeletes, int(sstable.numEntries))
 	require.Equal(t, numDeletes, int(sstable.numDeletes))
 	expectedSmallestKey := []byte(fmt.Sprintf("%ssomekey-%010d", string(commonPrefix), 0))
@@ -35,13 +43,21 @@ func TestBuildTable(t *testing.T) {
 }
 
 func TestBuildWithTombstones(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testBuildWithTombstones(t, format)
+		})
+	}
+}
+
+func testBuildWithTombstones(t *testing.T, format common.DataFormat) {
 	gi := &iteration2.StaticIterator{}
 	gi.AddKV([]byte("keyPrefix/key0"), nil)
 	gi.AddKV([]byte("keyPrefix/key1"), []byte("val1"))
 	gi.AddKV([]byte("keyPrefix/key2"), []byte("val2"))
 	gi.AddKV([]byte("keyPrefix/key3"), nil)
 
-	sstable, _, _, _, _, err := BuildSSTable(common.DataFormatV1, 0, 0, gi)
+	sstable, _, _, _, _, err := BuildSSTable(format, 0, 0, gi)
 	require.NoError(t, err)
 
 	iter, err := sstable.NewIterator([]byte("keyPrefix/"), nil)
@@ -84,6 +100,14 @@ func TestBuildWithPrefixTombstones(t *testing.T) {
 }
 
 func TestSeek(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testSeek(t, format)
+		})
+	}
+}
+
+func testSeek(t *testing.T, format common.DataFormat) {
 	commonPrefix := []byte("keyprefix/")
 	numEntries := 1000
 
@@ -100,7 +124,7 @@ func TestSeek(t *testing.T) {
 	value = fmt.Sprintf("%ssomevalue-%010d", "valueprefix/", 1600)
 	iter.AddKVAsString(key, value)
 
-	sstable, _, _, _, _, err := BuildSSTable(common.DataFormatV1, 0, 0, iter)
+	sstable, _, _, _, _, err := BuildSSTable(format, 0, 0, iter)
 	require.NoError(t, err)
 
 	// Seek all the keys - exact match
@@ -154,6 +178,14 @@ func seek(t *testing.T, seekKey []byte, expectedKey []byte, expectedValue []byte
 }
 
 func TestIterateWithGaps(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testIterateWithGaps(t, format)
+		})
+	}
+}
+
+func testIterateWithGaps(t *testing.T, format common.DataFormat) {
 	commonPrefix := []byte("keyprefix/")
 	it := &iteration2.StaticIterator{}
 	// Add a few more entries so we can test seeking to next
@@ -167,7 +199,7 @@ func TestIterateWithGaps(t *testing.T) {
 	value = fmt.Sprintf("%ssomevalue-%010d", "valueprefix/", 1600)
 	it.AddKVAsString(key, value)
 
-	sstable, _, _, _, _, err := BuildSSTable(common.DataFormatV1, 0, 0, it)
+	sstable, _, _, _, _, err := BuildSSTable(format, 0, 0, it)
 	require.NoError(t, err)
 	iter, err := sstable.NewIterator([]byte("keyprefix/somekey-0000001501"), nil)
 	require.NoError(t, err)
@@ -182,24 +214,40 @@ func TestIterateWithGaps(t *testing.T) {
 }
 
 func TestIterate(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testIterateWithFormat(t, format)
+		})
+	}
+}
+
+func testIterateWithFormat(t *testing.T, format common.DataFormat) {
 	commonPrefix := []byte("keyprefix/")
-	testIterate(t, commonPrefix, nil, 0, 999)
-	testIterate(t, commonPrefix, []byte("keyprefix/somekey-0000000450"), 0, 449)
-	testIterate(t, []byte("keyprefix/somekey-0000000300"), nil, 300, 999)
-	testIterate(t, []byte("keyprefix/somekey-0000000300999"), nil, 301, 999)
-	testIterate(t, []byte("keyprefix/somekey-0000000300"), []byte("keyprefix/somekey-0000000900"), 300, 899)
-	testIterate(t, []byte("keyprefix/somekey-0000000300"), []byte("keyprefix/somekey-0000000999"), 300, 998)
-	testIterate(t, []byte("keyprefix/somekey-0000000300"), []byte("keyprefix/somekey-0000000999999"), 300, 999)
-	testIterate(t, []byte("keyprefix/somekey-0000000300"), []byte("keyprefix/somekey-0000001000"), 300, 999)
-	testIterate(t, []byte("keyprefix/somekey-0000000700"), []byte("keyprefix/somekey-0000000701"), 700, 700)
-	testIterate(t, []byte("keyprefix/somekey-0000000700"), []byte("keyprefix/somekey-0000000700"), -1, -1)
-	testIterate(t, []byte("keyprefix/somekey-0000001000"), []byte("keyprefix/somekey-0000001001"), -1, -1)
-	testIterate(t, []byte("keyprefix/t"), []byte("keyprefix/u"), -1, -1)
+	testIterate(t, format, commonPrefix, nil, 0, 999)
+	testIterate(t, format, commonPrefix, []byte("keyprefix/somekey-0000000450"), 0, 449)
+	testIterate(t, format, []byte("keyprefix/somekey-0000000300"), nil, 300, 999)
+	testIterate(t, format, []byte("keyprefix/somekey-0000000300999"), nil, 301, 999)
+	testIterate(t, format, []byte("keyprefix/somekey-0000000300"), []byte("keyprefix/somekey-0000000900"), 300, 899)
+	testIterate(t, format, []byte("keyprefix/somekey-0000000300"), []byte("keyprefix/somekey-0000000999"), 300, 998)
+	testIterate(t, format, []byte("keyprefix/somekey-0000000300"), []byte("keyprefix/somekey-0000000999999"), 300, 999)
+	testIterate(t, format, []byte("keyprefix/somekey-0000000300"), []byte("keyprefix/somekey-0000001000"), 300, 999)
+	testIterate(t, format, []byte("keyprefix/somekey-0000000700"), []byte("keyprefix/somekey-0000000701"), 700, 700)
+	testIterate(t, format, []byte("keyprefix/somekey-0000000700"), []byte("keyprefix/somekey-0000000700"), -1, -1)
+	testIterate(t, format, []byte("keyprefix/somekey-0000001000"), []byte("keyprefix/somekey-0000001001"), -1, -1)
+	testIterate(t, format, []byte("keyprefix/t"), []byte("keyprefix/u"), -1, -1)
 }
 
 func TestCurrentIterateAll(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testCurrentIterateAll(t, format)
+		})
+	}
+}
+
+func testCurrentIterateAll(t *testing.T, format common.DataFormat) {
 	it := prepareInput(nil, nil, 10)
-	sstable, _, _, _, _, err := BuildSSTable(common.DataFormatV1, 0, 0, it)
+	sstable, _, _, _, _, err := BuildSSTable(format, 0, 0, it)
 	require.NoError(t, err)
 	iter, err := sstable.NewIterator(nil, nil)
 	require.NoError(t, err)
@@ -213,8 +261,16 @@ func TestCurrentIterateAll(t *testing.T) {
 }
 
 func TestCurrentIteratePartial(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testCurrentIteratePartial(t, format)
+		})
+	}
+}
+
+func testCurrentIteratePartial(t *testing.T, format common.DataFormat) {
 	it := prepareInput(nil, nil, 10)
-	sstable, _, _, _, _, err := BuildSSTable(common.DataFormatV1, 0, 0, it)
+	sstable, _, _, _, _, err := BuildSSTable(format, 0, 0, it)
 	require.NoError(t, err)
 	startRange := []byte(fmt.Sprintf("somekey-%010d", 2))
 	endRange := []byte(fmt.Sprintf("somekey-%010d", 7))
@@ -229,12 +285,12 @@ func TestCurrentIteratePartial(t *testing.T) {
 	require.Equal(t, common.KV{}, iter.Current())
 }
 
-func testIterate(t *testing.T, startKey []byte, endKey []byte, firstExpected int, lastExpected int) {
+func testIterate(t *testing.T, format common.DataFormat, startKey []byte, endKey []byte, firstExpected int, lastExpected int) {
 	t.Helper()
 	commonPrefix := []byte("keyprefix/")
 	numEntries := 1000
 	it := prepareInput(commonPrefix, []byte("valueprefix/"), numEntries)
-	sstable, _, _, _, _, err := BuildSSTable(common.DataFormatV1, 0, 0, it)
+	sstable, _, _, _, _, err := BuildSSTable(format, 0, 0, it)
 	require.NoError(t, err)
 
 	iter, err := sstable.NewIterator(startKey, endKey)
@@ -262,6 +318,14 @@ func testIterate(t *testing.T, startKey []byte, endKey []byte, firstExpected int
 }
 
 func TestSerializeDeserialize(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testSerializeDeserialize(t, format)
+		})
+	}
+}
+
+func testSerializeDeserialize(t *testing.T, format common.DataFormat) {
 	commonPrefix := []byte("................prefix1_")
 	numEntries := 1000
 
@@ -280,7 +344,7 @@ func TestSerializeDeserialize(t *testing.T) {
 	key2 := fmt.Sprintf("%ssomekey-%010d", string(commonPrefix), numEntries+1)
 	gi.AddKV(encoding.EncodeVersion([]byte(key2), 0), nil)
 
-	sstable, _, _, _, _, err := BuildSSTable(common.DataFormatV1, 0, 0, gi)
+	sstable, _, _, _, _, err := BuildSSTable(format, 0, 0, gi)
 	require.NoError(t, err)
 	require.Equal(t, 1, int(sstable.numPrefixDeletes))
 	buff := sstable.Serialize()
@@ -296,8 +360,12 @@ func TestSerializeDeserialize(t *testing.T) {
 	require.Equal(t, sstable.data, sstable2.data)
 	require.Equal(t, sstable.creationTime, sstable2.creationTime)
 	require.Equal(t, sstable.numPrefixDeletes, sstable2.numPrefixDeletes)
+	require.Equal(t, sstable.bloomOffset, sstable2.bloomOffset)
+	require.Equal(t, sstable.bloomLength, sstable2.bloomLength)
 }
 
+var dataFormats = []common.DataFormat{common.DataFormatV1, common.DataFormatV2}
+
 func prepareInput(keyPrefix []byte, valuePrefix []byte, numEntries int) *iteration2.StaticIterator {
 	gi := &iteration2.StaticIterator{}
 	for i := 0; i < numEntries; i++ {
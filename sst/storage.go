@@ -0,0 +1,316 @@
+package sst
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"github.com/spirit-labs/tektite/asl/encoding"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/compress"
+	"math"
+	"sort"
+)
+
+// TableDataFormatVersionRanged is the storage format written by ToStorageBytes. It is laid out so that a reader can
+// fetch just the parts of a table it needs using ranged reads:
+//
+//	╭───────┬───────────┬──────────┬──────────────────┬───────┬───────┬─────╮
+//	│version│compression│metaLength│meta              │block 0│block 1│ ... │
+//	├───────┼───────────┼──────────┼──────────────────┼───────┼───────┼─────┤
+//	│2 bytes│1 byte     │4 bytes   │metaLength bytes  │       │       │     │
+//	╰───────┴───────────┴──────────┴──────────────────┴───────┴───────┴─────╯
+//
+// The blocks hold the key-value pairs of the table, split on entry boundaries into blocks of roughly
+// storageBlockSize, each compressed independently. Meta is compressed and contains the table format, the index and
+// metadata, and the location of each block. Tables are usually read a key range at a time, so it is the index and
+// metadata that are needed first. They are at the front rather than in a footer, so the reader can get them with a
+// single ranged read from offset zero without first having to find out the size of the object.
+//
+// Tables stored with TableDataFormatVersion are a single compressed unit and can still be read, but only in full.
+const TableDataFormatVersionRanged = 2
+
+const (
+	// storageBlockSize is the target uncompressed size of a block
+	storageBlockSize = 64 * 1024
+	// rangedHeaderSize is the size of version, compression and metaLength
+	rangedHeaderSize = 7
+	// initialReadSize is the size of the first ranged read of a table. The meta of most tables fits in this.
+	initialReadSize = 64 * 1024
+)
+
+// RangeGetter gets length bytes of the stored table, starting at offset. If the range extends past the end of the
+// table the bytes up to the end are returned. If the table does not exist, an empty slice is returned.
+type RangeGetter func(tableID SSTableID, offset int64, length int64) ([]byte, error)
+
+type storageBlock struct {
+	// tableOffset is the offset in the table of the first byte in the block
+	tableOffset int
+	// storedOffset and storedLength give the position of the stored block relative to the end of meta
+	storedOffset int
+	storedLength int
+}
+
+type rangedMeta struct {
+	format         common.DataFormat
+	indexOffset    int
+	metadataOffset int
+	index          []byte
+	blocks         []storageBlock
+}
+
+func (s *SSTable) appendRangedStorage(buff []byte, compressionType compress.CompressionType) ([]byte, error) {
+	data := s.Serialize()
+	indexOffset := int(s.indexOffset)
+	metadataOffset, _ := encoding.ReadUint32FromBufferLE(data, 1)
+	blockOffsets := s.blockOffsets()
+
+	meta := make([]byte, 0, len(s.index)+32+8*len(blockOffsets))
+	meta = append(meta, byte(s.format))
+	meta = binary.AppendUvarint(meta, uint64(indexOffset))
+	meta = binary.AppendUvarint(meta, uint64(metadataOffset))
+	meta = binary.AppendUvarint(meta, uint64(len(s.index)))
+	meta = append(meta, s.index...)
+	meta = binary.AppendUvarint(meta, uint64(len(blockOffsets)))
+	blocks := make([]byte, 0, len(data)/2) // guess on initial capacity
+	for i, blockStart := range blockOffsets {
+		blockEnd := indexOffset
+		if i < len(blockOffsets)-1 {
+			blockEnd = blockOffsets[i+1]
+		}
+		prevLen := len(blocks)
+		var err error
+		blocks, err = appendCompressed(compressionType, blocks, data[blockStart:blockEnd])
+		if err != nil {
+			return nil, err
+		}
+		meta = binary.AppendUvarint(meta, uint64(blockStart))
+		meta = binary.AppendUvarint(meta, uint64(len(blocks)-prevLen))
+	}
+
+	// metaLength is filled in once we know the compressed size
+	buff = append(buff, 0, 0, 0, 0)
+	metaStart := len(buff)
+	var err error
+	buff, err = appendCompressed(compressionType, buff, meta)
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(buff[metaStart-4:], uint32(len(buff)-metaStart))
+	return append(buff, blocks...), nil
+}
+
+// blockOffsets returns the offset in the table of the start of each block. The first block also contains the table
+// header. For DataFormatV2 tables the blocks are the data blocks of the table.
+func (s *SSTable) blockOffsets() []int {
+	if s.format == common.DataFormatV2 {
+		index := s.indexBlock()
+		offsets := index.blockOffsets()
+		if len(offsets) == 0 {
+			return []int{0}
+		}
+		offsets[0] = 0
+		return offsets
+	}
+	offsets := []int{0}
+	indexRecordLen := int(s.maxKeyLength) + 4
+	blockStart := 0
+	for i := 0; i < int(s.numEntries); i++ {
+		entryOffset, _ := encoding.ReadUint32FromBufferLE(s.index, i*indexRecordLen+int(s.maxKeyLength))
+		if int(entryOffset)-blockStart >= storageBlockSize {
+			blockStart = int(entryOffset)
+			offsets = append(offsets, blockStart)
+		}
+	}
+	return offsets
+}
+
+func getSSTableFromRangedStorage(bytes []byte) (*SSTable, error) {
+	compressionType := compress.CompressionType(bytes[2])
+	metaLength := int(binary.BigEndian.Uint32(bytes[3:]))
+	blocksStart := rangedHeaderSize + metaLength
+	meta, err := decodeRangedMeta(compressionType, bytes[rangedHeaderSize:blocksStart])
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, meta.indexOffset+len(meta.index))
+	for _, block := range meta.blocks {
+		start := blocksStart + block.storedOffset
+		data, err = appendDecompressed(compressionType, data, bytes[start:start+block.storedLength])
+		if err != nil {
+			return nil, err
+		}
+	}
+	data = append(data, meta.index...)
+	table := &SSTable{}
+	table.Deserialize(data, 0)
+	return table, nil
+}
+
+// GetSSTableForKeyRange loads the part of a table needed to iterate over the range keyStart (inclusive) to keyEnd
+// (exclusive), fetching only the meta and the blocks covering the range. The returned table can only be iterated
+// over that range. Tables stored with TableDataFormatVersion are loaded in full.
+func GetSSTableForKeyRange(tableID SSTableID, getter RangeGetter, keyStart []byte, keyEnd []byte) (*SSTable, error) {
+	buff, err := getter(tableID, 0, initialReadSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(buff) == 0 {
+		return nil, errors.Errorf("cannot find sstable %s", tableID)
+	}
+	version := binary.BigEndian.Uint16(buff)
+	switch version {
+	case TableDataFormatVersion:
+		if len(buff) == initialReadSize {
+			// The table is a single compressed unit, so we need all of it
+			rest, err := getter(tableID, initialReadSize, math.MaxUint32)
+			if err != nil {
+				return nil, err
+			}
+			buff = concat(buff, rest)
+		}
+		return GetSSTableFromBytes(buff)
+	case TableDataFormatVersionRanged:
+	default:
+		return nil, errors.Errorf("invalid table format version: %d", version)
+	}
+	compressionType := compress.CompressionType(buff[2])
+	metaLength := int(binary.BigEndian.Uint32(buff[3:]))
+	blocksStart := rangedHeaderSize + metaLength
+	if len(buff) < blocksStart {
+		rest, err := getter(tableID, int64(len(buff)), int64(blocksStart-len(buff)))
+		if err != nil {
+			return nil, err
+		}
+		buff = concat(buff, rest)
+		if len(buff) < blocksStart {
+			return nil, errors.Errorf("sstable %s is truncated", tableID)
+		}
+	}
+	meta, err := decodeRangedMeta(compressionType, buff[rangedHeaderSize:blocksStart])
+	if err != nil {
+		return nil, err
+	}
+	table := &SSTable{
+		format:         meta.format,
+		index:          meta.index,
+		partial:        true,
+		dataOffset:     meta.indexOffset,
+		loadedKeyStart: keyStart,
+		loadedKeyEnd:   keyEnd,
+	}
+	table.deserializeMetadata(meta.index, meta.metadataOffset-meta.indexOffset)
+	firstBlock, lastBlock, ok := table.blocksForRange(meta, keyStart, keyEnd)
+	if !ok {
+		// No entries in range
+		return table, nil
+	}
+	var data []byte
+	for i := firstBlock; i <= lastBlock; i++ {
+		block := meta.blocks[i]
+		blockBytes, err := getter(tableID, int64(blocksStart+block.storedOffset), int64(block.storedLength))
+		if err != nil {
+			return nil, err
+		}
+		if len(blockBytes) != block.storedLength {
+			return nil, errors.Errorf("sstable %s is truncated", tableID)
+		}
+		data, err = appendDecompressed(compressionType, data, blockBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	table.data = data
+	table.dataOffset = meta.blocks[firstBlock].tableOffset
+	return table, nil
+}
+
+// blocksForRange returns the first and last blocks that must be loaded to iterate over the key range
+func (s *SSTable) blocksForRange(meta *rangedMeta, keyStart []byte, keyEnd []byte) (int, int, bool) {
+	if s.numEntries == 0 || !s.mayContainRange(keyStart, keyEnd) {
+		return 0, 0, false
+	}
+	lastBlock := len(meta.blocks) - 1
+	if s.format == common.DataFormatV2 {
+		// data blocks and stored blocks are the same
+		index := s.indexBlock()
+		firstBlock, _, _ := index.find(keyStart)
+		if keyEnd != nil {
+			lastBlock, _, _ = index.find(keyEnd)
+		}
+		return firstBlock, lastBlock, true
+	}
+	startOffset := s.findOffset(keyStart)
+	if startOffset == -1 {
+		return 0, 0, false
+	}
+	firstBlock := meta.blockContaining(startOffset)
+	if keyEnd != nil {
+		// The iterator reads the first entry at or after keyEnd to find the end of the range, so we need that too
+		if endOffset := s.findOffset(keyEnd); endOffset != -1 {
+			lastBlock = meta.blockContaining(endOffset)
+		}
+	}
+	return firstBlock, lastBlock, true
+}
+
+func decodeRangedMeta(compressionType compress.CompressionType, buff []byte) (*rangedMeta, error) {
+	buff, err := appendDecompressed(compressionType, nil, buff)
+	if err != nil {
+		return nil, err
+	}
+	meta := &rangedMeta{format: common.DataFormat(buff[0])}
+	offset := 1
+	readUvarint := func() int {
+		value, n := binary.Uvarint(buff[offset:])
+		offset += n
+		return int(value)
+	}
+	meta.indexOffset = readUvarint()
+	meta.metadataOffset = readUvarint()
+	indexLength := readUvarint()
+	meta.index = buff[offset : offset+indexLength]
+	offset += indexLength
+	numBlocks := readUvarint()
+	meta.blocks = make([]storageBlock, numBlocks)
+	storedOffset := 0
+	for i := 0; i < numBlocks; i++ {
+		meta.blocks[i].tableOffset = readUvarint()
+		meta.blocks[i].storedOffset = storedOffset
+		meta.blocks[i].storedLength = readUvarint()
+		storedOffset += meta.blocks[i].storedLength
+	}
+	return meta, nil
+}
+
+func (m *rangedMeta) blockContaining(tableOffset int) int {
+	return sort.Search(len(m.blocks), func(i int) bool {
+		return m.blocks[i].tableOffset > tableOffset
+	}) - 1
+}
+
+func appendCompressed(compressionType compress.CompressionType, buff []byte, data []byte) ([]byte, error) {
+	if compressionType == compress.CompressionTypeNone {
+		return append(buff, data...), nil
+	}
+	return compress.Compress(compressionType, buff, data)
+}
+
+func appendDecompressed(compressionType compress.CompressionType, buff []byte, data []byte) ([]byte, error) {
+	if compressionType == compress.CompressionTypeNone {
+		return append(buff, data...), nil
+	}
+	decompressed, err := compress.Decompress(compressionType, data)
+	if err != nil {
+		return nil, err
+	}
+	if buff == nil {
+		return decompressed, nil
+	}
+	return append(buff, decompressed...), nil
+}
+
+// concat returns a new slice, as the getter may return cached bytes which must not be appended to
+func concat(b1 []byte, b2 []byte) []byte {
+	buff := make([]byte, 0, len(b1)+len(b2))
+	buff = append(buff, b1...)
+	return append(buff, b2...)
+}
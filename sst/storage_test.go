@@ -0,0 +1,314 @@
+package sst
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/spirit-labs/tektite/common"
+	"github.com/spirit-labs/tektite/compress"
+	"github.com/spirit-labs/tektite/iteration"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var storageCompressionTypes = []compress.CompressionType{compress.CompressionTypeNone, compress.CompressionTypeLz4,
+	compress.CompressionTypeZstd}
+
+func TestStorageBytesRoundTrip(t *testing.T) {
+	forEachFormatAndCompressionType(t, func(t *testing.T, format common.DataFormat, compressionType compress.CompressionType) {
+		numEntries := 20000
+		table := buildStorageTestTable(t, format, numEntries)
+		require.Greater(t, len(table.blockOffsets()), 10)
+		storageBytes, err := table.ToStorageBytes(compressionType)
+		require.NoError(t, err)
+		require.Equal(t, TableDataFormatVersionRanged, int(binary.BigEndian.Uint16(storageBytes)))
+
+		table2, err := GetSSTableFromBytes(storageBytes)
+		require.NoError(t, err)
+		require.Equal(t, table.Serialize(), table2.Serialize())
+		require.Equal(t, table.format, table2.format)
+		require.Equal(t, table.numEntries, table2.numEntries)
+		require.Equal(t, table.indexOffset, table2.indexOffset)
+		require.Equal(t, table.creationTime, table2.creationTime)
+		requireEntries(t, table2, nil, nil, 0, numEntries-1)
+	})
+}
+
+func TestGetSSTableFromBytesV1(t *testing.T) {
+	for _, compressionType := range storageCompressionTypes {
+		t.Run(compressionType.String(), func(t *testing.T) {
+			numEntries := 20000
+			table := buildStorageTestTable(t, common.DataFormatV1, numEntries)
+			storageBytes := toV1StorageBytes(t, table, compressionType)
+
+			table2, err := GetSSTableFromBytes(storageBytes)
+			require.NoError(t, err)
+			require.Equal(t, table.Serialize(), table2.Serialize())
+
+			// Ranged loading must fall back to loading the whole table
+			getter := &countingRangeGetter{storageBytes: storageBytes}
+			table3, err := GetSSTableForKeyRange([]byte("sst1"), getter.getRange, storageTestKey(100), storageTestKey(200))
+			require.NoError(t, err)
+			require.Equal(t, len(storageBytes), getter.bytesFetched)
+			requireEntries(t, table3, storageTestKey(100), storageTestKey(200), 100, 199)
+		})
+	}
+}
+
+func TestGetSSTableForKeyRange(t *testing.T) {
+	forEachFormatAndCompressionType(t, func(t *testing.T, format common.DataFormat, compressionType compress.CompressionType) {
+		numEntries := 20000
+		table := buildStorageTestTable(t, format, numEntries)
+		storageBytes, err := table.ToStorageBytes(compressionType)
+		require.NoError(t, err)
+
+		testRange := func(keyStart []byte, keyEnd []byte, firstExpected int, lastExpected int) {
+			getter := &countingRangeGetter{storageBytes: storageBytes}
+			partial, err := GetSSTableForKeyRange([]byte("sst1"), getter.getRange, keyStart, keyEnd)
+			require.NoError(t, err)
+			require.True(t, partial.partial)
+			requireEntries(t, partial, keyStart, keyEnd, firstExpected, lastExpected)
+		}
+		testRange(nil, nil, 0, numEntries-1)
+		testRange(storageTestKey(0), storageTestKey(1), 0, 0)
+		testRange(storageTestKey(5000), storageTestKey(5001), 5000, 5000)
+		testRange(storageTestKey(5000), storageTestKey(15000), 5000, 14999)
+		testRange(storageTestKey(15000), nil, 15000, numEntries-1)
+		testRange(nil, storageTestKey(3000), 0, 2999)
+		testRange(storageTestKey(numEntries-1), storageTestKey(numEntries+1), numEntries-1, numEntries-1)
+		testRange(storageTestKey(numEntries), nil, -1, -1)
+		testRange(storageTestKey(5000), storageTestKey(5000), -1, -1)
+		// Range that falls between the last key of a block and the first key of the next
+		testRange(append(storageTestKey(5000), 0), append(storageTestKey(5000), 1), -1, -1)
+	})
+}
+
+func TestGetSSTableForKeyRangeFetchesOnlyNeededBlocks(t *testing.T) {
+	for _, format := range dataFormats {
+		t.Run(fmt.Sprintf("format-%d", format), func(t *testing.T) {
+			testGetSSTableForKeyRangeFetchesOnlyNeededBlocks(t, format)
+		})
+	}
+}
+
+func testGetSSTableForKeyRangeFetchesOnlyNeededBlocks(t *testing.T, format common.DataFormat) {
+	numEntries := 20000
+	table := buildStorageTestTable(t, format, numEntries)
+	storageBytes, err := table.ToStorageBytes(compress.CompressionTypeLz4)
+	require.NoError(t, err)
+
+	getter := &countingRangeGetter{storageBytes: storageBytes}
+	partial, err := GetSSTableForKeyRange([]byte("sst1"), getter.getRange, storageTestKey(10000), storageTestKey(10010))
+	require.NoError(t, err)
+	requireEntries(t, partial, storageTestKey(10000), storageTestKey(10010), 10000, 10009)
+
+	// Reads to get the meta, then one more read for the single block containing the range
+	require.Equal(t, numMetaReads(storageBytes)+1, getter.numGets)
+	require.Less(t, len(partial.data), 2*storageBlockSize)
+	require.Less(t, getter.bytesFetched, len(storageBytes)/2)
+
+	// Can't iterate outside the loaded range
+	_, err = partial.NewIterator(storageTestKey(0), nil)
+	require.Error(t, err)
+	require.Panics(t, func() {
+		partial.Serialize()
+	})
+}
+
+func TestGetSSTableForKeyRangeSkipsTableWithBloomFilter(t *testing.T) {
+	// Keys are a 16 byte prefix, as for partition data, followed by a suffix
+	key := func(prefix int, i int) []byte {
+		return append(bloomTestPrefix(prefix), fmt.Sprintf("key-%06d", i)...)
+	}
+	it := &iteration.StaticIterator{}
+	for prefix := 0; prefix < 100; prefix += 2 {
+		for i := 0; i < 200; i++ {
+			it.AddKV(key(prefix, i), []byte(fmt.Sprintf("value-%06d", i)))
+		}
+	}
+	table, _, _, _, _, err := BuildSSTable(common.DataFormatV2, 0, 0, it)
+	require.NoError(t, err)
+	storageBytes, err := table.ToStorageBytes(compress.CompressionTypeLz4)
+	require.NoError(t, err)
+
+	// Prefix is in the table
+	getter := &countingRangeGetter{storageBytes: storageBytes}
+	partial, err := GetSSTableForKeyRange([]byte("sst1"), getter.getRange, bloomTestPrefix(10), bloomTestPrefix(11))
+	require.NoError(t, err)
+	require.Equal(t, numMetaReads(storageBytes)+1, getter.numGets)
+	iter, err := partial.NewIterator(bloomTestPrefix(10), bloomTestPrefix(11))
+	require.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		kv := requireIterNextValid(t, iter, true)
+		require.Equal(t, key(10, i), kv.Key)
+	}
+	requireIterNextValid(t, iter, false)
+
+	// Prefixes not in the table - only the meta is fetched
+	for prefix := 1; prefix < 100; prefix += 10 {
+		getter = &countingRangeGetter{storageBytes: storageBytes}
+		partial, err = GetSSTableForKeyRange([]byte("sst1"), getter.getRange, bloomTestPrefix(prefix), bloomTestPrefix(prefix+1))
+		require.NoError(t, err)
+		require.Equal(t, numMetaReads(storageBytes), getter.numGets)
+		requireIterNextValid(t, mustNewIterator(t, partial, bloomTestPrefix(prefix), bloomTestPrefix(prefix+1)), false)
+	}
+}
+
+func mustNewIterator(t *testing.T, table *SSTable, keyStart []byte, keyEnd []byte) iteration.Iterator {
+	iter, err := table.NewIterator(keyStart, keyEnd)
+	require.NoError(t, err)
+	return iter
+}
+
+func TestGetSSTableForKeyRangeNotFound(t *testing.T) {
+	getter := &countingRangeGetter{}
+	_, err := GetSSTableForKeyRange([]byte("sst1"), getter.getRange, nil, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot find sstable sst1")
+}
+
+func TestLazySSTableIteratorWithRangeGetter(t *testing.T) {
+	numEntries := 20000
+	table := buildStorageTestTable(t, common.DataFormatV2, numEntries)
+	storageBytes, err := table.ToStorageBytes(compress.CompressionTypeLz4)
+	require.NoError(t, err)
+	getter := &countingRangeGetter{storageBytes: storageBytes}
+	iter, err := NewLazySSTableIteratorWithRangeGetter([]byte("sst1"), getter.getRange, storageTestKey(700), storageTestKey(800))
+	require.NoError(t, err)
+	require.Equal(t, 0, getter.numGets)
+	for i := 700; i < 800; i++ {
+		kv := requireIterNextValid(t, iter, true)
+		require.Equal(t, storageTestKey(i), kv.Key)
+	}
+	requireIterNextValid(t, iter, false)
+	require.Equal(t, numMetaReads(storageBytes)+1, getter.numGets)
+}
+
+func TestLazySSTableIteratorSkipsTableWithCachedBloomFilter(t *testing.T) {
+	key := func(prefix int, i int) []byte {
+		return append(bloomTestPrefix(prefix), fmt.Sprintf("key-%06d", i)...)
+	}
+	it := &iteration.StaticIterator{}
+	for prefix := 0; prefix < 100; prefix += 2 {
+		for i := 0; i < 10; i++ {
+			it.AddKV(key(prefix, i), []byte(fmt.Sprintf("value-%06d", i)))
+		}
+	}
+	table, _, _, _, _, err := BuildSSTable(common.DataFormatV2, 0, 0, it)
+	require.NoError(t, err)
+	storageBytes, err := table.ToStorageBytes(compress.CompressionTypeLz4)
+	require.NoError(t, err)
+	rangeGetter := &countingRangeGetter{storageBytes: storageBytes}
+	numGets := 0
+	tableGetter := func(tableID SSTableID) (*SSTable, error) {
+		numGets++
+		return GetSSTableFromBytes(storageBytes)
+	}
+	newIters := []func(tableID SSTableID, keyStart []byte, keyEnd []byte) (iteration.Iterator, error){
+		func(tableID SSTableID, keyStart []byte, keyEnd []byte) (iteration.Iterator, error) {
+			return NewLazySSTableIterator(tableID, tableGetter, keyStart, keyEnd)
+		},
+		func(tableID SSTableID, keyStart []byte, keyEnd []byte) (iteration.Iterator, error) {
+			return NewLazySSTableIteratorWithRangeGetter(tableID, rangeGetter.getRange, keyStart, keyEnd)
+		},
+	}
+	for i, newIter := range newIters {
+		tableID := SSTableID(fmt.Sprintf("%s%d", CreateSSTableId(), i))
+		getCount := func() int {
+			return numGets + rangeGetter.numGets
+		}
+		// Nothing is cached for the table until it has been loaded
+		iter, err := newIter(tableID, bloomTestPrefix(11), bloomTestPrefix(12))
+		require.NoError(t, err)
+		requireIterNextValid(t, iter, false)
+		require.Greater(t, getCount(), 0)
+
+		// Now prefixes that are not in the table don't fetch it
+		before := getCount()
+		for prefix := 1; prefix < 100; prefix += 10 {
+			iter, err = newIter(tableID, bloomTestPrefix(prefix), bloomTestPrefix(prefix+1))
+			require.NoError(t, err)
+			requireIterNextValid(t, iter, false)
+		}
+		require.Equal(t, before, getCount())
+
+		// But prefixes that are still do
+		iter, err = newIter(tableID, bloomTestPrefix(10), bloomTestPrefix(11))
+		require.NoError(t, err)
+		for j := 0; j < 10; j++ {
+			kv := requireIterNextValid(t, iter, true)
+			require.Equal(t, key(10, j), kv.Key)
+		}
+		requireIterNextValid(t, iter, false)
+		require.Greater(t, getCount(), before)
+	}
+}
+
+func numMetaReads(storageBytes []byte) int {
+	if rangedHeaderSize+int(binary.BigEndian.Uint32(storageBytes[3:])) > initialReadSize {
+		return 2
+	}
+	return 1
+}
+
+type countingRangeGetter struct {
+	storageBytes []byte
+	numGets      int
+	bytesFetched int
+}
+
+func (c *countingRangeGetter) getRange(_ SSTableID, offset int64, length int64) ([]byte, error) {
+	c.numGets++
+	if offset >= int64(len(c.storageBytes)) {
+		return []byte{}, nil
+	}
+	end := offset + length
+	if end > int64(len(c.storageBytes)) {
+		end = int64(len(c.storageBytes))
+	}
+	c.bytesFetched += int(end - offset)
+	return c.storageBytes[offset:end], nil
+}
+
+func forEachFormatAndCompressionType(t *testing.T, test func(*testing.T, common.DataFormat, compress.CompressionType)) {
+	for _, format := range dataFormats {
+		for _, compressionType := range storageCompressionTypes {
+			t.Run(fmt.Sprintf("format-%d-%s", format, compressionType.String()), func(t *testing.T) {
+				test(t, format, compressionType)
+			})
+		}
+	}
+}
+
+func buildStorageTestTable(t *testing.T, format common.DataFormat, numEntries int) *SSTable {
+	it := prepareInput([]byte("keyprefix/"), []byte("valueprefix/"), numEntries)
+	table, _, _, _, _, err := BuildSSTable(format, 0, 0, it)
+	require.NoError(t, err)
+	return table
+}
+
+func storageTestKey(i int) []byte {
+	return []byte(fmt.Sprintf("keyprefix/somekey-%010d", i))
+}
+
+// toV1StorageBytes creates the storage bytes as they were written before TableDataFormatVersionRanged
+func toV1StorageBytes(t *testing.T, table *SSTable, compressionType compress.CompressionType) []byte {
+	buff := binary.BigEndian.AppendUint16(nil, uint16(TableDataFormatVersion))
+	buff = append(buff, byte(compressionType))
+	buff, err := appendCompressed(compressionType, buff, table.Serialize())
+	require.NoError(t, err)
+	return buff
+}
+
+func requireEntries(t *testing.T, table *SSTable, keyStart []byte, keyEnd []byte, firstExpected int, lastExpected int) {
+	t.Helper()
+	iter, err := table.NewIterator(keyStart, keyEnd)
+	require.NoError(t, err)
+	if firstExpected != -1 {
+		for i := firstExpected; i <= lastExpected; i++ {
+			kv := requireIterNextValid(t, iter, true)
+			require.Equal(t, storageTestKey(i), kv.Key)
+			require.Equal(t, fmt.Sprintf("valueprefix/somevalue-%010d", i), string(kv.Value))
+		}
+	}
+	requireIterNextValid(t, iter, false)
+}
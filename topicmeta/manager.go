@@ -225,17 +225,34 @@ func (m *Manager) loadAllTopicsFromStorageWithRetry() ([]TopicInfo, error) {
 }
 
 func (m *Manager) loadAllTopicsFromStorage() ([]TopicInfo, error) {
-	keyEnd := common.IncBigEndianBytes(m.dataPrefix)
 	tg := &tableGetter{
 		bucketName: m.dataBucketName,
 		objStore:   m.objStore,
 	}
-	mi, err := queryutils.CreateIteratorForKeyRange(m.dataPrefix, keyEnd, m.lsm, tg.GetSSTable)
+	allTopics, err := LoadTopicInfos(m.lsm, tg.GetSSTable)
 	if err != nil {
 		return nil, err
 	}
-	if mi == nil {
+	if len(allTopics) > 0 {
+		m.topicIDSequence = int64(allTopics[len(allTopics)-1].ID + 1)
+	} else {
 		m.topicIDSequence = TopicIDSequenceBase
+	}
+	return allTopics, nil
+}
+
+// LoadTopicInfos loads the topic metadata, in topic id order, from the LSM the querier queries
+func LoadTopicInfos(querier queryutils.Querier, tableGetter sst.TableGetter) ([]TopicInfo, error) {
+	dataPrefix, err := parthash.CreateHash([]byte("topic.meta"))
+	if err != nil {
+		return nil, err
+	}
+	keyEnd := common.IncBigEndianBytes(dataPrefix)
+	mi, err := queryutils.CreateIteratorForKeyRange(dataPrefix, keyEnd, querier, tableGetter)
+	if err != nil {
+		return nil, err
+	}
+	if mi == nil {
 		return nil, nil
 	}
 	defer mi.Close()
@@ -256,22 +273,53 @@ func (m *Manager) loadAllTopicsFromStorage() ([]TopicInfo, error) {
 		info.Deserialize(kv.Value, 2)
 		allTopics = append(allTopics, info)
 	}
-	if len(allTopics) > 0 {
-		m.topicIDSequence = int64(allTopics[len(allTopics)-1].ID + 1)
-	} else {
-		m.topicIDSequence = TopicIDSequenceBase
-	}
 	return allTopics, nil
 }
 
-func (m *Manager) WriteTopic(topicInfo TopicInfo) error {
+// ReserveTopicID returns a new topic id without creating a topic. It is used to restore a topic, where the data must
+// be written before the topic is added with AddRestoredTopic.
+func (m *Manager) ReserveTopicID() (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.started {
+		return 0, errors.New("topicmeta manager not started")
+	}
+	topicID := int(m.topicIDSequence)
+	m.topicIDSequence++
+	return topicID, nil
+}
+
+// AddRestoredTopic adds a topic with an id from ReserveTopicID. Unlike CreateOrUpdateTopic, the topic metadata is not
+// written - register must atomically register it in the LSM, along with the data of the topic, with the KV returned by
+// TopicKV.
+func (m *Manager) AddRestoredTopic(topicInfo TopicInfo, register func() error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.topicInfosByName[topicInfo.Name]; ok {
+		return common.NewTektiteErrorf(common.TopicAlreadyExists, "topic: %s already exists", topicInfo.Name)
+	}
+	if err := register(); err != nil {
+		return err
+	}
+	m.topicInfosByName[topicInfo.Name] = &topicInfo
+	m.topicInfosByID[topicInfo.ID] = &topicInfo
+	m.SendTopicNotification(transport.HandlerIDMetaLocalCacheTopicAdded, topicInfo)
+	return nil
+}
+
+// TopicKV returns the KV the topic metadata is stored as
+func (m *Manager) TopicKV(topicInfo TopicInfo) common.KV {
 	key := encoding.KeyEncodeInt(m.dataPrefix, int64(topicInfo.ID))
 	key = encoding.EncodeVersion(key, 0)
 	// Encode a version number before the data
 	value := binary.BigEndian.AppendUint16(nil, topicMetadataVersion)
 	value = topicInfo.Serialize(value)
 	value = common.AppendValueMetadata(value)
-	return m.kvWriter([]common.KV{{Key: key, Value: value}})
+	return common.KV{Key: key, Value: value}
+}
+
+func (m *Manager) WriteTopic(topicInfo TopicInfo) error {
+	return m.kvWriter([]common.KV{m.TopicKV(topicInfo)})
 }
 
 func (m *Manager) WriteTopicDeletion(topicID int) error {
@@ -252,8 +252,14 @@ func (t *TestProcessor) GetReplicator() proc.Replicator {
 	return nil
 }
 
-func (t *TestProcessor) SubmitAction(func() error) bool {
-	return false
+func (t *TestProcessor) SubmitAction(action func() error) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return false
+	}
+	t.ingestCh <- ingestBatchHolder{action: action}
+	return true
 }
 
 func (t *TestProcessor) IsStopped() bool {
@@ -302,8 +308,9 @@ func newTestProcessor(id int, tpm *TestProcessorManager) *TestProcessor {
 }
 
 type ingestBatchHolder struct {
-	pb *proc.ProcessBatch
-	cf func(error)
+	pb     *proc.ProcessBatch
+	cf     func(error)
+	action func() error
 }
 
 func (t *TestProcessor) ID() int {
@@ -343,6 +350,12 @@ func (t *TestProcessor) eventLoop() {
 	defer t.stopWg.Done()
 	t.goID = routine.Goid()
 	for holder := range t.ingestCh {
+		if holder.action != nil {
+			if err := holder.action(); err != nil {
+				log.Errorf("failed to run processor action %v", err)
+			}
+			continue
+		}
 		t.handleBatchHolder(holder)
 	}
 }
@@ -350,14 +363,19 @@ func (t *TestProcessor) eventLoop() {
 func (t *TestProcessor) handleBatchHolder(holder ingestBatchHolder) {
 	ver := int(atomic.LoadUint64(t.version))
 	holder.pb.Version = ver
-	ok, mb, forwardBatches, err := t.tpm.batchHandler.HandleProcessBatch(t, holder.pb, false)
+	holder.pb.ProcessInjected(func() error {
+		return t.processBatch(holder.pb)
+	}, t.submitFunc, holder.cf)
+}
+
+func (t *TestProcessor) processBatch(pb *proc.ProcessBatch) error {
+	ok, mb, forwardBatches, err := t.tpm.batchHandler.HandleProcessBatch(t, pb, false)
 	if err != nil {
-		holder.cf(err)
-		return
+		return err
 	}
 	if !ok {
 		log.Error("batch handler failed to find receiver")
-		return
+		return nil
 	}
 	if mb != nil {
 		if err := t.tpm.st.Write(mb); err != nil {
@@ -375,7 +393,16 @@ func (t *TestProcessor) handleBatchHolder(holder ingestBatchHolder) {
 			}
 		}
 	}
-	holder.cf(nil)
+	return nil
+}
+
+func (t *TestProcessor) submitFunc(f func()) {
+	if !t.SubmitAction(func() error {
+		f()
+		return nil
+	}) {
+		f()
+	}
 }
 
 func (t *TestProcessor) close() {
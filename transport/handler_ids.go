@@ -26,4 +26,7 @@ const (
 	HandlerIDFetchCacheGetTableBytes
 	HandlerIDTablePusherDirectWrite
 	HandlerIDTablePusherDirectProduce
+	HandlerIDSchemaRegistryWrite
+	HandlerIDFetchCacheGetTableBytesRange
+	HandlerIDFetchCacheWarmTables
 )
@@ -0,0 +1,353 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spirit-labs/tektite/asl/errwrap"
+	"github.com/tidwall/gjson"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values of nested types are represented as follows:
+//
+//	array<T>        []any, each element being the value of T or nil
+//	map<string,T>   map[string]any, each value being the value of T or nil
+//	struct<...>     []any, with one entry per field in field order, each being the value of the field type or nil
+//
+// Scalar values inside nested values use the same representation as columns: int64, float64, bool, Decimal, string,
+// []byte and Timestamp.
+
+type ArrayType struct {
+	ElementType ColumnType
+}
+
+func (a *ArrayType) ID() ColumnTypeID {
+	return ColumnTypeIDArray
+}
+
+func (a *ArrayType) String() string {
+	return fmt.Sprintf("array<%s>", a.ElementType.String())
+}
+
+// MapType is a map with string keys.
+type MapType struct {
+	ValueType ColumnType
+}
+
+func (m *MapType) ID() ColumnTypeID {
+	return ColumnTypeIDMap
+}
+
+func (m *MapType) String() string {
+	return fmt.Sprintf("map<string,%s>", m.ValueType.String())
+}
+
+type StructType struct {
+	FieldNames []string
+	FieldTypes []ColumnType
+}
+
+func (s *StructType) ID() ColumnTypeID {
+	return ColumnTypeIDStruct
+}
+
+func (s *StructType) String() string {
+	var sb strings.Builder
+	sb.WriteString("struct<")
+	for i, name := range s.FieldNames {
+		if i > 0 {
+			sb.WriteRune(',')
+		}
+		sb.WriteString(name)
+		sb.WriteRune(':')
+		sb.WriteString(s.FieldTypes[i].String())
+	}
+	sb.WriteRune('>')
+	return sb.String()
+}
+
+// FieldIndex returns the index of the field with the specified name, or -1 if there is no such field.
+func (s *StructType) FieldIndex(name string) int {
+	for i, fieldName := range s.FieldNames {
+		if fieldName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsNestedType returns true if the column type is an array, map or struct.
+func IsNestedType(columnType ColumnType) bool {
+	switch columnType.ID() {
+	case ColumnTypeIDArray, ColumnTypeIDMap, ColumnTypeIDStruct:
+		return true
+	default:
+		return false
+	}
+}
+
+func isNestedTypeString(sColumnType string) bool {
+	return strings.HasPrefix(sColumnType, "array<") || strings.HasPrefix(sColumnType, "map<") ||
+		strings.HasPrefix(sColumnType, "struct<")
+}
+
+func parseNestedType(sColumnType string) (ColumnType, error) {
+	if !strings.HasSuffix(sColumnType, ">") {
+		return nil, errwrap.Errorf("invalid type '%s'", sColumnType)
+	}
+	start := strings.IndexRune(sColumnType, '<')
+	kind := sColumnType[:start]
+	inner := sColumnType[start+1 : len(sColumnType)-1]
+	parts, err := splitTypeList(inner)
+	if err != nil {
+		return nil, errwrap.Errorf("invalid type '%s': %v", sColumnType, err)
+	}
+	switch kind {
+	case "array":
+		if len(parts) != 1 {
+			return nil, errwrap.Errorf("invalid type '%s': array must have a single element type", sColumnType)
+		}
+		elementType, err := StringToColumnType(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		return &ArrayType{ElementType: elementType}, nil
+	case "map":
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != "string" {
+			return nil, errwrap.Errorf("invalid type '%s': map must have string keys, e.g. map<string,int>", sColumnType)
+		}
+		valueType, err := StringToColumnType(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return &MapType{ValueType: valueType}, nil
+	default:
+		if len(parts) == 0 {
+			return nil, errwrap.Errorf("invalid type '%s': struct must have at least one field", sColumnType)
+		}
+		structType := &StructType{}
+		for _, part := range parts {
+			index := strings.IndexRune(part, ':')
+			if index < 1 {
+				return nil, errwrap.Errorf("invalid type '%s': struct fields must be of the form name:type", sColumnType)
+			}
+			name := strings.TrimSpace(part[:index])
+			if structType.FieldIndex(name) != -1 {
+				return nil, errwrap.Errorf("invalid type '%s': duplicate struct field '%s'", sColumnType, name)
+			}
+			fieldType, err := StringToColumnType(strings.TrimSpace(part[index+1:]))
+			if err != nil {
+				return nil, err
+			}
+			structType.FieldNames = append(structType.FieldNames, name)
+			structType.FieldTypes = append(structType.FieldTypes, fieldType)
+		}
+		return structType, nil
+	}
+}
+
+// splitTypeList splits a comma separated list of types, ignoring commas inside nested types and decimal parameters.
+func splitTypeList(s string) ([]string, error) {
+	var parts []string
+	depth := 0
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '<', '(':
+			depth++
+		case '>', ')':
+			depth--
+			if depth < 0 {
+				return nil, errwrap.New("unbalanced brackets")
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errwrap.New("unbalanced brackets")
+	}
+	last := strings.TrimSpace(s[start:])
+	if last != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+	return parts, nil
+}
+
+func nestedTypesEqual(ct1 ColumnType, ct2 ColumnType) bool {
+	switch t1 := ct1.(type) {
+	case *ArrayType:
+		return ColumnTypesEqual(t1.ElementType, ct2.(*ArrayType).ElementType)
+	case *MapType:
+		return ColumnTypesEqual(t1.ValueType, ct2.(*MapType).ValueType)
+	case *StructType:
+		t2 := ct2.(*StructType)
+		if len(t1.FieldNames) != len(t2.FieldNames) {
+			return false
+		}
+		for i, name := range t1.FieldNames {
+			if name != t2.FieldNames[i] || !ColumnTypesEqual(t1.FieldTypes[i], t2.FieldTypes[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		panic("not a nested type")
+	}
+}
+
+// AppendJSON appends the value as JSON, in the same form as rows are written as JSON lines - decimals are written as
+// strings to preserve precision, bytes are written as strings and timestamps as milliseconds past the epoch. Structs are
+// written as objects with the fields in field order.
+func AppendJSON(buff []byte, colType ColumnType, val any) []byte {
+	if val == nil {
+		return append(buff, "null"...)
+	}
+	switch t := colType.(type) {
+	case *ArrayType:
+		buff = append(buff, '[')
+		for i, elem := range val.([]any) {
+			if i > 0 {
+				buff = append(buff, ',')
+			}
+			buff = AppendJSON(buff, t.ElementType, elem)
+		}
+		return append(buff, ']')
+	case *MapType:
+		m := val.(map[string]any)
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buff = append(buff, '{')
+		for i, k := range keys {
+			if i > 0 {
+				buff = append(buff, ',')
+			}
+			buff = appendJSONString(buff, k)
+			buff = append(buff, ':')
+			buff = AppendJSON(buff, t.ValueType, m[k])
+		}
+		return append(buff, '}')
+	case *StructType:
+		fields := val.([]any)
+		buff = append(buff, '{')
+		for i, name := range t.FieldNames {
+			if i > 0 {
+				buff = append(buff, ',')
+			}
+			buff = appendJSONString(buff, name)
+			buff = append(buff, ':')
+			buff = AppendJSON(buff, t.FieldTypes[i], fields[i])
+		}
+		return append(buff, '}')
+	}
+	switch colType.ID() {
+	case ColumnTypeIDInt:
+		return strconv.AppendInt(buff, val.(int64), 10)
+	case ColumnTypeIDFloat:
+		f := val.(float64)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return append(buff, "null"...)
+		}
+		return strconv.AppendFloat(buff, f, 'g', -1, 64)
+	case ColumnTypeIDBool:
+		return strconv.AppendBool(buff, val.(bool))
+	case ColumnTypeIDDecimal:
+		d := val.(Decimal)
+		return appendJSONString(buff, d.String())
+	case ColumnTypeIDString:
+		return appendJSONString(buff, val.(string))
+	case ColumnTypeIDBytes:
+		return appendJSONString(buff, string(val.([]byte)))
+	case ColumnTypeIDTimestamp:
+		return strconv.AppendInt(buff, val.(Timestamp).Val, 10)
+	default:
+		panic(fmt.Sprintf("unexpected type %s", colType.String()))
+	}
+}
+
+func appendJSONString(buff []byte, s string) []byte {
+	// Marshalling a string cannot fail
+	b, _ := json.Marshal(s)
+	return append(buff, b...)
+}
+
+// ParseJSON parses JSON into a value of the specified type, the reverse of AppendJSON. JSON values which do not match
+// the type are parsed as nil.
+func ParseJSON(buff []byte, colType ColumnType) any {
+	return jsonToValue(gjson.ParseBytes(buff), colType)
+}
+
+func jsonToValue(res gjson.Result, colType ColumnType) any {
+	if !res.Exists() || res.Type == gjson.Null {
+		return nil
+	}
+	switch t := colType.(type) {
+	case *ArrayType:
+		if !res.IsArray() {
+			return nil
+		}
+		arr := []any{}
+		res.ForEach(func(_, value gjson.Result) bool {
+			arr = append(arr, jsonToValue(value, t.ElementType))
+			return true
+		})
+		return arr
+	case *MapType:
+		if !res.IsObject() {
+			return nil
+		}
+		m := map[string]any{}
+		res.ForEach(func(key, value gjson.Result) bool {
+			m[key.String()] = jsonToValue(value, t.ValueType)
+			return true
+		})
+		return m
+	case *StructType:
+		if !res.IsObject() {
+			return nil
+		}
+		fields := make([]any, len(t.FieldNames))
+		res.ForEach(func(key, value gjson.Result) bool {
+			if index := t.FieldIndex(key.String()); index != -1 {
+				fields[index] = jsonToValue(value, t.FieldTypes[index])
+			}
+			return true
+		})
+		return fields
+	case *DecimalType:
+		sDec := res.String()
+		if res.Type == gjson.Number {
+			sDec = res.Raw
+		}
+		dec, err := NewDecimalFromString(sDec, t.Precision, t.Scale)
+		if err != nil {
+			return nil
+		}
+		return dec
+	}
+	switch colType.ID() {
+	case ColumnTypeIDInt:
+		return res.Int()
+	case ColumnTypeIDFloat:
+		return res.Float()
+	case ColumnTypeIDBool:
+		return res.Bool()
+	case ColumnTypeIDString:
+		return res.String()
+	case ColumnTypeIDBytes:
+		return []byte(res.String())
+	case ColumnTypeIDTimestamp:
+		return NewTimestamp(res.Int())
+	default:
+		panic("unexpected column type")
+	}
+}
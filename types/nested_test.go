@@ -0,0 +1,55 @@
+package types
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseNestedTypes(t *testing.T) {
+	for _, s := range []string{
+		"array<int>",
+		"map<string,decimal(10,2)>",
+		"struct<a:int,b:array<string>,c:map<string,struct<d:bool>>>",
+		"array<array<timestamp>>",
+	} {
+		colType, err := StringToColumnType(s)
+		require.NoError(t, err)
+		require.True(t, IsNestedType(colType))
+		require.Equal(t, s, colType.String())
+		colType2, err := StringToColumnType(s)
+		require.NoError(t, err)
+		require.True(t, ColumnTypesEqual(colType, colType2))
+	}
+}
+
+func TestParseInvalidNestedTypes(t *testing.T) {
+	for _, s := range []string{
+		"array<>",
+		"array<foo>",
+		"map<int,string>",
+		"struct<a>",
+		"struct<a:int,a:string>",
+		"array<int",
+	} {
+		_, err := StringToColumnType(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestNestedTypesNotEqual(t *testing.T) {
+	t1, err := StringToColumnType("struct<a:int,b:string>")
+	require.NoError(t, err)
+	t2, err := StringToColumnType("struct<a:int,c:string>")
+	require.NoError(t, err)
+	require.False(t, ColumnTypesEqual(t1, t2))
+	require.False(t, ColumnTypesEqual(&ArrayType{ElementType: ColumnTypeInt}, &ArrayType{ElementType: ColumnTypeFloat}))
+}
+
+func TestNestedJSONRoundTrip(t *testing.T) {
+	colType, err := StringToColumnType("struct<id:int,tags:array<string>,attrs:map<string,float>,ok:bool>")
+	require.NoError(t, err)
+	val := []any{int64(1), []any{"a", nil}, map[string]any{"x": 1.5}, nil}
+	buff := AppendJSON(nil, colType, val)
+	require.Equal(t, `{"id":1,"tags":["a",null],"attrs":{"x":1.5},"ok":null}`, string(buff))
+	require.Equal(t, val, ParseJSON(buff, colType))
+}
@@ -25,6 +25,9 @@ const (
 	ColumnTypeIDString
 	ColumnTypeIDBytes
 	ColumnTypeIDTimestamp
+	ColumnTypeIDArray
+	ColumnTypeIDMap
+	ColumnTypeIDStruct
 )
 
 var ColumnTypeInt = &nonParameterizedType{id: ColumnTypeIDInt}
@@ -83,6 +86,12 @@ func StringToColumnType(sColumnType string) (ColumnType, error) {
 				return nil, err
 			}
 			cType = decType
+		} else if isNestedTypeString(sColumnType) {
+			nestedType, err := parseNestedType(sColumnType)
+			if err != nil {
+				return nil, err
+			}
+			cType = nestedType
 		} else {
 			return nil, errwrap.Errorf("invalid type '%s'", sColumnType)
 		}
@@ -145,6 +154,9 @@ func ColumnTypesEqual(ct1 ColumnType, ct2 ColumnType) bool {
 	if ct1.ID() != ct2.ID() {
 		return false
 	}
+	if IsNestedType(ct1) {
+		return nestedTypesEqual(ct1, ct2)
+	}
 	d1, ok1 := ct1.(*DecimalType)
 	d2, ok2 := ct2.(*DecimalType)
 	if !ok1 && !ok2 {
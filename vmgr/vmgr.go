@@ -13,6 +13,7 @@ import (
 	"github.com/spirit-labs/tektite/sequence"
 	"math"
 	"reflect"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -40,6 +41,7 @@ type VersionManager struct {
 	disableFlush           bool
 	lastVersionToFlush     int // This is the last version all processors have called in as flushed
 	lastFlushedVersion     int // This is the last version that has been reliably flushed to the level manager
+	oldestRetainedVersion  int // This is the oldest version that point-in-time queries can be executed at
 	flushingClusterVersion int
 	flushedEntries         map[int]int
 	seqMgr                 sequence.Manager
@@ -50,9 +52,16 @@ type VersionManager struct {
 	shutdownWg             *sync.WaitGroup
 	activateWg             sync.WaitGroup
 	stuckVersionTimer      *common.TimerHandle
+	versionTimes           []versionTime
 	failureInfo
 }
 
+// versionTime is an entry in the version->time index, it records the time at which a version completed.
+type versionTime struct {
+	version int
+	time    int64 // millis past epoch
+}
+
 type Client interface {
 	// GetVersions returns the versions (current, lastCompleted, lastFlushed), from the version manager.
 	// This must block until the version manager is available
@@ -64,6 +73,9 @@ type Client interface {
 	GetLastFailureFlushedVersion(clusterVersion int) (int, error)
 	FailureComplete(liveProcessorCount int, clusterVersion int) error
 	IsFailureComplete(clusterVersion int) (bool, error)
+	// GetVersionForTime returns the last version that completed at or before the specified time, in millis past epoch,
+	// or -1 if there is no such version in the retained history
+	GetVersionForTime(unixMillis int64) (int, error)
 	Start() error
 	Stop() error
 }
@@ -84,6 +96,7 @@ func NewVersionManager(seqMgr sequence.Manager, levelMgrClient levels.Client, cf
 		lastCompletedVersion:   -1,
 		lastVersionToFlush:     -1,
 		lastFlushedVersion:     -1,
+		oldestRetainedVersion:  -1,
 		flushingClusterVersion: -1,
 		shutdownFlushVersion:   -1,
 	}
@@ -220,6 +233,9 @@ func (v *VersionManager) doActivate() error {
 	log.Debugf("vmgr loaded last flushed version as %d", lfv)
 	v.lastFlushedVersion = int(lfv)
 	v.lastVersionToFlush = v.lastFlushedVersion
+	// Compaction can't have removed versions at or after the last flushed version, so until we have loaded the oldest
+	// retained version from the level manager we use that
+	v.oldestRetainedVersion = v.lastFlushedVersion
 	v.remotingClient = remoting.NewClient(v.cfg.ClusterTlsConfig)
 	err := v.setNextCurrentVersion()
 	if err != nil {
@@ -430,6 +446,38 @@ func (v *VersionManager) GetLastCompletedVersion() (int, error) {
 	return v.lastCompletedVersion, nil
 }
 
+func (v *VersionManager) GetVersionForTime(unixMillis int64) (int, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if err := v.checkActive(); err != nil {
+		return 0, err
+	}
+	// Find the first entry that completed after the time, the entry before it is the one we want
+	i := sort.Search(len(v.versionTimes), func(i int) bool {
+		return v.versionTimes[i].time > unixMillis
+	})
+	if i == 0 {
+		// Either the time is before the history we retain, or before this version manager was activated
+		return -1, nil
+	}
+	return v.versionTimes[i-1].version, nil
+}
+
+// addVersionTime adds a completed version to the version->time index. Entries are kept for the history retention
+// period so that point-in-time queries can resolve a time to a version. The index is not persisted, so after a
+// version manager fails over, times before it was activated cannot be resolved.
+func (v *VersionManager) addVersionTime(version int, unixMillis int64) {
+	v.versionTimes = append(v.versionTimes, versionTime{version: version, time: unixMillis})
+	cutoff := unixMillis - v.cfg.HistoryRetention.Milliseconds()
+	// We keep the newest entry at or before the cutoff, as that is the version that was visible at the cutoff time
+	i := sort.Search(len(v.versionTimes), func(i int) bool {
+		return v.versionTimes[i].time > cutoff
+	})
+	if i > 1 {
+		v.versionTimes = v.versionTimes[i-1:]
+	}
+}
+
 func (v *VersionManager) broadcastVersionsAsync() {
 	common.Go(func() {
 		v.lock.Lock()
@@ -554,6 +602,7 @@ func (v *VersionManager) VersionComplete(version int, requiredCompletions int, c
 		}
 		// We only complete at the current version. Processor managers never unilaterally increment current version
 		v.lastCompletedVersion = v.currentVersion
+		v.addVersionTime(v.lastCompletedVersion, time.Now().UnixMilli())
 		if err := v.setNextCurrentVersion(); err != nil {
 			return err
 		}
@@ -628,9 +677,10 @@ func (v *VersionManager) doBroadcastVersions() {
 			log.Debugf("failed to broadcast versions %v", err)
 		}
 	}, &clustermsgs.VersionsMessage{
-		CurrentVersion:   int64(v.currentVersion),
-		CompletedVersion: int64(v.lastCompletedVersion),
-		FlushedVersion:   int64(v.lastFlushedVersion),
+		CurrentVersion:        int64(v.currentVersion),
+		CompletedVersion:      int64(v.lastCompletedVersion),
+		FlushedVersion:        int64(v.lastFlushedVersion),
+		OldestRetainedVersion: int64(v.oldestRetainedVersion),
 	}, v.serverAddresses...)
 }
 
@@ -665,16 +715,23 @@ func (v *VersionManager) storeLastFlushedVersion() {
 	defer v.scheduleLastFlushedVersion(false)
 	lvf := v.lastVersionToFlush
 	log.Debugf("vmgr last version to flush is %d", lvf)
-	if lvf == -1 || lvf == v.lastFlushedVersion {
-		// Already flushed this version, or nothing flushed yet
-		return
+	// Nothing to store if nothing has been flushed yet, or this version has already been stored
+	if lvf != -1 && lvf != v.lastFlushedVersion {
+		if err := v.levelMgrClient.StoreLastFlushedVersion(int64(lvf)); err != nil {
+			log.Warnf("vmgr:%p failed to store last flushed version: %v", v, err)
+			return
+		}
+		v.lastFlushedVersion = lvf
+		log.Debugf("vmgr: version %d has been flushed to level manager", lvf)
 	}
-	if err := v.levelMgrClient.StoreLastFlushedVersion(int64(lvf)); err != nil {
-		log.Warnf("vmgr:%p failed to store last flushed version: %v", v, err)
+	// The oldest retained version advances as compaction can remove older versions, it's broadcast with the other
+	// versions so point-in-time queries can be checked without contacting the level manager
+	oldest, err := v.levelMgrClient.GetOldestRetainedVersion()
+	if err != nil {
+		log.Warnf("vmgr:%p failed to load oldest retained version: %v", v, err)
 		return
 	}
-	v.lastFlushedVersion = lvf
-	log.Debugf("vmgr: version %d has been flushed to level manager", lvf)
+	v.oldestRetainedVersion = int(oldest)
 }
 
 func (v *VersionManager) SetClusterMessageHandlers(remotingServer remoting.Server) {
@@ -685,6 +742,7 @@ func (v *VersionManager) SetClusterMessageHandlers(remotingServer remoting.Serve
 	remotingServer.RegisterBlockingMessageHandler(remoting.ClusterMessageGetLastFailureFlushedVersionMessage, &getLastFailureFlushedVersionHandler{v: v})
 	remotingServer.RegisterBlockingMessageHandler(remoting.ClusterMessageFailureCompleteMessage, &failureCompleteHandler{v: v})
 	remotingServer.RegisterBlockingMessageHandler(remoting.ClusterMessageIsFailureCompleteMessage, &isFailureCompleteHandler{v: v})
+	remotingServer.RegisterBlockingMessageHandler(remoting.ClusterMessageGetVersionForTimeMessage, &getVersionForTimeHandler{v: v})
 
 }
 
@@ -747,6 +805,19 @@ func (i *isFailureCompleteHandler) HandleMessage(messageHolder remoting.MessageH
 	return &clustermsgs.IsFailureCompleteResponse{Complete: complete}, nil
 }
 
+type getVersionForTimeHandler struct {
+	v *VersionManager
+}
+
+func (g *getVersionForTimeHandler) HandleMessage(messageHolder remoting.MessageHolder) (remoting.ClusterMessage, error) {
+	msg := messageHolder.Message.(*clustermsgs.GetVersionForTimeMessage)
+	ver, err := g.v.GetVersionForTime(msg.Time)
+	if err != nil {
+		return nil, err
+	}
+	return &clustermsgs.GetVersionForTimeResponse{Version: int64(ver)}, nil
+}
+
 type versionCompleteHandler struct {
 	v *VersionManager
 }
@@ -53,6 +53,61 @@ func TestCompleteVersion(t *testing.T) {
 	}
 }
 
+func TestGetVersionForTime(t *testing.T) {
+	cfg := &conf.Config{}
+	cfg.ApplyDefaults()
+	cfg.HistoryRetention = 1 * time.Hour
+	vmgr, remotingServer, _ := setup(t, cfg)
+	defer func() {
+		stopVmgr(t, vmgr)
+		err := remotingServer.Stop()
+		require.NoError(t, err)
+	}()
+
+	start := time.Now().UnixMilli()
+	err := vmgr.VersionComplete(0, 1, 0, false)
+	require.NoError(t, err)
+	ver, err := vmgr.GetVersionForTime(time.Now().UnixMilli())
+	require.NoError(t, err)
+	require.Equal(t, 0, ver)
+	// Before any version completed
+	ver, err = vmgr.GetVersionForTime(start - 1)
+	require.NoError(t, err)
+	require.Equal(t, -1, ver)
+
+	vmgr.lock.Lock()
+	vmgr.versionTimes = nil
+	base := int64(1_000_000)
+	minute := time.Minute.Milliseconds()
+	for i := 0; i < 10; i++ {
+		vmgr.addVersionTime(i, base+int64(i)*minute)
+	}
+	vmgr.lock.Unlock()
+
+	ver, err = vmgr.GetVersionForTime(base + 3*minute)
+	require.NoError(t, err)
+	require.Equal(t, 3, ver)
+	ver, err = vmgr.GetVersionForTime(base + 3*minute + 1)
+	require.NoError(t, err)
+	require.Equal(t, 3, ver)
+	ver, err = vmgr.GetVersionForTime(base + 100*minute)
+	require.NoError(t, err)
+	require.Equal(t, 9, ver)
+
+	// Versions completed more than the history retention before the last one are removed from the index, apart from
+	// the one visible at the start of the retention period
+	vmgr.lock.Lock()
+	vmgr.addVersionTime(10, base+70*minute)
+	require.Equal(t, 2, len(vmgr.versionTimes))
+	vmgr.lock.Unlock()
+	ver, err = vmgr.GetVersionForTime(base + 15*minute)
+	require.NoError(t, err)
+	require.Equal(t, 9, ver)
+	ver, err = vmgr.GetVersionForTime(base + 5*minute)
+	require.NoError(t, err)
+	require.Equal(t, -1, ver)
+}
+
 func TestIgnoreOlderVersions(t *testing.T) {
 	cfg := &conf.Config{}
 	cfg.ApplyDefaults()
@@ -131,6 +186,33 @@ func TestPeriodicBroadcast(t *testing.T) {
 
 }
 
+func TestBroadcastOldestRetainedVersion(t *testing.T) {
+	cfg := &conf.Config{}
+	cfg.ApplyDefaults()
+	cfg.VersionCompletedBroadcastInterval = 10 * time.Millisecond
+	cfg.VersionManagerStoreFlushedInterval = 10 * time.Millisecond
+	seqMgr := sequence.NewInMemSequenceManager()
+	vmgr, remotingServer, vHandler, lmgrClient := setupWithSeqMgrWithActivate(t, seqMgr, cfg, true)
+	defer func() {
+		stopVmgr(t, vmgr)
+		err := remotingServer.Stop()
+		require.NoError(t, err)
+	}()
+
+	msg := <-vHandler.ch
+	require.Equal(t, -1, int(msg.OldestRetainedVersion))
+
+	// The oldest retained version is loaded from the level manager when the version manager stores the last flushed
+	// version, and broadcast with the other versions
+	lmgrClient.setOldestRetainedVersion(3)
+	ok, err := testutils.WaitUntilWithError(func() (bool, error) {
+		msg := <-vHandler.ch
+		return msg.OldestRetainedVersion == 3, nil
+	}, 5*time.Second, 1*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
 func TestResumeCurrentVersionAtLastSequence(t *testing.T) {
 	seqMgr := sequence.NewInMemSequenceManager()
 
@@ -1392,7 +1474,7 @@ func setupWithSeqMgrWithActivate(t *testing.T, seqMgr sequence.Manager, cfg *con
 	err := remotingServer.Start()
 	require.NoError(t, err)
 
-	lmgrClient := &testLevelMgrClient{lastFlushedVersion: -1}
+	lmgrClient := &testLevelMgrClient{lastFlushedVersion: -1, oldestRetainedVersion: -1}
 
 	vmgr := NewVersionManager(seqMgr, lmgrClient, cfg, "localhost:7888")
 	err = vmgr.Start()
@@ -1426,10 +1508,11 @@ func mustGetCurrentVersion(vMgr *VersionManager) int {
 }
 
 type testLevelMgrClient struct {
-	lock               sync.Mutex
-	lastFlushedVersion int64
-	unavailable        atomic.Bool
-	deadVersionRange   *levels.VersionRange
+	lock                  sync.Mutex
+	lastFlushedVersion    int64
+	oldestRetainedVersion int64
+	unavailable           atomic.Bool
+	deadVersionRange      *levels.VersionRange
 }
 
 func (t *testLevelMgrClient) GetStats() (levels.Stats, error) {
@@ -1496,6 +1579,21 @@ func (t *testLevelMgrClient) LoadLastFlushedVersion() (int64, error) {
 	return t.lastFlushedVersion, nil
 }
 
+func (t *testLevelMgrClient) GetOldestRetainedVersion() (int64, error) {
+	if t.unavailable.Load() {
+		return 0, common.NewTektiteErrorf(common.Unavailable, "unavailable")
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.oldestRetainedVersion, nil
+}
+
+func (t *testLevelMgrClient) setOldestRetainedVersion(version int64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.oldestRetainedVersion = version
+}
+
 func (t *testLevelMgrClient) RegisterSlabRetention(slabID int, retention time.Duration) error {
 	panic("not implemented")
 
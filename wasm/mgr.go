@@ -392,7 +392,8 @@ func (r *RegisteredModule) close() {
 
 func wasmTypeForTektiteType(tt types.ColumnType) api.ValueType {
 	switch tt.ID() {
-	case types.ColumnTypeIDInt, types.ColumnTypeIDTimestamp, types.ColumnTypeIDString, types.ColumnTypeIDBytes, types.ColumnTypeIDDecimal:
+	case types.ColumnTypeIDInt, types.ColumnTypeIDTimestamp, types.ColumnTypeIDString, types.ColumnTypeIDBytes, types.ColumnTypeIDDecimal,
+		types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
 		return api.ValueTypeI64
 	case types.ColumnTypeIDFloat:
 		return api.ValueTypeF64
@@ -472,6 +473,17 @@ func (ii *Invoker) Invoke(inArgs []any) (any, error) {
 		case types.ColumnTypeIDTimestamp:
 			val := inArg.(types.Timestamp).Val
 			args = append(args, uint64(val))
+		case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+			// Nested values are passed as JSON
+			arg, freeFunc, err := ii.prepareBytesArg(types.AppendJSON(nil, argType, inArg), ctx)
+			if err != nil {
+				return nil, err
+			}
+			if freeFunc != nil {
+				//goland:noinspection GoDeferInLoop
+				defer freeFunc()
+			}
+			args = append(args, arg)
 		default:
 			panic("unexpected type")
 		}
@@ -522,6 +534,21 @@ func (ii *Invoker) Invoke(inArgs []any) (any, error) {
 		return bytes, nil
 	case types.ColumnTypeIDTimestamp:
 		return types.NewTimestamp(int64(res)), nil
+	case types.ColumnTypeIDArray, types.ColumnTypeIDMap, types.ColumnTypeIDStruct:
+		// Nested values are returned as JSON
+		bytes, freeFunc, err := ii.decodeBytesReturn(res, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if freeFunc != nil {
+			defer freeFunc()
+		}
+		val := types.ParseJSON(bytes, ii.meta.ReturnType)
+		if val == nil {
+			return nil, common.NewTektiteErrorf(common.WasmError, "wasm function %s returned invalid JSON for type %s",
+				ii.f.Definition().Name(), ii.meta.ReturnType.String())
+		}
+		return val, nil
 	default:
 		panic("unexpected type")
 	}